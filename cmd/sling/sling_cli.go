@@ -3,12 +3,14 @@ package main
 import (
 	"context"
 	"embed"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
 	"runtime/debug"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -23,6 +25,7 @@ import (
 	"github.com/flarco/g/net"
 	"github.com/integrii/flaggy"
 	"github.com/slingdata-io/sling-cli/core/dbio/database"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
 	"github.com/spf13/cast"
 )
 
@@ -32,9 +35,11 @@ var examples = ``
 var ctx = g.NewContext(context.Background())
 var telemetry = true
 var interrupted = false
+var runningTask atomic.Pointer[sling.TaskExecution] // read by the signal handlers
 var machineID = ""
 
 func init() {
+	env.LoadProject() // before the logger, the project env may set its options
 	env.InitLogger()
 	store.InitDB()
 }
@@ -90,7 +95,7 @@ var cliRunFlags = []g.Flag{
 		Name:        "select",
 		ShortName:   "s",
 		Type:        "string",
-		Description: "Select or exclude specific columns from the source stream. (comma separated). Use '-' prefix to exclude.",
+		Description: "Select or exclude specific columns from the source stream. (comma separated). Use '-' prefix to exclude. Reference columns by position with '@N' (e.g. '@1 as id') for headerless files.",
 	},
 	{
 		Name:        "transforms",
@@ -116,17 +121,35 @@ var cliRunFlags = []g.Flag{
 		Type:        "bool",
 		Description: "Output the stream to standard output (STDOUT).",
 	},
+	{
+		Name:        "stdout-flush-interval",
+		ShortName:   "",
+		Type:        "string",
+		Description: "With --stdout, flush the buffered rows at this interval (e.g. `1s`, or seconds), for real-time consumers.",
+	},
+	{
+		Name:        "stdout-flush-rows",
+		ShortName:   "",
+		Type:        "string",
+		Description: "With --stdout, flush every N rows, for real-time consumers.",
+	},
 	{
 		Name:        "env",
 		ShortName:   "",
 		Type:        "string",
 		Description: "in-line environment variable object/map to pass in (JSON or YAML).",
 	},
+	{
+		Name:        "config-var",
+		ShortName:   "",
+		Type:        "string",
+		Description: "A variable `key=value` for the SQL templates (`{{ .vars.key }}`) and the replication (`{key}`),\n                       overriding the replication env defaults. Repeatable (e.g. `--config-var region=us-east-1`).",
+	},
 	{
 		Name:        "mode",
 		ShortName:   "m",
 		Type:        "string",
-		Description: "The target load mode to use: backfill, incremental, truncate, snapshot, full-refresh.\n                       Default is full-refresh. For incremental, must provide `update-key` and `primary-key` values.\n                       All modes load into a new temp table on tgtConn prior to final load.",
+		Description: "The target load mode to use: backfill, incremental, truncate, snapshot, full-refresh.\n                       Default is full-refresh. For incremental, must provide `update-key` and `primary-key` values.\n                       All modes load into a new temp table on tgtConn prior to final load,\n                       unless target option `direct_insert` is true (not atomic).",
 	},
 	{
 		Name:        "limit",
@@ -140,12 +163,36 @@ var cliRunFlags = []g.Flag{
 		Type:        "string",
 		Description: "The number of rows to offset by.",
 	},
+	{
+		Name:        "timeout",
+		ShortName:   "",
+		Type:        "string",
+		Description: "The maximum duration to pull from a streaming source (e.g. Pub/Sub), such as `30s` or `5m`. Also the timeout of the extraction and of the load, capping the `extract_timeout` source option and `load_timeout` target option.",
+	},
 	{
 		Name:        "range",
 		ShortName:   "",
 		Type:        "string",
 		Description: "The range to use for backfill mode, separated by a single comma. Example: `2021-01-01,2021-02-01` or `1,10000`",
 	},
+	{
+		Name:        "since",
+		ShortName:   "",
+		Type:        "string",
+		Description: "Only extract rows with an update-key value from this date (inclusive): absolute (`2024-01-01`) or relative to now (`--since=-7d`). Independent of the incremental watermark.",
+	},
+	{
+		Name:        "until",
+		ShortName:   "",
+		Type:        "string",
+		Description: "Only extract rows with an update-key value before this date (exclusive): absolute or relative to now (`now`, `-1d`). Use with --since for a bounded window.",
+	},
+	{
+		Name:        "where",
+		ShortName:   "",
+		Type:        "string",
+		Description: "The filter on file sources, e.g. `dt >= 2021-01-01 and amount > 100`. Files are skipped from Hive-style partition keys, parquet row groups from their statistics. Sets the `where` source option.",
+	},
 	{
 		Name:        "primary-key",
 		ShortName:   "",
@@ -158,12 +205,192 @@ var cliRunFlags = []g.Flag{
 		Type:        "string",
 		Description: "The update key to use for incremental.\n",
 	},
+	{
+		Name:        "force-disabled",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "Run the streams selected with --streams, even if they are disabled.",
+	},
+	{
+		Name:        "resume-failed",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "Only run the streams of a replication which failed or did not run in the last run, skipping the successful ones.",
+	},
+	{
+		Name:        "force-all",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "Run all the streams of a replication, even with --resume-failed.",
+	},
+	{
+		Name:        "commit-on-interrupt",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "On interrupt, commit the rows already extracted into the target (for incremental/snapshot modes).",
+	},
+	{
+		Name:        "no-temp-cleanup",
+		ShortName:   "",
+		Type:        "string",
+		Description: "Keep the temp table of database targets for inspection, logging its name: on `failure` (default) or `always`.",
+	},
+	{
+		Name:        "no-cache",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "Do not use the local column cache (enabled with SLING_CACHE_TTL), re-discover the source columns.",
+	},
+	{
+		Name:        "explain",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "Print the query plan (EXPLAIN) of the extraction query, for database sources.",
+	},
+	{
+		Name:        "dry-run",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "Prepare the run without extracting or loading data (use with --explain to only show the query plan).",
+	},
+	{
+		Name:        "tmp-dir",
+		ShortName:   "",
+		Type:        "string",
+		Description: "The folder to write the staging files in (bulk load files, spills, compression buffers), instead of the system temp folder. Also SLING_TMP_DIR.",
+	},
+	{
+		Name:        "otel-endpoint",
+		ShortName:   "",
+		Type:        "string",
+		Description: "The OpenTelemetry (OTLP/HTTP) endpoint to export the run, stream and stage spans to (e.g. http://localhost:4318). Also SLING_OTEL_ENDPOINT.",
+	},
+	{
+		Name:        "state-conn",
+		ShortName:   "",
+		Type:        "string",
+		Description: "The connection to store the incremental state (watermark) in. Defaults to the target connection.",
+	},
+	{
+		Name:        "state-table",
+		ShortName:   "",
+		Type:        "string",
+		Description: "The table to store the incremental state (watermark) in, instead of querying the max value of the target table (default `_sling_state`).",
+	},
+	{
+		Name:        "schema-snapshot",
+		ShortName:   "",
+		Type:        "string",
+		Description: "The file path to record the source schema of streams in, and compare against on subsequent runs to detect schema drift.",
+	},
+	{
+		Name:        "on-schema-drift",
+		ShortName:   "",
+		Type:        "string",
+		Description: "The action when the source schema drifted from the schema snapshot: `error` (default) or `warn`.",
+	},
+	{
+		Name:        "validate-only",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "Only compare the source schema with the schema snapshot, without loading.",
+	},
+	{
+		Name:        "abort-on-empty-source",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "Fail the run if the source returns 0 rows, before truncating or replacing the target. Set `abort_on_empty` in the stream source options to override.",
+	},
+	{
+		Name:        "columns-lowercase-keys",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "Lowercase the keys of JSON source objects, so that keys with inconsistent casing merge into one column. Set `normalize_keys` in the source options to override.",
+	},
+	{
+		Name:        "force-grants",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "Apply the `grants` (target option) even when the target table already existed.",
+	},
+	{
+		Name:        "rename-target-columns",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "When `column_map` (target option) renames columns of an existing target table, rename the old target columns instead of failing. Set `rename_target_columns` in the target options to override.",
+	},
+	{
+		Name:        "max-memory",
+		ShortName:   "",
+		Type:        "string",
+		Description: "The memory limit of the row buffering stages: the type inference sample and the `order_by` sort (e.g. `512MB`). Beyond it, their rows are spilled to temporary files under SLING_HOME/tmp. Other stages stream rows in bounded batches.",
+	},
+	{
+		Name:        "append-only",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "With mode incremental, insert the new rows without a merge (for insert-only sources). Rows whose primary-key is already in the target are skipped. Set `append_only` in the target options to override.",
+	},
+	{
+		Name:        "no-create",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "Never issue DDL on the target (create, alter or drop). The target table must already exist with all the stream columns. Set `no_create` in the target options to override.",
+	},
+	{
+		Name:        "fail-on-warning",
+		ShortName:   "",
+		Type:        "string",
+		Description: "Fail the run (non-zero exit) at the end if warnings were logged, with a summary of them. Optionally only for a subset of warning categories (comma separated): types, schema, rejects, other.",
+	},
+	{
+		Name:        "on-success",
+		ShortName:   "",
+		Type:        "string",
+		Description: "A shell command executed after a successful run, with the variables SLING_STATUS, SLING_ROWS & SLING_DURATION (seconds) in its environment.",
+	},
+	{
+		Name:        "on-failure",
+		ShortName:   "",
+		Type:        "string",
+		Description: "A shell command executed after a failed run, with the variables SLING_STATUS, SLING_ROWS, SLING_DURATION (seconds) & SLING_ERROR in its environment.",
+	},
+	{
+		Name:        "strict-hooks",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "Fail the run if the --on-success / --on-failure command fails. By default, the failure is logged as a warning.",
+	},
+	{
+		Name:        "transform-plugin",
+		ShortName:   "",
+		Type:        "string",
+		Description: "The path of a plugin transforming each row: a Go plugin (`.so`, exporting `Transform(map[string]any) (map[string]any, error)`) or a WASM module (`.wasm`, run with SLING_WASM_RUNTIME, default `wasmtime run` which must be installed). Rows failed by the plugin fail the run, unless skipped with SLING_ON_CONSTRAINT_FAILURE=skip.",
+	},
+	{
+		Name:        "progress-format",
+		ShortName:   "",
+		Type:        "string",
+		Description: "The elements of the progress display (comma separated): elapsed, rows, percent, rate, bytes, eta. Default is `elapsed,rows,rate,bytes`. The percent & ETA need the row count: the catalog estimate of the table (postgres), or an exact count with SLING_PROGRESS_COUNT=true (otherwise indeterminate).",
+	},
+	{
+		Name:        "quiet",
+		ShortName:   "q",
+		Type:        "bool",
+		Description: "Suppress progress and info logs, only output warnings & errors.",
+	},
 	{
 		Name:        "debug",
 		ShortName:   "d",
 		Type:        "bool",
 		Description: "Set logging level to DEBUG.",
 	},
+	{
+		Name:        "print-config",
+		ShortName:   "",
+		Type:        "bool",
+		Description: "Print the resolved config (after defaults, env interpolation and connection defaults) as YAML, with secrets redacted, and exit without running.",
+	},
 	{
 		Name:        "examples",
 		ShortName:   "e",
@@ -192,6 +419,18 @@ var cliUpdate = &g.CliSC{
 	ExecProcess: updateCLI,
 }
 
+var cliSchema = &g.CliSC{
+	Name:        "schema",
+	Description: "Print the JSON Schema of config files, for editor autocompletion & validation",
+	SubComs: []*g.CliSC{
+		{
+			Name:        "replication",
+			Description: "print the JSON Schema of replication files",
+		},
+	},
+	ExecProcess: processSchema,
+}
+
 var cliConns = &g.CliSC{
 	Name:                  "conns",
 	Singular:              "local connection",
@@ -216,6 +455,12 @@ var cliConns = &g.CliSC{
 					Type:        "string",
 					Description: "filter stream name by glob pattern (e.g. schema.prefix_*, dir/*.csv, dir/**/*.json, */*/*.parquet)",
 				},
+				{
+					Name:        "regex",
+					ShortName:   "",
+					Type:        "string",
+					Description: "filter stream name by regular expression, matching the whole name or its last part (e.g. 'sales\\.orders_(19|20)\\d{2}'). Cannot be used with --pattern.",
+				},
 				{
 					Name:        "recursive",
 					ShortName:   "",
@@ -228,6 +473,30 @@ var cliConns = &g.CliSC{
 					Type:        "bool",
 					Description: "Show column level metadata.",
 				},
+				{
+					Name:        "stats",
+					ShortName:   "",
+					Type:        "bool",
+					Description: "Show type inference stats of file columns (sampled rows, value types seen, confidence). Uses SAMPLE_SIZE.",
+				},
+				{
+					Name:        "concurrency",
+					ShortName:   "",
+					Type:        "int",
+					Description: "Number of streams whose columns are discovered in parallel, with --columns (bounded by the connection concurrency).",
+				},
+				{
+					Name:        "timeout",
+					ShortName:   "",
+					Type:        "string",
+					Description: "Maximum duration to discover the columns of a single stream (e.g. 30s, 2m). Slower streams are skipped with a warning.",
+				},
+				{
+					Name:        "types",
+					ShortName:   "",
+					Type:        "string",
+					Description: "Comma-separated object types to discover: table, view, materialized_view & external (postgres). For files, the formats (e.g. csv,parquet,json).",
+				},
 			},
 		},
 		{
@@ -246,6 +515,18 @@ var cliConns = &g.CliSC{
 				},
 			},
 		},
+		{
+			Name:        "validate",
+			Description: "validate a connection url, showing the expected format if invalid",
+			PosFlags: []g.Flag{
+				{
+					Name:        "url",
+					ShortName:   "",
+					Type:        "string",
+					Description: "The connection url to validate",
+				},
+			},
+		},
 		{
 			Name:        "unset",
 			Description: "remove a connection from the sling env file",
@@ -272,7 +553,83 @@ var cliConns = &g.CliSC{
 					Name:        "key=value properties...",
 					ShortName:   "",
 					Type:        "string",
-					Description: "The key=value properties to set. See https://docs.slingdata.io/sling-cli/environment#set-connections",
+					Description: "The key=value properties to set. Use `default_source_options` / `default_target_options` (a JSON or YAML map) for options applied to every run. See https://docs.slingdata.io/sling-cli/environment#set-connections",
+				},
+			},
+		},
+		{
+			Name:        "cache",
+			Description: "manage the local column cache (enabled with SLING_CACHE_TTL)",
+			PosFlags: []g.Flag{
+				{
+					Name:        "action",
+					ShortName:   "",
+					Type:        "string",
+					Description: "The action to perform: clear",
+				},
+			},
+		},
+		{
+			Name:        "share",
+			Description: "print a connection's properties with secrets masked, to share with teammates",
+			PosFlags: []g.Flag{
+				{
+					Name:        "name",
+					ShortName:   "",
+					Type:        "string",
+					Description: "The name of the connection to share",
+				},
+			},
+			Flags: []g.Flag{
+				{
+					Name:        "reveal",
+					ShortName:   "",
+					Type:        "bool",
+					Description: "Do not mask secret values (asks for confirmation). Only use within a secure channel.",
+				},
+			},
+		},
+		{
+			Name:        "export",
+			Description: "export the env file connections, to import on another machine",
+			Flags: []g.Flag{
+				{
+					Name:        "output",
+					ShortName:   "o",
+					Type:        "string",
+					Description: "The YAML file path to write to. Prints to stdout if not provided",
+				},
+				{
+					Name:        "with-secrets",
+					ShortName:   "",
+					Type:        "bool",
+					Description: "Do not mask secret values. Only use within a secure channel.",
+				},
+			},
+		},
+		{
+			Name:        "import",
+			Description: "import connections from an export file into the env file",
+			PosFlags: []g.Flag{
+				{
+					Name:        "file",
+					ShortName:   "",
+					Type:        "string",
+					Description: "The YAML file path of exported connections",
+				},
+			},
+			Flags: []g.Flag{
+				{
+					Name:        "merge",
+					ShortName:   "",
+					Type:        "bool",
+					Description: "Add new connections, keeping existing ones with different properties (default)",
+				},
+				{
+					Name:        "replace",
+					ShortName:   "",
+					Type:        "bool",
+					Description: "Overwrite the existing connections with the same names as the imported ones (other connections are kept)",
 				},
 			},
 		},
@@ -293,6 +650,38 @@ var cliConns = &g.CliSC{
 					Description: "The SQL queries to execute. Can be in-line text or a file",
 				},
 			},
+			Flags: []g.Flag{
+				{
+					Name:        "limit",
+					ShortName:   "",
+					Type:        "int",
+					Description: "The maximum number of rows to display for a SELECT query (default 100).",
+				},
+				{
+					Name:        "no-limit",
+					ShortName:   "",
+					Type:        "bool",
+					Description: "Display all the rows of a SELECT query.",
+				},
+				{
+					Name:        "wide",
+					ShortName:   "",
+					Type:        "bool",
+					Description: "Do not truncate long cell values. Also --no-truncate-cells.",
+				},
+				{
+					Name:        "no-truncate-cells",
+					ShortName:   "",
+					Type:        "bool",
+					Description: "Do not truncate long cell values.",
+				},
+				{
+					Name:        "translate",
+					ShortName:   "",
+					Type:        "bool",
+					Description: "Translate the quoting, LIMIT / TOP and date functions of the queries into the connection dialect.",
+				},
+			},
 		},
 	},
 	ExecProcess: processConns,
@@ -346,6 +735,7 @@ func init() {
 	cliConns.Make().Add()
 	cliRun.Make().Add()
 	cliUpdate.Make().Add()
+	cliSchema.Make().Add()
 
 	if projectID == "" {
 		projectID = os.Getenv("SLING_PROJECT_ID")
@@ -417,10 +807,12 @@ func main() {
 	signal.Notify(interrupt, os.Interrupt)
 	signal.Notify(kill, syscall.SIGTERM)
 
-	sling.ShowProgress = os.Getenv("SLING_SHOW_PROGRESS") != "false"
+	sling.ShowProgress = os.Getenv("SLING_SHOW_PROGRESS") != "false" && !env.IsQuiet() && os.Getenv("SLING_LOGGING") != "JSON"
 	database.UseBulkExportFlowCSV = cast.ToBool(os.Getenv("SLING_BULK_EXPORT_FLOW_CSV"))
 
 	exit := func() {
+		iop.CleanupSpillFiles()
+		env.CleanupTempFolder()
 		time.Sleep(50 * time.Millisecond) // so logger can flush
 		os.Exit(exitCode)
 	}
@@ -436,6 +828,17 @@ func main() {
 			if cliRun.Sc.Used {
 				env.Println("\ninterrupting...")
 				interrupted = true
+				if task := runningTask.Load(); task != nil && task.Interrupt() {
+					// let the load finalize, unless interrupted again
+					env.Println("committing rows extracted so far. Interrupt again to abort.")
+					select {
+					case <-done:
+						exit()
+						return
+					case <-interrupt:
+						env.Println("\naborting...")
+					}
+				}
 				ctx.Cancel()
 				select {
 				case <-done:
@@ -449,7 +852,23 @@ func main() {
 		}
 	}()
 
+	// print the progress on SIGUSR1, without interrupting the run
+	progress := make(chan os.Signal, 1)
+	if notifyProgressSignal(progress) {
+		go func() {
+			for range progress {
+				if task := runningTask.Load(); task != nil {
+					fmt.Fprintln(os.Stderr, task.Snapshot().String())
+				} else {
+					fmt.Fprintln(os.Stderr, "progress snapshot | no stream running")
+				}
+			}
+		}()
+	}
+
 	exitCode = cliInit(done)
+	iop.CleanupSpillFiles()
+	env.CleanupTempFolder()
 	if !interrupted {
 		g.SentryFlush(time.Second * 2)
 	}
@@ -481,9 +900,24 @@ func cliInit(done chan struct{}) int {
 		flaggy.AttachSubcommand(cli.Sc, 1)
 	}
 
+	flaggy.DefaultParser.AdditionalHelpAppend = "\nGlobal Flags:\n      --env-prefix   Read connection env vars with this prefix first (e.g. `MYAPP_` for `MYAPP_AWS_BUCKET`), falling back to unprefixed vars. Also SLING_ENV_PREFIX."
+
 	flaggy.ShowHelpOnUnexpectedDisable()
+	os.Args = setEnvPrefix(os.Args)
+	os.Args = setOptionalFlagValues(os.Args)
+	args, err := setConfigVars(os.Args)
+	if err != nil {
+		g.PrintFatal(err)
+		return 1
+	}
+	os.Args = args
 	flaggy.Parse()
 
+	if err := env.InitTempFolder(); err != nil {
+		g.PrintFatal(err)
+		return 1
+	}
+
 	setSentry()
 	ok, err := g.CliProcess()
 
@@ -496,6 +930,13 @@ func cliInit(done chan struct{}) int {
 			err = g.Error(cast.ToString(env.TelMap["error"]))
 		}
 
+		// prefer the code classified from the typed error by the run
+		errCode := sling.ErrorCode(cast.ToString(env.TelMap["error_code"]))
+		if errCode == "" {
+			errCode = sling.ClassifyError(err)
+			env.SetTelVal("error_code", string(errCode))
+		}
+
 		if g.In(g.CliObj.Name, "conns", "update") || env.TelMap["error"] == nil {
 			env.SetTelVal("error", getErrString(err))
 
@@ -507,6 +948,11 @@ func cliInit(done chan struct{}) int {
 		}
 
 		g.PrintFatal(err)
+		if os.Getenv("SLING_LOGGING") == "JSON" {
+			g.ZLogOut.Log().Str("error_code", string(errCode)).Send()
+		} else {
+			env.Println(g.F("error code: %s", errCode))
+		}
 		return 1
 	} else if !ok {
 		flaggy.ShowHelp("")
@@ -522,6 +968,86 @@ func cliInit(done chan struct{}) int {
 	return 0
 }
 
+func processSchema(c *g.CliSC) (ok bool, err error) {
+	switch c.UsedSC() {
+	case "replication":
+		fmt.Println(g.Pretty(sling.ReplicationJSONSchema()))
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// optionalValueFlags are the string flags which can be passed without a value,
+// with their default value (e.g. `--fail-on-warning`)
+var optionalValueFlags = map[string]string{
+	"--fail-on-warning": "all",
+	"--no-temp-cleanup": "failure",
+}
+
+// setOptionalFlagValues sets the default value of the optional-value flags
+// passed without one, since flaggy expects a value
+func setOptionalFlagValues(args []string) []string {
+	newArgs := make([]string, 0, len(args))
+	for i, arg := range args {
+		if val, ok := optionalValueFlags[arg]; ok && (i+1 == len(args) || strings.HasPrefix(args[i+1], "-")) {
+			arg = arg + "=" + val
+		}
+		newArgs = append(newArgs, arg)
+	}
+	return newArgs
+}
+
+// setEnvPrefix sets SLING_ENV_PREFIX from the global `--env-prefix` flag,
+// accepted with any command, and removes it from the args
+func setEnvPrefix(args []string) []string {
+	newArgs := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--env-prefix" && i+1 < len(args):
+			os.Setenv("SLING_ENV_PREFIX", args[i+1])
+			i++
+		case strings.HasPrefix(arg, "--env-prefix="):
+			os.Setenv("SLING_ENV_PREFIX", strings.TrimPrefix(arg, "--env-prefix="))
+		default:
+			newArgs = append(newArgs, arg)
+		}
+	}
+	return newArgs
+}
+
+// setConfigVars sets SLING_CONFIG_VARS from the repeatable `--config-var key=value`
+// flag, and removes it from the args, since flaggy keeps the last value only
+func setConfigVars(args []string) ([]string, error) {
+	newArgs := make([]string, 0, len(args))
+	values := []string{}
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--config-var":
+			if i+1 == len(args) {
+				return args, g.Error("missing value for --config-var, should be key=value")
+			}
+			values = append(values, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "--config-var="):
+			values = append(values, strings.TrimPrefix(arg, "--config-var="))
+		default:
+			newArgs = append(newArgs, arg)
+		}
+	}
+
+	if len(values) == 0 {
+		return newArgs, nil
+	}
+
+	vars, err := sling.ParseConfigVars(values)
+	if err != nil {
+		return args, err
+	}
+	os.Setenv("SLING_CONFIG_VARS", g.Marshal(vars))
+	return newArgs, nil
+}
+
 func getErrString(err error) (errString string) {
 	if err != nil {
 		errString = err.Error()
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"strings"
@@ -15,6 +16,7 @@ import (
 	"github.com/slingdata-io/sling-cli/core/env"
 	"github.com/slingdata-io/sling-cli/core/sling"
 	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
 )
 
 var (
@@ -22,6 +24,45 @@ var (
 	connsCheck    = func(*g.CliSC) error { return g.Error("please use the official build of Sling CLI to use this command") }
 )
 
+// execDisplayLimit is the default number of rows displayed by `conns exec`
+const execDisplayLimit = 100
+
+// execCellWidth is the maximum length of a cell displayed by `conns exec`,
+// unless --wide
+const execCellWidth = 80
+
+// limitExecRows caps the rows at the limit (0 for no limit), and truncates the
+// long text cells unless wide. Returns whether rows were left out.
+func limitExecRows(rows [][]any, limit int, wide bool) (out [][]any, truncated bool) {
+	if limit > 0 && len(rows) > limit {
+		rows, truncated = rows[:limit], true
+	}
+	if wide {
+		return rows, truncated
+	}
+
+	out = make([][]any, len(rows))
+	for i, row := range rows {
+		out[i] = make([]any, len(row))
+		for j, val := range row {
+			if s, ok := val.(string); ok {
+				if runes := []rune(s); len(runes) > execCellWidth {
+					val = string(runes[:execCellWidth-3]) + "..."
+				}
+			}
+			out[i][j] = val
+		}
+	}
+	return out, truncated
+}
+
+// confirm asks the user a yes/no question on the terminal
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N]: ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return g.In(strings.ToLower(strings.TrimSpace(answer)), "y", "yes")
+}
+
 func processConns(c *g.CliSC) (ok bool, err error) {
 	ok = true
 
@@ -69,6 +110,110 @@ func processConns(c *g.CliSC) (ok bool, err error) {
 			return ok, g.Error(err, "could not set %s (See https://docs.slingdata.io/sling-cli/environment)", name)
 		}
 		g.Info("connection `%s` has been set in %s. Please test with `sling conns test %s`", name, ec.EnvFile.Path, name)
+	case "cache":
+		switch action := cast.ToString(c.Vals["action"]); action {
+		case "clear":
+			if err = sling.ClearColumnCache(); err != nil {
+				return ok, g.Error(err, "could not clear cache")
+			}
+			g.Info("cleared the column cache at %s", sling.ColumnCacheFolder())
+		case "":
+			flaggy.ShowHelp("")
+		default:
+			return ok, g.Error("invalid cache action: %s. Valid actions are: clear", action)
+		}
+
+	case "share":
+		name := strings.ToUpper(cast.ToString(c.Vals["name"]))
+		if name == "" {
+			flaggy.ShowHelp("")
+			return ok, nil
+		}
+
+		conn := entries.Get(name)
+		if conn.Name == "" {
+			return ok, g.Error("did not find connection %s", name)
+		}
+
+		// prefer the raw env file properties, to preserve env-var references
+		props := conn.Connection.Data
+		for key, rawProps := range ef.Connections {
+			if strings.EqualFold(key, name) {
+				props = rawProps
+			}
+		}
+
+		if cast.ToBool(c.Vals["reveal"]) {
+			if !confirm(g.F("this will print the secret values of connection `%s`. Continue?", name)) {
+				return ok, g.Error("aborted by user")
+			}
+		} else {
+			props = connection.RedactProps(props)
+		}
+
+		out, err := yaml.Marshal(map[string]any{name: props})
+		if err != nil {
+			return ok, g.Error(err, "could not marshal connection %s", name)
+		}
+		fmt.Print(string(out))
+
+	case "export":
+		conns := ec.Export(cast.ToBool(c.Vals["with-secrets"]))
+		if cast.ToBool(c.Vals["with-secrets"]) && len(conns) > 0 && cast.ToString(c.Vals["output"]) == "" {
+			if !confirm("this will print the secret values of all connections. Continue?") {
+				return ok, g.Error("aborted by user")
+			}
+		}
+
+		if output := cast.ToString(c.Vals["output"]); output != "" {
+			exportFile := env.EnvFile{Path: output, Connections: conns}
+			if err = exportFile.WriteEnvFile(); err != nil {
+				return ok, g.Error(err, "could not write %s", output)
+			}
+			g.Info("exported %d connections to %s", len(conns), output)
+		} else {
+			out, err := yaml.Marshal(map[string]any{"connections": conns})
+			if err != nil {
+				return ok, g.Error(err, "could not marshal connections")
+			}
+			fmt.Print(string(out))
+		}
+
+	case "import":
+		file := cast.ToString(c.Vals["file"])
+		if file == "" {
+			flaggy.ShowHelp("")
+			return ok, nil
+		}
+
+		strategy := connection.ImportMerge
+		if cast.ToBool(c.Vals["replace"]) {
+			if cast.ToBool(c.Vals["merge"]) {
+				return ok, g.Error("cannot use both --merge and --replace")
+			}
+			strategy = connection.ImportReplace
+		}
+
+		conns, err := connection.ReadExportFile(file)
+		if err != nil {
+			return ok, g.Error(err, "could not read export file")
+		}
+
+		result, err := ec.Import(conns, strategy)
+		if err != nil {
+			return ok, g.Error(err, "could not import connections")
+		}
+
+		if asJSON {
+			fmt.Println(g.Marshal(result))
+			return ok, nil
+		}
+
+		g.Info("imported connections into %s (added: %d, updated: %d, unchanged: %d)", ec.EnvFile.Path, len(result.Added), len(result.Updated), len(result.Unchanged))
+		if len(result.Conflicts) > 0 {
+			g.Warn("kept existing connections with different properties: %s. Use --replace to overwrite", strings.Join(result.Conflicts, ", "))
+		}
+
 	case "exec":
 		env.SetTelVal("task", g.Marshal(g.M("type", sling.ConnExec)))
 
@@ -97,6 +242,18 @@ func processConns(c *g.CliSC) (ok bool, err error) {
 
 		queries := append([]string{cast.ToString(c.Vals["queries..."])}, flaggy.TrailingArguments...)
 
+		limit := cast.ToInt(c.Vals["limit"])
+		if limit < 0 {
+			return ok, g.Error("invalid --limit value: %d", limit)
+		} else if limit == 0 {
+			limit = execDisplayLimit
+		}
+		if cast.ToBool(c.Vals["no-limit"]) {
+			limit = 0
+		}
+		wide := cast.ToBool(c.Vals["wide"]) || cast.ToBool(c.Vals["no-truncate-cells"])
+		translate := cast.ToBool(c.Vals["translate"])
+
 		var totalAffected int64
 		for i, query := range queries {
 
@@ -105,6 +262,16 @@ func processConns(c *g.CliSC) (ok bool, err error) {
 				return ok, g.Error(err, "cannot get query")
 			}
 
+			if translate {
+				translated, tErr := database.TranslateSQL(query, conn.Connection.Type)
+				if tErr != nil {
+					g.Warn("could not translate query #%d, executing as is: %s", i+1, tErr.Error())
+				} else if translated != query {
+					g.Debug("translated query #%d for %s:\n%s", i+1, conn.Connection.Type, translated)
+					query = translated
+				}
+			}
+
 			sQuery, err := database.ParseTableName(query, conn.Connection.Type)
 			if err != nil {
 				return ok, g.Error(err, "cannot parse query")
@@ -112,18 +279,24 @@ func processConns(c *g.CliSC) (ok bool, err error) {
 
 			if len(database.ParseSQLMultiStatements(query)) == 1 && (!sQuery.IsQuery() || (strings.Contains(strings.ToLower(query), "select") && !strings.Contains(strings.ToLower(query), "insert")) || g.In(conn.Connection.Type, dbio.TypeDbPrometheus, dbio.TypeDbMongoDB)) {
 
-				data, err := dbConn.Query(sQuery.Select(100, 0))
+				// fetch one more row than the limit, to know if truncated
+				data, err := dbConn.Query(sQuery.Select(lo.Ternary(limit > 0, limit+1, 0), 0))
 				if err != nil {
 					return ok, g.Error(err, "cannot execute query")
 				}
 
+				rows, truncated := limitExecRows(data.Rows, limit, wide || asJSON)
 				if asJSON {
-					fmt.Println(g.Marshal(g.M("fields", data.GetFields(), "rows", data.Rows)))
+					fmt.Println(g.Marshal(g.M("fields", data.GetFields(), "rows", rows, "truncated", truncated)))
 				} else {
-					fmt.Println(g.PrettyTable(data.GetFields(), data.Rows))
+					fmt.Println(g.PrettyTable(data.GetFields(), rows))
 				}
 
-				totalAffected = cast.ToInt64(len(data.Rows))
+				if truncated {
+					g.Info("results truncated to the first %d rows (use --limit or --no-limit to show more)", limit)
+				}
+
+				totalAffected = cast.ToInt64(len(rows))
 			} else {
 				if len(queries) > 1 {
 					if strings.HasPrefix(query, "file://") {
@@ -172,22 +345,51 @@ func processConns(c *g.CliSC) (ok bool, err error) {
 			env.SetTelVal("conn_keys", lo.Keys(conn.Connection.Data))
 		}
 
-		ok, err = entries.Test(name)
-		if err != nil {
+		var result connection.TestResult
+		result, err = entries.Test(name)
+		if ok = err == nil; !ok {
 			err = g.Error(err, "could not test %s", name)
 		}
 
 		if asJSON {
-			fmt.Println(g.Marshal(g.M("success", err == nil, "error", g.ErrMsg(err))))
+			fmt.Println(g.Marshal(g.M(
+				"success", err == nil, "error", g.ErrMsg(err), "error_code", sling.ClassifyError(err),
+				"latency_ms", result.Latency.Milliseconds(), "version", result.Version,
+				"user", result.User, "role", result.Role,
+			)))
 			return
 		}
 
 		if err != nil {
 			return ok, err
-		} else if ok {
-			g.Info("success!") // successfully connected
 		}
+
+		g.Info("success! connected in %s", result.Latency.Round(time.Millisecond)) // successfully connected
+		for _, kv := range [][]string{{"version", result.Version}, {"user", result.User}, {"role", result.Role}} {
+			if kv[1] != "" {
+				g.Info("  %-8s %s", kv[0]+":", kv[1])
+			}
+		}
+	case "validate":
+		connURL := cast.ToString(c.Vals["url"])
+		if connURL == "" {
+			flaggy.ShowHelp("")
+			return ok, nil
+		}
+
+		err = connection.ValidateURL(connURL)
+		if asJSON {
+			fmt.Println(g.Marshal(g.M("valid", err == nil, "error", g.ErrMsg(err), "type", connection.SchemeType(connURL))))
+			return ok, nil
+		} else if err != nil {
+			return ok, err
+		}
+		g.Info("valid %s connection url", connection.SchemeType(connURL).NameLong())
+
 	case "discover":
+		if cast.ToString(c.Vals["pattern"]) != "" && cast.ToString(c.Vals["regex"]) != "" {
+			return ok, g.Error("cannot use both --pattern and --regex, please provide one")
+		}
 		return ok, connsDiscover(c)
 
 	case "check":
@@ -11,7 +11,10 @@ import (
 
 	"gopkg.in/yaml.v2"
 
+	"github.com/dustin/go-humanize"
+	"github.com/samber/lo"
 	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
 	"github.com/slingdata-io/sling-cli/core/env"
 	"github.com/slingdata-io/sling-cli/core/sling"
 
@@ -31,6 +34,7 @@ var (
 
 func processRun(c *g.CliSC) (ok bool, err error) {
 	ok = true
+	startTime := time.Now()
 	cfg := &sling.Config{
 		Source: sling.Source{Options: &sling.SourceOptions{}},
 		Target: sling.Target{Options: &sling.TargetOptions{}},
@@ -38,12 +42,30 @@ func processRun(c *g.CliSC) (ok bool, err error) {
 	replicationCfgPath := ""
 	taskCfgStr := ""
 	showExamples := false
+	printConfig := false
 	selectStreams := []string{}
+	runHooks := runHooks{}
+	hooksArmed, startRowCount := false, rowCount
+	var panicErr error
+
+	// execute the --on-success / --on-failure command with the outcome.
+	// deferred before the panic recovery, so that a panic is a failure
+	// for the hook (the recovered panic is still not returned)
+	defer func() {
+		if !hooksArmed {
+			return
+		} else if panicErr != nil {
+			runHooks.execute(panicErr, rowCount-startRowCount, time.Since(startTime))
+			return
+		}
+		err = runHooks.execute(err, rowCount-startRowCount, time.Since(startTime))
+	}()
 
 	// recover from panic
 	defer func() {
 		if r := recover(); r != nil {
 			env.SetTelVal("error", g.F("panic occurred! %#v\n%s", r, string(debug.Stack())))
+			panicErr = g.Error("panic occurred: %v", r)
 		}
 	}()
 
@@ -101,9 +123,21 @@ func processRun(c *g.CliSC) (ok bool, err error) {
 		case "offset":
 			cfg.Source.Options.Offset = g.Int(cast.ToInt(v))
 
+		case "timeout":
+			os.Setenv("SLING_TIMEOUT", cast.ToString(v))
+
 		case "range":
 			cfg.Source.Options.Range = g.String(cast.ToString(v))
 
+		case "where":
+			cfg.Source.Options.Where = g.String(cast.ToString(v))
+
+		case "since":
+			cfg.Source.Options.Since = g.String(cast.ToString(v))
+
+		case "until":
+			cfg.Source.Options.Until = g.String(cast.ToString(v))
+
 		case "tgt-object", "tgt-table", "tgt-file":
 			cfg.Target.Object = cast.ToString(v)
 			if strings.Contains(cfg.Target.Object, "://") {
@@ -135,6 +169,13 @@ func processRun(c *g.CliSC) (ok bool, err error) {
 			}
 		case "stdout":
 			cfg.Options.StdOut = cast.ToBool(v)
+		case "stdout-flush-interval":
+			os.Setenv("SLING_STDOUT_FLUSH_INTERVAL", cast.ToString(v))
+		case "stdout-flush-rows":
+			if rows, err := cast.ToIntE(v); err != nil || rows < 0 {
+				return ok, g.Error("invalid value for --stdout-flush-rows: %s", cast.ToString(v))
+			}
+			os.Setenv("SLING_STDOUT_FLUSH_ROWS", cast.ToString(v))
 		case "mode":
 			cfg.Mode = sling.Mode(cast.ToString(v))
 		case "columns":
@@ -153,6 +194,107 @@ func processRun(c *g.CliSC) (ok bool, err error) {
 			cfg.Source.Select = strings.Split(cast.ToString(v), ",")
 		case "streams":
 			selectStreams = strings.Split(cast.ToString(v), ",")
+		case "resume-failed":
+			if cast.ToBool(v) {
+				os.Setenv("SLING_RESUME_FAILED", "true")
+			}
+		case "force-all":
+			if cast.ToBool(v) {
+				os.Setenv("SLING_FORCE_ALL", "true")
+			}
+		case "force-disabled":
+			if cast.ToBool(v) {
+				os.Setenv("SLING_FORCE_DISABLED", "true")
+			}
+		case "commit-on-interrupt":
+			if cast.ToBool(v) {
+				os.Setenv("SLING_COMMIT_ON_INTERRUPT", "true")
+			}
+		case "no-temp-cleanup":
+			os.Setenv("SLING_NO_TEMP_CLEANUP", cast.ToString(v))
+		case "no-cache":
+			if cast.ToBool(v) {
+				os.Setenv("SLING_NO_CACHE", "true")
+			}
+		case "explain":
+			if cast.ToBool(v) {
+				os.Setenv("SLING_EXPLAIN", "true")
+			}
+		case "dry-run":
+			if cast.ToBool(v) {
+				os.Setenv("SLING_DRY_RUN", "true")
+			}
+		case "tmp-dir":
+			os.Setenv("SLING_TMP_DIR", cast.ToString(v))
+			if err = env.InitTempFolder(); err != nil {
+				return ok, g.Error(err, "invalid --tmp-dir")
+			}
+		case "otel-endpoint":
+			os.Setenv("SLING_OTEL_ENDPOINT", cast.ToString(v))
+		case "state-conn":
+			os.Setenv("SLING_STATE_CONN", cast.ToString(v))
+		case "state-table":
+			os.Setenv("SLING_STATE_TABLE", cast.ToString(v))
+		case "schema-snapshot":
+			os.Setenv("SLING_SCHEMA_SNAPSHOT", cast.ToString(v))
+		case "on-schema-drift":
+			os.Setenv("SLING_ON_SCHEMA_DRIFT", cast.ToString(v))
+		case "abort-on-empty-source":
+			if cast.ToBool(v) {
+				os.Setenv("SLING_ABORT_ON_EMPTY_SOURCE", "true")
+			}
+		case "append-only":
+			if cast.ToBool(v) {
+				os.Setenv("SLING_APPEND_ONLY", "true")
+			}
+		case "no-create":
+			if cast.ToBool(v) {
+				os.Setenv("SLING_NO_CREATE", "true")
+			}
+		case "columns-lowercase-keys":
+			if cast.ToBool(v) {
+				os.Setenv("SLING_COLUMNS_LOWERCASE_KEYS", "true")
+			}
+		case "force-grants":
+			if cast.ToBool(v) {
+				os.Setenv("SLING_FORCE_GRANTS", "true")
+			}
+		case "rename-target-columns":
+			if cast.ToBool(v) {
+				os.Setenv("SLING_RENAME_TARGET_COLUMNS", "true")
+			}
+		case "max-memory":
+			if _, err := iop.ParseMaxMemory(cast.ToString(v)); err != nil {
+				return ok, g.Error(err, "invalid value for --max-memory")
+			}
+			os.Setenv("SLING_MAX_MEMORY", cast.ToString(v))
+		case "fail-on-warning":
+			os.Setenv("SLING_FAIL_ON_WARNING", cast.ToString(v))
+		case "on-success":
+			runHooks.onSuccess = cast.ToString(v)
+		case "on-failure":
+			runHooks.onFailure = cast.ToString(v)
+		case "strict-hooks":
+			runHooks.strict = cast.ToBool(v)
+		case "transform-plugin":
+			os.Setenv("SLING_TRANSFORM_PLUGIN", cast.ToString(v))
+		case "validate-only":
+			if cast.ToBool(v) {
+				os.Setenv("SLING_VALIDATE_ONLY", "true")
+			}
+		case "progress-format":
+			if _, err = sling.ParseProgressFormat(cast.ToString(v)); err != nil {
+				return ok, g.Error(err, "invalid progress format")
+			}
+			os.Setenv("SLING_PROGRESS_FORMAT", cast.ToString(v))
+		case "quiet":
+			if cast.ToBool(v) {
+				if !env.IsQuiet() {
+					os.Setenv("SLING_QUIET", "true")
+				}
+				sling.ShowProgress = false
+				env.SetLogger()
+			}
 		case "debug":
 			cfg.Options.Debug = cast.ToBool(v)
 			if cfg.Options.Debug && os.Getenv("DEBUG") == "" {
@@ -161,6 +303,8 @@ func processRun(c *g.CliSC) (ok bool, err error) {
 			}
 		case "examples":
 			showExamples = cast.ToBool(v)
+		case "print-config":
+			printConfig = cast.ToBool(v)
 		}
 	}
 
@@ -180,6 +324,14 @@ func processRun(c *g.CliSC) (ok bool, err error) {
 		}
 	}
 
+	if err = env.CollectWarnings(os.Getenv("SLING_FAIL_ON_WARNING")); err != nil {
+		return ok, g.Error(err, "invalid value for --fail-on-warning")
+	}
+
+	if cast.ToBool(os.Getenv("SLING_VALIDATE_ONLY")) && os.Getenv("SLING_SCHEMA_SNAPSHOT") == "" {
+		return ok, g.Error("need to provide a schema snapshot with --validate-only (flag `--schema-snapshot`)")
+	}
+
 	os.Setenv("SLING_CLI", "TRUE")
 	os.Setenv("SLING_CLI_ARGS", g.Marshal(os.Args[1:]))
 	if os.Getenv("SLING_EXEC_ID") == "" {
@@ -187,10 +339,29 @@ func processRun(c *g.CliSC) (ok bool, err error) {
 		os.Setenv("SLING_EXEC_ID", sling.NewExecID())
 	}
 
+	if printConfig {
+		return ok, printResolvedConfig(replicationCfgPath, cfg, selectStreams...)
+	}
+
 	// check for update, and print note
 	go checkUpdate(false)
 	defer printUpdateAvailable()
 
+	// the run starts, the outcome is passed to the --on-success / --on-failure command
+	hooksArmed = true
+
+	// export spans to OpenTelemetry
+	if endpoint := os.Getenv("SLING_OTEL_ENDPOINT"); endpoint != "" {
+		shutdown, tErr := sling.StartTracing(endpoint)
+		if tErr != nil {
+			return ok, g.Error(tErr, "could not start tracing")
+		}
+		defer shutdown()
+
+		endRunSpan := sling.StartRunSpan("sling run")
+		defer func() { endRunSpan(err) }()
+	}
+
 runReplication:
 	if replicationCfgPath != "" {
 		//  run replication
@@ -219,12 +390,64 @@ runReplication:
 		}
 	}
 
+	// in quiet mode, optionally print the final summary (a log line with JSON logging)
+	if env.QuietSummary() {
+		summary := g.F("sling run completed in %s | %d rows | %s", g.DurationString(time.Since(startTime)), rowCount, humanize.Bytes(totalBytes))
+		if os.Getenv("SLING_LOGGING") == "JSON" {
+			g.ZLogOut.Log().Msg(summary)
+		} else {
+			env.Println(summary)
+		}
+	}
+
 	// test count/bytes if need
 	err = testOutput(rowCount, totalBytes, constraintFails)
 
+	// fail if warnings were logged (--fail-on-warning)
+	if err == nil && env.Warnings != nil {
+		err = env.Warnings.Err()
+	}
+
 	return ok, err
 }
 
+// runHooks are the shell commands executed after a run, depending on its outcome
+// (flags `--on-success` & `--on-failure`)
+type runHooks struct {
+	onSuccess string
+	onFailure string
+	strict    bool // the failure of the command fails the run (flag `--strict-hooks`)
+}
+
+// execute runs the command of the outcome of the run, returning the run error.
+// The failure of the command is logged, or returned if strict and the run succeeded.
+func (rh runHooks) execute(runErr error, rows int64, duration time.Duration) error {
+	vars := map[string]string{
+		"SLING_STATUS":   "success",
+		"SLING_ROWS":     cast.ToString(rows),
+		"SLING_DURATION": g.F("%.3f", duration.Seconds()),
+	}
+
+	id, command := "on-success", rh.onSuccess
+	if runErr != nil {
+		id, command = "on-failure", rh.onFailure
+		vars["SLING_STATUS"] = "error"
+		vars["SLING_ERROR"] = runErr.Error()
+	}
+
+	if command == "" {
+		return runErr
+	}
+
+	if hookErr := sling.ExecuteHookCommand(id, command, vars); hookErr != nil {
+		if rh.strict && runErr == nil {
+			return hookErr
+		}
+		g.Warn(hookErr.Error())
+	}
+	return runErr
+}
+
 func runTask(cfg *sling.Config, replication *sling.ReplicationConfig) (err error) {
 	var task *sling.TaskExecution
 
@@ -248,6 +471,7 @@ func runTask(cfg *sling.Config, replication *sling.ReplicationConfig) (err error
 			taskOptions["tgt_file_max_bytes"] = task.Config.Target.Options.FileMaxBytes
 			taskOptions["tgt_format"] = task.Config.Target.Options.Format
 			taskOptions["tgt_use_bulk"] = task.Config.Target.Options.UseBulk
+			taskOptions["tgt_direct_insert"] = task.Config.Target.Options.DirectInsert
 			taskOptions["tgt_add_new_columns"] = task.Config.Target.Options.AddNewColumns
 			taskOptions["tgt_adjust_column_type"] = task.Config.Target.Options.AdjustColumnType
 			taskOptions["tgt_column_casing"] = task.Config.Target.Options.ColumnCasing
@@ -297,6 +521,9 @@ func runTask(cfg *sling.Config, replication *sling.ReplicationConfig) (err error
 			taskStats["rows_count"] = task.GetCount()
 			taskStats["rows_in_bytes"] = inBytes
 			taskStats["rows_out_bytes"] = outBytes
+			if results := task.FanOutResults(); len(results) > 0 {
+				taskStats["fan_out"] = results
+			}
 
 			if memRAM, _ := mem.VirtualMemory(); memRAM != nil {
 				taskStats["mem_used"] = memRAM.Used
@@ -315,6 +542,7 @@ func runTask(cfg *sling.Config, replication *sling.ReplicationConfig) (err error
 
 		if err != nil {
 			env.SetTelVal("error", getErrString(err))
+			env.SetTelVal("error_code", string(sling.ClassifyError(err)))
 		}
 
 		env.SetTelVal("task_stats", g.Marshal(taskStats))
@@ -353,8 +581,12 @@ func runTask(cfg *sling.Config, replication *sling.ReplicationConfig) (err error
 	task = sling.NewTask(os.Getenv("SLING_EXEC_ID"), cfg)
 	task.Replication = replication
 
+	// with --explain, the task runs until the extraction query is planned
 	if cast.ToBool(cfg.Env["SLING_DRY_RUN"]) || cast.ToBool(os.Getenv("SLING_DRY_RUN")) {
-		return nil
+		if !cast.ToBool(os.Getenv("SLING_EXPLAIN")) {
+			return nil
+		}
+		os.Setenv("SLING_DRY_RUN", "true")
 	}
 
 	// set log sink
@@ -375,7 +607,9 @@ func runTask(cfg *sling.Config, replication *sling.ReplicationConfig) (err error
 
 	// run task
 	setTM()
+	runningTask.Store(task)
 	err = task.Execute()
+	runningTask.Store(nil)
 
 	if err != nil {
 
@@ -413,19 +647,57 @@ func runTask(cfg *sling.Config, replication *sling.ReplicationConfig) (err error
 	return nil
 }
 
-func runReplication(cfgPath string, cfgOverwrite *sling.Config, selectStreams ...string) (err error) {
-	startTime := time.Now()
+// printResolvedConfig prints the effective task or replication config
+// as YAML, without running it (flag `--print-config`)
+func printResolvedConfig(replicationCfgPath string, cfg *sling.Config, selectStreams ...string) (err error) {
+	var out string
+	switch {
+	case replicationCfgPath != "" || cfg.HasWildcard():
+		var replication sling.ReplicationConfig
+		if replicationCfgPath != "" {
+			replication, err = loadReplication(replicationCfgPath)
+		} else {
+			replication, err = sling.LoadReplicationConfig(g.Marshal(cfg.AsReplication()))
+		}
+		if err != nil {
+			return g.Error(err, "Error parsing replication config")
+		}
+
+		if err = replication.Compile(cfg, selectStreams...); err != nil {
+			return g.Error(err, "Error compiling replication config")
+		}
+		out, err = replication.ResolvedYAML()
+	default:
+		out, err = cfg.ResolvedYAML()
+	}
+	if err != nil {
+		return g.Error(err, "could not resolve config")
+	}
+
+	fmt.Print(out)
+	return nil
+}
 
-	replication, err := sling.LoadReplicationConfigFromFile(cfgPath)
+// loadReplication loads a replication from a file path, a JSON / YAML
+// payload, or the name of a replication in the project
+func loadReplication(cfgPath string) (replication sling.ReplicationConfig, err error) {
+	replication, err = sling.LoadReplicationConfigFromFile(cfgPath)
 	if err != nil {
 		if sling.IsJSONorYAML(cfgPath) {
 			replication, err = sling.LoadReplicationConfig(cfgPath) // is JSON
 		} else if r, e := lookupReplication(cfgPath); r.OriginalCfg() != "" {
 			replication, err = r, e
 		}
-		if err != nil {
-			return g.Error(err, "Error parsing replication config")
-		}
+	}
+	return
+}
+
+func runReplication(cfgPath string, cfgOverwrite *sling.Config, selectStreams ...string) (err error) {
+	startTime := time.Now()
+
+	replication, err := loadReplication(cfgPath)
+	if err != nil {
+		return g.Error(err, "Error parsing replication config")
 	}
 
 	err = replication.Compile(cfgOverwrite, selectStreams...)
@@ -437,19 +709,62 @@ func runReplication(cfgPath string, cfgOverwrite *sling.Config, selectStreams ..
 		g.Warn("Did not match any streams. Exiting.")
 		return
 	}
+	defer replication.Join.Cleanup() // ephemeral join database
+
+	// shell hooks, with the replication status
+	startRowCount := rowCount
+	streamNames := []string{}
+	for _, cfg := range replication.Tasks {
+		if !cfg.ReplicationStream.Disabled {
+			streamNames = append(streamNames, cfg.StreamName)
+		}
+	}
+	hookVars := func(status string, streams []string) map[string]string {
+		return map[string]string{
+			"SLING_STATUS": status,
+			"SLING_ROWS":   cast.ToString(rowCount - startRowCount),
+			"SLING_STREAM": strings.Join(streams, ","),
+		}
+	}
+
+	if err = replication.Hooks.Execute("start", hookVars("running", streamNames)); err != nil {
+		return g.Error(err, "start hook failed, aborting replication")
+	}
+
+	// the outcome of the streams, to resume the failed ones (--resume-failed)
+	// not for the temporary replication of a wildcard task
+	var runState *sling.RunState
+	if !strings.HasPrefix(filepath.Base(cfgPath), "replication.temp") {
+		runState = sling.NewRunState(cfgPath)
+	}
+	resume := runState != nil && cast.ToBool(os.Getenv("SLING_RESUME_FAILED")) && !cast.ToBool(os.Getenv("SLING_FORCE_ALL"))
+	if resume {
+		if runState, err = sling.LoadRunState(cfgPath); err != nil {
+			g.Warn("could not load the last run state, running all streams: %s", err.Error())
+		} else if len(runState.Streams) == 0 {
+			g.Warn("no last run state found for %s, running all streams", cfgPath)
+		}
+	}
 
 	eG := g.ErrorGroup{}
 	successes := 0
+	failedStreams := []string{}
 
 	// get final stream count
 	streamCnt := 0
 	for _, cfg := range replication.Tasks {
 		if cfg.ReplicationStream.Disabled {
 			continue
+		} else if resume && runState.Succeeded(cfg.StreamName) {
+			continue
 		}
 		streamCnt++
 	}
 
+	if resume && streamCnt == 0 {
+		g.Info("all streams succeeded in the last run, nothing to resume (use --force-all to run them again)")
+	}
+
 	if streamCnt > 1 {
 		g.Info("Sling Replication [%d streams] | %s -> %s", streamCnt, replication.Source, replication.Target)
 	}
@@ -464,7 +779,10 @@ func runReplication(cfgPath string, cfgOverwrite *sling.Config, selectStreams ..
 
 		if cfg.ReplicationStream.Disabled {
 			println()
-			g.Debug("skipping stream %s since it is disabled", cfg.StreamName)
+			g.Info("skipping stream %s since it is disabled", cfg.StreamName)
+			continue
+		} else if resume && runState.Succeeded(cfg.StreamName) {
+			g.Info("skipping stream %s since it succeeded in the last run", cfg.StreamName)
 			continue
 		} else if streamCnt == 1 {
 			g.Info("Sling Replication | %s -> %s | %s", replication.Source, replication.Target, cfg.StreamName)
@@ -477,8 +795,12 @@ func runReplication(cfgPath string, cfgOverwrite *sling.Config, selectStreams ..
 		env.TelMap = g.M("begin_time", time.Now().UnixMicro(), "run_mode", "replication") // reset map
 		env.SetTelVal("replication_md5", replication.MD5())
 		err = runTask(cfg, &replication)
+		if stateErr := runState.Set(cfg.StreamName, err); stateErr != nil {
+			g.Warn("could not save the run state: %s", stateErr.Error())
+		}
 		if err != nil {
 			eG.Capture(err, cfg.StreamName)
+			failedStreams = append(failedStreams, cfg.StreamName)
 
 			// if a connection issue, stop
 			if e, ok := err.(*g.ErrType); ok && strings.Contains(e.Debug(), "Could not connect to ") {
@@ -502,6 +824,17 @@ func runReplication(cfgPath string, cfgOverwrite *sling.Config, selectStreams ..
 
 	g.Info("Sling Replication Completed in %s | %s -> %s | %s | %s\n", g.DurationString(delta), replication.Source, replication.Target, successStr, failureStr)
 
+	if len(failedStreams) > 0 {
+		if hookErr := replication.Hooks.Execute("error", hookVars("error", failedStreams)); hookErr != nil {
+			g.Warn(hookErr.Error())
+		}
+	}
+
+	status := lo.Ternary(len(failedStreams) > 0, "error", "success")
+	if hookErr := replication.Hooks.Execute("end", hookVars(status, streamNames)); hookErr != nil {
+		g.Warn(hookErr.Error())
+	}
+
 	return eG.Err()
 }
 
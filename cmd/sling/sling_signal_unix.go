@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyProgressSignal relays SIGUSR1 to the channel, to print the progress
+func notifyProgressSignal(c chan os.Signal) bool {
+	signal.Notify(c, syscall.SIGUSR1)
+	return true
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+)
+
+// notifyProgressSignal is a no-op, since SIGUSR1 does not exist on windows
+func notifyProgressSignal(c chan os.Signal) bool {
+	return false
+}
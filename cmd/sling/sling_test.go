@@ -1143,233 +1143,2796 @@ mode: full-refresh
 	}
 }
 
+func TestIfExists(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	dbPath := filepath.Join(env.GetTempFolder(), g.NewTsID("if_exists")+".duckdb")
+	defer os.Remove(dbPath)
+	dbURL := "duckdb://" + dbPath
+	table := "main.if_exists_test"
+
+	run := func(mode sling.Mode, ifExists string) error {
+		cfgStr := g.F(`
+source:
+  stream: file://tests/files/test1.csv
+target:
+  conn: %s
+  object: %s
+  options:
+    if_exists: %s
+mode: %s
+`, dbURL, table, ifExists, mode)
+
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	withConn := func(f func(conn d.Connection)) {
+		conn, err := d.NewConn(dbURL)
+		if !g.AssertNoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		if g.AssertNoError(t, conn.Connect()) {
+			f(conn)
+		}
+	}
+
+	check := func(expectedCount uint64, hasExtraCol bool) {
+		withConn(func(conn d.Connection) {
+			count, err := conn.GetCount(table)
+			g.AssertNoError(t, err)
+			assert.Equal(t, expectedCount, count)
+
+			columns, err := conn.GetColumns(table)
+			g.AssertNoError(t, err)
+			_, ok := columns.FieldMap(true)["extra_col"]
+			assert.Equal(t, hasExtraCol, ok)
+		})
+	}
+
+	// initial load
+	if !g.AssertNoError(t, run(sling.FullRefreshMode, "")) {
+		return
+	}
+
+	var count uint64
+	withConn(func(conn d.Connection) {
+		count, _ = conn.GetCount(table)
+		_, err := conn.Exec("alter table " + table + " add column extra_col integer")
+		g.AssertNoError(t, err)
+	})
+	if !assert.Greater(t, count, uint64(0)) {
+		return
+	}
+
+	// append keeps structure & data
+	g.AssertNoError(t, run(sling.FullRefreshMode, "append"))
+	check(count*2, true)
+
+	// truncate keeps structure, clears data
+	g.AssertNoError(t, run(sling.FullRefreshMode, "truncate"))
+	check(count, true)
+
+	// default for truncate mode is to truncate
+	g.AssertNoError(t, run(sling.TruncateMode, ""))
+	check(count, true)
+
+	// fail errors, leaves table untouched
+	err := run(sling.FullRefreshMode, "fail")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "if_exists=fail")
+	}
+	check(count, true)
+
+	// replace drops & recreates
+	g.AssertNoError(t, run(sling.SnapshotMode, "replace"))
+	check(count, false)
+
+	// invalid value
+	assert.Error(t, run(sling.FullRefreshMode, "merge"))
+}
+
+func TestDirectInsert(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	dbPath := filepath.Join(env.GetTempFolder(), g.NewTsID("direct_insert")+".duckdb")
+	defer os.Remove(dbPath)
+	dbURL := "duckdb://" + dbPath
+	table := "main.direct_insert_test"
+
+	run := func(mode sling.Mode, directInsert bool, srcExtra, tgtExtra string) (task *sling.TaskExecution, err error) {
+		cfgStr := g.F(`
+source:
+  stream: file://tests/files/test1.csv
+  %s
+target:
+  conn: %s
+  object: %s
+  options:
+    direct_insert: %t
+    %s
+mode: %s
+`, srcExtra, dbURL, table, directInsert, tgtExtra, mode)
+
+		config := &sling.Config{}
+		if err = config.Unmarshal(cfgStr); err != nil {
+			return
+		} else if err = config.Prepare(); err != nil {
+			return
+		}
+
+		task = sling.NewTask("", config)
+		if task.Err != nil {
+			return task, task.Err
+		}
+		return task, task.Execute()
+	}
+
+	// with staging, a temp table is used
+	task, err := run(sling.FullRefreshMode, false, "", "")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	assert.NotEmpty(t, task.Config.Target.Options.TableTmp)
+	count := task.GetCount()
+
+	// direct insert skips the temp table
+	task, err = run(sling.FullRefreshMode, true, "", "")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	assert.Empty(t, task.Config.Target.Options.TableTmp)
+	assert.Equal(t, count, task.GetCount())
+
+	// appends into the final table
+	task, err = run(sling.FullRefreshMode, true, "", "if_exists: append")
+	if g.AssertNoError(t, err) {
+		assert.Empty(t, task.Config.Target.Options.TableTmp)
+
+		conn, err := d.NewConn(dbURL)
+		if g.AssertNoError(t, err) && g.AssertNoError(t, conn.Connect()) {
+			tCount, err := conn.GetCount(table)
+			g.AssertNoError(t, err)
+			assert.Equal(t, count*2, tCount)
+			conn.Close()
+		}
+	}
+
+	// not compatible with merge
+	_, err = run(sling.IncrementalMode, true, "primary_key: [id]\n  update_key: create_dt", "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "direct_insert is not compatible")
+	}
+}
+
+func TestDeletedMarker(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("deleted_marker"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	dbPath := folder + ".duckdb"
+	defer os.Remove(dbPath)
+	dbURL := "duckdb://" + dbPath
+
+	batch1 := "id,name,deleted_at\n1,a,\n2,b,\n3,c,\n"
+	batch2 := "id,name,deleted_at\n2,b2,\n3,c,2024-01-01 00:00:00\n4,d,\n"
+	os.WriteFile(filepath.Join(folder, "batch1.csv"), []byte(batch1), 0644)
+	os.WriteFile(filepath.Join(folder, "batch2.csv"), []byte(batch2), 0644)
+
+	run := func(file, table, tgtExtra string) (err error) {
+		cfgStr := g.F(`
+source:
+  stream: file://%s
+  primary_key: [id]
+  options:
+    deleted_marker: deleted_at
+target:
+  conn: %s
+  object: %s
+  options:
+    %s
+mode: incremental
+`, filepath.Join(folder, file), dbURL, table, tgtExtra)
+
+		config := &sling.Config{}
+		if err = config.Unmarshal(cfgStr); err != nil {
+			return
+		} else if err = config.Prepare(); err != nil {
+			return
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	query := func(sql string) (data iop.Dataset) {
+		conn, err := d.NewConn(dbURL)
+		if g.AssertNoError(t, err) && g.AssertNoError(t, conn.Connect()) {
+			data, err = conn.Query(sql)
+			g.AssertNoError(t, err)
+			conn.Close()
+		}
+		return
+	}
+
+	// hard delete
+	for _, file := range []string{"batch1.csv", "batch2.csv"} {
+		if !g.AssertNoError(t, run(file, "main.deleted_hard", "")) {
+			return
+		}
+	}
+	data := query("select id, name from main.deleted_hard order by id")
+	if assert.Len(t, data.Rows, 3) {
+		ids := lo.Map(data.Rows, func(row []any, i int) int { return cast.ToInt(row[0]) })
+		assert.Equal(t, []int{1, 2, 4}, ids)
+		assert.EqualValues(t, "b2", data.Rows[1][1])
+	}
+
+	// soft delete
+	for _, file := range []string{"batch1.csv", "batch2.csv"} {
+		if !g.AssertNoError(t, run(file, "main.deleted_soft", "soft_delete: _sling_deleted_at")) {
+			return
+		}
+	}
+	data = query("select id, name, _sling_deleted_at from main.deleted_soft order by id")
+	if assert.Len(t, data.Rows, 4) {
+		for _, row := range data.Rows {
+			if cast.ToInt(row[0]) == 3 {
+				assert.NotNil(t, row[2])
+			} else {
+				assert.Nil(t, row[2])
+			}
+		}
+	}
+
+	// requires a primary key
+	config := &sling.Config{}
+	err := config.Unmarshal(g.F("source:\n  stream: file://%s\n  options:\n    deleted_marker: deleted_at\ntarget:\n  conn: %s\n  object: main.t1\nmode: full-refresh", filepath.Join(folder, "batch1.csv"), dbURL))
+	if g.AssertNoError(t, err) {
+		err = config.Prepare()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "deleted_marker requires")
+		}
+	}
+}
+
+func TestReplicationJoin(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	pgEntry := conns.Get(connMap[dbio.TypeDbPostgres].name)
+	if pgEntry.Name == "" {
+		t.Skip("postgres connection not available")
+	}
+
+	pgConn, err := pgEntry.Connection.AsDatabase()
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, pgConn.Connect()) {
+		return
+	}
+	defer pgConn.Close()
+
+	_, err = pgConn.ExecMulti(`
+		drop table if exists public.join_orders;
+		create table public.join_orders (order_id int, customer_id int, amount numeric(10,2));
+		insert into public.join_orders values (1, 1, 10.5), (2, 1, 20), (3, 2, 5.25);
+	`)
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	defer pgConn.Exec("drop table if exists public.join_orders")
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("replication_join"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "customers.csv")
+	os.WriteFile(csvPath, []byte("customer_id,name\n1,alice\n2,bob\n3,carol\n"), 0644)
+
+	dbPath := filepath.Join(folder, "target.duckdb")
+	dbURL := "duckdb://" + dbPath
+
+	replicationCfg := g.F(`
+source: %s
+target: %s
+
+join:
+  streams:
+    - name: customers
+      stream: file://%s
+    - name: orders
+      stream: public.join_orders
+  sql: |
+    select c.name, count(*) as orders, sum(o.amount) as total
+    from customers c
+    join orders o on o.customer_id = c.customer_id
+    group by c.name
+  object: main.customer_orders
+`, pgEntry.Name, dbURL, csvPath)
+
+	replicationPath := filepath.Join(folder, "replication.yaml")
+	os.WriteFile(replicationPath, []byte(replicationCfg), 0644)
+
+	if !g.AssertNoError(t, runReplication(replicationPath, nil)) {
+		return
+	}
+
+	conn, err := d.NewConn(dbURL)
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+		return
+	}
+	defer conn.Close()
+
+	data, err := conn.Query("select name, orders, total from main.customer_orders order by name")
+	if g.AssertNoError(t, err) && assert.Len(t, data.Rows, 2) {
+		assert.EqualValues(t, "alice", data.Rows[0][0])
+		assert.EqualValues(t, 2, cast.ToInt(data.Rows[0][1]))
+		assert.EqualValues(t, 30.5, cast.ToFloat64(data.Rows[0][2]))
+		assert.EqualValues(t, "bob", data.Rows[1][0])
+	}
+
+	// ephemeral tables are not in the target
+	data, err = conn.Query("select count(*) from information_schema.tables where table_name in ('customers', 'orders')")
+	if g.AssertNoError(t, err) {
+		assert.EqualValues(t, 0, cast.ToInt(data.Rows[0][0]))
+	}
+}
+
+func TestFetchSize(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	pgEntry := conns.Get(connMap[dbio.TypeDbPostgres].name)
+	if pgEntry.Name == "" {
+		t.Skip("postgres connection not available")
+	}
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("fetch_size"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+
+	// the query counts the open cursors of its session: its own when declared as one
+	cfgStr := g.F(`
+source:
+  conn: %s
+  stream: |
+    select i as id, (select count(*) from pg_cursors where name like 'sling_cursor%%') as cursors
+    from generate_series(1, 2500) i
+  options:
+    fetch_size: 1000
+target:
+  conn: %s
+  object: main.fetch_size
+mode: full-refresh
+`, pgEntry.Name, dbURL)
+
+	config := &sling.Config{}
+	if err := config.Unmarshal(cfgStr); !g.AssertNoError(t, err) {
+		return
+	} else if err = config.Prepare(); !g.AssertNoError(t, err) {
+		return
+	}
+
+	task := sling.NewTask("", config)
+	if !g.AssertNoError(t, task.Err) || !g.AssertNoError(t, task.Execute()) {
+		return
+	}
+
+	conn, err := d.NewConn(dbURL)
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+		return
+	}
+	defer conn.Close()
+
+	data, err := conn.Query("select count(*), min(cursors), max(cursors) from main.fetch_size")
+	if g.AssertNoError(t, err) {
+		assert.EqualValues(t, 2500, cast.ToInt(data.Rows[0][0]))
+		assert.EqualValues(t, 1, cast.ToInt(data.Rows[0][1]))
+		assert.EqualValues(t, 1, cast.ToInt(data.Rows[0][2]))
+	}
+}
+
+func TestReplicationObjectNaming(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("object_naming"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	srcURL := "duckdb://" + filepath.Join(folder, "source.duckdb")
+	os.Setenv("NAMING_SRC", srcURL)
+	os.Setenv("NAMING_TGT", "duckdb://"+filepath.Join(folder, "target.duckdb"))
+	defer os.Unsetenv("NAMING_SRC")
+	defer os.Unsetenv("NAMING_TGT")
+	connection.GetLocalConns(true)
+
+	srcConn, err := d.NewConn(srcURL)
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, srcConn.Connect()) {
+		return
+	}
+	_, err = srcConn.ExecMulti(`
+		create table main.tbl_customers (id int, name varchar);
+		create table main.tbl_orders_v2 (id int, amount double);
+		create table main.audit_log (id int, event varchar);
+	`)
+	srcConn.Close()
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	compile := func(template string) (objects map[string]string, err error) {
+		replication, err := sling.LoadReplicationConfig(g.F(`
+source: NAMING_SRC
+target:
+  conn: NAMING_TGT
+  object_naming:
+    object: '%s'
+    schemas:
+      main: staging
+    rename:
+      '^tbl_(.*)$': '$1'
+      '^(.*)_v\d+$': '$1'
+streams:
+  main.tbl_*:
+  main.audit_log:
+    object: main.audit_copy
+`, template))
+		if err != nil {
+			return
+		} else if err = replication.Compile(nil); err != nil {
+			return
+		}
+
+		objects = map[string]string{}
+		for _, task := range replication.Tasks {
+			objects[task.StreamName] = task.Target.Object
+		}
+		return
+	}
+
+	objects, err := compile("{stream_schema}.stg_{stream_table}")
+	if g.AssertNoError(t, err) && assert.Len(t, objects, 3) {
+		assert.Equal(t, "staging.stg_customers", objects["main.tbl_customers"])
+		assert.Equal(t, "staging.stg_tbl_orders", objects["main.tbl_orders_v2"])
+		assert.Equal(t, "main.audit_copy", objects["main.audit_log"]) // explicit
+	}
+
+	// illegal identifier
+	_, err = compile("{stream_schema}.stg-{stream_table}")
+	assert.Error(t, err)
+}
+
+func TestReplicationFanOut(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("fan_out"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "customers.csv")
+	os.WriteFile(csvPath, []byte("id,name\n1,alice\n2,bob\n3,carol\n"), 0644)
+
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+	outPath := filepath.Join(folder, "out", "customers.csv")
+
+	replicationCfg := g.F(`
+source: LOCAL
+targets:
+  - conn: %s
+    object: main.customers
+  - conn: LOCAL
+    object: file://%s
+streams:
+  file://%s:
+mode: full-refresh
+`, dbURL, outPath, csvPath)
+
+	replicationPath := filepath.Join(folder, "replication.yaml")
+	os.WriteFile(replicationPath, []byte(replicationCfg), 0644)
+
+	if !g.AssertNoError(t, runReplication(replicationPath, nil)) {
+		return
+	}
+
+	// database target
+	conn, err := d.NewConn(dbURL)
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+		return
+	}
+	defer conn.Close()
+
+	data, err := conn.Query("select id, name from main.customers order by id")
+	if g.AssertNoError(t, err) && assert.Len(t, data.Rows, 3) {
+		assert.EqualValues(t, "alice", data.Rows[0][1])
+		assert.EqualValues(t, "carol", data.Rows[2][1])
+	}
+
+	// file target
+	bytes, err := os.ReadFile(outPath)
+	if g.AssertNoError(t, err) {
+		lines := strings.Split(strings.TrimSpace(string(bytes)), "\n")
+		if assert.Len(t, lines, 4) {
+			assert.Equal(t, "id,name", lines[0])
+			assert.Equal(t, "3,carol", lines[3])
+		}
+	}
+
+	// the fan-out results are in the task stats
+	env.TelMux.Lock()
+	taskStats := cast.ToString(env.TelMap["task_stats"])
+	env.TelMux.Unlock()
+	stats := struct {
+		FanOut []sling.FanOutResult `json:"fan_out"`
+	}{}
+	if g.AssertNoError(t, g.Unmarshal(taskStats, &stats)) && assert.Len(t, stats.FanOut, 1) {
+		assert.Equal(t, "LOCAL", stats.FanOut[0].Conn)
+		assert.EqualValues(t, 3, stats.FanOut[0].Rows)
+		assert.Empty(t, stats.FanOut[0].Error)
+	}
+}
+
+func TestAbortOnEmptySource(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("abort_on_empty"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "empty.csv")
+	os.WriteFile(csvPath, []byte("id,name\n"), 0644)
+
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+	conn, err := d.NewConn(dbURL)
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+		return
+	}
+	_, err = conn.ExecMulti(`
+		create table main.customers (id int, name varchar);
+		insert into main.customers values (1, 'alice'), (2, 'bob');
+	`)
+	conn.Close()
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	countRows := func() int {
+		conn, err := d.NewConn(dbURL)
+		if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+			return -1
+		}
+		defer conn.Close()
+		data, err := conn.Query("select count(*) from main.customers")
+		if !g.AssertNoError(t, err) {
+			return -1
+		}
+		return cast.ToInt(data.Rows[0][0])
+	}
+
+	run := func(mode, sourceOptions string) error {
+		cfgStr := g.F(`
+source:
+  stream: file://%s
+  options: %s
+target:
+  conn: %s
+  object: main.customers
+mode: %s
+`, csvPath, sourceOptions, dbURL, mode)
+
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	os.Setenv("SLING_ABORT_ON_EMPTY_SOURCE", "true")
+	defer os.Unsetenv("SLING_ABORT_ON_EMPTY_SOURCE")
+
+	// the target is not truncated
+	for _, mode := range []string{"full-refresh", "truncate"} {
+		err = run(mode, "{}")
+		if assert.Error(t, err, mode) {
+			assert.Contains(t, err.Error(), "returned 0 rows")
+		}
+		assert.Equal(t, 2, countRows(), mode)
+	}
+
+	// direct insert aborts as well
+	os.Setenv("SLING_DIRECT_INSERT", "true")
+	err = run("truncate", "{}")
+	os.Unsetenv("SLING_DIRECT_INSERT")
+	assert.Error(t, err)
+	assert.Equal(t, 2, countRows())
+
+	// per-stream override
+	err = run("truncate", "{abort_on_empty: false}")
+	g.AssertNoError(t, err)
+
+	os.Unsetenv("SLING_ABORT_ON_EMPTY_SOURCE")
+	err = run("truncate", "{abort_on_empty: true}")
+	assert.Error(t, err)
+}
+
+func TestColumnsFrom(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	dbPath := filepath.Join(env.GetTempFolder(), g.NewTsID("columns_from")+".duckdb")
+	defer os.Remove(dbPath)
+	dbURL := "duckdb://" + dbPath
+
+	// reference table has different types than source (id is integer in source)
+	conn, err := d.NewConn(dbURL)
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+		return
+	}
+	_, err = conn.Exec("create table main.ref_table (id varchar, first_name varchar, rating double)")
+	conn.Close()
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	run := func(selectStr string) error {
+		cfgStr := g.F(`
+source:
+  stream: file://tests/files/test1.csv
+  select: %s
+target:
+  conn: %s
+  object: main.columns_from_test
+  options:
+    columns_from:
+      table: main.ref_table
+mode: full-refresh
+`, selectStr, dbURL)
+
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	// stream has columns absent in reference
+	err = run("[]")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not present in reference table")
+	}
+
+	// select trims stream to reference columns
+	if !g.AssertNoError(t, run("[id, first_name, rating]")) {
+		return
+	}
+
+	conn, err = d.NewConn(dbURL)
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+		return
+	}
+	defer conn.Close()
+
+	columns, err := conn.GetColumns("main.columns_from_test")
+	if g.AssertNoError(t, err) && assert.Len(t, columns, 3) {
+		assert.True(t, columns[0].Type.IsString(), columns[0].Type) // casted from integer
+		assert.True(t, columns[2].Type.IsNumber(), columns[2].Type)
+	}
+
+	data, err := conn.Query("select id from main.columns_from_test where id = '1'")
+	if g.AssertNoError(t, err) {
+		assert.Len(t, data.Rows, 1)
+	}
+}
+
+func TestCommitOnInterrupt(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	os.Setenv("SLING_COMMIT_ON_INTERRUPT", "true")
+	defer os.Unsetenv("SLING_COMMIT_ON_INTERRUPT")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("commit_on_interrupt"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	// generate a large enough file to interrupt mid-load
+	totalRows := 3000000
+	csvPath := filepath.Join(folder, "data.csv")
+	file, err := os.Create(csvPath)
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	file.WriteString("id,name\n")
+	for i := 1; i <= totalRows; i++ {
+		file.WriteString(g.F("%d,name_%d\n", i, i))
+	}
+	file.Close()
+
+	dbURL := "duckdb://" + filepath.Join(folder, "test.duckdb")
+	cfgStr := g.F(`
+source:
+  stream: file://%s
+target:
+  conn: %s
+  object: main.commit_on_interrupt
+mode: snapshot
+`, csvPath, dbURL)
+
+	config := &sling.Config{}
+	err = config.Unmarshal(cfgStr)
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, config.Prepare()) {
+		return
+	}
+
+	task := sling.NewTask("", config)
+	if !g.AssertNoError(t, task.Err) {
+		return
+	}
+
+	// simulate interrupt once rows are flowing
+	go func() {
+		for {
+			if task.GetCount() > 10000 && task.Interrupt() {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	err = task.Execute()
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	assert.Equal(t, sling.ExecStatusInterrupted, task.Status)
+
+	conn, err := d.NewConn(dbURL)
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+		return
+	}
+	defer conn.Close()
+
+	count, err := conn.GetCount("main.commit_on_interrupt")
+	if g.AssertNoError(t, err) {
+		assert.Greater(t, count, uint64(10000))
+		assert.Less(t, count, uint64(totalRows))
+		assert.Equal(t, task.GetCount(), count)
+	}
+}
+
 func testDiscover(t *testing.T, pattern string, env map[string]any, connType dbio.Type) {
 
-	conn := connMap[connType]
+	conn := connMap[connType]
+
+	opt := connection.DiscoverOptions{
+		Pattern:   pattern,
+		Level:     database.SchemataLevel(cast.ToString(env["level"])),
+		Recursive: cast.ToBool(env["recursive"]),
+	}
+
+	if g.In(connType, dbio.TypeFileLocal, dbio.TypeFileSftp) && opt.Pattern == "" {
+		opt.Pattern = g.F("/tmp/%s/", connType)
+	}
+	if g.In(connType, dbio.TypeFileFtp) && opt.Pattern == "" {
+		opt.Pattern = g.F("tmp/%s/", connType)
+	}
+
+	g.Info("sling conns discover %s %s", conn.name, g.Marshal(opt))
+	files, schemata, err := conns.Discover(conn.name, &opt)
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	valContains := strings.Split(cast.ToString(env["validation_contains"]), ",")
+	valNotContains := strings.Split(cast.ToString(env["validation_not_contains"]), ",")
+	valContains = lo.Filter(valContains, func(v string, i int) bool { return v != "" })
+	valNotContains = lo.Filter(valNotContains, func(v string, i int) bool { return v != "" })
+
+	valRowCount := cast.ToInt(env["validation_row_count"])
+	valRowCountMin := -1
+	if val := cast.ToString(env["validation_row_count"]); strings.HasPrefix(val, ">") {
+		valRowCountMin = cast.ToInt(strings.TrimPrefix(val, ">"))
+	}
+
+	containsMap := map[string]bool{}
+	for _, word := range append(valContains, valNotContains...) {
+		if word == "" {
+			continue
+		}
+		containsMap[word] = false
+	}
+
+	if connType.IsDb() {
+		schemas := lo.Values(schemata.Database().Schemas)
+		tables := lo.Values(schemata.Tables())
+		columns := iop.Columns(lo.Values(schemata.Columns()))
+		if valRowCount > 0 {
+			switch opt.Level {
+			case d.SchemataLevelSchema:
+				assert.Equal(t, valRowCount, len(schemas), lo.Keys(schemata.Database().Schemas))
+			case d.SchemataLevelTable:
+				assert.Equal(t, valRowCount, len(tables), lo.Keys(schemata.Tables()))
+			case d.SchemataLevelColumn:
+				assert.Equal(t, valRowCount, len(columns), columns.Names())
+			}
+		} else {
+			switch opt.Level {
+			case d.SchemataLevelSchema:
+				assert.Greater(t, len(schemas), 0)
+			case d.SchemataLevelTable:
+				assert.Greater(t, len(tables), 0)
+			case d.SchemataLevelColumn:
+				assert.Greater(t, len(columns), 0)
+			}
+		}
+
+		if valRowCountMin > -1 {
+			switch opt.Level {
+			case d.SchemataLevelSchema:
+				assert.Greater(t, len(schemas), valRowCountMin, lo.Keys(schemata.Database().Schemas))
+			case d.SchemataLevelTable:
+				assert.Greater(t, len(tables), valRowCountMin, lo.Keys(schemata.Tables()))
+			case d.SchemataLevelColumn:
+				assert.Greater(t, len(columns), valRowCountMin, columns.Names())
+			}
+		}
+
+		if len(containsMap) > 0 {
+			var resultType string
+			switch opt.Level {
+			case d.SchemataLevelSchema:
+				resultType = "schemas"
+				for _, schema := range schemas {
+					for word := range containsMap {
+						if strings.EqualFold(word, schema.Name) {
+							containsMap[word] = true
+						}
+					}
+				}
+
+			case d.SchemataLevelTable:
+				resultType = "tables"
+				for _, table := range tables {
+					for word := range containsMap {
+						if strings.EqualFold(word, table.Name) {
+							containsMap[word] = true
+						}
+					}
+				}
+
+			case d.SchemataLevelColumn:
+				resultType = "columns"
+				for _, col := range columns {
+					for word := range containsMap {
+						if strings.EqualFold(word, col.Name) {
+							containsMap[word] = true
+						}
+					}
+				}
+			}
+
+			for _, word := range valContains {
+				found := containsMap[word]
+				assert.True(t, found, "did not find '%s' in %s for %s", word, resultType, connType)
+			}
+
+			for _, word := range valNotContains {
+				found := containsMap[word]
+				assert.False(t, found, "found '%s' in %s for %s", word, resultType, connType)
+			}
+		}
+	}
+
+	if connType.IsFile() {
+		g.Debug("returned into test: " + g.Marshal(files.Paths()))
+		// basic tests
+		assert.Greater(t, len(files), 0)
+		for _, uri := range files.URIs() {
+			u, err := net.NewURL(uri)
+			if !g.AssertNoError(t, err) {
+				break
+			}
+
+			if !g.In(connType, dbio.TypeFileSftp) {
+				if !assert.NotContains(t, strings.TrimPrefix(uri, u.U.Scheme+"://"), "//") {
+					break
+				}
+			}
+		}
+
+		if len(files) == 0 {
+			return
+		}
+
+		columns := iop.Columns(lo.Values(files.Columns()))
+
+		if valRowCount > 0 {
+			if opt.Level == d.SchemataLevelColumn {
+				assert.Equal(t, valRowCount, len(files[0].Columns), g.Marshal(files[0].Columns.Names()))
+			} else {
+				assert.Equal(t, valRowCount, len(files), g.Marshal(files.Paths()))
+			}
+		}
+
+		if valRowCountMin > -1 {
+			if opt.Level == d.SchemataLevelColumn {
+				assert.Greater(t, len(files[0].Columns), valRowCountMin)
+			} else {
+				assert.Greater(t, len(files), valRowCountMin)
+			}
+		}
+
+		if len(containsMap) > 0 {
+			resultType := "files"
+
+			if opt.Level == d.SchemataLevelColumn {
+				resultType = "columns"
+				for _, col := range columns {
+					for word := range containsMap {
+						if strings.EqualFold(word, col.Name) {
+							containsMap[word] = true
+						}
+					}
+				}
+			} else {
+				for _, file := range files {
+					for word := range containsMap {
+						if strings.Contains(file.URI, word) {
+							containsMap[word] = true
+						}
+					}
+				}
+			}
+
+			for _, word := range valContains {
+				found := containsMap[word]
+				assert.True(t, found, "did not find '%s' in %s for %s", word, resultType, connType)
+			}
+
+			for _, word := range valNotContains {
+				found := containsMap[word]
+				assert.False(t, found, "found '%s' in %s for %s", word, resultType, connType)
+			}
+		}
+	}
+
+	g.Info("valContains = %#v", valContains)
+	g.Info("valNotContains = %#v", valNotContains)
+	g.Info("containsMap = %#v", containsMap)
+}
+
+func TestSuiteFileS3(t *testing.T) {
+	t.Parallel()
+	testSuite(t, dbio.TypeFileS3)
+}
+
+func TestSuiteFileGoogle(t *testing.T) {
+	t.Parallel()
+	testSuite(t, dbio.TypeFileGoogle)
+}
+
+func TestSuiteFileAzure(t *testing.T) {
+	t.Parallel()
+	testSuite(t, dbio.TypeFileAzure)
+}
+
+func TestSuiteFileLocal(t *testing.T) {
+	t.Parallel()
+	testSuite(t, dbio.TypeFileLocal)
+}
+
+func TestSuiteFileSftp(t *testing.T) {
+	t.Parallel()
+	testSuite(t, dbio.TypeFileSftp)
+}
+
+func TestSuiteFileFtp(t *testing.T) {
+	t.Parallel()
+	testSuite(t, dbio.TypeFileFtp)
+}
+
+func TestAppendOnly(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("append_only"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "events.csv")
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+
+	run := func(content, primaryKey string) error {
+		os.WriteFile(csvPath, []byte(content), 0644)
+		cfgStr := g.F(`
+source:
+  stream: file://%s
+  primary_key: [%s]
+  update_key: updated_at
+target:
+  conn: %s
+  object: main.events
+  options:
+    append_only: true
+mode: incremental
+`, csvPath, primaryKey, dbURL)
+
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	query := func(sql string) [][]any {
+		conn, err := d.NewConn(dbURL)
+		if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+			return nil
+		}
+		defer conn.Close()
+		data, err := conn.Query(sql)
+		if !g.AssertNoError(t, err) {
+			return nil
+		}
+		return data.Rows
+	}
+
+	err := run("id,name,updated_at\n1,a,2024-01-01\n2,b,2024-01-02\n3,c,2024-01-03\n", "id")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	// id 3 was updated at the source: not merged, as rows are never updated
+	err = run("id,name,updated_at\n3,c2,2024-01-04\n4,d,2024-01-04\n", "id")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	rows := query("select id, name from main.events order by id")
+	if assert.Len(t, rows, 4) {
+		assert.Equal(t, "c", cast.ToString(rows[2][1]))
+		assert.Equal(t, "d", cast.ToString(rows[3][1]))
+	}
+
+	// without a primary key, all the new rows are appended
+	err = run("id,name,updated_at\n4,d2,2024-01-05\n", "")
+	if g.AssertNoError(t, err) {
+		assert.Len(t, query("select id from main.events where id = 4"), 2)
+	}
+}
+
+func TestAddHash(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	os.Setenv("SLING_EXEC_ID_COLUMN", "true")
+	defer os.Unsetenv("SLING_EXEC_ID_COLUMN")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("add_hash"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "customers.csv")
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+
+	run := func(execID, content string) error {
+		os.WriteFile(csvPath, []byte(content), 0644)
+		cfgStr := g.F(`
+source:
+  stream: file://%s
+  primary_key: [id]
+target:
+  conn: %s
+  object: main.customers
+  options:
+    add_hash: true
+mode: incremental
+`, csvPath, dbURL)
+
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask(execID, config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	query := func(sql string) [][]any {
+		conn, err := d.NewConn(dbURL)
+		if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+			return nil
+		}
+		defer conn.Close()
+		data, err := conn.Query(sql)
+		if !g.AssertNoError(t, err) {
+			return nil
+		}
+		return data.Rows
+	}
+
+	err := run("run1", "id,name,joined\n1,a,2024-01-01\n2,b,2024-01-02\n")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	first := query("select id, _sling_row_hash from main.customers order by id")
+
+	// id 1 is unchanged: not updated, with the same hash
+	err = run("run2", "id,name,joined\n1,a,2024-01-01\n2,b2,2024-01-02\n3,c,2024-01-03\n")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	rows := query("select id, name, _sling_exec_id, _sling_row_hash from main.customers order by id")
+	if assert.Len(t, rows, 3) && assert.Len(t, first, 2) {
+		assert.Equal(t, "run1", cast.ToString(rows[0][2]))
+		assert.Equal(t, cast.ToString(first[0][1]), cast.ToString(rows[0][3]))
+		assert.NotEmpty(t, cast.ToString(rows[0][3]))
+
+		assert.Equal(t, "b2", cast.ToString(rows[1][1]))
+		assert.Equal(t, "run2", cast.ToString(rows[1][2]))
+		assert.NotEqual(t, cast.ToString(first[1][1]), cast.ToString(rows[1][3]))
+
+		assert.Equal(t, "run2", cast.ToString(rows[2][2]))
+	}
+}
+
+func TestVersionColumn(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("version_column"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "accounts.csv")
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+
+	run := func(content string) (*sling.Config, error) {
+		os.WriteFile(csvPath, []byte(content), 0644)
+		cfgStr := g.F(`
+source:
+  stream: file://%s
+  primary_key: [id]
+target:
+  conn: %s
+  object: main.accounts
+  options:
+    version_column: version
+mode: incremental
+`, csvPath, dbURL)
+
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return config, err
+		} else if err = config.Prepare(); err != nil {
+			return config, err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return config, task.Err
+		}
+		return config, task.Execute()
+	}
+
+	_, err := run("id,name,version\n1,a,5\n2,b,5\n3,c,5\n")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	// id 1 is older and id 3 has the same version: the target rows are kept
+	config, err := run("id,name,version\n1,a_old,3\n2,b_new,7\n3,c_same,5\n4,d,1\n")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	assert.EqualValues(t, 2, config.Target.KeptCount)
+
+	conn, err := d.NewConn(dbURL)
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+		return
+	}
+	defer conn.Close()
+
+	data, err := conn.Query("select id, name, version from main.accounts order by id")
+	if g.AssertNoError(t, err) && assert.Len(t, data.Rows, 4) {
+		assert.Equal(t, "a", cast.ToString(data.Rows[0][1]))
+		assert.Equal(t, "b_new", cast.ToString(data.Rows[1][1]))
+		assert.EqualValues(t, 7, cast.ToInt(data.Rows[1][2]))
+		assert.Equal(t, "c", cast.ToString(data.Rows[2][1]))
+		assert.Equal(t, "d", cast.ToString(data.Rows[3][1]))
+	}
+
+	// a text version is not comparable
+	_, err = run("id,name,version\n1,a,x\n")
+	assert.Error(t, err)
+}
+
+func TestSurrogateKey(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("surrogate_key"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "contacts.csv")
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+
+	run := func(content, source, surrogateKey string) error {
+		os.WriteFile(csvPath, []byte(content), 0644)
+		cfgStr := g.F(`
+source:
+  stream: file://%s
+  %s
+target:
+  conn: %s
+  object: main.contacts
+  options:
+    surrogate_key: %s
+mode: incremental
+`, csvPath, source, dbURL, surrogateKey)
+
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	conn, err := d.NewConn(dbURL)
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	// keys returns the surrogate key of each natural key
+	keys := func(sql string) map[string]string {
+		g.AssertNoError(t, conn.Connect())
+		defer conn.Close()
+
+		data, err := conn.Query(sql)
+		if !g.AssertNoError(t, err) {
+			return nil
+		}
+		keys := map[string]string{}
+		for _, row := range data.Rows {
+			keys[cast.ToString(row[0])] = cast.ToString(row[1])
+		}
+		return keys
+	}
+
+	t.Run("hash", func(t *testing.T) {
+		// without a primary-key, the hash of the email is the merge key
+		surrogateKey := "{strategy: hash, columns: [email]}"
+		err := run("email,name\na@x.com,a\nb@x.com,b\n", "", surrogateKey)
+		if !g.AssertNoError(t, err) {
+			return
+		}
+		before := keys("select email, _sling_key from main.contacts")
+		assert.Len(t, before, 2)
+		assert.Equal(t, iop.HashRow([]any{"a@x.com"}, iop.HashAlgorithmMD5), before["a@x.com"])
+
+		err = run("email,name\nb@x.com,b_new\nc@x.com,c\n", "", surrogateKey)
+		if !g.AssertNoError(t, err) {
+			return
+		}
+		after := keys("select email, _sling_key from main.contacts")
+		assert.Len(t, after, 3)
+		assert.Equal(t, before["a@x.com"], after["a@x.com"])
+		assert.Equal(t, before["b@x.com"], after["b@x.com"])
+		assert.NotEmpty(t, after["c@x.com"])
+
+		names := keys("select email, name from main.contacts")
+		assert.Equal(t, "b_new", names["b@x.com"])
+
+		g.AssertNoError(t, conn.Connect())
+		conn.Exec("drop table main.contacts")
+		conn.Close()
+	})
+
+	t.Run("identity", func(t *testing.T) {
+		// the identity is generated on insert, and kept by the merge on id
+		surrogateKey := "{strategy: identity, column: contact_key}"
+		err := run("id,name\n1,a\n2,b\n", "primary_key: [id]", surrogateKey)
+		if !g.AssertNoError(t, err) {
+			return
+		}
+		before := keys("select id, contact_key from main.contacts")
+		assert.Len(t, before, 2)
+		assert.NotEmpty(t, before["1"])
+		assert.NotEqual(t, before["1"], before["2"])
+
+		err = run("id,name\n2,b_new\n3,c\n", "primary_key: [id]", surrogateKey)
+		if !g.AssertNoError(t, err) {
+			return
+		}
+		after := keys("select id, contact_key from main.contacts")
+		assert.Len(t, after, 3)
+		assert.Equal(t, before["1"], after["1"])
+		assert.Equal(t, before["2"], after["2"])
+		assert.Greater(t, cast.ToInt(after["3"]), cast.ToInt(after["2"]))
+
+		names := keys("select id, name from main.contacts")
+		assert.Equal(t, "b_new", names["2"])
+	})
+}
+
+func TestTargetColumnTypes(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("target_columns"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "items.csv")
+	os.WriteFile(csvPath, []byte("id,code,amount,name\n1,001,10.5,a\n2,002,20,b\n"), 0644)
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+
+	run := func(columns string) error {
+		cfgStr := g.F(`
+source:
+  stream: file://%s
+target:
+  conn: %s
+  object: main.items
+  options:
+    columns: %s
+mode: full-refresh
+`, csvPath, dbURL, columns)
+
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	query := func(sql string) [][]any {
+		conn, err := d.NewConn(dbURL)
+		if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+			return nil
+		}
+		defer conn.Close()
+		data, err := conn.Query(sql)
+		if !g.AssertNoError(t, err) {
+			return nil
+		}
+		return data.Rows
+	}
+
+	// code is inferred as an integer, amount as a decimal
+	err := run(`{code: "varchar(10)", amount: "double"}`)
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	types := map[string]string{}
+	for _, row := range query("select column_name, data_type from information_schema.columns where table_name = 'items'") {
+		types[cast.ToString(row[0])] = strings.ToLower(cast.ToString(row[1]))
+	}
+	assert.Equal(t, "varchar", types["code"])
+	assert.Equal(t, "double", types["amount"])
+
+	rows := query("select code, amount from main.items order by id")
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, "001", cast.ToString(rows[0][0])) // not parsed as a number
+		assert.Equal(t, "002", cast.ToString(rows[1][0]))
+		assert.EqualValues(t, 20, cast.ToFloat64(rows[1][1]))
+	}
+
+	// text values cannot be cast to an integer
+	err = run(`{name: "integer"}`)
+	assert.ErrorContains(t, err, "cannot cast column name")
+}
+
+func TestColumnMapRename(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("column_map"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "customers.csv")
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+
+	run := func(content, options string) error {
+		os.WriteFile(csvPath, []byte(content), 0644)
+		cfgStr := g.F(`
+source:
+  stream: file://%s
+  primary_key: [id]
+  update_key: updated_at
+target:
+  conn: %s
+  object: main.customers
+  options: %s
+mode: incremental
+`, csvPath, dbURL, options)
+
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	columns := func() []string {
+		conn, err := d.NewConn(dbURL)
+		if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+			return nil
+		}
+		defer conn.Close()
+		cols, err := conn.GetColumns("main.customers")
+		if !g.AssertNoError(t, err) {
+			return nil
+		}
+		return cols.Names()
+	}
+
+	err := run("id,name,updated_at\n1,a,2024-01-01\n2,b,2024-01-02\n", "{}")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	// the target still has the old column, the rename must be explicit
+	content := "id,name,updated_at\n2,b2,2024-01-03\n3,c,2024-01-03\n"
+	err = run(content, "{column_map: {name: full_name}}")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "rename_target_columns")
+	}
+
+	err = run(content, "{column_map: {name: full_name}, rename_target_columns: true}")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	cols := columns()
+	assert.Contains(t, cols, "full_name")
+	assert.NotContains(t, cols, "name")
+
+	conn, err := d.NewConn(dbURL)
+	if g.AssertNoError(t, err) && g.AssertNoError(t, conn.Connect()) {
+		defer conn.Close()
+		data, err := conn.Query("select id, full_name from main.customers order by id")
+		if g.AssertNoError(t, err) && assert.Len(t, data.Rows, 3) {
+			assert.Equal(t, "a", cast.ToString(data.Rows[0][1]))
+			assert.Equal(t, "b2", cast.ToString(data.Rows[1][1]))
+			assert.Equal(t, "c", cast.ToString(data.Rows[2][1]))
+		}
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("assertions"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "payments.csv")
+	os.WriteFile(csvPath, []byte("id,amount\n1,10\n2,-5\n3,20\n"), 0644)
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+
+	run := func(assertions string) error {
+		cfgStr := g.F(`
+source:
+  stream: file://%s
+target:
+  conn: %s
+  object: main.payments
+mode: full-refresh
+assertions: %s
+`, csvPath, dbURL, assertions)
+
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	// passing
+	err := run(`[{name: row_count, sql: "select count(*) from {target_table}", expect: 3}]`)
+	g.AssertNoError(t, err)
+
+	// failing, reported with the offending count
+	err = run(`[{name: no_negative_amounts, sql: "select count(*) from {target_table} where amount < 0"}]`)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "assertion no_negative_amounts failed: expected 0, got 1")
+	}
+
+	// failing with the warn severity
+	err = run(`[{name: no_negative_amounts, sql: "select count(*) from {target_table} where amount < 0", severity: warn}]`)
+	g.AssertNoError(t, err)
+
+	// invalid severity
+	err = run(`[{sql: "select 0", severity: fatal}]`)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid severity")
+	}
+}
+
+func TestFailOnWarning(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+	defer func() { env.Warnings = nil }()
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("fail_on_warning"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "items.csv")
+	os.WriteFile(csvPath, []byte("id,code\n1,a\n2,b\n"), 0644)
+
+	// the unknown column type is coerced into string, with a warning
+	run := func(spec string) error {
+		if err := env.CollectWarnings(spec); err != nil {
+			return err
+		}
+
+		cfgStr := g.F(`
+source:
+  stream: file://%s
+target:
+  object: file://%s
+  columns:
+    code: unknown_type
+`, csvPath, filepath.Join(folder, "out.csv"))
+
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if err := task.Execute(); err != nil {
+			return err
+		}
+		return env.Warnings.Err()
+	}
+
+	err := run("all")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "[types]")
+		assert.Contains(t, err.Error(), "unknown column type")
+	}
+
+	err = run("types,rejects")
+	assert.Error(t, err)
+
+	err = run("rejects")
+	assert.NoError(t, err)
+
+	assert.Error(t, env.CollectWarnings("types,bad"))
+	assert.NoError(t, env.CollectWarnings("false"))
+	assert.Nil(t, env.Warnings)
+
+	// categorized where raised, collected once when logged
+	wc, err := env.NewWarningCollector("rejects,other")
+	g.AssertNoError(t, err)
+	wc.AddCategory(env.WarningRejects, "column 'a' had 3 constraint failures (a > 0)")
+	wc.Add("WRN column 'a' had 3 constraint failures (a > 0)")
+	wc.AddCategory(env.WarningSchema, "schema drift detected for main.items")
+	wc.Add("WRN skipping pre hooks since shell hooks are not enabled")
+	if warnings := wc.Warnings(); assert.Len(t, warnings, 2) {
+		assert.Equal(t, env.WarningRejects, warnings[0].Category)
+		assert.Equal(t, env.WarningOther, warnings[1].Category)
+	}
+
+	args := setOptionalFlagValues([]string{"sling", "run", "--fail-on-warning", "--src-stream", "file://a.csv"})
+	assert.Equal(t, "--fail-on-warning=all", args[2])
+	args = setOptionalFlagValues([]string{"sling", "run", "--fail-on-warning", "types"})
+	assert.Equal(t, []string{"sling", "run", "--fail-on-warning", "types"}, args)
+
+	// global --env-prefix flag, with any command
+	defer os.Unsetenv("SLING_ENV_PREFIX")
+	args = setEnvPrefix([]string{"sling", "conns", "list", "--env-prefix", "MYAPP_"})
+	assert.Equal(t, []string{"sling", "conns", "list"}, args)
+	assert.Equal(t, "MYAPP_", os.Getenv("SLING_ENV_PREFIX"))
+	args = setEnvPrefix([]string{"sling", "--env-prefix=OTHER_", "run", "-r", "r.yaml"})
+	assert.Equal(t, []string{"sling", "run", "-r", "r.yaml"}, args)
+	assert.Equal(t, "OTHER_", os.Getenv("SLING_ENV_PREFIX"))
+}
+
+func TestAddComments(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("add_comments"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "customers.csv")
+	os.WriteFile(csvPath, []byte("id,name,joined\n1,a,2024-01-01\n2,b,2024-01-02\n"), 0644)
+	srcURL := "duckdb://" + filepath.Join(folder, "source.duckdb")
+	tgtURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+
+	run := func(cfgStr string) error {
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	getComments := func(dbURL, table string) (comments d.TableComments) {
+		conn, err := d.NewConn(dbURL)
+		if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+			return
+		}
+		defer conn.Close()
+		comments, err = conn.GetComments(table)
+		g.AssertNoError(t, err)
+		return
+	}
+
+	// file source, with columns_description
+	err := run(g.F(`
+source:
+  stream: file://%s
+target:
+  conn: %s
+  object: main.customers
+  options:
+    columns_description:
+      name: customer name
+      joined: date the customer joined
+      missing: not a column
+`, csvPath, srcURL))
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	comments := getComments(srcURL, "main.customers")
+	assert.Equal(t, map[string]string{"name": "customer name", "joined": "date the customer joined"}, comments.Columns)
+
+	// database source, copying the source comments
+	conn, err := d.NewConn(srcURL)
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+		return
+	}
+	_, err = conn.Exec("comment on table main.customers is 'the customers'")
+	conn.Close()
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	err = run(g.F(`
+source:
+  conn: %s
+  stream: main.customers
+target:
+  conn: %s
+  object: main.customers_copy
+  options:
+    add_comments: true
+    columns_description:
+      id: customer id
+`, srcURL, tgtURL))
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	comments = getComments(tgtURL, "main.customers_copy")
+	assert.Equal(t, "the customers", comments.Table)
+	assert.Equal(t, "customer id", comments.Columns["id"])
+	assert.Equal(t, "customer name", comments.Columns["name"])
+	assert.Equal(t, "date the customer joined", comments.Columns["joined"])
+}
+
+func TestLineageComment(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("lineage_comment"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "customers.csv")
+	os.WriteFile(csvPath, []byte("id,name\n1,a\n2,b\n"), 0644)
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+
+	run := func(mode, policy string) error {
+		config := &sling.Config{}
+		err := config.Unmarshal(g.F(`
+source:
+  stream: file://%s
+target:
+  conn: %s
+  object: main.customers
+  options:
+    lineage_comment: %s
+mode: %s
+`, csvPath, dbURL, policy, mode))
+		if err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	getComment := func() string {
+		conn, err := d.NewConn(dbURL)
+		if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+			return ""
+		}
+		defer conn.Close()
+		comments, err := conn.GetComments("main.customers")
+		g.AssertNoError(t, err)
+		return comments.Table
+	}
+
+	// commented when created
+	if !g.AssertNoError(t, run("full-refresh", "create")) {
+		return
+	}
+	created := getComment()
+	assert.True(t, strings.HasPrefix(created, "Loaded by Sling "), created)
+	assert.Contains(t, created, "customers.csv")
+	assert.Contains(t, created, core.Version)
+
+	time.Sleep(1100 * time.Millisecond) // the load time has a precision of seconds
+
+	// kept on reruns
+	if !g.AssertNoError(t, run("truncate", "create")) {
+		return
+	}
+	assert.Equal(t, created, getComment())
+
+	// updated on every run
+	if !g.AssertNoError(t, run("truncate", "update")) {
+		return
+	}
+	updated := getComment()
+	assert.True(t, strings.HasPrefix(updated, "Loaded by Sling "), updated)
+	assert.NotEqual(t, created, updated)
+}
+
+func TestMergeUpdateColumns(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("merge_update_columns"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "customers.csv")
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+
+	run := func(content string) error {
+		os.WriteFile(csvPath, []byte(content), 0644)
+		cfgStr := g.F(`
+source:
+  stream: file://%s
+  primary_key: [id]
+target:
+  conn: %s
+  object: main.customers
+  options:
+    merge_update_columns: [name]
+mode: incremental
+`, csvPath, dbURL)
+
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	withConn := func(f func(conn d.Connection) error) error {
+		conn, err := d.NewConn(dbURL)
+		if err != nil {
+			return err
+		} else if err = conn.Connect(); err != nil {
+			return err
+		}
+		defer conn.Close()
+		return f(conn)
+	}
+
+	err := run("id,name,score\n1,a,10\n2,b,20\n")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	// the score is enriched downstream, and must not be overwritten
+	err = withConn(func(conn d.Connection) error {
+		_, err := conn.Exec("update main.customers set score = 99 where id = 1")
+		return err
+	})
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	err = run("id,name,score\n1,a2,11\n3,c,30\n")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	var data iop.Dataset
+	err = withConn(func(conn d.Connection) (err error) {
+		data, err = conn.Query("select id, name, score from main.customers order by id")
+		return err
+	})
+	if g.AssertNoError(t, err) && assert.Len(t, data.Rows, 3) {
+		assert.Equal(t, "a2", cast.ToString(data.Rows[0][1]))
+		assert.EqualValues(t, 99, cast.ToInt(data.Rows[0][2])) // not updated
+		assert.EqualValues(t, 20, cast.ToInt(data.Rows[1][2]))
+		assert.Equal(t, "c", cast.ToString(data.Rows[2][1])) // inserted with all columns
+		assert.EqualValues(t, 30, cast.ToInt(data.Rows[2][2]))
+	}
+}
+
+func TestNoTempCleanup(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("no_temp_cleanup"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "orders.csv")
+	os.WriteFile(csvPath, []byte("id,amount\n1,10\n2,20\n"), 0644)
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+
+	// the post-sql fails, after loading the temp table
+	run := func(keepTemp string) error {
+		cfgStr := g.F(`
+source:
+  stream: file://%s
+target:
+  conn: %s
+  object: main.orders
+  options:
+    post_sql: select * from main.missing_table
+`, csvPath, dbURL)
+		if keepTemp != "" {
+			cfgStr += "    keep_temp: " + keepTemp + "\n"
+		}
+
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	tempCount := func() (int, error) {
+		conn, err := d.NewConn(dbURL)
+		if err != nil {
+			return 0, err
+		} else if err = conn.Connect(); err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+
+		data, err := conn.Query("select count(*) from main.orders_tmp")
+		if err != nil {
+			return 0, err
+		}
+		return cast.ToInt(data.Rows[0][0]), nil
+	}
+
+	// dropped by default
+	err := run("")
+	assert.Error(t, err)
+	_, err = tempCount()
+	assert.Error(t, err)
+
+	// kept on failure with the flag
+	os.Setenv("SLING_NO_TEMP_CLEANUP", "failure")
+	err = run("")
+	os.Unsetenv("SLING_NO_TEMP_CLEANUP")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "post")
+	}
+	cnt, err := tempCount()
+	if g.AssertNoError(t, err) {
+		assert.Equal(t, 2, cnt)
+	}
+
+	// the target option disables the flag
+	os.Setenv("SLING_NO_TEMP_CLEANUP", "failure")
+	err = run("false")
+	os.Unsetenv("SLING_NO_TEMP_CLEANUP")
+	assert.Error(t, err)
+	_, err = tempCount()
+	assert.Error(t, err)
+
+	err = run("sometimes")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid value for keep_temp")
+	}
+}
+
+func TestSourceUnion(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("source_union"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	// slightly different column sets
+	storePath := filepath.Join(folder, "orders_store.csv")
+	os.WriteFile(storePath, []byte("id,amount,store_id\n1,10,7\n2,20,8\n"), 0644)
+	webPath := filepath.Join(folder, "orders_web.csv")
+	os.WriteFile(webPath, []byte("id,amount,url\n3,30,https://a\n"), 0644)
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+
+	for _, concurrent := range []bool{false, true} {
+		cfgStr := g.F(`
+source:
+  union:
+    - name: store
+      stream: file://%s
+    - name: web
+      stream: file://%s
+  options:
+    union_column: _source
+    union_concurrent: %t
+target:
+  conn: %s
+  object: main.orders
+mode: full-refresh
+`, storePath, webPath, concurrent, dbURL)
+
+		config := &sling.Config{}
+		err := config.Unmarshal(cfgStr)
+		if !g.AssertNoError(t, err) {
+			return
+		}
+		err = config.Prepare()
+		if !g.AssertNoError(t, err) {
+			return
+		}
+
+		task := sling.NewTask("", config)
+		if !g.AssertNoError(t, task.Err) {
+			return
+		}
+		if !g.AssertNoError(t, task.Execute()) {
+			return
+		}
+
+		conn, err := d.NewConn(dbURL)
+		if !g.AssertNoError(t, err) {
+			return
+		}
+		g.AssertNoError(t, conn.Connect())
+
+		data, err := conn.Query("select id, amount, store_id, url, _source from main.orders order by id")
+		conn.Close()
+		if !g.AssertNoError(t, err) {
+			return
+		}
+		if assert.Len(t, data.Rows, 3) {
+			assert.EqualValues(t, 7, cast.ToInt(data.Rows[0][2]))
+			assert.Nil(t, data.Rows[0][3])
+			assert.Equal(t, "store", cast.ToString(data.Rows[1][4]))
+			assert.Nil(t, data.Rows[2][2])
+			assert.Equal(t, "https://a", cast.ToString(data.Rows[2][3]))
+			assert.Equal(t, "web", cast.ToString(data.Rows[2][4]))
+		}
+	}
+
+	// duplicate streams need distinct names
+	config := &sling.Config{}
+	err := config.Unmarshal(g.F(`
+source:
+  union:
+    - stream: file://%s
+    - stream: file://%s
+target:
+  conn: %s
+  object: main.orders
+`, storePath, storePath, dbURL))
+	if g.AssertNoError(t, err) {
+		err = config.Prepare()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "distinct `name`")
+		}
+	}
+}
+
+func TestTargetView(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("target_view"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+	conn, err := d.NewConn(dbURL)
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	g.AssertNoError(t, conn.Connect())
+	_, err = conn.ExecMulti("create table main.orders (id integer, amount integer); insert into main.orders values (1, 10), (2, 20), (3, 30)")
+	conn.Close()
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	runView := func(sql string) error {
+		config := &sling.Config{}
+		err := config.Unmarshal(g.F(`
+source:
+  conn: %s
+  stream: %s
+target:
+  conn: %s
+  object: main.big_orders
+  options:
+    object_type: view
+`, dbURL, sql, dbURL))
+		if err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	countView := func() int {
+		conn, err := d.NewConn(dbURL)
+		if !g.AssertNoError(t, err) {
+			return -1
+		}
+		g.AssertNoError(t, conn.Connect())
+		defer conn.Close()
+
+		data, err := conn.Query("select count(*) from main.big_orders")
+		if !g.AssertNoError(t, err) || len(data.Rows) == 0 {
+			return -1
+		}
+		return cast.ToInt(data.Rows[0][0])
+	}
+
+	// created, then replaced
+	if g.AssertNoError(t, runView(`"select id, amount from main.orders where amount > 10"`)) {
+		assert.Equal(t, 2, countView())
+	}
+	if g.AssertNoError(t, runView(`"select id, amount from main.orders where amount > 20"`)) {
+		assert.Equal(t, 1, countView())
+	}
+
+	// the source must be a query
+	err = runView("main.orders")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "requires a source sql query")
+	}
+
+	// on the same connection
+	config := &sling.Config{}
+	err = config.Unmarshal(g.F(`
+source:
+  conn: duckdb://%s
+  stream: select 1 as id
+target:
+  conn: %s
+  object: main.big_orders
+  options:
+    object_type: view
+`, filepath.Join(folder, "other.duckdb"), dbURL))
+	if g.AssertNoError(t, err) {
+		err = config.Prepare()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "same connection")
+		}
+	}
+}
+
+func TestTargetOrderBy(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("target_order_by"))
+	os.MkdirAll(filepath.Join(folder, "input"), 0755)
+	defer os.RemoveAll(folder)
+
+	// several files, read in any order
+	for i := 0; i < 3; i++ {
+		lines := []string{"id,name,amount"}
+		for j := 0; j < 50; j++ {
+			id := (j*7 + i) % 100
+			lines = append(lines, g.F("%d,name_%d,%d", id, id, (i+j)%4))
+		}
+		os.WriteFile(filepath.Join(folder, "input", g.F("part_%d.csv", i)), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	}
+
+	run := func(output string) []byte {
+		config := &sling.Config{}
+		err := config.Unmarshal(g.F(`
+source:
+  conn: LOCAL
+  stream: file://%s/
+target:
+  conn: LOCAL
+  object: file://%s
+  options:
+    order_by: [amount desc, id]
+`, filepath.Join(folder, "input"), output))
+		if !g.AssertNoError(t, err) {
+			return nil
+		}
+		if !g.AssertNoError(t, config.Prepare()) {
+			return nil
+		}
+
+		task := sling.NewTask("", config)
+		if !g.AssertNoError(t, task.Err) || !g.AssertNoError(t, task.Execute()) {
+			return nil
+		}
+
+		bytes, err := os.ReadFile(output)
+		g.AssertNoError(t, err)
+		return bytes
+	}
+
+	output1 := run(filepath.Join(folder, "output1.csv"))
+	output2 := run(filepath.Join(folder, "output2.csv"))
+	if !assert.NotEmpty(t, output1) {
+		return
+	}
+	assert.Equal(t, output1, output2)
+
+	lines := strings.Split(strings.TrimSpace(string(output1)), "\n")
+	if assert.Len(t, lines, 151) {
+		assert.True(t, strings.HasPrefix(lines[0], "id,name,amount"))
+		assert.True(t, strings.HasSuffix(lines[1], ",3"), lines[1])
+		assert.True(t, strings.HasSuffix(lines[150], ",0"), lines[150])
+	}
+
+	// same output when spilling to disk
+	os.Setenv("SLING_MAX_MEMORY", "2KB")
+	output3 := run(filepath.Join(folder, "output3.csv"))
+	os.Unsetenv("SLING_MAX_MEMORY")
+	assert.Equal(t, output1, output3)
+}
+
+func TestConnsExecLimit(t *testing.T) {
+	rows := [][]any{}
+	for i := 0; i < 150; i++ {
+		rows = append(rows, []any{i, strings.Repeat("x", 100)})
+	}
+
+	// default limit, long cells truncated
+	out, truncated := limitExecRows(rows, execDisplayLimit, false)
+	assert.True(t, truncated)
+	if assert.Len(t, out, 100) {
+		assert.Equal(t, 99, out[99][0])
+		assert.Len(t, cast.ToString(out[0][1]), execCellWidth)
+		assert.True(t, strings.HasSuffix(cast.ToString(out[0][1]), "..."))
+	}
+	assert.Len(t, cast.ToString(rows[0][1]), 100) // source rows unchanged
+
+	// no limit, wide
+	out, truncated = limitExecRows(rows, 0, true)
+	assert.False(t, truncated)
+	if assert.Len(t, out, 150) {
+		assert.Len(t, cast.ToString(out[0][1]), 100)
+	}
+
+	// exactly at the limit is not truncated
+	out, truncated = limitExecRows(rows[:10], 10, false)
+	assert.False(t, truncated)
+	assert.Len(t, out, 10)
+}
+
+func TestResumeFailed(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("resume_failed"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	homeDir := env.HomeDir
+	env.HomeDir = folder
+	defer func() {
+		env.HomeDir = homeDir
+		os.Unsetenv("SLING_RESUME_FAILED")
+		os.Unsetenv("SLING_FORCE_ALL")
+	}()
+
+	customersPath := filepath.Join(folder, "customers.csv")
+	ordersPath := filepath.Join(folder, "orders.csv")
+	os.WriteFile(customersPath, []byte("id,name\n1,alice\n2,bob\n"), 0644)
+
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+	replicationCfg := g.F(`
+source: LOCAL
+target: %s
+defaults:
+  mode: full-refresh
+streams:
+  file://%s:
+    object: main.customers
+  file://%s:
+    object: main.orders
+`, dbURL, customersPath, ordersPath)
+
+	replicationPath := filepath.Join(folder, "replication.yaml")
+	os.WriteFile(replicationPath, []byte(replicationCfg), 0644)
+
+	countRows := func(table string) int {
+		conn, err := d.NewConn(dbURL)
+		if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+			return -1
+		}
+		defer conn.Close()
+
+		data, err := conn.Query("select count(*) from " + table)
+		if !g.AssertNoError(t, err) {
+			return -1
+		}
+		return cast.ToInt(data.Rows[0][0])
+	}
+
+	// partial failure: the orders file is missing
+	assert.Error(t, runReplication(replicationPath, nil))
+	assert.Equal(t, 2, countRows("main.customers"))
+
+	// resume: only the orders stream runs, the customers table is not reloaded
+	os.WriteFile(ordersPath, []byte("id,amount\n1,10\n2,20\n3,30\n"), 0644)
+	os.WriteFile(customersPath, []byte("id,name\n1,alice\n2,bob\n3,carol\n"), 0644)
+	os.Setenv("SLING_RESUME_FAILED", "true")
+	if !g.AssertNoError(t, runReplication(replicationPath, nil)) {
+		return
+	}
+	assert.Equal(t, 2, countRows("main.customers"))
+	assert.Equal(t, 3, countRows("main.orders"))
+
+	// nothing left to resume
+	os.WriteFile(ordersPath, []byte("id,amount\n1,10\n"), 0644)
+	g.AssertNoError(t, runReplication(replicationPath, nil))
+	assert.Equal(t, 3, countRows("main.orders"))
+
+	// force all streams
+	os.Setenv("SLING_FORCE_ALL", "true")
+	g.AssertNoError(t, runReplication(replicationPath, nil))
+	assert.Equal(t, 3, countRows("main.customers"))
+	assert.Equal(t, 1, countRows("main.orders"))
+}
+
+func TestSourceExtract(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
+
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("source_extract"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	srcURL := "duckdb://" + filepath.Join(folder, "source.duckdb")
+	conn, err := d.NewConn(srcURL)
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	g.AssertNoError(t, conn.Connect())
+	_, err = conn.ExecMulti(`create table main.events (id integer, payload varchar); insert into main.events values (1, '{"user": {"id": 10, "name": "alice"}}'), (2, '{"user": {"id": 20}}'), (3, null)`)
+	conn.Close()
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	csvPath := filepath.Join(folder, "events.csv")
+	os.WriteFile(csvPath, []byte("id,payload\n1,\"{\"\"user\"\": {\"\"id\"\": 10, \"\"name\"\": \"\"alice\"\"}}\"\n2,\"{\"\"user\"\": {\"\"id\"\": 20}}\"\n3,\n"), 0644)
+
+	tgtURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+
+	run := func(source, stream string) error {
+		config := &sling.Config{}
+		err := config.Unmarshal(g.F(`
+source:
+  conn: %s
+  stream: %s
+  options:
+    extract:
+      - { from: payload, path: $.user.id, as: user_id, type: integer }
+      - { from: payload, path: $.user.name, as: user_name }
+target:
+  conn: %s
+  object: main.events
+mode: full-refresh
+`, source, stream, tgtURL))
+		if err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
+	}
+
+	check := func() {
+		conn, err := d.NewConn(tgtURL)
+		if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+			return
+		}
+		defer conn.Close()
+
+		data, err := conn.Query("select id, user_id, user_name from main.events order by id")
+		if g.AssertNoError(t, err) && assert.Len(t, data.Rows, 3) {
+			assert.True(t, data.Columns[1].IsInteger(), data.Columns[1].Type)
+			assert.EqualValues(t, 10, cast.ToInt(data.Rows[0][1]))
+			assert.EqualValues(t, "alice", data.Rows[0][2])
+			assert.EqualValues(t, 20, cast.ToInt(data.Rows[1][1]))
+			assert.Nil(t, data.Rows[1][2])
+			assert.Nil(t, data.Rows[2][1])
+		}
+	}
+
+	// pushed down with the JSON functions of the source database
+	if g.AssertNoError(t, run(srcURL, "main.events")) {
+		check()
+	}
+
+	// extracted in the stream
+	if g.AssertNoError(t, run("LOCAL", "file://"+csvPath)) {
+		check()
+	}
+
+	// invalid rule
+	config := &sling.Config{}
+	err = config.Unmarshal(g.F(`
+source:
+  conn: %s
+  stream: main.events
+  options:
+    extract:
+      - { from: payload, path: user.id, as: user_id }
+target:
+  conn: %s
+  object: main.events
+`, srcURL, tgtURL))
+	if g.AssertNoError(t, err) {
+		err = config.Prepare()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "invalid value for extract")
+		}
+	}
+}
+
+func TestNoCreate(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
 
-	opt := connection.DiscoverOptions{
-		Pattern:   pattern,
-		Level:     database.SchemataLevel(cast.ToString(env["level"])),
-		Recursive: cast.ToBool(env["recursive"]),
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("no_create"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
+
+	csvPath := filepath.Join(folder, "events.csv")
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
+
+	run := func(content string) error {
+		os.WriteFile(csvPath, []byte(content), 0644)
+		cfgStr := g.F(`
+source:
+  stream: file://%s
+target:
+  conn: %s
+  object: main.events
+  options:
+    no_create: true
+mode: truncate
+`, csvPath, dbURL)
+
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
+		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
 	}
 
-	if g.In(connType, dbio.TypeFileLocal, dbio.TypeFileSftp) && opt.Pattern == "" {
-		opt.Pattern = g.F("/tmp/%s/", connType)
+	query := func(sql string) [][]any {
+		conn, err := d.NewConn(dbURL)
+		if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+			return nil
+		}
+		defer conn.Close()
+		data, err := conn.Query(sql)
+		if !g.AssertNoError(t, err) {
+			return nil
+		}
+		return data.Rows
 	}
-	if g.In(connType, dbio.TypeFileFtp) && opt.Pattern == "" {
-		opt.Pattern = g.F("tmp/%s/", connType)
+
+	// the table is missing: not created
+	err := run("id,name\n1,a\n")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not exist")
 	}
+	assert.Len(t, query("select table_name from information_schema.tables"), 0)
 
-	g.Info("sling conns discover %s %s", conn.name, g.Marshal(opt))
-	files, schemata, err := conns.Discover(conn.name, &opt)
+	// the existing table has an extra column, and types sling would not infer
+	conn, err := d.NewConn(dbURL)
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+		return
+	}
+	_, err = conn.Exec(`create table main.events (id varchar, name varchar, note varchar)`)
+	conn.Close()
 	if !g.AssertNoError(t, err) {
 		return
 	}
 
-	valContains := strings.Split(cast.ToString(env["validation_contains"]), ",")
-	valNotContains := strings.Split(cast.ToString(env["validation_not_contains"]), ",")
-	valContains = lo.Filter(valContains, func(v string, i int) bool { return v != "" })
-	valNotContains = lo.Filter(valNotContains, func(v string, i int) bool { return v != "" })
-
-	valRowCount := cast.ToInt(env["validation_row_count"])
-	valRowCountMin := -1
-	if val := cast.ToString(env["validation_row_count"]); strings.HasPrefix(val, ">") {
-		valRowCountMin = cast.ToInt(strings.TrimPrefix(val, ">"))
+	err = run("id,name\n1,a\n2,b\n")
+	if !g.AssertNoError(t, err) {
+		return
 	}
+	assert.Len(t, query("select id, name from main.events"), 2)
 
-	containsMap := map[string]bool{}
-	for _, word := range append(valContains, valNotContains...) {
-		if word == "" {
-			continue
+	// no DDL: no other table (such as a temp table), columns unaltered
+	tables := query("select table_name from information_schema.tables")
+	if assert.Len(t, tables, 1) {
+		assert.Equal(t, "events", cast.ToString(tables[0][0]))
+	}
+	columns := query("select column_name, data_type from information_schema.columns where table_name = 'events' order by ordinal_position")
+	if assert.Len(t, columns, 3) {
+		for i, name := range []string{"id", "name", "note"} {
+			assert.Equal(t, name, cast.ToString(columns[i][0]))
+			assert.Equal(t, "VARCHAR", cast.ToString(columns[i][1]))
 		}
-		containsMap[word] = false
 	}
 
-	if connType.IsDb() {
-		schemas := lo.Values(schemata.Database().Schemas)
-		tables := lo.Values(schemata.Tables())
-		columns := iop.Columns(lo.Values(schemata.Columns()))
-		if valRowCount > 0 {
-			switch opt.Level {
-			case d.SchemataLevelSchema:
-				assert.Equal(t, valRowCount, len(schemas), lo.Keys(schemata.Database().Schemas))
-			case d.SchemataLevelTable:
-				assert.Equal(t, valRowCount, len(tables), lo.Keys(schemata.Tables()))
-			case d.SchemataLevelColumn:
-				assert.Equal(t, valRowCount, len(columns), columns.Names())
-			}
-		} else {
-			switch opt.Level {
-			case d.SchemataLevelSchema:
-				assert.Greater(t, len(schemas), 0)
-			case d.SchemataLevelTable:
-				assert.Greater(t, len(tables), 0)
-			case d.SchemataLevelColumn:
-				assert.Greater(t, len(columns), 0)
-			}
-		}
+	// stream columns missing in the table: not added
+	err = run("id,name,amount\n3,c,1.5\n")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not have stream columns amount")
+	}
+	columns = query("select column_name from information_schema.columns where table_name = 'events'")
+	assert.Len(t, columns, 3)
+}
 
-		if valRowCountMin > -1 {
-			switch opt.Level {
-			case d.SchemataLevelSchema:
-				assert.Greater(t, len(schemas), valRowCountMin, lo.Keys(schemata.Database().Schemas))
-			case d.SchemataLevelTable:
-				assert.Greater(t, len(tables), valRowCountMin, lo.Keys(schemata.Tables()))
-			case d.SchemataLevelColumn:
-				assert.Greater(t, len(columns), valRowCountMin, columns.Names())
-			}
-		}
+func TestNormalize(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
 
-		if len(containsMap) > 0 {
-			var resultType string
-			switch opt.Level {
-			case d.SchemataLevelSchema:
-				resultType = "schemas"
-				for _, schema := range schemas {
-					for word := range containsMap {
-						if strings.EqualFold(word, schema.Name) {
-							containsMap[word] = true
-						}
-					}
-				}
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("normalize"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
 
-			case d.SchemataLevelTable:
-				resultType = "tables"
-				for _, table := range tables {
-					for word := range containsMap {
-						if strings.EqualFold(word, table.Name) {
-							containsMap[word] = true
-						}
-					}
-				}
+	jsonPath := filepath.Join(folder, "orders.json")
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
 
-			case d.SchemataLevelColumn:
-				resultType = "columns"
-				for _, col := range columns {
-					for word := range containsMap {
-						if strings.EqualFold(word, col.Name) {
-							containsMap[word] = true
-						}
-					}
-				}
-			}
+	os.WriteFile(jsonPath, []byte(`[
+		{"order_id": 1, "status": "open", "items": [{"sku": "a", "qty": 2}, {"sku": "b", "qty": 1}]},
+		{"order_id": 2, "status": "paid", "items": [{"sku": "c", "qty": 5}]},
+		{"order_id": 3, "status": "open", "items": []}
+	]`), 0644)
 
-			for _, word := range valContains {
-				found := containsMap[word]
-				assert.True(t, found, "did not find '%s' in %s for %s", word, resultType, connType)
-			}
+	cfgStr := g.F(`
+source:
+  stream: file://%s
+  primary_key: [order_id]
+  options:
+    format: json
+    normalize: true
+target:
+  conn: %s
+  object: main.orders
+mode: full-refresh
+`, jsonPath, dbURL)
 
-			for _, word := range valNotContains {
-				found := containsMap[word]
-				assert.False(t, found, "found '%s' in %s for %s", word, resultType, connType)
-			}
+	for i := 0; i < 2; i++ { // twice, child table replaced
+		config := &sling.Config{}
+		if !g.AssertNoError(t, config.Unmarshal(cfgStr)) || !g.AssertNoError(t, config.Prepare()) {
+			return
+		}
+		task := sling.NewTask("", config)
+		if !g.AssertNoError(t, task.Err) || !g.AssertNoError(t, task.Execute()) {
+			return
 		}
 	}
 
-	if connType.IsFile() {
-		g.Debug("returned into test: " + g.Marshal(files.Paths()))
-		// basic tests
-		assert.Greater(t, len(files), 0)
-		for _, uri := range files.URIs() {
-			u, err := net.NewURL(uri)
-			if !g.AssertNoError(t, err) {
-				break
-			}
+	conn, err := d.NewConn(dbURL)
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+		return
+	}
+	defer conn.Close()
 
-			if !g.In(connType, dbio.TypeFileSftp) {
-				if !assert.NotContains(t, strings.TrimPrefix(uri, u.U.Scheme+"://"), "//") {
-					break
-				}
-			}
-		}
+	data, err := conn.Query(`select * from main.orders order by order_id`)
+	if g.AssertNoError(t, err) {
+		assert.Len(t, data.Rows, 3)
+		assert.Nil(t, data.Columns.GetColumn("items"))
+	}
 
-		if len(files) == 0 {
+	data, err = conn.Query(`select parent_order_id, _index, sku, qty from main.orders_items order by parent_order_id, _index`)
+	if g.AssertNoError(t, err) && assert.Len(t, data.Rows, 3) {
+		assert.EqualValues(t, 1, cast.ToInt(data.Rows[1][0]))
+		assert.EqualValues(t, 1, cast.ToInt(data.Rows[1][1]))
+		assert.Equal(t, "b", cast.ToString(data.Rows[1][2]))
+		assert.EqualValues(t, 5, cast.ToInt(data.Rows[2][3]))
+	}
+
+	// incremental: the items of the loaded orders are replaced, the others kept
+	os.WriteFile(jsonPath, []byte(`[
+		{"order_id": 1, "status": "paid", "items": [{"sku": "z", "qty": 3}]},
+		{"order_id": 3, "status": "paid", "items": [{"sku": "d", "qty": 4}]}
+	]`), 0644)
+	conn.Close() // the target database is locked while open
+	config := &sling.Config{}
+	if g.AssertNoError(t, config.Unmarshal(strings.ReplaceAll(cfgStr, "mode: full-refresh", "mode: incremental"))) && g.AssertNoError(t, config.Prepare()) {
+		task := sling.NewTask("", config)
+		if !g.AssertNoError(t, task.Err) || !g.AssertNoError(t, task.Execute()) {
 			return
 		}
+	}
 
-		columns := iop.Columns(lo.Values(files.Columns()))
+	conn, err = d.NewConn(dbURL)
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+		return
+	}
+	defer conn.Close()
+
+	data, err = conn.Query(`select parent_order_id, _index, sku from main.orders_items order by parent_order_id, _index`)
+	if g.AssertNoError(t, err) && assert.Len(t, data.Rows, 3) {
+		assert.Equal(t, "z", cast.ToString(data.Rows[0][2]))
+		assert.Equal(t, "c", cast.ToString(data.Rows[1][2]))
+		assert.EqualValues(t, 3, cast.ToInt(data.Rows[2][0]))
+		assert.Equal(t, "d", cast.ToString(data.Rows[2][2]))
+	}
 
-		if valRowCount > 0 {
-			if opt.Level == d.SchemataLevelColumn {
-				assert.Equal(t, valRowCount, len(files[0].Columns), g.Marshal(files[0].Columns.Names()))
-			} else {
-				assert.Equal(t, valRowCount, len(files), g.Marshal(files.Paths()))
-			}
+	// needs a primary key
+	config = &sling.Config{}
+	if g.AssertNoError(t, config.Unmarshal(strings.ReplaceAll(cfgStr, "primary_key: [order_id]", ""))) {
+		err = config.Prepare()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "needs a primary key")
 		}
+	}
+}
 
-		if valRowCountMin > -1 {
-			if opt.Level == d.SchemataLevelColumn {
-				assert.Greater(t, len(files[0].Columns), valRowCountMin)
-			} else {
-				assert.Greater(t, len(files), valRowCountMin)
-			}
-		}
+func TestAtomicSwap(t *testing.T) {
+	os.Setenv("SLING_CLI", "TRUE")
+	sling.ShowProgress = false
 
-		if len(containsMap) > 0 {
-			resultType := "files"
+	folder := filepath.Join(env.GetTempFolder(), g.NewTsID("atomic_swap"))
+	os.MkdirAll(folder, 0755)
+	defer os.RemoveAll(folder)
 
-			if opt.Level == d.SchemataLevelColumn {
-				resultType = "columns"
-				for _, col := range columns {
-					for word := range containsMap {
-						if strings.EqualFold(word, col.Name) {
-							containsMap[word] = true
-						}
-					}
-				}
-			} else {
-				for _, file := range files {
-					for word := range containsMap {
-						if strings.Contains(file.URI, word) {
-							containsMap[word] = true
-						}
-					}
-				}
-			}
+	csvPath := filepath.Join(folder, "orders.csv")
+	dbURL := "duckdb://" + filepath.Join(folder, "target.duckdb")
 
-			for _, word := range valContains {
-				found := containsMap[word]
-				assert.True(t, found, "did not find '%s' in %s for %s", word, resultType, connType)
-			}
+	run := func(content string) error {
+		os.WriteFile(csvPath, []byte(content), 0644)
+		cfgStr := g.F(`
+source:
+  stream: file://%s
+target:
+  conn: %s
+  object: main.orders
+  options:
+    write_mode: atomic-swap
+mode: full-refresh
+`, csvPath, dbURL)
 
-			for _, word := range valNotContains {
-				found := containsMap[word]
-				assert.False(t, found, "found '%s' in %s for %s", word, resultType, connType)
-			}
+		config := &sling.Config{}
+		if err := config.Unmarshal(cfgStr); err != nil {
+			return err
+		} else if err = config.Prepare(); err != nil {
+			return err
 		}
+
+		task := sling.NewTask("", config)
+		if task.Err != nil {
+			return task.Err
+		}
+		return task.Execute()
 	}
 
-	g.Info("valContains = %#v", valContains)
-	g.Info("valNotContains = %#v", valNotContains)
-	g.Info("containsMap = %#v", containsMap)
-}
+	query := func(sql string) [][]any {
+		conn, err := d.NewConn(dbURL)
+		if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+			return nil
+		}
+		defer conn.Close()
+		data, err := conn.Query(sql)
+		if !g.AssertNoError(t, err) {
+			return nil
+		}
+		return data.Rows
+	}
 
-func TestSuiteFileS3(t *testing.T) {
-	t.Parallel()
-	testSuite(t, dbio.TypeFileS3)
-}
+	// the target does not exist yet: the temp table is renamed
+	if !g.AssertNoError(t, run("id,name\n1,a\n2,b\n")) {
+		return
+	}
+	assert.Len(t, query("select * from main.orders"), 2)
 
-func TestSuiteFileGoogle(t *testing.T) {
-	t.Parallel()
-	testSuite(t, dbio.TypeFileGoogle)
-}
+	// a view selecting from the target
+	conn, err := d.NewConn(dbURL)
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+		return
+	}
+	_, err = conn.Exec(`create view main.orders_view as select id, name from main.orders`)
+	if g.AssertNoError(t, err) {
+		// a leftover of a failed swap
+		_, err = conn.Exec(`create table main.orders_old (id integer)`)
+	}
+	conn.Close()
+	if !g.AssertNoError(t, err) {
+		return
+	}
 
-func TestSuiteFileAzure(t *testing.T) {
-	t.Parallel()
-	testSuite(t, dbio.TypeFileAzure)
+	// swapped: new rows, no temp or previous table left
+	if !g.AssertNoError(t, run("id,name,amount\n3,c,1.5\n")) {
+		return
+	}
+	rows := query("select id, name from main.orders_view")
+	if assert.Len(t, rows, 1) {
+		assert.EqualValues(t, 3, cast.ToInt(rows[0][0]))
+	}
+	assert.Len(t, query("select amount from main.orders"), 1)
+	tables := query("select table_name from information_schema.tables where table_type = 'BASE TABLE'")
+	if assert.Len(t, tables, 1) {
+		assert.Equal(t, "orders", cast.ToString(tables[0][0]))
+	}
 }
 
-func TestSuiteFileLocal(t *testing.T) {
-	t.Parallel()
-	testSuite(t, dbio.TypeFileLocal)
-}
+func TestRunHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks are tested with sh")
+	}
 
-func TestSuiteFileSftp(t *testing.T) {
-	t.Parallel()
-	testSuite(t, dbio.TypeFileSftp)
-}
+	folder := t.TempDir()
+	successPath := filepath.Join(folder, "success.txt")
+	failurePath := filepath.Join(folder, "failure.txt")
+	readFile := func(path string) string {
+		bytes, _ := os.ReadFile(path)
+		return strings.TrimSpace(string(bytes))
+	}
 
-func TestSuiteFileFtp(t *testing.T) {
-	t.Parallel()
-	testSuite(t, dbio.TypeFileFtp)
+	hooks := runHooks{
+		onSuccess: `echo "$SLING_STATUS $SLING_ROWS $SLING_DURATION" > ` + successPath,
+		onFailure: `echo "$SLING_STATUS $SLING_ERROR" > ` + failurePath,
+	}
+
+	// success, only the success hook fires
+	err := hooks.execute(nil, 42, 1500*time.Millisecond)
+	g.AssertNoError(t, err)
+	assert.Equal(t, "success 42 1.500", readFile(successPath))
+	assert.NoFileExists(t, failurePath)
+
+	// failure, the run error is kept
+	os.Remove(successPath)
+	runErr := g.Error("could not connect")
+	err = hooks.execute(runErr, 0, time.Second)
+	assert.Equal(t, runErr, err)
+	assert.True(t, strings.HasPrefix(readFile(failurePath), "error "))
+	assert.Contains(t, readFile(failurePath), "could not connect")
+	assert.NoFileExists(t, successPath)
+
+	// a failing hook is logged, unless strict
+	hooks = runHooks{onSuccess: "echo 'notify failed'; exit 3", onFailure: "exit 3"}
+	g.AssertNoError(t, hooks.execute(nil, 0, time.Second))
+
+	hooks.strict = true
+	err = hooks.execute(nil, 0, time.Second)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "hook on-success failed")
+	}
+	assert.Equal(t, runErr, hooks.execute(runErr, 0, time.Second))
+
+	// no hook for the outcome
+	assert.Equal(t, runErr, runHooks{onSuccess: "exit 1", strict: true}.execute(runErr, 0, time.Second))
 }
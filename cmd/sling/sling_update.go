@@ -226,7 +226,7 @@ func getSlingPackage() string {
 }
 
 func printUpdateAvailable() {
-	if updateVersion != "" {
+	if updateVersion != "" && !env.IsQuiet() {
 		println(updateMessage)
 	}
 }
@@ -19,6 +19,7 @@ import (
 	"github.com/flarco/g/net"
 	"github.com/slingdata-io/sling-cli/core/dbio/database"
 	"github.com/slingdata-io/sling-cli/core/dbio/filesys"
+	"github.com/slingdata-io/sling-cli/core/env"
 	"github.com/spf13/cast"
 )
 
@@ -182,6 +183,37 @@ func (c *Connection) Copy() *Connection {
 	return &nc
 }
 
+// IsSecretKey returns true if the property key looks like it holds a secret (see env.IsSecretKey)
+func IsSecretKey(key string) bool {
+	return env.IsSecretKey(key)
+}
+
+// RedactProps returns a copy of the properties with secret values masked.
+// Env-var references (e.g. `${PG_PASSWORD}`) are kept as-is, as well
+// as the URL, with its password masked.
+func RedactProps(props map[string]any) map[string]any {
+	redacted := map[string]any{}
+	for k, v := range props {
+		value := cast.ToString(v)
+		switch {
+		case value == "" || env.IsEnvReference(value):
+			redacted[k] = v
+		case env.IsSecretKey(k):
+			redacted[k] = "***"
+		case strings.EqualFold(k, "url") || strings.Contains(value, "://"):
+			redacted[k] = env.RedactURL(value)
+		default:
+			redacted[k] = v
+		}
+	}
+	return redacted
+}
+
+// RedactURL masks the password & the secret query parameters of a URL (see env.RedactURL)
+func RedactURL(value string) string {
+	return env.RedactURL(value)
+}
+
 // Set sets key/values from a map
 func (c *Connection) Set(m map[string]interface{}) {
 	for k, v := range m {
@@ -379,6 +411,9 @@ func (c *Connection) setURL() (err error) {
 			// this does not return the full error since that can leak passwords
 			// only in TRACE debug mode
 			g.Trace(err.Error())
+			if uErr := ValidateURL(c.URL()); uErr != nil {
+				return g.Error(uErr, "could not parse provided credentials / url")
+			}
 			return g.Error("could not parse provided credentials / url")
 		}
 
@@ -409,6 +444,8 @@ func (c *Connection) setURL() (err error) {
 			} else if c.Type == dbio.TypeDbBigTable {
 				setIfMissing("project", U.Hostname())
 				setIfMissing("instance", pathValue)
+			} else if c.Type == dbio.TypeDbFirestore {
+				setIfMissing("project", U.Hostname())
 			} else if c.Type == dbio.TypeDbSQLite || c.Type == dbio.TypeDbDuckDb {
 				setIfMissing("instance", U.Path())
 				setIfMissing("schema", "main")
@@ -541,11 +578,58 @@ func (c *Connection) setURL() (err error) {
 		}
 
 		template = "prometheus://{host}"
+	case dbio.TypeDbInfluxDB:
+		// parse http url
+		if httpUrlStr, ok := c.Data["http_url"]; ok {
+			u, err := url.Parse(cast.ToString(httpUrlStr))
+			if err != nil {
+				g.Warn("invalid http_url: %s", err.Error())
+			} else {
+				setIfMissing("host", u.Hostname())
+				if u.Port() != "" {
+					setIfMissing("port", u.Port())
+				}
+			}
+		}
+
+		setIfMissing("username", c.Data["user"])
+		setIfMissing("password", "")
+		setIfMissing("port", c.Type.DefPort())
+		setIfMissing("database", c.Data["bucket"])
+
+		template = "influxdb://{host}:{port}/{database}"
 	case dbio.TypeDbBigTable:
 		template = "bigtable://{project}/{instance}?"
 		if _, ok := c.Data["keyfile"]; ok {
 			template = template + "&credentialsFile={keyfile}"
 		}
+	case dbio.TypeDbPubSub:
+		template = "pubsub://{project}?"
+		if _, ok := c.Data["keyfile"]; ok {
+			template = template + "&credentialsFile={keyfile}"
+		}
+	case dbio.TypeDbFirestore:
+		template = "firestore://{project}?"
+		if _, ok := c.Data["database"]; ok {
+			template = template + "&database={database}"
+		}
+		if _, ok := c.Data["keyfile"]; ok {
+			template = template + "&credentialsFile={keyfile}"
+		}
+	case dbio.TypeDbCassandra:
+		setIfMissing("host", c.Data["hosts"])
+		setIfMissing("username", c.Data["user"])
+		setIfMissing("password", "")
+		setIfMissing("keyspace", c.Data["database"])
+		setIfMissing("schema", c.Data["keyspace"])
+		setIfMissing("port", c.Type.DefPort())
+		template = "cassandra://{username}:{password}@{host}:{port}/{keyspace}?"
+		if _, ok := c.Data["consistency"]; ok {
+			template = template + "&consistency={consistency}"
+		}
+		if _, ok := c.Data["datacenter"]; ok {
+			template = template + "&datacenter={datacenter}"
+		}
 	case dbio.TypeDbSnowflake:
 		// setIfMissing("schema", "public")
 		// template = "snowflake://{username}:{password}@{host}.snowflakecomputing.com:443/{database}?schema={schema}&warehouse={warehouse}"
@@ -711,9 +795,147 @@ func (c *Connection) setURL() (err error) {
 	urlData["password"] = url.QueryEscape(cast.ToString(urlData["password"]))
 	setIfMissing("url", g.Rm(template, urlData))
 
+	if !c.Type.IsDb() {
+		return nil
+	}
+
+	// append driver-specific params verbatim
+	params, err := ParseURLParams(c.Data["url_params"])
+	if err != nil {
+		return g.Error(err, "invalid url_params")
+	}
+
+	// identify our queries on the server side
+	if g.In(c.Type, dbio.TypeDbPostgres, dbio.TypeDbRedshift) {
+		if _, ok := params["application_name"]; !ok && !strings.Contains(c.URL(), "application_name=") {
+			params["application_name"] = lo.Ternary(cast.ToString(c.Data["application_name"]) != "", cast.ToString(c.Data["application_name"]), "sling")
+		}
+	}
+
+	c.Data["url"] = AppendURLParams(c.URL(), params)
+
 	return nil
 }
 
+const (
+	// DefaultSourceOptionsKey is the property of source options applied to every run using the connection
+	DefaultSourceOptionsKey = "default_source_options"
+	// DefaultTargetOptionsKey is the property of target options applied to every run using the connection
+	DefaultTargetOptionsKey = "default_target_options"
+)
+
+// DefaultOptions returns the default options of the property key
+// (DefaultSourceOptionsKey or DefaultTargetOptionsKey), provided as a map
+// or a JSON / YAML object string
+func (c *Connection) DefaultOptions(key string) (options map[string]any, err error) {
+	return parseOptionsMap(c.Data[key])
+}
+
+func parseOptionsMap(val any) (options map[string]any, err error) {
+	options = map[string]any{}
+	switch v := val.(type) {
+	case nil:
+		return options, nil
+	case map[string]any, map[any]any:
+		for k, val := range cast.ToStringMap(v) {
+			options[strings.ToLower(k)] = stringKeys(val)
+		}
+		return options, nil
+	}
+
+	str := strings.TrimSpace(cast.ToString(val))
+	if str == "" {
+		return options, nil
+	}
+
+	m := map[string]any{}
+	if err = yaml.Unmarshal([]byte(str), &m); err != nil {
+		return nil, g.Error(err, "could not parse options, expected a map: %s", str)
+	}
+	return parseOptionsMap(m)
+}
+
+// stringKeys converts nested YAML maps (map[any]any) to map[string]any, to marshal as JSON
+func stringKeys(val any) any {
+	switch v := val.(type) {
+	case map[any]any, map[string]any:
+		m := map[string]any{}
+		for k, val := range cast.ToStringMap(v) {
+			m[k] = stringKeys(val)
+		}
+		return m
+	case []any:
+		arr := make([]any, len(v))
+		for i, val := range v {
+			arr[i] = stringKeys(val)
+		}
+		return arr
+	}
+	return val
+}
+
+// ParseURLParams parses the `url_params` property, provided as a map,
+// a JSON object string or a query string (e.g. `tls=true&timeout=10s`)
+func ParseURLParams(val any) (params map[string]string, err error) {
+	params = map[string]string{}
+	switch v := val.(type) {
+	case nil:
+	case map[string]any:
+		for k, val := range v {
+			params[k] = cast.ToString(val)
+		}
+	case map[any]any:
+		for k, val := range v {
+			params[cast.ToString(k)] = cast.ToString(val)
+		}
+	case map[string]string:
+		for k, val := range v {
+			params[k] = val
+		}
+	default:
+		str := strings.TrimSpace(cast.ToString(v))
+		if str == "" {
+			return params, nil
+		} else if strings.HasPrefix(str, "{") {
+			m := g.M()
+			if err = g.Unmarshal(str, &m); err != nil {
+				return nil, g.Error(err, "could not parse url_params JSON")
+			}
+			return ParseURLParams(m)
+		}
+
+		values, err := url.ParseQuery(strings.TrimPrefix(str, "?"))
+		if err != nil {
+			return nil, g.Error(err, "could not parse url_params query string")
+		}
+		for k := range values {
+			params[k] = values.Get(k)
+		}
+	}
+	return params, nil
+}
+
+// AppendURLParams sets the params in the query string of the URL,
+// leaving the rest of the URL (including credentials) untouched
+func AppendURLParams(urlStr string, params map[string]string) string {
+	if len(params) == 0 {
+		return urlStr
+	}
+
+	base, rawQuery, _ := strings.Cut(urlStr, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		g.Warn("could not parse url query string to append url_params")
+		return urlStr
+	}
+
+	for k, v := range params {
+		query.Set(k, v)
+	}
+
+	return base + "?" + query.Encode()
+}
+
 // CopyDirect copies directly from cloud files
 // (without passing through dbio)
 func CopyDirect(conn database.Connection, tableFName string, srcFile Connection) (cnt uint64, ok bool, err error) {
@@ -2,40 +2,60 @@ package connection
 
 import (
 	"context"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/flarco/g"
 	"github.com/gobwas/glob"
 	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio"
 	"github.com/slingdata-io/sling-cli/core/dbio/database"
 	"github.com/slingdata-io/sling-cli/core/dbio/filesys"
 	"github.com/slingdata-io/sling-cli/core/dbio/iop"
 )
 
-func (c *Connection) Test() (ok bool, err error) {
+// TestResult is the result of a connection test
+type TestResult struct {
+	Latency time.Duration // duration to connect
+	database.ServerInfo
+}
+
+// Test connects to the connection. For databases, the server version and
+// the effective user / role are returned as well.
+func (c *Connection) Test() (result TestResult, err error) {
+
+	start := time.Now()
 
 	switch {
 	case c.Type.IsDb():
 		dbConn, err := c.AsDatabase()
 		if err != nil {
-			return ok, g.Error(err, "could not initiate %s", c.Name)
+			return result, g.Error(err, "could not initiate %s", c.Name)
 		}
 		err = dbConn.Connect(10)
 		if err != nil {
-			return ok, g.Error(err, "could not connect to %s", c.Name)
+			return result, g.Error(err, "could not connect to %s", c.Name)
+		}
+		result.Latency = time.Since(start)
+
+		// informative only, not failing the test
+		if result.ServerInfo, err = database.GetServerInfo(dbConn); err != nil {
+			g.Debug("could not get server info of %s: %s", c.Name, err.Error())
 		}
 	case c.Type.IsFile():
 		fileClient, err := c.AsFile()
 		if err != nil {
-			return ok, g.Error(err, "could not initiate %s", c.Name)
+			return result, g.Error(err, "could not initiate %s", c.Name)
 		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 25*time.Second)
 		defer cancel()
 		err = fileClient.Init(ctx)
 		if err != nil {
-			return ok, g.Error(err, "could not connect to %s", c.Name)
+			return result, g.Error(err, "could not connect to %s", c.Name)
 		}
 
 		url := c.URL()
@@ -43,25 +63,109 @@ func (c *Connection) Test() (ok bool, err error) {
 		g.Debug("file test inputs: %s", g.Marshal(g.M("url", url)))
 		nodes, err := fileClient.List(url)
 		if err != nil {
-			return ok, g.Error(err, "could not connect to %s", c.Name)
+			return result, g.Error(err, "could not connect to %s", c.Name)
 		}
+		result.Latency = time.Since(start)
 		g.Debug("unfiltered nodes returned: %d", len(nodes))
 		if len(nodes) <= 10 {
 			g.Debug(g.Marshal(nodes.Paths()))
 		}
 	}
 
-	return true, nil
+	return result, nil
 }
 
 type DiscoverOptions struct {
 	Pattern   string                 `json:"pattern,omitempty"`
+	Regex     string                 `json:"regex,omitempty"` // filter stream names with a regular expression, instead of a glob pattern
 	Level     database.SchemataLevel `json:"level,omitempty"`
 	Recursive bool                   `json:"recursive,omitempty"`
+	Stats     bool                   `json:"stats,omitempty"` // sample file columns with the sample size, for type inference stats
+
+	// number of streams whose columns are discovered at a time (database tables are
+	// queried one by one when set), bounded by the connection concurrency
+	Concurrency int `json:"concurrency,omitempty"`
+	// maximum duration to discover the columns of a single stream, which is skipped after
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// the object types of database streams (table, view, materialized_view, external),
+	// or the format groups of files (e.g. csv, parquet, json)
+	Types []string `json:"types,omitempty"`
+}
+
+// discoverFileTypes are the file format groups, to filter discovered files by extension
+var discoverFileTypes = map[string][]dbio.FileType{
+	"csv":       {dbio.FileTypeCsv},
+	"json":      {dbio.FileTypeJson, dbio.FileTypeJsonLines},
+	"jsonlines": {dbio.FileTypeJsonLines},
+	"xml":       {dbio.FileTypeXml},
+	"parquet":   {dbio.FileTypeParquet},
+	"avro":      {dbio.FileTypeAvro},
+	"orc":       {dbio.FileTypeOrc},
+	"sas":       {dbio.FileTypeSAS},
+	"excel":     {dbio.FileTypeExcel},
+	"xlsx":      {dbio.FileTypeExcel},
+}
+
+// parseDiscoverTypes returns the object types (databases) or the file formats
+// (files) to discover, validated against the connection type
+func parseDiscoverTypes(connType dbio.Type, types []string) (tableTypes []database.TableType, fileTypes []dbio.FileType, err error) {
+	for _, typ := range types {
+		typ = strings.ToLower(strings.TrimSpace(typ))
+		if typ == "" {
+			continue
+		}
+
+		if connType.IsFile() {
+			groupTypes, ok := discoverFileTypes[typ]
+			if !ok {
+				valid := lo.Keys(discoverFileTypes)
+				sort.Strings(valid)
+				return nil, nil, g.Error("invalid file type %#v to discover, valid types are: %s", typ, strings.Join(valid, ", "))
+			}
+			fileTypes = append(fileTypes, groupTypes...)
+			continue
+		}
+
+		tableType := database.TableType(strings.ReplaceAll(typ, " ", "_"))
+		if !g.In(tableType, database.TableTypes...) {
+			return nil, nil, g.Error("invalid object type %#v to discover, valid types are: %s", typ, g.Marshal(database.TableTypes))
+		} else if g.In(tableType, database.TableTypeMaterializedView, database.TableTypeExternal) && connType != dbio.TypeDbPostgres {
+			// the other dialects list these as tables or views
+			return nil, nil, g.Error("discovering %s objects is only supported for postgres, not %s", tableType, connType)
+		}
+		tableTypes = append(tableTypes, tableType)
+	}
+
+	return lo.Uniq(tableTypes), lo.Uniq(fileTypes), nil
+}
+
+// CompileDiscoverRegex compiles the regular expression filtering stream names.
+// It is anchored to match whole names, case-insensitively.
+func CompileDiscoverRegex(expr string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(`(?i)^(?:` + expr + `)$`)
+	if err != nil {
+		return nil, g.Error(err, "invalid regex: %s", expr)
+	}
+	return re, nil
 }
 
 func (c *Connection) Discover(opt *DiscoverOptions) (ok bool, nodes filesys.FileNodes, schemata database.Schemata, err error) {
 
+	var regex *regexp.Regexp
+	if opt.Regex != "" {
+		if opt.Pattern != "" {
+			return ok, nodes, schemata, g.Error("cannot use both pattern and regex to discover, please provide one")
+		} else if regex, err = CompileDiscoverRegex(opt.Regex); err != nil {
+			return ok, nodes, schemata, err
+		}
+	}
+
+	tableTypes, fileTypes, err := parseDiscoverTypes(c.Type, opt.Types)
+	if err != nil {
+		return ok, nodes, schemata, err
+	}
+
 	patterns := []string{}
 	globPatterns := []glob.Glob{}
 
@@ -100,7 +204,9 @@ func (c *Connection) Discover(opt *DiscoverOptions) (ok bool, nodes filesys.File
 
 		var table database.Table
 		level := database.SchemataLevelSchema
-		if opt.Pattern != "" {
+		if regex != nil || len(tableTypes) > 0 {
+			level = database.SchemataLevelTable
+		} else if opt.Pattern != "" {
 			level = database.SchemataLevelTable
 			table, _ = database.ParseTableName(opt.Pattern, c.Type)
 			if strings.Contains(table.Schema, "*") || strings.Contains(table.Schema, "?") {
@@ -117,7 +223,15 @@ func (c *Connection) Discover(opt *DiscoverOptions) (ok bool, nodes filesys.File
 
 		g.Debug("database discover inputs: %s", g.Marshal(g.M("pattern", opt.Pattern, "schema", table.Schema, "table", table.Name, "level", opt.Level)))
 
-		schemata, err = dbConn.GetSchemata(opt.Level, table.Schema, table.Name)
+		if opt.Level == database.SchemataLevelColumn && (opt.Concurrency > 1 || opt.Timeout > 0) {
+			// list the tables, then query the columns of each table
+			schemata, err = dbConn.GetSchemata(database.SchemataLevelTable, table.Schema, table.Name)
+			if err == nil {
+				schemata, err = discoverColumns(dbConn, schemata, opt)
+			}
+		} else {
+			schemata, err = dbConn.GetSchemata(opt.Level, table.Schema, table.Name)
+		}
 		if err != nil {
 			return ok, nodes, schemata, g.Error(err, "could not discover %s", c.Name)
 		}
@@ -135,9 +249,14 @@ func (c *Connection) Discover(opt *DiscoverOptions) (ok bool, nodes filesys.File
 		}
 
 		// apply filter if table is not specified
-		if len(patterns) > 0 && table.Name == "" {
+		if regex != nil {
+			schemata = schemata.FilteredRegex(opt.Level == database.SchemataLevelColumn, regex)
+		} else if len(patterns) > 0 && table.Name == "" {
 			schemata = schemata.Filtered(opt.Level == database.SchemataLevelColumn, patterns...)
 		}
+		if len(tableTypes) > 0 {
+			schemata = schemata.FilteredTypes(tableTypes...)
+		}
 
 	case c.Type.IsFile():
 		fileClient, err := c.AsFile()
@@ -182,6 +301,17 @@ func (c *Connection) Discover(opt *DiscoverOptions) (ok bool, nodes filesys.File
 		// sort alphabetically
 		nodes.Sort()
 		nodes = lo.Filter(nodes, func(n filesys.FileNode, i int) bool {
+			if len(fileTypes) > 0 {
+				// folders have no format
+				if n.IsDir || !g.In(filesys.InferFileFormat(n.Path(), dbio.FileTypeNone), fileTypes...) {
+					return false
+				}
+			}
+			if regex != nil {
+				// match the full path, or the file / folder name
+				nodePath := strings.TrimSuffix(n.Path(), "/")
+				return regex.MatchString(nodePath) || regex.MatchString(filepath.Base(nodePath))
+			}
 			if len(patterns) == 0 || !(strings.Contains(opt.Pattern, "*") || strings.Contains(opt.Pattern, "?")) {
 				return true
 			}
@@ -194,27 +324,45 @@ func (c *Connection) Discover(opt *DiscoverOptions) (ok bool, nodes filesys.File
 		})
 
 		// if single file, get columns of file content
-		if opt.Level == database.SchemataLevelColumn {
-			ctx := g.NewContext(fileClient.Context().Ctx, 5)
+		if opt.Level == database.SchemataLevelColumn || opt.Stats {
+			ctx := g.NewContext(fileClient.Context().Ctx, lo.Ternary(opt.Concurrency > 0, opt.Concurrency, 5))
 
 			getColumns := func(i int) {
 				defer ctx.Wg.Read.Done()
 				node := nodes[i]
 
-				df, err := fileClient.ReadDataflow(node.URI, iop.FileStreamConfig{Limit: 100})
-				if err != nil {
-					ctx.CaptureErr(g.Error(err, "could not read file content of %s", node.URI))
-					return
-				}
+				var df *iop.Dataflow
+				err := withStreamTimeout(ctx.Ctx, opt.Timeout, func(timeoutCtx context.Context) (err error) {
+					limit := lo.Ternary(opt.Stats, iop.SampleSize, 100)
+					df, err = fileClient.ReadDataflow(node.URI, iop.FileStreamConfig{Limit: limit})
+					if err != nil {
+						return err
+					}
+
+					// stop reading the file on timeout
+					stop := context.AfterFunc(timeoutCtx, df.Context.Cancel)
+					defer stop()
 
-				// discard rows, just need columns
-				for stream := range df.StreamCh {
-					for range stream.Rows() {
+					// discard rows, just need columns
+					for stream := range df.StreamCh {
+						for range stream.Rows() {
+						}
 					}
+					return nil
+				})
+				if err == errStreamTimeout {
+					g.Warn("skipping columns of %s, not read after %s", node.URI, opt.Timeout)
+					return
+				} else if err != nil {
+					ctx.CaptureErr(g.Error(err, "could not read file content of %s", node.URI))
+					return
 				}
 
 				// get columns
 				nodes[i].Columns = df.Columns
+				if opt.Stats {
+					nodes[i].Stats = df.Columns.SampleStats()
+				}
 			}
 
 			for i := range nodes {
@@ -241,3 +389,105 @@ func (c *Connection) Discover(opt *DiscoverOptions) (ok bool, nodes filesys.File
 
 	return
 }
+
+// errStreamTimeout is returned when discovering a stream takes longer than the timeout
+var errStreamTimeout = g.Error("stream discovery timed out")
+
+// withStreamTimeout runs f, returning errStreamTimeout if it does not complete
+// within the timeout (no timeout if zero). f receives a context cancelled on
+// timeout, which it must pass to its queries / reads so they are stopped.
+func withStreamTimeout(parent context.Context, timeout time.Duration, f func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return f(parent)
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- f(ctx) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if parent.Err() != nil {
+			return parent.Err()
+		}
+		return errStreamTimeout
+	}
+}
+
+// discoverColumns queries the columns of each table of the schemata, with
+// opt.Concurrency tables at a time (bounded by the connection concurrency).
+// Tables failing or timing out are kept without columns, with a warning.
+func discoverColumns(dbConn database.Connection, schemata database.Schemata, opt *DiscoverOptions) (database.Schemata, error) {
+	type tableRef struct{ db, schema, table string }
+
+	// sort for a stable order of queries & warnings
+	refs := []tableRef{}
+	for dbKey, db := range schemata.Databases {
+		for schemaKey, schema := range db.Schemas {
+			for tableKey := range schema.Tables {
+				refs = append(refs, tableRef{dbKey, schemaKey, tableKey})
+			}
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return g.F("%s.%s.%s", refs[i].db, refs[i].schema, refs[i].table) < g.F("%s.%s.%s", refs[j].db, refs[j].schema, refs[j].table)
+	})
+
+	concurrency := lo.Ternary(opt.Concurrency > 0, opt.Concurrency, 1)
+	if limit := dbConn.Context().Wg.Limit; limit > 0 && concurrency > limit {
+		g.Debug("limiting discovery concurrency to %d (connection concurrency)", limit)
+		concurrency = limit
+	}
+
+	g.Debug("discovering columns of %d tables (concurrency: %d)", len(refs), concurrency)
+
+	results := make([]iop.Columns, len(refs))
+	ctx := g.NewContext(dbConn.Context().Ctx, concurrency)
+	for i, ref := range refs {
+		table := schemata.Databases[ref.db].Schemas[ref.schema].Tables[ref.table]
+
+		ctx.Wg.Read.Add()
+		go func(i int, table database.Table) {
+			defer ctx.Wg.Read.Done()
+
+			var columns iop.Columns
+			err := withStreamTimeout(ctx.Ctx, opt.Timeout, func(timeoutCtx context.Context) (err error) {
+				columns, err = dbConn.GetColumnsContext(timeoutCtx, table.FullName())
+				return err
+			})
+			if err == errStreamTimeout {
+				g.Warn("skipping columns of %s, not returned after %s", table.FullName(), opt.Timeout)
+				return
+			} else if err != nil {
+				g.Warn("could not get columns of %s: %s", table.FullName(), err.Error())
+				return
+			}
+
+			for j := range columns {
+				columns[j].Table = table.Name
+				columns[j].Schema = table.Schema
+				columns[j].Database = table.Database
+			}
+			results[i] = columns
+		}(i, table)
+	}
+	ctx.Wg.Read.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return schemata, g.Error(err, "could not discover columns")
+	}
+
+	// aggregate in order, maps hold values
+	for i, ref := range refs {
+		schema := schemata.Databases[ref.db].Schemas[ref.schema]
+		table := schema.Tables[ref.table]
+		table.Columns = lo.Ternary(results[i] != nil, results[i], iop.Columns{})
+		schema.Tables[ref.table] = table
+	}
+
+	return schemata, nil
+}
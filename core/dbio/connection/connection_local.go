@@ -3,6 +3,7 @@ package connection
 import (
 	"encoding/base64"
 	"encoding/json"
+	"net/url"
 	"os"
 	"sort"
 	"strings"
@@ -37,9 +38,16 @@ func (ce ConnEntries) Get(name string) ConnEntry {
 }
 
 func (ce ConnEntries) List() (fields []string, rows [][]any) {
-	fields = []string{"Conn Name", "Conn Type", "Source"}
+	fields = []string{"Conn Name", "Conn Type", "Source", "Default Options"}
 	for _, conn := range ce {
-		rows = append(rows, []any{conn.Name, conn.Description, conn.Source})
+		defaults := []string{}
+		if _, ok := conn.Connection.Data[DefaultSourceOptionsKey]; ok {
+			defaults = append(defaults, "source")
+		}
+		if _, ok := conn.Connection.Data[DefaultTargetOptionsKey]; ok {
+			defaults = append(defaults, "target")
+		}
+		rows = append(rows, []any{conn.Name, conn.Description, conn.Source, strings.Join(defaults, ", ")})
 	}
 	return fields, rows
 }
@@ -55,13 +63,13 @@ func (ce ConnEntries) Discover(name string, opt *DiscoverOptions) (nodes filesys
 	return
 }
 
-func (ce ConnEntries) Test(name string) (ok bool, err error) {
+func (ce ConnEntries) Test(name string) (result TestResult, err error) {
 	conn := ce.Get(name)
 	if conn.Name == "" {
-		return ok, g.Error("Invalid Connection name: %s. Make sure it is created. See https://docs.slingdata.io/sling-cli/environment", name)
+		return result, g.Error("Invalid Connection name: %s. Make sure it is created. See https://docs.slingdata.io/sling-cli/environment", name)
 	}
 	defer conn.Connection.Close()
-	ok, err = conn.Connection.Test()
+	result, err = conn.Connection.Test()
 	return
 }
 
@@ -126,6 +134,24 @@ func GetLocalConns(force ...bool) ConnEntries {
 
 	}
 
+	// project file of the working directory, overrides the global env files
+	if env.Project != nil {
+		m := g.M()
+		g.JSONConvert(g.M("connections", env.Project.Connections), &m)
+		projectConns, err := ReadConnections(m)
+		if !g.LogError(err) {
+			for _, conn := range projectConns {
+				c := ConnEntry{
+					Name:        strings.ToUpper(conn.Info().Name),
+					Description: conn.Type.NameLong(),
+					Source:      "project file",
+					Connection:  conn,
+				}
+				connsMap[c.Name] = c
+			}
+		}
+	}
+
 	// env.yaml as an Environment variable
 	if content := os.Getenv("ENV_YAML"); content != "" {
 		ef, err := env.LoadSlingEnvFileBody(content)
@@ -150,7 +176,7 @@ func GetLocalConns(force ...bool) ConnEntries {
 	}
 
 	// Environment variables
-	for key, val := range g.KVArrToMap(os.Environ()...) {
+	for key, val := range env.Environ() {
 		var conn Connection
 
 		// try to decode base64
@@ -262,6 +288,17 @@ func (ec *EnvFileConns) Set(name string, kvMap map[string]any) (err error) {
 		return g.Error("need to specify valid `type` key or provide `url`.")
 	}
 
+	// store default options as maps
+	for _, key := range []string{DefaultSourceOptionsKey, DefaultTargetOptionsKey} {
+		if val, ok := kvMap[key]; ok {
+			options, err := parseOptionsMap(val)
+			if err != nil {
+				return g.Error(err, "invalid value for %s", key)
+			}
+			kvMap[key] = options
+		}
+	}
+
 	ef := ec.EnvFile
 	ef.Connections[name] = kvMap
 	err = ef.WriteEnvFile()
@@ -335,3 +372,140 @@ func (ec *EnvFileConns) GetConnEntry(name string) (conn ConnEntry, ok bool) {
 	conn = entries.Get(name)
 	return conn, conn.Name != ""
 }
+
+// Export returns the env file connections, to migrate to another machine.
+// Secret values are masked unless withSecrets is true.
+func (ec *EnvFileConns) Export(withSecrets bool) (conns map[string]map[string]any) {
+	conns = map[string]map[string]any{}
+	for name, props := range ec.EnvFile.Connections {
+		if withSecrets {
+			conns[name] = props
+		} else {
+			conns[name] = RedactProps(props)
+		}
+	}
+	return conns
+}
+
+// ImportStrategy is how imported connections are combined with the existing ones
+type ImportStrategy string
+
+const (
+	// ImportMerge adds the new connections, keeping existing ones on conflict
+	ImportMerge ImportStrategy = "merge"
+	// ImportReplace overwrites the existing connections with the same names,
+	// the other connections are kept
+	ImportReplace ImportStrategy = "replace"
+)
+
+// ImportResult lists the connection names per outcome
+type ImportResult struct {
+	Added     []string `json:"added"`
+	Updated   []string `json:"updated"`   // replaced connections with different properties
+	Unchanged []string `json:"unchanged"` // identical connections
+	Conflicts []string `json:"conflicts"` // existing connections with different properties, not imported
+}
+
+// ReadExportFile reads the connections of an export file (same format as env.yaml)
+func ReadExportFile(path string) (conns map[string]map[string]any, err error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, g.Error(err, "could not read %s", path)
+	}
+
+	ef := env.EnvFile{}
+	if err = yaml.Unmarshal(bytes, &ef); err != nil {
+		return nil, g.Error(err, "could not parse %s", path)
+	}
+
+	return ef.Connections, nil
+}
+
+// isMaskedValue returns true if the value is (or is a url holding) a secret
+// masked on export, also url-encoded (`%2A%2A%2A`)
+func isMaskedValue(value string) bool {
+	if decoded, err := url.QueryUnescape(value); err == nil {
+		value = decoded
+	}
+	return value == "***" || strings.Contains(value, ":***@") || strings.Contains(value, "=***")
+}
+
+// Import validates and imports connections into the env file. With ImportMerge,
+// existing connections with different properties are reported as conflicts
+// and kept as-is, while ImportReplace overwrites them. Connections absent
+// from the import are never removed.
+func (ec *EnvFileConns) Import(conns map[string]map[string]any, strategy ImportStrategy) (result ImportResult, err error) {
+	if strategy == "" {
+		strategy = ImportMerge
+	} else if !g.In(strategy, ImportMerge, ImportReplace) {
+		return result, g.Error("invalid import strategy: %s (expected merge or replace)", strategy)
+	}
+
+	// validate all first, to not import partially
+	imported := map[string]map[string]any{}
+	for name, props := range conns {
+		name = strings.ToUpper(name)
+		if props == nil {
+			return result, g.Error("connection `%s` has no properties", name)
+		}
+
+		for k, v := range props {
+			if isMaskedValue(cast.ToString(v)) {
+				return result, g.Error("connection `%s` has a masked value for `%s`. Export with --with-secrets, or set the value", name, k)
+			}
+		}
+
+		t, found := props["type"]
+		if _, typeOK := dbio.ValidateType(cast.ToString(t)); found && !typeOK {
+			return result, g.Error("connection `%s` has an invalid type (%s)", name, cast.ToString(t))
+		} else if !found && cast.ToString(props["url"]) == "" {
+			return result, g.Error("connection `%s` needs a valid `type` key or `url`", name)
+		}
+
+		_, err = NewConnectionFromMap(g.M("name", name, "data", props, "type", props["type"]))
+		if err != nil {
+			return result, g.Error(err, "could not parse connection `%s`", name)
+		}
+		imported[name] = props
+	}
+
+	ef := ec.EnvFile
+	if ef.Connections == nil {
+		ef.Connections = map[string]map[string]any{}
+	}
+
+	existing := map[string]string{} // upper name => env file key
+	for key := range ef.Connections {
+		existing[strings.ToUpper(key)] = key
+	}
+
+	for name, props := range imported {
+		key, found := existing[name]
+		switch {
+		case !found:
+			ef.Connections[name] = props
+			result.Added = append(result.Added, name)
+		case g.Marshal(ef.Connections[key]) == g.Marshal(props):
+			result.Unchanged = append(result.Unchanged, key)
+		case strategy == ImportReplace:
+			ef.Connections[key] = props
+			result.Updated = append(result.Updated, key)
+		default:
+			result.Conflicts = append(result.Conflicts, key)
+		}
+	}
+
+	for _, names := range [][]string{result.Added, result.Updated, result.Unchanged, result.Conflicts} {
+		sort.Strings(names)
+	}
+
+	if len(result.Added)+len(result.Updated) == 0 {
+		return result, nil // nothing to write
+	}
+
+	if err = ef.WriteEnvFile(); err != nil {
+		return result, g.Error(err, "could not write env file")
+	}
+
+	return result, nil
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/cast"
+)
+
+// cursor is a server-side cursor, declared in a read-only transaction,
+// so that the rows of a large result are fetched in batches of `fetch_size`
+// instead of being buffered by the client
+type cursor struct {
+	tx        *sqlx.Tx
+	name      string
+	fetchSQL  string
+	fetchSize int
+	fetched   int // rows read from the current batch
+	batches   int // batches fetched
+}
+
+// cursorFetchSize returns the `fetch_size` to stream the query with a server-side
+// cursor, or 0 if not set, not supported by the database or within a transaction
+func (conn *BaseConn) cursorFetchSize(query string) int {
+	fetchSize := cast.ToInt(conn.GetProp("fetch_size"))
+	if fetchSize <= 0 || conn.tx != nil || strings.Contains(query, noDebugKey) {
+		return 0
+	} else if conn.GetTemplateValue("core.cursor_declare") == "" {
+		return 0
+	}
+
+	// only queries returning rows can be declared as a cursor
+	statement := strings.ToLower(strings.TrimSpace(query))
+	if !strings.HasPrefix(statement, "select") && !strings.HasPrefix(statement, "with") {
+		return 0
+	}
+	return fetchSize
+}
+
+// openCursor begins a read-only transaction, declares the cursor for the query
+// and fetches the first batch
+func (conn *BaseConn) openCursor(ctx context.Context, query string, fetchSize int) (cur *cursor, result *sqlx.Rows, err error) {
+	cur = &cursor{name: "sling_cursor" + g.RandSuffix("_", 3), fetchSize: fetchSize}
+	cur.fetchSQL = g.R(
+		conn.GetTemplateValue("core.cursor_fetch"),
+		"cursor", cur.name,
+		"fetch_size", cast.ToString(fetchSize),
+	)
+
+	cur.tx, err = conn.db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, nil, g.Error(err, "could not begin transaction for cursor")
+	}
+
+	declareSQL := g.R(
+		conn.GetTemplateValue("core.cursor_declare"),
+		"cursor", cur.name,
+		"sql", strings.TrimSuffix(strings.TrimSpace(query), ";"),
+	)
+	if _, err = cur.tx.ExecContext(ctx, declareSQL); err != nil {
+		cur.close()
+		return nil, nil, g.Error(err, "could not declare cursor")
+	}
+
+	if result, err = cur.fetch(ctx); err != nil {
+		cur.close()
+		return nil, nil, err
+	}
+
+	g.Debug("streaming with cursor %s, fetching %d rows per batch", cur.name, fetchSize)
+	return cur, result, nil
+}
+
+// fetch fetches the next batch of rows from the cursor
+func (c *cursor) fetch(ctx context.Context) (result *sqlx.Rows, err error) {
+	result, err = c.tx.QueryxContext(ctx, c.fetchSQL)
+	if err != nil {
+		return nil, g.Error(err, "could not fetch from cursor")
+	}
+	c.fetched = 0
+	c.batches++
+	return result, nil
+}
+
+// full returns true if the current batch was full, more rows may remain
+func (c *cursor) full() bool {
+	return c.fetched >= c.fetchSize
+}
+
+// close ends the read-only transaction, which closes the cursor
+func (c *cursor) close() {
+	if c.tx == nil {
+		return
+	}
+	if err := c.tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		g.Warn("could not close cursor %s: %s", c.name, err.Error())
+		return
+	}
+	g.Trace("closed cursor %s after %d batches", c.name, c.batches)
+}
@@ -82,11 +82,13 @@ type Connection interface {
 	ExecMultiContext(ctx context.Context, sqls ...string) (result sql.Result, err error)
 	GenerateDDL(table Table, data iop.Dataset, temporary bool) (string, error)
 	GenerateInsertStatement(tableName string, cols iop.Columns, numRows int) string
-	GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string) (sql string, err error)
+	GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string, options ...UpsertOptions) (sql string, err error)
 	GetAnalysis(string, map[string]interface{}) (string, error)
 	GetColumns(tableFName string, fields ...string) (iop.Columns, error)
+	GetColumnsContext(ctx context.Context, tableFName string, fields ...string) (iop.Columns, error)
 	GetColumnsFull(string) (iop.Dataset, error)
 	GetColumnStats(tableName string, fields ...string) (columns iop.Columns, err error)
+	GetComments(string) (TableComments, error)
 	GetCount(string) (uint64, error)
 	GetDatabases() (iop.Dataset, error)
 	GetDDL(string) (string, error)
@@ -131,11 +133,12 @@ type Connection interface {
 	StreamRows(sql string, options ...map[string]interface{}) (*iop.Datastream, error)
 	StreamRowsContext(ctx context.Context, sql string, options ...map[string]interface{}) (ds *iop.Datastream, err error)
 	SubmitTemplate(level string, templateMap map[string]string, name string, values map[string]interface{}) (data iop.Dataset, err error)
+	SubmitTemplateContext(ctx context.Context, level string, templateMap map[string]string, name string, values map[string]interface{}) (data iop.Dataset, err error)
 	SwapTable(srcTable string, tgtTable string) (err error)
 	Template() dbio.Template
 	Tx() Transaction
 	Unquote(string) string
-	Upsert(srcTable string, tgtTable string, pkFields []string) (rowAffCnt int64, err error)
+	Upsert(srcTable string, tgtTable string, pkFields []string, options ...UpsertOptions) (rowAffCnt int64, err error)
 	ValidateColumnNames(tgtCols iop.Columns, colNames []string, quote bool) (newCols iop.Columns, err error)
 	AddMissingColumns(table Table, newCols iop.Columns) (ok bool, err error)
 }
@@ -272,6 +275,14 @@ func NewConnContext(ctx context.Context, URL string, props ...string) (Connectio
 		conn = &MongoDBConn{URL: URL}
 	} else if strings.HasPrefix(URL, "prometheus") {
 		conn = &PrometheusConn{URL: URL}
+	} else if strings.HasPrefix(URL, "pubsub:") {
+		conn = &PubSubConn{URL: URL}
+	} else if strings.HasPrefix(URL, "influxdb:") {
+		conn = &InfluxDBConn{URL: URL}
+	} else if strings.HasPrefix(URL, "firestore:") {
+		conn = &FirestoreConn{URL: URL}
+	} else if strings.HasPrefix(URL, "cassandra:") || strings.HasPrefix(URL, "scylladb:") {
+		conn = &CassandraConn{URL: URL}
 	} else if strings.HasPrefix(URL, "mariadb:") {
 		conn = &MySQLConn{URL: URL}
 	} else if strings.HasPrefix(URL, "oracle:") {
@@ -568,8 +579,6 @@ func (conn *BaseConn) Kill() error {
 
 // Connect connects to the database
 func (conn *BaseConn) Connect(timeOut ...int) (err error) {
-	var tryNum int
-
 	to := 15
 	if len(timeOut) > 0 && timeOut[0] != 0 {
 		to = timeOut[0]
@@ -584,6 +593,35 @@ func (conn *BaseConn) Connect(timeOut ...int) (err error) {
 
 	connURL := conn.Self().ConnString()
 
+	// try the hosts of the `hosts` prop (read replicas / failover)
+	hosts, err := conn.Hosts()
+	if err != nil {
+		return err
+	} else if len(hosts) > 0 && conn.db == nil {
+		host, err := connectHosts(hosts, func(host string) error {
+			hostURL, err := replaceURLHost(connURL, host)
+			if err != nil {
+				return err
+			}
+			if err = conn.connect(hostURL, to); err != nil {
+				conn.closeDb()
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		conn.SetProp("connected_host", host)
+		return nil
+	}
+
+	return conn.connect(connURL, to)
+}
+
+// connect opens the database at the connection URL, and pings it
+func (conn *BaseConn) connect(connURL string, to int) (err error) {
+	var tryNum int
+
 	// start SSH Tunnel with SSH_TUNNEL prop
 	if sshURL := conn.GetProp("SSH_TUNNEL"); sshURL != "" {
 
@@ -692,6 +730,88 @@ func (conn *BaseConn) Connect(timeOut ...int) (err error) {
 	return nil
 }
 
+// Hosts returns the hosts to try in order, from the `hosts` prop (a comma-separated
+// list or a list) and `host_selection`: `failover` tries the hosts in order,
+// `random` in a random order, and `primary` only the first one. The host of the
+// connection, if not listed, is tried first.
+func (conn *BaseConn) Hosts() (hosts []string, err error) {
+	hostsVal := strings.TrimSpace(conn.GetProp("hosts"))
+	if hostsVal == "" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(hostsVal, "[") {
+		if err = g.Unmarshal(hostsVal, &hosts); err != nil {
+			return nil, g.Error(err, "invalid value for hosts: %s", hostsVal)
+		}
+	} else {
+		hosts = strings.Split(hostsVal, ",")
+	}
+
+	hosts = lo.Uniq(lo.Compact(lo.Map(hosts, func(h string, i int) string { return strings.TrimSpace(h) })))
+	host := conn.GetProp("host")
+	if u, err := url.Parse(conn.Self().ConnString()); host == "" && err == nil {
+		host = u.Hostname()
+	}
+	if host != "" && !lo.Contains(hosts, host) {
+		hosts = append([]string{host}, hosts...)
+	}
+
+	switch selection := strings.ToLower(conn.GetProp("host_selection")); selection {
+	case "", "failover":
+	case "random":
+		hosts = lo.Shuffle(hosts)
+	case "primary":
+		hosts = hosts[:1]
+	default:
+		return nil, g.Error("invalid value for host_selection: %s. Valid values are: failover, random, primary", selection)
+	}
+
+	return hosts, nil
+}
+
+// connectHosts connects to the first reachable host, in order
+func connectHosts(hosts []string, connect func(host string) error) (host string, err error) {
+	errors := []string{}
+	for i, host := range hosts {
+		if err = connect(host); err == nil {
+			if i > 0 {
+				g.Warn("connected to failover host %s", host)
+			}
+			return host, nil
+		}
+		g.Debug("could not connect to host %s: %s", host, err.Error())
+		errors = append(errors, g.F("%s: %s", host, err.Error()))
+	}
+	return "", g.Error("could not connect to any of the hosts:\n%s", strings.Join(errors, "\n"))
+}
+
+// replaceURLHost returns the connection URL with the host replaced. The port
+// of the URL is kept, unless the host has one (`host:port`).
+func replaceURLHost(connURL, host string) (string, error) {
+	u, err := url.Parse(connURL)
+	if err != nil {
+		return "", g.Error(err, "could not parse connection URL to set the host %s", host)
+	} else if u.Host == "" {
+		return "", g.Error("connection URL has no host to replace with %s", host)
+	}
+
+	if !strings.Contains(host, ":") && u.Port() != "" {
+		host = host + ":" + u.Port()
+	}
+	u.Host = host
+
+	return u.String(), nil
+}
+
+// closeDb closes the database of a failed connection attempt
+func (conn *BaseConn) closeDb() {
+	if conn.db != nil {
+		conn.db.Close()
+		conn.db = nil
+	}
+}
+
 func reconnectIfClosed(conn Connection) (err error) {
 	// g.Warn("connected => %s", conn.GetProp("connected"))
 	if conn.GetProp("connected") != "true" {
@@ -810,7 +930,10 @@ func (conn *BaseConn) StreamRowsContext(ctx context.Context, query string, optio
 
 	conn.LogSQL(query)
 	var result *sqlx.Rows
-	if conn.tx != nil {
+	var cur *cursor
+	if fetchSize := conn.cursorFetchSize(query); fetchSize > 0 {
+		cur, result, err = conn.openCursor(queryContext.Ctx, query, fetchSize)
+	} else if conn.tx != nil {
 		result, err = conn.tx.QueryContext(queryContext.Ctx, query)
 	} else {
 		result, err = conn.db.QueryxContext(queryContext.Ctx, query)
@@ -889,7 +1012,21 @@ func (conn *BaseConn) StreamRowsContext(ctx context.Context, query string, optio
 		}
 
 		next := result.Next()
+		if !next && cur != nil && cur.full() && result.Err() == nil {
+			// fetch the next batch from the cursor
+			result.Close()
+			if result, err = cur.fetch(queryContext.Ctx); err != nil {
+				it.Context.CaptureErr(err)
+				return false
+			}
+			next = result.Next()
+		}
+
 		if next {
+			if cur != nil {
+				cur.fetched++
+			}
+
 			// add row
 			it.Row, err = result.SliceScan()
 			if err != nil {
@@ -912,6 +1049,9 @@ func (conn *BaseConn) StreamRowsContext(ctx context.Context, query string, optio
 	}
 
 	ds = iop.NewDatastreamIt(queryContext.Ctx, conn.Data.Columns, nextFunc)
+	if cur != nil {
+		ds.Defer(cur.close)
+	}
 	ds.NoDebug = strings.Contains(query, noDebugKey)
 	ds.Inferred = !InferDBStream && ds.Columns.Sourced()
 	if !ds.NoDebug {
@@ -1232,6 +1372,11 @@ func SplitTableFullName(tableName string) (string, string) {
 }
 
 func (conn *BaseConn) SubmitTemplate(level string, templateMap map[string]string, name string, values map[string]interface{}) (data iop.Dataset, err error) {
+	return conn.Self().SubmitTemplateContext(conn.Context().Ctx, level, templateMap, name, values)
+}
+
+// SubmitTemplateContext submits a template query with ctx
+func (conn *BaseConn) SubmitTemplateContext(ctx context.Context, level string, templateMap map[string]string, name string, values map[string]interface{}) (data iop.Dataset, err error) {
 	template, ok := templateMap[name]
 	if !ok {
 		err = g.Error("Could not find template %s", name)
@@ -1244,7 +1389,7 @@ func (conn *BaseConn) SubmitTemplate(level string, templateMap map[string]string
 		err = g.Error(err, "error processing template")
 		return
 	}
-	return conn.Self().Query(sql)
+	return conn.Self().QueryContext(ctx, sql)
 }
 
 // GetCount returns count of records
@@ -1472,13 +1617,19 @@ func TableExists(conn Connection, tableFName string) (exists bool, err error) {
 // include schema and table, example: `schema1.table2`
 // fields should be `column_name|data_type`
 func (conn *BaseConn) GetTableColumns(table *Table, fields ...string) (columns iop.Columns, err error) {
+	return conn.getTableColumns(conn.Context().Ctx, table, "columns", fields...)
+}
+
+// getTableColumns returns the columns of the table, with the metadata template
+// `templateName` (e.g. `columns`)
+func (conn *BaseConn) getTableColumns(ctx context.Context, table *Table, templateName string, fields ...string) (columns iop.Columns, err error) {
 	if table.IsQuery() {
 		return conn.GetSQLColumns(*table)
 	}
 
 	columns = iop.Columns{}
-	colData, err := conn.Self().SubmitTemplate(
-		"single", conn.template.Metadata, "columns",
+	colData, err := conn.Self().SubmitTemplateContext(
+		ctx, "single", conn.template.Metadata, templateName,
 		g.M("schema", table.Schema, "table", table.Name),
 	)
 	if err != nil {
@@ -1564,6 +1715,18 @@ func (conn *BaseConn) GetColumns(tableFName string, fields ...string) (columns i
 	return conn.Self().GetTableColumns(&table, fields...)
 }
 
+// GetColumnsContext returns the columns of the table like GetColumns, with
+// the metadata query cancelled with ctx. Connections not listing their
+// columns with the `columns` template override it, ignoring ctx.
+func (conn *BaseConn) GetColumnsContext(ctx context.Context, tableFName string, fields ...string) (columns iop.Columns, err error) {
+	table, err := ParseTableName(tableFName, conn.Type)
+	if err != nil {
+		return columns, g.Error(err, "could not parse table name: "+tableFName)
+	}
+
+	return conn.getTableColumns(ctx, &table, "columns", fields...)
+}
+
 // GetColumnsFull returns columns for given table. `tableName` should
 // include schema and table, example: `schema1.table2`
 // fields should be `schema_name|table_name|table_type|column_name|data_type|column_id`
@@ -1592,6 +1755,47 @@ func (conn *BaseConn) GetPrimaryKeys(tableFName string) (iop.Dataset, error) {
 	)
 }
 
+// GetComments returns the comments of the given table and of its columns.
+// Comments are empty if the dialect has no `comments` metadata template.
+func (conn *BaseConn) GetComments(tableFName string) (comments TableComments, err error) {
+	table, err := ParseTableName(tableFName, conn.Type)
+	if err != nil {
+		return comments, g.Error(err, "could not parse table name: "+tableFName)
+	}
+
+	if _, ok := conn.template.Metadata["comments"]; !ok {
+		return comments, nil
+	}
+
+	data, err := conn.SubmitTemplate(
+		"single", conn.template.Metadata, "comments",
+		g.M("schema", table.Schema, "table", table.Name),
+	)
+	if err != nil {
+		return comments, g.Error(err, "could not get comments of %s", tableFName)
+	}
+
+	// the table comment has a blank column name
+	comments.Columns = map[string]string{}
+	comments.Definitions = map[string]string{}
+	for _, rec := range data.Records() {
+		columnName := cast.ToString(rec["column_name"])
+		comment := strings.TrimSpace(cast.ToString(rec["comment"]))
+		if definition := cast.ToString(rec["definition"]); columnName != "" && definition != "" {
+			comments.Definitions[columnName] = definition
+		}
+		if comment == "" {
+			continue
+		} else if columnName == "" {
+			comments.Table = comment
+		} else {
+			comments.Columns[columnName] = comment
+		}
+	}
+
+	return comments, nil
+}
+
 // GetIndexes returns indexes for given table.
 func (conn *BaseConn) GetIndexes(tableFName string) (iop.Dataset, error) {
 	table, err := ParseTableName(tableFName, conn.Type)
@@ -1835,6 +2039,7 @@ func (conn *BaseConn) GetSchemata(level SchemataLevel, schemaName string, tableN
 				Schema:   schemaName,
 				Database: currDatabase,
 				IsView:   cast.ToBool(rec["is_view"]),
+				Type:     ParseTableType(rec["table_type"], cast.ToBool(rec["is_view"])),
 				Columns:  iop.Columns{},
 				Dialect:  conn.GetType(),
 			}
@@ -2120,8 +2325,12 @@ func (conn *BaseConn) Unquote(field string) string {
 	return conn.Type.Unquote(field)
 }
 
-// Quote adds quotes to the field name
+// Quote adds quotes to the field name, according to the
+// `quote_identifiers` prop (always, never or when-needed)
 func (conn *BaseConn) Quote(field string, normalize ...bool) string {
+	if mode := conn.GetProp("quote_identifiers"); mode != "" {
+		return conn.Type.QuoteWith(field, dbio.QuoteIdentifiers(mode), normalize...)
+	}
 	return conn.Type.Quote(field, normalize...)
 }
 
@@ -2155,12 +2364,12 @@ func (conn *BaseConn) GenerateInsertStatement(tableName string, cols iop.Columns
 
 // Upsert inserts / updates from a srcTable into a target table.
 // Assuming the srcTable has some or all of the tgtTable fields with matching types
-func (conn *BaseConn) Upsert(srcTable string, tgtTable string, primKeys []string) (rowAffCnt int64, err error) {
+func (conn *BaseConn) Upsert(srcTable string, tgtTable string, primKeys []string, options ...UpsertOptions) (rowAffCnt int64, err error) {
 	var cnt int64
 	if conn.tx != nil {
-		cnt, err = Upsert(conn.Self(), conn.tx, srcTable, tgtTable, primKeys)
+		cnt, err = Upsert(conn.Self(), conn.tx, srcTable, tgtTable, primKeys, options...)
 	} else {
-		cnt, err = Upsert(conn.Self(), nil, srcTable, tgtTable, primKeys)
+		cnt, err = Upsert(conn.Self(), nil, srcTable, tgtTable, primKeys, options...)
 	}
 	if err != nil {
 		err = g.Error(err, "could not upsert")
@@ -2168,6 +2377,175 @@ func (conn *BaseConn) Upsert(srcTable string, tgtTable string, primKeys []string
 	return cast.ToInt64(cnt), err
 }
 
+// ExplainSQL returns the dialect statement showing the query plan of a query
+func ExplainSQL(dbType dbio.Type, sql string) (string, error) {
+	template, err := dbType.Template()
+	if err != nil {
+		return "", g.Error(err, "could not get template for %s", dbType)
+	}
+
+	explain := template.Core["explain"]
+	if explain == "" {
+		return "", g.Error("explain is not supported for %s", dbType)
+	}
+
+	sql = strings.TrimSuffix(strings.TrimSpace(sql), ";")
+	return g.R(explain, "sql", sql), nil
+}
+
+// CallProcedureSQL returns the dialect statement calling a stored procedure,
+// with the args passed as string literals
+func CallProcedureSQL(dbType dbio.Type, procedure string, args ...string) (string, error) {
+	template, err := dbType.Template()
+	if err != nil {
+		return "", g.Error(err, "could not get template for %s", dbType)
+	}
+
+	call := template.Core["call_procedure"]
+	if call == "" {
+		return "", g.Error("calling procedures is not supported for %s", dbType)
+	}
+
+	literals := lo.Map(args, func(arg string, i int) string {
+		return "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+	})
+	return g.R(call, "procedure", procedure, "args", strings.Join(literals, ", ")), nil
+}
+
+// ServerInfo is the version of the database server, with the effective user & role
+type ServerInfo struct {
+	Version string `json:"version,omitempty"`
+	User    string `json:"user,omitempty"`
+	Role    string `json:"role,omitempty"`
+}
+
+// ServerInfoSQL returns the dialect query of the server version, user & role
+func ServerInfoSQL(dbType dbio.Type) (string, error) {
+	template, err := dbType.Template()
+	if err != nil {
+		return "", g.Error(err, "could not get template for %s", dbType)
+	}
+
+	sql := strings.TrimSpace(template.Metadata["server_info"])
+	if sql == "" {
+		return "", g.Error("server info is not supported for %s", dbType)
+	}
+	return sql, nil
+}
+
+// GetServerInfo queries the server version, user & role. The info is empty
+// if the dialect does not support it.
+func GetServerInfo(conn Connection) (info ServerInfo, err error) {
+	sql, err := ServerInfoSQL(conn.GetType())
+	if err != nil {
+		return info, nil
+	}
+
+	data, err := conn.Query(sql + noDebugKey)
+	if err != nil {
+		return info, g.Error(err, "could not get server info")
+	}
+	return ParseServerInfo(data), nil
+}
+
+// ParseServerInfo parses the result of the server info query, with the
+// `version`, `user_name` & `role_name` columns
+func ParseServerInfo(data iop.Dataset) (info ServerInfo) {
+	if len(data.Rows) == 0 {
+		return
+	}
+
+	row := data.Rows[0]
+	for i, col := range data.Columns {
+		if i >= len(row) || row[i] == nil {
+			continue
+		}
+		val := strings.TrimSpace(cast.ToString(row[i]))
+		switch strings.ToLower(col.Name) {
+		case "version":
+			info.Version = strings.TrimSpace(strings.Split(val, "\n")[0]) // first line (SQL Server)
+		case "user_name":
+			info.User = val
+		case "role_name":
+			info.Role = val
+		}
+	}
+	return
+}
+
+// ProcedureExists returns true if the stored procedure (or function) exists.
+// The defaultSchema is used if the procedure name is not qualified.
+func ProcedureExists(conn Connection, procedure, defaultSchema string) (exists bool, err error) {
+	proc, err := ParseTableName(procedure, conn.GetType())
+	if err != nil {
+		return false, g.Error(err, "could not parse procedure name: %s", procedure)
+	}
+
+	sql := conn.GetTemplateValue("core.procedure_exists")
+	if sql == "" {
+		return true, nil // cannot check, assume it exists
+	}
+
+	sql = g.R(sql, "schema", lo.Ternary(proc.Schema != "", proc.Schema, defaultSchema), "name", proc.Name)
+	data, err := conn.Query(sql)
+	if err != nil {
+		return false, g.Error(err, "could not check if procedure exists: %s", procedure)
+	}
+
+	return len(data.Rows) > 0, nil
+}
+
+// DedupeSQL returns the dialect statement deleting duplicate rows in a table,
+// keeping the first row per primary key for the provided ordering
+func DedupeSQL(dbType dbio.Type, table string, pk []string, orderBy string) (string, error) {
+	template, err := dbType.Template()
+	if err != nil {
+		return "", g.Error(err, "could not get template for %s", dbType)
+	}
+
+	dedupe := template.Core["dedupe"]
+	if dedupe == "" {
+		return "", g.Error("dedupe is not supported for %s", dbType)
+	} else if len(pk) == 0 {
+		return "", g.Error("dedupe requires a primary key")
+	} else if strings.TrimSpace(orderBy) == "" {
+		return "", g.Error("dedupe requires an ordering")
+	}
+
+	pkQuoted := lo.Map(pk, func(k string, i int) string { return dbType.Quote(k) })
+	return g.R(
+		dedupe,
+		"table", table,
+		"partition_by", strings.Join(pkQuoted, ", "),
+		"order_by", orderBy,
+	), nil
+}
+
+// RenameColumnSQL returns the dialect statement renaming a column of a table
+func RenameColumnSQL(dbType dbio.Type, table Table, column, newColumn string) (string, error) {
+	template, err := dbType.Template()
+	if err != nil {
+		return "", g.Error(err, "could not get template for %s", dbType)
+	}
+
+	rename := template.Core["rename_column"]
+	if rename == "" || strings.Contains(rename, "{new_type}") {
+		return "", g.Error("renaming a column is not supported for %s", dbType)
+	}
+
+	tableName, newColName := table.FullName(), dbType.Quote(newColumn)
+	if strings.Contains(rename, "sp_rename") {
+		tableName, newColName = dbType.Unquote(table.FullName()), newColumn
+	}
+
+	return g.R(
+		rename,
+		"table", tableName,
+		"column", dbType.Quote(column),
+		"new_column", newColName,
+	), nil
+}
+
 // SwapTable swaps two table
 func (conn *BaseConn) SwapTable(srcTable string, tgtTable string) (err error) {
 
@@ -2453,9 +2831,9 @@ func (conn *BaseConn) BulkExportFlowCSV(table Table) (df *iop.Dataflow, err erro
 }
 
 // GenerateUpsertSQL returns a sql for upsert
-func (conn *BaseConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string) (sql string, err error) {
+func (conn *BaseConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string, options ...UpsertOptions) (sql string, err error) {
 
-	upsertMap, err := conn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields)
+	upsertMap, err := conn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields, options...)
 	if err != nil {
 		err = g.Error(err, "could not generate upsert variables")
 		return
@@ -2481,8 +2859,15 @@ func (conn *BaseConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFiel
 	return
 }
 
+// UpsertOptions restricts the columns updated by an upsert, all the non-pk
+// columns by default. Only one of the lists should be set.
+type UpsertOptions struct {
+	UpdateColumns  []string // only update these columns
+	ExcludeColumns []string // update all the columns but these
+}
+
 // GenerateUpsertExpressions returns a map with needed expressions
-func (conn *BaseConn) GenerateUpsertExpressions(srcTable string, tgtTable string, pkFields []string) (exprs map[string]string, err error) {
+func (conn *BaseConn) GenerateUpsertExpressions(srcTable string, tgtTable string, pkFields []string, options ...UpsertOptions) (exprs map[string]string, err error) {
 
 	srcColumns, err := conn.GetColumns(srcTable)
 	if err != nil {
@@ -2502,12 +2887,10 @@ func (conn *BaseConn) GenerateUpsertExpressions(srcTable string, tgtTable string
 	}
 
 	pkFields = pkCols.Names()
-	pkFieldMap := map[string]string{}
 	pkEqualFields := []string{}
 	for _, pkField := range pkFields {
 		pkEqualField := g.F("src.%s = tgt.%s", pkField, pkField)
 		pkEqualFields = append(pkEqualFields, pkEqualField)
-		pkFieldMap[pkField] = ""
 	}
 
 	srcCols, err := conn.ValidateColumnNames(tgtColumns, srcColumns.Names(), true)
@@ -2516,6 +2899,20 @@ func (conn *BaseConn) GenerateUpsertExpressions(srcTable string, tgtTable string
 		return
 	}
 
+	// the columns to update, all the non-pk columns by default
+	opts := UpsertOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	setCols, err := mergeSetColumns(
+		srcCols.Names(), pkFields,
+		opts.UpdateColumns, opts.ExcludeColumns,
+		conn.Self().Unquote,
+	)
+	if err != nil {
+		return
+	}
+
 	tgtFields := srcCols.Names()
 	setFields := []string{}
 	insertFields := []string{}
@@ -2523,11 +2920,10 @@ func (conn *BaseConn) GenerateUpsertExpressions(srcTable string, tgtTable string
 	for _, colName := range srcCols.Names() {
 		insertFields = append(insertFields, colName)
 		placeholdFields = append(placeholdFields, g.F("ph.%s", colName))
-		if _, ok := pkFieldMap[colName]; !ok {
-			// is not a pk field
-			setField := g.F("%s = src.%s", colName, colName)
-			setFields = append(setFields, setField)
-		}
+	}
+	for _, colName := range setCols {
+		setField := g.F("%s = src.%s", colName, colName)
+		setFields = append(setFields, setField)
 	}
 
 	// cast into the correct type
@@ -2547,6 +2943,62 @@ func (conn *BaseConn) GenerateUpsertExpressions(srcTable string, tgtTable string
 	return
 }
 
+// mergeSetColumns returns the columns set by the update clause of a merge:
+// the non-pk columns, restricted to updateColumns if any, without the
+// excludeColumns. Names are matched case-insensitively, unquoted.
+func mergeSetColumns(colNames, pkFields, updateColumns, excludeColumns []string, unquote func(string) string) (setCols []string, err error) {
+	normalize := func(name string) string {
+		return strings.ToLower(unquote(strings.TrimSpace(name)))
+	}
+
+	isPK := map[string]bool{}
+	for _, pkField := range pkFields {
+		isPK[normalize(pkField)] = true
+	}
+
+	colMap := map[string]bool{}
+	for _, colName := range colNames {
+		colMap[normalize(colName)] = true
+	}
+
+	toSet := func(names []string, option string) (set map[string]bool, err error) {
+		set = map[string]bool{}
+		for _, name := range names {
+			key := normalize(name)
+			if !colMap[key] {
+				return nil, g.Error("column '%s' of %s is not in the stream columns", name, option)
+			} else if isPK[key] {
+				return nil, g.Error("column '%s' of %s is a primary key column, which is never updated", name, option)
+			}
+			set[key] = true
+		}
+		return set, nil
+	}
+
+	updateSet, err := toSet(updateColumns, "merge_update_columns")
+	if err != nil {
+		return nil, err
+	}
+	excludeSet, err := toSet(excludeColumns, "merge_exclude_columns")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, colName := range colNames {
+		key := normalize(colName)
+		if isPK[key] || excludeSet[key] || (len(updateSet) > 0 && !updateSet[key]) {
+			continue
+		}
+		setCols = append(setCols, colName)
+	}
+
+	if len(setCols) == 0 && (len(updateColumns) > 0 || len(excludeColumns) > 0) {
+		return nil, g.Error("no columns left to update with merge_update_columns / merge_exclude_columns")
+	}
+
+	return setCols, nil
+}
+
 // GetColumnStats analyzes the table and returns the column statistics
 func (conn *BaseConn) GetColumnStats(tableName string, fields ...string) (columns iop.Columns, err error) {
 
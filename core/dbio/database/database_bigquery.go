@@ -22,12 +22,18 @@ import (
 	"golang.org/x/oauth2/google"
 
 	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
 	"cloud.google.com/go/civil"
 	"github.com/flarco/g"
 	"github.com/slingdata-io/sling-cli/core/dbio/iop"
 	"github.com/spf13/cast"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
 // BigQueryConn is a Google Big Query connection
@@ -94,15 +100,10 @@ func (conn *BigQueryConn) Init() error {
 	return nil
 }
 
-func (conn *BigQueryConn) getNewClient(timeOut ...int) (client *bigquery.Client, err error) {
-	var authOption option.ClientOption
+// getAuthOption returns the google client auth option from the props
+func (conn *BigQueryConn) getAuthOption() (authOption option.ClientOption, err error) {
 	var credJsonBody string
 
-	to := 15
-	if len(timeOut) > 0 {
-		to = timeOut[0]
-	}
-
 	if val := conn.GetProp("GC_KEY_BODY"); val != "" {
 		credJsonBody = val
 		authOption = option.WithCredentialsJSON([]byte(val))
@@ -110,7 +111,7 @@ func (conn *BigQueryConn) getNewClient(timeOut ...int) (client *bigquery.Client,
 		authOption = option.WithCredentialsFile(val)
 		b, err := os.ReadFile(val)
 		if err != nil {
-			return client, g.Error(err, "could not read google cloud key file")
+			return nil, g.Error(err, "could not read google cloud key file")
 		}
 		credJsonBody = string(b)
 	} else if val := conn.GetProp("GC_CRED_API_KEY"); val != "" {
@@ -119,13 +120,13 @@ func (conn *BigQueryConn) getNewClient(timeOut ...int) (client *bigquery.Client,
 		authOption = option.WithCredentialsFile(val)
 		b, err := os.ReadFile(val)
 		if err != nil {
-			return client, g.Error(err, "could not read google cloud key file")
+			return nil, g.Error(err, "could not read google cloud key file")
 		}
 		credJsonBody = string(b)
 	} else {
 		creds, err := google.FindDefaultCredentials(conn.BaseConn.Context().Ctx)
 		if err != nil {
-			return client, g.Error(err, "No Google credentials provided or could not find Application Default Credentials.")
+			return nil, g.Error(err, "No Google credentials provided or could not find Application Default Credentials.")
 		}
 		authOption = option.WithCredentials(creds)
 	}
@@ -136,6 +137,20 @@ func (conn *BigQueryConn) getNewClient(timeOut ...int) (client *bigquery.Client,
 		conn.ProjectID = cast.ToString(m["project_id"])
 	}
 
+	return authOption, nil
+}
+
+func (conn *BigQueryConn) getNewClient(timeOut ...int) (client *bigquery.Client, err error) {
+	to := 15
+	if len(timeOut) > 0 {
+		to = timeOut[0]
+	}
+
+	authOption, err := conn.getAuthOption()
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(conn.BaseConn.Context().Ctx, time.Duration(to)*time.Second)
 	defer cancel()
 
@@ -557,13 +572,81 @@ func (conn *BigQueryConn) BulkImportFlow(tableFName string, df *iop.Dataflow) (c
 		}
 	}
 
-	if gcBucket := conn.GetProp("GC_BUCKET"); gcBucket == "" {
+	stagingURL := conn.GetProp("GCS_STAGING")
+	if stagingURL == "" && conn.GetProp("GC_BUCKET") != "" {
+		stagingURL = "gs://" + conn.GetProp("GC_BUCKET")
+	}
+
+	largeLoad := df != nil && len(df.Buffer) >= iop.SampleSize
+	method, err := BigQueryLoadMethod(conn.GetProp("BIGQUERY_METHOD"), stagingURL, largeLoad)
+	if err != nil {
+		return 0, err
+	}
+
+	switch method {
+	case BigQueryMethodStorageWrite:
+		return conn.importViaStorageWrite(tableFName, df)
+	case BigQueryMethodInsertAll:
+		return conn.importViaInsertAll(tableFName, df)
+	}
+
+	if stagingURL == "" {
 		return conn.importViaLocalStorage(tableFName, df)
 	}
 
 	return conn.importViaGoogleStorage(tableFName, df)
 }
 
+const (
+	// BigQueryMethodStorageWrite streams rows with the Storage Write API
+	BigQueryMethodStorageWrite = "storage_write"
+	// BigQueryMethodLoadJob stages files (locally or in GCS) and runs load jobs
+	BigQueryMethodLoadJob = "load_job"
+	// BigQueryMethodInsertAll streams rows with the legacy insertAll API
+	BigQueryMethodInsertAll = "insert_all"
+)
+
+// BigQueryLoadMethod returns the method to load data into BigQuery.
+// If not specified, a load job is used when a GCS staging location is set,
+// the Storage Write API for large loads, and a local load job otherwise.
+func BigQueryLoadMethod(method, stagingURL string, largeLoad bool) (string, error) {
+	method = strings.ToLower(strings.TrimSpace(method))
+	switch method {
+	case BigQueryMethodStorageWrite, BigQueryMethodLoadJob, BigQueryMethodInsertAll:
+		return method, nil
+	case "":
+	default:
+		return "", g.Error("invalid bigquery_method: %s. Expected storage_write, load_job or insert_all", method)
+	}
+
+	if stagingURL == "" && largeLoad {
+		return BigQueryMethodStorageWrite, nil
+	}
+	return BigQueryMethodLoadJob, nil
+}
+
+// BigQueryStagingPath returns the GCS folder to stage the table files into,
+// from a staging location such as `gs://my-bucket/some/prefix`
+func BigQueryStagingPath(stagingURL, tableFName, timestamp string) (string, error) {
+	stagingURL = strings.TrimSpace(stagingURL)
+	if !strings.HasPrefix(stagingURL, "gs://") {
+		return "", g.Error("invalid gcs_staging location, must start with gs:// : %s", stagingURL)
+	}
+
+	bucketPath := strings.TrimSuffix(strings.TrimPrefix(stagingURL, "gs://"), "/")
+	if bucketPath == "" {
+		return "", g.Error("invalid gcs_staging location, missing bucket: %s", stagingURL)
+	}
+
+	return fmt.Sprintf(
+		"gs://%s/%s/%s/%s",
+		bucketPath,
+		tempCloudStorageFolder,
+		env.CleanTableName(tableFName),
+		timestamp,
+	), nil
+}
+
 func (conn *BigQueryConn) importViaLocalStorage(tableFName string, df *iop.Dataflow) (count uint64, err error) {
 	settingMppBulkImportFlow(conn, iop.GzipCompressorType)
 
@@ -639,25 +722,44 @@ func (conn *BigQueryConn) importViaLocalStorage(tableFName string, df *iop.Dataf
 }
 
 func (conn *BigQueryConn) importViaGoogleStorage(tableFName string, df *iop.Dataflow) (count uint64, err error) {
-	settingMppBulkImportFlow(conn, iop.GzipCompressorType)
-
 	gcBucket := conn.GetProp("GC_BUCKET")
+	stagingURL := conn.GetProp("GCS_STAGING")
 
-	if gcBucket == "" {
-		return count, g.Error("Need to set 'GC_BUCKET' to copy to google storage")
+	if gcBucket == "" && stagingURL == "" {
+		return count, g.Error("Need to set 'GC_BUCKET' or 'gcs_staging' to copy to google storage")
 	}
-	fs, err := filesys.NewFileSysClient(dbio.TypeFileGoogle, conn.PropArr()...)
+
+	// stage as parquet when gcs_staging is provided, else as csv in GC_BUCKET
+	var gcsPath string
+	if stagingURL != "" {
+		settingMppBulkImportFlow(conn, iop.SnappyCompressorType)
+		gcsPath, err = BigQueryStagingPath(stagingURL, tableFName, g.NowFileStr())
+		if err != nil {
+			return count, err
+		}
+		gcBucket = strings.Split(strings.TrimPrefix(gcsPath, "gs://"), "/")[0]
+	} else {
+		settingMppBulkImportFlow(conn, iop.GzipCompressorType)
+		gcsPath = fmt.Sprintf(
+			"gs://%s/%s/%s.csv",
+			gcBucket,
+			tempCloudStorageFolder,
+			tableFName,
+		)
+	}
+
+	fs, err := filesys.NewFileSysClient(dbio.TypeFileGoogle, append(conn.PropArr(), "BUCKET="+gcBucket)...)
 	if err != nil {
 		err = g.Error(err, "Could not get fs client for GCS")
 		return
 	}
 
-	gcsPath := fmt.Sprintf(
-		"gs://%s/%s/%s.csv",
-		gcBucket,
-		tempCloudStorageFolder,
-		tableFName,
-	)
+	if stagingURL != "" {
+		fs.SetProp("FORMAT", string(dbio.FileTypeParquet))
+		if err = checkStagingPermissions(fs, gcsPath); err != nil {
+			return count, err
+		}
+	}
 
 	err = filesys.Delete(fs, gcsPath)
 	if err != nil {
@@ -728,6 +830,293 @@ func (conn *BigQueryConn) importViaGoogleStorage(tableFName string, df *iop.Data
 	return df.Count(), nil
 }
 
+// checkStagingPermissions validates that the staging location is writable
+// and deletable, to fail early rather than after extracting the data
+func checkStagingPermissions(fs filesys.FileSysClient, gcsPath string) error {
+	testPath := gcsPath + "/_sling_permission_check"
+	if _, err := fs.Write(testPath, strings.NewReader("ok")); err != nil {
+		return g.Error(err, "could not write to gcs_staging location %s. Make sure the credentials have the storage.objects.create permission", gcsPath)
+	}
+	if err := filesys.Delete(fs, testPath); err != nil {
+		return g.Error(err, "could not delete from gcs_staging location %s. Make sure the credentials have the storage.objects.delete permission", gcsPath)
+	}
+	return nil
+}
+
+// bqColumnIndex maps the stream column index to the table column index
+func bqColumnIndex(tableCols, dsCols iop.Columns) map[int]int {
+	tableIndex := map[string]int{}
+	for j, col := range tableCols {
+		tableIndex[strings.ToLower(col.Name)] = j
+	}
+
+	colIndex := map[int]int{}
+	for i, col := range dsCols {
+		if j, ok := tableIndex[strings.ToLower(col.Name)]; ok {
+			colIndex[i] = j
+		}
+	}
+	return colIndex
+}
+
+// storageWriteDescriptor builds the proto message descriptor of the table
+// columns, for the Storage Write API. Timestamps are sent as epoch micros,
+// dates as epoch days, numerics & datetimes as strings.
+func storageWriteDescriptor(columns iop.Columns) (protoreflect.MessageDescriptor, *descriptorpb.DescriptorProto, error) {
+	dp := &descriptorpb.DescriptorProto{Name: proto.String("SlingRow")}
+	for i, col := range columns {
+		fieldType := descriptorpb.FieldDescriptorProto_TYPE_STRING
+		switch {
+		case col.Type.IsInteger():
+			fieldType = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		case col.Type.IsFloat():
+			fieldType = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+		case col.Type.IsBool():
+			fieldType = descriptorpb.FieldDescriptorProto_TYPE_BOOL
+		case col.Type.IsBinary():
+			fieldType = descriptorpb.FieldDescriptorProto_TYPE_BYTES
+		case col.Type.IsDate():
+			fieldType = descriptorpb.FieldDescriptorProto_TYPE_INT32
+		case col.Type.IsDatetime() && !strings.EqualFold(col.DbType, "DATETIME"):
+			fieldType = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		}
+
+		dp.Field = append(dp.Field, &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(col.Name),
+			Number: proto.Int32(int32(i + 1)),
+			Type:   fieldType.Enum(),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		})
+	}
+
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("sling_row.proto"),
+		Syntax:      proto.String("proto2"),
+		MessageType: []*descriptorpb.DescriptorProto{dp},
+	}, nil)
+	if err != nil {
+		return nil, nil, g.Error(err, "could not build storage write descriptor (use bigquery_method 'load_job' for non-standard column names)")
+	}
+
+	return fd.Messages().Get(0), dp, nil
+}
+
+// storageWriteValue converts a value for a proto field of the column
+func storageWriteValue(col iop.Column, field protoreflect.FieldDescriptor, val any) (protoreflect.Value, error) {
+	switch field.Kind() {
+	case protoreflect.Int64Kind:
+		if col.Type.IsDatetime() {
+			t, err := cast.ToTimeE(val)
+			return protoreflect.ValueOfInt64(t.UnixMicro()), err
+		}
+		i, err := cast.ToInt64E(val)
+		return protoreflect.ValueOfInt64(i), err
+	case protoreflect.Int32Kind:
+		t, err := cast.ToTimeE(val)
+		days := t.UTC().Truncate(24*time.Hour).Unix() / 86400
+		return protoreflect.ValueOfInt32(int32(days)), err
+	case protoreflect.DoubleKind:
+		f, err := cast.ToFloat64E(val)
+		return protoreflect.ValueOfFloat64(f), err
+	case protoreflect.BoolKind:
+		b, err := cast.ToBoolE(val)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.BytesKind:
+		if b, ok := val.([]byte); ok {
+			return protoreflect.ValueOfBytes(b), nil
+		}
+		return protoreflect.ValueOfBytes([]byte(cast.ToString(val))), nil
+	}
+
+	if t, ok := val.(time.Time); ok {
+		return protoreflect.ValueOfString(t.Format("2006-01-02 15:04:05.999999")), nil
+	}
+	return protoreflect.ValueOfString(cast.ToString(val)), nil
+}
+
+// importViaStorageWrite streams the rows into the table with the
+// Storage Write API, using the default stream (committed on append)
+func (conn *BigQueryConn) importViaStorageWrite(tableFName string, df *iop.Dataflow) (count uint64, err error) {
+	ctx := conn.Context().Ctx
+
+	table, err := ParseTableName(tableFName, conn.Type)
+	if err != nil {
+		err = g.Error(err, "could not parse table name: "+tableFName)
+		return
+	}
+
+	table.Columns, err = conn.GetSQLColumns(table)
+	if err != nil {
+		err = g.Error(err, "could not get table columns: "+tableFName)
+		return
+	}
+
+	md, dp, err := storageWriteDescriptor(table.Columns)
+	if err != nil {
+		return
+	}
+
+	authOption, err := conn.getAuthOption()
+	if err != nil {
+		return
+	}
+
+	client, err := managedwriter.NewClient(ctx, conn.ProjectID, authOption)
+	if err != nil {
+		return count, g.Error(err, "could not create storage write client")
+	}
+	defer client.Close()
+
+	stream, err := client.NewManagedStream(ctx,
+		managedwriter.WithDestinationTable(managedwriter.TableParentFromParts(conn.ProjectID, table.Schema, table.Name)),
+		managedwriter.WithType(managedwriter.DefaultStream),
+		managedwriter.WithSchemaDescriptor(dp),
+	)
+	if err != nil {
+		return count, g.Error(err, "could not open storage write stream for %s. Make sure the credentials have the bigquery.tables.updateData permission", tableFName)
+	}
+	defer stream.Close()
+
+	g.Info("importing into bigquery via storage write api")
+
+	results := []*managedwriter.AppendResult{}
+	batch := [][]byte{}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		result, err := stream.AppendRows(ctx, batch)
+		if err != nil {
+			return g.Error(err, "could not append rows")
+		}
+		results = append(results, result)
+		batch = [][]byte{}
+		return nil
+	}
+
+	ds := iop.MergeDataflow(df)
+	var colIndex map[int]int
+	for row := range ds.Rows() {
+		if colIndex == nil {
+			colIndex = bqColumnIndex(table.Columns, ds.Columns)
+		}
+
+		msg := dynamicpb.NewMessage(md)
+		for i, val := range row {
+			j, ok := colIndex[i]
+			if !ok || val == nil {
+				continue
+			}
+
+			field := md.Fields().Get(j)
+			value, err := storageWriteValue(table.Columns[j], field, val)
+			if err != nil {
+				return count, g.Error(err, "could not convert value for column %s: %v", table.Columns[j].Name, val)
+			}
+			msg.Set(field, value)
+		}
+
+		b, err := proto.Marshal(msg)
+		if err != nil {
+			return count, g.Error(err, "could not serialize row")
+		}
+
+		batch = append(batch, b)
+		if len(batch) >= 500 {
+			if err = flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+
+	if err = flush(); err != nil {
+		return count, err
+	}
+
+	for _, result := range results {
+		if _, err = result.GetResult(ctx); err != nil {
+			return count, g.Error(err, "error appending rows via storage write api")
+		}
+	}
+
+	if df.Err() != nil {
+		return df.Count(), g.Error(df.Err(), "Error importing to BigQuery")
+	}
+
+	return df.Count(), nil
+}
+
+// importViaInsertAll streams the rows into the table with the
+// legacy insertAll API. Suited for small, low-latency loads.
+func (conn *BigQueryConn) importViaInsertAll(tableFName string, df *iop.Dataflow) (count uint64, err error) {
+	table, err := ParseTableName(tableFName, conn.Type)
+	if err != nil {
+		err = g.Error(err, "could not parse table name: "+tableFName)
+		return
+	}
+
+	table.Columns, err = conn.GetSQLColumns(table)
+	if err != nil {
+		err = g.Error(err, "could not get table columns: "+tableFName)
+		return
+	}
+
+	client, err := conn.getNewClient()
+	if err != nil {
+		return count, g.Error(err, "Failed to connect to client")
+	}
+	defer client.Close()
+
+	inserter := client.Dataset(table.Schema).Table(table.Name).Inserter()
+	schema := getBqSchema(table.Columns)
+
+	g.Info("importing into bigquery via insertAll")
+
+	batch := []*bigquery.ValuesSaver{}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := inserter.Put(conn.Context().Ctx, batch); err != nil {
+			return g.Error(err, "could not insert rows into %s. Make sure the credentials have the bigquery.tables.updateData permission", tableFName)
+		}
+		batch = []*bigquery.ValuesSaver{}
+		return nil
+	}
+
+	ds := iop.MergeDataflow(df)
+	var colIndex map[int]int
+	for row := range ds.Rows() {
+		if colIndex == nil {
+			colIndex = bqColumnIndex(table.Columns, ds.Columns)
+		}
+
+		values := make([]bigquery.Value, len(table.Columns))
+		for i, val := range row {
+			if j, ok := colIndex[i]; ok {
+				values[j] = val
+			}
+		}
+
+		batch = append(batch, &bigquery.ValuesSaver{Schema: schema, Row: values})
+		if len(batch) >= 500 {
+			if err = flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+
+	if err = flush(); err != nil {
+		return count, err
+	}
+
+	if df.Err() != nil {
+		return df.Count(), g.Error(df.Err(), "Error importing to BigQuery")
+	}
+
+	return df.Count(), nil
+}
+
 // CopyFromGCS into bigquery from google storage
 func (conn *BigQueryConn) CopyFromLocal(localURI string, table Table, dsColumns []iop.Column) error {
 
@@ -795,14 +1184,18 @@ func (conn *BigQueryConn) CopyFromGCS(gcsURI string, table Table, dsColumns []io
 	defer client.Close()
 
 	gcsRef := bigquery.NewGCSReference(gcsURI)
-	gcsRef.FieldDelimiter = ","
-	gcsRef.AllowQuotedNewlines = true
-	gcsRef.Quote = `"`
-	gcsRef.NullMarker = `\N`
-	gcsRef.SkipLeadingRows = 1
-	gcsRef.Schema = getBqSchema(dsColumns)
-	if strings.HasSuffix(strings.ToLower(gcsURI), ".gz") {
-		gcsRef.Compression = bigquery.Gzip
+	if strings.Contains(strings.ToLower(gcsURI), ".parquet") {
+		gcsRef.SourceFormat = bigquery.Parquet
+	} else {
+		gcsRef.FieldDelimiter = ","
+		gcsRef.AllowQuotedNewlines = true
+		gcsRef.Quote = `"`
+		gcsRef.NullMarker = `\N`
+		gcsRef.SkipLeadingRows = 1
+		gcsRef.Schema = getBqSchema(dsColumns)
+		if strings.HasSuffix(strings.ToLower(gcsURI), ".gz") {
+			gcsRef.Compression = bigquery.Gzip
+		}
 	}
 	gcsRef.MaxBadRecords = 0
 	loader := client.Dataset(table.Schema).Table(table.Name).LoaderFrom(gcsRef)
@@ -1024,9 +1417,9 @@ func (conn *BigQueryConn) CastColumnForSelect(srcCol iop.Column, tgtCol iop.Colu
 }
 
 // GenerateUpsertSQL generates the upsert SQL
-func (conn *BigQueryConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string) (sql string, err error) {
+func (conn *BigQueryConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string, options ...UpsertOptions) (sql string, err error) {
 
-	upsertMap, err := conn.BaseConn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields)
+	upsertMap, err := conn.BaseConn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields, options...)
 	if err != nil {
 		err = g.Error(err, "could not generate upsert variables")
 		return
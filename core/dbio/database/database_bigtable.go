@@ -367,6 +367,11 @@ func (conn *BigTableConn) GetSQLColumns(table Table) (columns iop.Columns, err e
 	return conn.GetColumns(table.FullName())
 }
 
+// GetColumnsContext returns the columns with GetColumns, ctx is not used
+func (conn *BigTableConn) GetColumnsContext(ctx context.Context, tableFName string, fields ...string) (columns iop.Columns, err error) {
+	return conn.GetColumns(tableFName, fields...)
+}
+
 func (conn *BigTableConn) GetColumns(tableFName string, fields ...string) (columns iop.Columns, err error) {
 	// fields: [table_name]
 
@@ -0,0 +1,874 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"math/big"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	gnet "github.com/flarco/g/net"
+	"github.com/gocql/gocql"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+	"gopkg.in/inf.v0"
+)
+
+// CassandraConn is a Cassandra / ScyllaDB connection (CQL).
+// The schema is the keyspace. Collections, tuples and user-defined types
+// are read as JSON columns. Rows are written with batches of prepared
+// inserts, which are upserts on the primary key of the table.
+//
+// Connection properties:
+//   - `host`: the contact points, comma separated (e.g. `node1,node2`)
+//   - `keyspace`: the default keyspace
+//   - `consistency`: the consistency level of reads & writes (default `local_quorum`)
+//   - `datacenter`: the local datacenter, to only query its nodes
+//   - `batch_size`: the rows per batch (default 50). A batch only holds rows
+//     of the same partition, which avoids large multi-partition batches.
+type CassandraConn struct {
+	BaseConn
+	URL      string
+	Session  *gocql.Session
+	Keyspace string
+}
+
+// cassandraDefBatchSize is the default number of rows per batch
+const cassandraDefBatchSize = 50
+
+// Init initiates the object
+func (conn *CassandraConn) Init() error {
+	conn.BaseConn.URL = conn.URL
+	conn.BaseConn.Type = dbio.TypeDbCassandra
+
+	u, err := gnet.NewURL(conn.BaseConn.URL)
+	if err != nil {
+		return g.Error(err, "could not parse cassandra url")
+	}
+
+	setIfMissing := func(key, val string) {
+		if conn.GetProp(key) == "" && val != "" {
+			conn.SetProp(key, val)
+		}
+	}
+	setIfMissing("host", conn.GetProp("hosts"))
+	setIfMissing("host", u.Hostname())
+	setIfMissing("port", u.Port(dbio.TypeDbCassandra.DefPort()))
+	setIfMissing("username", u.Username())
+	setIfMissing("password", u.Password())
+	setIfMissing("keyspace", strings.ReplaceAll(u.Path(), "/", ""))
+	for key, val := range u.Query() {
+		setIfMissing(key, val)
+	}
+
+	conn.Keyspace = conn.GetProp("keyspace")
+	setIfMissing("schema", conn.Keyspace)
+
+	instance := Connection(conn)
+	conn.BaseConn.instance = &instance
+
+	err = conn.BaseConn.Init()
+	if err != nil {
+		err = g.Error(err, "could not initialize connection")
+		return err
+	}
+
+	return nil
+}
+
+// getCluster returns the cluster config from the properties
+func (conn *CassandraConn) getCluster(timeOut ...int) (cluster *gocql.ClusterConfig, err error) {
+	hosts := lo.Filter(
+		lo.Map(strings.Split(conn.GetProp("host"), ","), func(h string, i int) string { return strings.TrimSpace(h) }),
+		func(h string, i int) bool { return h != "" },
+	)
+	if len(hosts) == 0 {
+		return nil, g.Error("no cassandra host provided")
+	}
+
+	cluster = gocql.NewCluster(hosts...)
+	cluster.Port = cast.ToInt(conn.GetProp("port"))
+	cluster.Keyspace = conn.Keyspace
+
+	to := 15
+	if len(timeOut) > 0 && timeOut[0] > 0 {
+		to = timeOut[0]
+	}
+	cluster.ConnectTimeout = time.Duration(to) * time.Second
+	cluster.Timeout = 30 * time.Second
+	if val := cast.ToInt(conn.GetProp("timeout")); val > 0 {
+		cluster.Timeout = time.Duration(val) * time.Second
+	}
+
+	cluster.Consistency = gocql.LocalQuorum
+	if val := conn.GetProp("consistency"); val != "" {
+		if cluster.Consistency, err = gocql.ParseConsistencyWrapper(strings.ToUpper(val)); err != nil {
+			return nil, g.Error(err, "invalid consistency: %s", val)
+		}
+	}
+
+	if val := cast.ToInt(conn.GetProp("proto_version")); val > 0 {
+		cluster.ProtoVersion = val
+	}
+
+	if username := conn.GetProp("username"); username != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{
+			Username: username,
+			Password: conn.GetProp("password"),
+		}
+	}
+
+	// route the queries & batches to the replicas of their partition
+	if dc := conn.GetProp("datacenter"); dc != "" {
+		cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.DCAwareRoundRobinPolicy(dc))
+	} else {
+		cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())
+	}
+
+	tlsConfig, err := conn.makeTlsConfig()
+	if err != nil {
+		return nil, g.Error(err, "could not make tls config")
+	} else if tlsConfig != nil {
+		cluster.SslOpts = &gocql.SslOptions{
+			Config:                 tlsConfig,
+			EnableHostVerification: !tlsConfig.InsecureSkipVerify,
+		}
+	}
+
+	return cluster, nil
+}
+
+// Connect connects to the database
+func (conn *CassandraConn) Connect(timeOut ...int) (err error) {
+	if conn.Session != nil {
+		return nil
+	}
+
+	cluster, err := conn.getCluster(timeOut...)
+	if err != nil {
+		return g.Error(err, "could not configure cassandra cluster")
+	}
+
+	conn.Session, err = cluster.CreateSession()
+	if err != nil {
+		return g.Error(err, "could not connect to cassandra")
+	}
+
+	g.Debug(`opened "%s" connection (%s)`, conn.Type, conn.GetProp("sling_conn_id"))
+
+	return nil
+}
+
+// Close closes the session
+func (conn *CassandraConn) Close() error {
+	if conn.Session != nil {
+		conn.Session.Close()
+		conn.Session = nil
+	}
+	g.Debug(`closed "%s" connection (%s)`, conn.Type, conn.GetProp("sling_conn_id"))
+	return nil
+}
+
+// NewTransaction creates a new transaction
+func (conn *CassandraConn) NewTransaction(ctx context.Context, options ...*sql.TxOptions) (tx Transaction, err error) {
+	// does not support transaction
+	return
+}
+
+// ExecContext runs a CQL statement
+func (conn *CassandraConn) ExecContext(ctx context.Context, q string, args ...interface{}) (result sql.Result, err error) {
+	q = strings.TrimSuffix(strings.TrimSpace(q), ";")
+	if q == "" {
+		g.Warn("Empty Query")
+		return
+	}
+
+	if err = reconnectIfClosed(conn); err != nil {
+		return nil, g.Error(err, "Could not reconnect")
+	}
+
+	conn.LogSQL(q, args...)
+	if err = conn.Session.Query(q, args...).WithContext(ctx).Exec(); err != nil {
+		return nil, g.Error(err, "Error executing %s", q)
+	}
+
+	return Result{rowsAffected: 0}, nil
+}
+
+// StreamRowsContext streams the rows of a CQL query
+func (conn *CassandraConn) StreamRowsContext(ctx context.Context, query string, options ...map[string]interface{}) (ds *iop.Datastream, err error) {
+	if err = reconnectIfClosed(conn); err != nil {
+		return ds, g.Error(err, "Could not reconnect")
+	}
+
+	opts := getQueryOptions(options)
+	Limit := cast.ToUint64(opts["limit"])
+
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+	if query == "" {
+		return ds, g.Error("Empty Query")
+	}
+
+	queryContext := g.NewContext(ctx)
+
+	if !cast.ToBool(opts["silent"]) {
+		conn.LogSQL(query)
+	}
+
+	pageSize := 5000
+	if val := cast.ToInt(conn.GetProp("page_size")); val > 0 {
+		pageSize = val
+	}
+
+	iter := conn.Session.Query(query).WithContext(queryContext.Ctx).PageSize(pageSize).Iter()
+	if len(iter.Columns()) == 0 {
+		if err = iter.Close(); err != nil {
+			queryContext.Cancel()
+			return ds, g.Error(err, "CQL Error for:\n"+query)
+		}
+	}
+
+	colTypes := lo.Map(iter.Columns(), func(ci gocql.ColumnInfo, i int) ColumnType {
+		return ColumnType{
+			Name:             ci.Name,
+			DatabaseTypeName: ci.TypeInfo.Type().String(),
+			Sourced:          true,
+		}
+	})
+	columns := SQLColumns(colTypes, conn)
+
+	scanner := newCassandraScanner(iter.Columns())
+
+	nextFunc := func(it *iop.Iterator) bool {
+		if Limit > 0 && it.Counter >= Limit {
+			iter.Close()
+			return false
+		}
+
+		if row, ok := scanner.Scan(iter); ok {
+			it.Row = row
+			return true
+		}
+
+		if err := iter.Close(); err != nil {
+			it.Context.CaptureErr(g.Error(err, "error during row iteration"))
+		}
+		return false
+	}
+
+	ds = iop.NewDatastreamIt(queryContext.Ctx, columns, nextFunc)
+	ds.NoDebug = strings.Contains(query, noDebugKey)
+	ds.Inferred = !InferDBStream && ds.Columns.Sourced()
+	if !ds.NoDebug {
+		ds.SetMetadata(conn.GetProp("METADATA"))
+		conn.setTransforms(ds.Columns)
+		ds.SetConfig(conn.Props())
+	}
+
+	err = ds.Start()
+	if err != nil {
+		queryContext.Cancel()
+		return ds, g.Error(err, "could start datastream")
+	}
+
+	return
+}
+
+var cassandraLimitRegex = regexp.MustCompile(`(?is)\s+limit\s+\d+`)
+
+// cassandraLimitSQL adds the limit to the CQL query, before any `allow filtering`
+func cassandraLimitSQL(query string, limit int) string {
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+	if limit <= 0 || cassandraLimitRegex.MatchString(query) {
+		return query
+	}
+
+	limitStr := g.F(" limit %d", limit)
+	lower := strings.ToLower(query)
+	if strings.HasSuffix(lower, "allow filtering") {
+		index := len(query) - len("allow filtering")
+		return strings.TrimSpace(query[:index]) + limitStr + " " + query[index:]
+	}
+	return query + limitStr
+}
+
+// cassandraScanner scans the rows into nullable values. The values of a
+// tuple column are scanned separately, and combined into a JSON array.
+type cassandraScanner struct {
+	columns []gocql.ColumnInfo
+	dests   []any
+}
+
+func newCassandraScanner(columns []gocql.ColumnInfo) *cassandraScanner {
+	s := &cassandraScanner{columns: columns}
+	for _, col := range columns {
+		if tuple, ok := col.TypeInfo.(gocql.TupleTypeInfo); ok {
+			for _, elem := range tuple.Elems {
+				s.dests = append(s.dests, newCassandraDest(elem))
+			}
+			continue
+		}
+		s.dests = append(s.dests, newCassandraDest(col.TypeInfo))
+	}
+	return s
+}
+
+// newCassandraDest returns a pointer to a pointer of the go type of the
+// CQL type, so that null values are scanned as nil pointers
+func newCassandraDest(info gocql.TypeInfo) any {
+	val, err := info.NewWithError()
+	if err != nil {
+		val = new([]byte) // custom types, as bytes
+	}
+	return reflect.New(reflect.TypeOf(val)).Interface()
+}
+
+// Scan scans the next row
+func (s *cassandraScanner) Scan(iter *gocql.Iter) (row []any, ok bool) {
+	if !iter.Scan(s.dests...) {
+		return nil, false
+	}
+
+	deref := func(dest any) any {
+		ptr := reflect.ValueOf(dest).Elem()
+		if ptr.IsNil() {
+			return nil
+		}
+		return ptr.Elem().Interface()
+	}
+
+	row = make([]any, len(s.columns))
+	d := 0
+	for i, col := range s.columns {
+		if tuple, ok := col.TypeInfo.(gocql.TupleTypeInfo); ok {
+			values := make([]any, len(tuple.Elems))
+			for j := range tuple.Elems {
+				values[j] = deref(s.dests[d])
+				d++
+			}
+			row[i] = cassandraValue(values)
+			continue
+		}
+		row[i] = cassandraValue(deref(s.dests[d]))
+		d++
+	}
+
+	return row, true
+}
+
+// cassandraValue converts a scanned value: uuids, decimals, varints and
+// inets into strings, and collections, tuples & user-defined types into JSON
+func cassandraValue(val any) any {
+	switch v := val.(type) {
+	case nil:
+		return nil
+	case string, bool, int, int8, int16, int32, int64, float32, float64, []byte, time.Time:
+		return v
+	case time.Duration:
+		// the `time` type, a time of day
+		return time.Time{}.Add(v).Format("15:04:05.999999999")
+	case gocql.UUID:
+		return v.String()
+	case gocql.Duration:
+		// as a CQL duration literal
+		return g.F("%dmo%dd%dns", v.Months, v.Days, v.Nanoseconds)
+	case *inf.Dec:
+		if v == nil {
+			return nil
+		}
+		return v.String()
+	case *big.Int:
+		if v == nil {
+			return nil
+		}
+		return v.String()
+	case net.IP:
+		return v.String()
+	}
+
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if rv.Len() == 0 {
+			return nil // empty collections are null
+		}
+		return g.Marshal(cassandraJSONValue(val))
+	}
+
+	return cast.ToString(val)
+}
+
+// cassandraJSONValue converts the collection into a JSON compatible value
+func cassandraJSONValue(val any) any {
+	switch val.(type) {
+	case nil, []byte, gocql.UUID, *inf.Dec, *big.Int, net.IP:
+		return cassandraValue(val)
+	}
+
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		arr := make([]any, rv.Len())
+		for i := range arr {
+			arr[i] = cassandraJSONValue(rv.Index(i).Interface())
+		}
+		return arr
+	case reflect.Map:
+		m := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[cast.ToString(cassandraValue(iter.Key().Interface()))] = cassandraJSONValue(iter.Value().Interface())
+		}
+		return m
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+		return cassandraJSONValue(rv.Elem().Interface())
+	}
+
+	return cassandraValue(val)
+}
+
+// GetCount returns the number of rows of the table
+func (conn *CassandraConn) GetCount(tableFName string) (uint64, error) {
+	data, err := conn.Self().Query(g.F(`select count(*) as cnt from %s`, tableFName))
+	if err != nil {
+		return 0, err
+	}
+	return cast.ToUint64(data.Rows[0][0]), nil
+}
+
+// GetColumnsContext returns the columns with GetColumns, ctx is not used
+func (conn *CassandraConn) GetColumnsContext(ctx context.Context, tableFName string, fields ...string) (columns iop.Columns, err error) {
+	return conn.GetColumns(tableFName, fields...)
+}
+
+// GetTableColumns returns the columns of the table, in the order of a
+// `select *`: partition key, clustering columns, then the other columns
+func (conn *CassandraConn) GetTableColumns(table *Table, fields ...string) (columns iop.Columns, err error) {
+	if table.IsQuery() {
+		return conn.GetSQLColumns(*table)
+	}
+
+	colData, err := conn.Self().SubmitTemplate(
+		"single", conn.template.Metadata, "columns",
+		g.M("schema", table.Schema, "table", table.Name),
+	)
+	if err != nil {
+		return columns, g.Error(err, "could not get list of columns for %s", table.FullName())
+	} else if len(colData.Rows) == 0 {
+		return columns, g.Error("did not find any columns for %s. Perhaps it does not exists, or user does not have read permission.", table.FullName())
+	}
+
+	records := sortCassandraColumns(colData.Records())
+	if len(fields) > 0 {
+		recMap := lo.KeyBy(records, func(rec map[string]any) string {
+			return strings.ToLower(cast.ToString(rec["column_name"]))
+		})
+		records = nil
+		for _, field := range fields {
+			rec, ok := recMap[strings.ToLower(field)]
+			if !ok {
+				return columns, g.Error("provided field '%s' not found in table %s", strings.ToLower(field), table.FullName())
+			}
+			records = append(records, rec)
+		}
+	}
+
+	colTypes := lo.Map(records, func(rec map[string]any, i int) ColumnType {
+		return ColumnType{
+			Name:             cast.ToString(rec["column_name"]),
+			DatabaseTypeName: cast.ToString(rec["data_type"]),
+			Sourced:          true,
+		}
+	})
+
+	columns = SQLColumns(colTypes, conn)
+	for i := range columns {
+		columns[i].Database = table.Database
+		columns[i].Schema = table.Schema
+		columns[i].Table = table.Name
+	}
+	table.Columns = columns
+
+	return columns, nil
+}
+
+// cassandraKindOrder is the order of the column kinds in a `select *`
+var cassandraKindOrder = map[string]int{"partition_key": 0, "clustering": 1, "static": 2, "regular": 3}
+
+// sortCassandraColumns sorts the column records of system_schema.columns
+// (clustered by column name) into the order of a `select *`
+func sortCassandraColumns(records []map[string]any) []map[string]any {
+	sort.SliceStable(records, func(i, j int) bool {
+		ki, kj := cassandraKindOrder[cast.ToString(records[i]["kind"])], cassandraKindOrder[cast.ToString(records[j]["kind"])]
+		if ki != kj {
+			return ki < kj
+		} else if ki <= 1 {
+			return cast.ToInt(records[i]["position"]) < cast.ToInt(records[j]["position"])
+		}
+		return cast.ToString(records[i]["column_name"]) < cast.ToString(records[j]["column_name"])
+	})
+	return records
+}
+
+// getPartitionKey returns the partition key columns of the table
+func (conn *CassandraConn) getPartitionKey(table Table) (keys []string, err error) {
+	data, err := conn.Self().SubmitTemplate(
+		"single", conn.template.Metadata, "primary_keys",
+		g.M("schema", table.Schema, "table", table.Name),
+	)
+	if err != nil {
+		return nil, g.Error(err, "could not get primary key of %s", table.FullName())
+	}
+
+	for _, rec := range sortCassandraColumns(data.Records()) {
+		if cast.ToString(rec["kind"]) == "partition_key" {
+			keys = append(keys, cast.ToString(rec["column_name"]))
+		}
+	}
+
+	return keys, nil
+}
+
+// GenerateDDL generates the create table statement, with the primary key.
+// The partition key is the `partition` table key (or the first primary key
+// column), the clustering columns are the `cluster` table key (or the other
+// primary key columns).
+func (conn *CassandraConn) GenerateDDL(table Table, data iop.Dataset, temporary bool) (string, error) {
+	ddl, err := conn.BaseConn.GenerateDDL(table, data, temporary)
+	if err != nil || !strings.Contains(ddl, "{primary_key}") {
+		return ddl, err // provided DDL
+	}
+
+	primaryKey, err := cassandraPrimaryKey(table, data.Columns)
+	if err != nil {
+		return "", g.Error(err, "could not generate DDL for %s", table.FullName())
+	}
+
+	return g.R(ddl, "primary_key", primaryKey), nil
+}
+
+// cassandraPrimaryKey returns the primary key definition, e.g. `("a", "b"), "c"`
+func cassandraPrimaryKey(table Table, columns iop.Columns) (string, error) {
+	primaryKey := table.Keys[iop.PrimaryKey]
+	if len(primaryKey) == 0 {
+		for _, col := range columns {
+			if col.Metadata[iop.PrimaryKey.MetadataKey()] != "" {
+				primaryKey = append(primaryKey, col.Name)
+			}
+		}
+	}
+
+	partitionKey := table.Keys[iop.PartitionKey]
+	clusterKey := table.Keys[iop.ClusterKey]
+	if len(partitionKey) == 0 && len(primaryKey) > 0 {
+		partitionKey = primaryKey[:1]
+		if len(clusterKey) == 0 {
+			clusterKey = primaryKey[1:]
+		}
+	}
+
+	if len(partitionKey) == 0 {
+		return "", g.Error("a cassandra table needs a primary key, please specify `primary_key` (or the `partition` table key)")
+	}
+
+	quote := func(names []string) []string {
+		return lo.Map(names, func(name string, i int) string {
+			if col := columns.GetColumn(name); col != nil {
+				name = col.Name
+			}
+			return dbio.TypeDbCassandra.Quote(name)
+		})
+	}
+
+	parts := []string{"(" + strings.Join(quote(partitionKey), ", ") + ")"}
+	parts = append(parts, quote(clusterKey)...)
+
+	return strings.Join(parts, ", "), nil
+}
+
+// BulkImportStream writes the rows with batches of prepared inserts (upserts
+// on the primary key). A batch only holds the rows of one partition, so that
+// it is routed to the replicas of the partition (token-aware), without the
+// coordination of multi-partition batches.
+func (conn *CassandraConn) BulkImportStream(tableFName string, ds *iop.Datastream) (count uint64, err error) {
+	table, err := ParseTableName(tableFName, conn.GetType())
+	if err != nil {
+		return 0, g.Error(err, "could not get table name for import")
+	}
+
+	partitionKey, err := conn.getPartitionKey(table)
+	if err != nil {
+		return 0, err
+	}
+
+	batchSize := cassandraDefBatchSize
+	if val := cast.ToInt(conn.GetProp("batch_size")); val > 0 {
+		batchSize = val
+	}
+
+	var columns iop.Columns
+	for batch := range ds.BatchChan {
+		if batch.ColumnsChanged() || batch.IsFirst() {
+			columns, err = conn.GetColumns(tableFName, batch.Columns.Names()...)
+			if err != nil {
+				return count, g.Error(err, "could not get matching list of columns from table")
+			}
+		}
+
+		insCols, err := conn.ValidateColumnNames(columns, batch.Columns.Names(), false)
+		if err != nil {
+			return count, g.Error(err, "columns mismatch")
+		}
+
+		keyIndexes := []int{}
+		for _, key := range partitionKey {
+			_, index, found := lo.FindIndexOf(insCols, func(col iop.Column) bool {
+				return strings.EqualFold(col.Name, key)
+			})
+			if !found {
+				return count, g.Error("partition key column %s is missing in stream", key)
+			}
+			keyIndexes = append(keyIndexes, index)
+		}
+
+		statement := conn.GenerateInsertStatement(table.FullName(), insCols, 1)
+		batcher := newCassandraBatcher(keyIndexes, batchSize)
+
+		write := func(rows [][]any) error {
+			if len(rows) == 1 {
+				return conn.Session.Query(statement, rows[0]...).WithContext(ds.Context.Ctx).Exec()
+			}
+			b := conn.Session.NewBatch(gocql.UnloggedBatch).WithContext(ds.Context.Ctx)
+			for _, row := range rows {
+				b.Query(statement, row...)
+			}
+			return conn.Session.ExecuteBatch(b)
+		}
+
+		for row := range batch.Rows {
+			values := make([]any, len(insCols))
+			for i, col := range insCols {
+				if i < len(row) {
+					if values[i], err = cassandraBindValue(row[i], col.DbType); err != nil {
+						return count, g.Error(err, "could not convert value for column %s", col.Name)
+					}
+				}
+			}
+
+			for _, rows := range batcher.Add(values) {
+				if err = write(rows); err != nil {
+					return count, g.Error(err, "could not insert into %s", tableFName)
+				}
+				count += uint64(len(rows))
+			}
+		}
+
+		for _, rows := range batcher.Flush() {
+			if err = write(rows); err != nil {
+				return count, g.Error(err, "could not insert into %s", tableFName)
+			}
+			count += uint64(len(rows))
+		}
+	}
+
+	ds.SetEmpty()
+
+	g.Debug("inserted %d rows into %s", count, tableFName)
+	return count, ds.Err()
+}
+
+// InsertBatchStream inserts the stream rows with batches
+func (conn *CassandraConn) InsertBatchStream(tableFName string, ds *iop.Datastream) (count uint64, err error) {
+	return conn.BulkImportStream(tableFName, ds)
+}
+
+// cassandraBatcher groups the rows by partition key, into batches of rows
+// of the same partition. The buffered rows are bounded.
+type cassandraBatcher struct {
+	keyIndexes  []int
+	batchSize   int
+	maxBuffered int
+	groups      map[string][][]any
+	order       []string
+	buffered    int
+}
+
+func newCassandraBatcher(keyIndexes []int, batchSize int) *cassandraBatcher {
+	return &cassandraBatcher{
+		keyIndexes:  keyIndexes,
+		batchSize:   batchSize,
+		maxBuffered: batchSize * 20,
+		groups:      map[string][][]any{},
+	}
+}
+
+// Add buffers the row, and returns the batches ready to be written
+func (b *cassandraBatcher) Add(row []any) (batches [][][]any) {
+	keyValues := make([]any, len(b.keyIndexes))
+	for i, index := range b.keyIndexes {
+		keyValues[i] = row[index]
+	}
+	key := g.Marshal(keyValues)
+
+	if _, ok := b.groups[key]; !ok {
+		b.order = append(b.order, key)
+	}
+	b.groups[key] = append(b.groups[key], row)
+	b.buffered++
+
+	if rows := b.groups[key]; len(rows) >= b.batchSize {
+		batches = append(batches, rows)
+		b.buffered -= len(rows)
+		delete(b.groups, key)
+		b.order = lo.Without(b.order, key)
+	}
+
+	if b.buffered >= b.maxBuffered {
+		batches = append(batches, b.Flush()...)
+	}
+
+	return batches
+}
+
+// Flush returns the batches of all the buffered rows
+func (b *cassandraBatcher) Flush() (batches [][][]any) {
+	for _, key := range b.order {
+		batches = append(batches, b.groups[key])
+	}
+	b.groups = map[string][][]any{}
+	b.order = nil
+	b.buffered = 0
+	return batches
+}
+
+// cassandraBindValue converts the value for the CQL type of the column
+func cassandraBindValue(val any, dbType string) (any, error) {
+	if val == nil {
+		return nil, nil
+	}
+
+	baseType := strings.Split(strings.ToLower(strings.TrimSpace(dbType)), "<")[0]
+	switch baseType {
+	case "int":
+		return cast.ToInt32E(val)
+	case "smallint":
+		return cast.ToInt16E(val)
+	case "tinyint":
+		return cast.ToInt8E(val)
+	case "bigint", "counter":
+		return cast.ToInt64E(val)
+	case "varint":
+		i, ok := new(big.Int).SetString(cast.ToString(val), 10)
+		if !ok {
+			return nil, g.Error("invalid varint: %v", val)
+		}
+		return i, nil
+	case "float":
+		return cast.ToFloat32E(val)
+	case "double":
+		return cast.ToFloat64E(val)
+	case "decimal":
+		d, ok := new(inf.Dec).SetString(cast.ToString(val))
+		if !ok {
+			return nil, g.Error("invalid decimal: %v", val)
+		}
+		return d, nil
+	case "boolean":
+		return cast.ToBoolE(val)
+	case "timestamp", "date":
+		return cast.ToTimeE(val)
+	case "time":
+		t, err := cast.ToTimeE(val)
+		if err != nil {
+			if t, err = time.Parse("15:04:05.999999999", cast.ToString(val)); err != nil {
+				return nil, g.Error(err, "invalid time: %v", val)
+			}
+		}
+		return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+			time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond()), nil
+	case "uuid", "timeuuid":
+		return gocql.ParseUUID(cast.ToString(val))
+	case "blob":
+		if b, ok := val.([]byte); ok {
+			return b, nil
+		}
+		return []byte(cast.ToString(val)), nil
+	case "list", "set", "map", "frozen", "tuple", "udt":
+		// JSON values, into the collection
+		if s, ok := val.(string); ok {
+			var decoded any
+			if err := g.Unmarshal(s, &decoded); err != nil {
+				return nil, g.Error(err, "invalid JSON value for %s column", dbType)
+			}
+			return decoded, nil
+		}
+		return val, nil
+	}
+
+	return cast.ToString(val), nil
+}
+
+// GetSchemata obtain full schemata info for a schema and/or table in current database
+func (conn *CassandraConn) GetSchemata(level SchemataLevel, schemaName string, tableNames ...string) (Schemata, error) {
+	schemata := Schemata{
+		Databases: map[string]Database{},
+		conn:      conn,
+	}
+
+	data, err := conn.GetTables(schemaName)
+	if err != nil {
+		return schemata, g.Error(err, "could not get tables")
+	}
+
+	schemas := map[string]Schema{}
+	for _, rec := range data.Records() {
+		schemaName := cast.ToString(rec["schema_name"])
+		tableName := cast.ToString(rec["table_name"])
+
+		schema, ok := schemas[strings.ToLower(schemaName)]
+		if !ok {
+			schema = Schema{Name: schemaName, Tables: map[string]Table{}}
+		}
+
+		if g.In(level, SchemataLevelTable, SchemataLevelColumn) {
+			if len(tableNames) > 0 && !g.In(tableName, tableNames...) {
+				continue
+			}
+
+			table := Table{
+				Name:     tableName,
+				Schema:   schemaName,
+				Database: conn.Type.String(),
+				Columns:  iop.Columns{},
+				Dialect:  conn.GetType(),
+			}
+
+			if level == SchemataLevelColumn {
+				if table.Columns, err = conn.GetTableColumns(&table); err != nil {
+					return schemata, g.Error(err, "could not get columns of table %s", table.FullName())
+				}
+			}
+
+			schema.Tables[strings.ToLower(tableName)] = table
+		}
+
+		schemas[strings.ToLower(schemaName)] = schema
+	}
+
+	schemata.Databases[strings.ToLower(conn.Type.String())] = Database{
+		Name:    conn.Type.String(),
+		Schemas: schemas,
+	}
+
+	return schemata, nil
+}
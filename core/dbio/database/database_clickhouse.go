@@ -304,8 +304,8 @@ func (conn *ClickhouseConn) GenerateInsertStatement(tableName string, cols iop.C
 }
 
 // GenerateUpsertSQL generates the upsert SQL
-func (conn *ClickhouseConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string) (sql string, err error) {
-	upsertMap, err := conn.BaseConn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields)
+func (conn *ClickhouseConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string, options ...UpsertOptions) (sql string, err error) {
+	upsertMap, err := conn.BaseConn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields, options...)
 	if err != nil {
 		err = g.Error(err, "could not generate upsert variables")
 		return
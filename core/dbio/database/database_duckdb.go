@@ -258,9 +258,9 @@ func (conn *DuckDbConn) generateCsvColumns(columns iop.Columns) (colStr string)
 }
 
 // GenerateUpsertSQL generates the upsert SQL
-func (conn *DuckDbConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string) (sql string, err error) {
+func (conn *DuckDbConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string, options ...UpsertOptions) (sql string, err error) {
 
-	upsertMap, err := conn.BaseConn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields)
+	upsertMap, err := conn.BaseConn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields, options...)
 	if err != nil {
 		err = g.Error(err, "could not generate upsert variables")
 		return
@@ -0,0 +1,553 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/flarco/g"
+	"github.com/flarco/g/net"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// FirestoreConn is a Google Cloud Firestore connection (native mode).
+// The stream is the collection path (e.g. `users`, or `users/{id}/orders`).
+// Documents are flattened into columns (per the `flatten` source option),
+// with the document id in the `_id` column.
+//
+// Connection properties:
+//   - `project`: the project id (defaults to the one of the credentials)
+//   - `database`: the database id (default `(default)`)
+//
+// Source options:
+//   - `where`: a JSON list of `[field, op, value]` conditions, e.g. `[["status", "==", "open"]]`
+//   - `subcollections`: read all the collections with the stream id, at any depth
+//     (collection group), adding the document path in the `_path` column
+type FirestoreConn struct {
+	BaseConn
+	URL        string
+	Client     *firestore.Client
+	ProjectID  string
+	DatabaseID string
+}
+
+const (
+	// FirestoreIDColumn holds the document id
+	FirestoreIDColumn = "_id"
+	// FirestorePathColumn holds the document path, when reading subcollections
+	FirestorePathColumn = "_path"
+)
+
+// firestoreOperators are the operators of the where conditions
+var firestoreOperators = []string{
+	"==", "!=", "<", "<=", ">", ">=",
+	"array-contains", "array-contains-any", "in", "not-in",
+}
+
+// Init initiates the object
+func (conn *FirestoreConn) Init() error {
+	conn.BaseConn.URL = conn.URL
+	conn.BaseConn.Type = dbio.TypeDbFirestore
+
+	u, err := net.NewURL(conn.BaseConn.URL)
+	if err != nil {
+		return g.Error(err, "could not parse firestore url")
+	}
+
+	conn.ProjectID = conn.GetProp("project")
+	if conn.ProjectID == "" {
+		conn.ProjectID = u.U.Host
+	}
+
+	instance := Connection(conn)
+	conn.BaseConn.instance = &instance
+
+	err = conn.BaseConn.Init()
+	if err != nil {
+		err = g.Error(err, "could not initialize connection")
+		return err
+	}
+
+	conn.DatabaseID = conn.GetProp("database")
+	if conn.DatabaseID == "" {
+		conn.DatabaseID = firestore.DefaultDatabaseID
+	}
+
+	if conn.GetProp("GC_KEY_FILE") == "" {
+		conn.SetProp("GC_KEY_FILE", conn.GetProp("keyfile")) // dbt style
+	}
+	if conn.GetProp("GC_KEY_FILE") == "" {
+		conn.SetProp("GC_KEY_FILE", conn.GetProp("credentialsFile"))
+	}
+
+	return nil
+}
+
+func (conn *FirestoreConn) getNewClient(timeOut ...int) (client *firestore.Client, err error) {
+	var authOption option.ClientOption
+	var credJsonBody string
+
+	to := 15
+	if len(timeOut) > 0 {
+		to = timeOut[0]
+	}
+
+	if val := conn.GetProp("GC_KEY_BODY"); val != "" {
+		credJsonBody = val
+		authOption = option.WithCredentialsJSON([]byte(val))
+	} else if val := conn.GetProp("GC_KEY_FILE"); val != "" {
+		authOption = option.WithCredentialsFile(val)
+		b, err := os.ReadFile(val)
+		if err != nil {
+			return client, g.Error(err, "could not read google cloud key file")
+		}
+		credJsonBody = string(b)
+	} else if val := conn.GetProp("GOOGLE_APPLICATION_CREDENTIALS"); val != "" {
+		authOption = option.WithCredentialsFile(val)
+		b, err := os.ReadFile(val)
+		if err != nil {
+			return client, g.Error(err, "could not read google cloud key file")
+		}
+		credJsonBody = string(b)
+	} else if os.Getenv("FIRESTORE_EMULATOR_HOST") != "" {
+		authOption = option.WithoutAuthentication()
+	} else {
+		err = g.Error("no Google credentials provided")
+		return
+	}
+
+	if conn.ProjectID == "" && credJsonBody != "" {
+		m := g.M()
+		g.Unmarshal(credJsonBody, &m)
+		conn.ProjectID = cast.ToString(m["project_id"])
+	}
+
+	ctx, cancel := context.WithTimeout(conn.BaseConn.Context().Ctx, time.Duration(to)*time.Second)
+	defer cancel()
+	return firestore.NewClientWithDatabase(ctx, conn.ProjectID, conn.DatabaseID, authOption)
+}
+
+// Connect connects to the database
+func (conn *FirestoreConn) Connect(timeOut ...int) (err error) {
+	if conn.Client != nil {
+		return nil
+	}
+
+	conn.Client, err = conn.getNewClient(timeOut...)
+	if err != nil {
+		return g.Error(err, "Failed to get client")
+	}
+
+	g.Debug(`opened "%s" connection (%s)`, conn.Type, conn.GetProp("sling_conn_id"))
+
+	return nil
+}
+
+// Close closes the client
+func (conn *FirestoreConn) Close() error {
+	if conn.Client != nil {
+		if err := conn.Client.Close(); err != nil {
+			return g.Error(err, "Failed to close client")
+		}
+		conn.Client = nil
+	}
+	g.Debug(`closed "%s" connection (%s)`, conn.Type, conn.GetProp("sling_conn_id"))
+	return nil
+}
+
+// NewTransaction creates a new transaction
+func (conn *FirestoreConn) NewTransaction(ctx context.Context, options ...*sql.TxOptions) (tx Transaction, err error) {
+	// does not support transaction
+	return
+}
+
+// GetColumnsContext returns the columns with GetColumns, ctx is not used
+func (conn *FirestoreConn) GetColumnsContext(ctx context.Context, tableFName string, fields ...string) (columns iop.Columns, err error) {
+	return conn.GetColumns(tableFName, fields...)
+}
+
+// GetTableColumns samples documents of the collection to infer the columns
+func (conn *FirestoreConn) GetTableColumns(table *Table, fields ...string) (columns iop.Columns, err error) {
+	ds, err := conn.StreamRows(table.Name, g.M("limit", 10, "silent", true))
+	if err != nil {
+		return columns, g.Error(err, "could not query to get columns")
+	}
+
+	data, err := ds.Collect(10)
+	if err != nil {
+		return columns, g.Error(err, "could not collect to get columns")
+	}
+
+	for i := range data.Columns {
+		data.Columns[i].Schema = table.Schema
+		data.Columns[i].Table = table.Name
+		data.Columns[i].DbType = "-"
+	}
+
+	return data.Columns, nil
+}
+
+func (conn *FirestoreConn) ExecContext(ctx context.Context, sql string, args ...interface{}) (result sql.Result, err error) {
+	return nil, g.Error("ExecContext not implemented on FirestoreConn")
+}
+
+func (conn *FirestoreConn) BulkExportFlow(table Table) (df *iop.Dataflow, err error) {
+	options, err := parseFirestoreOptions(table.SQL)
+	if err != nil {
+		return df, err
+	}
+
+	ds, err := conn.StreamRowsContext(conn.Context().Ctx, table.Name, options)
+	if err != nil {
+		return df, g.Error(err, "could start datastream")
+	}
+
+	df, err = iop.MakeDataFlow(ds)
+	if err != nil {
+		return df, g.Error(err, "could start dataflow")
+	}
+
+	return
+}
+
+// StreamRowsContext reads the documents of the collection, filtered with
+// the `where` conditions and the update key (incremental / backfill)
+func (conn *FirestoreConn) StreamRowsContext(ctx context.Context, collection string, Opts ...map[string]interface{}) (ds *iop.Datastream, err error) {
+	opts := getQueryOptions(Opts)
+
+	collection = strings.Trim(strings.TrimSpace(collection), "/")
+	if collection == "" {
+		return ds, g.Error("Empty collection name")
+	}
+
+	subcollections := cast.ToBool(conn.GetProp("subcollections"))
+	query, err := conn.makeQuery(collection, subcollections, opts)
+	if err != nil {
+		return ds, g.Error(err, "could not build query for collection %s", collection)
+	}
+
+	if !cast.ToBool(opts["silent"]) {
+		conn.LogSQL(g.Marshal(g.M("collection", collection, "subcollections", subcollections, "where", conn.GetProp("where"), "options", opts)))
+	}
+
+	queryContext := g.NewContext(ctx)
+	iter := query.Documents(queryContext.Ctx)
+
+	decoder := &firestoreDecoder{iter: iter, withPath: subcollections}
+
+	ds = iop.NewDatastreamContext(queryContext.Ctx, nil)
+
+	flatten := true
+	if val := conn.GetProp("flatten"); val != "" {
+		flatten = cast.ToBool(val)
+	}
+	js := iop.NewJSONStream(ds, decoder, flatten, conn.GetProp("jmespath"))
+	js.HasMapPayload = true
+
+	ds.SetIterator(ds.NewIterator(ds.Columns, js.NextFunc))
+	ds.NoDebug = strings.Contains(collection, noDebugKey)
+	ds.SetMetadata(conn.GetProp("METADATA"))
+	ds.SetConfig(conn.Props())
+	ds.Defer(func() { iter.Stop() })
+
+	err = ds.Start()
+	if err != nil {
+		queryContext.Cancel()
+		return ds, g.Error(err, "could start datastream")
+	}
+
+	return
+}
+
+// makeQuery builds the query of the collection (or collection group) from
+// the `where` property and the options (fields, limit, update key values)
+func (conn *FirestoreConn) makeQuery(collection string, subcollections bool, opts map[string]any) (query firestore.Query, err error) {
+	if subcollections {
+		if strings.Contains(collection, "/") {
+			return query, g.Error("with subcollections, the stream must be a collection id (not a path): %s", collection)
+		}
+		query = conn.Client.CollectionGroup(collection).Query
+	} else {
+		ref := conn.Client.Collection(collection)
+		if ref == nil {
+			return query, g.Error("invalid collection path: %s", collection)
+		}
+		query = ref.Query
+	}
+
+	conditions, err := parseFirestoreWhere(conn.GetProp("where"))
+	if err != nil {
+		return query, err
+	}
+	for _, cond := range conditions {
+		query = query.Where(cond.Field, cond.Op, cond.Value)
+	}
+
+	if updateKey := cast.ToString(opts["update_key"]); updateKey != "" {
+		if val, ok := opts["value"]; ok && val != nil {
+			// incremental mode, or a since / until window bound
+			op := cast.ToString(opts["op"])
+			if op == "" {
+				op = ">"
+			}
+			query = query.Where(updateKey, op, firestoreFilterValue(val))
+		} else if startValue, endValue := opts["start_value"], opts["end_value"]; startValue != nil && endValue != nil {
+			// backfill mode
+			query = query.Where(updateKey, ">=", firestoreFilterValue(startValue)).
+				Where(updateKey, "<=", firestoreFilterValue(endValue))
+		}
+		query = query.OrderBy(updateKey, firestore.Asc)
+	}
+
+	if fields := cast.ToStringSlice(opts["fields"]); len(fields) > 0 {
+		query = query.Select(fields...)
+	}
+
+	if limit := cast.ToInt(opts["limit"]); limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	return query, nil
+}
+
+// firestoreCondition is a condition of the `where` property
+type firestoreCondition struct {
+	Field string
+	Op    string
+	Value any
+}
+
+// parseFirestoreWhere parses the `where` property, a JSON list of
+// `[field, op, value]` conditions
+func parseFirestoreWhere(where string) (conditions []firestoreCondition, err error) {
+	if strings.TrimSpace(where) == "" {
+		return nil, nil
+	}
+
+	var items [][]any
+	if err = g.Unmarshal(where, &items); err != nil {
+		return nil, g.Error(err, `invalid firestore where, expected a JSON list of [field, op, value] conditions, e.g. [["status", "==", "open"]]: %s`, where)
+	}
+
+	for _, item := range items {
+		if len(item) != 3 {
+			return nil, g.Error("invalid firestore where condition, expected [field, op, value]: %s", g.Marshal(item))
+		}
+
+		cond := firestoreCondition{
+			Field: cast.ToString(item[0]),
+			Op:    strings.ToLower(cast.ToString(item[1])),
+			Value: item[2],
+		}
+		if cond.Field == "" {
+			return nil, g.Error("invalid firestore where condition, missing field: %s", g.Marshal(item))
+		} else if !g.In(cond.Op, firestoreOperators...) {
+			return nil, g.Error("invalid firestore where operator '%s', expected one of: %s", cond.Op, strings.Join(firestoreOperators, ", "))
+		}
+		conditions = append(conditions, cond)
+	}
+
+	return conditions, nil
+}
+
+// parseFirestoreOptions parses the query options rendered with the
+// `incremental_where` / `backfill_where` templates, where the values are
+// JSON values (see iop.FormatValue), keeping the numbers exact
+func parseFirestoreOptions(sql string) (opts map[string]any, err error) {
+	if !strings.HasPrefix(strings.TrimSpace(sql), "{") {
+		return map[string]any{}, nil // no incremental / backfill conditions
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(sql))
+	decoder.UseNumber()
+	if err = decoder.Decode(&opts); err != nil {
+		return nil, g.Error(err, "invalid firestore query options: %s", sql)
+	} else if decoder.More() {
+		return nil, g.Error("firestore only supports a single update key condition (e.g. not both --since & --until): %s", sql)
+	}
+	return opts, nil
+}
+
+// firestoreFilterValue converts the JSON value of the update key (see
+// parseFirestoreOptions) into the typed value: a string, a number, or a
+// timestamp (`{"timestamp": "..."}`)
+func firestoreFilterValue(val any) any {
+	switch v := val.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		if t, err := cast.ToTimeE(v["timestamp"]); err == nil {
+			return t
+		}
+	}
+	return val
+}
+
+// firestoreDecoder decodes the documents into records for the JSON stream
+type firestoreDecoder struct {
+	iter     *firestore.DocumentIterator
+	withPath bool
+}
+
+// Decode reads the next document into obj (a map).
+// Returns io.EOF when all documents are read.
+func (d *firestoreDecoder) Decode(obj any) (err error) {
+	doc, err := d.iter.Next()
+	if err == iterator.Done {
+		return io.EOF
+	} else if err != nil {
+		return g.Error(err, "could not read firestore document")
+	}
+
+	m, ok := obj.(*map[string]any)
+	if !ok {
+		return g.Error("invalid decode target: %T", obj)
+	}
+	*m = firestoreRecord(doc.Ref.ID, firestoreRelativePath(doc.Ref.Path), doc.Data(), d.withPath)
+	return nil
+}
+
+// firestoreRecord converts the document data into a record, with the document
+// id (and path). Nested maps & arrays are left to the JSON stream (flatten).
+func firestoreRecord(id, path string, data map[string]any, withPath bool) (record map[string]any) {
+	record = make(map[string]any, len(data)+2)
+	for key, val := range data {
+		record[key] = firestoreValue(val)
+	}
+
+	record[FirestoreIDColumn] = id
+	if withPath {
+		record[FirestorePathColumn] = path
+	}
+
+	return record
+}
+
+// firestoreValue converts the firestore types: references into their
+// document path, and geo points into a latitude / longitude map
+func firestoreValue(val any) any {
+	switch v := val.(type) {
+	case *firestore.DocumentRef:
+		if v == nil {
+			return nil
+		}
+		return firestoreRelativePath(v.Path)
+	case interface {
+		GetLatitude() float64
+		GetLongitude() float64
+	}:
+		return map[string]any{"latitude": v.GetLatitude(), "longitude": v.GetLongitude()}
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for key, val := range v {
+			m[key] = firestoreValue(val)
+		}
+		return m
+	case []any:
+		arr := make([]any, len(v))
+		for i, val := range v {
+			arr[i] = firestoreValue(val)
+		}
+		return arr
+	}
+	return val
+}
+
+// firestoreRelativePath returns the document path relative to the database,
+// e.g. `users/abc` for `projects/p/databases/(default)/documents/users/abc`
+func firestoreRelativePath(path string) string {
+	if _, after, found := strings.Cut(path, "/documents/"); found {
+		return after
+	}
+	return path
+}
+
+// GetSchemas returns the database, as the only schema
+func (conn *FirestoreConn) GetSchemas() (data iop.Dataset, err error) {
+	data = iop.NewDataset(iop.NewColumnsFromFields("schema_name"))
+	data.Append([]interface{}{conn.DatabaseID})
+	return data, nil
+}
+
+// GetTables returns the root collections
+func (conn *FirestoreConn) GetTables(schema string) (data iop.Dataset, err error) {
+	data = iop.NewDataset(iop.NewColumnsFromFields("table_name"))
+
+	queryContext := g.NewContext(conn.Context().Ctx)
+	it := conn.Client.Collections(queryContext.Ctx)
+	for {
+		ref, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return data, g.Error(err, "could not list firestore collections")
+		}
+		data.Append([]interface{}{ref.ID})
+	}
+
+	return data, nil
+}
+
+// GetSchemata obtain full schemata info for a schema and/or table in current database
+func (conn *FirestoreConn) GetSchemata(level SchemataLevel, schemaName string, tableNames ...string) (Schemata, error) {
+	schemata := Schemata{
+		Databases: map[string]Database{},
+		conn:      conn,
+	}
+
+	data, err := conn.GetTables(schemaName)
+	if err != nil {
+		return schemata, err
+	}
+
+	schemaName = conn.DatabaseID
+	schema := Schema{
+		Name:   schemaName,
+		Tables: map[string]Table{},
+	}
+
+	if g.In(level, SchemataLevelTable, SchemataLevelColumn) {
+		for _, row := range data.Rows {
+			tableName := cast.ToString(row[0])
+			if len(tableNames) > 0 && !g.In(tableName, tableNames...) {
+				continue
+			}
+
+			table := Table{
+				Name:     tableName,
+				Schema:   schemaName,
+				Database: conn.Type.String(),
+				Columns:  iop.Columns{},
+				Dialect:  conn.GetType(),
+			}
+
+			if level == SchemataLevelColumn {
+				if table.Columns, err = conn.GetTableColumns(&table); err != nil {
+					return schemata, g.Error(err, "could not get columns of collection %s", tableName)
+				}
+			}
+
+			schema.Tables[strings.ToLower(tableName)] = table
+		}
+	}
+
+	schemata.Databases[strings.ToLower(conn.Type.String())] = Database{
+		Name:    conn.Type.String(),
+		Schemas: map[string]Schema{strings.ToLower(schemaName): schema},
+	}
+
+	return schemata, nil
+}
@@ -0,0 +1,804 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+)
+
+// InfluxDBConn is an InfluxDB connection. Queries are run as InfluxQL
+// (select / show statements) or Flux, with v1 (user/password) or
+// v2 (token) authentication.
+type InfluxDBConn struct {
+	BaseConn
+	URL    string
+	Client *http.Client
+}
+
+// Init initiates the object
+func (conn *InfluxDBConn) Init() error {
+
+	conn.BaseConn.URL = conn.URL
+	conn.BaseConn.Type = dbio.TypeDbInfluxDB
+
+	instance := Connection(conn)
+	conn.BaseConn.instance = &instance
+	return conn.BaseConn.Init()
+}
+
+// Connect connects to the database
+func (conn *InfluxDBConn) Connect(timeOut ...int) error {
+	transport := &http.Transport{}
+
+	// get tls
+	tlsConfig, err := conn.makeTlsConfig()
+	if err != nil {
+		return g.Error(err)
+	} else if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	conn.Client = &http.Client{Transport: transport}
+
+	req, err := conn.newRequest(conn.Context().Ctx, http.MethodGet, "/ping", nil)
+	if err != nil {
+		return g.Error(err, "Failed to connect to client")
+	}
+
+	resp, err := conn.do(req)
+	if err != nil {
+		return g.Error(err, "Failed to connect to client")
+	}
+	resp.Body.Close()
+
+	g.Debug(`opened "%s" connection (%s)`, conn.Type, conn.GetProp("sling_conn_id"))
+
+	return nil
+}
+
+func (conn *InfluxDBConn) Close() error {
+	g.Debug(`closed "%s" connection (%s)`, conn.Type, conn.GetProp("sling_conn_id"))
+	return nil
+}
+
+// NewTransaction creates a new transaction
+func (conn *InfluxDBConn) NewTransaction(ctx context.Context, options ...*sql.TxOptions) (tx Transaction, err error) {
+	// does not support transaction
+	return
+}
+
+func (conn *InfluxDBConn) ExecContext(ctx context.Context, sql string, args ...interface{}) (result sql.Result, err error) {
+	return nil, g.Error("ExecContext not implemented on InfluxDBConn")
+}
+
+// httpURL returns the base url of the http api
+func (conn *InfluxDBConn) httpURL() string {
+	if httpURL := conn.GetProp("http_url"); httpURL != "" {
+		return strings.TrimSuffix(httpURL, "/")
+	}
+
+	scheme := "http"
+	if cast.ToBool(conn.GetProp("ssl")) {
+		scheme = "https"
+	}
+	return g.F("%s://%s:%s", scheme, conn.GetProp("host"), conn.GetProp("port"))
+}
+
+// version returns the api version: 2 when a token is provided, else 1
+func (conn *InfluxDBConn) version() int {
+	if version := cast.ToInt(conn.GetProp("version")); version > 0 {
+		return version
+	} else if conn.GetProp("token") != "" {
+		return 2
+	}
+	return 1
+}
+
+func (conn *InfluxDBConn) newRequest(ctx context.Context, method, path string, body io.Reader) (req *http.Request, err error) {
+	req, err = http.NewRequestWithContext(ctx, method, conn.httpURL()+path, body)
+	if err != nil {
+		return nil, g.Error(err, "could not create request")
+	}
+
+	if token := conn.GetProp("token"); token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	} else if user := conn.GetProp("username", "user"); user != "" {
+		req.SetBasicAuth(user, conn.GetProp("password"))
+	}
+
+	return req, nil
+}
+
+func (conn *InfluxDBConn) do(req *http.Request) (resp *http.Response, err error) {
+	resp, err = conn.Client.Do(req)
+	if err != nil {
+		return nil, g.Error(err, "could not make request to %s", req.URL.Path)
+	}
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, g.Error("unexpected status %d from %s: %s", resp.StatusCode, req.URL.Path, strings.TrimSpace(string(body)))
+	}
+
+	return resp, nil
+}
+
+// isInfluxQL returns true if the query is InfluxQL, otherwise it is Flux
+func isInfluxQL(query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	return strings.HasPrefix(query, "select") || strings.HasPrefix(query, "show")
+}
+
+// noopWhereRegex matches the always-true conditions set by sling when
+// not running incrementally, which InfluxQL does not support
+var noopWhereRegex = regexp.MustCompile(`(?i)\s+(where\s+1=1\s+and|where\s+1=1|and\s+1=1)\b`)
+
+// fluxQuery returns the Flux query to run: pivoted, so that the fields of a
+// point are in one row, and limited when a limit is set. Queries which
+// already pivot, limit or yield their results are kept as is.
+func fluxQuery(query string, limit int) string {
+	if strings.Contains(query, "yield(") {
+		return query
+	}
+
+	query = strings.TrimSpace(query)
+	if limit > 0 && !strings.Contains(query, "limit(") {
+		query = query + g.F("\n  |> limit(n: %d)", limit)
+	}
+	if !strings.Contains(query, "pivot(") && !strings.Contains(query, "fieldsAsCols(") {
+		query = query + "\n  |> pivot(rowKey: [\"_time\"], columnKey: [\"_field\"], valueColumn: \"_value\")"
+	}
+	return query
+}
+
+// query runs the query and returns a reader of the points of the result,
+// streamed from the response: chunked JSON for InfluxQL, annotated CSV for Flux
+func (conn *InfluxDBConn) query(ctx context.Context, query string, limit int) (reader influxReader, err error) {
+	var req *http.Request
+
+	influxQL := isInfluxQL(query)
+	if influxQL {
+		query = noopWhereRegex.ReplaceAllStringFunc(query, func(m string) string {
+			if strings.HasSuffix(strings.ToLower(m), "and") {
+				return " where"
+			}
+			return ""
+		})
+
+		database := conn.GetProp("database")
+		if database == "" {
+			database = conn.GetProp("bucket")
+		}
+		params := url.Values{"q": {query}, "db": {database}, "epoch": {"ns"}, "chunked": {"true"}}
+		if rp := conn.GetProp("retention_policy"); rp != "" {
+			params.Set("rp", rp)
+		}
+
+		req, err = conn.newRequest(ctx, http.MethodPost, "/query", strings.NewReader(params.Encode()))
+		if err != nil {
+			return nil, g.Error(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		if conn.version() < 2 {
+			return nil, g.Error("Flux queries require InfluxDB v2 (with a token)")
+		}
+
+		query = fluxQuery(query, limit)
+		payload := g.M(
+			"query", query,
+			"type", "flux",
+			"dialect", g.M("header", true, "annotations", []string{"group", "datatype", "default"}),
+		)
+
+		path := "/api/v2/query?" + url.Values{"org": {conn.GetProp("org")}}.Encode()
+		req, err = conn.newRequest(ctx, http.MethodPost, path, bytes.NewReader([]byte(g.Marshal(payload))))
+		if err != nil {
+			return nil, g.Error(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/csv")
+	}
+
+	g.Trace("influxdb query: %s", query)
+
+	resp, err := conn.do(req)
+	if err != nil {
+		return nil, g.Error(err, "could not run query")
+	}
+
+	if influxQL {
+		return newInfluxQLReader(resp.Body), nil
+	}
+	return newInfluxFluxReader(resp.Body), nil
+}
+
+func (conn *InfluxDBConn) BulkExportFlow(table Table) (df *iop.Dataflow, err error) {
+	sql := table.SQL
+	if sql == "" {
+		sql = table.Select(0, 0)
+	}
+
+	ds, err := conn.StreamRowsContext(conn.Context().Ctx, sql)
+	if err != nil {
+		return df, g.Error(err, "could start datastream")
+	}
+
+	df, err = iop.MakeDataFlow(ds)
+	if err != nil {
+		return df, g.Error(err, "could start dataflow")
+	}
+
+	return
+}
+
+func (conn *InfluxDBConn) StreamRowsContext(ctx context.Context, query string, Opts ...map[string]interface{}) (ds *iop.Datastream, err error) {
+	opts := getQueryOptions(Opts)
+	Limit := int(0) // infinite
+	if val := cast.ToInt(opts["limit"]); val > 0 {
+		Limit = val
+	}
+
+	if strings.TrimSpace(query) == "" {
+		g.Warn("Empty query")
+		return ds, nil
+	}
+
+	queryContext := g.NewContext(ctx)
+
+	reader, err := conn.query(queryContext.Ctx, query, Limit)
+	if err != nil {
+		queryContext.Cancel()
+		return ds, g.Error(err, "could not query")
+	}
+
+	ds = newInfluxStream(queryContext.Ctx, reader, Limit)
+	ds.NoDebug = strings.Contains(query, noDebugKey)
+	ds.SetMetadata(conn.GetProp("METADATA"))
+	ds.SetConfig(conn.Props())
+
+	err = ds.Start()
+	if err != nil {
+		queryContext.Cancel()
+		return ds, g.Error(err, "could start datastream")
+	}
+
+	return ds, nil
+}
+
+func (conn *InfluxDBConn) GetSQLColumns(table Table) (columns iop.Columns, err error) {
+	sql := table.SQL
+	if sql == "" {
+		sql = table.Select(10, 0)
+	}
+
+	ds, err := conn.StreamRows(sql, g.M("limit", 10))
+	if err != nil {
+		return columns, g.Error(err, "could not query to get columns")
+	}
+
+	data, err := ds.Collect(10)
+	if err != nil {
+		return columns, g.Error(err, "could not collect to get columns")
+	}
+
+	return data.Columns, nil
+}
+
+// GetColumnsContext returns the columns with GetColumns, ctx is not used
+func (conn *InfluxDBConn) GetColumnsContext(ctx context.Context, tableFName string, fields ...string) (columns iop.Columns, err error) {
+	return conn.GetColumns(tableFName, fields...)
+}
+
+func (conn *InfluxDBConn) GetTableColumns(table *Table, fields ...string) (columns iop.Columns, err error) {
+	return conn.GetSQLColumns(Table{Name: table.Name, Schema: table.Schema, Dialect: conn.GetType()})
+}
+
+// GetSchemas returns schemas
+func (conn *InfluxDBConn) GetSchemas() (data iop.Dataset, err error) {
+	data = iop.NewDataset(iop.NewColumnsFromFields("schema_name"))
+	data.Append([]interface{}{conn.GetProp("database")})
+	return data, nil
+}
+
+// GetTables returns the measurements
+func (conn *InfluxDBConn) GetTables(schema string) (data iop.Dataset, err error) {
+	ds, err := conn.StreamRowsContext(conn.Context().Ctx, "show measurements")
+	if err != nil {
+		return data, g.Error(err, "could not get measurements")
+	}
+
+	result, err := ds.Collect(0)
+	if err != nil {
+		return data, g.Error(err, "could not get measurements")
+	}
+
+	data = iop.NewDataset(iop.NewColumnsFromFields("table_name"))
+	if col := result.Columns.GetColumn("name"); col != nil {
+		for _, row := range result.Rows {
+			data.Append([]interface{}{row[col.Position-1]})
+		}
+	}
+	return data, nil
+}
+
+// GetSchemata obtain full schemata info for a schema and/or table in current database
+func (conn *InfluxDBConn) GetSchemata(level SchemataLevel, schemaName string, tableNames ...string) (Schemata, error) {
+	database := conn.GetProp("database")
+	schemata := Schemata{
+		Databases: map[string]Database{},
+		conn:      conn,
+	}
+
+	schema := Schema{
+		Name:   database,
+		Tables: map[string]Table{},
+	}
+
+	if g.In(level, SchemataLevelTable, SchemataLevelColumn) {
+		tablesData, err := conn.GetTables(schemaName)
+		if err != nil {
+			return schemata, g.Error(err, "Could not get measurements")
+		}
+
+		for _, row := range tablesData.Rows {
+			tableName := cast.ToString(row[0])
+			if len(tableNames) > 0 && !g.In(tableName, tableNames...) {
+				continue
+			}
+
+			table := Table{
+				Name:     tableName,
+				Schema:   database,
+				Database: database,
+				Columns:  iop.Columns{},
+				Dialect:  conn.GetType(),
+			}
+
+			if level == SchemataLevelColumn {
+				table.Columns, err = conn.GetTableColumns(&table)
+				if err != nil {
+					return schemata, g.Error(err, "Could not get columns of %s", tableName)
+				}
+				for i := range table.Columns {
+					table.Columns[i].Table = tableName
+					table.Columns[i].Schema = database
+					table.Columns[i].Database = database
+				}
+			}
+
+			schema.Tables[strings.ToLower(tableName)] = table
+		}
+	}
+
+	schemata.Databases[strings.ToLower(database)] = Database{
+		Name:    database,
+		Schemas: map[string]Schema{strings.ToLower(schema.Name): schema},
+	}
+
+	return schemata, nil
+}
+
+// influxPoint is a point of a series: its fields at a time.
+// Results without time (such as of `show` statements) only have fields.
+type influxPoint struct {
+	Time        time.Time
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]any
+	Timeless    bool
+}
+
+// influxReader reads the points of a query result, one at a time.
+// Next returns io.EOF once all points are read.
+type influxReader interface {
+	Next() (p *influxPoint, err error)
+	Close() error
+}
+
+// influxStream maps the points of the reader into the rows of a datastream.
+// The columns are `time`, `measurement`, then the tags and the fields, sorted
+// by name, added as they appear in the points.
+type influxStream struct {
+	ds     *iop.Datastream
+	reader influxReader
+	colIdx map[string]int
+}
+
+// newInfluxStream returns a datastream of the points of the reader, up to the limit
+func newInfluxStream(ctx context.Context, reader influxReader, limit int) *iop.Datastream {
+	ds := iop.NewDatastreamContext(ctx, nil)
+	is := &influxStream{ds: ds, reader: reader, colIdx: map[string]int{}}
+
+	nextFunc := func(it *iop.Iterator) bool {
+		if limit > 0 && it.Counter >= cast.ToUint64(limit) {
+			return false
+		} else if it.Context.Err() != nil {
+			return false
+		}
+
+		p, err := is.reader.Next()
+		if err == io.EOF {
+			return false
+		} else if err != nil {
+			it.Context.CaptureErr(g.Error(err, "could not read InfluxDB result"))
+			return false
+		}
+
+		it.Row = is.row(p)
+		return true
+	}
+
+	ds.SetIterator(ds.NewIterator(ds.Columns, nextFunc))
+	ds.Defer(func() { reader.Close() }) // close response when done
+
+	return ds
+}
+
+// row returns the row of the point, adding the columns of new tags & fields
+func (is *influxStream) row(p *influxPoint) []any {
+	newCols := iop.Columns{}
+	addColumn := func(name string, colType iop.ColumnType) {
+		if _, ok := is.colIdx[name]; ok {
+			return
+		}
+		is.colIdx[name] = len(is.ds.Columns) + len(newCols)
+		newCols = append(newCols, iop.Column{Name: name, Type: colType, Position: is.colIdx[name] + 1})
+	}
+
+	if !p.Timeless {
+		addColumn("time", iop.TimestampzType)
+		addColumn("measurement", iop.StringType)
+	}
+
+	tagNames := lo.Keys(p.Tags)
+	sort.Strings(tagNames)
+	for _, name := range tagNames {
+		addColumn(name, iop.StringType)
+	}
+
+	fieldNames := lo.Keys(p.Fields)
+	sort.Strings(fieldNames)
+	for _, name := range fieldNames {
+		addColumn(name, lo.Ternary(influxValueType(p.Fields[name]) == "", iop.StringType, influxValueType(p.Fields[name])))
+	}
+
+	if len(newCols) > 0 {
+		mux := is.ds.Context.Mux
+		if df := is.ds.Df(); df != nil {
+			mux = df.Context.Mux
+		}
+		mux.Lock()
+		is.ds.AddColumns(newCols, false)
+		mux.Unlock()
+	}
+
+	row := make([]any, len(is.ds.Columns))
+	if !p.Timeless {
+		row[is.colIdx["time"]] = p.Time
+		row[is.colIdx["measurement"]] = p.Measurement
+	}
+	for name, val := range p.Tags {
+		row[is.colIdx[name]] = val
+	}
+	for name, val := range p.Fields {
+		if _, isTag := p.Tags[name]; !isTag {
+			row[is.colIdx[name]] = val
+		}
+	}
+	return row
+}
+
+// influxValueType returns the column type of a field value
+func influxValueType(val any) iop.ColumnType {
+	switch val.(type) {
+	case nil:
+		return ""
+	case bool:
+		return iop.BoolType
+	case int, int64, uint64:
+		return iop.BigIntType
+	case float64:
+		return iop.FloatType
+	case time.Time:
+		return iop.TimestampzType
+	}
+	return iop.StringType
+}
+
+// influxQLResult is a JSON response (or chunk) of the /query endpoint
+type influxQLResult struct {
+	Results []struct {
+		StatementID int    `json:"statement_id"`
+		Error       string `json:"error"`
+		Series      []struct {
+			Name    string            `json:"name"`
+			Tags    map[string]string `json:"tags"`
+			Columns []string          `json:"columns"`
+			Values  [][]any           `json:"values"`
+		} `json:"series"`
+	} `json:"results"`
+	Error string `json:"error"`
+}
+
+// points returns the points of the series of the result
+func (result *influxQLResult) points() (points []*influxPoint, err error) {
+	if result.Error != "" {
+		return nil, g.Error("InfluxQL error: %s", result.Error)
+	}
+
+	for _, res := range result.Results {
+		if res.Error != "" {
+			return nil, g.Error("InfluxQL error (statement %d): %s", res.StatementID, res.Error)
+		}
+
+		for _, series := range res.Series {
+			timeIndex := lo.IndexOf(series.Columns, "time")
+
+			for _, values := range series.Values {
+				p := &influxPoint{
+					Measurement: series.Name,
+					Tags:        map[string]string{},
+					Fields:      map[string]any{},
+					Timeless:    timeIndex == -1, // such as `show measurements`
+				}
+				for k, v := range series.Tags {
+					p.Tags[k] = v
+				}
+
+				for i, val := range values {
+					if i >= len(series.Columns) {
+						break
+					}
+					if i == timeIndex {
+						p.Time, err = influxParseTime(val)
+						if err != nil {
+							return nil, g.Error(err, "could not parse time")
+						}
+						continue
+					}
+					p.Fields[series.Columns[i]] = influxJSONValue(val)
+				}
+				points = append(points, p)
+			}
+		}
+	}
+
+	return points, nil
+}
+
+// influxQLReader reads the points of the chunked JSON result of an InfluxQL
+// query, one chunk at a time
+type influxQLReader struct {
+	body    io.ReadCloser
+	decoder *json.Decoder
+	points  []*influxPoint
+}
+
+func newInfluxQLReader(body io.ReadCloser) *influxQLReader {
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+	return &influxQLReader{body: body, decoder: decoder}
+}
+
+// Next returns the next point, decoding the next chunk when needed
+func (r *influxQLReader) Next() (p *influxPoint, err error) {
+	for len(r.points) == 0 {
+		result := influxQLResult{}
+		if err = r.decoder.Decode(&result); err == io.EOF {
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, g.Error(err, "could not decode InfluxQL result")
+		}
+
+		if r.points, err = result.points(); err != nil {
+			return nil, err
+		}
+	}
+
+	p, r.points = r.points[0], r.points[1:]
+	return p, nil
+}
+
+func (r *influxQLReader) Close() error {
+	return r.body.Close()
+}
+
+// influxParseTime parses the time of a point, in epoch nanoseconds or RFC3339
+func influxParseTime(val any) (t time.Time, err error) {
+	switch v := val.(type) {
+	case json.Number:
+		ns, err := v.Int64()
+		if err != nil {
+			return t, g.Error(err, "invalid epoch: %s", v)
+		}
+		return time.Unix(0, ns).UTC(), nil
+	case string:
+		t, err = time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return t, g.Error(err, "invalid timestamp: %s", v)
+		}
+		return t.UTC(), nil
+	}
+	return t, g.Error("invalid time value: %#v", val)
+}
+
+// influxJSONValue converts json numbers to int64 or float64
+func influxJSONValue(val any) any {
+	if num, ok := val.(json.Number); ok {
+		if i, err := num.Int64(); err == nil && !strings.ContainsAny(num.String(), ".eE") {
+			return i
+		}
+		f, _ := num.Float64()
+		return f
+	}
+	return val
+}
+
+// influxFluxReader reads the points of the annotated CSV result of a Flux
+// query, one row at a time. The `_start`, `_stop`, `result` & `table`
+// columns are dropped, and the other columns of the group key are tags.
+// Non-group columns (such as pivoted fields) are fields, and the `_value`
+// of an unpivoted row is the field named by `_field`.
+type influxFluxReader struct {
+	body   io.ReadCloser
+	reader *csv.Reader
+
+	header, datatypes, groups, defaults []string
+}
+
+func newInfluxFluxReader(body io.ReadCloser) *influxFluxReader {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	return &influxFluxReader{body: body, reader: reader}
+}
+
+// Next returns the point of the next data row
+func (r *influxFluxReader) Next() (p *influxPoint, err error) {
+	for {
+		record, err := r.reader.Read()
+		if err == io.EOF {
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, g.Error(err, "could not read Flux result")
+		}
+
+		if len(record) == 0 {
+			continue
+		}
+
+		switch record[0] {
+		case "#datatype":
+			r.datatypes, r.header = record, nil
+			continue
+		case "#group":
+			r.groups, r.header = record, nil
+			continue
+		case "#default":
+			r.defaults, r.header = record, nil
+			continue
+		}
+
+		if r.header == nil {
+			r.header = record
+			continue
+		}
+
+		// error table, in the result
+		if idx := lo.IndexOf(r.header, "error"); idx > -1 && !lo.Contains(r.header, "_time") {
+			if idx < len(record) && record[idx] != "" {
+				return nil, g.Error("Flux error: %s", record[idx])
+			}
+			continue
+		}
+
+		return r.point(record)
+	}
+}
+
+// point returns the point of a data row
+func (r *influxFluxReader) point(record []string) (p *influxPoint, err error) {
+	p = &influxPoint{Tags: map[string]string{}, Fields: map[string]any{}}
+	field := ""
+	var value any
+
+	for i, col := range r.header {
+		if i >= len(record) {
+			break
+		}
+
+		raw := record[i]
+		if raw == "" && i < len(r.defaults) {
+			raw = r.defaults[i]
+		}
+
+		datatype := ""
+		if i < len(r.datatypes) {
+			datatype = r.datatypes[i]
+		}
+
+		switch col {
+		case "", "result", "table", "_start", "_stop":
+			continue
+		case "_time":
+			if raw != "" {
+				p.Time, err = time.Parse(time.RFC3339Nano, raw)
+				if err != nil {
+					return nil, g.Error(err, "invalid _time: %s", raw)
+				}
+				p.Time = p.Time.UTC()
+			}
+			continue
+		case "_measurement":
+			p.Measurement = raw
+			continue
+		case "_field":
+			field = raw
+			continue
+		}
+
+		val, err := influxFluxValue(raw, datatype)
+		if err != nil {
+			return nil, g.Error(err, "invalid value for %s", col)
+		}
+
+		if col == "_value" {
+			value = val
+		} else if i < len(r.groups) && r.groups[i] == "true" {
+			p.Tags[col] = raw
+		} else {
+			p.Fields[col] = val
+		}
+	}
+
+	if field != "" {
+		p.Fields[field] = value
+	} else if value != nil {
+		p.Fields["_value"] = value
+	}
+
+	return p, nil
+}
+
+func (r *influxFluxReader) Close() error {
+	return r.body.Close()
+}
+
+// influxFluxValue parses the value of the annotated CSV datatype
+func influxFluxValue(raw, datatype string) (val any, err error) {
+	if raw == "" && datatype != "string" {
+		return nil, nil
+	}
+
+	switch {
+	case datatype == "long", datatype == "unsignedLong":
+		return cast.ToInt64E(raw)
+	case datatype == "double":
+		return cast.ToFloat64E(raw)
+	case datatype == "boolean":
+		return cast.ToBoolE(raw)
+	case strings.HasPrefix(datatype, "dateTime"):
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return nil, err
+		}
+		return t.UTC(), nil
+	}
+	return raw, nil
+}
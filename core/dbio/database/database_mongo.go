@@ -111,6 +111,11 @@ func (conn *MongoDBConn) NewTransaction(ctx context.Context, options ...*sql.TxO
 	return
 }
 
+// GetColumnsContext returns the columns with GetColumns, ctx is not used
+func (conn *MongoDBConn) GetColumnsContext(ctx context.Context, tableFName string, fields ...string) (columns iop.Columns, err error) {
+	return conn.GetColumns(tableFName, fields...)
+}
+
 // NewTransaction creates a new transaction
 func (conn *MongoDBConn) GetTableColumns(table *Table, fields ...string) (columns iop.Columns, err error) {
 	tables, err := conn.GetTables(table.Schema)
@@ -1,17 +1,27 @@
 package database
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
+	"path"
+	"regexp"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/env"
 
 	"github.com/flarco/g"
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
 	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
 	"github.com/xo/dburl"
 )
 
@@ -36,9 +46,10 @@ func (conn *MySQLConn) Init() error {
 	// the LoadDataOutFile needs special circumstances
 	conn.BaseConn.SetProp("allow_bulk_export", "false")
 
-	// InsertBatchStream is faster than LoadDataInFile
+	// LOAD DATA LOCAL INFILE is used when the server allows it (local_infile=ON),
+	// otherwise batched inserts. Set allow_bulk_import=false to always insert.
 	if conn.BaseConn.GetProp("allow_bulk_import") == "" {
-		conn.BaseConn.SetProp("allow_bulk_import", "false")
+		conn.BaseConn.SetProp("allow_bulk_import", "true")
 	}
 
 	instance := Connection(conn)
@@ -164,11 +175,10 @@ func (conn *MySQLConn) BulkExportStream(table Table) (ds *iop.Datastream, err er
 
 // BulkImportStream bulk import stream
 func (conn *MySQLConn) BulkImportStream(tableFName string, ds *iop.Datastream) (count uint64, err error) {
-	_, err = exec.LookPath("mysql")
-	if err != nil {
-		g.Trace("mysql not found in path. Using cursor...")
+	if conn.GetProp("allow_bulk_import") != "true" {
 		return conn.BaseConn.InsertBatchStream(tableFName, ds)
-	} else if conn.GetProp("allow_bulk_import") != "true" {
+	} else if !conn.LocalInfileEnabled() {
+		g.Debug("local_infile is disabled on the server, using batched inserts")
 		return conn.BaseConn.InsertBatchStream(tableFName, ds)
 	}
 
@@ -185,7 +195,291 @@ func (conn *MySQLConn) BulkImportStream(tableFName string, ds *iop.Datastream) (
 		return
 	}
 
-	return conn.LoadDataInFile(tableFName, ds)
+	return conn.LoadDataLocalInfile(tableFName, ds)
+}
+
+// LocalInfileEnabled returns true if the server allows `LOAD DATA LOCAL INFILE`
+// (the `local_infile` system variable). The value is cached on the connection.
+func (conn *MySQLConn) LocalInfileEnabled() bool {
+	if val := conn.GetProp("local_infile"); val != "" {
+		return cast.ToBool(val)
+	}
+
+	enabled := false
+	data, err := conn.Query("select @@global.local_infile as local_infile" + noDebugKey)
+	if err != nil {
+		g.Debug("could not get local_infile value: %s", err.Error())
+	} else if len(data.Rows) > 0 {
+		enabled = cast.ToBool(data.Rows[0][0])
+	}
+
+	conn.SetProp("local_infile", cast.ToString(enabled))
+	return enabled
+}
+
+// loadDataRefusedRegex matches the errors of a refused `LOAD DATA LOCAL INFILE`
+var loadDataRefusedRegex = regexp.MustCompile(`(?i)Error (1148|2068|3948)\b|local file .* is not registered`)
+
+// LoadDataLocalInfile stages the stream to a local temp csv file, and loads
+// it with `LOAD DATA LOCAL INFILE`. The file is registered with the driver
+// (no need for allowAllFiles), and removed once loaded. If the statement is
+// refused, the staged rows are inserted in batches.
+func (conn *MySQLConn) LoadDataLocalInfile(tableFName string, ds *iop.Datastream) (count uint64, err error) {
+	folder := path.Join(env.GetTempFolder(), "mysql", env.CleanTableName(tableFName), g.NowFileStr())
+	if err = os.MkdirAll(folder, 0755); err != nil {
+		return 0, g.Error(err, "could not create temp folder: %s", folder)
+	}
+	defer env.RemoveAllLocalTempFile(folder)
+
+	filePath := path.Join(folder, "data.csv")
+	if err = writeLoadDataFile(filePath, ds, conn.loadDataLocation()); err != nil {
+		ds.Context.CaptureErr(err)
+		return 0, g.Error(err, "could not write load data file: %s", filePath)
+	} else if ds.Err() != nil {
+		return 0, g.Error(ds.Err(), "could not read stream")
+	} else if ds.Count == 0 {
+		return 0, nil
+	}
+
+	mysql.RegisterLocalFile(filePath)
+	defer mysql.DeregisterLocalFile(filePath)
+
+	loaded, warnings, err := conn.execLoadData(conn.LoadDataSQL(tableFName, filePath, ds.Columns))
+	if err != nil && loadDataRefusedRegex.MatchString(err.Error()) {
+		g.Warn("LOAD DATA LOCAL INFILE was refused for %s, using batched inserts: %s", tableFName, err.Error())
+		conn.SetProp("local_infile", "false") // do not retry
+
+		fileDs, err := readLoadDataFile(conn.Context().Ctx, filePath, ds.Columns)
+		if err != nil {
+			return 0, g.Error(err, "could not read load data file: %s", filePath)
+		}
+		return conn.BaseConn.InsertBatchStream(tableFName, fileDs)
+	} else if err != nil {
+		return 0, g.Error(err, "could not load data into %s", tableFName)
+	}
+
+	// LOAD DATA LOCAL has IGNORE semantics: rows with duplicate keys are
+	// skipped and conversion errors are warnings, instead of failing
+	if len(warnings) > 0 {
+		return 0, g.Error("LOAD DATA into %s raised warnings (skipped or converted rows):\n%s", tableFName, strings.Join(warnings, "\n"))
+	} else if uint64(loaded) != ds.Count {
+		return 0, g.Error("LOAD DATA loaded %d of %d rows into %s (duplicate keys are skipped)", loaded, ds.Count, tableFName)
+	}
+
+	return ds.Count, nil
+}
+
+// loadDataMaxWarnings is the number of warnings reported of a LOAD DATA statement
+const loadDataMaxWarnings = 10
+
+// execLoadData executes the LOAD DATA statement, returning the number of rows
+// loaded and its warnings, read on the same session
+func (conn *MySQLConn) execLoadData(sql string) (loaded int64, warnings []string, err error) {
+	ctx := conn.Context().Ctx
+
+	type rowsScanner interface {
+		Next() bool
+		Scan(dest ...any) error
+		Close() error
+	}
+
+	var result interface{ RowsAffected() (int64, error) }
+	var query func(q string) (rowsScanner, error)
+	if tx := conn.Tx(); tx != nil {
+		if result, err = tx.ExecContext(ctx, sql); err != nil {
+			return 0, nil, err
+		}
+		query = func(q string) (rowsScanner, error) { return tx.QueryContext(ctx, q) }
+	} else {
+		session, err := conn.Db().DB.Conn(ctx)
+		if err != nil {
+			return 0, nil, g.Error(err, "could not open session")
+		}
+		defer session.Close()
+
+		conn.LogSQL(sql)
+		if result, err = session.ExecContext(ctx, sql); err != nil {
+			return 0, nil, err
+		}
+		query = func(q string) (rowsScanner, error) { return session.QueryContext(ctx, q) }
+	}
+
+	loaded, _ = result.RowsAffected()
+
+	rows, err := query(g.F("SHOW WARNINGS LIMIT %d", loadDataMaxWarnings))
+	if err != nil {
+		return loaded, nil, g.Error(err, "could not read the warnings of LOAD DATA")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var level, message string
+		var code int
+		if err = rows.Scan(&level, &code, &message); err != nil {
+			return loaded, nil, g.Error(err, "could not read the warnings of LOAD DATA")
+		}
+		warnings = append(warnings, g.F("  - %s %d: %s", level, code, message))
+	}
+
+	return loaded, warnings, nil
+}
+
+// loadDataLocation returns the location of the datetime values of the
+// session, the `loc` param of the driver (UTC by default)
+func (conn *MySQLConn) loadDataLocation() *time.Location {
+	if val := conn.GetProp("loc"); val != "" {
+		if loc, err := time.LoadLocation(val); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// loadDataPathEscaper escapes the file path for the string literal, where
+// backslashes (of Windows paths) are escape characters
+var loadDataPathEscaper = strings.NewReplacer(`\`, `\\`, `'`, `''`)
+
+// LoadDataSQL returns the `LOAD DATA LOCAL INFILE` statement loading the file
+// written by writeLoadDataFile: values are enclosed with double quotes and
+// escaped with backslashes, nulls are `\N`
+func (conn *MySQLConn) LoadDataSQL(tableFName, filePath string, columns iop.Columns) string {
+	fields := lo.Map(columns.Names(), func(name string, i int) string { return conn.Quote(name) })
+	return g.R(
+		`LOAD DATA LOCAL INFILE '{file}' INTO TABLE {table} CHARACTER SET utf8mb4 FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '"' ESCAPED BY '\\' LINES TERMINATED BY '\n' ({fields})`,
+		"file", loadDataPathEscaper.Replace(filePath),
+		"table", tableFName,
+		"fields", strings.Join(fields, ", "),
+	)
+}
+
+// writeLoadDataFile writes the stream rows to a csv file for LOAD DATA
+func writeLoadDataFile(filePath string, ds *iop.Datastream, loc *time.Location) (err error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return g.Error(err, "could not create file")
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	for row := range ds.Rows() {
+		for i, val := range row {
+			if i > 0 {
+				w.WriteString(",")
+			}
+			colType := iop.ColumnType("")
+			if i < len(ds.Columns) {
+				colType = ds.Columns[i].Type
+			}
+			w.WriteString(loadDataValue(val, colType, loc))
+		}
+		if _, err = w.WriteString("\n"); err != nil {
+			return g.Error(err, "could not write row")
+		}
+	}
+
+	if err = w.Flush(); err != nil {
+		return g.Error(err, "could not flush file")
+	}
+	return nil
+}
+
+// loadDataEscaper escapes the values for `ESCAPED BY '\\'`
+var loadDataEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\x00", `\0`,
+	"\n", `\n`,
+	"\r", `\r`,
+)
+
+// loadDataValue returns the value as a LOAD DATA csv field, `\N` for nulls,
+// to keep them distinct from empty strings. Timestamps with a time zone are
+// converted to the session location like the driver does, other dates and
+// datetimes keep their wall clock.
+func loadDataValue(val any, colType iop.ColumnType, loc *time.Location) string {
+	var str string
+	switch v := val.(type) {
+	case nil:
+		return `\N`
+	case time.Time:
+		if colType.IsDate() {
+			str = v.Format("2006-01-02")
+		} else if colType == iop.TimestampzType {
+			str = v.In(loc).Format("2006-01-02 15:04:05.999999")
+		} else {
+			str = v.Format("2006-01-02 15:04:05.999999")
+		}
+	case bool:
+		str = lo.Ternary(v, "1", "0")
+	case []byte:
+		str = string(v)
+	default:
+		str = cast.ToString(v)
+	}
+	return `"` + loadDataEscaper.Replace(str) + `"`
+}
+
+// loadDataUnescaper reverts loadDataEscaper
+var loadDataUnescaper = strings.NewReplacer(
+	`\\`, `\`,
+	`\"`, `"`,
+	`\0`, "\x00",
+	`\n`, "\n",
+	`\r`, "\r",
+)
+
+// readLoadDataFile streams the rows of a file written by writeLoadDataFile
+func readLoadDataFile(ctx context.Context, filePath string, columns iop.Columns) (ds *iop.Datastream, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, g.Error(err, "could not open file")
+	}
+	reader := bufio.NewReader(file)
+
+	nextFunc := func(it *iop.Iterator) bool {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return false
+		} else if err != nil && err != io.EOF {
+			it.Context.CaptureErr(g.Error(err, "could not read load data file"))
+			return false
+		}
+		it.Row = parseLoadDataLine(strings.TrimSuffix(line, "\n"))
+		return true
+	}
+
+	ds = iop.NewDatastreamIt(ctx, columns, nextFunc)
+	ds.Inferred = true
+	ds.Defer(func() { file.Close() })
+	if err = ds.Start(); err != nil {
+		file.Close()
+		return nil, g.Error(err, "could not start datastream")
+	}
+	return ds, nil
+}
+
+// parseLoadDataLine parses the fields of a line written by writeLoadDataFile:
+// `\N` or a quoted value, escaped with backslashes
+func parseLoadDataLine(line string) (row []any) {
+	for len(line) > 0 {
+		if strings.HasPrefix(line, `\N`) {
+			row = append(row, nil)
+			line = strings.TrimPrefix(line[2:], ",")
+			continue
+		}
+
+		// quoted value, ending at the first unescaped quote
+		end := 1
+		for end < len(line) && line[end] != '"' {
+			if line[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		row = append(row, loadDataUnescaper.Replace(line[1:min(end, len(line))]))
+		line = strings.TrimPrefix(line[min(end+1, len(line)):], ",")
+	}
+	return row
 }
 
 // LoadDataOutFile Bulk Export
@@ -243,62 +537,12 @@ func (conn *MySQLConn) LoadDataOutFile(ctx *g.Context, sql string) (stdOutReader
 	return stdOutReader, err
 }
 
-// LoadDataInFile Bulk Import
-func (conn *MySQLConn) LoadDataInFile(tableFName string, ds *iop.Datastream) (count uint64, err error) {
-	var stderr bytes.Buffer
-
-	connURL := conn.URL
-	if su := conn.GetProp("ssh_url"); su != "" {
-		connURL = su // use ssh url if specified
-	}
-
-	url, err := dburl.Parse(connURL)
-	if err != nil {
-		err = g.Error(err, "Error dburl.Parse(conn.URL)")
-		return
-	}
-
-	password, _ := url.User.Password()
-	host := strings.ReplaceAll(url.Host, ":"+url.Port(), "")
-	database := strings.ReplaceAll(url.Path, "/", "")
-
-	loadQuery := g.R(`LOAD DATA LOCAL INFILE '/dev/stdin' INTO TABLE {table} FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '"' ESCAPED BY '"' IGNORE 1 LINES;`, "table", tableFName)
-	proc := exec.Command(
-		"mysql",
-		"--local-infile=1",
-		"-h", host,
-		"-P", url.Port(),
-		"-u", url.User.Username(),
-		"-p"+password,
-		database,
-		"-e", loadQuery,
-	)
-
-	proc.Stderr = &stderr
-	proc.Stdin = ds.NewCsvReader(iop.DefaultStreamConfig())
-
-	err = proc.Run()
-	if err != nil {
-		cmdStr := strings.ReplaceAll(strings.Join(proc.Args, " "), password, "****")
-		err = g.Error(
-			err,
-			fmt.Sprintf(
-				"MySQL Import Command -> %s\nMySQL Import Error  -> %s",
-				cmdStr, stderr.String(),
-			),
-		)
-		return ds.Count, err
-	}
-
-	return ds.Count, nil
-}
-
 // UPSERT
 // https://vladmihalcea.com/how-do-upsert-and-merge-work-in-oracle-sql-server-postgresql-and-mysql/
 // GenerateUpsertSQL generates the upsert SQL
-func (conn *MySQLConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string) (sql string, err error) {
+func (conn *MySQLConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string, options ...UpsertOptions) (sql string, err error) {
 
-	upsertMap, err := conn.BaseConn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields)
+	upsertMap, err := conn.BaseConn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields, options...)
 	if err != nil {
 		err = g.Error(err, "could not generate upsert variables")
 		return
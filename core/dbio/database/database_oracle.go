@@ -189,9 +189,7 @@ func (conn *OracleConn) GetTableColumns(table *Table, fields ...string) (columns
 	columns, err = conn.BaseConn.GetTableColumns(table, fields...)
 	if err != nil {
 		// try synonym
-		conn.SetProp("get_synonym", "true")
-		columns, err = conn.BaseConn.GetTableColumns(table, fields...)
-		conn.SetProp("get_synonym", "false")
+		columns, err = conn.BaseConn.getTableColumns(conn.Context().Ctx, table, "columns_synonym", fields...)
 	}
 	return
 }
@@ -221,11 +219,17 @@ func (conn *OracleConn) GenerateDDL(table Table, data iop.Dataset, temporary boo
 	return ddl, nil
 }
 
-func (conn *OracleConn) SubmitTemplate(level string, templateMap map[string]string, name string, values map[string]interface{}) (data iop.Dataset, err error) {
-	if cast.ToBool(conn.GetProp("get_synonym")) && name == "columns" {
-		name = "columns_synonym"
+func (conn *OracleConn) GetColumnsContext(ctx context.Context, tableFName string, fields ...string) (columns iop.Columns, err error) {
+	columns, err = conn.BaseConn.GetColumnsContext(ctx, tableFName, fields...)
+	if err != nil && ctx.Err() == nil {
+		// try synonym
+		table, parseErr := ParseTableName(tableFName, conn.Type)
+		if parseErr != nil {
+			return columns, err
+		}
+		columns, err = conn.BaseConn.getTableColumns(ctx, &table, "columns_synonym", fields...)
 	}
-	return conn.BaseConn.SubmitTemplate(level, templateMap, name, values)
+	return
 }
 
 func (conn *OracleConn) sqlldrPath() string {
@@ -514,9 +518,9 @@ func (conn *OracleConn) writeCsv(ds *iop.Datastream, writer io.Writer, pu *cmap.
 }
 
 // GenerateUpsertSQL generates the upsert SQL
-func (conn *OracleConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string) (sql string, err error) {
+func (conn *OracleConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string, options ...UpsertOptions) (sql string, err error) {
 
-	upsertMap, err := conn.BaseConn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields)
+	upsertMap, err := conn.BaseConn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields, options...)
 	if err != nil {
 		err = g.Error(err, "could not generate upsert variables")
 		return
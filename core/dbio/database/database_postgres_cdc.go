@@ -0,0 +1,283 @@
+package database
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+)
+
+// Change-data-capture (CDC) from a PostgreSQL logical replication slot,
+// using the wal2json output plugin (format-version 2).
+//
+// Setup:
+//
+//	-- postgresql.conf (requires a restart)
+//	wal_level = logical
+//	max_replication_slots = 10
+//
+//	-- create one slot per stream (wal2json must be installed on the server)
+//	select pg_create_logical_replication_slot('sling_orders', 'wal2json');
+//
+//	-- optional, to receive the full old row on updates / deletes
+//	-- (by default only the primary key is included)
+//	alter table public.orders replica identity full;
+//
+// The slot is peeked (not consumed) when reading, and advanced after the load
+// succeeds, so the changes of a failed run are read again on the next run.
+// Unused slots retain WAL on the server and should be dropped with
+// `select pg_drop_replication_slot('sling_orders')`.
+//
+// The pgoutput plugin (and its publications) is not supported, since it
+// requires the streaming replication protocol.
+
+const (
+	// CDCOperationColumn holds the change operation: insert, update or delete
+	CDCOperationColumn = "_sling_cdc_op"
+	// CDCLsnColumn holds the LSN of the change
+	CDCLsnColumn = "_sling_cdc_lsn"
+)
+
+// CDCChange is a row change decoded from a replication slot
+type CDCChange struct {
+	LSN      string      `json:"lsn"`
+	Action   string      `json:"action"` // I, U or D
+	Schema   string      `json:"schema"`
+	Table    string      `json:"table"`
+	Columns  []CDCColumn `json:"columns"`
+	Identity []CDCColumn `json:"identity"`
+}
+
+// CDCColumn is a column value of a change
+type CDCColumn struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+// Operation returns the change operation: insert, update or delete
+func (c CDCChange) Operation() string {
+	switch c.Action {
+	case "I":
+		return "insert"
+	case "U":
+		return "update"
+	case "D":
+		return "delete"
+	}
+	return ""
+}
+
+// Values returns the column values of the change, keyed by lower-case name.
+// For deletes, only the identity values (primary key, or the full old row
+// with `replica identity full`) are available.
+func (c CDCChange) Values() map[string]any {
+	values := map[string]any{}
+	for _, col := range c.Identity {
+		values[strings.ToLower(col.Name)] = col.Value
+	}
+	for _, col := range c.Columns {
+		values[strings.ToLower(col.Name)] = col.Value
+	}
+	return values
+}
+
+// ParseWal2JSONChange parses a wal2json (format-version 2) message.
+// ok is false for the non-row messages (begin, commit, truncate, message).
+func ParseWal2JSONChange(lsn, data string) (change CDCChange, ok bool, err error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber() // keep large integers & decimals exact
+	if err = decoder.Decode(&change); err != nil {
+		return change, false, g.Error(err, "could not parse wal2json message: %s", data)
+	}
+
+	if lsn != "" {
+		change.LSN = lsn
+	}
+
+	// convert json numbers
+	for _, cols := range [][]CDCColumn{change.Columns, change.Identity} {
+		for i, col := range cols {
+			if num, isNum := col.Value.(json.Number); isNum {
+				cols[i].Value = num.String()
+			}
+		}
+	}
+
+	return change, change.Operation() != "", nil
+}
+
+// CompareLSN compares two LSNs (e.g. `0/16B3748`), returning -1, 0 or 1.
+// A blank LSN is the lowest.
+func CompareLSN(a, b string) int {
+	parse := func(lsn string) (uint64, uint64) {
+		hi, lo, _ := strings.Cut(strings.TrimSpace(lsn), "/")
+		hiVal, _ := strconv.ParseUint(hi, 16, 32)
+		loVal, _ := strconv.ParseUint(lo, 16, 32)
+		return hiVal, loVal
+	}
+
+	aHi, aLo := parse(a)
+	bHi, bLo := parse(b)
+	switch {
+	case aHi < bHi, aHi == bHi && aLo < bLo:
+		return -1
+	case aHi > bHi, aHi == bHi && aLo > bLo:
+		return 1
+	}
+	return 0
+}
+
+// LatestCDCChanges keeps the last change of each primary key, so that a
+// merge applies a single row per key. The LSN order is preserved.
+func LatestCDCChanges(changes []CDCChange, pk []string) (latest []CDCChange) {
+	if len(pk) == 0 {
+		return changes
+	}
+
+	keyOf := func(change CDCChange) string {
+		values := change.Values()
+		parts := make([]string, len(pk))
+		for i, k := range pk {
+			parts[i] = cast.ToString(values[strings.ToLower(k)])
+		}
+		return strings.Join(parts, "\x1f")
+	}
+
+	lastIndex := map[string]int{}
+	for i, change := range changes {
+		lastIndex[keyOf(change)] = i
+	}
+
+	for i, change := range changes {
+		if lastIndex[keyOf(change)] == i {
+			latest = append(latest, change)
+		}
+	}
+	return latest
+}
+
+// CDCColumns returns the table columns with the operation & LSN columns
+func CDCColumns(tableColumns iop.Columns) (columns iop.Columns) {
+	columns = append(columns, tableColumns...)
+	columns = append(columns,
+		iop.Column{Name: CDCOperationColumn, Type: iop.StringType},
+		iop.Column{Name: CDCLsnColumn, Type: iop.StringType},
+	)
+	for i := range columns {
+		columns[i].Position = i + 1
+	}
+	return columns
+}
+
+// CDCChangesToDataset converts the changes into rows of the table columns,
+// with the operation & LSN columns appended
+func CDCChangesToDataset(changes []CDCChange, tableColumns iop.Columns) (data iop.Dataset) {
+	data = iop.NewDataset(CDCColumns(tableColumns))
+	for _, change := range changes {
+		values := change.Values()
+		row := make([]any, len(data.Columns))
+		for i, col := range tableColumns {
+			row[i] = values[strings.ToLower(col.Name)]
+		}
+		row[len(tableColumns)] = change.Operation()
+		row[len(tableColumns)+1] = change.LSN
+		data.Rows = append(data.Rows, row)
+	}
+	return data
+}
+
+// CDCPeekLimit is the default maximum number of changes peeked from the
+// replication slot per run. The remaining changes are read on the next run,
+// after the slot is advanced.
+var CDCPeekLimit = 100000
+
+// wal2jsonTableName returns the `schema.table` filter of the table for the
+// wal2json `add-tables` option, with the special characters escaped
+func wal2jsonTableName(table Table) string {
+	escape := strings.NewReplacer(`\`, `\\`, " ", `\ `, "'", `\'`, ",", `\,`, ".", `\.`, "*", `\*`)
+	return escape.Replace(table.Schema) + "." + escape.Replace(table.Name)
+}
+
+// PeekCDCChangesSQL returns the query peeking the row changes of the table
+// from the replication slot, after the provided LSN (if any). The peek stops
+// at the end of the transaction reaching the limit of changes.
+func PeekCDCChangesSQL(slot string, table Table, afterLSN string, limit int) string {
+	literal := func(val string) string {
+		return "'" + strings.ReplaceAll(val, "'", "''") + "'"
+	}
+
+	if limit <= 0 {
+		limit = CDCPeekLimit
+	}
+
+	sql := g.F(
+		"select lsn::text, data from pg_logical_slot_peek_changes(%s, null, %d, 'format-version', '2', 'add-tables', %s)",
+		literal(slot), limit, literal(wal2jsonTableName(table)),
+	)
+	if afterLSN != "" {
+		sql = sql + g.F(" where lsn > %s::pg_lsn", literal(afterLSN))
+	}
+	return sql
+}
+
+// PeekCDCChanges reads the row changes of the table from the replication slot,
+// after the provided LSN (if any), without consuming them
+func (conn *PostgresConn) PeekCDCChanges(slot string, table Table, afterLSN string, limit int) (changes []CDCChange, err error) {
+	sql := PeekCDCChangesSQL(slot, table, afterLSN, limit)
+	data, err := conn.Self().Query(sql)
+	if err != nil {
+		return nil, g.Error(err, "could not read changes from replication slot %s", slot)
+	}
+
+	for _, row := range data.Rows {
+		change, ok, err := ParseWal2JSONChange(cast.ToString(row[0]), cast.ToString(row[1]))
+		if err != nil {
+			return nil, err
+		} else if ok {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+// AdvanceCDCSlot advances the replication slot to the LSN,
+// releasing the WAL retained before it
+func (conn *PostgresConn) AdvanceCDCSlot(slot, lsn string) (err error) {
+	sql := g.F(
+		"select pg_replication_slot_advance('%s', '%s'::pg_lsn)",
+		strings.ReplaceAll(slot, "'", "''"), strings.ReplaceAll(lsn, "'", "''"),
+	)
+	if _, err = conn.Self().Exec(sql); err != nil {
+		return g.Error(err, "could not advance replication slot %s", slot)
+	}
+	return nil
+}
+
+// StreamCDC returns a datastream of the latest change of each primary key
+// of the table, read from the replication slot after the LSN. lastLSN is the
+// LSN of the last change read, to store and to advance the slot to.
+// At most limit changes are peeked (CDCPeekLimit if not positive).
+func (conn *PostgresConn) StreamCDC(slot string, table Table, afterLSN string, pk []string, limit int) (ds *iop.Datastream, lastLSN string, err error) {
+	changes, err := conn.PeekCDCChanges(slot, table, afterLSN, limit)
+	if err != nil {
+		return nil, afterLSN, err
+	}
+
+	lastLSN = afterLSN
+	for _, change := range changes {
+		if CompareLSN(change.LSN, lastLSN) > 0 {
+			lastLSN = change.LSN
+		}
+	}
+
+	changes = LatestCDCChanges(changes, pk)
+	g.Debug("read %d changes from replication slot %s (last lsn: %s)", len(changes), slot, lastLSN)
+
+	data := CDCChangesToDataset(changes, table.Columns)
+	return data.Stream(), lastLSN, nil
+}
@@ -103,6 +103,11 @@ func (conn *PrometheusConn) GetSQLColumns(table Table) (columns iop.Columns, err
 	return iop.Columns{{Name: "metric"}}, nil
 }
 
+// GetColumnsContext returns the columns with GetColumns, ctx is not used
+func (conn *PrometheusConn) GetColumnsContext(ctx context.Context, tableFName string, fields ...string) (columns iop.Columns, err error) {
+	return conn.GetColumns(tableFName, fields...)
+}
+
 // NewTransaction creates a new transaction
 func (conn *PrometheusConn) GetTableColumns(table *Table, fields ...string) (columns iop.Columns, err error) {
 	tables, err := conn.GetTables(table.Schema)
@@ -0,0 +1,522 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/flarco/g"
+	"github.com/flarco/g/net"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// PubSubConn is a Google Cloud Pub/Sub connection.
+// The stream is the subscription id. The pulled messages are held (leases
+// extended) until the target write succeeds, then acked with `AckMessages`,
+// for at-least-once delivery. Messages not acked are nacked on close.
+//
+// Connection properties:
+//   - `receive_timeout`: max duration of the pull (default: unbounded),
+//     also set with the `--timeout` flag, such as `5m` or a number of seconds
+//   - `idle_timeout`: stop pulling after no message for this duration (default `10s`)
+//   - `payload`: `json` (default) decodes the message data, `raw` keeps it as a string
+//   - `metadata_columns`: add the message id, publish time, ordering key & attributes
+//   - `max_outstanding_messages`: max messages held un-acked during the pull
+//     (default: the `--limit`, else 1000). The pull waits once reached.
+type PubSubConn struct {
+	BaseConn
+	URL       string
+	Client    *pubsub.Client
+	ProjectID string
+
+	pending       []*pubsub.Message
+	receiveCancel context.CancelFunc
+	receiveDone   chan struct{}
+	mux           sync.Mutex
+}
+
+const (
+	// PubSubMessageIDColumn holds the message id
+	PubSubMessageIDColumn = "_pubsub_message_id"
+	// PubSubPublishTimeColumn holds the message publish time
+	PubSubPublishTimeColumn = "_pubsub_publish_time"
+	// PubSubOrderingKeyColumn holds the message ordering key
+	PubSubOrderingKeyColumn = "_pubsub_ordering_key"
+	// PubSubAttributesColumn holds the message attributes, as JSON
+	PubSubAttributesColumn = "_pubsub_attributes"
+)
+
+// Init initiates the object
+func (conn *PubSubConn) Init() error {
+	conn.BaseConn.URL = conn.URL
+	conn.BaseConn.Type = dbio.TypeDbPubSub
+
+	u, err := net.NewURL(conn.BaseConn.URL)
+	if err != nil {
+		return g.Error(err, "could not parse pubsub url")
+	}
+
+	conn.ProjectID = conn.GetProp("project")
+	if conn.ProjectID == "" {
+		conn.ProjectID = u.U.Host
+	}
+
+	instance := Connection(conn)
+	conn.BaseConn.instance = &instance
+
+	err = conn.BaseConn.Init()
+	if err != nil {
+		err = g.Error(err, "could not initialize connection")
+		return err
+	}
+
+	if conn.GetProp("GC_KEY_FILE") == "" {
+		conn.SetProp("GC_KEY_FILE", conn.GetProp("keyfile")) // dbt style
+	}
+	if conn.GetProp("GC_KEY_FILE") == "" {
+		conn.SetProp("GC_KEY_FILE", conn.GetProp("credentialsFile"))
+	}
+
+	return nil
+}
+
+func (conn *PubSubConn) getNewClient(timeOut ...int) (client *pubsub.Client, err error) {
+	var authOption option.ClientOption
+	var credJsonBody string
+
+	to := 15
+	if len(timeOut) > 0 {
+		to = timeOut[0]
+	}
+
+	if val := conn.GetProp("GC_KEY_BODY"); val != "" {
+		credJsonBody = val
+		authOption = option.WithCredentialsJSON([]byte(val))
+	} else if val := conn.GetProp("GC_KEY_FILE"); val != "" {
+		authOption = option.WithCredentialsFile(val)
+		b, err := os.ReadFile(val)
+		if err != nil {
+			return client, g.Error(err, "could not read google cloud key file")
+		}
+		credJsonBody = string(b)
+	} else if val := conn.GetProp("GOOGLE_APPLICATION_CREDENTIALS"); val != "" {
+		authOption = option.WithCredentialsFile(val)
+		b, err := os.ReadFile(val)
+		if err != nil {
+			return client, g.Error(err, "could not read google cloud key file")
+		}
+		credJsonBody = string(b)
+	} else {
+		err = g.Error("no Google credentials provided")
+		return
+	}
+
+	if conn.ProjectID == "" && credJsonBody != "" {
+		m := g.M()
+		g.Unmarshal(credJsonBody, &m)
+		conn.ProjectID = cast.ToString(m["project_id"])
+	}
+
+	ctx, cancel := context.WithTimeout(conn.BaseConn.Context().Ctx, time.Duration(to)*time.Second)
+	defer cancel()
+	return pubsub.NewClient(ctx, conn.ProjectID, authOption)
+}
+
+// Connect connects to the database
+func (conn *PubSubConn) Connect(timeOut ...int) (err error) {
+	if conn.Client != nil {
+		return nil
+	}
+
+	conn.Client, err = conn.getNewClient(timeOut...)
+	if err != nil {
+		return g.Error(err, "Failed to get client")
+	}
+
+	g.Debug(`opened "%s" connection (%s)`, conn.Type, conn.GetProp("sling_conn_id"))
+
+	return nil
+}
+
+// Close nacks the messages not acked, and closes the client
+func (conn *PubSubConn) Close() error {
+	conn.NackMessages()
+	if conn.Client != nil {
+		if err := conn.Client.Close(); err != nil {
+			return g.Error(err, "Failed to close client")
+		}
+		conn.Client = nil
+	}
+	g.Debug(`closed "%s" connection (%s)`, conn.Type, conn.GetProp("sling_conn_id"))
+	return nil
+}
+
+// NewTransaction creates a new transaction
+func (conn *PubSubConn) NewTransaction(ctx context.Context, options ...*sql.TxOptions) (tx Transaction, err error) {
+	// does not support transaction
+	return
+}
+
+// GetSQLColumns does not pull messages, since it would lease them.
+// The columns are inferred from the messages when streaming.
+func (conn *PubSubConn) GetSQLColumns(table Table) (columns iop.Columns, err error) {
+	return iop.Columns{{Name: "data"}}, nil
+}
+
+// GetColumnsContext returns the columns with GetColumns, ctx is not used
+func (conn *PubSubConn) GetColumnsContext(ctx context.Context, tableFName string, fields ...string) (columns iop.Columns, err error) {
+	return conn.GetColumns(tableFName, fields...)
+}
+
+// GetTableColumns returns the columns
+func (conn *PubSubConn) GetTableColumns(table *Table, fields ...string) (columns iop.Columns, err error) {
+	return conn.GetSQLColumns(*table)
+}
+
+func (conn *PubSubConn) ExecContext(ctx context.Context, sql string, args ...interface{}) (result sql.Result, err error) {
+	return nil, g.Error("ExecContext not implemented on PubSubConn")
+}
+
+func (conn *PubSubConn) BulkExportFlow(table Table) (df *iop.Dataflow, err error) {
+	options, _ := g.UnmarshalMap(table.SQL)
+
+	ds, err := conn.StreamRowsContext(conn.Context().Ctx, table.Name, options)
+	if err != nil {
+		return df, g.Error(err, "could start datastream")
+	}
+
+	df, err = iop.MakeDataFlow(ds)
+	if err != nil {
+		return df, g.Error(err, "could start dataflow")
+	}
+
+	return
+}
+
+// StreamRowsContext pulls messages from the subscription, until the limit,
+// the receive timeout or the idle timeout is reached
+func (conn *PubSubConn) StreamRowsContext(ctx context.Context, subscription string, Opts ...map[string]interface{}) (ds *iop.Datastream, err error) {
+	opts := getQueryOptions(Opts)
+	Limit := int(0) // infinite
+	if val := cast.ToInt(opts["limit"]); val > 0 {
+		Limit = val
+	}
+
+	subscription = strings.TrimSpace(subscription)
+	if subscription == "" {
+		return ds, g.Error("Empty subscription name")
+	}
+
+	idleTimeout := 10 * time.Second
+	if val := conn.GetProp("idle_timeout"); val != "" {
+		if idleTimeout, err = parsePubSubDuration(val); err != nil {
+			return ds, g.Error(err, "invalid idle_timeout: %s", val)
+		}
+	}
+
+	var deadline time.Time
+	receiveTimeout := conn.GetProp("receive_timeout")
+	if receiveTimeout == "" {
+		receiveTimeout = os.Getenv("SLING_TIMEOUT")
+	}
+	if receiveTimeout != "" {
+		duration, err := parsePubSubDuration(receiveTimeout)
+		if err != nil {
+			return ds, g.Error(err, "invalid receive_timeout: %s", receiveTimeout)
+		}
+		deadline = time.Now().Add(duration)
+	}
+
+	// messages are received until acked / nacked, to keep the leases extended
+	conn.NackMessages()
+	receiveCtx, receiveCancel := context.WithCancel(conn.Context().Ctx)
+	msgChan := make(chan *pubsub.Message)
+	receiveDone := make(chan struct{})
+
+	// the messages are held until the target write, so this bounds the pull
+	sub := conn.Client.Subscription(subscription)
+	if val := cast.ToInt(conn.GetProp("max_outstanding_messages")); val > 0 {
+		sub.ReceiveSettings.MaxOutstandingMessages = val
+	} else if Limit > 0 {
+		sub.ReceiveSettings.MaxOutstandingMessages = Limit
+	}
+
+	conn.mux.Lock()
+	conn.receiveCancel = receiveCancel
+	conn.receiveDone = receiveDone
+	conn.mux.Unlock()
+
+	g.Debug("pulling messages from subscription %s (limit: %d)", subscription, Limit)
+	go func() {
+		defer close(receiveDone)
+		err := sub.Receive(receiveCtx, func(ctx context.Context, msg *pubsub.Message) {
+			select {
+			case msgChan <- msg:
+			case <-ctx.Done():
+				msg.Nack() // not read
+			}
+		})
+		if err != nil && receiveCtx.Err() == nil {
+			g.Warn("could not receive from subscription %s: %s", subscription, err.Error())
+			receiveCancel()
+		}
+	}()
+
+	decoder := &pubsubDecoder{
+		conn:         conn,
+		messages:     msgChan,
+		done:         receiveCtx.Done(),
+		limit:        Limit,
+		idleTimeout:  idleTimeout,
+		deadline:     deadline,
+		raw:          strings.EqualFold(conn.GetProp("payload"), "raw"),
+		withMetadata: cast.ToBool(conn.GetProp("metadata_columns")),
+	}
+
+	ds = iop.NewDatastreamContext(ctx, nil)
+
+	flatten := true
+	if val := conn.GetProp("flatten"); val != "" {
+		flatten = cast.ToBool(val)
+	}
+	js := iop.NewJSONStream(ds, decoder, flatten, conn.GetProp("jmespath"))
+	js.HasMapPayload = true
+
+	ds.SetIterator(ds.NewIterator(ds.Columns, js.NextFunc))
+	ds.SetMetadata(conn.GetProp("METADATA"))
+	ds.SetConfig(conn.Props())
+
+	err = ds.Start()
+	if err != nil {
+		conn.NackMessages()
+		return ds, g.Error(err, "could start datastream")
+	}
+
+	return
+}
+
+// AckMessages acknowledges the pulled messages, once written to the target.
+// It stops receiving from the subscription.
+func (conn *PubSubConn) AckMessages() (count int) {
+	return conn.settleMessages(true)
+}
+
+// NackMessages releases the pulled messages for redelivery.
+// It stops receiving from the subscription.
+func (conn *PubSubConn) NackMessages() (count int) {
+	return conn.settleMessages(false)
+}
+
+func (conn *PubSubConn) settleMessages(ack bool) (count int) {
+	conn.mux.Lock()
+	pending, cancel, done := conn.pending, conn.receiveCancel, conn.receiveDone
+	conn.pending, conn.receiveCancel, conn.receiveDone = nil, nil, nil
+	conn.mux.Unlock()
+
+	for _, msg := range pending {
+		if ack {
+			msg.Ack()
+		} else {
+			msg.Nack()
+		}
+	}
+
+	// stopping the receive flushes the acks / nacks
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+
+	if len(pending) > 0 {
+		g.Debug("%s %d pubsub messages", lo.Ternary(ack, "acked", "nacked"), len(pending))
+	}
+	return len(pending)
+}
+
+func (conn *PubSubConn) addPending(msg *pubsub.Message) {
+	conn.mux.Lock()
+	conn.pending = append(conn.pending, msg)
+	conn.mux.Unlock()
+}
+
+// pubsubDecoder decodes the received messages into records for the JSON stream
+type pubsubDecoder struct {
+	conn         *PubSubConn
+	messages     chan *pubsub.Message
+	done         <-chan struct{}
+	limit        int
+	count        int
+	idleTimeout  time.Duration
+	deadline     time.Time
+	raw          bool
+	withMetadata bool
+}
+
+// Decode receives the next message into obj (a map).
+// Returns io.EOF when the limit or a timeout is reached.
+func (d *pubsubDecoder) Decode(obj any) (err error) {
+	if d.limit > 0 && d.count >= d.limit {
+		return io.EOF
+	}
+
+	timeout := d.idleTimeout
+	if !d.deadline.IsZero() {
+		if remaining := time.Until(d.deadline); remaining <= 0 {
+			return io.EOF
+		} else if remaining < timeout {
+			timeout = remaining
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var msg *pubsub.Message
+	select {
+	case msg = <-d.messages:
+	case <-timer.C:
+		return io.EOF
+	case <-d.done:
+		return io.EOF
+	}
+
+	if d.conn != nil {
+		d.conn.addPending(msg)
+	}
+	d.count++
+
+	record, err := pubsubRecord(msg, d.raw, d.withMetadata)
+	if err != nil {
+		return err
+	}
+
+	m, ok := obj.(*map[string]any)
+	if !ok {
+		return g.Error("invalid decode target: %T", obj)
+	}
+	*m = record
+	return nil
+}
+
+// pubsubRecord converts the message into a record. A JSON payload which is
+// not an object (or a raw payload) is set in the `data` key.
+func pubsubRecord(msg *pubsub.Message, raw, withMetadata bool) (record map[string]any, err error) {
+	record = map[string]any{}
+	if raw {
+		record["data"] = string(msg.Data)
+	} else {
+		var payload any
+		if err = json.Unmarshal(msg.Data, &payload); err != nil {
+			return nil, g.Error(err, "could not decode message %s as JSON. Use the `payload: raw` property to keep it as a string.", msg.ID)
+		}
+
+		if m, ok := payload.(map[string]any); ok {
+			record = m
+		} else {
+			record["data"] = payload
+		}
+	}
+
+	if withMetadata {
+		record[PubSubMessageIDColumn] = msg.ID
+		record[PubSubPublishTimeColumn] = msg.PublishTime
+		record[PubSubOrderingKeyColumn] = msg.OrderingKey
+		record[PubSubAttributesColumn] = g.Marshal(msg.Attributes)
+	}
+
+	return record, nil
+}
+
+// GetSchemas returns schemas
+func (conn *PubSubConn) GetSchemas() (data iop.Dataset, err error) {
+	data = iop.NewDataset(iop.NewColumnsFromFields("schema_name"))
+	data.Append([]interface{}{conn.ProjectID})
+	return data, nil
+}
+
+// GetTables returns the subscriptions
+func (conn *PubSubConn) GetTables(schema string) (data iop.Dataset, err error) {
+	data = iop.NewDataset(iop.NewColumnsFromFields("table_name"))
+
+	queryContext := g.NewContext(conn.Context().Ctx)
+	it := conn.Client.Subscriptions(queryContext.Ctx)
+	for {
+		sub, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return data, g.Error(err, "could not list pubsub subscriptions")
+		}
+		data.Append([]interface{}{sub.ID()})
+	}
+
+	return data, nil
+}
+
+// GetSchemata obtain full schemata info for a schema and/or table in current database
+func (conn *PubSubConn) GetSchemata(level SchemataLevel, schemaName string, tableNames ...string) (Schemata, error) {
+	schemata := Schemata{
+		Databases: map[string]Database{},
+		conn:      conn,
+	}
+
+	data, err := conn.GetTables(schemaName)
+	if err != nil {
+		return schemata, err
+	}
+
+	schemaName = conn.ProjectID
+	schema := Schema{
+		Name:   schemaName,
+		Tables: map[string]Table{},
+	}
+
+	if g.In(level, SchemataLevelTable, SchemataLevelColumn) {
+		for _, row := range data.Rows {
+			tableName := cast.ToString(row[0])
+			if len(tableNames) > 0 && !g.In(tableName, tableNames...) {
+				continue
+			}
+
+			schema.Tables[strings.ToLower(tableName)] = Table{
+				Name:     tableName,
+				Schema:   schemaName,
+				Database: conn.Type.String(),
+				Columns:  iop.Columns{},
+				Dialect:  conn.GetType(),
+			}
+		}
+	}
+
+	schemata.Databases[strings.ToLower(conn.Type.String())] = Database{
+		Name:    conn.Type.String(),
+		Schemas: map[string]Schema{strings.ToLower(schemaName): schema},
+	}
+
+	return schemata, nil
+}
+
+// parsePubSubDuration parses a duration (e.g. `90s`, `10m`) or a number of seconds
+func parsePubSubDuration(val string) (duration time.Duration, err error) {
+	if seconds, err := cast.ToIntE(strings.TrimSpace(val)); err == nil {
+		duration = time.Duration(seconds) * time.Second
+	} else if duration, err = time.ParseDuration(strings.TrimSpace(val)); err != nil {
+		return 0, g.Error("could not parse duration: %s", val)
+	}
+
+	if duration < 0 {
+		return 0, g.Error("duration cannot be negative: %s", val)
+	}
+	return duration, nil
+}
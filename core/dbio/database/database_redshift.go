@@ -91,8 +91,10 @@ func (conn *RedshiftConn) Unload(ctx *g.Context, tables ...Table) (s3Path string
 		return "", g.Error("need to set AWS_BUCKET")
 	}
 
-	AwsID := conn.GetProp("AWS_ACCESS_KEY_ID")
-	AwsAccessKey := conn.GetProp("AWS_SECRET_ACCESS_KEY")
+	credentials, err := conn.copyCredentials()
+	if err != nil {
+		return "", g.Error(err, "could not get credentials for unload")
+	}
 
 	g.Info("unloading from redshift to s3")
 	unload := func(table Table, s3PathPart string) {
@@ -105,14 +107,12 @@ func (conn *RedshiftConn) Unload(ctx *g.Context, tables ...Table) (s3Path string
 			conn.template.Core["copy_to_s3"],
 			"sql", sql,
 			"s3_path", s3PathPart,
-			"aws_access_key_id", AwsID,
-			"aws_secret_access_key", AwsAccessKey,
+			"credentials", credentials,
 			"parallel", conn.GetProp("PARALLEL"),
 		)
 		_, err = conn.Exec(unloadSQL)
 		if err != nil {
-			cleanSQL := strings.ReplaceAll(unloadSQL, AwsID, "*****")
-			cleanSQL = strings.ReplaceAll(cleanSQL, AwsAccessKey, "*****")
+			cleanSQL := conn.maskCredentials(unloadSQL)
 			err = g.Error(err, fmt.Sprintf("SQL Error for %s:\n%s", s3PathPart, cleanSQL))
 			ctx.CaptureErr(err)
 		}
@@ -209,27 +209,34 @@ func (conn *RedshiftConn) BulkExportFlow(table Table) (df *iop.Dataflow, err err
 
 // BulkImportFlow inserts a flow of streams into a table.
 // For redshift we need to create CSVs in S3 and then use the COPY command.
+// If no S3 staging location is configured, falls back to batch inserts.
 func (conn *RedshiftConn) BulkImportFlow(tableFName string, df *iop.Dataflow) (count uint64, err error) {
 	defer df.CleanUp()
 
-	settingMppBulkImportFlow(conn, iop.GzipCompressorType)
-	if conn.GetProp("AWS_BUCKET") == "" {
-		return count, g.Error("Need to set 'AWS_BUCKET' to copy to redshift")
+	s3Path := conn.stagingPath(tableFName)
+	if s3Path == "" {
+		g.Debug("no S3 staging configured (s3_staging or AWS_BUCKET), using direct inserts into redshift")
+		for ds := range df.StreamCh {
+			cnt, err := conn.BaseConn.InsertBatchStream(tableFName, ds)
+			count += cnt
+			if err != nil {
+				return count, g.Error(err, "could not insert into redshift")
+			}
+		}
+		return count, df.Err()
 	}
 
-	s3Path := fmt.Sprintf(
-		"s3://%s/%s/%s",
-		conn.GetProp("AWS_BUCKET"),
-		tempCloudStorageFolder,
-		tableFName,
-	)
+	settingMppBulkImportFlow(conn, iop.GzipCompressorType)
 
-	s3Fs, err := filesys.NewFileSysClient(dbio.TypeFileS3, conn.PropArr()...)
+	bucket := strings.Split(strings.TrimPrefix(s3Path, "s3://"), "/")[0]
+	s3Props := append(conn.PropArr(), "BUCKET="+bucket)
+	s3Fs, err := filesys.NewFileSysClient(dbio.TypeFileS3, s3Props...)
 	if err != nil {
 		err = g.Error(err, "Could not get fs client for S3")
 		return
 	}
 
+	manifestPath := s3Path + ".manifest"
 	err = filesys.Delete(s3Fs, s3Path)
 	if err != nil {
 		return count, g.Error(err, "Could not Delete: "+s3Path)
@@ -238,6 +245,7 @@ func (conn *RedshiftConn) BulkImportFlow(tableFName string, df *iop.Dataflow) (c
 	df.Defer(func() {
 		if !cast.ToBool(os.Getenv("SLING_KEEP_TEMP")) {
 			filesys.Delete(s3Fs, s3Path)
+			filesys.Delete(s3Fs, manifestPath)
 		}
 	}) // cleanup
 
@@ -249,7 +257,23 @@ func (conn *RedshiftConn) BulkImportFlow(tableFName string, df *iop.Dataflow) (c
 	}
 	g.DebugLow("total written: %s to %s", humanize.Bytes(cast.ToUint64(bw)), s3Path)
 
-	_, err = conn.CopyFromS3(tableFName, s3Path, df.Columns)
+	// list the staged files into a manifest, so COPY loads exactly those
+	nodes, err := s3Fs.List(s3Path)
+	if err != nil {
+		return df.Count(), g.Error(err, "could not list staged files in "+s3Path)
+	}
+
+	manifest, err := makeCopyManifest(nodes.URIs())
+	if err != nil {
+		return df.Count(), g.Error(err, "could not make manifest for "+s3Path)
+	}
+
+	_, err = s3Fs.Write(manifestPath, strings.NewReader(manifest))
+	if err != nil {
+		return df.Count(), g.Error(err, "could not write manifest to "+manifestPath)
+	}
+
+	_, err = conn.CopyFromS3(tableFName, manifestPath, df.Columns)
 	if err != nil {
 		return df.Count(), g.Error(err, "error copying into redshift from s3")
 	}
@@ -257,6 +281,41 @@ func (conn *RedshiftConn) BulkImportFlow(tableFName string, df *iop.Dataflow) (c
 	return df.Count(), nil
 }
 
+// stagingPath returns the S3 folder to stage files into for the table.
+// `s3_staging` (from target options) takes precedence over AWS_BUCKET.
+func (conn *RedshiftConn) stagingPath(tableFName string) string {
+	if staging := strings.TrimSuffix(conn.GetProp("S3_STAGING"), "/"); staging != "" {
+		if !strings.HasPrefix(staging, "s3://") {
+			staging = "s3://" + staging
+		}
+		return fmt.Sprintf("%s/%s", staging, tableFName)
+	}
+
+	if bucket := conn.GetProp("AWS_BUCKET"); bucket != "" {
+		return fmt.Sprintf("s3://%s/%s/%s", bucket, tempCloudStorageFolder, tableFName)
+	}
+
+	return ""
+}
+
+// makeCopyManifest creates the JSON manifest listing the files for a COPY.
+// see https://docs.aws.amazon.com/redshift/latest/dg/loading-data-files-using-manifest.html
+func makeCopyManifest(uris []string) (manifest string, err error) {
+	entries := []map[string]any{}
+	for _, uri := range uris {
+		if strings.HasSuffix(uri, "/") || strings.HasSuffix(uri, ".manifest") {
+			continue
+		}
+		entries = append(entries, g.M("url", uri, "mandatory", true))
+	}
+
+	if len(entries) == 0 {
+		return "", g.Error("no staged files found")
+	}
+
+	return g.Marshal(g.M("entries", entries)), nil
+}
+
 // BulkImportStream inserts a stream into a table.
 // For redshift we need to create CSVs in S3 and then use the COPY command.
 func (conn *RedshiftConn) BulkImportStream(tableFName string, ds *iop.Datastream) (count uint64, err error) {
@@ -270,9 +329,9 @@ func (conn *RedshiftConn) BulkImportStream(tableFName string, ds *iop.Datastream
 }
 
 // GenerateUpsertSQL generates the upsert SQL
-func (conn *RedshiftConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string) (sql string, err error) {
+func (conn *RedshiftConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string, options ...UpsertOptions) (sql string, err error) {
 
-	upsertMap, err := conn.BaseConn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields)
+	upsertMap, err := conn.BaseConn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields, options...)
 	if err != nil {
 		err = g.Error(err, "could not generate upsert variables")
 		return
@@ -308,36 +367,83 @@ func (conn *RedshiftConn) GenerateUpsertSQL(srcTable string, tgtTable string, pk
 	return
 }
 
-// CopyFromS3 uses the COPY INTO Table command from AWS S3
+// CopyFromS3 uses the COPY INTO Table command from AWS S3.
+// If s3Path ends with `.manifest`, it is loaded as a manifest file.
 func (conn *RedshiftConn) CopyFromS3(tableFName, s3Path string, columns iop.Columns) (count uint64, err error) {
-	AwsID := conn.GetProp("AWS_ACCESS_KEY_ID")
-	AwsAccessKey := conn.GetProp("AWS_SECRET_ACCESS_KEY")
-	if AwsID == "" || AwsAccessKey == "" {
-		err = g.Error("Need to set 'AWS_ACCESS_KEY_ID' and 'AWS_SECRET_ACCESS_KEY' to copy to snowflake from S3")
-		return
+	sql, err := conn.GenerateCopySQL(tableFName, s3Path, columns)
+	if err != nil {
+		return 0, g.Error(err, "could not generate COPY statement")
 	}
 
-	tgtColumns := conn.GetType().QuoteNames(columns.Names()...)
-
 	g.Debug("copying into redshift from s3")
 	g.Debug("url: " + s3Path)
-	sql := g.R(
+
+	_, err = conn.Exec(sql)
+	if err != nil {
+		conn.WarnStlLoadErrors(err)
+		return 0, g.Error(err)
+	}
+
+	return 0, nil
+}
+
+// GenerateCopySQL generates the COPY statement to load S3 files into a table
+func (conn *RedshiftConn) GenerateCopySQL(tableFName, s3Path string, columns iop.Columns) (sql string, err error) {
+	credentials, err := conn.copyCredentials()
+	if err != nil {
+		return "", err
+	}
+
+	tgtColumns := conn.GetType().QuoteNames(columns.Names()...)
+
+	manifest := ""
+	if strings.HasSuffix(s3Path, ".manifest") {
+		manifest = "MANIFEST"
+	}
+
+	sql = g.R(
 		conn.template.Core["copy_from_s3"],
 		"tgt_table", tableFName,
 		"tgt_columns", strings.Join(tgtColumns, ", "),
 		"s3_path", s3Path,
-		"aws_access_key_id", AwsID,
-		"aws_secret_access_key", AwsAccessKey,
+		"credentials", credentials,
+		"manifest", manifest,
 	)
 	sql = conn.setEmptyAsNull(sql)
 
-	_, err = conn.Exec(sql)
-	if err != nil {
-		conn.WarnStlLoadErrors(err)
-		return 0, g.Error(err)
+	return strings.TrimSpace(sql), nil
+}
+
+// copyCredentials returns the authorization clause for COPY / UNLOAD.
+// An IAM role (AWS_IAM_ROLE) is preferred over access keys.
+func (conn *RedshiftConn) copyCredentials() (clause string, err error) {
+	if role := conn.GetProp("AWS_IAM_ROLE", "IAM_ROLE"); role != "" {
+		return g.F("iam_role '%s'", role), nil
 	}
 
-	return 0, nil
+	AwsID := conn.GetProp("AWS_ACCESS_KEY_ID")
+	AwsAccessKey := conn.GetProp("AWS_SECRET_ACCESS_KEY")
+	if AwsID == "" || AwsAccessKey == "" {
+		err = g.Error("Need to set 'AWS_IAM_ROLE' or 'AWS_ACCESS_KEY_ID' and 'AWS_SECRET_ACCESS_KEY' to copy to redshift from S3")
+		return
+	}
+
+	clause = g.F("aws_access_key_id=%s;aws_secret_access_key=%s", AwsID, AwsAccessKey)
+	if token := conn.GetProp("AWS_SESSION_TOKEN"); token != "" {
+		clause = clause + ";token=" + token
+	}
+
+	return g.F("credentials '%s'", clause), nil
+}
+
+// maskCredentials removes the secrets from a sql text
+func (conn *RedshiftConn) maskCredentials(sql string) string {
+	for _, key := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN"} {
+		if val := conn.GetProp(key); val != "" {
+			sql = strings.ReplaceAll(sql, val, "*****")
+		}
+	}
+	return sql
 }
 
 // CastColumnForSelect casts to the correct target column type
@@ -443,6 +443,11 @@ func (conn *SnowflakeConn) BulkImportFlow(tableFName string, df *iop.Dataflow) (
 	settingMppBulkImportFlow(conn, iop.ZStandardCompressorType)
 
 	if conn.GetProp("use_bulk") != "false" {
+		// an explicit stage takes precedence
+		if conn.GetProp("stage") != "" {
+			return conn.CopyViaStage(tableFName, df)
+		}
+
 		switch conn.CopyMethod {
 		case "AWS":
 			return conn.CopyViaAWS(tableFName, df)
@@ -721,7 +726,7 @@ func (conn *SnowflakeConn) CopyViaStage(tableFName string, df *iop.Dataflow) (co
 
 	context := g.NewContext(conn.Context().Ctx)
 
-	if conn.GetProp("internal_stage") == "" {
+	if conn.GetProp("stage") == "" && conn.GetProp("internal_stage") == "" {
 		return 0, g.Error("Prop internal_stage is required")
 	}
 
@@ -736,6 +741,16 @@ func (conn *SnowflakeConn) CopyViaStage(tableFName string, df *iop.Dataflow) (co
 		conn.SetProp("schema", table.Schema)
 	}
 
+	// files for an external stage are written to its cloud storage url
+	if stage := conn.GetProp("stage"); stage != "" {
+		stageURL, err := conn.StageURL(conn.stageName())
+		if err != nil {
+			return 0, g.Error(err, "could not describe stage %s", stage)
+		} else if stageURL != "" {
+			return conn.CopyViaExternalStage(tableFName, stageURL, df)
+		}
+	}
+
 	// Write the ds to a temp file
 	folderPath := path.Join(env.GetTempFolder(), "snowflake", "put", env.CleanTableName(tableFName), g.NowFileStr())
 
@@ -761,9 +776,9 @@ func (conn *SnowflakeConn) CopyViaStage(tableFName string, df *iop.Dataflow) (co
 	}()
 
 	// Import to staging
-	stageFolderPath := g.F("@%s.%s/%s/%s", conn.GetProp("schema"), conn.GetProp("internal_stage"), env.CleanTableName(tableFName), g.NowFileStr())
+	stageFolderPath := conn.stageFolderPath(tableFName)
 	conn.Exec("USE SCHEMA " + conn.GetProp("schema"))
-	_, err = conn.Exec("REMOVE " + stageFolderPath)
+	_, err = conn.Exec(conn.GenerateStageRemoveSQL(stageFolderPath))
 	if err != nil {
 		err = g.Error(err, "REMOVE: "+stageFolderPath)
 		return
@@ -771,8 +786,8 @@ func (conn *SnowflakeConn) CopyViaStage(tableFName string, df *iop.Dataflow) (co
 	df.Defer(func() {
 		if err != nil && strings.Contains(err.Error(), "transaction") {
 			conn.tx = nil // clear any failed transactions
-			conn.Exec("REMOVE " + stageFolderPath)
 		}
+		conn.removeStaged(stageFolderPath)
 	})
 
 	doPut := func(file filesys.FileReady) (stageFilePath string) {
@@ -780,7 +795,7 @@ func (conn *SnowflakeConn) CopyViaStage(tableFName string, df *iop.Dataflow) (co
 		os.Chmod(file.Node.Path(), 0777) // make file readeable everywhere
 		err = conn.StagePUT(file.Node.URI, stageFolderPath)
 		if err != nil {
-			df.Context.CaptureErr(g.Error(err, "Error copying to Snowflake Stage: "+conn.GetProp("internal_stagee")))
+			df.Context.CaptureErr(g.Error(err, "Error copying to Snowflake Stage: "+conn.stageName()))
 		}
 		pathArr := strings.Split(file.Node.Path(), "/")
 		fileName := pathArr[len(pathArr)-1]
@@ -801,26 +816,7 @@ func (conn *SnowflakeConn) CopyViaStage(tableFName string, df *iop.Dataflow) (co
 			return
 		}
 
-		tgtColumns := make([]string, len(file.Columns))
-		for i, name := range file.Columns.Names() {
-			colName, _ := ParseColumnName(name, conn.GetType())
-			tgtColumns[i] = conn.Quote(colName)
-		}
-
-		srcColumns := make([]string, len(file.Columns))
-		for i := range file.Columns {
-			srcColumns[i] = g.F("T.$%d", i+1)
-		}
-
-		sql := g.R(
-			conn.template.Core["copy_from_stage"],
-			"table", tableFName,
-			"tgt_columns", strings.Join(tgtColumns, ", "),
-			"src_columns", strings.Join(srcColumns, ", "),
-			"stage_path", stageFilePath,
-		)
-		sql = conn.setEmptyAsNull(sql)
-
+		sql := conn.GenerateCopyFromStageSQL(tableFName, file.Columns, stageFilePath)
 		_, err = conn.Exec(sql)
 		if err != nil {
 			err = g.Error(err, "Error with COPY INTO")
@@ -835,26 +831,7 @@ func (conn *SnowflakeConn) CopyViaStage(tableFName string, df *iop.Dataflow) (co
 			return
 		}
 
-		tgtColumns := make([]string, len(df.Columns))
-		for i, name := range df.Columns.Names() {
-			colName, _ := ParseColumnName(name, conn.GetType())
-			tgtColumns[i] = conn.Quote(colName)
-		}
-
-		srcColumns := make([]string, len(df.Columns))
-		for i := range df.Columns {
-			srcColumns[i] = g.F("T.$%d", i+1)
-		}
-
-		sql := g.R(
-			conn.template.Core["copy_from_stage"],
-			"table", tableFName,
-			"tgt_columns", strings.Join(tgtColumns, ", "),
-			"src_columns", strings.Join(srcColumns, ", "),
-			"stage_path", stageFolderPath,
-		)
-		sql = conn.setEmptyAsNull(sql)
-
+		sql := conn.GenerateCopyFromStageSQL(tableFName, df.Columns, stageFolderPath)
 		data, err := conn.Query(sql)
 		if err != nil {
 			err = g.Error(err, "Error with COPY INTO")
@@ -891,6 +868,141 @@ func (conn *SnowflakeConn) CopyViaStage(tableFName string, df *iop.Dataflow) (co
 	return df.Count(), nil
 }
 
+// CopyViaExternalStage writes the files to the cloud storage url of an
+// external stage (S3, GCS or Azure), and loads them with COPY INTO
+func (conn *SnowflakeConn) CopyViaExternalStage(tableFName, stageURL string, df *iop.Dataflow) (count uint64, err error) {
+	stageFolderPath := conn.stageFolderPath(tableFName)
+	folder := strings.TrimPrefix(stageFolderPath, "@"+conn.stageName()+"/")
+	fileURL := strings.TrimSuffix(stageURL, "/") + "/" + folder
+
+	fs, err := filesys.NewFileSysClientFromURLContext(conn.Context().Ctx, fileURL, conn.PropArrExclude("url")...)
+	if err != nil {
+		return 0, g.Error(err, "could not get fs client for stage url: %s", stageURL)
+	}
+
+	df.Defer(func() { conn.removeStaged(stageFolderPath) }) // cleanup
+
+	g.Info("writing to stage %s for snowflake import", conn.stageName())
+	fs.SetProp("null_as", `\N`)
+	bw, err := filesys.WriteDataflow(fs, df, fileURL)
+	if err != nil {
+		return df.Count(), g.Error(err, "Error in FileSysWriteDataflow")
+	}
+	g.DebugLow("total written: %s to %s", humanize.Bytes(cast.ToUint64(bw)), fileURL)
+
+	sql := conn.GenerateCopyFromStageSQL(tableFName, df.Columns, stageFolderPath)
+	data, err := conn.Query(sql)
+	if err != nil {
+		return df.Count(), g.Error(err, "Error with COPY INTO")
+	}
+	g.Debug("\n" + data.PrettyTable("file", "status", "rows_loaded", "errors_seen"))
+
+	return df.Count(), nil
+}
+
+// stageName returns the stage to load through: the `stage` target
+// option, else the internal stage created by sling
+func (conn *SnowflakeConn) stageName() string {
+	if stage := strings.TrimPrefix(conn.GetProp("stage"), "@"); stage != "" {
+		return stage
+	}
+	return g.F("%s.%s", conn.GetProp("schema"), conn.GetProp("internal_stage"))
+}
+
+// stageFolderPath returns the stage folder to put the table files into
+func (conn *SnowflakeConn) stageFolderPath(tableFName string) string {
+	return g.F("@%s/%s/%s", conn.stageName(), env.CleanTableName(tableFName), g.NowFileStr())
+}
+
+// removeStaged deletes the staged files once loaded, unless SLING_KEEP_TEMP
+func (conn *SnowflakeConn) removeStaged(stagePath string) {
+	if cast.ToBool(os.Getenv("SLING_KEEP_TEMP")) {
+		return
+	}
+	if _, err := conn.Exec(conn.GenerateStageRemoveSQL(stagePath) + noDebugKey); err != nil {
+		g.Warn("could not remove staged files in %s: %s", stagePath, g.ErrMsgSimple(err))
+	}
+}
+
+// StageURL returns the cloud storage url of an external stage,
+// or blank for an internal stage
+func (conn *SnowflakeConn) StageURL(stage string) (url string, err error) {
+	data, err := conn.Query("DESC STAGE " + stage + noDebugKey)
+	if err != nil {
+		return "", g.Error(err, "could not describe stage %s", stage)
+	}
+
+	for _, rec := range data.Records(true) {
+		if strings.EqualFold(cast.ToString(rec["property"]), "URL") {
+			return parseStageURL(cast.ToString(rec["property_value"])), nil
+		}
+	}
+
+	return "", nil
+}
+
+// parseStageURL parses the url property of a stage, such as
+// `["s3://bucket/path/"]`. Snowflake's `gcs://` is changed to `gs://`.
+func parseStageURL(val string) string {
+	val = strings.Trim(strings.TrimSpace(val), `[]`)
+	val = strings.Trim(strings.Split(val, ",")[0], `" `)
+	if strings.HasPrefix(val, "gcs://") {
+		val = "gs://" + strings.TrimPrefix(val, "gcs://")
+	}
+	return val
+}
+
+// GenerateCopyFromStageSQL generates the COPY INTO statement loading the
+// staged files. The `stage_file_format` prop can be a named file format,
+// or format options such as `TYPE = CSV FIELD_DELIMITER = ','`.
+func (conn *SnowflakeConn) GenerateCopyFromStageSQL(tableFName string, columns iop.Columns, stagePath string) (sql string) {
+	tgtColumns := make([]string, len(columns))
+	for i, name := range columns.Names() {
+		colName, _ := ParseColumnName(name, conn.GetType())
+		tgtColumns[i] = conn.Quote(colName)
+	}
+
+	srcColumns := make([]string, len(columns))
+	for i := range columns {
+		srcColumns[i] = g.F("T.$%d", i+1)
+	}
+
+	fileFormat := strings.TrimSpace(conn.template.Core["stage_file_format"])
+	if val := strings.TrimSpace(conn.GetProp("stage_file_format")); val != "" {
+		if strings.Contains(val, "=") {
+			fileFormat = "(" + strings.TrimSuffix(strings.TrimPrefix(val, "("), ")") + ")"
+		} else {
+			fileFormat = g.F("(FORMAT_NAME = '%s')", val)
+		}
+	}
+
+	sql = g.R(
+		conn.template.Core["copy_from_stage"],
+		"table", tableFName,
+		"tgt_columns", strings.Join(tgtColumns, ", "),
+		"src_columns", strings.Join(srcColumns, ", "),
+		"stage_path", stagePath,
+		"file_format", fileFormat,
+	)
+
+	return conn.setEmptyAsNull(sql)
+}
+
+// GenerateStagePutSQL generates the PUT statement of a local file into a stage path
+func (conn *SnowflakeConn) GenerateStagePutSQL(fileURI, stagePath string) string {
+	return g.R(
+		conn.template.Core["stage_put"],
+		"file_uri", fileURI,
+		"stage_path", stagePath,
+		"parallel", cast.ToString(runtime.NumCPU()),
+	)
+}
+
+// GenerateStageRemoveSQL generates the REMOVE statement of the files in a stage path
+func (conn *SnowflakeConn) GenerateStageRemoveSQL(stagePath string) string {
+	return g.R(conn.template.Core["stage_remove"], "stage_path", stagePath)
+}
+
 func (conn *SnowflakeConn) setEmptyAsNull(sql string) string {
 	if cast.ToBool(conn.GetProp("empty_as_null")) {
 		sql = strings.ReplaceAll(sql, "EMPTY_FIELD_AS_NULL = FALSE", "EMPTY_FIELD_AS_NULL = TRUE")
@@ -924,10 +1036,7 @@ func (conn *SnowflakeConn) StageGET(internalStagePath, folderPath string) (fileP
 
 // StagePUT Copies a local file or folder into a staging location
 func (conn *SnowflakeConn) StagePUT(fileURI string, internalStagePath string) (err error) {
-	query := g.F(
-		"PUT '%s' %s PARALLEL=%d AUTO_COMPRESS=FALSE",
-		fileURI, internalStagePath, runtime.NumCPU(),
-	)
+	query := conn.GenerateStagePutSQL(fileURI, internalStagePath)
 
 	data, err := conn.Query(query)
 	if err != nil {
@@ -941,9 +1050,9 @@ func (conn *SnowflakeConn) StagePUT(fileURI string, internalStagePath string) (e
 }
 
 // GenerateUpsertSQL generates the upsert SQL
-func (conn *SnowflakeConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string) (sql string, err error) {
+func (conn *SnowflakeConn) GenerateUpsertSQL(srcTable string, tgtTable string, pkFields []string, options ...UpsertOptions) (sql string, err error) {
 
-	upsertMap, err := conn.BaseConn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields)
+	upsertMap, err := conn.BaseConn.GenerateUpsertExpressions(srcTable, tgtTable, pkFields, options...)
 	if err != nil {
 		err = g.Error(err, "could not generate upsert variables")
 		return
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+)
+
+// Incremental reads from SQL Server Change Tracking, with CHANGETABLE(CHANGES ...).
+// Only the keys of the rows changed since a version are tracked, so the current
+// values are joined from the table (deleted rows have their primary key only).
+//
+// Setup:
+//
+//	alter database mydb set change_tracking = on (change_retention = 2 days, auto_cleanup = on);
+//	alter table dbo.orders enable change_tracking;
+//
+// The first read (no version stored) is a full read of the table. The version
+// to read after is stored in the state table, and must not be older than the
+// retention (CHANGE_TRACKING_MIN_VALID_VERSION), else a full refresh is required.
+
+// CTVersionColumn holds the change tracking version of the change
+const CTVersionColumn = "_sling_ct_version"
+
+// ChangeTrackingColumns returns the table columns with the operation &
+// version columns appended
+func ChangeTrackingColumns(tableColumns iop.Columns) (columns iop.Columns) {
+	columns = append(columns, tableColumns...)
+	columns = append(columns,
+		iop.Column{Name: CDCOperationColumn, Type: iop.StringType},
+		iop.Column{Name: CTVersionColumn, Type: iop.BigIntType},
+	)
+	for i := range columns {
+		columns[i].Position = i + 1
+	}
+	return columns
+}
+
+// ChangeTrackingSQL returns the query of the changes of the table after the
+// version. The primary key values are read from the change table, since
+// deleted rows are no longer in the table. Without a version, the full table
+// is read, as inserts at the provided current version.
+func ChangeTrackingSQL(table Table, afterVersion, currentVersion string, pk []string) string {
+	dbType := dbio.TypeDbSQLServer
+	isPk := func(name string) bool {
+		return lo.ContainsBy(pk, func(k string) bool { return strings.EqualFold(k, name) })
+	}
+
+	if afterVersion == "" {
+		fields := []string{}
+		for _, col := range table.Columns {
+			fields = append(fields, "t."+dbType.Quote(col.Name))
+		}
+		return g.F(
+			"select %s, 'insert' as %s, cast(%s as bigint) as %s from %s as t",
+			strings.Join(fields, ", "), dbType.Quote(CDCOperationColumn),
+			currentVersion, dbType.Quote(CTVersionColumn), table.FullName(),
+		)
+	}
+
+	fields := []string{}
+	for _, col := range table.Columns {
+		alias := lo.Ternary(isPk(col.Name), "ct", "t")
+		fields = append(fields, g.F("%s.%s", alias, dbType.Quote(col.Name)))
+	}
+
+	joins := []string{}
+	for _, key := range pk {
+		joins = append(joins, g.F("t.%s = ct.%s", dbType.Quote(key), dbType.Quote(key)))
+	}
+
+	return g.F(
+		`select %s, case ct.SYS_CHANGE_OPERATION when 'I' then 'insert' when 'U' then 'update' else 'delete' end as %s, ct.SYS_CHANGE_VERSION as %s from CHANGETABLE(CHANGES %s, %s) as ct left join %s as t on %s`,
+		strings.Join(fields, ", "), dbType.Quote(CDCOperationColumn), dbType.Quote(CTVersionColumn),
+		table.FullName(), cast.ToString(cast.ToInt64(afterVersion)), table.FullName(),
+		strings.Join(joins, " and "),
+	)
+}
+
+// ChangeTrackingVersions returns the current change tracking version of the
+// database, and the minimum valid version of the table
+func (conn *MsSQLServerConn) ChangeTrackingVersions(table Table) (current, minValid int64, err error) {
+	sql := g.F(
+		"select CHANGE_TRACKING_CURRENT_VERSION() as current_version, CHANGE_TRACKING_MIN_VALID_VERSION(OBJECT_ID('%s')) as min_valid_version",
+		strings.ReplaceAll(table.FullName(), "'", "''"),
+	)
+	data, err := conn.Self().Query(sql + noDebugKey)
+	if err != nil {
+		return 0, 0, g.Error(err, "could not get change tracking versions of %s", table.FullName())
+	} else if len(data.Rows) == 0 || data.Rows[0][0] == nil || data.Rows[0][1] == nil {
+		return 0, 0, g.Error("change tracking is not enabled for %s", table.FullName())
+	}
+	return cast.ToInt64(data.Rows[0][0]), cast.ToInt64(data.Rows[0][1]), nil
+}
+
+// StreamChangeTracking returns a datastream of the changes of the table after
+// the version. lastVersion is the version to store, read before the changes.
+func (conn *MsSQLServerConn) StreamChangeTracking(table Table, afterVersion string, pk []string) (ds *iop.Datastream, lastVersion string, err error) {
+	current, minValid, err := conn.ChangeTrackingVersions(table)
+	if err != nil {
+		return nil, afterVersion, err
+	}
+
+	if afterVersion != "" && cast.ToInt64(afterVersion) < minValid {
+		return nil, afterVersion, g.Error(
+			"the stored change tracking version (%s) of %s is older than the minimum valid version (%d). A full refresh is required.",
+			afterVersion, table.FullName(), minValid,
+		)
+	}
+
+	lastVersion = cast.ToString(current)
+	sql := ChangeTrackingSQL(table, afterVersion, lastVersion, pk)
+	g.Debug("reading changes of %s after change tracking version %s (current: %s)", table.FullName(), afterVersion, lastVersion)
+
+	ds, err = conn.Self().StreamRows(sql, g.M("columns", ChangeTrackingColumns(table.Columns)))
+	if err != nil {
+		return nil, afterVersion, g.Error(err, "could not read changes of %s", table.FullName())
+	}
+	return ds, lastVersion, nil
+}
@@ -3,7 +3,9 @@ package database
 import (
 	"database/sql"
 	"encoding/json"
+	"regexp"
 	"runtime/debug"
+	"sort"
 	"strings"
 	"unicode"
 
@@ -22,6 +24,7 @@ type Table struct {
 	Schema   string      `json:"schema"`
 	Database string      `json:"database,omitempty"`
 	IsView   bool        `json:"is_view,omitempty"` // whether is a view
+	Type     TableType   `json:"type,omitempty"`    // the object type, when discovered
 	SQL      string      `json:"sql,omitempty"`
 	DDL      string      `json:"ddl,omitempty"`
 	Dialect  dbio.Type   `json:"dialect,omitempty"`
@@ -30,9 +33,41 @@ type Table struct {
 
 	Raw string `json:"raw"`
 
+	// Quoting is how the names are quoted in FullName, SchemaQ & NameQ (default always)
+	Quoting dbio.QuoteIdentifiers `json:"-"`
+
 	limit, offset int
 }
 
+// TableType is the type of a database object
+type TableType string
+
+const (
+	TableTypeTable            TableType = "table"
+	TableTypeView             TableType = "view"
+	TableTypeMaterializedView TableType = "materialized_view"
+	TableTypeExternal         TableType = "external"
+)
+
+// TableTypes are the valid database object types
+var TableTypes = []TableType{TableTypeTable, TableTypeView, TableTypeMaterializedView, TableTypeExternal}
+
+// ParseTableType returns the object type of a `table_type` metadata value
+// (e.g. `BASE TABLE`, `m`, `FOREIGN`), defaulting to a table or a view
+func ParseTableType(value any, isView bool) TableType {
+	switch strings.ToLower(strings.ReplaceAll(strings.TrimSpace(cast.ToString(value)), " ", "_")) {
+	case "table", "base_table", "r", "p":
+		return TableTypeTable
+	case "view", "v":
+		return TableTypeView
+	case "materialized_view", "matview", "m":
+		return TableTypeMaterializedView
+	case "external", "external_table", "foreign", "foreign_table", "f":
+		return TableTypeExternal
+	}
+	return lo.Ternary(isView, TableTypeView, TableTypeTable)
+}
+
 var ChunkByColumn = func(conn Connection, table Table, c string, p int) ([]Table, error) {
 	return []Table{table}, nil
 }
@@ -72,8 +107,15 @@ func (t *Table) SetKeys(sourcePKCols []string, updateCol string, tableKeys Table
 		eG.Capture(t.Columns.SetMetadata(iop.PrimaryKey.MetadataKey(), "source", sourcePKCols...))
 	}
 
-	if updateCol != "" {
-		eG.Capture(t.Columns.SetMetadata(iop.UpdateKey.MetadataKey(), "source", updateCol))
+	// the update key can be composite (comma separated)
+	updateCols := []string{}
+	for _, col := range strings.Split(updateCol, ",") {
+		if col = strings.TrimSpace(col); col != "" {
+			updateCols = append(updateCols, col)
+		}
+	}
+	if len(updateCols) > 0 {
+		eG.Capture(t.Columns.SetMetadata(iop.UpdateKey.MetadataKey(), "source", updateCols...))
 	}
 
 	if tkMap := tableKeys; tkMap != nil {
@@ -82,39 +124,53 @@ func (t *Table) SetKeys(sourcePKCols []string, updateCol string, tableKeys Table
 		}
 	}
 
+	// keep the order of the primary key, the first column being the partition key
+	if t.Dialect == dbio.TypeDbCassandra && len(sourcePKCols) > 0 && len(t.Keys[iop.PrimaryKey]) == 0 {
+		keys := TableKeys{iop.PrimaryKey: sourcePKCols}
+		for tableKey, cols := range tableKeys {
+			keys[tableKey] = cols
+		}
+		t.Keys = keys
+	}
+
 	return eG.Err()
 }
 
 func (t *Table) FullName() string {
-	q := GetQualifierQuote(t.Dialect)
-
 	fdqnArr := []string{}
 	if t.Schema != "" {
-		fdqnArr = append(fdqnArr, q+t.Schema+q)
+		fdqnArr = append(fdqnArr, t.quote(t.Schema))
 	}
 	if t.Name != "" {
 		if t.Name == "*" {
 			fdqnArr = append(fdqnArr, t.Name)
 		} else {
-			fdqnArr = append(fdqnArr, q+t.Name+q)
+			fdqnArr = append(fdqnArr, t.quote(t.Name))
 		}
 	}
 	return strings.Join(fdqnArr, ".")
 }
 
+// quote quotes the name part according to the Quoting mode
+func (t *Table) quote(name string) string {
+	q := GetQualifierQuote(t.Dialect)
+	if q == "" || t.Quoting == "" || t.Quoting == dbio.QuoteIdentifiersAlways {
+		return q + name + q
+	}
+	return t.Dialect.QuoteWith(name, t.Quoting, false)
+}
+
 func (t *Table) DatabaseQ() string {
 	q := GetQualifierQuote(t.Dialect)
 	return q + t.Database + q
 }
 
 func (t *Table) SchemaQ() string {
-	q := GetQualifierQuote(t.Dialect)
-	return q + t.Schema + q
+	return t.quote(t.Schema)
 }
 
 func (t *Table) NameQ() string {
-	q := GetQualifierQuote(t.Dialect)
-	return q + t.Name + q
+	return t.quote(t.Name)
 }
 
 func (t *Table) FDQN() string {
@@ -143,6 +199,7 @@ func (t *Table) Clone() Table {
 		Schema:   t.Schema,
 		Database: t.Database,
 		IsView:   t.IsView,
+		Type:     t.Type,
 		SQL:      t.SQL,
 		DDL:      t.DDL,
 		Dialect:  t.Dialect,
@@ -172,6 +229,37 @@ func (t *Table) Select(limit, offset int, fields ...string) (sql string) {
 	switch t.Dialect {
 	case dbio.TypeDbPrometheus:
 		return t.SQL
+	case dbio.TypeDbInfluxDB:
+		if t.SQL != "" {
+			return t.SQL // limit is applied when streaming
+		}
+	case dbio.TypeDbPubSub:
+		if limit > 0 {
+			return g.Marshal(g.M("limit", limit))
+		}
+		return t.SQL
+	case dbio.TypeDbFirestore:
+		m, _ := g.UnmarshalMap(t.SQL)
+		if m == nil {
+			m = g.M()
+		}
+		if limit > 0 {
+			m["limit"] = limit
+		}
+		if len(fields) > 0 && fields[0] != "*" {
+			m["fields"] = lo.Map(fields, func(v string, i int) string {
+				return strings.TrimSpace(v)
+			})
+		}
+		if len(m) == 0 {
+			return t.SQL
+		}
+		return g.Marshal(m)
+	case dbio.TypeDbCassandra:
+		if t.IsQuery() {
+			// cql has no subqueries, the limit is added to the query
+			return cassandraLimitSQL(t.SQL, limit)
+		}
 	case dbio.TypeDbMongoDB:
 		m, _ := g.UnmarshalMap(t.SQL)
 		if m == nil {
@@ -427,8 +515,6 @@ func (s *Schemata) Filtered(columnLevel bool, filters ...string) (ns Schemata) {
 }
 
 func (s *Schemata) filterTables(filters ...string) (ns Schemata) {
-	ns = Schemata{Databases: map[string]Database{}, conn: s.conn}
-
 	var gc *glob.Glob
 	if len(filters) == 0 {
 		return *s
@@ -439,13 +525,45 @@ func (s *Schemata) filterTables(filters ...string) (ns Schemata) {
 		}
 	}
 
-	matchedTables := lo.Filter(lo.Values(s.Tables()), func(t Table, i int) bool {
+	return s.filterTablesFunc(func(t Table) bool {
 		key := strings.ToLower(g.F("%s.%s", t.Schema, t.Name))
 		if gc != nil {
 			return (*gc).Match(key)
 		}
 		return g.IsMatched(filters, key)
 	})
+}
+
+// FilteredRegex returns the tables (or columns) matching the regular expression.
+// The expression must match the full name (`schema.table` or `schema.table.column`)
+// or the last part of the name (`table` or `column`).
+func (s *Schemata) FilteredRegex(columnLevel bool, re *regexp.Regexp) (ns Schemata) {
+	if columnLevel {
+		return s.filterColumnsFunc(func(col iop.Column) bool {
+			return re.MatchString(g.F("%s.%s.%s", col.Schema, col.Table, col.Name)) || re.MatchString(col.Name)
+		})
+	}
+	return s.filterTablesFunc(func(t Table) bool {
+		return re.MatchString(g.F("%s.%s", t.Schema, t.Name)) || re.MatchString(t.Name)
+	})
+}
+
+// FilteredTypes returns the tables (or views) of the object types
+func (s *Schemata) FilteredTypes(types ...TableType) (ns Schemata) {
+	if len(types) == 0 {
+		return *s
+	}
+	return s.filterTablesFunc(func(t Table) bool {
+		return g.In(ParseTableType(string(t.Type), t.IsView), types...)
+	})
+}
+
+func (s *Schemata) filterTablesFunc(match func(t Table) bool) (ns Schemata) {
+	ns = Schemata{Databases: map[string]Database{}, conn: s.conn}
+
+	matchedTables := lo.Filter(lo.Values(s.Tables()), func(t Table, i int) bool {
+		return match(t)
+	})
 
 	if len(matchedTables) == 0 {
 		return
@@ -480,8 +598,6 @@ func (s *Schemata) filterTables(filters ...string) (ns Schemata) {
 }
 
 func (s *Schemata) filterColumns(filters ...string) (ns Schemata) {
-	ns = Schemata{Databases: map[string]Database{}, conn: s.conn}
-
 	var gc *glob.Glob
 	if len(filters) == 0 {
 		return *s
@@ -492,7 +608,7 @@ func (s *Schemata) filterColumns(filters ...string) (ns Schemata) {
 		}
 	}
 
-	matchedColumns := lo.Filter(lo.Values(s.Columns()), func(col iop.Column, i int) bool {
+	return s.filterColumnsFunc(func(col iop.Column) bool {
 		keyTable := strings.ToLower(g.F("%s.%s", col.Schema, col.Table))
 		keyCol := strings.ToLower(g.F("%s.%s.%s", col.Schema, col.Table, col.Name))
 		if gc != nil {
@@ -500,6 +616,14 @@ func (s *Schemata) filterColumns(filters ...string) (ns Schemata) {
 		}
 		return g.IsMatched(filters, keyCol) || g.IsMatched(filters, keyTable)
 	})
+}
+
+func (s *Schemata) filterColumnsFunc(match func(col iop.Column) bool) (ns Schemata) {
+	ns = Schemata{Databases: map[string]Database{}, conn: s.conn}
+
+	matchedColumns := lo.Filter(lo.Values(s.Columns()), func(col iop.Column, i int) bool {
+		return match(col)
+	})
 
 	if len(matchedColumns) == 0 {
 		return
@@ -725,7 +849,7 @@ func GetQualifierQuote(dialect dbio.Type) string {
 	switch dialect {
 	case dbio.TypeDbMySQL, dbio.TypeDbMariaDB, dbio.TypeDbStarRocks, dbio.TypeDbBigQuery, dbio.TypeDbClickhouse, dbio.TypeDbProton:
 		quote = "`"
-	case dbio.TypeDbBigTable, dbio.TypeDbMongoDB, dbio.TypeDbPrometheus:
+	case dbio.TypeDbBigTable, dbio.TypeDbMongoDB, dbio.TypeDbPrometheus, dbio.TypeDbPubSub, dbio.TypeDbFirestore:
 		quote = ""
 	}
 	return quote
@@ -888,6 +1012,389 @@ type TableIndex struct {
 	Table   *Table
 }
 
+// IndexDefinition is an index to create on the target table after the load
+// (target option `indexes`)
+type IndexDefinition struct {
+	Name    string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Columns []string `json:"columns" yaml:"columns"`
+	Unique  bool     `json:"unique,omitempty" yaml:"unique,omitempty"`
+}
+
+// TableIndex returns the index of the definition on the table.
+// If no name is provided, it is derived from the table & column names.
+func (def IndexDefinition) TableIndex(t *Table) TableIndex {
+	name := def.Name
+	if name == "" {
+		nameParts := []string{strings.ToLower(t.Name)}
+		for _, col := range def.Columns {
+			nameParts = append(nameParts, strings.ToLower(col))
+		}
+		name = strings.Join(append(nameParts, lo.Ternary(def.Unique, "uidx", "idx")), "_")
+	}
+
+	columns := make(iop.Columns, len(def.Columns))
+	for i, col := range def.Columns {
+		columns[i] = iop.Column{Name: col, Position: i + 1}
+	}
+
+	return TableIndex{Name: name, Columns: columns, Unique: def.Unique, Table: t}
+}
+
+// IndexesDDL returns the statements creating the index definitions on the
+// table, skipping the existing index names. Snowflake has no indexes, so the
+// columns of all the definitions are set as the clustering key instead.
+func (t *Table) IndexesDDL(defs []IndexDefinition, existing []string) (ddls []string) {
+	if len(defs) == 0 {
+		return
+	}
+
+	if t.Dialect == dbio.TypeDbSnowflake {
+		colNames := []string{}
+		for _, def := range defs {
+			colNames = append(colNames, def.Columns...)
+		}
+		quotedNames := t.Dialect.QuoteNames(lo.Uniq(colNames)...)
+		ddl := g.R(
+			t.Dialect.GetTemplateValue("core.cluster_by"),
+			"table", t.FDQN(),
+			"cols", strings.Join(quotedNames, ", "),
+		)
+		return []string{ddl}
+	}
+
+	for _, def := range defs {
+		index := def.TableIndex(t)
+		if lo.ContainsBy(existing, func(name string) bool { return strings.EqualFold(name, index.Name) }) {
+			g.Debug("index %s already exists on %s", index.Name, t.FullName())
+			continue
+		}
+		ddls = append(ddls, index.CreateDDL())
+	}
+
+	return
+}
+
+// GrantDefinition is a privilege to grant on the target table after it is created
+// (target option `grants`), e.g. `{privilege: SELECT, to: role_analyst}`
+type GrantDefinition struct {
+	Privilege string `json:"privilege" yaml:"privilege"`
+	To        string `json:"to" yaml:"to"`
+}
+
+var (
+	grantPrivilegeRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z_ ,]*$`)
+	grantToRegex        = regexp.MustCompile(`^[\w\.\-@"' ]+$`)
+)
+
+// Validate checks that the privilege and grantee are plain names
+func (def GrantDefinition) Validate() error {
+	if !grantPrivilegeRegex.MatchString(strings.TrimSpace(def.Privilege)) {
+		return g.Error("invalid grant privilege: %#v", def.Privilege)
+	} else if !grantToRegex.MatchString(strings.TrimSpace(def.To)) {
+		return g.Error("invalid grant grantee: %#v", def.To)
+	}
+	return nil
+}
+
+// GrantsDDL returns the statements granting the privileges on the table,
+// in the dialect syntax (`core.grant_table` template)
+func (t *Table) GrantsDDL(defs []GrantDefinition) (ddls []string, err error) {
+	if len(defs) == 0 {
+		return
+	}
+
+	template := t.Dialect.GetTemplateValue("core.grant_table")
+	if template == "" {
+		return nil, g.Error("grants are not supported for %s", t.Dialect)
+	}
+
+	for _, def := range defs {
+		if err = def.Validate(); err != nil {
+			return nil, err
+		}
+		ddls = append(ddls, g.R(
+			template,
+			"privilege", strings.ToUpper(strings.TrimSpace(def.Privilege)),
+			"table", t.FDQN(),
+			"grantee", strings.TrimSpace(def.To),
+		))
+	}
+
+	return
+}
+
+// CreateViewDDL returns the statement creating the table as a view of the
+// select query, in the dialect syntax (`core.create_view` template)
+func (t *Table) CreateViewDDL(sql string) (ddl string, err error) {
+	sql = strings.TrimSuffix(strings.TrimSpace(sql), ";")
+	if sql == "" {
+		return "", g.Error("need a select query to create view %s", t.FullName())
+	}
+
+	template := t.Dialect.GetTemplateValue("core.create_view")
+	if template == "" {
+		return "", g.Error("views are not supported for %s", t.Dialect)
+	}
+
+	return g.R(template, "view", t.FDQN(), "sql", sql), nil
+}
+
+// SwapSupported returns true if the dialect can swap a table with another by
+// renaming atomically (target option `write_mode: atomic-swap`)
+func SwapSupported(dialect dbio.Type) bool {
+	return g.In(dialect,
+		dbio.TypeDbPostgres, dbio.TypeDbRedshift, dbio.TypeDbDuckDb, dbio.TypeDbMySQL, dbio.TypeDbMariaDB,
+		dbio.TypeDbSnowflake, dbio.TypeDbSQLServer, dbio.TypeDbAzure, dbio.TypeDbClickhouse,
+	)
+}
+
+// SwapDDL returns the statements replacing the table with the staging table,
+// in the dialect syntax, then the statement dropping the previous table. When
+// renaming the table aside, a leftover previous table is dropped first. If the
+// table does not exist, the staging table is renamed (and dropDDL is empty).
+func (t *Table) SwapDDL(staging Table, exists bool) (ddls []string, dropDDL string, err error) {
+	if !SwapSupported(t.Dialect) {
+		return nil, "", g.Error("atomic swap is not supported for %s", t.Dialect)
+	}
+
+	old := t.Clone()
+	old.Quoting = t.Quoting
+	old.Name += lo.Ternary(t.Dialect.DBNameUpperCase(), "_OLD", "_old")
+
+	switch t.Dialect {
+	case dbio.TypeDbSnowflake:
+		if !exists {
+			return []string{g.F("alter table %s rename to %s", staging.FullName(), t.FullName())}, "", nil
+		}
+		// swapped in place: the staging table holds the previous rows
+		ddls = []string{g.F("alter table %s swap with %s", staging.FullName(), t.FullName())}
+		return ddls, g.R(t.Dialect.GetTemplateValue("core.drop_table"), "table", staging.FullName()), nil
+
+	case dbio.TypeDbClickhouse:
+		if !exists {
+			return []string{g.F("rename table %s to %s", staging.FullName(), t.FullName())}, "", nil
+		}
+		ddls = []string{g.F("exchange tables %s and %s", staging.FullName(), t.FullName())}
+		return ddls, g.R(t.Dialect.GetTemplateValue("core.drop_table"), "table", staging.FullName()), nil
+
+	case dbio.TypeDbMySQL, dbio.TypeDbMariaDB:
+		if !exists {
+			return []string{g.F("rename table %s to %s", staging.FullName(), t.FullName())}, "", nil
+		}
+		// both renames in one atomic statement
+		ddls = []string{g.F("rename table %s to %s, %s to %s", t.FullName(), old.FullName(), staging.FullName(), t.FullName())}
+
+	case dbio.TypeDbSQLServer, dbio.TypeDbAzure:
+		if staging.Schema != t.Schema {
+			return nil, "", g.Error("the temp table %s should be in the schema of %s to swap", staging.FullName(), t.FullName())
+		}
+		rename := func(table Table, newName string) string {
+			return g.F("exec sp_rename '%s.%s', '%s'", table.Schema, table.Name, newName)
+		}
+		if exists {
+			ddls = append(ddls, rename(*t, old.Name))
+		}
+		ddls = append(ddls, rename(staging, t.Name))
+
+	default:
+		// renamed within the schema
+		if staging.Schema != t.Schema {
+			return nil, "", g.Error("the temp table %s should be in the schema of %s to swap", staging.FullName(), t.FullName())
+		}
+		if exists {
+			ddls = append(ddls, g.F("alter table %s rename to %s", t.FullName(), old.NameQ()))
+		}
+		ddls = append(ddls, g.F("alter table %s rename to %s", staging.FullName(), t.NameQ()))
+	}
+
+	if exists {
+		// a leftover of a failed swap would fail the rename
+		dropDDL = g.R(t.Dialect.GetTemplateValue("core.drop_table"), "table", old.FullName())
+		ddls = append([]string{dropDDL}, ddls...)
+	}
+	return ddls, dropDDL, nil
+}
+
+// ViewReplaceable returns true if the dialect replaces an existing view in
+// place (`create or replace` / `create or alter`), otherwise it is dropped first
+func ViewReplaceable(dialect dbio.Type) bool {
+	template := strings.ToLower(dialect.GetTemplateValue("core.create_view"))
+	return strings.Contains(template, " or replace ") || strings.Contains(template, " or alter ")
+}
+
+// JSONExtractSQL wraps the select query of the table to extract nested JSON
+// fields into columns, with the JSON function of the dialect. The values are
+// extracted as text, and cast to the extract types in the stream.
+func (t *Table) JSONExtractSQL(extracts []iop.JSONExtract) (sql string, err error) {
+	template := t.Dialect.GetTemplateValue("function.json_extract")
+	if template == "" {
+		return "", g.Error("JSON extraction is not supported for %s", t.Dialect)
+	}
+
+	sql = strings.TrimSuffix(strings.TrimSpace(t.SQL), ";")
+	if sql == "" {
+		sql = t.Select(0, 0)
+	} else if g.In(t.Dialect, dbio.TypeDbSQLServer, dbio.TypeDbAzure, dbio.TypeDbAzureDWH) && strings.HasPrefix(strings.ToLower(sql), "with") {
+		return "", g.Error("cannot wrap a query starting with WITH for %s", t.Dialect)
+	}
+
+	fields := []string{"t.*"}
+	for _, extract := range extracts {
+		if err = extract.Validate(); err != nil {
+			return "", err
+		}
+
+		pathKeys := lo.Map(extract.Keys(), func(key any, i int) string {
+			if index, ok := key.(int); ok {
+				return cast.ToString(index)
+			}
+			return `"` + cast.ToString(key) + `"`
+		})
+
+		from := extract.From
+		if col := t.Columns.GetColumn(from); col != nil {
+			from = col.Name // the casing of the source column
+		}
+
+		expr := g.R(
+			template,
+			"field", "t."+t.Dialect.Quote(from, false),
+			"path", extract.PathSQL(),
+			"path_keys", strings.Join(pathKeys, ","),
+		)
+		fields = append(fields, expr+" as "+t.Dialect.Quote(extract.As, false))
+	}
+
+	// a derived table cannot be ordered (e.g. SQL Server), order the wrapping query instead
+	sql, orderBy := splitOrderBy(sql)
+	sql = g.F("select %s from (\n%s\n) t", strings.Join(fields, ", "), sql)
+	if orderBy != "" {
+		sql = sql + " order by " + orderBy
+	}
+	return sql, nil
+}
+
+// orderByQualifier matches the table qualifier of an order by expression
+var orderByQualifier = regexp.MustCompile("^(\\w+|\"[^\"]+\"|`[^`]+`|\\[[^\\]]+\\])\\.")
+
+// splitOrderBy splits the trailing ORDER BY clause of the query, outside of
+// parentheses and quotes. The clause is kept in the query when followed by
+// a limit or an offset, or by a function call. The qualifiers of the expressions are replaced by `t`,
+// the alias of the wrapping derived table.
+func splitOrderBy(sql string) (body, orderBy string) {
+	lower := strings.ToLower(sql)
+	depth, quote, pos := 0, rune(0), -1
+	for i, r := range lower {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '[':
+			quote = ']'
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(lower[i:], "order by") && (i == 0 || unicode.IsSpace(rune(lower[i-1]))):
+			pos = i
+		}
+	}
+	if pos == -1 || strings.HasPrefix(strings.TrimSpace(lower), "select top ") {
+		return sql, "" // the rows of a top query depend on the order
+	}
+
+	clause := strings.TrimSpace(sql[pos+len("order by"):])
+	for _, word := range strings.Fields(strings.ToLower(clause)) {
+		if g.In(word, "limit", "offset", "fetch", "for") || strings.Contains(word, ")") {
+			return sql, "" // limited, or not at the end
+		}
+	}
+
+	exprs := strings.Split(clause, ",")
+	for i, expr := range exprs {
+		exprs[i] = orderByQualifier.ReplaceAllString(strings.TrimSpace(expr), "t.")
+	}
+	return strings.TrimSpace(sql[:pos]), strings.Join(exprs, ", ")
+}
+
+// TableComments are the descriptions of a table and of its columns
+// (column name -> comment)
+type TableComments struct {
+	Table   string            `json:"table,omitempty"`
+	Columns map[string]string `json:"columns,omitempty"`
+
+	// Definitions are the column definitions (column name -> definition), for
+	// dialects re-stating them to comment a column (mysql)
+	Definitions map[string]string `json:"-"`
+}
+
+// IsEmpty returns true if there are no comments
+func (tc TableComments) IsEmpty() bool {
+	return strings.TrimSpace(tc.Table) == "" && len(tc.Columns) == 0
+}
+
+// CommentsSupported returns true if the dialect can comment tables
+func CommentsSupported(dialect dbio.Type) bool {
+	return dialect.GetTemplateValue("core.comment_table") != ""
+}
+
+// commentLiteral returns the comment as a quoted string literal
+func commentLiteral(dialect dbio.Type, comment string) string {
+	switch dialect {
+	case dbio.TypeDbSnowflake, dbio.TypeDbMySQL, dbio.TypeDbMariaDB, dbio.TypeDbStarRocks,
+		dbio.TypeDbBigQuery, dbio.TypeDbClickhouse:
+		// backslash is an escape character in string literals
+		comment = strings.ReplaceAll(comment, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(comment, "'", "''") + "'"
+}
+
+// CommentsDDL returns the statements commenting the table and its columns,
+// in the dialect syntax (`core.comment_table` & `core.comment_column`
+// templates). Column comments are skipped if the dialect cannot set them, or
+// if the template needs the column definition and it is unknown.
+func (t *Table) CommentsDDL(comments TableComments) (ddls []string) {
+	if comment := strings.TrimSpace(comments.Table); comment != "" {
+		if template := t.Dialect.GetTemplateValue("core.comment_table"); template != "" {
+			ddls = append(ddls, g.R(
+				template,
+				"table", t.FDQN(),
+				"comment", commentLiteral(t.Dialect, comment),
+			))
+		}
+	}
+
+	template := t.Dialect.GetTemplateValue("core.comment_column")
+	if template == "" {
+		return
+	}
+
+	columnNames := lo.Keys(comments.Columns)
+	sort.Strings(columnNames)
+	for _, name := range columnNames {
+		comment := strings.TrimSpace(comments.Columns[name])
+		definition := comments.Definitions[name]
+		if comment == "" {
+			continue
+		} else if strings.Contains(template, "{definition}") && definition == "" {
+			g.Debug("could not comment column %s, its definition is unknown", name)
+			continue
+		}
+		ddls = append(ddls, g.R(
+			template,
+			"table", t.FDQN(),
+			"column", t.Dialect.Quote(name, false),
+			"definition", definition,
+			"comment", commentLiteral(t.Dialect, comment),
+		))
+	}
+
+	return
+}
+
 func (ti *TableIndex) CreateDDL() string {
 	dialect := ti.Table.Dialect
 	quotedNames := dialect.QuoteNames(ti.Columns.Names()...)
@@ -4,7 +4,9 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/flarco/g"
 	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -198,3 +200,348 @@ func TestParseColumnName(t *testing.T) {
 		assert.Equal(t, c.output, column, c)
 	}
 }
+
+func TestIndexesDDL(t *testing.T) {
+	defs := []IndexDefinition{
+		{Columns: []string{"customer_id"}},
+		{Name: "orders_ref_key", Columns: []string{"ref", "created_at"}, Unique: true},
+	}
+
+	// postgres
+	table, err := ParseTableName("public.orders", dbio.TypeDbPostgres)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ddls := table.IndexesDDL(defs, nil)
+	if assert.Len(t, ddls, 2) {
+		assert.Equal(t, `create index if not exists "orders_customer_id_idx" on "public"."orders" ("customer_id")`, ddls[0])
+		assert.Equal(t, `create unique index if not exists "orders_ref_key" on "public"."orders" ("ref", "created_at")`, ddls[1])
+	}
+
+	// existing indexes are skipped
+	ddls = table.IndexesDDL(defs, []string{"ORDERS_REF_KEY"})
+	if assert.Len(t, ddls, 1) {
+		assert.Contains(t, ddls[0], "orders_customer_id_idx")
+	}
+
+	// mysql
+	table, err = ParseTableName("mydb.orders", dbio.TypeDbMySQL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ddls = table.IndexesDDL(defs, nil)
+	if assert.Len(t, ddls, 2) {
+		assert.Equal(t, "create index `orders_customer_id_idx` on `mydb`.`orders` (`customer_id`)", ddls[0])
+		assert.True(t, strings.HasPrefix(ddls[1], "create unique index `orders_ref_key` on `mydb`.`orders`"))
+	}
+
+	// snowflake, as a single clustering key
+	table, err = ParseTableName("public.orders", dbio.TypeDbSnowflake)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ddls = table.IndexesDDL(defs, nil)
+	if assert.Len(t, ddls, 1) {
+		assert.True(t, strings.HasPrefix(ddls[0], "alter table "))
+		assert.Contains(t, strings.ToLower(ddls[0]), `cluster by ("customer_id", "ref", "created_at")`)
+	}
+
+	assert.Empty(t, table.IndexesDDL(nil, nil))
+}
+
+func TestGrantsDDL(t *testing.T) {
+	defs := []GrantDefinition{
+		{Privilege: "select", To: "role_analyst"},
+		{Privilege: "INSERT, UPDATE", To: "etl_user"},
+	}
+
+	// postgres
+	table, err := ParseTableName("public.orders", dbio.TypeDbPostgres)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ddls, err := table.GrantsDDL(defs)
+	if assert.NoError(t, err) && assert.Len(t, ddls, 2) {
+		assert.Equal(t, `grant SELECT on "public"."orders" to role_analyst`, ddls[0])
+		assert.Equal(t, `grant INSERT, UPDATE on "public"."orders" to etl_user`, ddls[1])
+	}
+
+	// snowflake, granted to roles
+	table, err = ParseTableName("public.orders", dbio.TypeDbSnowflake)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ddls, err = table.GrantsDDL(defs[:1])
+	if assert.NoError(t, err) && assert.Len(t, ddls, 1) {
+		assert.True(t, strings.HasPrefix(ddls[0], "grant SELECT on table "))
+		assert.True(t, strings.HasSuffix(ddls[0], `"ORDERS" to role role_analyst`))
+	}
+
+	// invalid privilege or grantee
+	_, err = table.GrantsDDL([]GrantDefinition{{Privilege: "select; drop table x", To: "role_analyst"}})
+	assert.Error(t, err)
+	_, err = table.GrantsDDL([]GrantDefinition{{Privilege: "select", To: ""}})
+	assert.Error(t, err)
+
+	// no grants in sqlite
+	table, err = ParseTableName("main.orders", dbio.TypeDbSQLite)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = table.GrantsDDL(defs)
+	assert.Error(t, err)
+}
+
+func TestCreateViewDDL(t *testing.T) {
+	sql := "select id, amount from public.orders where status = 'open';\n"
+
+	// postgres, replaced in place
+	table, err := ParseTableName("analytics.open_orders", dbio.TypeDbPostgres)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ddl, err := table.CreateViewDDL(sql)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `create or replace view "analytics"."open_orders" as select id, amount from public.orders where status = 'open'`, ddl)
+	}
+	assert.True(t, ViewReplaceable(dbio.TypeDbPostgres))
+
+	// sql server
+	table, err = ParseTableName("dbo.open_orders", dbio.TypeDbSQLServer)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ddl, err = table.CreateViewDDL(sql)
+	if assert.NoError(t, err) {
+		assert.True(t, strings.HasPrefix(ddl, `create or alter view "dbo"."open_orders" as select id`))
+	}
+	assert.True(t, ViewReplaceable(dbio.TypeDbSQLServer))
+
+	// sqlite, dropped first
+	table, err = ParseTableName("main.open_orders", dbio.TypeDbSQLite)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ddl, err = table.CreateViewDDL(sql)
+	if assert.NoError(t, err) {
+		assert.True(t, strings.HasPrefix(ddl, `create view "main"."open_orders" as select id`))
+	}
+	assert.False(t, ViewReplaceable(dbio.TypeDbSQLite))
+
+	// no query
+	_, err = table.CreateViewDDL(" ; ")
+	assert.Error(t, err)
+}
+
+func TestSwapDDL(t *testing.T) {
+	parse := func(name string, dialect dbio.Type) Table {
+		table, err := ParseTableName(name, dialect)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return table
+	}
+
+	// postgres: renamed within the schema, then the previous table dropped
+	target, staging := parse("public.orders", dbio.TypeDbPostgres), parse("public.orders_tmp", dbio.TypeDbPostgres)
+	ddls, dropDDL, err := target.SwapDDL(staging, true)
+	if assert.NoError(t, err) && assert.Len(t, ddls, 3) {
+		assert.Equal(t, `drop table if exists "public"."orders_old"`, ddls[0]) // leftover
+		assert.Equal(t, `alter table "public"."orders" rename to "orders_old"`, ddls[1])
+		assert.Equal(t, `alter table "public"."orders_tmp" rename to "orders"`, ddls[2])
+		assert.Equal(t, `drop table if exists "public"."orders_old"`, dropDDL)
+	}
+
+	// the target does not exist yet
+	ddls, dropDDL, err = target.SwapDDL(staging, false)
+	if assert.NoError(t, err) && assert.Len(t, ddls, 1) {
+		assert.Equal(t, `alter table "public"."orders_tmp" rename to "orders"`, ddls[0])
+		assert.Empty(t, dropDDL)
+	}
+
+	// the staging table must be in the same schema
+	_, _, err = target.SwapDDL(parse("staging.orders_tmp", dbio.TypeDbPostgres), true)
+	assert.ErrorContains(t, err, "schema")
+
+	// mysql: both renames in one statement
+	target, staging = parse("db.orders", dbio.TypeDbMySQL), parse("db.orders_tmp", dbio.TypeDbMySQL)
+	ddls, dropDDL, err = target.SwapDDL(staging, true)
+	if assert.NoError(t, err) && assert.Len(t, ddls, 2) {
+		assert.Equal(t, "rename table `db`.`orders` to `db`.`orders_old`, `db`.`orders_tmp` to `db`.`orders`", ddls[1])
+		assert.Contains(t, dropDDL, "`db`.`orders_old`")
+	}
+
+	// snowflake: swapped in place, the staging table then holds the previous rows
+	target, staging = parse("public.orders", dbio.TypeDbSnowflake), parse("public.orders_tmp", dbio.TypeDbSnowflake)
+	ddls, dropDDL, err = target.SwapDDL(staging, true)
+	if assert.NoError(t, err) && assert.Len(t, ddls, 1) {
+		assert.Equal(t, `alter table "PUBLIC"."ORDERS_TMP" swap with "PUBLIC"."ORDERS"`, ddls[0])
+		assert.Contains(t, dropDDL, `"PUBLIC"."ORDERS_TMP"`)
+	}
+
+	// sql server: sp_rename
+	target, staging = parse("dbo.orders", dbio.TypeDbSQLServer), parse("dbo.orders_tmp", dbio.TypeDbSQLServer)
+	ddls, _, err = target.SwapDDL(staging, true)
+	if assert.NoError(t, err) && assert.Len(t, ddls, 3) {
+		assert.Contains(t, ddls[0], `DROP TABLE "dbo"."orders_old"`)
+		assert.Equal(t, "exec sp_rename 'dbo.orders', 'orders_old'", ddls[1])
+		assert.Equal(t, "exec sp_rename 'dbo.orders_tmp', 'orders'", ddls[2])
+	}
+
+	// not supported
+	target, staging = parse("main.orders", dbio.TypeDbSQLite), parse("main.orders_tmp", dbio.TypeDbSQLite)
+	_, _, err = target.SwapDDL(staging, true)
+	assert.ErrorContains(t, err, "not supported")
+}
+
+func TestCommentsDDL(t *testing.T) {
+	comments := TableComments{
+		Table: "customer orders",
+		Columns: map[string]string{
+			"status":  "order status, e.g. 'open'",
+			"id":      "order id",
+			"ignored": " ",
+			"notes":   `path C:\orders`,
+		},
+	}
+
+	// postgres
+	table, err := ParseTableName("public.orders", dbio.TypeDbPostgres)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ddls := table.CommentsDDL(comments)
+	if assert.Len(t, ddls, 4) {
+		assert.Equal(t, `comment on table "public"."orders" is 'customer orders'`, ddls[0])
+		assert.Equal(t, `comment on column "public"."orders"."id" is 'order id'`, ddls[1])
+		assert.Equal(t, `comment on column "public"."orders"."notes" is 'path C:\orders'`, ddls[2])
+		assert.Equal(t, `comment on column "public"."orders"."status" is 'order status, e.g. ''open'''`, ddls[3])
+	}
+
+	// snowflake, with escaped backslashes
+	table, err = ParseTableName("public.orders", dbio.TypeDbSnowflake)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ddls = table.CommentsDDL(comments)
+	if assert.Len(t, ddls, 4) {
+		assert.True(t, strings.HasPrefix(ddls[0], "comment on table "))
+		assert.True(t, strings.HasSuffix(ddls[0], `"ORDERS" is 'customer orders'`))
+		assert.True(t, strings.HasPrefix(ddls[2], "comment on column "))
+		assert.True(t, strings.HasSuffix(ddls[2], `"ORDERS"."notes" is 'path C:\\orders'`))
+		assert.True(t, strings.HasSuffix(ddls[3], `"ORDERS"."status" is 'order status, e.g. ''open'''`))
+	}
+
+	// mysql re-states the column definitions, skipping unknown ones
+	table, err = ParseTableName("mydb.orders", dbio.TypeDbMySQL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	comments.Definitions = map[string]string{"id": "bigint not null auto_increment", "status": "varchar(10) default 'open'"}
+	ddls = table.CommentsDDL(comments)
+	if assert.Len(t, ddls, 3) {
+		assert.Equal(t, "alter table `mydb`.`orders` comment = 'customer orders'", ddls[0])
+		assert.Equal(t, "alter table `mydb`.`orders` modify column `id` bigint not null auto_increment comment 'order id'", ddls[1])
+		assert.Equal(t, "alter table `mydb`.`orders` modify column `status` varchar(10) default 'open' comment 'order status, e.g. ''open'''", ddls[2])
+	}
+
+	// sqlite does not comment at all
+
+	assert.True(t, CommentsSupported(dbio.TypeDbPostgres))
+	assert.False(t, CommentsSupported(dbio.TypeDbSQLite))
+	table, err = ParseTableName("main.orders", dbio.TypeDbSQLite)
+	if assert.NoError(t, err) {
+		assert.Empty(t, table.CommentsDDL(comments))
+	}
+}
+
+func TestJSONExtractSQL(t *testing.T) {
+	extracts := []iop.JSONExtract{
+		{From: "payload", Path: "$.user.id", As: "user_id", Type: iop.BigIntType},
+		{From: "payload", Path: `$.items[0]["first name"]`, As: "first_name"},
+	}
+
+	// postgres table, with the source column casing
+	table, err := ParseTableName("public.events", dbio.TypeDbPostgres)
+	if !assert.NoError(t, err) {
+		return
+	}
+	table.Columns = iop.Columns{{Name: "Payload", Type: iop.JsonType, Position: 1}}
+	sql, err := table.JSONExtractSQL(extracts)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `select t.*, (t."Payload")::jsonb #>> '{"user","id"}' as "user_id", (t."Payload")::jsonb #>> '{"items",0,"first name"}' as "first_name" from (
+select * from "public"."events"
+) t`, sql)
+	}
+
+	// mysql query
+	table, err = ParseTableName("select * from events where id > 10;", dbio.TypeDbMySQL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	sql, err = table.JSONExtractSQL(extracts[:1])
+	if assert.NoError(t, err) {
+		assert.Equal(t, "select t.*, json_unquote(json_extract(t.`payload`, '$.user.id')) as `user_id` from (\nselect * from events where id > 10\n) t", sql)
+	}
+
+	// duckdb
+	table, err = ParseTableName("main.events", dbio.TypeDbDuckDb)
+	if !assert.NoError(t, err) {
+		return
+	}
+	sql, err = table.JSONExtractSQL(extracts[1:])
+	if assert.NoError(t, err) {
+		assert.Contains(t, sql, `json_extract_string(t."payload", '$.items[0]."first name"') as "first_name"`)
+	}
+
+	// sql server incremental query: the order by is applied to the wrapping query
+	table, err = ParseTableName("dbo.events", dbio.TypeDbSQLServer)
+	if !assert.NoError(t, err) {
+		return
+	}
+	table.SQL = g.R(
+		dbio.TypeDbSQLServer.GetTemplateValue("core.incremental_select"),
+		"fields", "*",
+		"table", table.FullName(),
+		"incremental_where_cond", "[updated_at] > '2024-01-01'",
+		"update_key", "[updated_at]",
+	)
+	sql, err = table.JSONExtractSQL(extracts[:1])
+	if assert.NoError(t, err) {
+		assert.Equal(t, "select t.*, json_value(t.[payload], '$.user.id') as [user_id] from (\nselect * from [dbo].[events] where [updated_at] > '2024-01-01'\n) t order by [updated_at] asc", sql)
+	}
+
+	// the qualifiers of the order by are replaced, limited queries are kept as is
+	table.SQL = "select e.* from dbo.events e join dbo.users u on u.id = e.user_id order by e.[updated_at] desc, u.id"
+	sql, err = table.JSONExtractSQL(extracts[:1])
+	if assert.NoError(t, err) {
+		assert.True(t, strings.HasSuffix(sql, "on u.id = e.user_id\n) t order by t.[updated_at] desc, t.id"), sql)
+	}
+	for _, query := range []string{
+		"select top 10 * from dbo.events order by id",
+		"select * from dbo.events order by id offset 10 rows",
+		"select * from (select * from dbo.events order by id offset 0 rows) x",
+	} {
+		body, orderBy := splitOrderBy(query)
+		assert.Equal(t, query, body)
+		assert.Empty(t, orderBy)
+	}
+
+	// not supported, extracted in the stream
+	table, err = ParseTableName("system.events", dbio.TypeDbOracle)
+	if assert.NoError(t, err) {
+		_, err = table.JSONExtractSQL(extracts)
+		assert.Error(t, err)
+	}
+}
+
+func TestTableSetKeys(t *testing.T) {
+	table := Table{Name: "events", Dialect: dbio.TypeDbPostgres, Columns: iop.Columns{{Name: "id"}, {Name: "updated_at"}, {Name: "seq"}}}
+
+	// a composite update key is split
+	if assert.NoError(t, table.SetKeys([]string{"id"}, "updated_at, seq", nil)) {
+		assert.Equal(t, "source", table.Columns.GetColumn("updated_at").Metadata[iop.UpdateKey.MetadataKey()])
+		assert.Equal(t, "source", table.Columns.GetColumn("seq").Metadata[iop.UpdateKey.MetadataKey()])
+		assert.Empty(t, table.Columns.GetColumn("id").Metadata[iop.UpdateKey.MetadataKey()])
+	}
+}
@@ -449,7 +449,7 @@ func InsertBatchStream(conn Connection, tx Transaction, tableFName string, ds *i
 }
 
 // Upsert upserts from source table into target table
-func Upsert(conn Connection, tx Transaction, sourceTable, targetTable string, pkFields []string) (count int64, err error) {
+func Upsert(conn Connection, tx Transaction, sourceTable, targetTable string, pkFields []string, options ...UpsertOptions) (count int64, err error) {
 
 	srcTable, err := ParseTableName(sourceTable, conn.GetType())
 	if err != nil {
@@ -463,7 +463,7 @@ func Upsert(conn Connection, tx Transaction, sourceTable, targetTable string, pk
 		return
 	}
 
-	q, err := conn.GenerateUpsertSQL(srcTable.FullName(), tgtTable.FullName(), pkFields)
+	q, err := conn.GenerateUpsertSQL(srcTable.FullName(), tgtTable.FullName(), pkFields, options...)
 	if err != nil {
 		err = g.Error(err, "could not generate upsert sql")
 		return
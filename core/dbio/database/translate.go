@@ -0,0 +1,211 @@
+package database
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+)
+
+var (
+	translateTopRegex    = regexp.MustCompile(`(?is)^(\s*select\s+(?:distinct\s+)?)top\s*(?:\(\s*(\d+)\s*\)|(\d+))\s+`)
+	translateTopXRegex   = regexp.MustCompile(`(?i)^(?:percent|with\s+ties)\b`)
+	translateLimitRegex  = regexp.MustCompile(`(?is)\blimit\s+(\d+)\s*$`)
+	translateFetchRegex  = regexp.MustCompile(`(?is)\bfetch\s+(?:first|next)\s+(\d+)\s+rows?\s+only\s*$`)
+	translateLimitXRegex = regexp.MustCompile(`(?i)\b(?:limit|offset)\b`)
+	translateOffsetRegex = regexp.MustCompile(`(?i)\boffset\b`)
+	translateSelectRegex = regexp.MustCompile(`(?is)^\s*select\s+(?:distinct\s+)?`)
+	translateNowRegex    = regexp.MustCompile(`(?i)\b(?:now\s*\(\s*\)|getdate\s*\(\s*\)|current_timestamp\b(?:\s*\(\s*\))?)`)
+	translateTodayRegex  = regexp.MustCompile(`(?i)\b(?:current_date\b(?:\s*\(\s*\))?|curdate\s*\(\s*\))`)
+	translateIdentRegex  = regexp.MustCompile("\"([^\"]+)\"|`([^`]+)`|(^|[^\\w\\])])\\[([^\\[\\]]+)\\]")
+)
+
+// TranslateSQL rewrites a small dialect-neutral subset of SQL into the dialect
+// of the connection type: the identifier quoting (`"col"`, “ `col` “ or `[col]`),
+// the row limit (`limit n`, `top n` or `fetch first n rows only`) and the
+// current timestamp / date functions. String literals and comments are left
+// untouched. SQL which cannot be translated safely (such as a limit with an
+// offset) is returned untouched, with an error describing why.
+func TranslateSQL(sql string, dialect dbio.Type) (string, error) {
+	if !dialect.IsDb() || dialect.IsNoSQL() || dialect.GetTemplateValue("variable.quote_char") == "" {
+		return sql, g.Error("cannot translate SQL for %s", dialect)
+	}
+
+	statements := ParseSQLMultiStatements(sql)
+	if len(statements) > 1 {
+		return sql, g.Error("cannot translate multiple statements, found %d", len(statements))
+	} else if len(statements) == 0 {
+		return sql, nil
+	}
+
+	newSQL := strings.TrimSuffix(strings.TrimSpace(statements[0]), ";")
+
+	// current timestamp / date functions
+	newSQL = translateReplace(newSQL, true, translateNowRegex, func(groups []string) string {
+		return dialect.GetTemplateValue("function.current_timestamp")
+	})
+	newSQL = translateReplace(newSQL, true, translateTodayRegex, func(groups []string) string {
+		return dialect.GetTemplateValue("function.current_date")
+	})
+
+	// row limit
+	newSQL, err := translateLimit(newSQL, dialect)
+	if err != nil {
+		return sql, err
+	}
+
+	// identifier quoting
+	q := dialect.GetTemplateValue("variable.quote_char")
+	newSQL = translateReplace(newSQL, false, translateIdentRegex, func(groups []string) string {
+		switch {
+		case groups[1] != "":
+			return q + groups[1] + q
+		case groups[2] != "":
+			return q + groups[2] + q
+		}
+		return groups[3] + q + groups[4] + q
+	})
+
+	if strings.HasSuffix(strings.TrimSpace(sql), ";") {
+		newSQL = newSQL + ";"
+	}
+
+	return newSQL, nil
+}
+
+// translateLimit rewrites the row limit of the select into the limit style of the dialect
+func translateLimit(sql string, dialect dbio.Type) (string, error) {
+	masked := translateMask(sql, true)
+
+	limit := ""
+	if m := translateTopRegex.FindStringSubmatchIndex(masked); m != nil {
+		if translateTopXRegex.MatchString(masked[m[1]:]) {
+			return sql, g.Error("cannot translate top with percent or ties")
+		}
+		if m[4] > -1 {
+			limit = masked[m[4]:m[5]] // top (n)
+		} else {
+			limit = masked[m[6]:m[7]]
+		}
+		sql = sql[:m[3]] + sql[m[1]:] // remove the top clause
+	} else if m := translateLimitRegex.FindStringSubmatchIndex(masked); m != nil {
+		limit = masked[m[2]:m[3]]
+		sql = translateTrimRight(sql[:m[0]])
+	} else if m := translateFetchRegex.FindStringSubmatchIndex(masked); m != nil {
+		limit = masked[m[2]:m[3]]
+		sql = translateTrimRight(sql[:m[0]])
+	} else if translateLimitXRegex.MatchString(masked) {
+		return sql, g.Error("cannot translate the row limit, only a trailing `limit n` is supported")
+	} else {
+		return sql, nil // no limit
+	}
+
+	if translateOffsetRegex.MatchString(translateMask(sql, true)) {
+		return sql, g.Error("cannot translate a row limit with an offset")
+	}
+
+	limitSQL := strings.ToLower(dialect.GetTemplateValue("core.limit"))
+	switch {
+	case strings.Contains(limitSQL, "top {limit}"):
+		m := translateSelectRegex.FindStringIndex(translateMask(sql, true))
+		if m == nil {
+			return sql, g.Error("cannot translate a row limit to top, query does not start with select")
+		}
+		return sql[:m[1]] + "top " + limit + " " + sql[m[1]:], nil
+	case strings.Contains(limitSQL, "rownum"), strings.Contains(limitSQL, "fetch "):
+		return sql + " fetch first " + limit + " rows only", nil
+	}
+	return sql + " limit " + limit, nil
+}
+
+// translateTrimRight trims the trailing spaces, keeping the line break after a comment
+func translateTrimRight(sql string) string {
+	trimmed := strings.TrimRight(sql, " \t\r\n")
+	if masked := translateMask(trimmed+"\n", false); strings.HasSuffix(masked, " \n") {
+		return strings.TrimRight(sql, " \t\r")
+	}
+	return trimmed
+}
+
+// translateReplace replaces the matches of the pattern found outside of the
+// string literals and comments (and quoted identifiers if maskIdents)
+func translateReplace(sql string, maskIdents bool, re *regexp.Regexp, repl func(groups []string) string) string {
+	masked := translateMask(sql, maskIdents)
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(masked, -1) {
+		groups := make([]string, len(m)/2)
+		for i := range groups {
+			if m[2*i] > -1 {
+				groups[i] = sql[m[2*i]:m[2*i+1]]
+			}
+		}
+		sb.WriteString(sql[last:m[0]])
+		sb.WriteString(repl(groups))
+		last = m[1]
+	}
+	sb.WriteString(sql[last:])
+	return sb.String()
+}
+
+// translateMask returns the sql with the same length, with the content of the
+// string literals (and quoted identifiers if maskIdents) replaced with
+// underscores, and the comments replaced with spaces
+func translateMask(sql string, maskIdents bool) string {
+	masked := []byte(sql)
+	var closing byte // the closing char of the current literal or identifier
+	inLineComment, inMultiComment := false, false
+
+	for i := 0; i < len(masked); i++ {
+		c := sql[i]
+		switch {
+		case inLineComment:
+			if c == '\n' {
+				inLineComment = false
+				continue
+			}
+			masked[i] = ' '
+		case inMultiComment:
+			masked[i] = ' '
+			if c == '*' && i+1 < len(sql) && sql[i+1] == '/' {
+				masked[i+1] = ' '
+				inMultiComment = false
+				i++
+			}
+		case closing != 0:
+			if c == closing {
+				if closing == '\'' && i+1 < len(sql) && sql[i+1] == '\'' {
+					masked[i], masked[i+1] = '_', '_' // escaped quote
+					i++
+					continue
+				}
+				closing = 0
+				continue
+			}
+			if closing == '\'' || maskIdents {
+				masked[i] = '_'
+			}
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			inLineComment = true
+			masked[i] = ' '
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			inMultiComment = true
+			masked[i] = ' '
+		case c == '\'':
+			closing = '\''
+		case c == '"' || c == '`':
+			closing = c
+		case c == '[' && (i == 0 || !isTranslateWordChar(sql[i-1])):
+			closing = ']'
+		}
+	}
+
+	return string(masked)
+}
+
+// isTranslateWordChar returns true if a bracket following the char is an index, not an identifier
+func isTranslateWordChar(c byte) bool {
+	return c == '_' || c == ')' || c == ']' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
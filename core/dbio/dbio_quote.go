@@ -0,0 +1,96 @@
+package dbio
+
+import (
+	"regexp"
+	"strings"
+)
+
+// QuoteIdentifiers is how table & column names are quoted in generated SQL
+type QuoteIdentifiers string
+
+const (
+	QuoteIdentifiersAlways     QuoteIdentifiers = "always"      // always quote names. The default.
+	QuoteIdentifiersNever      QuoteIdentifiers = "never"       // never quote names, as provided
+	QuoteIdentifiersWhenNeeded QuoteIdentifiers = "when-needed" // quote mixed-case, special & reserved names
+)
+
+// QuoteIdentifiersModes are the valid values of QuoteIdentifiers
+var QuoteIdentifiersModes = []QuoteIdentifiers{QuoteIdentifiersAlways, QuoteIdentifiersNever, QuoteIdentifiersWhenNeeded}
+
+var plainIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedWords are the keywords (reserved or not) of the supported databases,
+// which are always quoted with `when-needed`, since some cannot be used unquoted
+// as identifiers in at least one of them
+var reservedWords = map[string]bool{}
+
+func init() {
+	words := `access action add admin after aggregate all alter analyse analyze and any array as asc asensitive assertion
+		asymmetric at attach authorization backup before begin between bigint binary binary_integer bit both break
+		browse bulk by call called cascade cascaded case cast catalog change char character check checkpoint close
+		cluster clustered coalesce collate collation column comment commit compute connect connection constraint
+		continue convert copy create cross cube current current_catalog current_date current_role current_schema
+		current_time current_timestamp current_user cursor cycle database databases date day dbcc deallocate declare
+		default deferrable delete deny desc describe detach deterministic disk distinct distributed do domain double
+		drop dual dump each else elseif enable end errlvl escape except exclusive exec execute exists exit explain
+		external extract false fetch file fillfactor filter final first float following for force foreign format
+		freetext freeze from full function functions generated global goto grant grants group handler having holdlock
+		hour identity if ignore ilike immediate import in include increment index inherit initially inner inout input
+		insensitive insert instead int integer intersect interval into is iterate join key keys kill label language
+		large last lateral leading leave left level like limit lineno load local localtime localtimestamp lock loop
+		match materialized maxvalue merge minus minute minvalue mode modifies modify month move natural new next no
+		nocheck nocompress nonclustered not notnull nowait null nulls number numeric object of off offset offsets old
+		on only open openquery optimize option or order out outer output over overlaps overwrite owner partition
+		percent pivot placing plan preceding precision prepare primary print prior privileges proc procedure public
+		purge qualify raiserror range raw read reads readtext reconfigure recursive references regexp release rename
+		repeat replace replication require resignal restore restrict return returning revert revoke right role
+		rollback rollup routine row rowcount rowguidcol rowid rownum rows rule sample save savepoint schema schemas
+		scroll search second security select semantickeysimilaritytable session session_user set setuser share show
+		shutdown signal similar size some sql sqlexception sqlstate sqlwarning start statistics stored straight_join
+		successful symmetric synonym sysdate system system_user table tablesample temporary terminate textsize then to
+		top trailing tran transaction trigger true truncate try_convert tsequal uid unbounded under undo union unique
+		unlock unnest unpivot until update updatetext usage use user using utc_date utc_time utc_timestamp vacuum
+		validate value values varchar variadic varying verbose version view volatile waitfor when whenever where while
+		window with without work write writetext xor year zone`
+	for _, word := range strings.Fields(words) {
+		reservedWords[word] = true
+	}
+}
+
+// IsReservedWord returns true if the name is a reserved keyword
+func IsReservedWord(name string) bool {
+	return reservedWords[strings.ToLower(name)]
+}
+
+// QuoteWith quotes the field name according to the quoting mode. `always` is
+// the same as Quote. `when-needed` leaves names unquoted when they are plain,
+// non-reserved identifiers in the case the database folds unquoted names to,
+// so that the quoted & unquoted names resolve the same. `never` returns the
+// name unquoted, as provided.
+func (t Type) QuoteWith(field string, mode QuoteIdentifiers, normalize ...bool) string {
+	switch mode {
+	case QuoteIdentifiersNever:
+		return t.Unquote(field)
+	case QuoteIdentifiersWhenNeeded:
+		quoted := t.Quote(field, normalize...)
+		name := t.Unquote(quoted)
+		if !plainIdentifier.MatchString(name) || IsReservedWord(name) {
+			return quoted
+		}
+
+		folded := strings.ToLower(name)
+		if t.foldsUpper() {
+			folded = strings.ToUpper(name)
+		}
+		if name == folded {
+			return name
+		}
+		return quoted
+	}
+	return t.Quote(field, normalize...)
+}
+
+// foldsUpper returns true if the database folds unquoted names to upper case
+func (t Type) foldsUpper() bool {
+	return t == TypeDbOracle || t == TypeDbSnowflake
+}
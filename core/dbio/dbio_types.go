@@ -68,6 +68,10 @@ const (
 	TypeDbMongoDB    Type = "mongodb"
 	TypeDbPrometheus Type = "prometheus"
 	TypeDbProton     Type = "proton"
+	TypeDbPubSub     Type = "pubsub"
+	TypeDbInfluxDB   Type = "influxdb"
+	TypeDbFirestore  Type = "firestore"
+	TypeDbCassandra  Type = "cassandra"
 )
 
 var AllType = []struct {
@@ -103,6 +107,10 @@ var AllType = []struct {
 	{TypeDbMongoDB, "TypeDbMongoDB"},
 	{TypeDbPrometheus, "TypeDbPrometheus"},
 	{TypeDbProton, "TypeDbProton"},
+	{TypeDbPubSub, "TypeDbPubSub"},
+	{TypeDbInfluxDB, "TypeDbInfluxDB"},
+	{TypeDbFirestore, "TypeDbFirestore"},
+	{TypeDbCassandra, "TypeDbCassandra"},
 }
 
 // ValidateType returns true is type is valid
@@ -112,6 +120,7 @@ func ValidateType(tStr string) (Type, bool) {
 	tMap := map[string]Type{
 		"postgresql":  TypeDbPostgres,
 		"mongodb+srv": TypeDbMongoDB,
+		"scylladb":    TypeDbCassandra,
 		"file":        TypeFileLocal,
 	}
 
@@ -122,7 +131,7 @@ func ValidateType(tStr string) (Type, bool) {
 	switch t {
 	case
 		TypeFileLocal, TypeFileS3, TypeFileAzure, TypeFileGoogle, TypeFileSftp, TypeFileFtp,
-		TypeDbPostgres, TypeDbRedshift, TypeDbStarRocks, TypeDbMySQL, TypeDbMariaDB, TypeDbOracle, TypeDbBigQuery, TypeDbSnowflake, TypeDbSQLite, TypeDbSQLServer, TypeDbAzure, TypeDbAzureDWH, TypeDbDuckDb, TypeDbMotherDuck, TypeDbClickhouse, TypeDbTrino, TypeDbMongoDB, TypeDbPrometheus:
+		TypeDbPostgres, TypeDbRedshift, TypeDbStarRocks, TypeDbMySQL, TypeDbMariaDB, TypeDbOracle, TypeDbBigQuery, TypeDbSnowflake, TypeDbSQLite, TypeDbSQLServer, TypeDbAzure, TypeDbAzureDWH, TypeDbDuckDb, TypeDbMotherDuck, TypeDbClickhouse, TypeDbTrino, TypeDbMongoDB, TypeDbPrometheus, TypeDbPubSub, TypeDbInfluxDB, TypeDbFirestore, TypeDbCassandra:
 		return t, true
 	}
 
@@ -149,7 +158,9 @@ func (t Type) DefPort() int {
 		TypeDbClickhouse: 9000,
 		TypeDbMongoDB:    27017,
 		TypeDbPrometheus: 9090,
+		TypeDbInfluxDB:   8086,
 		TypeDbProton:     8463,
+		TypeDbCassandra:  9042,
 		TypeFileFtp:      21,
 		TypeFileSftp:     22,
 	}
@@ -165,7 +176,7 @@ func (t Type) DBNameUpperCase() bool {
 func (t Type) Kind() Kind {
 	switch t {
 	case TypeDbPostgres, TypeDbRedshift, TypeDbStarRocks, TypeDbMySQL, TypeDbMariaDB, TypeDbOracle, TypeDbBigQuery, TypeDbBigTable,
-		TypeDbSnowflake, TypeDbSQLite, TypeDbSQLServer, TypeDbAzure, TypeDbClickhouse, TypeDbTrino, TypeDbDuckDb, TypeDbMotherDuck, TypeDbMongoDB, TypeDbPrometheus, TypeDbProton:
+		TypeDbSnowflake, TypeDbSQLite, TypeDbSQLServer, TypeDbAzure, TypeDbClickhouse, TypeDbTrino, TypeDbDuckDb, TypeDbMotherDuck, TypeDbMongoDB, TypeDbPrometheus, TypeDbProton, TypeDbPubSub, TypeDbInfluxDB, TypeDbFirestore, TypeDbCassandra:
 		return KindDatabase
 	case TypeFileLocal, TypeFileHDFS, TypeFileS3, TypeFileAzure, TypeFileGoogle, TypeFileSftp, TypeFileFtp, TypeFileHTTP, Type("https"):
 		return KindFile
@@ -224,6 +235,10 @@ func (t Type) NameLong() string {
 		TypeDbPrometheus: "DB - Prometheus",
 		TypeDbMongoDB:    "DB - MongoDB",
 		TypeDbProton:     "DB - Proton",
+		TypeDbPubSub:     "DB - PubSub",
+		TypeDbInfluxDB:   "DB - InfluxDB",
+		TypeDbFirestore:  "DB - Firestore",
+		TypeDbCassandra:  "DB - Cassandra",
 	}
 
 	return mapping[t]
@@ -260,6 +275,10 @@ func (t Type) Name() string {
 		TypeDbMongoDB:    "MongoDB",
 		TypeDbAzure:      "Azure",
 		TypeDbProton:     "Proton",
+		TypeDbPubSub:     "PubSub",
+		TypeDbInfluxDB:   "InfluxDB",
+		TypeDbFirestore:  "Firestore",
+		TypeDbCassandra:  "Cassandra",
 	}
 
 	return mapping[t]
@@ -537,11 +556,13 @@ const (
 	FileTypeJson      FileType = "json"
 	FileTypeParquet   FileType = "parquet"
 	FileTypeAvro      FileType = "avro"
+	FileTypeOrc       FileType = "orc"
 	FileTypeSAS       FileType = "sas7bdat"
 	FileTypeJsonLines FileType = "jsonlines"
 	FileTypeIceberg   FileType = "iceberg"
 	FileTypeDelta     FileType = "delta"
 	FileTypeRaw       FileType = "raw"
+	FileTypeFixed     FileType = "fixed"
 )
 
 var AllFileType = []struct {
@@ -555,17 +576,21 @@ var AllFileType = []struct {
 	{FileTypeJson, "FileTypeJson"},
 	{FileTypeParquet, "FileTypeParquet"},
 	{FileTypeAvro, "FileTypeAvro"},
+	{FileTypeOrc, "FileTypeOrc"},
 	{FileTypeSAS, "FileTypeSAS"},
 	{FileTypeJsonLines, "FileTypeJsonLines"},
 	{FileTypeIceberg, "FileTypeIceberg"},
 	{FileTypeDelta, "FileTypeDelta"},
 	{FileTypeRaw, "FileTypeRaw"},
+	{FileTypeFixed, "FileTypeFixed"},
 }
 
 func (ft FileType) Ext() string {
 	switch ft {
 	case FileTypeJsonLines:
 		return ".jsonl"
+	case FileTypeFixed:
+		return ".txt"
 	default:
 		return "." + string(ft)
 	}
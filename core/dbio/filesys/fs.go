@@ -422,12 +422,17 @@ func (fs *BaseFileSysClient) GetDatastream(uri string, cfg ...iop.FileStreamConf
 	ds.SafeInference = true
 	ds.SetMetadata(fs.GetProp("METADATA"))
 	ds.Metadata.StreamURL.Value = uri
+	ds.Metadata.Partitions = Cfg.Partitions
 	ds.SetConfig(fs.Props())
 
 	if Cfg.Format == dbio.FileTypeNone {
 		Cfg.Format = InferFileFormat(uri)
 	}
 
+	if ss := ds.Sp.Config.SampleStrategy; ss != "" && ss != string(iop.SampleStrategyHead) && g.In(Cfg.Format, dbio.FileTypeCsv, dbio.FileTypeJson, dbio.FileTypeJsonLines) {
+		g.Warn("sample_strategy %s requires a local file, sampling the first rows of %s", ss, uri)
+	}
+
 	go func() {
 		// recover from panic
 		defer func() {
@@ -463,10 +468,22 @@ func (fs *BaseFileSysClient) GetDatastream(uri string, cfg ...iop.FileStreamConf
 			return
 		}
 
-		reader, err := fs.Self().GetReader(uri)
-		if err != nil {
-			ds.Context.CaptureErr(g.Error(err, "error getting reader"))
-			return
+		var reader io.Reader
+		var err error
+		if Cfg.UseS3Select {
+			reader, err = fs.getS3SelectReader(uri, Cfg)
+			if err != nil {
+				ds.Context.CaptureErr(g.Error(err, "error getting S3 Select reader"))
+				return
+			}
+		}
+
+		if reader == nil {
+			reader, err = fs.Self().GetReader(uri)
+			if err != nil {
+				ds.Context.CaptureErr(g.Error(err, "error getting reader"))
+				return
+			}
 		}
 
 		// Wait for reader to start reading or err
@@ -484,16 +501,20 @@ func (fs *BaseFileSysClient) GetDatastream(uri string, cfg ...iop.FileStreamConf
 		}
 
 		switch Cfg.Format {
-		case dbio.FileTypeJson:
+		case dbio.FileTypeJson, dbio.FileTypeJsonLines:
 			err = ds.ConsumeJsonReader(reader)
 		case dbio.FileTypeXml:
 			err = ds.ConsumeXmlReader(reader)
 		case dbio.FileTypeParquet:
-			err = ds.ConsumeParquetReader(reader)
+			err = ds.ConsumeParquetReader(reader, Cfg.Filters...)
 		case dbio.FileTypeAvro:
 			err = ds.ConsumeAvroReader(reader)
+		case dbio.FileTypeOrc:
+			err = ds.ConsumeOrcReader(reader)
 		case dbio.FileTypeSAS:
 			err = ds.ConsumeSASReader(reader)
+		case dbio.FileTypeFixed:
+			err = ds.ConsumeFixedReader(reader)
 		case dbio.FileTypeExcel:
 			err = ds.ConsumeExcelReader(reader, fs.properties)
 		case dbio.FileTypeCsv:
@@ -512,6 +533,41 @@ func (fs *BaseFileSysClient) GetDatastream(uri string, cfg ...iop.FileStreamConf
 	return ds, err
 }
 
+// getS3SelectReader returns a reader of the S3 Select results, or nil when
+// S3 Select does not support the file or the select / where, or fails (also
+// mid-stream), to download it fully
+func (fs *BaseFileSysClient) getS3SelectReader(uri string, cfg iop.FileStreamConfig) (reader io.Reader, err error) {
+	s3Fs, ok := fs.Self().(*S3FileSysClient)
+	if !ok {
+		return nil, nil
+	}
+
+	// where conditions on csv & jsonlines columns can only be applied by S3 Select
+	fallback := func(err error) (io.Reader, error) {
+		if len(cfg.Filters) > 0 && cfg.Format != dbio.FileTypeParquet {
+			return nil, g.Error(err, "could not apply where conditions with S3 Select")
+		}
+		g.Warn("not using S3 Select for %s, reading the full file: %s", uri, err.Error())
+		return nil, nil
+	}
+
+	expr, err := S3SelectExpression(cfg.Select, cfg.Filters, cfg.Format)
+	if err != nil {
+		return fallback(err)
+	}
+
+	compression, err := s3SelectCompression(uri, fs.GetProp("compression"))
+	if err != nil {
+		return fallback(err)
+	}
+
+	reader, err = s3Fs.SelectReader(uri, expr, cfg.Select, cfg.Format, compression)
+	if err != nil {
+		return fallback(err)
+	}
+	return reader, nil
+}
+
 // ReadDataflow read
 func (fs *BaseFileSysClient) ReadDataflow(url string, cfg ...iop.FileStreamConfig) (df *iop.Dataflow, err error) {
 	Cfg := iop.FileStreamConfig{} // infinite
@@ -567,6 +623,13 @@ func (fs *BaseFileSysClient) ReadDataflow(url string, cfg ...iop.FileStreamConfi
 	var nodes FileNodes
 	if Cfg.ShouldUseDuckDB() {
 		nodes = FileNodes{FileNode{URI: url}}
+	} else if Cfg.Manifest != "" {
+		g.Debug("reading the objects listed in manifest %s", Cfg.Manifest)
+		nodes, err = ManifestNodes(fs.Self(), url, Cfg)
+		if err != nil {
+			err = g.Error(err, "Error reading manifest")
+			return
+		}
 	} else {
 		g.Trace("listing path: %s", url)
 		nodes, err = fs.Self().ListRecursive(url)
@@ -688,6 +751,16 @@ func (fs *BaseFileSysClient) WriteDataflowReady(df *iop.Dataflow, url string, fi
 		fileFormat = InferFileFormat(url)
 	}
 
+	// json_format sets the json layout: line-delimited or a single array
+	if fileFormat.IsJson() {
+		switch strings.ToLower(fs.GetProp("JSON_FORMAT")) {
+		case "lines":
+			fileFormat = dbio.FileTypeJsonLines
+		case "array":
+			fileFormat = dbio.FileTypeJson
+		}
+	}
+
 	url = strings.TrimSuffix(NormalizeURI(fs, url), "/")
 
 	singleFile := sc.FileMaxRows == 0 && sc.FileMaxBytes == 0
@@ -758,7 +831,7 @@ func (fs *BaseFileSysClient) WriteDataflowReady(df *iop.Dataflow, url string, fi
 			}
 
 			compressor := iop.NewCompressor(sc.Compression)
-			if fileFormat == dbio.FileTypeParquet {
+			if g.In(fileFormat, dbio.FileTypeParquet, dbio.FileTypeOrc) {
 				compressor = iop.NewCompressor("none") // compression is done internally
 			} else {
 				subPartURL = subPartURL + compressor.Suffix()
@@ -794,6 +867,13 @@ func (fs *BaseFileSysClient) WriteDataflowReady(df *iop.Dataflow, url string, fi
 					break
 				}
 			}
+		case dbio.FileTypeOrc:
+			for reader := range ds.NewOrcReaderChnl(sc) {
+				err := processReader(reader)
+				if err != nil {
+					break
+				}
+			}
 		case dbio.FileTypeExcel:
 			for reader := range ds.NewExcelReaderChnl(sc) {
 				err := processReader(reader)
@@ -953,6 +1033,69 @@ func GetDataflow(fs FileSysClient, nodes FileNodes, cfg iop.FileStreamConfig) (d
 		return
 	}
 
+	// split the where conditions: partition keys prune files from their path,
+	// the others prune parquet row groups from their statistics
+	partKeys := nodes.PartitionKeys()
+	whereConditions, err := iop.ParseFilterConditions(cfg.Where)
+	if err != nil {
+		return df, g.Error(err, "could not parse where")
+	}
+	wherePartConditions := []PartitionCondition{}
+	cfg.Filters = nil
+	for _, cond := range whereConditions {
+		if g.In(cond.Key, partKeys...) {
+			wherePartConditions = append(wherePartConditions, cond)
+		} else {
+			cfg.Filters = append(cfg.Filters, cond)
+		}
+	}
+	// S3 Select filters csv & jsonlines files at the source
+	cfg.UseS3Select = cfg.UseS3Select && fs.FsType() == dbio.TypeFileS3 && !cfg.ShouldUseDuckDB()
+	if len(cfg.Filters) > 0 && !cfg.UseS3Select && (cfg.ShouldUseDuckDB() || !(cfg.Format == dbio.FileTypeParquet || isFiletype(dbio.FileTypeParquet, nodes.URIs()...))) {
+		return df, g.Error("where conditions on non-partition columns are only supported for parquet files: %s", g.Marshal(cfg.Filters))
+	}
+
+	// filter on Hive-style partitions, and expose their values as columns
+	partitioned := false
+	if len(partKeys) > 0 && !cfg.ShouldUseDuckDB() {
+		conditions, err := ParsePartitionFilter(cfg.PartitionFilter)
+		if err != nil {
+			return df, g.Error(err, "could not parse partition filter")
+		}
+		conditions = append(conditions, wherePartConditions...)
+
+		// incremental on a partition key: only load newer partitions
+		if key := strings.ToLower(cfg.IncrementalKey); key != "" && g.In(key, partKeys...) {
+			if val := strings.Trim(cfg.IncrementalValue, `'`); val != "" {
+				conditions = append(conditions, PartitionCondition{Key: key, Operator: ">", Value: val})
+			}
+			fs.SetProp("SLING_INCREMENTAL_COL", "") // rows are filtered by partition
+			partitioned = true
+		}
+
+		if cfg.PartitionFilter != "" || len(wherePartConditions) > 0 {
+			partitioned = true
+		}
+
+		if partitioned {
+			selected := nodes.FilterPartitions(conditions)
+			selectedURIs := map[string]bool{}
+			for _, uri := range selected.URIs() {
+				selectedURIs[uri] = true
+			}
+			for _, node := range nodes {
+				if _, ok := selectedURIs[node.URI]; !ok && !node.IsDir {
+					g.DebugLow("pruned file %s from partition filter", node.URI)
+				}
+			}
+			nodes = selected
+			g.Debug("selected %d files from partitions %s", len(nodes), g.Marshal(partKeys))
+			if len(nodes) == 0 {
+				return df, g.Error("Provided 0 files for partition filter: %s", g.Marshal(conditions))
+			}
+		}
+	}
+
 	df = iop.NewDataflowContext(fs.Context().Ctx, cfg.Limit)
 	dsCh := make(chan *iop.Datastream)
 	fs.setDf(df)
@@ -960,21 +1103,24 @@ func GetDataflow(fs FileSysClient, nodes FileNodes, cfg iop.FileStreamConfig) (d
 	go func() {
 		defer close(dsCh)
 
-		allowMerging := strings.ToLower(os.Getenv("SLING_MERGE_READERS")) != "false" && !cfg.ShouldUseDuckDB()
+		allowMerging := strings.ToLower(os.Getenv("SLING_MERGE_READERS")) != "false" && !cfg.ShouldUseDuckDB() && !partitioned && !cfg.UseS3Select
 
 		pushDatastream := func(ds *iop.Datastream) {
 			// use selected fields only when not parquet
 			skipSelect := g.In(cfg.Format, dbio.FileTypeParquet, dbio.FileTypeIceberg, dbio.FileTypeDelta) || cfg.ShouldUseDuckDB()
-			if len(cfg.Select) > 1 && !skipSelect {
-				cols := iop.NewColumnsFromFields(cfg.Select...)
-				fm := ds.Columns.FieldMap(true)
-				ds.Columns.DbTypes()
+			if (len(cfg.Select) > 1 || iop.HasPositionalSelect(cfg.Select)) && !skipSelect {
+				// resolve names and positional references (e.g. `@1 as id`)
+				selected, indexes, err := iop.SelectColumns(ds.Columns, cfg.Select)
+				if err != nil {
+					df.Context.CaptureErr(g.Error(err, "could not select columns"))
+					return
+				}
+				cols := iop.NewColumnsFromFields(selected.Names()...)
 				transf := func(in []interface{}) (out []interface{}) {
-					for _, col := range cols {
-						if i, ok := fm[strings.ToLower(col.Name)]; ok {
-							out = append(out, in[i])
-						} else {
-							df.Context.CaptureErr(g.Error("column %s not found", col.Name))
+					out = make([]interface{}, len(indexes))
+					for j, i := range indexes {
+						if i < len(in) {
+							out[j] = in[i]
 						}
 					}
 					return
@@ -1030,8 +1176,19 @@ func GetDataflow(fs FileSysClient, nodes FileNodes, cfg iop.FileStreamConfig) (d
 				}
 			}
 
-			ds, err := fs.GetDatastream(uri, cfg)
-			if err != nil {
+			nodeCfg := cfg
+			if partitioned {
+				nodeCfg.Partitions = node.Partitions()
+			}
+
+			var ds *iop.Datastream
+			err := checkManifestObject(fs, cfg, uri)
+			if err == nil {
+				ds, err = fs.GetDatastream(uri, nodeCfg)
+			}
+			if skip, err := ManifestObjectMissing(cfg, uri, err); skip {
+				continue
+			} else if err != nil {
 				df.Context.CaptureErr(g.Error(err, "Unable to process "+uri))
 				return
 			}
@@ -1246,8 +1403,14 @@ func MergeReaders(fs FileSysClient, fileType dbio.FileType, nodes FileNodes, cfg
 
 				g.Debug("processing reader from %s", node.URI)
 
-				reader, err := fs.Self().GetReader(node.URI)
-				if err != nil {
+				var reader io.Reader
+				err := checkManifestObject(fs, cfg, node.URI)
+				if err == nil {
+					reader, err = fs.Self().GetReader(node.URI)
+				}
+				if skip, err := ManifestObjectMissing(cfg, node.URI, err); skip {
+					return
+				} else if err != nil {
 					setError(g.Error(err, "Error getting reader"))
 					return
 				}
@@ -1312,7 +1475,7 @@ func MergeReaders(fs FileSysClient, fileType dbio.FileType, nodes FileNodes, cfg
 func InferFileFormat(path string, defaults ...dbio.FileType) dbio.FileType {
 	path = strings.TrimSpace(strings.ToLower(path))
 
-	for _, fileType := range []dbio.FileType{dbio.FileTypeCsv, dbio.FileTypeJsonLines, dbio.FileTypeJson, dbio.FileTypeXml, dbio.FileTypeParquet, dbio.FileTypeAvro, dbio.FileTypeSAS, dbio.FileTypeExcel} {
+	for _, fileType := range []dbio.FileType{dbio.FileTypeCsv, dbio.FileTypeJsonLines, dbio.FileTypeJson, dbio.FileTypeXml, dbio.FileTypeParquet, dbio.FileTypeAvro, dbio.FileTypeOrc, dbio.FileTypeSAS, dbio.FileTypeExcel} {
 		ext := fileType.Ext()
 		if strings.HasSuffix(path, ext) || strings.Contains(path, ext+".") {
 			return fileType
@@ -82,13 +82,18 @@ func (fs *AzureFileSysClient) GetPath(uri string) (path string, err error) {
 // Connect initiates the fs client connection
 func (fs *AzureFileSysClient) Connect() (err error) {
 
+	clientOptions, err := fs.clientOptions()
+	if err != nil {
+		return g.Error(err, "invalid storage options")
+	}
+
 	serviceURL := g.F("https://%s.blob.core.windows.net/", fs.account)
 	if cs := fs.GetProp("CONN_STR"); cs != "" {
 		connProps := g.KVArrToMap(strings.Split(cs, ";")...)
 		fs.account = connProps["AccountName"]
 		fs.key = connProps["AccountKey"]
 
-		fs.client, err = azblob.NewClientFromConnectionString(cs, &azblob.ClientOptions{})
+		fs.client, err = azblob.NewClientFromConnectionString(cs, clientOptions)
 		if err != nil {
 			err = g.Error(err, "Could not connect to Azure using provided CONN_STR")
 			return
@@ -100,7 +105,7 @@ func (fs *AzureFileSysClient) Connect() (err error) {
 			return
 		}
 
-		fs.client, err = azblob.NewClientWithNoCredential(cs, &azblob.ClientOptions{})
+		fs.client, err = azblob.NewClientWithNoCredential(cs, clientOptions)
 		if err != nil {
 			err = g.Error(err, "Could not connect to Azure using provided SAS_SVC_URL")
 			return
@@ -111,7 +116,7 @@ func (fs *AzureFileSysClient) Connect() (err error) {
 			return g.Error(err, "Could not process shared key / account key")
 		}
 
-		fs.client, err = azblob.NewClientWithSharedKeyCredential(serviceURL, cred, &azblob.ClientOptions{})
+		fs.client, err = azblob.NewClientWithSharedKeyCredential(serviceURL, cred, clientOptions)
 		if err != nil {
 			return g.Error(err, "Could not connect to Azure using shared key credentials")
 		}
@@ -121,7 +126,7 @@ func (fs *AzureFileSysClient) Connect() (err error) {
 			return g.Error(err, "No Azure credentials provided")
 		}
 
-		fs.client, err = azblob.NewClient(serviceURL, cred, &azblob.ClientOptions{})
+		fs.client, err = azblob.NewClient(serviceURL, cred, clientOptions)
 		if err != nil {
 			return g.Error(err, "Could not connect to Azure using default credentials")
 		}
@@ -129,6 +134,27 @@ func (fs *AzureFileSysClient) Connect() (err error) {
 	return
 }
 
+// clientOptions returns the client options, with the retry policy of the
+// storage options (applied to each block of uploads and downloads)
+func (fs *AzureFileSysClient) clientOptions() (opts *azblob.ClientOptions, err error) {
+	storageOpts, err := fs.storageOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	opts = &azblob.ClientOptions{}
+	if storageOpts.MaxRetries > 0 {
+		opts.Retry.MaxRetries = int32(storageOpts.MaxRetries)
+	} else if storageOpts.MaxRetries == 0 {
+		opts.Retry.MaxRetries = -1 // 0 is the default of 3 retries
+	}
+	if storageOpts.RequestTimeout > 0 {
+		opts.Retry.TryTimeout = storageOpts.RequestTimeout
+	}
+
+	return opts, nil
+}
+
 // Buckets returns the containers found in the project
 func (fs *AzureFileSysClient) Buckets() (paths []string, err error) {
 	pager := fs.client.NewListContainersPager(&service.ListContainersOptions{})
@@ -289,7 +315,12 @@ func (fs *AzureFileSysClient) Write(uri string, reader io.Reader) (bw int64, err
 		return
 	}
 
-	resp, err := fs.client.UploadStream(fs.Context().Ctx, fs.container, path, reader, &blockblob.UploadStreamOptions{})
+	uploadOptions := &blockblob.UploadStreamOptions{}
+	if storageOpts, _ := fs.storageOptions(); storageOpts.PartSize > 0 {
+		uploadOptions.BlockSize = storageOpts.PartSize
+	}
+
+	resp, err := fs.client.UploadStream(fs.Context().Ctx, fs.container, path, reader, uploadOptions)
 	if err != nil {
 		err = g.Error(err, "Error UploadStream: "+uri)
 		return
@@ -314,5 +345,12 @@ func (fs *AzureFileSysClient) GetReader(uri string) (reader io.Reader, err error
 
 	reader = resp.Body
 
+	// re-request from the current offset on read errors
+	if storageOpts, _ := fs.storageOptions(); storageOpts.MaxRetries > 0 {
+		reader = resp.NewRetryReader(fs.Context().Ctx, &blob.RetryReaderOptions{
+			MaxRetries: int32(storageOpts.MaxRetries),
+		})
+	}
+
 	return
 }
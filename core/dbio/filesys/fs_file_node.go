@@ -26,6 +26,8 @@ type FileNode struct {
 	Columns  iop.Columns `json:"columns,omitempty"`
 	Children FileNodes   `json:"children,omitempty"`
 
+	Stats []iop.ColumnSampleStats `json:"stats,omitempty"` // type inference stats of the columns
+
 	typ  dbio.Type // cached type
 	path string    // cached path
 }
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	gcstorage "cloud.google.com/go/storage"
 	"github.com/flarco/g"
@@ -110,6 +111,14 @@ func (fs *GoogleFileSysClient) Connect() (err error) {
 		return
 	}
 
+	storageOpts, err := fs.storageOptions()
+	if err != nil {
+		return g.Error(err, "invalid storage options")
+	} else if storageOpts.MaxRetries >= 0 {
+		// only idempotent operations are retried (the client default)
+		fs.client.SetRetry(gcstorage.WithMaxAttempts(storageOpts.MaxRetries + 1))
+	}
+
 	return nil
 }
 
@@ -119,8 +128,34 @@ func (fs *GoogleFileSysClient) Write(path string, reader io.Reader) (bw int64, e
 		return
 	}
 
-	obj := fs.client.Bucket(fs.bucket).Object(key)
+	storageOpts, err := fs.storageOptions()
+	if err != nil {
+		return 0, g.Error(err, "invalid storage options")
+	}
+	chunkSize := int(storageOpts.PartSize)
+	if storageOpts.PartSize > 0 && chunkSize <= 0 {
+		return 0, g.Error("invalid value for part_size: %d (must be positive)", storageOpts.PartSize)
+	}
+
+	// uploads are resumable, so failed chunks are retried individually,
+	// up to a bounded number of attempts
+	attempts := defaultMaxRetries + 1
+	if storageOpts.MaxRetries >= 0 {
+		attempts = storageOpts.MaxRetries + 1
+	}
+	obj := fs.client.Bucket(fs.bucket).Object(key).Retryer(
+		gcstorage.WithMaxAttempts(attempts),
+		gcstorage.WithPolicy(gcstorage.RetryAlways),
+	)
+
 	wc := obj.NewWriter(fs.Context().Ctx)
+	if chunkSize > 0 {
+		wc.ChunkSize = chunkSize
+	}
+	if storageOpts.RequestTimeout > 0 {
+		// the deadline covers all the attempts of a chunk
+		wc.ChunkRetryDeadline = storageOpts.RequestTimeout * time.Duration(attempts)
+	}
 	bw, err = io.Copy(wc, reader)
 	if err != nil {
 		err = g.Error(err, "Error Copying")
@@ -140,7 +175,21 @@ func (fs *GoogleFileSysClient) GetReader(path string) (reader io.Reader, err err
 	if err != nil {
 		return
 	}
-	reader, err = fs.client.Bucket(fs.bucket).Object(key).NewReader(fs.Context().Ctx)
+	obj := fs.client.Bucket(fs.bucket).Object(key)
+
+	// re-open from the current offset on read errors
+	if storageOpts, _ := fs.storageOptions(); storageOpts.MaxRetries > 0 {
+		open := func(offset int64) (io.ReadCloser, error) {
+			return obj.NewRangeReader(fs.Context().Ctx, offset, -1)
+		}
+		reader, err = newRangeRetryReader(fs.Context().Ctx, open, storageOpts.MaxRetries)
+		if err != nil {
+			err = g.Error(err, "Could not get reader for "+path)
+		}
+		return
+	}
+
+	reader, err = obj.NewReader(fs.Context().Ctx)
 	if err != nil {
 		err = g.Error(err, "Could not get reader for "+path)
 		return
@@ -122,6 +122,7 @@ func (fs *LocalFileSysClient) GetDatastream(uri string, cfg ...iop.FileStreamCon
 	ds.SafeInference = true
 	ds.SetMetadata(fs.GetProp("METADATA"))
 	ds.Metadata.StreamURL.Value = path
+	ds.Metadata.Partitions = Cfg.Partitions
 	ds.SetConfig(fs.Props())
 
 	// set selectFields for pruning at source
@@ -131,6 +132,13 @@ func (fs *LocalFileSysClient) GetDatastream(uri string, cfg ...iop.FileStreamCon
 		Cfg.Format = InferFileFormat(path)
 	}
 
+	// sample across the file for inference, with sample_strategy
+	if file != nil && g.In(Cfg.Format, dbio.FileTypeCsv, dbio.FileTypeJson, dbio.FileTypeJsonLines) {
+		if stat, err := file.Stat(); err == nil {
+			ds.SetSampleSource(file, stat.Size())
+		}
+	}
+
 	go func() {
 		// recover from panic
 		defer func() {
@@ -172,11 +180,15 @@ func (fs *LocalFileSysClient) GetDatastream(uri string, cfg ...iop.FileStreamCon
 		case dbio.FileTypeXml:
 			err = ds.ConsumeXmlReader(bufio.NewReader(file))
 		case dbio.FileTypeParquet:
-			err = ds.ConsumeParquetReaderSeeker(file)
+			err = ds.ConsumeParquetReaderSeeker(file, Cfg.Filters...)
 		case dbio.FileTypeAvro:
 			err = ds.ConsumeAvroReaderSeeker(file)
+		case dbio.FileTypeOrc:
+			err = ds.ConsumeOrcReaderSeeker(file)
 		case dbio.FileTypeSAS:
 			err = ds.ConsumeSASReaderSeeker(file)
+		case dbio.FileTypeFixed:
+			err = ds.ConsumeFixedReader(bufio.NewReader(file))
 		case dbio.FileTypeExcel:
 			err = ds.ConsumeExcelReaderSeeker(file, fs.properties)
 		case dbio.FileTypeCsv:
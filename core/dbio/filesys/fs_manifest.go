@@ -0,0 +1,220 @@
+package filesys
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+)
+
+// ManifestEntry is an object listed in a manifest
+type ManifestEntry struct {
+	Bucket string // the bucket, if listed (inventory reports)
+	Key    string // the object key, or the full url
+}
+
+// ParseManifest parses a manifest enumerating the objects to read, in one of the formats:
+//   - an S3 Inventory CSV report: quoted `"bucket","key",...` rows, with URL-encoded keys
+//   - a CSV report with a header holding the `bucket` and `key` (or `name`) columns, such as a GCS inventory report
+//   - a simple list: one object key or url per line
+//
+// Blank lines and lines starting with `#` are skipped, as well as folders.
+func ParseManifest(reader io.Reader) (entries []ManifestEntry, err error) {
+	err = scanManifest(reader, func(entry ManifestEntry) {
+		entries = append(entries, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// scanManifest calls onEntry for each object listed in the manifest, as it is read
+func scanManifest(reader io.Reader, onEntry func(entry ManifestEntry)) (err error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	format := ""              // inventory, header or list
+	bucketCol, keyCol := 0, 1 // the columns of the csv formats
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if format == "" {
+			header := lo.Map(strings.Split(strings.ToLower(line), ","), func(h string, i int) string {
+				return strings.Trim(strings.TrimSpace(h), `"`)
+			})
+			keyCol = lo.IndexOf(header, "key")
+			if keyCol == -1 {
+				keyCol = lo.IndexOf(header, "name")
+			}
+			bucketCol = lo.IndexOf(header, "bucket")
+
+			switch {
+			case bucketCol > -1 && keyCol > -1:
+				format = "header"
+				continue
+			case strings.HasPrefix(line, `"`):
+				format = "inventory"
+				bucketCol, keyCol = 0, 1
+			default:
+				format = "list"
+			}
+		}
+
+		entry := ManifestEntry{Key: line}
+		if format != "list" {
+			row, err := csv.NewReader(strings.NewReader(line)).Read()
+			if err != nil {
+				return g.Error(err, "invalid manifest line %d", lineNum)
+			} else if len(row) <= bucketCol || len(row) <= keyCol {
+				return g.Error("invalid manifest line %d, expected the bucket and key columns", lineNum)
+			}
+
+			entry = ManifestEntry{Bucket: row[bucketCol], Key: row[keyCol]}
+			if format == "inventory" {
+				// keys of S3 Inventory reports are URL-encoded
+				if entry.Key, err = url.QueryUnescape(entry.Key); err != nil {
+					return g.Error(err, "invalid key on manifest line %d", lineNum)
+				}
+			}
+		}
+
+		if entry.Key == "" || strings.HasSuffix(entry.Key, "/") {
+			continue // folder
+		}
+		onEntry(entry)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return g.Error(err, "could not read manifest")
+	}
+	return nil
+}
+
+// ManifestNodes returns the nodes of the objects listed in the manifest (`cfg.Manifest`),
+// under the url, without listing the bucket. The objects are not checked to exist here:
+// a missing object errors when read, or is skipped with `cfg.ManifestSkipMissing`
+// (see ManifestObjectMissing).
+func ManifestNodes(fs FileSysClient, uri string, cfg iop.FileStreamConfig) (nodes FileNodes, err error) {
+	reader, closeManifest, err := readManifest(fs, cfg.Manifest)
+	if err != nil {
+		return nil, g.Error(err, "could not open manifest %s", cfg.Manifest)
+	}
+	defer closeManifest()
+
+	// resolve the urls of the objects under the url
+	prefix := NormalizeURI(fs, uri)
+	seen := map[string]bool{}
+	err = scanManifest(reader, func(entry ManifestEntry) {
+		objectURI := entry.Key
+		if entry.Bucket != "" {
+			objectURI = g.F("%s://%s/%s", fs.FsType(), entry.Bucket, strings.TrimPrefix(entry.Key, "/"))
+		} else if !strings.Contains(objectURI, "://") {
+			objectURI = NormalizeURI(fs, objectURI)
+		}
+
+		if seen[objectURI] {
+			return
+		} else if objectURI == prefix || strings.HasPrefix(objectURI, strings.TrimSuffix(prefix, "/")+"/") {
+			seen[objectURI] = true
+			nodes = append(nodes, FileNode{URI: objectURI})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nodes) == 0 {
+		return nil, g.Error("manifest %s does not list any object under %s", cfg.Manifest, uri)
+	}
+	g.Debug("manifest %s lists %d objects under %s", cfg.Manifest, len(nodes), uri)
+
+	return nodes, nil
+}
+
+// objectExister is a file system whose readers only fail once read,
+// checking that an object exists beforehand
+type objectExister interface {
+	objectExists(uri string) (found bool, err error)
+}
+
+// checkManifestObject checks that an object listed in the manifest exists,
+// when its file system reader would not fail upfront
+func checkManifestObject(fs FileSysClient, cfg iop.FileStreamConfig, uri string) error {
+	exister, ok := fs.Self().(objectExister)
+	if cfg.Manifest == "" || !ok {
+		return nil
+	}
+	if found, err := exister.objectExists(uri); err != nil {
+		return err
+	} else if !found {
+		return g.Error("%s not found", uri)
+	}
+	return nil
+}
+
+// ManifestObjectMissing checks the error of reading an object listed in the manifest.
+// It returns true when the object was not found and `cfg.ManifestSkipMissing` is set
+// (the object is skipped), and otherwise the error to raise.
+func ManifestObjectMissing(cfg iop.FileStreamConfig, uri string, readErr error) (skip bool, err error) {
+	if cfg.Manifest == "" || !isNotFoundErr(readErr) {
+		return false, readErr
+	} else if !cfg.ManifestSkipMissing {
+		return false, g.Error(readErr, "object %s of manifest %s not found (use manifest_skip_missing to skip)", uri, cfg.Manifest)
+	}
+	g.Warn("skipping object %s of manifest %s: not found", uri, cfg.Manifest)
+	return true, nil
+}
+
+// isNotFoundErr returns true if the error is a missing file or object,
+// from the local disk or a cloud storage
+func isNotFoundErr(err error) bool {
+	if err == nil {
+		return false
+	} else if errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, pattern := range []string{
+		"no such file", "nosuchkey", "notfound", "not found",
+		"does not exist", "doesn't exist", "status code: 404", "statuscode=404",
+	} {
+		if strings.Contains(msg, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// readManifest opens the manifest, a local file or an object of the file system,
+// decompressing it if needed
+func readManifest(fs FileSysClient, manifest string) (reader io.Reader, closeFunc func(), err error) {
+	closeFunc = func() {}
+	if strings.Contains(manifest, "://") && !strings.HasPrefix(manifest, "file://") {
+		reader, err = fs.Self().GetReader(manifest)
+	} else {
+		var file *os.File
+		file, err = os.Open(strings.TrimPrefix(manifest, "file://"))
+		reader, closeFunc = file, func() { file.Close() }
+	}
+	if err != nil {
+		return nil, closeFunc, err
+	}
+
+	if reader, err = iop.AutoDecompress(reader); err != nil {
+		closeFunc()
+		return nil, closeFunc, g.Error(err, "could not decompress manifest")
+	}
+	return reader, closeFunc, nil
+}
@@ -0,0 +1,86 @@
+package filesys
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+)
+
+// Partitions returns the Hive-style partition values in the node path,
+// such as `dt=2021-01-01` in `s3://bucket/table/dt=2021-01-01/file.parquet`
+func (fn *FileNode) Partitions() (partitions []iop.KeyValue) {
+	parts := strings.Split(strings.TrimSuffix(fn.Path(), "/"), "/")
+	if !fn.IsDir && len(parts) > 0 {
+		parts = parts[:len(parts)-1] // last part is the file name
+	}
+
+	for _, part := range parts {
+		key, value, found := strings.Cut(part, "=")
+		if !found || key == "" {
+			continue
+		}
+		if val, err := url.PathUnescape(value); err == nil {
+			value = val
+		}
+		partitions = append(partitions, iop.KeyValue{Key: strings.ToLower(key), Value: value})
+	}
+	return
+}
+
+// PartitionKeys returns the partition keys common to all the files
+func (fns FileNodes) PartitionKeys() (keys []string) {
+	first := true
+	for _, node := range fns {
+		if node.IsDir {
+			continue
+		}
+		nodeKeys := lo.Map(node.Partitions(), func(kv iop.KeyValue, i int) string { return kv.Key })
+		if first {
+			keys, first = nodeKeys, false
+		} else {
+			keys = lo.Intersect(keys, nodeKeys)
+		}
+	}
+	return keys
+}
+
+// PartitionCondition is a condition to filter partition values on
+type PartitionCondition = iop.FilterCondition
+
+// ParsePartitionFilter parses a filter expression such as
+// `dt >= 2021-01-01 and region = us`. Conditions are joined with `and`.
+func ParsePartitionFilter(expr string) (conditions []PartitionCondition, err error) {
+	return iop.ParseFilterConditions(expr)
+}
+
+// FilterPartitions returns the file nodes whose partition values satisfy
+// the conditions. Nodes without a partition key in the conditions are excluded.
+func (fns FileNodes) FilterPartitions(conditions []PartitionCondition) (nodes FileNodes) {
+	for _, node := range fns {
+		if node.IsDir {
+			continue
+		}
+
+		values := map[string]string{}
+		for _, kv := range node.Partitions() {
+			values[kv.Key] = cast.ToString(kv.Value)
+		}
+
+		matched := true
+		for _, cond := range conditions {
+			value, ok := values[cond.Key]
+			if !ok || !cond.Match(value) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			nodes = append(nodes, node)
+		}
+	}
+	return
+}
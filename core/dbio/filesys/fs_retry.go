@@ -0,0 +1,149 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+)
+
+// StorageOptions are the request options of the cloud storage clients
+// (S3, Google Storage & Azure), from the connection properties
+// `request_timeout`, `max_retries` and `part_size`
+type StorageOptions struct {
+	RequestTimeout time.Duration // timeout of each request (0 is the client default)
+	MaxRetries     int           // retries of a failed request or part (-1 is the client default)
+	PartSize       int64         // size of the multipart upload/download parts (0 is the client default)
+}
+
+// minPartSize is the minimum part size of multipart uploads (of S3)
+const minPartSize = 5 * 1024 * 1024
+
+// defaultMaxRetries bounds the retries when max_retries is not set, where
+// the client would otherwise retry until its deadline
+const defaultMaxRetries = 3
+
+// storageOptions parses the storage options from the properties. The
+// timeout is a duration (`30s`) or seconds, the part size in bytes (`16MB`).
+func (fs *BaseFileSysClient) storageOptions() (so StorageOptions, err error) {
+	so.MaxRetries = -1
+
+	if val := fs.GetProp("REQUEST_TIMEOUT"); val != "" {
+		if seconds, e := cast.ToIntE(val); e == nil {
+			so.RequestTimeout = time.Duration(seconds) * time.Second
+		} else if so.RequestTimeout, err = time.ParseDuration(val); err != nil {
+			return so, g.Error(err, "invalid value for request_timeout: %s", val)
+		}
+		if so.RequestTimeout <= 0 {
+			return so, g.Error("invalid value for request_timeout: %s (must be positive)", val)
+		}
+	}
+
+	if val := fs.GetProp("MAX_RETRIES"); val != "" {
+		if so.MaxRetries, err = cast.ToIntE(val); err != nil || so.MaxRetries < 0 {
+			return so, g.Error("invalid value for max_retries: %s", val)
+		}
+	}
+
+	if val := fs.GetProp("PART_SIZE"); val != "" {
+		partSize, err := humanize.ParseBytes(val)
+		if err != nil {
+			return so, g.Error(err, "invalid value for part_size: %s", val)
+		} else if partSize < minPartSize {
+			return so, g.Error("part_size (%s) must be at least %s", val, humanize.IBytes(minPartSize))
+		}
+		so.PartSize = int64(partSize)
+	}
+
+	return so, nil
+}
+
+// storageHTTPClient returns the http client of the storage requests, with the
+// timeout bounding the connection and the wait for the response headers. The
+// body is not bounded, so that large object downloads are not cut off.
+func storageHTTPClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = timeout
+	transport.ResponseHeaderTimeout = timeout
+	return &http.Client{Transport: transport}
+}
+
+// rangeReadOpener opens the object from the offset
+type rangeReadOpener func(offset int64) (io.ReadCloser, error)
+
+// rangeRetryReader re-opens the object from the current offset on a
+// transient read error (such as a reset connection), instead of failing
+// the whole read
+type rangeRetryReader struct {
+	ctx        context.Context
+	open       rangeReadOpener
+	reader     io.ReadCloser
+	offset     int64
+	retries    int
+	maxRetries int
+	backoff    time.Duration
+}
+
+// newRangeRetryReader opens the object, to retry up to maxRetries times
+func newRangeRetryReader(ctx context.Context, open rangeReadOpener, maxRetries int) (r *rangeRetryReader, err error) {
+	r = &rangeRetryReader{
+		ctx:        ctx,
+		open:       open,
+		maxRetries: maxRetries,
+		backoff:    time.Second,
+	}
+
+	r.reader, err = open(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *rangeRetryReader) Read(p []byte) (n int, err error) {
+	for {
+		n, err = r.reader.Read(p)
+		r.offset += int64(n)
+		if err == nil || err == io.EOF || r.retries >= r.maxRetries {
+			return n, err
+		} else if errors.Is(err, context.Canceled) || r.ctx.Err() != nil {
+			return n, err
+		}
+
+		r.retries++
+		g.Debug("retrying read at offset %d (%d/%d) after error: %s", r.offset, r.retries, r.maxRetries, err.Error())
+		r.reader.Close()
+
+		select {
+		case <-r.ctx.Done():
+			return n, r.ctx.Err()
+		case <-time.After(r.backoff * time.Duration(r.retries)):
+		}
+
+		if r.reader, err = r.open(r.offset); err != nil {
+			r.reader = io.NopCloser(&errReader{err})
+			return n, g.Error(err, "could not re-open reader at offset %d", r.offset)
+		}
+
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (r *rangeRetryReader) Close() error {
+	return r.reader.Close()
+}
+
+// errReader returns the error on read
+type errReader struct{ err error }
+
+func (er *errReader) Read(p []byte) (int, error) { return 0, er.err }
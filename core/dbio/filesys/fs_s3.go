@@ -126,6 +126,11 @@ func (fs *S3FileSysClient) Connect() (err error) {
 		region = defaultRegion
 	}
 
+	storageOpts, err := fs.storageOptions()
+	if err != nil {
+		return g.Error(err, "invalid storage options")
+	}
+
 	// https://docs.aws.amazon.com/sdk-for-go/api/service/s3/
 	awsConfig := &aws.Config{
 		Region:                         aws.String(region),
@@ -135,6 +140,15 @@ func (fs *S3FileSysClient) Connect() (err error) {
 		// LogLevel: aws.LogLevel(aws.LogDebugWithHTTPBody),
 	}
 
+	// retries apply to each request, so failed multipart parts are retried
+	// individually instead of the whole transfer
+	if storageOpts.MaxRetries >= 0 {
+		awsConfig.MaxRetries = aws.Int(storageOpts.MaxRetries)
+	}
+	if storageOpts.RequestTimeout > 0 {
+		awsConfig.HTTPClient = storageHTTPClient(storageOpts.RequestTimeout)
+	}
+
 	if profile := fs.GetProp("PROFILE"); profile != "" {
 		awsConfig.Credentials = credentials.NewSharedCredentials("", profile)
 	} else if fs.GetProp("ACCESS_KEY_ID") != "" && fs.GetProp("SECRET_ACCESS_KEY") != "" {
@@ -253,6 +267,23 @@ func (fs *S3FileSysClient) delete(uri string) (err error) {
 	return
 }
 
+// getPartSize returns the part size of multipart uploads & downloads
+func (fs *S3FileSysClient) getPartSize() int64 {
+	if storageOpts, _ := fs.storageOptions(); storageOpts.PartSize > 0 {
+		return storageOpts.PartSize
+	}
+	return int64(os.Getpagesize()) * 1024 * 10
+}
+
+// getPartBodyMaxRetries returns the retries of a part download, when
+// reading its body fails
+func (fs *S3FileSysClient) getPartBodyMaxRetries() int {
+	if storageOpts, _ := fs.storageOptions(); storageOpts.MaxRetries >= 0 {
+		return storageOpts.MaxRetries
+	}
+	return s3manager.DefaultPartBodyMaxRetries
+}
+
 func (fs *S3FileSysClient) getConcurrency() int {
 	conc := cast.ToInt(fs.GetProp("CONCURRENCY"))
 	if conc == 0 {
@@ -261,6 +292,27 @@ func (fs *S3FileSysClient) getConcurrency() int {
 	return conc
 }
 
+// objectExists checks that the object exists with a HEAD request, since the
+// reader of GetReader only fails once read
+func (fs *S3FileSysClient) objectExists(uri string) (found bool, err error) {
+	key, err := fs.GetPath(uri)
+	if err != nil {
+		return false, err
+	}
+
+	svc := s3.New(fs.getSession())
+	_, err = svc.HeadObjectWithContext(fs.Context().Ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && g.In(aerr.Code(), "NotFound", s3.ErrCodeNoSuchKey) {
+		return false, nil
+	} else if err != nil {
+		return false, g.Error(err, "could not check object "+uri)
+	}
+	return true, nil
+}
+
 // GetReader return a reader for the given path
 // path should specify the full path with scheme:
 // `s3://my_bucket/key/to/file.txt` or `s3://my_bucket/key/to/directory`
@@ -271,14 +323,16 @@ func (fs *S3FileSysClient) GetReader(uri string) (reader io.Reader, err error) {
 	}
 
 	// https://github.com/chanzuckerberg/s3parcp
-	PartSize := int64(os.Getpagesize()) * 1024 * 10
+	PartSize := fs.getPartSize()
 	Concurrency := fs.getConcurrency()
 	BufferSize := 64 * 1024
 	svc := s3.New(fs.getSession())
 
 	// Create a downloader with the session and default options
+	// parts are downloaded with range requests, re-requested on body read errors
 	downloader := s3manager.NewDownloader(fs.getSession(), func(d *s3manager.Downloader) {
 		d.PartSize = PartSize
+		d.PartBodyMaxRetries = fs.getPartBodyMaxRetries()
 		d.Concurrency = Concurrency
 		d.BufferProvider = s3manager.NewPooledBufferedWriterReadFromProvider(BufferSize)
 		d.S3 = svc
@@ -318,7 +372,7 @@ func (fs *S3FileSysClient) GetWriter(uri string) (writer io.Writer, err error) {
 	}
 
 	// https://github.com/chanzuckerberg/s3parcp
-	PartSize := int64(os.Getpagesize()) * 1024 * 10
+	PartSize := fs.getPartSize()
 	Concurrency := fs.getConcurrency()
 	BufferSize := 10485760 // 10MB
 	svc := s3.New(fs.getSession())
@@ -366,6 +420,9 @@ func (fs *S3FileSysClient) Write(uri string, reader io.Reader) (bw int64, err er
 
 	uploader := s3manager.NewUploader(fs.getSession())
 	uploader.Concurrency = fs.Context().Wg.Limit
+	if storageOpts, _ := fs.storageOptions(); storageOpts.PartSize > 0 {
+		uploader.PartSize = storageOpts.PartSize
+	}
 
 	// Create pipe to get bytes written
 	pr, pw := io.Pipe()
@@ -0,0 +1,277 @@
+package filesys
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"encoding/csv"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/slingdata-io/sling-cli/core/env"
+	"github.com/spf13/cast"
+)
+
+var (
+	s3SelectIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	s3SelectField      = regexp.MustCompile(`^[\w\-\.]+$`)
+)
+
+// S3SelectExpression generates the S3 Select SQL of the selected columns
+// and where conditions, for a CSV or JSON Lines object. It errors when the
+// select cannot be pushed to S3 (exclusions, aliases, other formats).
+func S3SelectExpression(fields []string, filters []iop.FilterCondition, format dbio.FileType) (expr string, err error) {
+	if !g.In(format, dbio.FileTypeCsv, dbio.FileTypeJsonLines) {
+		return "", g.Error("S3 Select is only supported for csv and jsonlines files, not %s", format)
+	}
+
+	columns, err := s3SelectFields(fields)
+	if err != nil {
+		return "", err
+	}
+
+	selectExpr := "*"
+	if len(columns) > 0 {
+		selectExpr = strings.Join(lo.Map(columns, func(c string, i int) string { return s3SelectColumn(c) }), ", ")
+	}
+	expr = g.F("SELECT %s FROM S3Object s", selectExpr)
+
+	conditions := []string{}
+	for _, cond := range filters {
+		operator := cond.Operator
+		switch operator {
+		case "==":
+			operator = "="
+		case "=", "!=", "<>", ">", ">=", "<", "<=":
+		default:
+			return "", g.Error("operator not supported by S3 Select: %s", operator)
+		}
+
+		column := s3SelectColumn(cond.Key)
+		value := g.F("'%s'", strings.ReplaceAll(cond.Value, `'`, `''`))
+		if _, err := cast.ToFloat64E(cond.Value); err == nil {
+			value = cond.Value
+			if format == dbio.FileTypeCsv {
+				// CSV values are strings, cast to compare as numbers
+				column = g.F("CAST(%s AS FLOAT)", column)
+			}
+		}
+		conditions = append(conditions, g.F("%s %s %s", column, operator, value))
+	}
+
+	if len(conditions) > 0 {
+		expr = expr + " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	return expr, nil
+}
+
+// s3SelectFields returns the selected column names, empty for all columns
+func s3SelectFields(fields []string) (columns []string, err error) {
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "*" {
+			return nil, nil
+		} else if strings.HasPrefix(field, "-") || !s3SelectField.MatchString(field) {
+			return nil, g.Error("select field not supported by S3 Select: %s", field)
+		}
+		columns = append(columns, field)
+	}
+	return columns, nil
+}
+
+// s3SelectColumn references a column of the S3 object. Simple identifiers
+// are unquoted so that they match case-insensitively.
+func s3SelectColumn(name string) string {
+	if s3SelectIdentifier.MatchString(name) {
+		return "s." + name
+	}
+	return g.F(`s."%s"`, strings.ReplaceAll(name, `"`, `""`))
+}
+
+// s3SelectCompression returns the S3 Select compression type of the object,
+// from its extension or the compression option
+func s3SelectCompression(uri, compression string) (string, error) {
+	lowerURI := strings.ToLower(uri)
+	switch {
+	case strings.HasSuffix(lowerURI, ".gz"):
+		return s3.CompressionTypeGzip, nil
+	case strings.HasSuffix(lowerURI, ".bz2"):
+		return s3.CompressionTypeBzip2, nil
+	}
+
+	switch iop.CompressorType(strings.ToLower(compression)) {
+	case "", iop.NoneCompressorType, iop.AutoCompressorType:
+		return s3.CompressionTypeNone, nil
+	case iop.GzipCompressorType:
+		return s3.CompressionTypeGzip, nil
+	}
+	return "", g.Error("compression not supported by S3 Select: %s", compression)
+}
+
+// SelectReader returns a reader of the S3 Select results of an object.
+// CSV results are returned with a header line, JSON results as JSON Lines.
+// The results are spooled to a temp file, removed once read.
+func (fs *S3FileSysClient) SelectReader(uri, expr string, fields []string, format dbio.FileType, compression string) (reader io.Reader, err error) {
+	key, err := fs.GetPath(uri)
+	if err != nil {
+		return
+	}
+
+	delimiter := fs.GetProp("delimiter")
+	if delimiter == "" {
+		delimiter = ","
+	}
+	quote := fs.GetProp("quote")
+	if quote == "" {
+		quote = `"`
+	}
+
+	input := &s3.InputSerialization{CompressionType: aws.String(compression)}
+	output := &s3.OutputSerialization{}
+	header := []string{}
+	if format == dbio.FileTypeCsv {
+		input.CSV = &s3.CSVInput{
+			FileHeaderInfo:             aws.String(s3.FileHeaderInfoUse),
+			FieldDelimiter:             aws.String(delimiter),
+			QuoteCharacter:             aws.String(quote),
+			AllowQuotedRecordDelimiter: aws.Bool(true),
+		}
+		output.CSV = &s3.CSVOutput{
+			FieldDelimiter: aws.String(delimiter),
+			QuoteCharacter: aws.String(quote),
+		}
+
+		// S3 Select does not output the header
+		header, err = fs.selectHeader(key, fields, compression, delimiter)
+		if err != nil {
+			return nil, g.Error(err, "could not get header of %s", uri)
+		}
+	} else {
+		input.JSON = &s3.JSONInput{Type: aws.String(s3.JSONTypeLines)}
+		output.JSON = &s3.JSONOutput{RecordDelimiter: aws.String("\n")}
+	}
+
+	g.Debug("using S3 Select for %s: %s", uri, expr)
+	svc := s3.New(fs.getSession())
+	resp, err := svc.SelectObjectContentWithContext(
+		fs.Context().Ctx,
+		&s3.SelectObjectContentInput{
+			Bucket:              aws.String(fs.bucket),
+			Key:                 aws.String(key),
+			Expression:          aws.String(expr),
+			ExpressionType:      aws.String(s3.ExpressionTypeSql),
+			InputSerialization:  input,
+			OutputSerialization: output,
+		})
+	if err != nil {
+		return nil, g.Error(err, "could not S3 Select from %s", uri)
+	}
+
+	// spool the results, so that a failure mid-stream surfaces before any row
+	// is read, and the file can be downloaded fully instead
+	file, err := os.CreateTemp(env.GetTempFolder(), "sling_s3_select_*")
+	if err != nil {
+		resp.EventStream.Close()
+		return nil, g.Error(err, "could not create temp file for S3 Select results")
+	}
+	defer resp.EventStream.Close()
+
+	spooled := &spooledReader{file}
+	if len(header) > 0 {
+		w := csv.NewWriter(file)
+		w.Comma = rune(delimiter[0])
+		w.Write(header)
+		w.Flush()
+	}
+
+	for event := range resp.EventStream.Events() {
+		if records, ok := event.(*s3.RecordsEvent); ok {
+			if _, err = file.Write(records.Payload); err != nil {
+				spooled.Close()
+				return nil, g.Error(err, "could not write S3 Select records of %s", key)
+			}
+		}
+	}
+
+	if err = resp.EventStream.Err(); err != nil {
+		spooled.Close()
+		return nil, g.Error(err, "error reading S3 Select results of %s", key)
+	} else if _, err = file.Seek(0, io.SeekStart); err != nil {
+		spooled.Close()
+		return nil, g.Error(err, "could not read S3 Select results of %s", key)
+	}
+
+	return spooled, nil
+}
+
+// spooledReader reads a temp file, removed once fully read or closed
+type spooledReader struct {
+	file *os.File
+}
+
+func (sr *spooledReader) Read(p []byte) (n int, err error) {
+	n, err = sr.file.Read(p)
+	if err == io.EOF {
+		sr.Close()
+	}
+	return n, err
+}
+
+func (sr *spooledReader) Close() error {
+	sr.file.Close()
+	return os.Remove(sr.file.Name())
+}
+
+// selectHeader returns the column names of the S3 Select results on a CSV object:
+// the selected columns, else the header line of the object
+func (fs *S3FileSysClient) selectHeader(key string, fields []string, compression, delimiter string) (header []string, err error) {
+	if header, err = s3SelectFields(fields); err != nil || len(header) > 0 {
+		return header, err
+	}
+
+	// read the first bytes of the object for the header line
+	svc := s3.New(fs.getSession())
+	resp, err := svc.GetObjectWithContext(
+		fs.Context().Ctx,
+		&s3.GetObjectInput{
+			Bucket: aws.String(fs.bucket),
+			Key:    aws.String(key),
+			Range:  aws.String("bytes=0-1048575"),
+		})
+	if err != nil {
+		return nil, g.Error(err, "could not read the header of %s", key)
+	}
+	defer resp.Body.Close()
+
+	var reader io.Reader = resp.Body
+	switch compression {
+	case s3.CompressionTypeBzip2:
+		reader = bzip2.NewReader(reader)
+	case s3.CompressionTypeGzip:
+		if reader, err = iop.AutoDecompress(reader); err != nil {
+			return nil, g.Error(err, "could not decompress %s", key)
+		}
+	}
+
+	line, err := bufio.NewReader(reader).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, g.Error(err, "could not read the header of %s", key)
+	}
+
+	r := csv.NewReader(strings.NewReader(line))
+	r.Comma = rune(delimiter[0])
+	header, err = r.Read()
+	if err != nil {
+		return nil, g.Error(err, "could not parse the header of %s", key)
+	}
+
+	return header, nil
+}
@@ -2,9 +2,13 @@ package filesys
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"path"
 	"strings"
 	"testing"
 	"time"
@@ -15,6 +19,7 @@ import (
 	"github.com/flarco/g/net"
 	"github.com/linkedin/goavro/v2"
 	"github.com/parquet-go/parquet-go"
+	"github.com/samber/lo"
 	"github.com/slingdata-io/sling-cli/core/dbio"
 	"github.com/spf13/cast"
 
@@ -91,6 +96,240 @@ func TestFileSysLocalCsv(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, 18, len(data1.Rows))
 
+	// select and alias positional columns of the headerless csv
+	df2, err := fs.ReadDataflow("test/test2/test2.1.noheader.csv", iop.FileStreamConfig{Select: []string{"@1 as id", "@3", "@4 as email"}})
+	assert.NoError(t, err)
+
+	data2, err := df2.Collect()
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 18, len(data2.Rows))
+		assert.Equal(t, []string{"id", "col_003", "email"}, data2.Columns.Names())
+		assert.EqualValues(t, 1, data2.Rows[0][0])
+		assert.Equal(t, "Lumox", data2.Rows[0][1])
+		assert.Equal(t, "ilumox0@unc.edu", data2.Rows[0][2])
+	}
+
+}
+
+func TestFileSysLocalPartitions(t *testing.T) {
+	t.Parallel()
+	fs, err := NewFileSysClient(dbio.TypeFileLocal)
+	assert.NoError(t, err)
+
+	// mock a Hive-style partitioned layout
+	folder, err := os.MkdirTemp("", "sling_partitions")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(folder)
+
+	for i, dt := range []string{"2021-01-01", "2021-01-02", "2021-01-03"} {
+		for _, region := range []string{"us", "eu"} {
+			content := g.F("id,name\n%d,%s\n", i+1, region)
+			_, err = fs.Write(g.F("%s/dt=%s/region=%s/data.csv", folder, dt, region), strings.NewReader(content))
+			assert.NoError(t, err)
+		}
+	}
+
+	nodes, err := fs.ListRecursive(folder)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"dt", "region"}, nodes.PartitionKeys())
+	}
+
+	conditions, err := ParsePartitionFilter("dt >= 2021-01-02 and region = 'us'")
+	if assert.NoError(t, err) && assert.Len(t, conditions, 2) {
+		assert.Equal(t, PartitionCondition{Key: "dt", Operator: ">=", Value: "2021-01-02"}, conditions[0])
+		assert.Len(t, nodes.FilterPartitions(conditions), 2)
+	}
+
+	_, err = ParsePartitionFilter("dt >>> 2021")
+	assert.Error(t, err)
+
+	// numbers compare as numbers
+	assert.True(t, PartitionCondition{Key: "h", Operator: ">", Value: "9"}.Match("10"))
+
+	// read with filter, partition keys become columns
+	df, err := fs.ReadDataflow(folder, iop.FileStreamConfig{PartitionFilter: "dt >= 2021-01-02"})
+	if assert.NoError(t, err) {
+		data, err := df.Collect()
+		assert.NoError(t, err)
+		assert.Len(t, data.Rows, 4)
+		assert.Contains(t, data.Columns.Names(), "dt")
+		assert.Contains(t, data.Columns.Names(), "region")
+		for _, rec := range data.Records() {
+			assert.GreaterOrEqual(t, cast.ToString(rec["dt"]), "2021-01-02")
+		}
+	}
+
+	// incremental on partition key, only newer partitions
+	df, err = fs.ReadDataflow(folder, iop.FileStreamConfig{IncrementalKey: "dt", IncrementalValue: "'2021-01-02'"})
+	if assert.NoError(t, err) {
+		data, err := df.Collect()
+		assert.NoError(t, err)
+		if assert.Len(t, data.Rows, 2) {
+			assert.Equal(t, "2021-01-03", data.Records()[0]["dt"])
+		}
+	}
+
+	// no new partitions
+	_, err = fs.ReadDataflow(folder, iop.FileStreamConfig{IncrementalKey: "dt", IncrementalValue: "'2021-01-03'"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Provided 0 files")
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	// S3 Inventory report, with URL-encoded keys
+	inventory := strings.Join([]string{
+		`"my-bucket","events/2024/a%20b.csv","1024","2024-01-01T00:00:00.000Z"`,
+		`"my-bucket","events/2024/c%2Bd.csv","2048","2024-01-02T00:00:00.000Z"`,
+		`"my-bucket","events/2024/","0","2024-01-02T00:00:00.000Z"`,
+	}, "\n")
+	entries, err := ParseManifest(strings.NewReader(inventory))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []ManifestEntry{
+			{Bucket: "my-bucket", Key: "events/2024/a b.csv"},
+			{Bucket: "my-bucket", Key: "events/2024/c+d.csv"},
+		}, entries)
+	}
+
+	// report with a header, such as a GCS inventory report
+	entries, err = ParseManifest(strings.NewReader("bucket,name,size\nmy-bucket,events/a.csv,10\n"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []ManifestEntry{{Bucket: "my-bucket", Key: "events/a.csv"}}, entries)
+	}
+
+	// simple list of keys & urls
+	entries, err = ParseManifest(strings.NewReader("# objects\nevents/a.csv\n\n  s3://my-bucket/events/b,c.csv \n"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []ManifestEntry{{Key: "events/a.csv"}, {Key: "s3://my-bucket/events/b,c.csv"}}, entries)
+	}
+
+	_, err = ParseManifest(strings.NewReader(`"my-bucket"` + "\n"))
+	assert.Error(t, err)
+}
+
+func TestFileSysLocalManifest(t *testing.T) {
+	fs, err := NewFileSysClient(dbio.TypeFileLocal)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	folder, err := os.MkdirTemp("", "sling_manifest")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(folder)
+
+	for i := 1; i <= 3; i++ {
+		_, err = fs.Write(g.F("%s/data/file%d.csv", folder, i), strings.NewReader(g.F("id,name\n%d,name%d\n", i, i)))
+		assert.NoError(t, err)
+	}
+
+	// the manifest lists 2 of the 3 files, a missing one and a file outside of the url (skipped)
+	manifestPath := path.Join(folder, "manifest.txt")
+	manifest := strings.Join([]string{
+		folder + "/data/file1.csv",
+		"file://" + folder + "/data/file3.csv",
+		folder + "/data/missing.csv",
+		folder + "/manifest.txt",
+	}, "\n")
+	if !assert.NoError(t, os.WriteFile(manifestPath, []byte(manifest), 0644)) {
+		return
+	}
+
+	// only the listed objects are read, without checking them
+	nodes, err := ManifestNodes(fs, folder+"/data/", iop.FileStreamConfig{Manifest: manifestPath})
+	if assert.NoError(t, err) && assert.Len(t, nodes, 3) {
+		assert.Equal(t, "file://"+folder+"/data/file1.csv", nodes[0].URI)
+		assert.Equal(t, "file://"+folder+"/data/missing.csv", nodes[2].URI)
+	}
+
+	// missing objects error when read
+	df, err := fs.ReadDataflow(folder+"/data/", iop.FileStreamConfig{Manifest: manifestPath, Format: dbio.FileTypeCsv})
+	if err == nil {
+		_, err = df.Collect()
+	}
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "of manifest")
+		assert.Contains(t, err.Error(), "missing.csv")
+	}
+
+	df, err = fs.ReadDataflow(folder+"/data/", iop.FileStreamConfig{Manifest: manifestPath, ManifestSkipMissing: true, Format: dbio.FileTypeCsv})
+	if assert.NoError(t, err) {
+		data, err := df.Collect()
+		assert.NoError(t, err)
+		assert.Len(t, data.Rows, 2)
+		ids := lo.Map(data.Records(), func(rec map[string]any, i int) int { return cast.ToInt(rec["id"]) })
+		assert.ElementsMatch(t, []int{1, 3}, ids)
+	}
+
+	// gzipped manifest
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte(folder + "/data/file2.csv\n"))
+	gw.Close()
+	if assert.NoError(t, os.WriteFile(manifestPath+".gz", buf.Bytes(), 0644)) {
+		nodes, err = ManifestNodes(fs, folder+"/data/", iop.FileStreamConfig{Manifest: manifestPath + ".gz"})
+		if assert.NoError(t, err) && assert.Len(t, nodes, 1) {
+			assert.Equal(t, "file://"+folder+"/data/file2.csv", nodes[0].URI)
+		}
+	}
+}
+
+func TestFileSysLocalPartitionsWhere(t *testing.T) {
+	fs, err := NewFileSysClient(dbio.TypeFileLocal)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// mock a Hive-style partitioned parquet layout
+	folder, err := os.MkdirTemp("", "sling_partitions_where")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(folder)
+
+	columns := iop.NewColumns(iop.Column{Name: "id", Type: iop.BigIntType}, iop.Column{Name: "name", Type: iop.StringType})
+	for _, dt := range []string{"2021-01-02", "2021-01-03"} {
+		filePath := g.F("%s/dt=%s/data.parquet", folder, dt)
+		os.MkdirAll(path.Dir(filePath), 0755)
+		f, err := os.Create(filePath)
+		if !assert.NoError(t, err) {
+			return
+		}
+		pw, err := iop.NewParquetArrowWriter(f, columns, compress.Codecs.Snappy)
+		if !assert.NoError(t, err) {
+			return
+		}
+		for i := 1; i <= 3; i++ {
+			assert.NoError(t, pw.WriteRow([]any{int64(i), g.F("name-%d", i)}))
+		}
+		assert.NoError(t, pw.Close())
+		f.Close()
+	}
+
+	// an invalid file in a pruned partition, would fail if opened
+	os.MkdirAll(folder+"/dt=2021-01-01", 0755)
+	os.WriteFile(folder+"/dt=2021-01-01/data.parquet", []byte("not a parquet file"), 0644)
+
+	df, err := fs.ReadDataflow(folder, iop.FileStreamConfig{Format: dbio.FileTypeParquet, Where: "dt >= 2021-01-02 and id > 1"})
+	if assert.NoError(t, err) {
+		data, err := df.Collect()
+		assert.NoError(t, err)
+		assert.Len(t, data.Rows, 4)
+		for _, rec := range data.Records() {
+			assert.Greater(t, cast.ToInt(rec["id"]), 1)
+			assert.GreaterOrEqual(t, cast.ToString(rec["dt"]), "2021-01-02")
+		}
+	}
+
+	// non-partition conditions need parquet files
+	csvFolder := folder + "/csv"
+	_, err = fs.Write(csvFolder+"/dt=2021-01-01/data.csv", strings.NewReader("id\n1\n"))
+	assert.NoError(t, err)
+	_, err = fs.ReadDataflow(csvFolder, iop.FileStreamConfig{Where: "id > 1"})
+	assert.Error(t, err)
 }
 
 func TestFileSysLocalFormat(t *testing.T) {
@@ -245,6 +484,59 @@ func TestFileSysLocalJson(t *testing.T) {
 
 }
 
+func TestFileSysLocalJsonGzipArrays(t *testing.T) {
+	t.Parallel()
+
+	// directory of gzipped JSON arrays, with varying keys per file
+	dir := path.Join(t.TempDir(), "json_arrays")
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+
+	files := map[string][]map[string]any{
+		"part-1.json.gz": {
+			{"id": 1, "name": "alice"},
+			{"id": 2, "name": "bob"},
+		},
+		"part-2.json.gz": {
+			{"id": 3, "email": "carol@example.com", "address": map[string]any{"city": "Lyon"}},
+		},
+		"part-3.json.gz": {},
+	}
+	for i := 4; i < 1004; i++ {
+		files["part-3.json.gz"] = append(files["part-3.json.gz"], map[string]any{"id": i, "active": i%2 == 0})
+	}
+
+	for name, records := range files {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		_, err := gw.Write([]byte(g.Marshal(records)))
+		assert.NoError(t, err)
+		assert.NoError(t, gw.Close())
+		assert.NoError(t, os.WriteFile(path.Join(dir, name), buf.Bytes(), 0644))
+	}
+
+	fs, err := NewFileSysClient(dbio.TypeFileLocal)
+	assert.NoError(t, err)
+	fs.SetProp("flatten", "true")
+
+	df, err := fs.ReadDataflow(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	data, err := df.Collect()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1003, len(data.Rows))
+
+	// the columns are unioned across files
+	names := strings.ToLower(strings.Join(data.Columns.Names(), ","))
+	for _, name := range []string{"id", "name", "email", "address__city", "active"} {
+		assert.Contains(t, names, name)
+	}
+	for _, row := range data.Rows {
+		assert.Equal(t, len(data.Columns), len(row))
+	}
+}
+
 func TestFileSysLocalXml(t *testing.T) {
 
 	fileBytes, err := os.ReadFile("test/test1/xml/test1.1.xml")
@@ -1440,3 +1732,141 @@ func testManyCSV(t *testing.T) {
 // 	}
 
 // }
+
+func TestS3SelectExpression(t *testing.T) {
+	filters, err := iop.ParseFilterConditions(`amount > 100 and region = 'us' and code != "it's"`)
+	g.AssertNoError(t, err)
+
+	expr, err := S3SelectExpression([]string{"id", "region", "unit-price"}, filters, dbio.FileTypeCsv)
+	if g.AssertNoError(t, err) {
+		assert.Equal(t, `SELECT s.id, s.region, s."unit-price" FROM S3Object s WHERE CAST(s.amount AS FLOAT) > 100 AND s.region = 'us' AND s.code != 'it''s'`, expr)
+	}
+
+	expr, err = S3SelectExpression(nil, filters[:1], dbio.FileTypeJsonLines)
+	if g.AssertNoError(t, err) {
+		assert.Equal(t, `SELECT * FROM S3Object s WHERE s.amount > 100`, expr)
+	}
+
+	expr, err = S3SelectExpression([]string{"*"}, nil, dbio.FileTypeCsv)
+	if g.AssertNoError(t, err) {
+		assert.Equal(t, `SELECT * FROM S3Object s`, expr)
+	}
+
+	// not supported, falls back to a full download
+	_, err = S3SelectExpression([]string{"-id"}, nil, dbio.FileTypeCsv)
+	assert.Error(t, err)
+	_, err = S3SelectExpression([]string{"id as key"}, nil, dbio.FileTypeCsv)
+	assert.Error(t, err)
+	_, err = S3SelectExpression(nil, filters, dbio.FileTypeParquet)
+	assert.Error(t, err)
+	_, err = s3SelectCompression("s3://bucket/file.csv.zst", "zstd")
+	assert.Error(t, err)
+
+	compression, err := s3SelectCompression("s3://bucket/file.csv.gz", "")
+	if g.AssertNoError(t, err) {
+		assert.Equal(t, "GZIP", compression)
+	}
+}
+
+func TestFileSysStorageOptions(t *testing.T) {
+	fs, err := NewFileSysClient(dbio.TypeFileS3, "BUCKET=test", "ACCESS_KEY_ID=a", "SECRET_ACCESS_KEY=b", "REGION=us-east-1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	s3Fs := fs.(*S3FileSysClient)
+
+	// defaults
+	so, err := s3Fs.storageOptions()
+	assert.NoError(t, err)
+	assert.Equal(t, StorageOptions{MaxRetries: -1}, so)
+	assert.Equal(t, int64(os.Getpagesize())*1024*10, s3Fs.getPartSize())
+
+	s3Fs.SetProp("REQUEST_TIMEOUT", "90s")
+	s3Fs.SetProp("MAX_RETRIES", "7")
+	s3Fs.SetProp("PART_SIZE", "16MiB")
+	so, err = s3Fs.storageOptions()
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Second, so.RequestTimeout)
+	assert.Equal(t, 7, so.MaxRetries)
+	assert.Equal(t, int64(16*1024*1024), s3Fs.getPartSize())
+	assert.Equal(t, 7, s3Fs.getPartBodyMaxRetries())
+
+	s3Fs.SetProp("REQUEST_TIMEOUT", "30")
+	so, _ = s3Fs.storageOptions()
+	assert.Equal(t, 30*time.Second, so.RequestTimeout)
+
+	// multipart parts must be at least 5MB
+	s3Fs.SetProp("PART_SIZE", "1MB")
+	_, err = s3Fs.storageOptions()
+	assert.Error(t, err)
+
+	s3Fs.SetProp("PART_SIZE", "")
+	s3Fs.SetProp("MAX_RETRIES", "-2")
+	_, err = s3Fs.storageOptions()
+	assert.Error(t, err)
+
+	s3Fs.SetProp("MAX_RETRIES", "")
+	s3Fs.SetProp("REQUEST_TIMEOUT", "-5s")
+	_, err = s3Fs.storageOptions()
+	assert.Error(t, err)
+
+	// the timeout bounds the connection & response headers, not the body
+	client := storageHTTPClient(90 * time.Second)
+	assert.Zero(t, client.Timeout)
+	if transport, ok := client.Transport.(*http.Transport); assert.True(t, ok) {
+		assert.Equal(t, 90*time.Second, transport.ResponseHeaderTimeout)
+		assert.Equal(t, 90*time.Second, transport.TLSHandshakeTimeout)
+	}
+}
+
+// flakyReader fails once after reading failAt bytes
+type flakyReader struct {
+	io.Reader
+	read, failAt int
+	failed       *bool
+}
+
+func (fr *flakyReader) Read(p []byte) (n int, err error) {
+	if !*fr.failed && fr.read >= fr.failAt {
+		*fr.failed = true
+		return 0, fmt.Errorf("connection reset by peer")
+	}
+	if len(p) > 3 {
+		p = p[:3] // small reads
+	}
+	n, err = fr.Reader.Read(p)
+	fr.read += n
+	return
+}
+
+func TestFileSysRangeRetryReader(t *testing.T) {
+	content := "0123456789abcdefghijklmnopqrstuvwxyz"
+	failed := false
+	offsets := []int64{}
+
+	open := func(offset int64) (io.ReadCloser, error) {
+		offsets = append(offsets, offset)
+		return io.NopCloser(&flakyReader{Reader: strings.NewReader(content[offset:]), failAt: 10, failed: &failed}), nil
+	}
+
+	reader, err := newRangeRetryReader(context.Background(), open, 2)
+	if !assert.NoError(t, err) {
+		return
+	}
+	reader.backoff = time.Millisecond
+
+	data, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(data))
+	assert.Equal(t, []int64{0, 12}, offsets) // re-opened from the offset read
+	assert.Equal(t, 1, reader.retries)
+
+	// without retries, the transient error fails the read
+	failed = false
+	reader, err = newRangeRetryReader(context.Background(), open, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = io.ReadAll(reader)
+	assert.ErrorContains(t, err, "connection reset")
+}
@@ -5,10 +5,12 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/flarco/g"
 	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/env"
 	"github.com/spf13/cast"
 )
 
@@ -30,6 +32,7 @@ type Dataflow struct {
 	readyChn        chan struct{}
 	StreamMap       map[string]*Datastream
 	closed          bool
+	stopped         atomic.Bool // whether to stop reading from sources
 	mux             sync.Mutex
 	SchemaVersion   int // for column type version
 }
@@ -153,6 +156,17 @@ func (df *Dataflow) Close() {
 	df.closed = true
 }
 
+// StopReading stops pulling rows from the sources. The streams end
+// gracefully, so the rows already read (including buffers) are still pushed downstream
+func (df *Dataflow) StopReading() {
+	df.stopped.Store(true)
+}
+
+// IsStopped returns true if the dataflow stopped reading from sources
+func (df *Dataflow) IsStopped() bool {
+	return df.stopped.Load()
+}
+
 // BufferDataset return the buffer as a dataset
 func (df *Dataflow) BufferDataset() Dataset {
 	data := NewDataset(df.Columns)
@@ -490,6 +504,9 @@ func (df *Dataflow) SyncStats() {
 			if colStats.MaxDecLen > dfCols[i].Stats.MaxDecLen {
 				dfCols[i].Stats.MaxDecLen = colStats.MaxDecLen
 			}
+			if colStats.MaxIntLen > dfCols[i].Stats.MaxIntLen {
+				dfCols[i].Stats.MaxIntLen = colStats.MaxIntLen
+			}
 
 			if col.Constraint != nil {
 				dfCols[i].Constraint.FailCnt = dfCols[i].Constraint.FailCnt + col.Constraint.FailCnt
@@ -518,6 +535,26 @@ func (df *Dataflow) Count() (cnt uint64) {
 	return
 }
 
+// NormalizedStreams returns the streams of the child rows split from the JSON
+// records of the streams (option `normalize`), merged by array path and column name.
+// The dataflow must be done reading.
+func (df *Dataflow) NormalizedStreams() (streams map[string]*Datastream, err error) {
+	if df == nil {
+		return map[string]*Datastream{}, nil
+	}
+	return normalizedStreams(df.Context.Ctx, df.Streams)
+}
+
+// FilteredCount returns the aggregate count of rows dropped by the pipeline filters
+func (df *Dataflow) FilteredCount() (cnt uint64) {
+	if df != nil {
+		for _, ds := range df.Streams {
+			cnt += ds.FilteredCount()
+		}
+	}
+	return
+}
+
 // AddEgressBytes add egress bytes
 func (df *Dataflow) AddEgressBytes(bytes uint64) {
 	df.EgressBytes = df.EgressBytes + bytes
@@ -582,7 +619,7 @@ func (df *Dataflow) PushStreamChan(dsCh chan *Datastream) {
 				ok := df.MergeColumns(ds.Columns, false)
 				if !ok {
 					// Could not run MergeColumns process
-					g.Warn("could not merge columns...")
+					env.Warn(env.WarningSchema, "could not merge columns...")
 				}
 
 				// add new columns two-way if not exist
@@ -782,3 +819,212 @@ func MergeDataflow(df *Dataflow) (dsN *Datastream) {
 
 	return dsN
 }
+
+// TeeDataflow merges the dataflow streams, and splits the rows into n started
+// datastreams, each receiving every row. This allows reading a source once
+// for several targets. The branches are pushed in lockstep: a slow branch
+// slows down the others. Canceling the context of a branch (on failure)
+// detaches it, so that the other branches continue.
+func TeeDataflow(df *Dataflow, n int) (branches []*Datastream, err error) {
+	dsN := MergeDataflow(df)
+	if err = dsN.Err(); err != nil {
+		return nil, g.Error(err, "could not merge dataflow")
+	}
+
+	rowChans := make([]chan []any, n)
+	for i := 0; i < n; i++ {
+		rows := MakeRowsChan()
+		nextFunc := func(it *Iterator) bool {
+			for it.Row = range rows {
+				return true
+			}
+			return false
+		}
+
+		ds := NewDatastreamIt(df.Context.Ctx, dsN.Columns.Clone(), nextFunc)
+		ds.it.IsCasted = true
+		ds.Inferred = true
+		ds.Sp.Config = dsN.Sp.Config
+
+		rowChans[i] = rows
+		branches = append(branches, ds)
+	}
+
+	go func() {
+		defer func() {
+			for _, rows := range rowChans {
+				close(rows)
+			}
+		}()
+
+		for row := range dsN.Rows() {
+			for i, rows := range rowChans {
+				rowCopy := make([]any, len(row))
+				copy(rowCopy, row)
+				select {
+				case rows <- rowCopy:
+				case <-branches[i].Context.Ctx.Done():
+				}
+			}
+		}
+	}()
+
+	// start concurrently, since each branch reads a sample on start
+	errs := make([]error, n)
+	wg := sync.WaitGroup{}
+	for i, ds := range branches {
+		wg.Add(1)
+		go func(i int, ds *Datastream) {
+			defer wg.Done()
+			errs[i] = ds.Start()
+		}(i, ds)
+	}
+	wg.Wait()
+
+	eG := g.ErrorGroup{}
+	for _, err := range errs {
+		eG.Capture(err)
+	}
+	if err = eG.Err(); err != nil {
+		return nil, g.Error(err, "could not start tee datastreams")
+	}
+
+	return branches, nil
+}
+
+// UnionDataflow merges the streams of several dataflows into one datastream,
+// with the union of their columns (matched by name). Columns missing from a
+// dataflow are filled with nulls. If sourceCol is provided, a string column
+// is added with the label of the dataflow each row comes from. The dataflows
+// are read one after the other, or concurrently.
+func UnionDataflow(dfs []*Dataflow, labels []string, sourceCol string, concurrent bool) (dsN *Datastream, err error) {
+	if len(dfs) == 0 {
+		return nil, g.Error("Provided 0 dataflows to union")
+	} else if len(labels) != len(dfs) {
+		return nil, g.Error("expected %d labels, got %d", len(dfs), len(labels))
+	}
+
+	columns := UnionColumns(lo.Map(dfs, func(df *Dataflow, i int) Columns { return df.Columns })...)
+	sourceIndex := -1
+	if sourceCol != "" {
+		if columns.GetColumn(sourceCol) != nil {
+			return nil, g.Error("source column %s already exists", sourceCol)
+		}
+		columns = append(columns, Column{Name: sourceCol, Type: StringType, Position: len(columns) + 1})
+		sourceIndex = len(columns) - 1
+	}
+
+	rows := MakeRowsChan()
+	nextFunc := func(it *Iterator) bool {
+		for it.Row = range rows {
+			return true
+		}
+		return false
+	}
+	dsN = NewDatastreamIt(dfs[0].Context.Ctx, columns, nextFunc)
+	dsN.Inferred = true
+	dsN.Sp.Config = dfs[0].StreamConfig()
+
+	fieldMap := columns.FieldMap(true)
+	pushRows := func(df *Dataflow, label string) {
+		for ds := range df.StreamCh {
+			for batch := range ds.BatchChan {
+				// batch columns can differ between batches (added columns)
+				colMap := map[int]int{}
+				for i, col := range batch.Columns {
+					if j, ok := fieldMap[strings.ToLower(col.Name)]; ok {
+						colMap[i] = j
+					}
+				}
+
+				for row := range batch.Rows {
+					newRow := make([]any, len(columns))
+					for i, j := range colMap {
+						if i < len(row) {
+							newRow[j] = row[i]
+						}
+					}
+					if sourceIndex > -1 {
+						newRow[sourceIndex] = label
+					}
+
+					select {
+					case <-dsN.Context.Ctx.Done():
+						return
+					case rows <- newRow:
+					}
+				}
+			}
+			ds.Buffer = nil // clear buffer
+		}
+
+		if err := df.Err(); err != nil {
+			dsN.Context.CaptureErr(g.Error(err, "could not read %s", label))
+		}
+	}
+
+	go func() {
+		defer close(rows)
+
+		if !concurrent {
+			for i, df := range dfs {
+				pushRows(df, labels[i])
+			}
+			return
+		}
+
+		wg := sync.WaitGroup{}
+		for i, df := range dfs {
+			wg.Add(1)
+			go func(df *Dataflow, label string) {
+				defer wg.Done()
+				pushRows(df, label)
+			}(df, labels[i])
+		}
+		wg.Wait()
+	}()
+
+	if err = dsN.Start(); err != nil {
+		return nil, g.Error(err, "could not start union datastream")
+	}
+
+	return dsN, nil
+}
+
+// UnionColumns returns the union of the columns (matched by name). When the
+// types of a column differ, the widest type is used.
+func UnionColumns(colsList ...Columns) (columns Columns) {
+	columns = Columns{}
+	for _, cols := range colsList {
+		fieldMap := columns.FieldMap(true)
+		for _, col := range cols {
+			if i, ok := fieldMap[strings.ToLower(col.Name)]; ok {
+				columns[i].Type = unionColumnType(columns[i].Type, col.Type)
+				continue
+			}
+			col.Position = len(columns) + 1
+			columns = append(columns, col)
+		}
+	}
+	return columns
+}
+
+// unionColumnType returns a type which can hold the values of both types
+func unionColumnType(t1, t2 ColumnType) ColumnType {
+	switch {
+	case t1 == t2:
+		return t1
+	case t1.IsInteger() && t2.IsInteger():
+		return BigIntType
+	case t1.IsNumber() && t2.IsNumber():
+		return DecimalType
+	case (t1.IsDate() || t1.IsDatetime()) && (t2.IsDate() || t2.IsDatetime()):
+		if t1 == TimestampzType || t2 == TimestampzType {
+			return TimestampzType
+		}
+		return TimestampType
+	case t1 == TextType || t2 == TextType:
+		return TextType
+	}
+	return StringType
+}
@@ -439,7 +439,12 @@ func (data *Dataset) InferColumnTypes() {
 		}
 
 		for j, val := range row {
-			val = data.Sp.ParseString(strings.TrimSpace(cast.ToString(val)), j)
+			rawStr := strings.TrimSpace(cast.ToString(val))
+			if b, ok := data.Sp.Config.BoolValues.Parse(columns[j].Name, rawStr); ok {
+				val = b
+			} else {
+				val = data.Sp.ParseString(rawStr, j)
+			}
 			columns[j].Stats.TotalCnt++
 
 			valStr := cast.ToString(val)
@@ -469,6 +474,9 @@ func (data *Dataset) InferColumnTypes() {
 				columns[j].Stats.NullCnt++
 			case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
 				columns[j].Stats.IntCnt++
+				if intLen, _ := numericDigits(rawStr); intLen > columns[j].Stats.MaxIntLen {
+					columns[j].Stats.MaxIntLen = intLen
+				}
 				val0 := cast.ToInt64(val)
 				if val0 > columns[j].Stats.Max {
 					columns[j].Stats.Max = val0
@@ -493,6 +501,15 @@ func (data *Dataset) InferColumnTypes() {
 					}
 				}
 
+				// digits of the raw value, since floats can lose some
+				intLen, decLen := numericDigits(rawStr)
+				if intLen > columns[j].Stats.MaxIntLen {
+					columns[j].Stats.MaxIntLen = intLen
+				}
+				if decLen > columns[j].Stats.MaxDecLen {
+					columns[j].Stats.MaxDecLen = decLen
+				}
+
 			case bool:
 				columns[j].Stats.BoolCnt++
 			case string, []uint8:
@@ -515,6 +532,11 @@ func (data *Dataset) InferColumnTypes() {
 	// overwrite if found in config.columns
 	data.Columns = data.Columns.Coerce(data.Sp.Config.Columns, data.Sp.Config.Header)
 
+	// fit, override or widen the precision of decimals
+	if err := data.Columns.ApplyDecimalPrecision(data.Sp.Config); err != nil {
+		g.Warn(err.Error())
+	}
+
 	data.Inferred = true
 }
 
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	arrowCompress "github.com/apache/arrow/go/v16/parquet/compress"
+	"github.com/dustin/go-humanize"
 	"github.com/flarco/g"
 	"github.com/flarco/g/csv"
 	"github.com/flarco/g/json"
@@ -65,6 +66,18 @@ type Datastream struct {
 	paused        bool
 	pauseChan     chan struct{}
 	unpauseChan   chan struct{}
+	bufferBytes   uint64         // estimated memory of Buffer
+	spill         *SpillFile     // buffered rows beyond the max memory
+	sampler       *sampler       // seekable source sampled for inference
+	sampleRows    [][]any        // rows sampled across the file, for inference
+	rowHasher     *rowHasher     // sets the row hash column
+	keyHasher     *rowHasher     // sets the surrogate key column
+	jsonExtractor *jsonExtractor // sets the columns extracted from JSON columns
+	pipeline      *pipeline      // applies the pipeline steps
+	normalizer    *normalizer    // splits the arrays of JSON records into child datasets
+
+	transformPlugin        TransformPlugin // transforms the rows (--transform-plugin)
+	transformPluginFailCnt int
 }
 
 type schemaChg struct {
@@ -75,14 +88,21 @@ type schemaChg struct {
 }
 
 type FileStreamConfig struct {
-	Limit            int               `json:"limit"`
-	Select           []string          `json:"select"`
-	SQL              string            `json:"sql"`
-	Format           dbio.FileType     `json:"format"`
-	IncrementalKey   string            `json:"incremental_key"`
-	IncrementalValue string            `json:"incremental_value"`
-	FileSelect       *[]string         `json:"file_select"` // a list of files to include.
-	Props            map[string]string `json:"props"`
+	Limit               int               `json:"limit"`
+	Select              []string          `json:"select"`
+	SQL                 string            `json:"sql"`
+	Format              dbio.FileType     `json:"format"`
+	IncrementalKey      string            `json:"incremental_key"`
+	IncrementalValue    string            `json:"incremental_value"`
+	FileSelect          *[]string         `json:"file_select"`           // a list of files to include.
+	PartitionFilter     string            `json:"partition_filter"`      // a filter on Hive-style partition values
+	Partitions          []KeyValue        `json:"partitions"`            // partition values of the file, added as columns
+	Where               string            `json:"where"`                 // a filter on partition values (prunes files) and parquet columns (prunes row groups)
+	Filters             []FilterCondition `json:"filters"`               // the conditions of Where on file columns
+	UseS3Select         bool              `json:"use_s3_select"`         // push Select and Filters to S3 Select (csv & jsonlines)
+	Manifest            string            `json:"manifest"`              // a file listing the objects to read, instead of listing the url
+	ManifestSkipMissing bool              `json:"manifest_skip_missing"` // skip the objects of the manifest not found, instead of erroring
+	Props               map[string]string `json:"props"`
 }
 
 func (sc *FileStreamConfig) ShouldUseDuckDB() bool {
@@ -100,6 +120,12 @@ type Metadata struct {
 	RowNum    KeyValue `json:"row_num"`
 	RowID     KeyValue `json:"row_id"`
 	ExecID    KeyValue `json:"exec_id"`
+	RowHash   KeyValue `json:"row_hash"` // value is the RowHashOptions
+
+	// the hash of the natural key columns, value is the RowHashOptions
+	SurrogateKey KeyValue `json:"surrogate_key"`
+
+	Partitions []KeyValue `json:"partitions,omitempty"` // Hive-style partition values
 }
 
 // AsMap return as map
@@ -413,7 +439,7 @@ func (ds *Datastream) Close() {
 		}
 
 		if ds.Sp.unrecognizedDate != "" {
-			g.Warn("unrecognized date format (%s)", ds.Sp.unrecognizedDate)
+			env.Warn(env.WarningTypes, "unrecognized date format (%s)", ds.Sp.unrecognizedDate)
 		}
 
 		ds.Buffer = nil // clear buffer
@@ -658,6 +684,34 @@ func (ds *Datastream) Collect(limit int) (Dataset, error) {
 	return data, nil
 }
 
+// bufferRow adds a row to the sample buffer. Once the buffer exceeds
+// the max memory, the following rows are spilled to disk.
+func (ds *Datastream) bufferRow(row []any) (err error) {
+	if ds.spill != nil {
+		return ds.spill.Write(row)
+	}
+
+	ds.Buffer = append(ds.Buffer, row)
+
+	limit := MaxMemory()
+	if limit == 0 {
+		return nil
+	}
+
+	ds.bufferBytes += RowBytes(row)
+	if ds.bufferBytes > limit {
+		ds.spill, err = NewSpillFile(ds.ID)
+		if err != nil {
+			return g.Error(err, "could not spill buffer to disk")
+		}
+		spill := ds.spill
+		ds.Defer(func() { spill.Close() })
+		g.Warn("max memory (%s) reached while buffering rows, spilling to %s", humanize.Bytes(limit), SpillFolder())
+	}
+
+	return nil
+}
+
 // Err return the error if any
 func (ds *Datastream) Err() (err error) {
 	return ds.Context.Err()
@@ -706,7 +760,10 @@ loop:
 			}
 
 			row := ds.Sp.ProcessRow(ds.it.Row)
-			ds.Buffer = append(ds.Buffer, row)
+			if err = ds.bufferRow(row); err != nil {
+				ds.Context.CaptureErr(err)
+				return
+			}
 			if ds.it.Counter >= cast.ToUint64(SampleSize) {
 				break loop
 			}
@@ -715,10 +772,18 @@ loop:
 
 skipBuffer:
 
+	if ds.spill != nil {
+		g.Debug("spilled %d rows (%s) to disk while buffering, types are inferred from the %d rows in memory", ds.spill.Rows, humanize.Bytes(ds.spill.Bytes), len(ds.Buffer))
+	}
+
 	// infer types
 	if !ds.Inferred && len(ds.Buffer) > 0 {
 		sampleData := NewDataset(ds.Columns)
 		sampleData.Rows = ds.Buffer
+		if len(ds.sampleRows) > 0 {
+			sampleData.Rows = append(append([][]any{}, ds.Buffer...), ds.sampleRows...)
+			ds.sampleRows = nil
+		}
 		sampleData.NoDebug = ds.NoDebug
 		sampleData.SafeInference = ds.SafeInference
 		sampleData.Sp.dateLayouts = ds.Sp.dateLayouts
@@ -738,6 +803,20 @@ skipBuffer:
 		return
 	}
 
+	// extracted JSON fields, computed on the casted row (see loop below)
+	if len(ds.config.JSONExtracts) > 0 {
+		if ds.jsonExtractor, err = ds.newJSONExtractor(ds.config.JSONExtracts); err != nil {
+			return g.Error(err, "could not add JSON extract columns")
+		}
+	}
+
+	// pipeline steps, applied on the casted row after the JSON extracts
+	if len(ds.config.Pipeline) > 0 {
+		if ds.pipeline, err = ds.newPipeline(ds.config.Pipeline); err != nil {
+			return g.Error(err, "could not prepare pipeline")
+		}
+	}
+
 	// add metadata
 	metaValuesMap := map[int]func(it *Iterator) any{}
 	{
@@ -825,6 +904,25 @@ skipBuffer:
 			}
 		}
 
+		// partition values, if not already in the data.
+		// these are data columns (included in row hashes), not sling metadata
+		for _, partition := range ds.Metadata.Partitions {
+			if ds.Columns.GetColumn(partition.Key) != nil {
+				continue
+			}
+			value := partition.Value
+			col := Column{
+				Name:        partition.Key,
+				Type:        StringType,
+				Position:    len(ds.Columns) + 1,
+				Description: "Sling.Metadata.Partition",
+			}
+			ds.Columns = append(ds.Columns, col)
+			metaValuesMap[col.Position-1] = func(it *Iterator) any {
+				return value
+			}
+		}
+
 		if ds.Metadata.ExecID.Key != "" {
 			ds.Metadata.ExecID.Key = ensureName(ds.Metadata.ExecID.Key)
 			col := Column{
@@ -839,6 +937,31 @@ skipBuffer:
 				return ds.Metadata.ExecID.Value
 			}
 		}
+
+		// surrogate key & row hash, computed on the casted row (see loop below)
+		if ds.Metadata.SurrogateKey.Key != "" {
+			ds.Metadata.SurrogateKey.Key = ensureName(ds.Metadata.SurrogateKey.Key)
+			if ds.keyHasher, err = ds.newRowHasher(ds.Metadata.SurrogateKey, "surrogate_key"); err != nil {
+				return g.Error(err, "could not add surrogate key column")
+			}
+		}
+		if ds.Metadata.RowHash.Key != "" {
+			ds.Metadata.RowHash.Key = ensureName(ds.Metadata.RowHash.Key)
+			if ds.rowHasher, err = ds.newRowHasher(ds.Metadata.RowHash, "row_hash"); err != nil {
+				return g.Error(err, "could not add row hash column")
+			}
+		}
+	}
+
+	if path := ds.config.TransformPlugin; path != "" {
+		if ds.transformPlugin, err = LoadTransformPlugin(path); err != nil {
+			return g.Error(err, "could not load transform plugin")
+		}
+		ds.Defer(func() {
+			if err := ds.transformPlugin.Close(); err != nil {
+				g.Warn(err.Error())
+			}
+		})
 	}
 
 	// setMetaValues sets mata column values
@@ -906,6 +1029,28 @@ skipBuffer:
 				} else {
 					row = ds.Sp.CastRow(ds.it.Row, ds.Columns)
 				}
+				if ds.transformPlugin != nil {
+					if row, err = ds.applyTransformPlugin(row); err != nil {
+						ds.Context.CaptureErr(err)
+						break loop
+					} else if row == nil {
+						goto loop // dropped
+					}
+				}
+				if ds.jsonExtractor != nil {
+					row = ds.jsonExtractor.Set(row)
+				}
+				if ds.pipeline != nil {
+					if row = ds.pipeline.Apply(row); row == nil {
+						goto loop // filtered
+					}
+				}
+				if ds.keyHasher != nil {
+					row = ds.keyHasher.Set(row)
+				}
+				if ds.rowHasher != nil {
+					row = ds.rowHasher.Set(row)
+				}
 				if ds.config.SkipBlankLines && ds.Sp.rowBlankValCnt == len(row) {
 					goto loop
 				}
@@ -1030,8 +1175,16 @@ func (ds *Datastream) ConsumeJsonReader(reader io.Reader) (err error) {
 		reader2 = newReader
 	}
 
-	decoder := json.NewDecoder(reader2)
+	// the elements of a top-level array are streamed, to bound the memory
+	bufReader := bufio.NewReader(reader2)
+	decoder := json.NewDecoder(bufReader)
 	js := NewJSONStream(ds, decoder, ds.Sp.Config.Flatten, ds.Sp.Config.Jmespath)
+	js.streamArray = js.jmespath == "" && peekJSONArray(bufReader)
+
+	// sample records across the file, for inference
+	if ds.sampler != nil && js.flatten && js.jmespath == "" && ds.normalizer == nil {
+		js.seedSample()
+	}
 	ds.it = ds.NewIterator(ds.Columns, js.NextFunc)
 
 	err = ds.Start()
@@ -1089,13 +1242,15 @@ func (ds *Datastream) ConsumeJsonReaderChl(readerChn chan *ReaderReady, isXML bo
 			reader2 = newReader
 		}
 
-		var decoder decoderLike
 		if isXML {
-			decoder = xml.NewDecoder(reader2)
-		} else {
-			decoder = json.NewDecoder(reader2)
+			decoder := xml.NewDecoder(reader2)
+			return NewJSONStream(ds, decoder, ds.Sp.Config.Flatten, ds.Sp.Config.Jmespath), nil
 		}
-		jsNew := NewJSONStream(ds, decoder, ds.Sp.Config.Flatten, ds.Sp.Config.Jmespath)
+
+		// the elements of a top-level array are streamed, to bound the memory
+		bufReader := bufio.NewReader(reader2)
+		jsNew := NewJSONStream(ds, json.NewDecoder(bufReader), ds.Sp.Config.Flatten, ds.Sp.Config.Jmespath)
+		jsNew.streamArray = jsNew.jmespath == "" && peekJSONArray(bufReader)
 		return jsNew, nil
 	}
 
@@ -1394,6 +1549,11 @@ func (ds *Datastream) ConsumeCsvReader(reader io.Reader) (err error) {
 		ds.SetFields(CleanHeaderRow(row0))
 	}
 
+	// sample rows across the file, for inference
+	if ds.sampler != nil && !ds.Inferred {
+		ds.sampleCsv(c.Delimiter)
+	}
+
 	nextFunc := func(it *Iterator) bool {
 
 		row, err := r.Read()
@@ -1439,12 +1599,13 @@ func (ds *Datastream) ConsumeCsvReader(reader io.Reader) (err error) {
 	return
 }
 
-// ConsumeParquetReader uses the provided reader to stream rows
-func (ds *Datastream) ConsumeParquetReaderSeeker(reader *os.File) (err error) {
+// ConsumeParquetReader uses the provided reader to stream rows.
+// Row groups which cannot match the filters are skipped.
+func (ds *Datastream) ConsumeParquetReaderSeeker(reader *os.File, filters ...FilterCondition) (err error) {
 	selected := ds.Columns.Names()
 
 	// p, err := NewParquetStream(reader, Columns{}) // old version
-	p, err := NewParquetArrowReader(reader, selected)
+	p, err := NewParquetArrowReader(reader, selected, filters...)
 	if err != nil {
 		return g.Error(err, "could create parquet stream")
 	}
@@ -1463,7 +1624,7 @@ func (ds *Datastream) ConsumeParquetReaderSeeker(reader *os.File) (err error) {
 }
 
 // ConsumeParquetReader uses the provided reader to stream rows
-func (ds *Datastream) ConsumeParquetReader(reader io.Reader) (err error) {
+func (ds *Datastream) ConsumeParquetReader(reader io.Reader, filters ...FilterCondition) (err error) {
 	// need to write to temp file prior
 	parquetPath := path.Join(env.GetTempFolder(), g.NewTsID("parquet.temp")+".parquet")
 	ds.Defer(func() { env.RemoveLocalTempFile(parquetPath) })
@@ -1485,7 +1646,7 @@ func (ds *Datastream) ConsumeParquetReader(reader io.Reader) (err error) {
 		return g.Error(err, "Unable to seek to beginning of temp file: "+parquetPath)
 	}
 
-	return ds.ConsumeParquetReaderSeeker(file)
+	return ds.ConsumeParquetReaderSeeker(file, filters...)
 }
 
 // ConsumeParquetReader uses the provided reader to stream rows
@@ -1616,6 +1777,53 @@ func (ds *Datastream) ConsumeAvroReader(reader io.Reader) (err error) {
 	return ds.ConsumeAvroReaderSeeker(file)
 }
 
+// ConsumeOrcReaderSeeker uses the provided reader to stream rows
+func (ds *Datastream) ConsumeOrcReaderSeeker(reader io.ReadSeeker) (err error) {
+	o, err := NewORCStream(reader, Columns{})
+	if err != nil {
+		return g.Error(err, "could create orc stream")
+	}
+	ds.Defer(func() { o.Close() })
+
+	ds.Columns = o.cols
+	ds.Inferred = true
+	ds.it = ds.NewIterator(ds.Columns, o.nextFunc)
+	ds.SetFileURI()
+
+	err = ds.Start()
+	if err != nil {
+		return g.Error(err, "could start datastream")
+	}
+
+	return
+}
+
+// ConsumeOrcReader uses the provided reader to stream rows
+func (ds *Datastream) ConsumeOrcReader(reader io.Reader) (err error) {
+	// need to write to temp file prior, since orc is read from the footer
+	orcPath := path.Join(env.GetTempFolder(), g.NewTsID("orc.temp")+".orc")
+	ds.Defer(func() { env.RemoveLocalTempFile(orcPath) })
+
+	file, err := os.Create(orcPath)
+	if err != nil {
+		return g.Error(err, "Unable to create temp file: "+orcPath)
+	}
+
+	g.Debug("downloading to temp file on disk: %s", orcPath)
+	bw, err := io.Copy(file, reader)
+	if err != nil {
+		return g.Error(err, "Unable to write to temp file: "+orcPath)
+	}
+	g.Debug("wrote %d bytes to %s", bw, orcPath)
+
+	_, err = file.Seek(0, 0) // reset to beginning
+	if err != nil {
+		return g.Error(err, "Unable to seek to beginning of temp file: "+orcPath)
+	}
+
+	return ds.ConsumeOrcReaderSeeker(file)
+}
+
 // ConsumeSASReaderSeeker uses the provided reader to stream rows
 func (ds *Datastream) ConsumeSASReaderSeeker(reader io.ReadSeeker) (err error) {
 	s, err := NewSASStream(reader, Columns{})
@@ -1662,6 +1870,40 @@ func (ds *Datastream) ConsumeSASReader(reader io.Reader) (err error) {
 	return ds.ConsumeSASReaderSeeker(file)
 }
 
+// ConsumeFixedReader uses the provided reader to stream fixed-width rows
+func (ds *Datastream) ConsumeFixedReader(reader io.Reader) (err error) {
+	// decompress if needed
+	readerDecompr, err := AutoDecompress(reader)
+	if err != nil {
+		err = g.Error(err, "could not AutoDecompress")
+		ds.Context.CaptureErr(err)
+		return err
+	}
+
+	// decode File if requested by transform
+	reader = readerDecompr
+	if newReader, ok := ds.transformReader(readerDecompr); ok {
+		reader = newReader
+	}
+
+	f, err := newFixedReaderFromConfig(reader, ds.Sp.Config.Map)
+	if err != nil {
+		return g.Error(err, "could not create fixed-width reader")
+	}
+
+	ds.Columns = f.Layout.Columns()
+	ds.Inferred = ds.Columns.Sourced()
+	ds.it = ds.NewIterator(ds.Columns, f.nextFunc)
+	ds.SetFileURI()
+
+	err = ds.Start()
+	if err != nil {
+		return g.Error(err, "could start datastream")
+	}
+
+	return
+}
+
 // ConsumeSASReaderSeeker uses the provided reader to stream rows
 func (ds *Datastream) ConsumeExcelReaderSeeker(reader io.ReadSeeker, props map[string]string) (err error) {
 	data, err := NewExcelDataset(reader, props)
@@ -2161,7 +2403,7 @@ func (ds *Datastream) NewJsonReaderChnl(sc StreamConfig) (readerChn chan *io.Pip
 
 				rec := g.M()
 				for i, val := range row0 {
-					rec[fields[i]] = val
+					rec[fields[i]] = jsonRecValue(batch.Columns[i], val)
 				}
 
 				b, err := json.Marshal(rec)
@@ -2211,6 +2453,24 @@ func (ds *Datastream) NewJsonReaderChnl(sc StreamConfig) (readerChn chan *io.Pip
 	return readerChn
 }
 
+// jsonRecValue returns the value to marshal in a JSON record. Values of JSON
+// columns are embedded as raw JSON, instead of escaped strings.
+func jsonRecValue(col Column, val any) any {
+	if col.Type.IsJSON() {
+		switch v := val.(type) {
+		case string:
+			if json.Valid([]byte(v)) {
+				return json.RawMessage(v)
+			}
+		case []byte:
+			if json.Valid(v) {
+				return json.RawMessage(v)
+			}
+		}
+	}
+	return val
+}
+
 // NewJsonLinesReaderChnl provides a channel of readers as the limit is reached
 // each channel flows as fast as the consumer consumes
 func (ds *Datastream) NewJsonLinesReaderChnl(sc StreamConfig) (readerChn chan *io.PipeReader) {
@@ -2234,7 +2494,7 @@ func (ds *Datastream) NewJsonLinesReaderChnl(sc StreamConfig) (readerChn chan *i
 
 				rec := g.M()
 				for i, val := range row0 {
-					rec[fields[i]] = val
+					rec[fields[i]] = jsonRecValue(batch.Columns[i], val)
 				}
 
 				b, err := json.Marshal(rec)
@@ -2497,6 +2757,84 @@ func (ds *Datastream) NewParquetReaderChnl(sc StreamConfig) (readerChn chan *Bat
 	return readerChn
 }
 
+// NewOrcReaderChnl provides a channel of readers as the limit is reached.
+// Each reader is an orc file, written in stripes.
+func (ds *Datastream) NewOrcReaderChnl(sc StreamConfig) (readerChn chan *BatchReader) {
+	readerChn = make(chan *BatchReader, 100)
+
+	pipeR, pipeW := io.Pipe()
+
+	go func() {
+		var ow *ORCWriter
+		var br *BatchReader
+		var err error
+
+		defer close(readerChn)
+
+		closeWriter := func() {
+			if ow != nil {
+				if err := ow.Close(); err != nil {
+					ds.Context.CaptureErr(g.Error(err, "could not close orc writer"))
+				}
+			}
+			pipeW.Close()
+		}
+
+		nextPipe := func(batch *Batch) error {
+			closeWriter()
+
+			// new reader
+			pipeR, pipeW = io.Pipe()
+
+			br = &BatchReader{batch, batch.Columns, pipeR, 0}
+			readerChn <- br
+
+			ow, err = NewORCWriter(pipeW, batch.Columns, sc.Compression)
+			if err != nil {
+				return g.Error(err, "could not create orc writer")
+			}
+
+			return nil
+		}
+
+		for batch := range ds.BatchChan {
+			if batch.ColumnsChanged() || batch.IsFirst() {
+				err := nextPipe(batch)
+				if err != nil {
+					ds.Context.CaptureErr(err)
+					pipeW.CloseWithError(err)
+					return
+				}
+			}
+
+			for row := range batch.Rows {
+				err := ow.WriteRow(row)
+				if err != nil {
+					ds.Context.CaptureErr(g.Error(err, "error writing row"))
+					ds.Context.Cancel()
+					pipeW.Close()
+					return
+				}
+
+				br.Counter++
+
+				if sc.FileMaxRows > 0 && br.Counter >= sc.FileMaxRows {
+					err = nextPipe(batch)
+					if err != nil {
+						ds.Context.CaptureErr(err)
+						pipeW.CloseWithError(err)
+						return
+					}
+				}
+			}
+		}
+
+		closeWriter()
+	}()
+
+	return readerChn
+}
+
 // NewCsvReader creates a Reader with limit. If limit == 0, then read all rows.
 func (ds *Datastream) NewCsvReader(sc StreamConfig) *io.PipeReader {
 	pipeR, pipeW := io.Pipe()
@@ -2724,6 +3062,29 @@ func (it *Iterator) next() bool {
 			return true
 		}
 
+		// process spilled rows, after the ds Buffer
+		if it.dsBufferI > -1 && it.ds.spill != nil {
+			row, err := it.ds.spill.Read()
+			if err == nil {
+				it.Row = row
+				if i := it.dsBufferI + it.ds.spill.read - 1; i < len(it.dsBufferStream) && it.dsBufferStream[i] != "" {
+					it.ds.Metadata.StreamURL.Value = it.dsBufferStream[i]
+				}
+				it.incrementStreamRowNum()
+				return true
+			} else if err != io.EOF {
+				it.Context.CaptureErr(err)
+				return false
+			}
+			it.ds.spill.Close() // all read, remove file
+			it.ds.spill = nil
+		}
+
+		// stop reading from source, buffer is flushed above
+		if it.ds.df != nil && it.ds.df.IsStopped() {
+			return false
+		}
+
 	processNext:
 		next := it.nextFunc(it)
 		if next {
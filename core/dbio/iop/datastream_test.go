@@ -2,10 +2,18 @@ package iop
 
 import (
 	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/flarco/g"
 	"github.com/flarco/g/csv"
+	"github.com/slingdata-io/sling-cli/core/env"
 	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestBW(t *testing.T) {
@@ -63,3 +71,463 @@ func TestBW(t *testing.T) {
 		})
 	}
 }
+
+func TestJsonReaderChnl(t *testing.T) {
+	columns := Columns{
+		{Name: "id", Type: BigIntType, Position: 1},
+		{Name: "payload", Type: JsonType, Position: 2},
+	}
+
+	read := func(rows [][]any, asArray bool) string {
+		data := NewDataset(columns)
+		data.Rows = rows
+		data.Inferred = true
+		ds := data.Stream()
+
+		readers := ds.NewJsonLinesReaderChnl(StreamConfig{})
+		if asArray {
+			readers = ds.NewJsonReaderChnl(StreamConfig{})
+		}
+
+		out := ""
+		for reader := range readers {
+			b, err := io.ReadAll(reader)
+			g.AssertNoError(t, err)
+			out = out + string(b)
+		}
+		return out
+	}
+
+	rows := [][]any{
+		{int64(1), `{"a":1,"b":[1,2]}`},
+		{int64(2), nil},
+	}
+
+	// nested json is embedded, not escaped
+	expected := `{"id":1,"payload":{"a":1,"b":[1,2]}}` + "\n" + `{"id":2,"payload":null}` + "\n"
+	assert.Equal(t, expected, read(rows, false), "json lines")
+
+	expected = `[{"id":1,"payload":{"a":1,"b":[1,2]}},{"id":2,"payload":null}]`
+	assert.Equal(t, expected, read(rows, true), "json array")
+
+	// empty streams
+	assert.Empty(t, read(nil, false), "json lines")
+	assert.Equal(t, "[]", read(nil, true), "json array")
+}
+
+func TestTeeDataflow(t *testing.T) {
+	columns := Columns{
+		{Name: "id", Type: BigIntType, Position: 1},
+		{Name: "name", Type: StringType, Position: 2},
+	}
+
+	data := NewDataset(columns)
+	for i := 0; i < 2500; i++ {
+		data.Rows = append(data.Rows, []any{int64(i), cast.ToString(i)})
+	}
+	data.Inferred = true
+
+	df, err := MakeDataFlow(data.Stream())
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	branches, err := TeeDataflow(df, 3)
+	if !g.AssertNoError(t, err) || !assert.Len(t, branches, 3) {
+		return
+	}
+
+	// branches are consumed concurrently
+	datasets := make([]Dataset, len(branches))
+	wg := sync.WaitGroup{}
+	for i, ds := range branches {
+		wg.Add(1)
+		go func(i int, ds *Datastream) {
+			defer wg.Done()
+			datasets[i], _ = ds.Collect(0)
+		}(i, ds)
+	}
+	wg.Wait()
+
+	for i, data := range datasets {
+		if assert.Len(t, data.Rows, 2500, "branch %d", i) {
+			assert.Equal(t, 2499, cast.ToInt(data.Rows[2499][0]), "branch %d", i)
+		}
+	}
+}
+
+func TestUnionDataflow(t *testing.T) {
+	makeDf := func(columns Columns, rows ...[]any) *Dataflow {
+		data := NewDataset(columns)
+		data.Rows = rows
+		data.Inferred = true
+		df, err := MakeDataFlow(data.Stream())
+		g.AssertNoError(t, err)
+		return df
+	}
+
+	for _, concurrent := range []bool{false, true} {
+		df1 := makeDf(
+			Columns{
+				{Name: "id", Type: IntegerType, Position: 1},
+				{Name: "name", Type: StringType, Position: 2},
+			},
+			[]any{int64(1), "a"},
+			[]any{int64(2), "b"},
+		)
+		df2 := makeDf(
+			Columns{
+				{Name: "ID", Type: BigIntType, Position: 1},
+				{Name: "amount", Type: DecimalType, Position: 2},
+			},
+			[]any{int64(3), 1.5},
+		)
+
+		ds, err := UnionDataflow([]*Dataflow{df1, df2}, []string{"first", "second"}, "_source", concurrent)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"id", "name", "amount", "_source"}, ds.Columns.Names())
+		assert.Equal(t, BigIntType, ds.Columns[0].Type)
+
+		data, err := ds.Collect(0)
+		assert.NoError(t, err)
+		if !assert.Len(t, data.Rows, 3) {
+			continue
+		}
+
+		bySource := map[string][][]any{}
+		for _, row := range data.Rows {
+			bySource[cast.ToString(row[3])] = append(bySource[cast.ToString(row[3])], row)
+		}
+		if assert.Len(t, bySource["first"], 2) {
+			assert.Nil(t, bySource["first"][0][2])
+		}
+		if assert.Len(t, bySource["second"], 1) {
+			assert.EqualValues(t, 3, cast.ToInt(bySource["second"][0][0]))
+			assert.Nil(t, bySource["second"][0][1])
+		}
+
+		// sequential reads keep the order of the dataflows
+		if !concurrent {
+			assert.Equal(t, "second", data.Rows[2][3])
+		}
+	}
+
+	_, err := UnionDataflow([]*Dataflow{NewDataflow()}, []string{"a", "b"}, "", false)
+	assert.Error(t, err)
+}
+
+func TestDatastreamSpill(t *testing.T) {
+	homeDir, sampleSize := env.HomeDir, SampleSize
+	env.HomeDir, SampleSize = t.TempDir(), 500
+	os.Setenv("SLING_MAX_MEMORY", "2KB")
+	defer func() {
+		env.HomeDir, SampleSize = homeDir, sampleSize
+		os.Unsetenv("SLING_MAX_MEMORY")
+	}()
+
+	data := NewDataset(NewColumnsFromFields("id", "name", "created_at"))
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 1000; i++ {
+		data.Append([]any{int64(i), g.F("name_%d", i), created.Add(time.Duration(i) * time.Minute)})
+	}
+
+	ds := data.Stream()
+	if !assert.NotNil(t, ds.spill, "expected buffer to spill") {
+		return
+	}
+	assert.Less(t, len(ds.Buffer), 500)
+	assert.Greater(t, ds.spill.Rows, 0)
+	assert.FileExists(t, ds.spill.Path)
+	spillPath := ds.spill.Path
+
+	result, err := ds.Collect(0)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// all rows in order, with types preserved
+	if assert.Len(t, result.Rows, 1000) {
+		for i, row := range result.Rows {
+			assert.EqualValues(t, i, cast.ToInt(row[0]))
+			assert.Equal(t, g.F("name_%d", i), cast.ToString(row[1]))
+		}
+		assert.Equal(t, created.Add(999*time.Minute), cast.ToTime(result.Rows[999][2]).UTC())
+	}
+	assert.NoFileExists(t, spillPath)
+
+	// without a limit, nothing spills
+	os.Unsetenv("SLING_MAX_MEMORY")
+	ds = data.Stream()
+	assert.Nil(t, ds.spill)
+	assert.Len(t, ds.Buffer, 500)
+}
+
+func TestSpillFileCleanup(t *testing.T) {
+	homeDir := env.HomeDir
+	env.HomeDir = t.TempDir()
+	defer func() { env.HomeDir = homeDir }()
+
+	sf, err := NewSpillFile("test")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, sf.Write([]any{1, nil, "a", map[string]any{"k": 1}, struct{}{}}))
+
+	row, err := sf.Read()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []any{1, nil, "a", `{"k":1}`, "{}"}, row)
+	}
+	_, err = sf.Read()
+	assert.Equal(t, io.EOF, err)
+
+	// removed on exit / interrupt
+	assert.FileExists(t, sf.Path)
+	CleanupSpillFiles()
+	assert.NoFileExists(t, sf.Path)
+}
+
+func TestTempDirOverride(t *testing.T) {
+	tmpDir := path.Join(t.TempDir(), "staging")
+	os.Setenv("SLING_TMP_DIR", tmpDir)
+	defer os.Unsetenv("SLING_TMP_DIR")
+	defer env.CleanupTempFolder()
+
+	if !assert.NoError(t, env.InitTempFolder()) {
+		return
+	}
+	runFolder := env.GetTempFolder()
+	assert.True(t, strings.HasPrefix(runFolder, tmpDir+"/"))
+	assert.DirExists(t, runFolder)
+
+	// staging files and spills land in the override dir
+	sqlPath, err := env.WriteTempSQL("select 1", "test")
+	if assert.NoError(t, err) {
+		assert.Equal(t, runFolder, path.Dir(sqlPath))
+	}
+	sf, err := NewSpillFile("test")
+	if assert.NoError(t, err) {
+		assert.Equal(t, runFolder, path.Dir(sf.Path))
+		assert.NoError(t, sf.Write([]any{1}))
+	}
+
+	// removed on exit / interrupt
+	CleanupSpillFiles()
+	env.CleanupTempFolder()
+	assert.NoDirExists(t, runFolder)
+	entries, err := os.ReadDir(tmpDir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// not writable
+	os.Setenv("SLING_TMP_DIR", "/dev/null/staging")
+	assert.Error(t, env.InitTempFolder())
+}
+
+func TestJsonNormalizeKeys(t *testing.T) {
+	assert.Equal(t, "userid", NormalizeKey("userId", NormalizeKeysLowercase))
+	assert.Equal(t, "user_id", NormalizeKey("userId", NormalizeKeysSnakeCase))
+	assert.Equal(t, "user_id", NormalizeKey("UserID", NormalizeKeysSnakeCase))
+	assert.Equal(t, "address__zip_code", NormalizeKey("address__zipCode", NormalizeKeysSnakeCase))
+	assert.Equal(t, "userId", NormalizeKey("userId", ""))
+
+	read := func(normalizeKeys, payload string) Dataset {
+		ds := NewDatastream(nil)
+		ds.SetConfig(map[string]string{"flatten": "true", "normalize_keys": normalizeKeys})
+		err := ds.ConsumeJsonReader(strings.NewReader(payload))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		data, err := ds.Collect(0)
+		assert.NoError(t, err)
+		return data
+	}
+
+	payload := `[
+		{"userId": 1, "Name": "a"},
+		{"userid": 2, "name": "b"},
+		{"USERID": 3, "NAME": "c", "Address": {"zipCode": "123"}}
+	]`
+
+	data := read(NormalizeKeysLowercase, payload)
+	assert.Equal(t, []string{"name", "userid", "address__zipcode"}, data.Columns.Names())
+	if assert.Len(t, data.Rows, 3) {
+		assert.EqualValues(t, 1, data.Rows[0][1])
+		assert.EqualValues(t, 2, data.Rows[1][1])
+		assert.EqualValues(t, "c", data.Rows[2][0])
+		assert.EqualValues(t, "123", data.Rows[2][2])
+	}
+
+	data = read(NormalizeKeysSnakeCase, payload)
+	assert.Equal(t, []string{"name", "user_id", "userid", "address__zip_code"}, data.Columns.Names())
+
+	// without normalization, each casing is a column
+	data = read("", payload)
+	assert.Len(t, data.Columns, 7)
+
+	// keys merged in the same record keep the first non-null value
+	data = read(NormalizeKeysLowercase, `[{"userId": null, "userid": 5}]`)
+	if assert.Len(t, data.Rows, 1) {
+		assert.EqualValues(t, 5, data.Rows[0][0])
+	}
+}
+
+func TestJsonNormalize(t *testing.T) {
+	payload := `[
+		{"id": 1, "name": "a", "labels": ["x", "y"], "items": [{"sku": "s1", "qty": 2, "tags": [{"tag": "new"}]}, {"sku": "s2", "qty": 1}]},
+		{"id": 2, "name": "b", "items": [], "customer": {"addresses": [{"city": "Paris"}]}}
+	]`
+
+	read := func(config NormalizeConfig) (Dataset, map[string]*Dataset) {
+		ds := NewDatastream(nil)
+		ds.SetConfig(map[string]string{"normalize": g.Marshal(config)})
+		err := ds.ConsumeJsonReader(strings.NewReader(payload))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		data, err := ds.Collect(0)
+		assert.NoError(t, err)
+
+		streams, err := ds.NormalizedStreams()
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		children := map[string]*Dataset{}
+		for path, childDs := range streams {
+			childData, err := childDs.Collect(0)
+			assert.NoError(t, err)
+			children[path] = &childData
+		}
+		return data, children
+	}
+
+	data, children := read(NormalizeConfig{Keys: []string{"id"}})
+	assert.Len(t, data.Rows, 2)
+	assert.Nil(t, data.Columns.GetColumn("items"))
+	assert.Nil(t, data.Columns.GetColumn("customer__addresses"))
+	assert.NotNil(t, data.Columns.GetColumn("labels")) // array of values kept as JSON
+
+	if items := children["items"]; assert.NotNil(t, items) {
+		assert.Equal(t, []string{"parent_id", "_index", "qty", "sku", "tags"}, items.GetFields())
+		assert.Len(t, items.Rows, 2)
+		assert.EqualValues(t, 1, items.Rows[1][0])
+		assert.EqualValues(t, 1, items.Rows[1][1])
+		assert.Equal(t, "s2", items.Rows[1][3])
+		assert.Contains(t, items.Rows[0][4], "new") // depth 1, tags kept as JSON
+	}
+	if addresses := children["customer__addresses"]; assert.NotNil(t, addresses) {
+		assert.Len(t, addresses.Rows, 1)
+		assert.EqualValues(t, 2, addresses.Rows[0][0])
+	}
+	assert.NotContains(t, children, "items__tags")
+
+	// nested arrays, limited to items
+	_, children = read(NormalizeConfig{Keys: []string{"id"}, Depth: 2, Arrays: []string{"items", "items.tags"}})
+	assert.NotContains(t, children, "customer__addresses")
+	if tags := children["items__tags"]; assert.NotNil(t, tags) {
+		assert.Equal(t, []string{"parent_id", "items_index", "_index", "tag"}, tags.GetFields())
+		assert.Len(t, tags.Rows, 1)
+		assert.Equal(t, "new", tags.Rows[0][3])
+	}
+	if items := children["items"]; assert.NotNil(t, items) {
+		assert.Nil(t, items.Columns.GetColumn("tags"))
+	}
+
+	// the key is matched to the casing of the records
+	_, children = read(NormalizeConfig{Keys: []string{"ID"}})
+	if items := children["items"]; assert.NotNil(t, items) {
+		assert.Equal(t, "parent_ID", items.Columns[0].Name)
+		assert.EqualValues(t, 1, items.Rows[0][0])
+	}
+}
+
+func TestSampleStrategy(t *testing.T) {
+	defer func(size, chunks int, chunkBytes int64) {
+		SampleSize, SampleChunks, SampleChunkBytes = size, chunks, chunkBytes
+	}(SampleSize, SampleChunks, SampleChunkBytes)
+	SampleSize, SampleChunks, SampleChunkBytes = 50, 60, 256
+
+	folder := t.TempDir()
+
+	// later rows introduce a new type
+	csvPath := folder + "/later_type.csv"
+	lines := []string{"id,code"}
+	for i := 1; i <= 5000; i++ {
+		code := cast.ToString(i)
+		if i > 4000 {
+			code = "X" + code
+		}
+		lines = append(lines, g.F("%d,%s", i, code))
+	}
+	os.WriteFile(csvPath, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+
+	// later records introduce a new column
+	jsonPath := folder + "/later_column.jsonl"
+	lines = []string{}
+	for i := 1; i <= 5000; i++ {
+		if i > 4000 {
+			lines = append(lines, g.F(`{"id": %d, "amount": %d.5}`, i, i))
+		} else {
+			lines = append(lines, g.F(`{"id": %d}`, i))
+		}
+	}
+	os.WriteFile(jsonPath, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+
+	read := func(path, strategy string) Columns {
+		file, err := os.Open(path)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer file.Close()
+		stat, _ := file.Stat()
+
+		ds := NewDatastream(nil)
+		ds.SetConfig(map[string]string{"sample_strategy": strategy, "flatten": "true"})
+		ds.SetSampleSource(file, stat.Size())
+		if strings.HasSuffix(path, ".csv") {
+			err = ds.ConsumeCsvReader(file)
+		} else {
+			err = ds.ConsumeJsonReader(file)
+		}
+		assert.NoError(t, err)
+		return ds.Columns
+	}
+
+	// head only sees integers
+	cols := read(csvPath, "head")
+	assert.True(t, cols.GetColumn("code").IsInteger())
+
+	for _, strategy := range []string{"spread", "random"} {
+		cols = read(csvPath, strategy)
+		assert.True(t, cols.GetColumn("code").IsString(), strategy)
+	}
+
+	// head does not see the amount column
+	cols = read(jsonPath, "head")
+	assert.Nil(t, cols.GetColumn("amount"))
+
+	cols = read(jsonPath, "spread")
+	if col := cols.GetColumn("amount"); assert.NotNil(t, col) {
+		assert.True(t, col.IsDecimal() || col.IsFloat(), col.Type)
+	}
+}
+
+func TestHashRow(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tsLocal := ts.In(time.FixedZone("EST", -5*3600))
+	values := []any{int64(1), "a", 1.5, ts, nil, true}
+
+	for _, algorithm := range HashAlgorithms {
+		hash := HashRow(values, algorithm)
+		assert.NotEmpty(t, hash)
+		assert.Equal(t, hash, HashRow([]any{int64(1), "a", 1.5, tsLocal, nil, true}, algorithm), algorithm)
+		assert.NotEqual(t, hash, HashRow([]any{int64(1), "a", 1.5, ts, "", true}, algorithm), "null differs from empty")
+		assert.NotEqual(t, hash, HashRow([]any{int64(1), "a", 1.5, ts, nil, false}, algorithm))
+	}
+	assert.Len(t, HashRow(values, HashAlgorithmMD5), 32)
+	assert.Len(t, HashRow(values, HashAlgorithmXXHash), 16)
+
+	// values are not ambiguous across columns
+	assert.NotEqual(t, HashRow([]any{"ab", "c"}, ""), HashRow([]any{"a", "bc"}, ""))
+}
@@ -107,6 +107,7 @@ type ColumnStats struct {
 	MinLen       int    `json:"min_len,omitempty"`
 	MaxLen       int    `json:"max_len,omitempty"`
 	MaxDecLen    int    `json:"max_dec_len,omitempty"`
+	MaxIntLen    int    `json:"max_int_len,omitempty"`
 	Min          int64  `json:"min"`
 	Max          int64  `json:"max"`
 	NullCnt      int64  `json:"null_cnt"`
@@ -137,11 +138,83 @@ func (cs *ColumnStats) DuplicatePercent() float64 {
 	return cast.ToFloat64(val) / 100
 }
 
+// ColumnSampleStats is the type inference summary of a sampled column
+type ColumnSampleStats struct {
+	Name       string     `json:"name"`
+	Type       ColumnType `json:"type"`
+	Rows       int64      `json:"rows"`
+	Nulls      int64      `json:"nulls"`
+	Types      []string   `json:"types"`
+	Confidence string     `json:"confidence"`
+}
+
+// SampleTypes returns the distinct value types seen in the sample
+func (cs *ColumnStats) SampleTypes() (types []string) {
+	counts := []struct {
+		name string
+		cnt  int64
+	}{
+		{"int", cs.IntCnt},
+		{"decimal", cs.DecCnt},
+		{"bool", cs.BoolCnt},
+		{"date", cs.DateCnt},
+		{"datetime", cs.DateTimeCnt},
+		{"datetimez", cs.DateTimeZCnt},
+		{"json", cs.JsonCnt},
+		{"string", cs.StringCnt},
+	}
+	for _, count := range counts {
+		if count.cnt > 0 {
+			types = append(types, count.name)
+		}
+	}
+	return types
+}
+
+// SampleConfidence returns how reliable the inferred type is: `high` for a
+// single value type, `medium` for compatible types (e.g. int/decimal),
+// `low` for all nulls and `mixed ...` for incompatible types.
+func (cs *ColumnStats) SampleConfidence() string {
+	types := cs.SampleTypes()
+	switch {
+	case len(types) == 0:
+		return "low (no values)"
+	case len(types) == 1:
+		return "high"
+	}
+
+	numeric := lo.Every([]string{"int", "decimal"}, types)
+	temporal := lo.Every([]string{"date", "datetime", "datetimez"}, types)
+	if numeric || temporal {
+		return "medium (" + strings.Join(types, "/") + ")"
+	}
+	return "mixed " + strings.Join(types, "/")
+}
+
+// SampleStats returns the type inference summary of the inferred columns
+func (cols Columns) SampleStats() (stats []ColumnSampleStats) {
+	for _, col := range cols {
+		stats = append(stats, ColumnSampleStats{
+			Name:       col.Name,
+			Type:       col.Type,
+			Rows:       col.Stats.TotalCnt,
+			Nulls:      col.Stats.NullCnt,
+			Types:      col.Stats.SampleTypes(),
+			Confidence: col.Stats.SampleConfidence(),
+		})
+	}
+	return stats
+}
+
 func init() {
 	if val := os.Getenv("SAMPLE_SIZE"); val != "" {
 		SampleSize = cast.ToInt(val) // legacy
 	}
 
+	if val := os.Getenv("SLINGELT_SAMPLE_SIZE"); val != "" {
+		SampleSize = cast.ToInt(val) // legacy
+	}
+
 	if val := os.Getenv("SLING_SAMPLE_SIZE"); val != "" {
 		SampleSize = cast.ToInt(val)
 	}
@@ -555,7 +628,7 @@ func (cols Columns) Coerce(castCols Columns, hasHeader bool) (newCols Columns) {
 			newCols[i].DbScale = lo.Ternary(col.DbScale > 0, col.DbScale, newCols[i].DbScale)
 			newCols[i].Sourced = true
 			if !newCols[i].Type.IsValid() {
-				g.Warn("Provided unknown column type (%s) for column '%s'. Using string.", newCols[i].Type, newCols[i].Name)
+				env.Warn(env.WarningTypes, "Provided unknown column type (%s) for column '%s'. Using string.", newCols[i].Type, newCols[i].Name)
 				newCols[i].Type = StringType
 			}
 			continue
@@ -571,7 +644,7 @@ func (cols Columns) Coerce(castCols Columns, hasHeader bool) (newCols Columns) {
 				newCols[i].DbScale = lo.Ternary(col.DbScale > 0, col.DbScale, newCols[i].DbScale)
 				newCols[i].Sourced = true
 			} else {
-				g.Warn("Provided unknown column type (%s) for column '%s'. Using string.", col.Type, col.Name)
+				env.Warn(env.WarningTypes, "Provided unknown column type (%s) for column '%s'. Using string.", col.Type, col.Name)
 				newCols[i].Type = StringType
 			}
 		}
@@ -583,7 +656,7 @@ func (cols Columns) Coerce(castCols Columns, hasHeader bool) (newCols Columns) {
 				newCols[i].Type = col.Type
 				newCols[i].Sourced = true
 			} else {
-				g.Warn("Provided unknown column type (%s) for column '%s'. Using string.", col.Type, newCols[i].Name)
+				env.Warn(env.WarningTypes, "Provided unknown column type (%s) for column '%s'. Using string.", col.Type, newCols[i].Name)
 				newCols[i].Type = StringType
 			}
 
@@ -724,7 +797,7 @@ func CompareColumns(columns1 Columns, columns2 Columns) (reshape bool, err error
 				switch {
 				case g.In(c1.Type, TextType, StringType) && g.In(c2.Type, TextType, StringType):
 				default:
-					g.Warn("type mismatch: %s (%s) != %s (%s)", c1.Name, c1.Type, c2.Name, c2.Type)
+					env.Warn(env.WarningTypes, "type mismatch: %s (%s) != %s (%s)", c1.Name, c1.Type, c2.Name, c2.Type)
 				}
 			}
 		} else {
@@ -735,6 +808,110 @@ func CompareColumns(columns1 Columns, columns2 Columns) (reshape bool, err error
 	return reshape, eG.Err()
 }
 
+// ParseDecimalPrecision parses the decimal precision setting (source option
+// `decimal_precision`): `auto` to fit the sampled values, or an explicit
+// `precision,scale` such as `18,4`. Empty keeps the default precision.
+func ParseDecimalPrecision(setting string) (auto bool, precision, scale int, err error) {
+	setting = strings.ToLower(strings.TrimSpace(setting))
+	if setting == "" {
+		return false, 0, 0, nil
+	} else if setting == "auto" {
+		return true, 0, 0, nil
+	}
+
+	parts := strings.Split(strings.Trim(setting, "()"), ",")
+	if len(parts) != 2 {
+		return false, 0, 0, g.Error("invalid decimal_precision: %s. Expecting `auto` or `precision,scale`", setting)
+	}
+
+	precision, err1 := cast.ToIntE(strings.TrimSpace(parts[0]))
+	scale, err2 := cast.ToIntE(strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil || precision < 1 || scale < 0 || scale > precision {
+		return false, 0, 0, g.Error("invalid decimal_precision: %s. Expecting `auto` or `precision,scale`", setting)
+	}
+
+	return false, precision, scale, nil
+}
+
+// FitDecimal returns the precision & scale fitting the sampled values of the
+// column, adding the headroom to the integer digits, capped at maxPrecision
+func (col *Column) FitDecimal(headroom, maxPrecision int) (precision, scale int) {
+	intLen := lo.Ternary(col.Stats.MaxIntLen > 0, col.Stats.MaxIntLen, 1)
+	scale = lo.Ternary(col.Stats.MaxDecLen > env.DdlMaxDecScale, env.DdlMaxDecScale, col.Stats.MaxDecLen)
+	return capDecimal(intLen+headroom+scale, scale, intLen, maxPrecision)
+}
+
+// capDecimal caps the precision at maxPrecision, reducing the scale
+// to keep the integer digits
+func capDecimal(precision, scale, intLen, maxPrecision int) (int, int) {
+	if precision > maxPrecision {
+		precision = maxPrecision
+		scale = lo.Ternary(precision-intLen < scale, precision-intLen, scale)
+		scale = lo.Ternary(scale < 0, 0, scale)
+	}
+	return precision, scale
+}
+
+// ApplyDecimalPrecision sets the precision & scale of the decimal columns,
+// according to the `decimal_precision` setting (see ParseDecimalPrecision),
+// when provided:
+//   - columns without a precision get the fitted (or the explicit) one.
+//   - columns with a precision (sourced) are widened when the sampled values overflow it.
+//
+// The headroom (default 2) and the maximum precision (default 38) are set with
+// the target options `decimal_headroom` & `decimal_max_precision`.
+func (cols Columns) ApplyDecimalPrecision(sc StreamConfig) (err error) {
+	auto, precision, scale, err := ParseDecimalPrecision(sc.DecimalPrecision)
+	if err != nil || (!auto && precision == 0) {
+		return err // not set, keep the default precision
+	}
+
+	headroom := 2
+	if sc.DecimalHeadroom != nil && *sc.DecimalHeadroom >= 0 {
+		headroom = *sc.DecimalHeadroom
+	}
+	maxPrecision := lo.Ternary(sc.DecimalMaxDigits > 0, sc.DecimalMaxDigits, env.DdlMaxDecLength)
+
+	for i, col := range cols {
+		if col.Type != DecimalType {
+			continue
+		}
+
+		fitPrecision, fitScale := col.FitDecimal(headroom, maxPrecision)
+		switch {
+		case col.DbPrecision == 0 && auto:
+			cols[i].DbPrecision, cols[i].DbScale = fitPrecision, fitScale
+		case col.DbPrecision == 0:
+			cols[i].DbPrecision, cols[i].DbScale = precision, scale
+		default:
+			// widen if the sampled values overflow the integer or fractional digits
+			intLen := lo.Ternary(col.Stats.MaxIntLen > 0, col.Stats.MaxIntLen, 1)
+			if col.DbPrecision-col.DbScale >= intLen && col.DbScale >= fitScale {
+				continue
+			}
+			newScale := lo.Ternary(col.DbScale > fitScale, col.DbScale, fitScale)
+			newPrecision := lo.Ternary(col.DbPrecision > intLen+headroom+newScale, col.DbPrecision, intLen+headroom+newScale)
+			cols[i].DbPrecision, cols[i].DbScale = capDecimal(newPrecision, newScale, intLen, maxPrecision)
+			g.Debug("widening decimal column %s from decimal(%d,%d) to decimal(%d,%d) to fit the sampled values", col.Name, col.DbPrecision, col.DbScale, cols[i].DbPrecision, cols[i].DbScale)
+		}
+		cols[i].Sourced = true
+		g.Trace("decimal column %s set as decimal(%d,%d)", col.Name, cols[i].DbPrecision, cols[i].DbScale)
+	}
+
+	return nil
+}
+
+// numericDigits returns the count of integer & fractional digits of a
+// numeric string. Scientific notations are not counted.
+func numericDigits(s string) (intLen, decLen int) {
+	s = strings.TrimLeft(strings.TrimSpace(s), "+-")
+	if s == "" || strings.ContainsAny(s, "eE") {
+		return 0, 0
+	}
+	intPart, decPart, _ := strings.Cut(s, ".")
+	return len(strings.TrimLeft(intPart, "0")), len(decPart)
+}
+
 // InferFromStats using the stats to infer data types
 func InferFromStats(columns []Column, safe bool, noDebug bool) []Column {
 	for j, col := range columns {
@@ -1101,6 +1278,11 @@ func (cc *ColumnConstraint) parse() {
 
 // GetNativeType returns the native column type from generic
 func (col *Column) GetNativeType(t dbio.Type) (nativeType string, err error) {
+	// explicit type of the target column (target option `columns`)
+	if nativeType = col.Metadata["target_type"]; nativeType != "" {
+		return nativeType, nil
+	}
+
 	template, _ := t.Template()
 	nativeType, ok := template.GeneralTypeMap[string(col.Type)]
 	if !ok {
@@ -1253,6 +1435,8 @@ func FormatValue(val any, column Column, connType dbio.Type) (newVal string) {
 		}
 	} else if column.Type.IsNumber() {
 		newVal = cast.ToString(val)
+	} else if connType == dbio.TypeDbFirestore {
+		newVal = g.Marshal(cast.ToString(val)) // a JSON value, in the query options
 	} else {
 		newVal = strings.ReplaceAll(cast.ToString(val), `'`, `''`)
 		newVal = `'` + newVal + `'`
@@ -1269,8 +1453,16 @@ const (
 	SnakeColumnCasing  ColumnCasing = "snake"  // converts snake casing according to target database. Lower-case for files.
 	UpperColumnCasing  ColumnCasing = "upper"  // make it upper case
 	LowerColumnCasing  ColumnCasing = "lower"  // make it lower case
+
+	NormalizeColumnCasing ColumnCasing = "normalize" // strips special characters, keeping the casing
 )
 
+// ColumnCasings are the valid column casings
+var ColumnCasings = []ColumnCasing{
+	SourceColumnCasing, TargetColumnCasing, SnakeColumnCasing,
+	UpperColumnCasing, LowerColumnCasing, NormalizeColumnCasing,
+}
+
 // Equals evaluates equality for column casing (pointer safe)
 func (cc *ColumnCasing) Equals(val ColumnCasing) bool {
 	if cc.IsEmpty() {
@@ -1305,6 +1497,9 @@ func (cc *ColumnCasing) Apply(name string, tgtConnType dbio.Type) string {
 	name = CleanName(name)
 
 	switch {
+	case cc.Equals(NormalizeColumnCasing):
+		// keep the casing, the special characters are cleaned above
+		return name
 	case cc.Equals(UpperColumnCasing):
 		return strings.ToUpper(name)
 	case cc.Equals(LowerColumnCasing):
@@ -1319,3 +1514,22 @@ func (cc *ColumnCasing) Apply(name string, tgtConnType dbio.Type) string {
 
 	return name
 }
+
+// Collisions returns the new names resulting from more than one
+// original name (e.g. `Col` and `col` both becoming `col`),
+// mapped to the original names
+func (cc *ColumnCasing) Collisions(names []string, tgtConnType dbio.Type) (collisions map[string][]string) {
+	collisions = map[string][]string{}
+	origNames := map[string][]string{}
+	for _, name := range names {
+		newName := cc.Apply(name, tgtConnType)
+		origNames[newName] = append(origNames[newName], name)
+	}
+
+	for newName, names := range origNames {
+		if len(names) > 1 {
+			collisions[newName] = names
+		}
+	}
+	return collisions
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/flarco/g"
 	"github.com/shopspring/decimal"
+	"github.com/slingdata-io/sling-cli/core/dbio"
 	"github.com/spf13/cast"
 	"github.com/stretchr/testify/assert"
 )
@@ -372,3 +373,200 @@ func TestParseString(t *testing.T) {
 	g.P(val)
 	g.P(cast.ToTime(val).Location().String() == "UTC")
 }
+
+func TestDecimalPrecision(t *testing.T) {
+	auto, p, s, err := ParseDecimalPrecision("18,4")
+	if assert.NoError(t, err) {
+		assert.False(t, auto)
+		assert.Equal(t, 18, p)
+		assert.Equal(t, 4, s)
+	}
+	auto, _, _, err = ParseDecimalPrecision("AUTO")
+	assert.NoError(t, err)
+	assert.True(t, auto)
+	auto, p, _, err = ParseDecimalPrecision("")
+	assert.NoError(t, err)
+	assert.False(t, auto, "opt-in")
+	assert.Equal(t, 0, p)
+	for _, invalid := range []string{"18", "4,18", "a,b", "0,0"} {
+		_, _, _, err = ParseDecimalPrecision(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	newData := func(sc StreamConfig, values ...string) Dataset {
+		data := NewDataset(NewColumnsFromFields("amount"))
+		for _, val := range values {
+			data.Rows = append(data.Rows, []any{val})
+		}
+		data.Sp.Config.DecimalPrecision = sc.DecimalPrecision
+		data.Sp.Config.DecimalHeadroom = sc.DecimalHeadroom
+		data.Sp.Config.DecimalMaxDigits = sc.DecimalMaxDigits
+		data.Sp.Config.Columns = sc.Columns
+		data.InferColumnTypes()
+		return data
+	}
+
+	// not set, default precision
+	data := newData(StreamConfig{}, "1.5", "-123.25")
+	assert.Equal(t, DecimalType, data.Columns[0].Type)
+	assert.Equal(t, 0, data.Columns[0].DbPrecision)
+
+	// varying scale, fitted with the headroom
+	data = newData(StreamConfig{DecimalPrecision: "auto"}, "1.5", "-123.25", "0.123456789", "98765.1")
+	col := data.Columns[0]
+	assert.Equal(t, DecimalType, col.Type)
+	assert.Equal(t, 5, col.Stats.MaxIntLen)
+	assert.Equal(t, 9, col.Stats.MaxDecLen)
+	assert.Equal(t, 16, col.DbPrecision) // 5 + 2 + 9
+	assert.Equal(t, 9, col.DbScale)
+	nativeType, err := col.GetNativeType(dbio.TypeDbMySQL)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "decimal(16,9)", nativeType)
+	}
+
+	// would overflow the default integer digits (24 - 6), capped at 38
+	data = newData(StreamConfig{DecimalPrecision: "auto"}, "123456789012345678901234567890.5", "1.123456789012")
+	col = data.Columns[0]
+	assert.Equal(t, 30, col.Stats.MaxIntLen)
+	assert.Equal(t, 38, col.DbPrecision)
+	assert.Equal(t, 8, col.DbScale) // reduced to keep the integer digits
+
+	// configurable headroom & cap
+	data = newData(StreamConfig{DecimalPrecision: "auto", DecimalHeadroom: g.Int(0)}, "12.5", "1234.55")
+	assert.Equal(t, 6, data.Columns[0].DbPrecision)
+	assert.Equal(t, 2, data.Columns[0].DbScale)
+	data = newData(StreamConfig{DecimalPrecision: "auto", DecimalMaxDigits: 20}, "123456789012345.5", "1.123456789")
+	assert.Equal(t, 20, data.Columns[0].DbPrecision)
+	assert.Equal(t, 5, data.Columns[0].DbScale)
+
+	// override
+	data = newData(StreamConfig{DecimalPrecision: "12,3"}, "1.5", "2.25")
+	nativeType, err = data.Columns[0].GetNativeType(dbio.TypeDbMySQL)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "decimal(12,3)", nativeType)
+	}
+
+	// sourced decimal without a precision is fitted
+	sourced := func(precision, scale int) StreamConfig {
+		return StreamConfig{DecimalPrecision: "auto", Columns: Columns{{Name: "amount", Type: DecimalType, DbPrecision: precision, DbScale: scale}}}
+	}
+	data = newData(sourced(0, 0), "1.5", "1234567890123456789012.25")
+	assert.Equal(t, 26, data.Columns[0].DbPrecision) // 22 + 2 + 2
+	assert.Equal(t, 2, data.Columns[0].DbScale)
+
+	// sourced decimal is widened when the values overflow it
+	data = newData(sourced(6, 2), "1.5", "123456.125")
+	assert.Equal(t, 11, data.Columns[0].DbPrecision) // 6 + 2 + 3
+	assert.Equal(t, 3, data.Columns[0].DbScale)
+
+	// sourced decimal fitting the values is kept
+	data = newData(sourced(10, 4), "1.5", "123.25")
+	assert.Equal(t, 10, data.Columns[0].DbPrecision)
+	assert.Equal(t, 4, data.Columns[0].DbScale)
+}
+
+func TestColumnSampleStats(t *testing.T) {
+	data := NewDataset(NewColumnsFromFields("id", "code", "amount", "empty"))
+	data.Rows = [][]any{
+		{"1", "100", "1", ""},
+		{"2", "200", "2.5", ""},
+		{"3", "A-300", "3", ""},
+	}
+	data.InferColumnTypes()
+
+	stats := data.Columns.SampleStats()
+	if !assert.Len(t, stats, 4) {
+		return
+	}
+
+	assert.Equal(t, "id", stats[0].Name)
+	assert.EqualValues(t, 3, stats[0].Rows)
+	assert.Equal(t, []string{"int"}, stats[0].Types)
+	assert.Equal(t, "high", stats[0].Confidence)
+
+	assert.Equal(t, StringType, stats[1].Type)
+	assert.Equal(t, []string{"int", "string"}, stats[1].Types)
+	assert.Equal(t, "mixed int/string", stats[1].Confidence)
+
+	assert.Equal(t, DecimalType, stats[2].Type)
+	assert.Equal(t, "medium (int/decimal)", stats[2].Confidence)
+
+	assert.EqualValues(t, 3, stats[3].Nulls)
+	assert.Empty(t, stats[3].Types)
+	assert.Equal(t, "low (no values)", stats[3].Confidence)
+
+	// sample size is respected
+	sampleSize := SampleSize
+	SampleSize = 2
+	defer func() { SampleSize = sampleSize }()
+
+	data.Columns = NewColumnsFromFields("id", "code", "amount", "empty")
+	data.InferColumnTypes()
+	stats = data.Columns.SampleStats()
+	assert.EqualValues(t, 2, stats[1].Rows)
+	assert.Equal(t, "high", stats[1].Confidence)
+}
+
+func TestBoolValues(t *testing.T) {
+	bv := &BoolValues{
+		Truthy: []string{"Y", "yes", "t"},
+		Falsy:  []string{"N", "no", "f"},
+		Columns: map[string]*BoolValues{
+			"is_active": {Truthy: []string{"1"}, Falsy: []string{"0"}},
+		},
+	}
+
+	for val, expected := range map[string]bool{"Y": true, "n": false, "YES": true, " no ": false, "t": true, "F": false} {
+		b, ok := bv.Parse("flag", val)
+		assert.True(t, ok, val)
+		assert.Equal(t, expected, b, val)
+	}
+
+	// not mapped, or empty (null)
+	for _, val := range []string{"", "1", "maybe"} {
+		_, ok := bv.Parse("flag", val)
+		assert.False(t, ok, val)
+	}
+
+	// per-column override
+	b, ok := bv.Parse("IS_ACTIVE", "1")
+	assert.True(t, ok)
+	assert.True(t, b)
+	_, ok = bv.Parse("is_active", "Y")
+	assert.False(t, ok)
+
+	var nilValues *BoolValues
+	_, ok = nilValues.Parse("flag", "Y")
+	assert.False(t, ok)
+
+	// inference & casting
+	data := NewDataset(NewColumnsFromFields("flag", "is_active", "amount"))
+	data.Rows = [][]any{
+		{"Y", "1", "1"},
+		{"no", "0", "0"},
+		{"", "", "5"},
+	}
+	data.Sp.Config.BoolValues = bv
+	data.InferColumnTypes()
+	assert.Equal(t, BoolType, data.Columns[0].Type)
+	assert.Equal(t, BoolType, data.Columns[1].Type)
+	assert.Equal(t, BigIntType, data.Columns[2].Type)
+
+	sp := NewStreamProcessor()
+	sp.Config.BoolValues = bv
+	assert.Equal(t, true, sp.CastVal(0, "Y", &data.Columns[0]))
+	assert.Equal(t, false, sp.CastVal(0, "no", &data.Columns[0]))
+	assert.Equal(t, false, sp.CastVal(1, "0", &data.Columns[1]))
+	assert.Nil(t, sp.CastVal(0, "", &data.Columns[0]))
+
+	sp.SetConfig(map[string]string{"bool_values": g.Marshal(bv), "bool_format": "Y/N"})
+	assert.Len(t, sp.Config.BoolValues.Columns, 1)
+	assert.Equal(t, "Y", sp.CastToString(0, true, BoolType))
+	assert.Equal(t, "N", sp.CastToString(0, false, BoolType))
+	assert.Equal(t, "1", FormatBool("1/0", true))
+	assert.Equal(t, "false", FormatBool("", false))
+
+	assert.NoError(t, ValidateBoolFormat("yes/no"))
+	assert.Error(t, ValidateBoolFormat("Y"))
+	assert.Error(t, ValidateBoolFormat("Y/Y"))
+}
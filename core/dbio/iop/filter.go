@@ -0,0 +1,110 @@
+package iop
+
+import (
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+)
+
+// FilterCondition is a simple comparison on a column or partition key,
+// such as `dt >= 2021-01-01`
+type FilterCondition struct {
+	Key      string
+	Operator string
+	Value    string
+}
+
+// compare returns -1, 0 or 1. Values are compared as numbers if both are numeric, else as strings.
+func (fc FilterCondition) compare(value string) int {
+	cmp := strings.Compare(value, fc.Value)
+	if fVal, err := cast.ToFloat64E(value); err == nil {
+		if fCond, err := cast.ToFloat64E(fc.Value); err == nil {
+			cmp = lo.Ternary(fVal < fCond, -1, lo.Ternary(fVal > fCond, 1, 0))
+		}
+	}
+	return cmp
+}
+
+// Match returns true if the value satisfies the condition.
+// Values are compared as numbers if both are numeric, else as strings.
+func (fc FilterCondition) Match(value string) bool {
+	cmp := fc.compare(value)
+
+	switch fc.Operator {
+	case "=", "==":
+		return cmp == 0
+	case "!=", "<>":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// MatchRange returns false if no value between min and max (such as the
+// statistics of a parquet row group) can satisfy the condition
+func (fc FilterCondition) MatchRange(min, max string) bool {
+	switch fc.Operator {
+	case "=", "==":
+		return fc.compare(min) <= 0 && fc.compare(max) >= 0
+	case "!=", "<>":
+		return !(fc.compare(min) == 0 && fc.compare(max) == 0)
+	case ">":
+		return fc.compare(max) > 0
+	case ">=":
+		return fc.compare(max) >= 0
+	case "<":
+		return fc.compare(min) < 0
+	case "<=":
+		return fc.compare(min) <= 0
+	}
+	return true
+}
+
+// ParseFilterConditions parses a filter expression such as
+// `dt >= 2021-01-01 and region = us`. Conditions are joined with `and`.
+func ParseFilterConditions(expr string) (conditions []FilterCondition, err error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return
+	}
+
+	for _, condStr := range splitAnd(expr) {
+		matches := g.Matches(strings.TrimSpace(condStr), `^([\w\-\.]+)\s*(==|=|!=|<>|>=|<=|>|<)\s*(.+)$`)
+		if len(matches) == 0 || len(matches[0].Group) < 3 {
+			return nil, g.Error("invalid filter condition: %s", condStr)
+		}
+
+		value := strings.TrimSpace(matches[0].Group[2])
+		value = strings.Trim(value, `'"`)
+		conditions = append(conditions, FilterCondition{
+			Key:      strings.ToLower(matches[0].Group[0]),
+			Operator: matches[0].Group[1],
+			Value:    value,
+		})
+	}
+
+	return
+}
+
+func splitAnd(expr string) (parts []string) {
+	words := strings.Fields(expr)
+	current := []string{}
+	for _, word := range words {
+		if strings.EqualFold(word, "and") {
+			parts = append(parts, strings.Join(current, " "))
+			current = []string{}
+			continue
+		}
+		current = append(current, word)
+	}
+	return append(parts, strings.Join(current, " "))
+}
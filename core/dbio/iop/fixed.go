@@ -0,0 +1,237 @@
+package iop
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/transform"
+)
+
+// FixedField is a column definition in a fixed-width layout
+type FixedField struct {
+	Name   string     `json:"name" yaml:"name"`
+	Start  int        `json:"start" yaml:"start"`   // 1-based position of first character
+	Length int        `json:"length" yaml:"length"` // number of characters
+	Type   ColumnType `json:"type,omitempty" yaml:"type,omitempty"`
+	Trim   *bool      `json:"trim,omitempty" yaml:"trim,omitempty"` // defaults to true
+}
+
+// FixedLayout is the list of fields used to slice a fixed-width line
+type FixedLayout []FixedField
+
+// ParseFixedLayout parses a layout payload (JSON/YAML string or list of maps)
+func ParseFixedLayout(payload any) (layout FixedLayout, err error) {
+	switch pv := payload.(type) {
+	case nil:
+		return nil, g.Error("fixed-width layout is empty")
+	case string:
+		if strings.TrimSpace(pv) == "" {
+			return nil, g.Error("fixed-width layout is empty")
+		}
+		err = g.Unmarshal(pv, &layout)
+	default:
+		err = g.JSONConvert(pv, &layout)
+	}
+	if err != nil {
+		return nil, g.Error(err, "could not parse fixed-width layout")
+	}
+
+	if len(layout) == 0 {
+		return nil, g.Error("fixed-width layout is empty")
+	}
+
+	for i, field := range layout {
+		switch {
+		case field.Name == "":
+			return nil, g.Error("fixed-width layout field #%d has no name", i+1)
+		case field.Start < 1:
+			return nil, g.Error("fixed-width layout field %s must have a start >= 1", field.Name)
+		case field.Length < 1:
+			return nil, g.Error("fixed-width layout field %s must have a length >= 1", field.Name)
+		case field.Type != "" && !field.Type.IsValid():
+			return nil, g.Error("fixed-width layout field %s has an invalid type: %s", field.Name, field.Type)
+		}
+	}
+
+	return layout, nil
+}
+
+// Columns returns the columns of the layout
+func (l FixedLayout) Columns() Columns {
+	cols := make(Columns, len(l))
+	for i, field := range l {
+		cols[i] = Column{
+			Name:     field.Name,
+			Position: i + 1,
+			Type:     lo.Ternary(field.Type != "", field.Type, StringType),
+			Sourced:  field.Type != "",
+		}
+	}
+	return cols
+}
+
+// FixedDecoder returns the decoding transformer for the provided encoding name
+func FixedDecoder(encoding string) (t transform.Transformer, err error) {
+	transformers := NewTransformers()
+	switch strings.ToLower(strings.ReplaceAll(encoding, "-", "_")) {
+	case "", "utf8":
+		return nil, nil
+	case "utf8_bom":
+		return transformers.DecodeUTF8BOM, nil
+	case "utf16":
+		return transformers.DecodeUTF16, nil
+	case "latin1", "iso8859_1":
+		return transformers.DecodeISO8859_1, nil
+	case "latin5", "iso8859_5":
+		return transformers.DecodeISO8859_5, nil
+	case "latin9", "iso8859_15":
+		return transformers.DecodeISO8859_15, nil
+	case "windows1250", "cp1250":
+		return transformers.DecodeWindows1250, nil
+	case "windows1252", "cp1252":
+		return transformers.DecodeWindows1252, nil
+	case "ebcdic", "cp037", "ibm037":
+		return charmap.CodePage037.NewDecoder(), nil
+	case "cp1047", "ibm1047":
+		return charmap.CodePage1047.NewDecoder(), nil
+	}
+	return nil, g.Error("unsupported encoding: %s", encoding)
+}
+
+// FixedReader reads fixed-width text line by line
+type FixedReader struct {
+	Layout          FixedLayout
+	SkipHeaderLines int
+	SkipFooterLines int
+
+	scanner *bufio.Scanner
+	pending []string // lines held back to detect footer lines
+	skipped bool
+}
+
+// NewFixedReader creates a new fixed-width reader
+func NewFixedReader(reader io.Reader, layout FixedLayout) *FixedReader {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	return &FixedReader{Layout: layout, scanner: scanner}
+}
+
+// readLine returns the next data line, honoring header & footer skipping
+func (f *FixedReader) readLine() (line string, ok bool, err error) {
+	if !f.skipped {
+		f.skipped = true
+		for i := 0; i < f.SkipHeaderLines; i++ {
+			if !f.scanner.Scan() {
+				return "", false, f.scanner.Err()
+			}
+		}
+	}
+
+	for f.scanner.Scan() {
+		line = strings.TrimRight(f.scanner.Text(), "\r")
+		if f.SkipFooterLines <= 0 {
+			return line, true, nil
+		}
+
+		f.pending = append(f.pending, line)
+		if len(f.pending) > f.SkipFooterLines {
+			line = f.pending[0]
+			f.pending = f.pending[1:]
+			return line, true, nil
+		}
+	}
+
+	return "", false, f.scanner.Err()
+}
+
+// ParseLine slices the line into values per the layout
+func (f *FixedReader) ParseLine(line string) []any {
+	chars := []rune(line)
+	row := make([]any, len(f.Layout))
+	for i, field := range f.Layout {
+		start := field.Start - 1
+		if start >= len(chars) {
+			row[i] = nil // ragged line
+			continue
+		}
+
+		end := start + field.Length
+		if end > len(chars) {
+			end = len(chars)
+		}
+
+		val := string(chars[start:end])
+		if field.Trim == nil || *field.Trim {
+			val = strings.TrimSpace(val)
+		}
+
+		if field.Type.IsNumber() {
+			val = normalizeFixedNumber(val)
+		}
+
+		if val == "" && !field.Type.IsString() {
+			row[i] = nil
+		} else {
+			row[i] = val
+		}
+	}
+	return row
+}
+
+// normalizeFixedNumber handles signs used in mainframe extracts,
+// such as trailing minus (123.45-) or accounting parentheses ((123.45))
+func normalizeFixedNumber(val string) string {
+	val = strings.TrimSpace(val)
+	switch {
+	case val == "":
+		return val
+	case strings.HasPrefix(val, "(") && strings.HasSuffix(val, ")"):
+		return "-" + strings.TrimSpace(val[1:len(val)-1])
+	case strings.HasSuffix(val, "-"):
+		return "-" + strings.TrimSpace(strings.TrimSuffix(val, "-"))
+	case strings.HasSuffix(val, "+"):
+		return strings.TrimSpace(strings.TrimSuffix(val, "+"))
+	case strings.HasPrefix(val, "+"):
+		return strings.TrimSpace(strings.TrimPrefix(val, "+"))
+	}
+	return val
+}
+
+func (f *FixedReader) nextFunc(it *Iterator) bool {
+	line, ok, err := f.readLine()
+	if err != nil {
+		it.Context.CaptureErr(g.Error(err, "could not read fixed-width line"))
+		return false
+	} else if !ok {
+		return false
+	}
+
+	it.Row = f.ParseLine(line)
+	return true
+}
+
+// newFixedReaderFromConfig creates a fixed-width reader from the stream config
+func newFixedReaderFromConfig(reader io.Reader, configMap map[string]string) (f *FixedReader, err error) {
+	layout, err := ParseFixedLayout(configMap["layout"])
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := FixedDecoder(configMap["encoding"])
+	if err != nil {
+		return nil, g.Error(err, "could not get decoder")
+	} else if decoder != nil {
+		reader = transform.NewReader(reader, decoder)
+	}
+
+	f = NewFixedReader(reader, layout)
+	f.SkipHeaderLines = cast.ToInt(configMap["skip_header_lines"])
+	f.SkipFooterLines = cast.ToInt(configMap["skip_footer_lines"])
+
+	return f, nil
+}
@@ -0,0 +1,100 @@
+package iop
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedWidth(t *testing.T) {
+	layout := `[
+		{"name": "id", "start": 1, "length": 5, "type": "integer"},
+		{"name": "name", "start": 6, "length": 20, "type": "string"},
+		{"name": "amount", "start": 26, "length": 12, "type": "decimal"},
+		{"name": "as_of", "start": 38, "length": 8, "type": "string"},
+		{"name": "flag", "start": 46, "length": 1}
+	]`
+
+	configMap := map[string]string{
+		"layout":            layout,
+		"skip_header_lines": "1",
+		"skip_footer_lines": "1",
+	}
+
+	file, err := os.Open("test/test1.fixed.txt")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ds := NewDatastream(nil)
+	ds.SetConfig(configMap)
+	err = ds.ConsumeFixedReader(bufio.NewReader(file))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	data, err := ds.Collect(0)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if assert.Len(t, data.Rows, 5) {
+		assert.Equal(t, []string{"id", "name", "amount", "as_of", "flag"}, data.Columns.Names())
+		assert.Equal(t, IntegerType, data.Columns[0].Type)
+		assert.Equal(t, DecimalType, data.Columns[2].Type)
+
+		assert.EqualValues(t, 1, data.Rows[0][0])
+		assert.Equal(t, "Alice Smith", data.Rows[0][1]) // trailing spaces trimmed
+		assert.Equal(t, 123.45, cast.ToFloat64(data.Rows[0][2]))
+		assert.Equal(t, "20240101", data.Rows[0][3])
+		assert.Equal(t, "Y", data.Rows[0][4])
+
+		assert.Equal(t, -50.0, cast.ToFloat64(data.Rows[1][2])) // trailing minus
+		assert.Equal(t, -10.5, cast.ToFloat64(data.Rows[2][2])) // parentheses
+		assert.Equal(t, "Carol  White", data.Rows[2][1])        // inner spaces kept
+		assert.Equal(t, "", data.Rows[3][1])                    // blank string
+		assert.Nil(t, data.Rows[3][4])                          // blank value
+		assert.Equal(t, "Dan", data.Rows[4][1])                 // ragged line
+		assert.Nil(t, data.Rows[4][2])                          // ragged line
+	}
+
+	// encoding
+	{
+		fl, err := ParseFixedLayout(`[{"name": "code", "start": 1, "length": 3}]`)
+		assert.NoError(t, err)
+
+		decoder, err := FixedDecoder("ebcdic")
+		assert.NoError(t, err)
+
+		// "ABC" in EBCDIC (cp037)
+		ebcdic := string([]byte{0xC1, 0xC2, 0xC3})
+		fr, err := newFixedReaderFromConfig(strings.NewReader(ebcdic), map[string]string{
+			"layout":   `[{"name": "code", "start": 1, "length": 3}]`,
+			"encoding": "ebcdic",
+		})
+		if assert.NoError(t, err) && assert.NotNil(t, decoder) {
+			line, ok, err := fr.readLine()
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, []any{"ABC"}, fr.ParseLine(line))
+			assert.Equal(t, fl, fr.Layout)
+		}
+
+		_, err = FixedDecoder("klingon")
+		assert.Error(t, err)
+	}
+
+	// invalid layouts
+	{
+		_, err = ParseFixedLayout("")
+		assert.Error(t, err)
+		_, err = ParseFixedLayout(`[{"name": "a", "start": 0, "length": 2}]`)
+		assert.Error(t, err)
+		_, err = ParseFixedLayout(`[{"name": "a", "start": 1, "length": 2, "type": "blah"}]`)
+		assert.Error(t, err)
+	}
+}
@@ -1,15 +1,18 @@
 package iop
 
 import (
+	"bufio"
 	"io"
 	"reflect"
 	"sort"
 	"strings"
 
 	"github.com/flarco/g"
+	"github.com/flarco/g/json"
 	"github.com/jmespath/go-jmespath"
 	"github.com/nqd/flat"
 	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/env"
 	"github.com/spf13/cast"
 )
 
@@ -27,6 +30,30 @@ type jsonStream struct {
 	jmespath string
 	flatten  bool
 	buffer   chan []interface{}
+
+	streamArray bool // decode the elements of the top-level array one at a time
+	inArray     bool // the opening bracket of the array was read
+
+	normalizeKeys string                           // `lowercase` or `snake_case`
+	keyTypes      map[string]map[string]ColumnType // normalized key -> original key -> type
+	keyWarned     map[string]bool
+}
+
+const (
+	NormalizeKeysLowercase = "lowercase"  // `userId` -> `userid`
+	NormalizeKeysSnakeCase = "snake_case" // `userId` -> `user_id`
+)
+
+// NormalizeKey returns the JSON object key normalized with the method
+// (`lowercase` or `snake_case`), so that keys with inconsistent casing merge
+func NormalizeKey(key, method string) string {
+	switch method {
+	case NormalizeKeysLowercase:
+		return strings.ToLower(key)
+	case NormalizeKeysSnakeCase:
+		return strings.ToLower(matchAllCap.ReplaceAllString(key, "${1}_${2}"))
+	}
+	return key
 }
 
 func NewJSONStream(ds *Datastream, decoder decoderLike, flatten bool, jmespath string) *jsonStream {
@@ -38,6 +65,14 @@ func NewJSONStream(ds *Datastream, decoder decoderLike, flatten bool, jmespath s
 		jmespath:  jmespath,
 		buffer:    make(chan []interface{}, 100000),
 		sp:        NewStreamProcessor(),
+		keyTypes:  map[string]map[string]ColumnType{},
+		keyWarned: map[string]bool{},
+	}
+	if ds.Sp != nil {
+		js.normalizeKeys = ds.Sp.Config.NormalizeKeys
+	}
+	if flatten && ds.Sp != nil && ds.Sp.Config.Normalize != nil {
+		ds.normalizer = newNormalizer(*ds.Sp.Config.Normalize)
 	}
 	if !flatten {
 		col := &Column{Position: 1, Name: "data", Type: JsonType, FileURI: cast.ToString(js.ds.Metadata.StreamURL.Value)}
@@ -63,6 +98,21 @@ func (js *jsonStream) NextFunc(it *Iterator) bool {
 	default:
 	}
 
+	// stream the elements of a top-level array, instead of decoding it whole
+	if js.streamArray {
+		if rec, ok := js.nextArrayElement(it); ok {
+			js.parseRecords([]map[string]interface{}{rec})
+			if err = it.Context.Err(); err != nil {
+				it.Context.CaptureErr(g.Error(err, "error parsing records"))
+				return false
+			}
+			it.Row = <-js.buffer
+			return true
+		} else if it.Context.Err() != nil {
+			return false
+		}
+	}
+
 	var payload interface{}
 	if js.HasMapPayload {
 		m := g.M()
@@ -164,6 +214,60 @@ func (js *jsonStream) NextFunc(it *Iterator) bool {
 	}
 }
 
+// nextArrayElement decodes the next element of the top-level array as a record.
+// Returns false at the end of the array, after which values are decoded whole.
+func (js *jsonStream) nextArrayElement(it *Iterator) (rec map[string]interface{}, ok bool) {
+	decoder, isJSON := js.decoder.(*json.Decoder)
+	if !isJSON {
+		js.streamArray = false
+		return nil, false
+	}
+
+	if !js.inArray {
+		if _, err := decoder.Token(); err != nil { // the opening bracket
+			it.Context.CaptureErr(g.Error(err, "could not decode JSON array"))
+			return nil, false
+		}
+		js.inArray = true
+	}
+
+	if !decoder.More() {
+		decoder.Token() // the closing bracket
+		js.streamArray, js.inArray = false, false
+		return nil, false
+	}
+
+	var elem interface{}
+	if err := decoder.Decode(&elem); err != nil {
+		it.Context.CaptureErr(g.Error(err, "could not decode JSON array element"))
+		return nil, false
+	}
+
+	if rec = toStringMap(elem); rec == nil {
+		rec = map[string]interface{}{"data": elem} // array of single values
+	}
+	return rec, true
+}
+
+// peekJSONArray returns true if the first non-space character of the
+// reader opens an array, without consuming it
+func peekJSONArray(reader *bufio.Reader) bool {
+	for n := 1; n <= 4096; n++ {
+		b, err := reader.Peek(n)
+		if err != nil || len(b) < n {
+			return false
+		}
+		switch b[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			return true
+		}
+		return false
+	}
+	return false
+}
+
 func (js *jsonStream) addColumn(cols ...Column) {
 	mux := js.ds.Context.Mux
 	if df := js.ds.Df(); df != nil {
@@ -183,7 +287,14 @@ func (js *jsonStream) parseRecords(records []map[string]interface{}) {
 			continue
 		}
 
+		if js.ds.normalizer != nil {
+			rec = js.ds.normalizer.split(rec)
+		}
+
 		newRec, _ := flat.Flatten(rec, &flat.Options{Delimiter: "__", Safe: true})
+		if js.normalizeKeys != "" {
+			newRec = js.normalizeRecord(newRec)
+		}
 		keys := lo.Keys(newRec)
 		sort.Strings(keys)
 
@@ -220,6 +331,49 @@ func (js *jsonStream) parseRecords(records []map[string]interface{}) {
 	// g.Debug("JSON Stream -> Parsed %d records", len(records))
 }
 
+// normalizeRecord normalizes the keys of the flattened record. When several keys
+// merge into one, the first non-null value (in key order) is kept, and a warning
+// is emitted once if their values have differing types.
+func (js *jsonStream) normalizeRecord(rec map[string]interface{}) map[string]interface{} {
+	keys := lo.Keys(rec)
+	sort.Strings(keys)
+
+	newRec := make(map[string]interface{}, len(rec))
+	for _, key := range keys {
+		val := rec[key]
+		newKey := NormalizeKey(key, js.normalizeKeys)
+		if existing, ok := newRec[newKey]; !ok || existing == nil {
+			newRec[newKey] = val
+		}
+
+		if val == nil {
+			continue
+		}
+
+		// track the value types of the original keys, to detect collisions
+		types, ok := js.keyTypes[newKey]
+		if !ok {
+			types = map[string]ColumnType{}
+			js.keyTypes[newKey] = types
+		}
+		if _, ok := types[key]; !ok {
+			types[key] = js.ds.Sp.GetType(val)
+		}
+
+		if len(types) > 1 && !js.keyWarned[newKey] {
+			origKeys := lo.Keys(types)
+			sort.Strings(origKeys)
+			typeNames := lo.Uniq(lo.Map(origKeys, func(k string, i int) string { return string(types[k]) }))
+			if len(typeNames) > 1 {
+				env.Warn(env.WarningTypes, "JSON keys %s were merged into column %s, but have differing types: %s", strings.Join(origKeys, ", "), newKey, strings.Join(typeNames, ", "))
+				js.keyWarned[newKey] = true
+			}
+		}
+	}
+
+	return newRec
+}
+
 func (js *jsonStream) extractNestedArray(rec map[string]interface{}) (recordsInterf []map[string]interface{}) {
 	if !js.flatten {
 		return []map[string]interface{}{rec}
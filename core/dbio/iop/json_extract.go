@@ -0,0 +1,253 @@
+package iop
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+)
+
+// JSONExtract is a rule extracting a nested field of a JSON column into a new
+// column, e.g. `{"from": "payload", "path": "$.user.id", "as": "user_id", "type": "integer"}`
+type JSONExtract struct {
+	From string     `json:"from" yaml:"from"`
+	Path string     `json:"path" yaml:"path"`
+	As   string     `json:"as" yaml:"as"`
+	Type ColumnType `json:"type,omitempty" yaml:"type,omitempty"`
+
+	keys []any // the parsed path: object keys (string) or array indexes (int)
+}
+
+var jsonPathPartRegex = regexp.MustCompile(`^(\.([^.\[\]'"\\]+)|\[(\d+)\]|\["([^"'\\\]]+)"\]|\['([^"'\\\]]+)'\])`)
+
+var jsonPathKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseJSONPath parses a JSON path such as `$.user.id`, `$.items[0].name`
+// or `$["user name"]` into object keys (string) and array indexes (int)
+func ParseJSONPath(path string) (keys []any, err error) {
+	rest := strings.TrimSpace(path)
+	if !strings.HasPrefix(rest, "$") {
+		return nil, g.Error("invalid JSON path %#v, should start with `$`", path)
+	}
+	rest = rest[1:]
+
+	for rest != "" {
+		match := jsonPathPartRegex.FindStringSubmatch(rest)
+		if match == nil {
+			return nil, g.Error("invalid JSON path %#v, at %#v", path, rest)
+		}
+
+		switch {
+		case match[2] != "":
+			keys = append(keys, match[2])
+		case match[3] != "":
+			index, _ := strconv.Atoi(match[3])
+			keys = append(keys, index)
+		case match[4] != "":
+			keys = append(keys, match[4])
+		default:
+			keys = append(keys, match[5])
+		}
+		rest = rest[len(match[0]):]
+	}
+
+	if len(keys) == 0 {
+		return nil, g.Error("invalid JSON path %#v, no field specified", path)
+	}
+
+	return keys, nil
+}
+
+// Validate checks the rule, and parses its path
+func (je *JSONExtract) Validate() (err error) {
+	if je.From == "" || je.As == "" {
+		return g.Error("JSON extract needs the `from` and `as` columns: %s", g.Marshal(je))
+	}
+	if je.keys, err = ParseJSONPath(je.Path); err != nil {
+		return err
+	}
+	if je.Type == "" {
+		je.Type = StringType
+	} else if !je.Type.IsValid() {
+		return g.Error("invalid type %#v for JSON extract %s", je.Type, je.As)
+	}
+	return nil
+}
+
+// Keys returns the parsed path
+func (je *JSONExtract) Keys() []any {
+	return je.keys
+}
+
+// PathSQL returns the path in the standard JSON path syntax of databases,
+// quoting the keys which are not identifiers, e.g. `$.user."first name"`
+func (je *JSONExtract) PathSQL() string {
+	var sb strings.Builder
+	sb.WriteString("$")
+	for _, key := range je.keys {
+		switch k := key.(type) {
+		case int:
+			sb.WriteString("[" + strconv.Itoa(k) + "]")
+		case string:
+			if jsonPathKeyRegex.MatchString(k) {
+				sb.WriteString("." + k)
+			} else {
+				sb.WriteString(`."` + k + `"`)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// Extract returns the value at the path, from a JSON string or a decoded
+// object. Returns nil if the value is not JSON, or the path is not found.
+// Objects and arrays are returned as JSON strings.
+func (je *JSONExtract) Extract(val any) any {
+	switch v := val.(type) {
+	case nil:
+		return nil
+	case string:
+		if v = strings.TrimSpace(v); v == "" {
+			return nil
+		}
+		var decoded any
+		if err := g.Unmarshal(v, &decoded); err != nil {
+			return nil
+		}
+		val = decoded
+	case []byte:
+		var decoded any
+		if err := g.Unmarshal(string(v), &decoded); err != nil {
+			return nil
+		}
+		val = decoded
+	}
+
+	for _, key := range je.keys {
+		switch k := key.(type) {
+		case string:
+			obj, ok := val.(map[string]any)
+			if !ok {
+				return nil
+			}
+			if val, ok = obj[k]; !ok {
+				return nil
+			}
+		case int:
+			arr, ok := val.([]any)
+			if !ok || k >= len(arr) {
+				return nil
+			}
+			val = arr[k]
+		}
+	}
+
+	switch val.(type) {
+	case map[string]any, []any:
+		return g.Marshal(val)
+	case float64:
+		// avoid the exponent format of large integers
+		return cast.ToString(val)
+	}
+	return val
+}
+
+// jsonExtractor sets the extracted columns of a row
+type jsonExtractor struct {
+	extracts []JSONExtract
+	from     []int  // indexes of the JSON columns
+	index    []int  // indexes of the extracted columns
+	pushed   []bool // whether extracted in the source query, only to cast
+}
+
+// newJSONExtractor returns the extractor of the rules, appending the extracted columns
+func (ds *Datastream) newJSONExtractor(extracts []JSONExtract) (je *jsonExtractor, err error) {
+	je = &jsonExtractor{}
+	for _, extract := range extracts {
+		if err = extract.Validate(); err != nil {
+			return nil, err
+		}
+
+		// already extracted in the source query (pushed down), as text
+		if col := ds.Columns.GetColumn(extract.As); col != nil {
+			ds.Columns[col.Position-1].Type = extract.Type
+			je.extracts = append(je.extracts, extract)
+			je.from = append(je.from, col.Position-1)
+			je.index = append(je.index, col.Position-1)
+			je.pushed = append(je.pushed, true)
+			continue
+		}
+
+		from := ds.Columns.GetColumn(extract.From)
+		if from == nil {
+			return nil, g.Error("JSON extract column %s not found", extract.From)
+		}
+
+		col := Column{
+			Name:        extract.As,
+			Type:        extract.Type,
+			Position:    len(ds.Columns) + 1,
+			Description: "Sling.JSONExtract",
+		}
+		ds.Columns = append(ds.Columns, col)
+
+		je.extracts = append(je.extracts, extract)
+		je.from = append(je.from, from.Position-1)
+		je.index = append(je.index, col.Position-1)
+		je.pushed = append(je.pushed, false)
+	}
+
+	return je, nil
+}
+
+// Set sets the extracted column values of the row
+func (je *jsonExtractor) Set(row []any) []any {
+	for i, extract := range je.extracts {
+		for len(row) <= je.index[i] {
+			row = append(row, nil)
+		}
+		var val any
+		if je.from[i] < len(row) {
+			val = row[je.from[i]]
+		}
+		if !je.pushed[i] {
+			val = extract.Extract(val)
+		}
+		row[je.index[i]] = castExtracted(val, extract.Type)
+	}
+	return row
+}
+
+// castExtracted casts the extracted value to the type, nil if not castable
+func castExtracted(val any, typ ColumnType) any {
+	if val == nil {
+		return nil
+	}
+
+	var err error
+	switch {
+	case typ.IsString(), typ.IsDecimal():
+		return cast.ToString(val)
+	case typ.IsInteger():
+		if val, err = cast.ToInt64E(val); err != nil {
+			return nil
+		}
+	case typ.IsFloat():
+		if val, err = cast.ToFloat64E(val); err != nil {
+			return nil
+		}
+	case typ.IsBool():
+		if val, err = cast.ToBoolE(val); err != nil {
+			return nil
+		}
+	case typ.IsDatetime(), typ.IsDate():
+		if val, err = cast.ToTimeE(val); err != nil {
+			return nil
+		}
+	default:
+		return cast.ToString(val)
+	}
+	return val
+}
@@ -0,0 +1,86 @@
+package iop
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	keys, err := ParseJSONPath("$.user.id")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []any{"user", "id"}, keys)
+	}
+
+	keys, err = ParseJSONPath(`$.items[0]["first name"]['x-y']`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []any{"items", 0, "first name", "x-y"}, keys)
+	}
+
+	for _, path := range []string{"user.id", "$", "$.items[a]", `$.user'`, "$..id"} {
+		_, err = ParseJSONPath(path)
+		assert.Error(t, err, path)
+	}
+
+	extract := JSONExtract{From: "payload", Path: `$.items[0]["first name"]`, As: "name"}
+	if assert.NoError(t, extract.Validate()) {
+		assert.Equal(t, `$.items[0]."first name"`, extract.PathSQL())
+		assert.Equal(t, StringType, extract.Type)
+	}
+	assert.Error(t, (&JSONExtract{From: "payload", Path: "$.id"}).Validate())
+	assert.Error(t, (&JSONExtract{From: "payload", Path: "$.id", As: "id", Type: "number"}).Validate())
+}
+
+func TestJSONExtractStream(t *testing.T) {
+	payload := strings.Join([]string{
+		`id,payload`,
+		`1,"{""user"": {""id"": 10, ""name"": ""alice""}, ""tags"": [""a"", ""b""], ""at"": ""2024-01-02T03:04:05Z""}"`,
+		`2,"{""user"": {""id"": ""x""}}"`,
+		`3,not json`,
+		`4,`,
+	}, "\n")
+
+	extracts := []JSONExtract{
+		{From: "payload", Path: "$.user.id", As: "user_id", Type: BigIntType},
+		{From: "PAYLOAD", Path: "$.user.name", As: "user_name"},
+		{From: "payload", Path: "$.tags", As: "tags", Type: JsonType},
+		{From: "payload", Path: "$.tags[1]", As: "tag_2"},
+		{From: "payload", Path: "$.at", As: "at", Type: TimestampType},
+	}
+
+	ds := NewDatastream(nil)
+	ds.SetConfig(map[string]string{"extract": g.Marshal(extracts)})
+	if !assert.NoError(t, ds.ConsumeCsvReader(strings.NewReader(payload))) {
+		return
+	}
+	data, err := ds.Collect(0)
+	if !assert.NoError(t, err) || !assert.Len(t, data.Rows, 4) {
+		return
+	}
+
+	assert.Equal(t, []string{"id", "payload", "user_id", "user_name", "tags", "tag_2", "at"}, data.Columns.Names())
+	assert.Equal(t, BigIntType, data.Columns[2].Type)
+	assert.Equal(t, TimestampType, data.Columns[6].Type)
+
+	row := data.Rows[0]
+	assert.EqualValues(t, 10, row[2])
+	assert.Equal(t, "alice", row[3])
+	assert.Equal(t, `["a","b"]`, row[4])
+	assert.Equal(t, "b", row[5])
+	assert.Equal(t, "2024-01-02T03:04:05Z", cast.ToTime(row[6]).UTC().Format(time.RFC3339))
+
+	// not castable, not json, or empty: null
+	for _, row := range data.Rows[1:] {
+		assert.Nil(t, row[2])
+		assert.Nil(t, row[3])
+	}
+
+	// missing source column
+	ds = NewDatastream(nil)
+	ds.SetConfig(map[string]string{"extract": g.Marshal([]JSONExtract{{From: "missing", Path: "$.id", As: "id_"}})})
+	assert.Error(t, ds.ConsumeCsvReader(strings.NewReader(payload)))
+}
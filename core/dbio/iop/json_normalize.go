@@ -0,0 +1,344 @@
+package iop
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/flarco/g"
+	"github.com/nqd/flat"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+)
+
+// NormalizeConfig is the config to split the arrays of objects of nested JSON
+// records into child datasets, instead of flattening them as JSON strings
+type NormalizeConfig struct {
+	Keys   []string `json:"keys"`             // the primary key of the parent records
+	Depth  int      `json:"depth,omitempty"`  // the levels of nested arrays split, default 1
+	Arrays []string `json:"arrays,omitempty"` // the arrays split (e.g. `items`, `items__tags`), default all
+}
+
+// NormalizeIndexColumn is the column of the child rows holding the position in the array
+const NormalizeIndexColumn = "_index"
+
+// NormalizeParentPrefix prefixes the parent primary key columns in the child rows
+const NormalizeParentPrefix = "parent_"
+
+// normalizeKey is a key column of a row, passed down to its children
+type normalizeKey struct {
+	name  string
+	value any
+}
+
+// normalizer splits the arrays of objects of the records into child rows,
+// keyed by the array path (nested paths joined with `__`, as when flattened)
+type normalizer struct {
+	config   NormalizeConfig
+	children map[string]*normalizedChild
+	err      error // the first error spilling the child rows
+	mux      sync.Mutex
+}
+
+// normalizedChild holds the child rows of an array path: spilled to disk as
+// they are split, with a sample kept in memory to infer the column types
+type normalizedChild struct {
+	columns Columns
+	colMap  map[string]int // column name -> index
+	sample  [][]any
+	spill   *SpillFile
+}
+
+func newNormalizer(config NormalizeConfig) *normalizer {
+	if config.Depth < 1 {
+		config.Depth = 1
+	}
+	config.Arrays = lo.Map(config.Arrays, func(a string, i int) string {
+		return strings.ReplaceAll(strings.TrimSpace(a), ".", "__")
+	})
+
+	return &normalizer{
+		config:   config,
+		children: map[string]*normalizedChild{},
+	}
+}
+
+// split removes the arrays of objects from the record, adding their elements as child rows
+func (n *normalizer) split(rec map[string]any) map[string]any {
+	keys := make([]normalizeKey, len(n.config.Keys))
+	for i, key := range n.config.Keys {
+		keys[i] = normalizeKey{name: NormalizeParentPrefix + key, value: lookupKey(rec, key)}
+	}
+	return n.explode(rec, "", keys, 1)
+}
+
+// lookupKey returns the value of the key in the record, matching the
+// casing of the record field if not found as is
+func lookupKey(rec map[string]any, key string) any {
+	if val, ok := rec[key]; ok {
+		return val
+	}
+	for field, val := range rec {
+		if strings.EqualFold(field, key) {
+			return val
+		}
+	}
+	return nil
+}
+
+// explode splits the arrays of the record (at the level), returning a copy without them
+func (n *normalizer) explode(rec map[string]any, prefix string, keys []normalizeKey, level int) map[string]any {
+	newRec := make(map[string]any, len(rec))
+	for key, val := range rec {
+		path := prefix + key
+
+		// nested objects can hold arrays
+		if obj := toStringMap(val); obj != nil {
+			if newObj := n.explode(obj, path+"__", keys, level); len(newObj) > 0 || len(obj) == 0 {
+				newRec[key] = newObj
+			}
+			continue
+		}
+
+		elems, ok := n.splittable(path, val, level)
+		if !ok {
+			newRec[key] = val
+			continue
+		}
+
+		for i, elem := range elems {
+			child := toStringMap(elem)
+			if child == nil {
+				child = map[string]any{"value": elem}
+			}
+
+			// the key of the child row, for its own children
+			childKeys := append(append([]normalizeKey{}, keys...), normalizeKey{name: path + NormalizeIndexColumn, value: i})
+			child = n.explode(child, path+"__", childKeys, level+1)
+			n.addChild(path, keys, i, child)
+		}
+	}
+	return newRec
+}
+
+// splittable returns the elements if the value is an array of objects to split
+func (n *normalizer) splittable(path string, val any, level int) (elems []any, ok bool) {
+	if level > n.config.Depth {
+		return nil, false
+	} else if len(n.config.Arrays) > 0 && !g.In(path, n.config.Arrays...) {
+		return nil, false
+	}
+
+	value := reflect.ValueOf(val)
+	if val == nil || (value.Kind() != reflect.Slice && value.Kind() != reflect.Array) {
+		return nil, false
+	}
+
+	for i := 0; i < value.Len(); i++ {
+		elems = append(elems, value.Index(i).Interface())
+	}
+
+	// an empty array is split if already known as an array of objects
+	if len(elems) == 0 {
+		n.mux.Lock()
+		_, known := n.children[path]
+		n.mux.Unlock()
+		return nil, known
+	}
+
+	// only arrays of objects, arrays of values are kept as JSON
+	_, hasObject := lo.Find(elems, func(e any) bool { return toStringMap(e) != nil })
+	return elems, hasObject
+}
+
+// addChild adds the flattened child record to the rows of the path
+func (n *normalizer) addChild(path string, keys []normalizeKey, index int, child map[string]any) {
+	flatRec, _ := flat.Flatten(child, &flat.Options{Delimiter: "__", Safe: true})
+
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	nc, ok := n.children[path]
+	if !ok {
+		nc = &normalizedChild{colMap: map[string]int{}}
+		n.children[path] = nc
+	}
+
+	row := make([]any, len(nc.columns))
+	set := func(name string, val any) {
+		if arr, ok := val.([]any); ok {
+			val = g.Marshal(arr) // cast arrays as string
+		}
+
+		i, ok := nc.colMap[name]
+		if !ok {
+			i = len(nc.columns)
+			nc.colMap[name] = i
+			nc.columns = append(nc.columns, Column{Name: name, Position: i + 1}) // typed when inferred
+			row = append(row, nil)
+		}
+		row[i] = val
+	}
+
+	for _, key := range keys {
+		set(key.name, key.value)
+	}
+	set(NormalizeIndexColumn, index)
+
+	fields := lo.Keys(flatRec)
+	sort.Strings(fields)
+	for _, field := range fields {
+		set(field, flatRec[field])
+	}
+
+	if len(nc.sample) < SampleSize {
+		nc.sample = append(nc.sample, row)
+	}
+
+	if n.err != nil {
+		return
+	} else if nc.spill == nil {
+		if nc.spill, n.err = NewSpillFile("normalize"); n.err != nil {
+			return
+		}
+	}
+	n.err = nc.spill.Write(row)
+}
+
+// close removes the spill files of the child rows
+func (n *normalizer) close() {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	for _, nc := range n.children {
+		if nc.spill != nil {
+			nc.spill.Close()
+		}
+	}
+}
+
+// NormalizedStreams returns the streams of the child rows split from the JSON
+// records (option `normalize`), keyed by array path. See normalizedStreams.
+func (ds *Datastream) NormalizedStreams() (streams map[string]*Datastream, err error) {
+	return normalizedStreams(ds.Context.Ctx, []*Datastream{ds})
+}
+
+// normalizedStreams returns the streams of the child rows split by the datastreams,
+// keyed by array path. The child rows of the datastreams are merged by column name,
+// read back from the spill files, and cast with the types inferred from their samples.
+// The datastreams must be done reading.
+func normalizedStreams(ctx context.Context, dss []*Datastream) (streams map[string]*Datastream, err error) {
+	// the child rows of a path, from a datastream
+	type childPart struct {
+		child   *normalizedChild
+		indexes []int // the merged column index of each column of the child
+	}
+
+	parts := map[string][]childPart{}
+	columns := map[string]Columns{}
+	normalizers := []*normalizer{}
+	for _, ds := range dss {
+		n := ds.normalizer
+		if n == nil {
+			continue
+		} else if n.err != nil {
+			return nil, g.Error(n.err, "could not spill the normalized rows")
+		}
+		normalizers = append(normalizers, n)
+
+		for path, nc := range n.children {
+			part := childPart{child: nc, indexes: make([]int, len(nc.columns))}
+			for i, col := range nc.columns {
+				if mCol := columns[path].GetColumn(col.Name); mCol != nil {
+					part.indexes[i] = mCol.Position - 1
+				} else {
+					col.Position = len(columns[path]) + 1
+					columns[path] = append(columns[path], col)
+					part.indexes[i] = col.Position - 1
+				}
+			}
+			parts[path] = append(parts[path], part)
+		}
+	}
+
+	remap := func(part childPart, row []any, width int) []any {
+		newRow := make([]any, width)
+		for i, val := range row {
+			newRow[part.indexes[i]] = val
+		}
+		return newRow
+	}
+
+	streams = map[string]*Datastream{}
+	for path, pathParts := range parts {
+		cols := columns[path]
+
+		// infer the column types from the samples
+		sample := NewDataset(cols)
+		for _, part := range pathParts {
+			for _, row := range part.child.sample {
+				sample.Rows = append(sample.Rows, remap(part, row, len(cols)))
+			}
+		}
+		sample.InferColumnTypes()
+		cols = sample.Columns
+
+		pathParts := pathParts
+		nextFunc := func(it *Iterator) bool {
+			for len(pathParts) > 0 {
+				row, err := pathParts[0].child.spill.Read()
+				if err == io.EOF {
+					pathParts[0].child.spill.Close()
+					pathParts = pathParts[1:]
+					continue
+				} else if err != nil {
+					it.Context.CaptureErr(g.Error(err, "could not read the normalized rows of %s", path))
+					return false
+				}
+
+				it.Row = remap(pathParts[0], row, len(cols))
+				for j, val := range it.Row {
+					it.Row[j] = castExtracted(val, cols[j].Type)
+				}
+				return true
+			}
+			return false
+		}
+
+		ds := NewDatastreamIt(ctx, cols, nextFunc)
+		ds.it.IsCasted = true
+		ds.Inferred = true
+		for _, part := range pathParts {
+			ds.Defer(func() { part.child.spill.Close() }) // removed once read
+		}
+		streams[path] = ds
+	}
+
+	for path, ds := range streams {
+		if err = ds.Start(); err != nil {
+			for _, n := range normalizers {
+				n.close()
+			}
+			return nil, g.Error(err, "could not start the stream of the normalized rows of %s", path)
+		}
+	}
+
+	return streams, nil
+}
+
+// toStringMap returns the value as a map with string keys, or nil if not an object
+func toStringMap(val any) map[string]any {
+	switch v := val.(type) {
+	case map[string]any:
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, val := range v {
+			m[cast.ToString(k)] = val
+		}
+		return m
+	}
+	return nil
+}
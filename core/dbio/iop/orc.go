@@ -0,0 +1,317 @@
+package iop
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/scritchley/orc"
+	"github.com/spf13/cast"
+)
+
+// ORC is an orc` object
+type ORC struct {
+	Path   string
+	Reader *orc.Reader
+	cursor *orc.Cursor
+	cols   Columns
+}
+
+// orcSizedReader makes a ReaderAt sized, as needed by the orc reader
+type orcSizedReader struct {
+	io.ReaderAt
+	size int64
+}
+
+func (r *orcSizedReader) Size() int64 {
+	return r.size
+}
+
+// NewORCStream creates an orc stream from a seekable reader
+func NewORCStream(reader io.ReadSeeker, columns Columns) (o *ORC, err error) {
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, g.Error(err, "could not determine orc file size")
+	} else if _, err = reader.Seek(0, io.SeekStart); err != nil {
+		return nil, g.Error(err, "could not seek orc file")
+	}
+
+	readerAt, ok := reader.(io.ReaderAt)
+	if !ok {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, g.Error(err, "could not read orc file")
+		}
+		readerAt = bytes.NewReader(data)
+	}
+
+	or, err := orc.NewReader(&orcSizedReader{ReaderAt: readerAt, size: size})
+	if err != nil {
+		return nil, g.Error(err, "could not read orc reader")
+	}
+
+	o = &ORC{Reader: or}
+	o.cols = o.Columns()
+	o.cursor = or.Select(o.cols.Names()...)
+
+	return
+}
+
+// Columns returns the columns of the orc schema
+func (o *ORC) Columns() Columns {
+	schema := o.Reader.Schema()
+	fields := schema.Columns()
+	types := schema.Types()
+
+	cols := NewColumnsFromFields(fields...)
+	for i := range cols {
+		if i >= len(types) {
+			break
+		}
+		cols[i].Type, cols[i].DbPrecision, cols[i].DbScale = orcColumnType(types[i].String())
+		cols[i].DbType = types[i].String()
+		cols[i].Sourced = true
+	}
+
+	return cols
+}
+
+// orcColumnType maps an orc type (e.g. `decimal(10,2)`) to a column type
+func orcColumnType(orcType string) (colType ColumnType, precision, scale int) {
+	orcType = strings.ToLower(strings.TrimSpace(orcType))
+	name, args, _ := strings.Cut(orcType, "(")
+
+	switch {
+	case strings.HasPrefix(orcType, "struct"), strings.HasPrefix(orcType, "array"),
+		strings.HasPrefix(orcType, "map"), strings.HasPrefix(orcType, "uniontype"):
+		return JsonType, 0, 0
+	}
+
+	switch name {
+	case "boolean":
+		colType = BoolType
+	case "tinyint", "smallint":
+		colType = SmallIntType
+	case "int":
+		colType = IntegerType
+	case "bigint":
+		colType = BigIntType
+	case "float", "double":
+		colType = FloatType
+	case "decimal":
+		colType = DecimalType
+		precisionStr, scaleStr, _ := strings.Cut(strings.TrimSuffix(args, ")"), ",")
+		precision, scale = cast.ToInt(precisionStr), cast.ToInt(scaleStr)
+	case "date":
+		colType = DateType
+	case "timestamp":
+		colType = DatetimeType
+	case "binary":
+		colType = BinaryType
+	default: // string, char, varchar
+		colType = StringType
+	}
+
+	return colType, precision, scale
+}
+
+func (o *ORC) nextFunc(it *Iterator) bool {
+	for !o.cursor.Next() {
+		if err := o.cursor.Err(); err != nil {
+			it.Context.CaptureErr(g.Error(err, "could not read ORC row"))
+			return false
+		} else if !o.cursor.Stripes() {
+			if err := o.cursor.Err(); err != nil {
+				it.Context.CaptureErr(g.Error(err, "could not read ORC stripe"))
+			}
+			return false
+		}
+	}
+
+	row := o.cursor.Row()
+	it.Row = make([]any, len(it.ds.Columns))
+	for i, val := range row {
+		if i >= len(it.Row) {
+			break
+		}
+		it.Row[i] = orcValue(val, it.ds.Columns[i])
+	}
+
+	return true
+}
+
+// orcValue converts an orc value to a stream value
+func orcValue(val any, col Column) any {
+	switch v := val.(type) {
+	case nil:
+		return nil
+	case orc.Decimal:
+		if v.Int == nil {
+			return nil
+		}
+		return decimalString(v.Int, int(v.Scale))
+	case time.Time:
+		return v
+	case interface{ UTC() time.Time }: // dates
+		return v.UTC()
+	case []byte:
+		if col.Type == BinaryType {
+			return v
+		}
+		return string(v)
+	}
+
+	if col.Type == JsonType {
+		return g.Marshal(val)
+	}
+	return val
+}
+
+// decimalString formats an unscaled integer with the scale, e.g. 12345 & 2 => 123.45
+func decimalString(unscaled *big.Int, scale int) string {
+	if scale <= 0 {
+		return unscaled.String()
+	}
+	return new(big.Rat).SetFrac(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)).FloatString(scale)
+}
+
+// decimalUnscaled returns the unscaled integer of the value at the scale,
+// rounded half away from zero, e.g. 1.235 & 2 => 124
+func decimalUnscaled(rat *big.Rat, scale int) *big.Int {
+	scaled := new(big.Rat).Mul(rat, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+	unscaled, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(scaled.Denom()) >= 0 {
+		unscaled.Add(unscaled, big.NewInt(int64(scaled.Num().Sign())))
+	}
+	return unscaled
+}
+
+// Close closes the orc cursor
+func (o *ORC) Close() error {
+	if o.cursor != nil {
+		return o.cursor.Close()
+	}
+	return nil
+}
+
+// ORCWriter writes rows as an orc file
+type ORCWriter struct {
+	Writer  *orc.Writer
+	columns Columns
+}
+
+// NewORCWriter creates an orc writer, with the schema derived from the columns
+func NewORCWriter(w io.Writer, columns Columns, compression CompressorType) (ow *ORCWriter, err error) {
+	columns = columns.Clone()
+
+	fields := make([]string, len(columns))
+	for i, col := range columns {
+		if strings.ContainsAny(col.Name, " ,:<>`\"") {
+			return nil, g.Error("column name `%s` is not valid for ORC", col.Name)
+		}
+		fields[i] = col.Name + ":" + orcSchemaType(&columns[i])
+	}
+
+	schema, err := orc.ParseSchema("struct<" + strings.Join(fields, ",") + ">")
+	if err != nil {
+		return nil, g.Error(err, "could not create orc schema")
+	}
+
+	var codec orc.CompressionCodec
+	switch compression {
+	case SnappyCompressorType:
+		codec = orc.CompressionSnappy{}
+	case GzipCompressorType, AutoCompressorType, "zlib", "":
+		codec = orc.CompressionZlib{Level: flate.DefaultCompression}
+	case NoneCompressorType:
+		codec = orc.CompressionNone{}
+	case ZStandardCompressorType:
+		return nil, g.Error("zstd compression is not supported for ORC files (use zlib or snappy)")
+	default:
+		return nil, g.Error("invalid compression for ORC files: %s", compression)
+	}
+
+	writer, err := orc.NewWriter(w, orc.SetSchema(schema), orc.SetCompression(codec))
+	if err != nil {
+		return nil, g.Error(err, "could not create orc writer")
+	}
+
+	return &ORCWriter{Writer: writer, columns: columns}, nil
+}
+
+// orcSchemaType returns the orc type of the column
+func orcSchemaType(col *Column) string {
+	switch {
+	case col.IsBool():
+		return "boolean"
+	case col.Type == SmallIntType:
+		return "smallint"
+	case col.Type == IntegerType:
+		return "int"
+	case col.IsInteger():
+		return "bigint"
+	case col.Type == FloatType:
+		return "double"
+	case col.Type == DecimalType:
+		if !col.Sourced || col.DbPrecision == 0 || col.DbPrecision > 38 {
+			// keep the decimals seen in the values, instead of truncating them
+			col.DbPrecision = 38
+			col.DbScale = min(max(col.DbScale, col.Stats.MaxDecLen, 9), 38)
+		}
+		return g.F("decimal(%d,%d)", col.DbPrecision, col.DbScale)
+	case col.IsDatetime() || col.IsDate():
+		return "timestamp"
+	case col.Type == BinaryType:
+		return "binary"
+	}
+	return "string"
+}
+
+// WriteRow writes a row
+func (ow *ORCWriter) WriteRow(row []any) error {
+	values := make([]any, len(ow.columns))
+	for i, col := range ow.columns {
+		if i >= len(row) || row[i] == nil {
+			continue
+		}
+
+		switch {
+		case col.IsBool():
+			values[i] = cast.ToBool(row[i])
+		case col.IsInteger():
+			values[i] = cast.ToInt64(row[i])
+		case col.Type == FloatType:
+			values[i] = cast.ToFloat64(row[i])
+		case col.Type == DecimalType:
+			rat, ok := new(big.Rat).SetString(cast.ToString(row[i]))
+			if !ok {
+				return g.Error("invalid decimal value for %s: %v", col.Name, row[i])
+			}
+			values[i] = orc.Decimal{Int: decimalUnscaled(rat, col.DbScale), Scale: int64(col.DbScale)}
+		case col.IsDatetime() || col.IsDate():
+			t, err := cast.ToTimeE(row[i])
+			if err != nil {
+				return g.Error(err, "invalid timestamp value for %s: %v", col.Name, row[i])
+			}
+			values[i] = t
+		case col.Type == BinaryType:
+			values[i] = []byte(cast.ToString(row[i]))
+		default:
+			values[i] = cast.ToString(row[i])
+		}
+	}
+
+	if err := ow.Writer.Write(values...); err != nil {
+		return g.Error(err, "could not write orc row")
+	}
+	return nil
+}
+
+// Close flushes the stripes and footer
+func (ow *ORCWriter) Close() error {
+	return ow.Writer.Close()
+}
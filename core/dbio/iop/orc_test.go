@@ -0,0 +1,134 @@
+package iop
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestORCRoundTrip(t *testing.T) {
+	cols := NewColumns(
+		Column{Name: "id", Type: BigIntType},
+		Column{Name: "name", Type: StringType},
+		Column{Name: "active", Type: BoolType},
+		Column{Name: "rating", Type: FloatType},
+		Column{Name: "amount", Type: DecimalType, DbPrecision: 10, DbScale: 2, Sourced: true},
+		Column{Name: "created_at", Type: DatetimeType},
+	)
+
+	ts := time.Date(2024, 3, 1, 12, 30, 45, 0, time.UTC)
+	rows := [][]any{
+		{int64(1), "alice", true, 4.5, "1234.56", ts},
+		{int64(2), "bob", false, 3.25, "-0.01", ts.Add(time.Hour)},
+		{int64(3), nil, nil, nil, nil, nil},
+	}
+
+	for _, compression := range []CompressorType{NoneCompressorType, GzipCompressorType, SnappyCompressorType} {
+		buf := &bytes.Buffer{}
+		ow, err := NewORCWriter(buf, cols, compression)
+		if !assert.NoError(t, err, compression) {
+			return
+		}
+		for _, row := range rows {
+			assert.NoError(t, ow.WriteRow(row))
+		}
+		if !assert.NoError(t, ow.Close()) {
+			return
+		}
+
+		ds := NewDatastream(Columns{})
+		if !assert.NoError(t, ds.ConsumeOrcReaderSeeker(bytes.NewReader(buf.Bytes())), compression) {
+			return
+		}
+
+		// types are mapped from the orc schema
+		assert.Equal(t, BigIntType, ds.Columns[0].Type)
+		assert.Equal(t, StringType, ds.Columns[1].Type)
+		assert.Equal(t, BoolType, ds.Columns[2].Type)
+		assert.Equal(t, FloatType, ds.Columns[3].Type)
+		assert.Equal(t, DecimalType, ds.Columns[4].Type)
+		assert.Equal(t, 10, ds.Columns[4].DbPrecision)
+		assert.Equal(t, 2, ds.Columns[4].DbScale)
+		assert.Equal(t, DatetimeType, ds.Columns[5].Type)
+
+		data, err := ds.Collect(0)
+		if !assert.NoError(t, err) || !assert.Len(t, data.Rows, 3) {
+			return
+		}
+
+		assert.EqualValues(t, 1, cast.ToInt(data.Rows[0][0]))
+		assert.Equal(t, "alice", data.Rows[0][1])
+		assert.Equal(t, true, cast.ToBool(data.Rows[0][2]))
+		assert.Equal(t, 4.5, cast.ToFloat64(data.Rows[0][3]))
+		assert.Equal(t, "1234.56", cast.ToString(data.Rows[0][4]))
+		assert.Equal(t, "-0.01", cast.ToString(data.Rows[1][4]))
+		assert.True(t, ts.Equal(cast.ToTime(data.Rows[0][5])))
+
+		for _, val := range data.Rows[2][1:] {
+			assert.Nil(t, val)
+		}
+	}
+
+	// unsupported codec
+	_, err := NewORCWriter(&bytes.Buffer{}, cols, ZStandardCompressorType)
+	assert.Error(t, err)
+}
+
+func TestORCDecimals(t *testing.T) {
+	// not sourced: the scale covers the decimals of the values
+	col := Column{Name: "amount", Type: DecimalType}
+	col.Stats.MaxDecLen = 12
+	cols := NewColumns(col)
+
+	buf := &bytes.Buffer{}
+	ow, err := NewORCWriter(buf, cols, NoneCompressorType)
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	assert.NoError(t, ow.WriteRow([]any{"1.123456789012"}))
+	assert.NoError(t, ow.WriteRow([]any{"-98765432109876.5"}))
+	if !g.AssertNoError(t, ow.Close()) {
+		return
+	}
+
+	ds := NewDatastream(Columns{})
+	if !g.AssertNoError(t, ds.ConsumeOrcReaderSeeker(bytes.NewReader(buf.Bytes()))) {
+		return
+	}
+	assert.Equal(t, DecimalType, ds.Columns[0].Type)
+	assert.Equal(t, 38, ds.Columns[0].DbPrecision)
+	assert.Equal(t, 12, ds.Columns[0].DbScale)
+
+	data, err := ds.Collect(0)
+	if g.AssertNoError(t, err) && assert.Len(t, data.Rows, 2) {
+		assert.Equal(t, "1.123456789012", cast.ToString(data.Rows[0][0]))
+		assert.Equal(t, "-98765432109876.500000000000", cast.ToString(data.Rows[1][0]))
+	}
+
+	// values with more decimals than the scale are rounded, not truncated
+	for val, expected := range map[string]string{"0.125": "13", "-0.125": "-13", "1.2349": "123", "-0.004": "0"} {
+		rat, _ := new(big.Rat).SetString(val)
+		assert.Equal(t, expected, decimalUnscaled(rat, 2).String(), val)
+	}
+}
+
+func TestORCColumnType(t *testing.T) {
+	typ, precision, scale := orcColumnType("decimal(18,4)")
+	assert.Equal(t, DecimalType, typ)
+	assert.Equal(t, 18, precision)
+	assert.Equal(t, 4, scale)
+
+	typ, _, _ = orcColumnType("timestamp")
+	assert.Equal(t, DatetimeType, typ)
+	typ, _, _ = orcColumnType("date")
+	assert.Equal(t, DateType, typ)
+	typ, _, _ = orcColumnType("array<int>")
+	assert.Equal(t, JsonType, typ)
+	typ, _, _ = orcColumnType("varchar(20)")
+	assert.Equal(t, StringType, typ)
+}
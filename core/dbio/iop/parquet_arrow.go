@@ -16,6 +16,7 @@ import (
 	"github.com/apache/arrow/go/v16/parquet"
 	"github.com/apache/arrow/go/v16/parquet/compress"
 	"github.com/apache/arrow/go/v16/parquet/file"
+	"github.com/apache/arrow/go/v16/parquet/metadata"
 	"github.com/apache/arrow/go/v16/parquet/schema"
 	"github.com/flarco/g"
 	"github.com/samber/lo"
@@ -33,6 +34,10 @@ type ParquetArrowReader struct {
 	colMap             map[string]int
 	nextRow            chan nextRow
 	done               bool
+
+	filters         []FilterCondition
+	filterIndices   []int // index of the filter column in the selected columns
+	PrunedRowGroups []int // row groups skipped from the filters statistics
 }
 
 type nextRow struct {
@@ -40,7 +45,7 @@ type nextRow struct {
 	err error
 }
 
-func NewParquetArrowReader(reader *os.File, selected []string) (p *ParquetArrowReader, err error) {
+func NewParquetArrowReader(reader *os.File, selected []string, filters ...FilterCondition) (p *ParquetArrowReader, err error) {
 	ctx := g.NewContext(context.Background())
 
 	// recover from panic
@@ -75,6 +80,16 @@ func NewParquetArrowReader(reader *os.File, selected []string) (p *ParquetArrowR
 		}
 	}
 
+	// filters apply to the selected columns
+	for _, filter := range filters {
+		index, found := p.Columns().FieldMap(true)[strings.ToLower(filter.Key)]
+		if !found {
+			return p, g.Error("where column '%s' not found (or not selected)", filter.Key)
+		}
+		p.filters = append(p.filters, filter)
+		p.filterIndices = append(p.filterIndices, index)
+	}
+
 	go p.readRowsLoop()
 
 	return
@@ -221,6 +236,12 @@ func (p *ParquetArrowReader) readRowsLoop() {
 		rowGroup := p.Reader.RowGroup(r)
 		rowGroupMeta := rowGroup.MetaData()
 
+		if !p.rowGroupMatches(rowGroupMeta, columns) {
+			g.DebugLow("pruned parquet row group %d (%d rows) from where statistics", r, rowGroupMeta.NumRows())
+			p.PrunedRowGroups = append(p.PrunedRowGroups, r)
+			continue
+		}
+
 		scanners := make([]*ParquetArrowDumper, len(p.selectedColIndices))
 		fields := make([]string, len(p.selectedColIndices))
 
@@ -275,6 +296,8 @@ func (p *ParquetArrowReader) readRowsLoop() {
 
 			if done {
 				break
+			} else if !p.rowMatches(row) {
+				continue
 			}
 
 			count++
@@ -288,6 +311,74 @@ func (p *ParquetArrowReader) readRowsLoop() {
 	p.Reader.Close()
 }
 
+// rowGroupMatches returns false if the row group statistics show that
+// no row can match the filters. Only plain numeric and string columns
+// are pruned on, since stats of other types are not comparable with the values.
+func (p *ParquetArrowReader) rowGroupMatches(rowGroupMeta *metadata.RowGroupMetaData, columns Columns) bool {
+	for i, filter := range p.filters {
+		col := columns[p.filterIndices[i]]
+		colI := p.selectedColIndices[p.filterIndices[i]]
+
+		_, numErr := cast.ToFloat64E(filter.Value)
+		switch {
+		case col.IsInteger() || col.Type == FloatType:
+			if numErr != nil {
+				continue
+			}
+		case col.Type == StringType:
+			if numErr == nil {
+				continue // numeric strings are not ordered as numbers
+			}
+		default:
+			continue
+		}
+
+		chunkMeta, err := rowGroupMeta.ColumnChunk(colI)
+		if err != nil {
+			continue
+		}
+		if ok, err := chunkMeta.StatsSet(); !ok || err != nil {
+			continue
+		}
+		stats, err := chunkMeta.Statistics()
+		if err != nil || stats == nil || !stats.HasMinMax() {
+			continue
+		}
+
+		var min, max any
+		switch s := stats.(type) {
+		case *metadata.Int32Statistics:
+			min, max = s.Min(), s.Max()
+		case *metadata.Int64Statistics:
+			min, max = s.Min(), s.Max()
+		case *metadata.Float32Statistics:
+			min, max = s.Min(), s.Max()
+		case *metadata.Float64Statistics:
+			min, max = s.Min(), s.Max()
+		case *metadata.ByteArrayStatistics:
+			min, max = s.Min().String(), s.Max().String()
+		default:
+			continue
+		}
+
+		if !filter.MatchRange(cast.ToString(min), cast.ToString(max)) {
+			return false
+		}
+	}
+	return true
+}
+
+// rowMatches returns true if the row satisfies all filters. Nulls never match.
+func (p *ParquetArrowReader) rowMatches(row []any) bool {
+	for i, filter := range p.filters {
+		val := row[p.filterIndices[i]]
+		if val == nil || !filter.Match(cast.ToString(val)) {
+			return false
+		}
+	}
+	return true
+}
+
 func (p *ParquetArrowReader) nextFunc(it *Iterator) bool {
 
 retry:
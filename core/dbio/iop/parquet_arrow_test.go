@@ -361,3 +361,104 @@ func (dump *Dumper) Next() (interface{}, bool) {
 
 	return v, true
 }
+
+func TestParquetArrowFilters(t *testing.T) {
+	folder, err := os.MkdirTemp("", "sling_parquet_filters")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(folder)
+
+	f, err := os.Create(folder + "/data.parquet")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	columns := NewColumns(
+		Column{Name: "id", Type: BigIntType},
+		Column{Name: "code", Type: StringType},
+	)
+	pw, err := NewParquetArrowWriter(f, columns, compress.Codecs.Snappy)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// 3 row groups: ids 1-10 (code a), 11-20 (code b), 21-30 (code c)
+	for group, code := range []string{"a", "b", "c"} {
+		if group > 0 {
+			assert.NoError(t, pw.writeBuffer())
+			assert.NoError(t, pw.AppendNewRowGroup())
+		}
+		for i := 1; i <= 10; i++ {
+			assert.NoError(t, pw.WriteRow([]any{int64(group*10 + i), code}))
+		}
+	}
+	assert.NoError(t, pw.Close())
+	f.Close()
+
+	read := func(where string) (p *ParquetArrowReader, rows [][]any) {
+		filters, err := ParseFilterConditions(where)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		f, err := os.Open(folder + "/data.parquet")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer f.Close()
+
+		p, err = NewParquetArrowReader(f, nil, filters...)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		ds := NewDatastream(p.Columns())
+		it := ds.NewIterator(ds.Columns, p.nextFunc)
+		for p.nextFunc(it) {
+			rows = append(rows, it.Row)
+		}
+		return
+	}
+
+	p, rows := read("id > 15")
+	if assert.NotNil(t, p) {
+		assert.Equal(t, []int{0}, p.PrunedRowGroups)
+		assert.Len(t, rows, 15) // rows of the matching group are filtered too
+	}
+
+	p, rows = read("code = c")
+	if assert.NotNil(t, p) {
+		assert.Equal(t, []int{0, 1}, p.PrunedRowGroups)
+		assert.Len(t, rows, 10)
+	}
+
+	p, rows = read("id >= 5 and id <= 12")
+	if assert.NotNil(t, p) {
+		assert.Equal(t, []int{2}, p.PrunedRowGroups)
+		assert.Len(t, rows, 8)
+	}
+
+	// no stats pruning of a numeric value on a string column, rows still filtered
+	p, rows = read("code != 1")
+	if assert.NotNil(t, p) {
+		assert.Empty(t, p.PrunedRowGroups)
+		assert.Len(t, rows, 30)
+	}
+
+	f, _ = os.Open(folder + "/data.parquet")
+	defer f.Close()
+	_, err = NewParquetArrowReader(f, nil, FilterCondition{Key: "missing", Operator: "=", Value: "1"})
+	assert.Error(t, err)
+}
+
+func TestFilterConditionMatchRange(t *testing.T) {
+	cond := func(op, val string) FilterCondition { return FilterCondition{Key: "k", Operator: op, Value: val} }
+	assert.True(t, cond("=", "5").MatchRange("1", "10"))
+	assert.False(t, cond("=", "11").MatchRange("1", "10"))
+	assert.False(t, cond(">", "10").MatchRange("1", "10"))
+	assert.True(t, cond(">=", "10").MatchRange("1", "10"))
+	assert.False(t, cond("<", "1").MatchRange("1", "10"))
+	assert.False(t, cond("!=", "3").MatchRange("3", "3"))
+	assert.True(t, cond("<=", "b").MatchRange("a", "c"))
+}
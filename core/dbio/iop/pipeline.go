@@ -0,0 +1,277 @@
+package iop
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+)
+
+// PipelineStepType is the type of a pipeline step
+type PipelineStepType string
+
+const (
+	PipelineStepRename  PipelineStepType = "rename"
+	PipelineStepCast    PipelineStepType = "cast"
+	PipelineStepHash    PipelineStepType = "hash"
+	PipelineStepMask    PipelineStepType = "mask"
+	PipelineStepCompute PipelineStepType = "compute"
+	PipelineStepFilter  PipelineStepType = "filter"
+)
+
+// PipelineStepTypes are the valid pipeline step types
+var PipelineStepTypes = []PipelineStepType{
+	PipelineStepRename, PipelineStepCast, PipelineStepHash,
+	PipelineStepMask, PipelineStepCompute, PipelineStepFilter,
+}
+
+// PipelineStep is a row transform, applied in order with the other steps of the pipeline:
+//
+//	{type: rename, column: fname, as: first_name}
+//	{type: cast, column: amount, to: decimal}
+//	{type: hash, columns: [email], algorithm: sha256}
+//	{type: mask, columns: [phone], keep: 4}
+//	{type: compute, as: full_name, expr: "{first_name} {last_name}"}
+//	{type: filter, expr: "amount > 0 and status != cancelled"}
+type PipelineStep struct {
+	Type      PipelineStepType `json:"type" yaml:"type"`
+	Column    string           `json:"column,omitempty" yaml:"column,omitempty"`
+	Columns   []string         `json:"columns,omitempty" yaml:"columns,omitempty"`
+	As        string           `json:"as,omitempty" yaml:"as,omitempty"`               // rename: new name, compute: new column
+	To        ColumnType       `json:"to,omitempty" yaml:"to,omitempty"`               // cast & compute: the type
+	Expr      string           `json:"expr,omitempty" yaml:"expr,omitempty"`           // compute: template, filter: conditions
+	Algorithm string           `json:"algorithm,omitempty" yaml:"algorithm,omitempty"` // hash: md5 (default), sha256 or sha512
+	Keep      int              `json:"keep,omitempty" yaml:"keep,omitempty"`           // mask: number of trailing characters kept
+
+	conditions []FilterCondition
+}
+
+var pipelineExprRegex = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// targets returns the columns the step applies to
+func (ps *PipelineStep) targets() (names []string) {
+	if ps.Column != "" {
+		names = append(names, ps.Column)
+	}
+	return append(names, ps.Columns...)
+}
+
+// Validate checks the step and its params
+func (ps *PipelineStep) Validate() (err error) {
+	switch ps.Type {
+	case PipelineStepRename:
+		if ps.Column == "" || ps.As == "" {
+			return g.Error("pipeline step rename needs the `column` and `as` params")
+		}
+	case PipelineStepCast:
+		if ps.Column == "" || ps.To == "" {
+			return g.Error("pipeline step cast needs the `column` and `to` params")
+		} else if !ps.To.IsValid() {
+			return g.Error("invalid type %#v for pipeline step cast of %s", ps.To, ps.Column)
+		}
+	case PipelineStepHash:
+		if len(ps.targets()) == 0 {
+			return g.Error("pipeline step hash needs the `column` or `columns` param")
+		} else if !g.In(strings.ToLower(ps.Algorithm), "", "md5", "sha256", "sha512") {
+			return g.Error("invalid algorithm %#v for pipeline step hash, valid algorithms are: md5, sha256, sha512", ps.Algorithm)
+		}
+	case PipelineStepMask:
+		if len(ps.targets()) == 0 {
+			return g.Error("pipeline step mask needs the `column` or `columns` param")
+		} else if ps.Keep < 0 {
+			return g.Error("invalid value %d for `keep` of pipeline step mask", ps.Keep)
+		}
+	case PipelineStepCompute:
+		if ps.As == "" || ps.Expr == "" {
+			return g.Error("pipeline step compute needs the `as` and `expr` params")
+		}
+		if ps.To == "" {
+			ps.To = StringType
+		} else if !ps.To.IsValid() {
+			return g.Error("invalid type %#v for pipeline step compute of %s", ps.To, ps.As)
+		}
+	case PipelineStepFilter:
+		if strings.TrimSpace(ps.Expr) == "" {
+			return g.Error("pipeline step filter needs the `expr` param")
+		}
+		if ps.conditions, err = ParseFilterConditions(ps.Expr); err != nil {
+			return g.Error(err, "invalid expr for pipeline step filter")
+		}
+	default:
+		return g.Error("invalid pipeline step type %#v, valid types are: %s", ps.Type, g.Marshal(PipelineStepTypes))
+	}
+	return nil
+}
+
+// pipelineStep is a step resolved against the stream columns
+type pipelineStep struct {
+	step    PipelineStep
+	indexes []int    // indexes of the columns (or of the filter condition keys)
+	index   int      // index of the computed column
+	literal []string // compute: the literal parts around the column values
+}
+
+// pipeline applies the steps on the casted rows of a stream
+type pipeline struct {
+	steps    []pipelineStep
+	filtered uint64 // rows dropped by the filter steps
+}
+
+// newPipeline resolves the steps in order (so that a step can reference the
+// columns renamed or computed by a previous step), adjusting the columns
+func (ds *Datastream) newPipeline(steps []PipelineStep) (p *pipeline, err error) {
+	p = &pipeline{}
+
+	index := func(name string) (int, error) {
+		col := ds.Columns.GetColumn(name)
+		if col == nil {
+			return -1, g.Error("pipeline column %s not found", name)
+		}
+		return col.Position - 1, nil
+	}
+
+	for _, step := range steps {
+		if err = step.Validate(); err != nil {
+			return nil, err
+		}
+
+		ps := pipelineStep{step: step, index: -1}
+		switch step.Type {
+		case PipelineStepFilter:
+			for _, cond := range step.conditions {
+				i, err := index(cond.Key)
+				if err != nil {
+					return nil, err
+				}
+				ps.indexes = append(ps.indexes, i)
+			}
+		case PipelineStepCompute:
+			// split the template into literals & column values
+			parts := pipelineExprRegex.FindAllStringSubmatchIndex(step.Expr, -1)
+			last := 0
+			for _, part := range parts {
+				i, err := index(strings.TrimSpace(step.Expr[part[2]:part[3]]))
+				if err != nil {
+					return nil, err
+				}
+				ps.literal = append(ps.literal, step.Expr[last:part[0]])
+				ps.indexes = append(ps.indexes, i)
+				last = part[1]
+			}
+			ps.literal = append(ps.literal, step.Expr[last:])
+
+			if col := ds.Columns.GetColumn(step.As); col != nil {
+				ps.index = col.Position - 1
+				ds.Columns[ps.index].Type = step.To
+			} else {
+				col := Column{
+					Name:        step.As,
+					Type:        step.To,
+					Position:    len(ds.Columns) + 1,
+					Description: "Sling.Pipeline",
+				}
+				ds.Columns = append(ds.Columns, col)
+				ps.index = col.Position - 1
+			}
+		default:
+			for _, name := range step.targets() {
+				i, err := index(name)
+				if err != nil {
+					return nil, err
+				}
+				ps.indexes = append(ps.indexes, i)
+			}
+		}
+
+		// adjust the columns
+		for _, i := range ps.indexes {
+			switch step.Type {
+			case PipelineStepRename:
+				ds.Columns[i].Name = step.As
+			case PipelineStepCast:
+				ds.Columns[i].Type = step.To
+			case PipelineStepHash, PipelineStepMask:
+				ds.Columns[i].Type = StringType
+			}
+		}
+
+		p.steps = append(p.steps, ps)
+	}
+
+	return p, nil
+}
+
+// FilteredCount returns the count of rows dropped by the pipeline filters
+func (ds *Datastream) FilteredCount() uint64 {
+	if ds.pipeline == nil {
+		return 0
+	}
+	return ds.pipeline.filtered
+}
+
+// Apply applies the steps on the row. A nil row is returned if dropped by a filter.
+func (p *pipeline) Apply(row []any) []any {
+	value := func(i int) any {
+		if i < len(row) {
+			return row[i]
+		}
+		return nil
+	}
+
+	for _, ps := range p.steps {
+		switch ps.step.Type {
+		case PipelineStepFilter:
+			for j, cond := range ps.step.conditions {
+				if !cond.Match(cast.ToString(value(ps.indexes[j]))) {
+					p.filtered++
+					return nil
+				}
+			}
+		case PipelineStepCompute:
+			var sb strings.Builder
+			for j, literal := range ps.literal {
+				sb.WriteString(literal)
+				if j < len(ps.indexes) {
+					sb.WriteString(cast.ToString(value(ps.indexes[j])))
+				}
+			}
+			for len(row) <= ps.index {
+				row = append(row, nil)
+			}
+			row[ps.index] = castExtracted(sb.String(), ps.step.To)
+		case PipelineStepCast, PipelineStepHash, PipelineStepMask:
+			for _, i := range ps.indexes {
+				if i < len(row) && row[i] != nil {
+					row[i] = ps.apply(row[i])
+				}
+			}
+		}
+	}
+
+	return row
+}
+
+// apply transforms a non-null value with a cast, hash or mask step.
+// Values which cannot be cast are set to null.
+func (ps *pipelineStep) apply(val any) any {
+	switch ps.step.Type {
+	case PipelineStepCast:
+		return castExtracted(val, ps.step.To)
+	case PipelineStepHash:
+		switch strings.ToLower(ps.step.Algorithm) {
+		case "sha256":
+			return Transforms.SHA256(cast.ToString(val))
+		case "sha512":
+			return Transforms.SHA512(cast.ToString(val))
+		}
+		return g.MD5(cast.ToString(val))
+	case PipelineStepMask:
+		runes := []rune(cast.ToString(val))
+		for i := 0; i < len(runes)-ps.step.Keep; i++ {
+			runes[i] = '*'
+		}
+		return string(runes)
+	}
+	return val
+}
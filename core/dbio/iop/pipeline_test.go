@@ -0,0 +1,87 @@
+package iop
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/flarco/g"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineStepValidate(t *testing.T) {
+	valid := []PipelineStep{
+		{Type: PipelineStepRename, Column: "fname", As: "first_name"},
+		{Type: PipelineStepCast, Column: "amount", To: DecimalType},
+		{Type: PipelineStepHash, Columns: []string{"email"}, Algorithm: "sha256"},
+		{Type: PipelineStepMask, Column: "phone", Keep: 4},
+		{Type: PipelineStepCompute, As: "full_name", Expr: "{first_name} {last_name}"},
+		{Type: PipelineStepFilter, Expr: "amount > 0 and status != cancelled"},
+	}
+	for _, step := range valid {
+		assert.NoError(t, step.Validate(), g.Marshal(step))
+	}
+
+	invalid := []PipelineStep{
+		{Type: "upper", Column: "name"},
+		{Type: PipelineStepRename, Column: "fname"},
+		{Type: PipelineStepCast, Column: "amount", To: "money"},
+		{Type: PipelineStepHash, Columns: []string{"email"}, Algorithm: "crc32"},
+		{Type: PipelineStepMask},
+		{Type: PipelineStepCompute, As: "full_name"},
+		{Type: PipelineStepFilter, Expr: "amount"},
+	}
+	for _, step := range invalid {
+		assert.Error(t, step.Validate(), g.Marshal(step))
+	}
+}
+
+func TestPipelineStream(t *testing.T) {
+	payload := strings.Join([]string{
+		`id,fname,lname,email,phone,amount,status`,
+		`1,Alice,Smith,alice@example.com,555-1234,10.5,paid`,
+		`2,Bob,Jones,bob@example.com,555-9876,0,paid`,
+		`3,Carol,White,carol@example.com,555-4567,7,cancelled`,
+		`4,Dan,Brown,,555-0000,3,paid`,
+	}, "\n")
+
+	steps := []PipelineStep{
+		{Type: PipelineStepRename, Column: "fname", As: "first_name"},
+		{Type: PipelineStepCompute, As: "full_name", Expr: "{first_name} {LNAME}"},
+		{Type: PipelineStepCast, Column: "amount", To: StringType},
+		{Type: PipelineStepHash, Column: "email", Algorithm: "md5"},
+		{Type: PipelineStepMask, Columns: []string{"phone"}, Keep: 2},
+		{Type: PipelineStepFilter, Expr: "amount > 0 and status != cancelled"},
+	}
+
+	ds := NewDatastream(nil)
+	ds.SetConfig(map[string]string{"pipeline": g.Marshal(steps)})
+	if !assert.NoError(t, ds.ConsumeCsvReader(strings.NewReader(payload))) {
+		return
+	}
+	data, err := ds.Collect(0)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"id", "first_name", "lname", "email", "phone", "amount", "status", "full_name"}, data.Columns.Names())
+	assert.Equal(t, StringType, data.Columns[5].Type)
+
+	// rows 2 (amount = 0) and 3 (cancelled) are filtered out
+	if assert.Len(t, data.Rows, 2) {
+		row := data.Rows[0]
+		assert.EqualValues(t, 1, row[0])
+		assert.Equal(t, "Alice Smith", row[7])
+		assert.Equal(t, g.MD5("alice@example.com"), row[3])
+		assert.Equal(t, "******34", row[4])
+		assert.Equal(t, "10.5", row[5])
+
+		// nulls are not hashed
+		assert.Nil(t, data.Rows[1][3])
+	}
+	assert.EqualValues(t, 2, ds.FilteredCount())
+
+	// a step references a missing column
+	ds = NewDatastream(nil)
+	ds.SetConfig(map[string]string{"pipeline": g.Marshal([]PipelineStep{{Type: PipelineStepMask, Column: "fname"}, {Type: PipelineStepRename, Column: "fname", As: "name"}, {Type: PipelineStepMask, Column: "fname"}})})
+	assert.Error(t, ds.ConsumeCsvReader(strings.NewReader(payload)))
+}
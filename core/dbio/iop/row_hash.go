@@ -0,0 +1,142 @@
+package iop
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"hash"
+	"strconv"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+)
+
+// HashAlgorithm is the algorithm of the row hash column
+type HashAlgorithm string
+
+const (
+	HashAlgorithmMD5    HashAlgorithm = "md5" // the default
+	HashAlgorithmXXHash HashAlgorithm = "xxhash"
+)
+
+// HashAlgorithms are the valid row hash algorithms
+var HashAlgorithms = []HashAlgorithm{HashAlgorithmMD5, HashAlgorithmXXHash}
+
+// RowHashOptions is the value of the row hash metadata
+type RowHashOptions struct {
+	Algorithm HashAlgorithm `json:"algorithm,omitempty"`
+	Columns   []string      `json:"columns,omitempty"` // defaults to all non-metadata columns
+}
+
+// rowHasher computes the hash of the hashed columns of a row, once casted
+type rowHasher struct {
+	index     int   // index of the hash column
+	indexes   []int // indexes of the hashed columns
+	algorithm HashAlgorithm
+}
+
+// newRowHasher returns the hasher of the columns, appending the hash column of the
+// metadata (the row hash, or the surrogate key hashing the natural key columns)
+func (ds *Datastream) newRowHasher(meta KeyValue, kind string) (rh *rowHasher, err error) {
+	opts := RowHashOptions{}
+	if err = g.JSONConvert(meta.Value, &opts); err != nil {
+		return nil, g.Error(err, "invalid %s options", kind)
+	}
+
+	rh = &rowHasher{algorithm: opts.Algorithm}
+	if len(opts.Columns) > 0 {
+		for _, name := range opts.Columns {
+			col := ds.Columns.GetColumn(name)
+			if col == nil {
+				return nil, g.Error("%s column %s not found", kind, name)
+			}
+			rh.indexes = append(rh.indexes, col.Position-1)
+		}
+	} else {
+		for i, col := range ds.Columns {
+			if _, ok := col.Metadata["sling_metadata"]; !ok {
+				rh.indexes = append(rh.indexes, i)
+			}
+		}
+	}
+
+	description := "Sling.Metadata.RowHash"
+	if kind == "surrogate_key" {
+		description = "Sling.Metadata.SurrogateKey"
+	}
+
+	col := Column{
+		Name:        meta.Key,
+		Type:        StringType,
+		Position:    len(ds.Columns) + 1,
+		Description: description,
+		Metadata:    map[string]string{"sling_metadata": kind},
+	}
+	ds.Columns = append(ds.Columns, col)
+	rh.index = col.Position - 1
+
+	return rh, nil
+}
+
+// Set sets the hash column value of the row
+func (rh *rowHasher) Set(row []any) []any {
+	for len(row) <= rh.index {
+		row = append(row, nil)
+	}
+	values := make([]any, len(rh.indexes))
+	for i, index := range rh.indexes {
+		if index < len(row) {
+			values[i] = row[index]
+		}
+	}
+	row[rh.index] = HashRow(values, rh.algorithm)
+	return row
+}
+
+// HashRow returns the hex hash of the values. The values are serialized the
+// same way regardless of their origin (timestamps as UTC, nulls apart from
+// empty strings), so that the same data always has the same hash.
+func HashRow(values []any, algorithm HashAlgorithm) string {
+	var h hash.Hash
+	switch algorithm {
+	case HashAlgorithmXXHash:
+		h = xxhash.New()
+	default:
+		h = md5.New()
+	}
+
+	for i, val := range values {
+		if i > 0 {
+			h.Write([]byte{0x1f}) // unit separator
+		}
+		h.Write([]byte(hashValue(val)))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashValue returns the deterministic string of a value
+func hashValue(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return "\x00"
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case *time.Time:
+		if v == nil {
+			return "\x00"
+		}
+		return v.UTC().Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return cast.ToString(val)
+}
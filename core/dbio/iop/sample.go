@@ -0,0 +1,210 @@
+package iop
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/nqd/flat"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+)
+
+// SampleStrategy is how rows are sampled to infer the schema of a file
+type SampleStrategy string
+
+const (
+	SampleStrategyHead   SampleStrategy = "head"   // the first rows. The default.
+	SampleStrategySpread SampleStrategy = "spread" // plus chunks evenly distributed across the file
+	SampleStrategyRandom SampleStrategy = "random" // plus chunks at random offsets of the file
+)
+
+// SampleStrategies are the valid sample strategies
+var SampleStrategies = []SampleStrategy{SampleStrategyHead, SampleStrategySpread, SampleStrategyRandom}
+
+var (
+	// SampleChunks is the number of chunks read across the file
+	SampleChunks = 20
+	// SampleChunkBytes is the number of bytes of each chunk
+	SampleChunkBytes = int64(64 * 1024)
+)
+
+// sampler reads chunks of a seekable file, in addition to the first rows
+// (the buffer), so that columns & types appearing later in large files are
+// part of the schema inference
+type sampler struct {
+	reader   io.ReaderAt
+	size     int64
+	strategy SampleStrategy
+}
+
+// SetSampleSource sets the seekable source of the stream, sampled according to
+// the `sample_strategy` config when inferring the schema of CSV & JSON Lines files
+func (ds *Datastream) SetSampleSource(reader io.ReaderAt, size int64) {
+	strategy := SampleStrategy(ds.Sp.Config.SampleStrategy)
+	if strategy == "" || strategy == SampleStrategyHead || size <= 0 {
+		return
+	}
+	ds.sampler = &sampler{reader: reader, size: size, strategy: strategy}
+}
+
+// offsets returns the byte offsets of the chunks to read. The head of the
+// file is not included, since it is sampled from the buffer.
+func (s *sampler) offsets() (offsets []int64) {
+	if s.size <= SampleChunkBytes {
+		return nil
+	}
+
+	last := s.size - SampleChunkBytes
+	for i := 1; i <= SampleChunks; i++ {
+		switch s.strategy {
+		case SampleStrategyRandom:
+			offsets = append(offsets, rand.Int63n(last)+1)
+		default:
+			offsets = append(offsets, last*int64(i)/int64(SampleChunks))
+		}
+	}
+
+	offsets = lo.Uniq(offsets)
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	return offsets
+}
+
+// Lines returns the complete lines of the sampled chunks. Partial lines at
+// the edges of each chunk are dropped. Compressed files are not sampled.
+func (s *sampler) Lines() (lines []string, err error) {
+	magic := make([]byte, 4)
+	if n, _ := s.reader.ReadAt(magic, 0); n >= 2 && isCompressed(magic[:n]) {
+		g.Debug("not sampling with strategy %s, the file is compressed", s.strategy)
+		return nil, nil
+	}
+
+	for _, offset := range s.offsets() {
+		chunk, err := io.ReadAll(io.NewSectionReader(s.reader, offset, SampleChunkBytes))
+		if err != nil {
+			return nil, g.Error(err, "could not read sample chunk at offset %d", offset)
+		}
+
+		// drop the partial first line, and the partial last line (unless at the end)
+		if i := bytes.IndexByte(chunk, '\n'); i > -1 {
+			chunk = chunk[i+1:]
+		} else {
+			continue
+		}
+		if offset+SampleChunkBytes < s.size {
+			if i := bytes.LastIndexByte(chunk, '\n'); i > -1 {
+				chunk = chunk[:i]
+			} else {
+				continue
+			}
+		}
+
+		for _, line := range strings.Split(string(chunk), "\n") {
+			if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+
+	g.Debug("sampled %d lines across the file with strategy %s", len(lines), s.strategy)
+	return lines, nil
+}
+
+// isCompressed returns true for gzip, zstd, zip & snappy magic bytes
+func isCompressed(magic []byte) bool {
+	switch {
+	case magic[0] == 31 && magic[1] == 139: // gzip
+		return true
+	case magic[0] == 'P' && magic[1] == 'K': // zip
+		return true
+	case len(magic) >= 4 && bytes.Equal(magic[:4], []byte{0x28, 0xb5, 0x2f, 0xfd}): // zstd
+		return true
+	case magic[0] == 0xff && magic[1] == 0x06: // snappy framed
+		return true
+	}
+	return false
+}
+
+// sampleCsv parses the sampled lines as CSV rows with the number of columns.
+// Lines which do not parse (e.g. inside a multi-line value) are skipped.
+func (ds *Datastream) sampleCsv(delimiter rune) {
+	lines, err := ds.sampler.Lines()
+	if err != nil {
+		g.Warn("could not sample file: %s", err.Error())
+		return
+	}
+
+	for _, line := range lines {
+		r := csv.NewReader(strings.NewReader(line))
+		r.Comma = delimiter
+		r.LazyQuotes = true
+		row, err := r.Read()
+		if err != nil || len(row) != len(ds.Columns) {
+			continue
+		}
+		ds.sampleRows = append(ds.sampleRows, lo.Map(row, func(v string, i int) any { return v }))
+	}
+}
+
+// seedSample adds the columns of the sampled JSON Lines records, so that
+// columns appearing later in the file are inferred with the first rows
+func (js *jsonStream) seedSample() {
+	lines, err := js.ds.sampler.Lines()
+	if err != nil {
+		g.Warn("could not sample file: %s", err.Error())
+		return
+	}
+
+	records := []map[string]any{}
+	for _, line := range lines {
+		rec := map[string]any{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			continue // not a JSON Lines record
+		}
+
+		rec, _ = flat.Flatten(rec, &flat.Options{Delimiter: "__", Safe: true})
+		if js.normalizeKeys != "" {
+			rec = js.normalizeRecord(rec)
+		}
+		records = append(records, rec)
+	}
+
+	// add the sampled columns, in order
+	colsToAdd := Columns{}
+	for _, rec := range records {
+		keys := lo.Keys(rec)
+		sort.Strings(keys)
+		for _, key := range keys {
+			if _, ok := js.ColumnMap[key]; ok {
+				continue
+			}
+			col := &Column{
+				Name:     key,
+				Type:     StringType,
+				Position: len(js.ds.Columns) + len(colsToAdd) + 1,
+				FileURI:  cast.ToString(js.ds.Metadata.StreamURL.Value),
+			}
+			colsToAdd = append(colsToAdd, *col)
+			js.ColumnMap[key] = col
+		}
+	}
+	if len(colsToAdd) > 0 {
+		js.addColumn(colsToAdd...)
+	}
+
+	for _, rec := range records {
+		row := make([]any, len(js.ds.Columns))
+		for key, val := range rec {
+			if arr, ok := val.([]any); ok {
+				val = g.Marshal(arr)
+			}
+			row[js.ColumnMap[key].Position-1] = val
+		}
+		js.ds.sampleRows = append(js.ds.sampleRows, row)
+	}
+}
@@ -0,0 +1,92 @@
+package iop
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/flarco/g"
+)
+
+// SelectField is a parsed `select` entry, referencing a column by name
+// (e.g. `id`) or by position (e.g. `@1`), optionally aliased (`@1 as id`)
+type SelectField struct {
+	Name     string // the column name, empty when positional
+	Position int    // the 1-based column position, 0 when by name
+	Alias    string // the output column name, empty to keep the source name
+}
+
+var selectFieldRegex = regexp.MustCompile(`(?i)^(.+?)\s+as\s+(\S+)$`)
+
+var selectPositionRegex = regexp.MustCompile(`^@(\d+)$`)
+
+// ParseSelectField parses a select entry such as `name`, `@3` or `@1 as id`
+func ParseSelectField(field string) (sf SelectField, err error) {
+	ref := strings.TrimSpace(field)
+	if match := selectFieldRegex.FindStringSubmatch(ref); match != nil {
+		ref, sf.Alias = strings.TrimSpace(match[1]), match[2]
+	}
+
+	if match := selectPositionRegex.FindStringSubmatch(ref); match != nil {
+		sf.Position, _ = strconv.Atoi(match[1])
+		if sf.Position < 1 {
+			return sf, g.Error("invalid select field %#v, positions start at @1", field)
+		}
+	} else if strings.HasPrefix(ref, "@") {
+		return sf, g.Error("invalid select field %#v, positional references should be like @1", field)
+	} else {
+		sf.Name = ref
+	}
+
+	if sf.Name == "" && sf.Position == 0 {
+		return sf, g.Error("invalid select field %#v", field)
+	}
+
+	return sf, nil
+}
+
+// HasPositionalSelect returns true if any select entry references a column
+// by position or with an alias, requiring to be resolved with SelectColumns
+func HasPositionalSelect(fields []string) bool {
+	for _, field := range fields {
+		sf, err := ParseSelectField(field)
+		if err == nil && (sf.Position > 0 || sf.Alias != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectColumns resolves the select entries against the columns, returning
+// the selected (and aliased) columns with the index of each in the source row
+func SelectColumns(cols Columns, fields []string) (selected Columns, indexes []int, err error) {
+	fm := cols.FieldMap(true)
+	for _, field := range fields {
+		sf, err := ParseSelectField(field)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		index := -1
+		if sf.Position > 0 {
+			if sf.Position > len(cols) {
+				return nil, nil, g.Error("select field %s is out of range, stream has %d columns", strings.TrimSpace(field), len(cols))
+			}
+			index = sf.Position - 1
+		} else if i, ok := fm[strings.ToLower(sf.Name)]; ok {
+			index = i
+		} else {
+			return nil, nil, g.Error("column %s not found", sf.Name)
+		}
+
+		col := cols[index]
+		if sf.Alias != "" {
+			col.Name = sf.Alias
+		}
+		col.Position = len(selected) + 1
+		selected = append(selected, col)
+		indexes = append(indexes, index)
+	}
+
+	return selected, indexes, nil
+}
@@ -0,0 +1,50 @@
+package iop
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSelectField(t *testing.T) {
+	sf, err := ParseSelectField(" @3 ")
+	if assert.NoError(t, err) {
+		assert.Equal(t, SelectField{Position: 3}, sf)
+	}
+
+	sf, err = ParseSelectField("@1 AS id")
+	if assert.NoError(t, err) {
+		assert.Equal(t, SelectField{Position: 1, Alias: "id"}, sf)
+	}
+
+	sf, err = ParseSelectField("first_name as name")
+	if assert.NoError(t, err) {
+		assert.Equal(t, SelectField{Name: "first_name", Alias: "name"}, sf)
+	}
+
+	for _, field := range []string{"", "@0", "@a", "@ as id"} {
+		_, err = ParseSelectField(field)
+		assert.Error(t, err, field)
+	}
+
+	assert.True(t, HasPositionalSelect([]string{"id", "@2"}))
+	assert.True(t, HasPositionalSelect([]string{"name as first_name"}))
+	assert.False(t, HasPositionalSelect([]string{"id", "name"}))
+}
+
+func TestSelectColumns(t *testing.T) {
+	cols := NewColumnsFromFields(CreateDummyFields(5)...)
+
+	selected, indexes, err := SelectColumns(cols, []string{"@1 as id", " @3", "col_005 as email"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"id", "col_003", "email"}, selected.Names())
+		assert.Equal(t, []int{0, 2, 4}, indexes)
+		assert.Equal(t, 2, selected[1].Position)
+	}
+
+	_, _, err = SelectColumns(cols, []string{"@6"})
+	assert.Error(t, err)
+
+	_, _, err = SelectColumns(cols, []string{"missing"})
+	assert.Error(t, err)
+}
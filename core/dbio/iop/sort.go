@@ -0,0 +1,346 @@
+package iop
+
+import (
+	"cmp"
+	"container/heap"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/flarco/g"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cast"
+)
+
+// SortKey is a column to sort a stream by
+type SortKey struct {
+	Column string
+	Desc   bool
+}
+
+// ParseSortKeys parses order by expressions such as `id`, `id desc` or `name asc`
+func ParseSortKeys(orderBy []string) (keys []SortKey, err error) {
+	for _, expr := range orderBy {
+		parts := strings.Fields(expr)
+		switch {
+		case len(parts) == 1:
+			keys = append(keys, SortKey{Column: parts[0]})
+		case len(parts) == 2 && g.In(strings.ToLower(parts[1]), "asc", "desc"):
+			keys = append(keys, SortKey{Column: parts[0], Desc: strings.EqualFold(parts[1], "desc")})
+		default:
+			return nil, g.Error("invalid order by expression: %#v, expecting `<column> [asc|desc]`", expr)
+		}
+	}
+	return keys, nil
+}
+
+// SortDataflow merges the dataflow streams into one datastream, sorted by the
+// keys. Ties are ordered by the whole row, so that the output is the same for
+// the same input rows, whatever their order. All rows are read before the first
+// one is returned. Once the sorted rows exceed the max memory (SLING_MAX_MEMORY),
+// they are spilled to disk in sorted runs, which are merged when reading.
+func SortDataflow(df *Dataflow, keys []SortKey) (dsN *Datastream, err error) {
+	if len(keys) == 0 {
+		return nil, g.Error("need at least one column to sort by")
+	}
+
+	dsM := MergeDataflow(df)
+	if err = dsM.Err(); err != nil {
+		return nil, g.Error(err, "could not merge dataflow")
+	}
+
+	sorter := &rowSorter{}
+	for _, key := range keys {
+		index := -1
+		for i, col := range dsM.Columns {
+			if strings.EqualFold(col.Name, key.Column) {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			return nil, g.Error("sort column not found: %s", key.Column)
+		}
+		sorter.indexes = append(sorter.indexes, index)
+		sorter.desc = append(sorter.desc, key.Desc)
+	}
+
+	limit := MaxMemory()
+	runs := []*SpillFile{}
+	closeRuns := func() {
+		for _, run := range runs {
+			run.Close()
+		}
+	}
+
+	// sort the rows in runs of the max memory
+	var chunk [][]any
+	var chunkBytes uint64
+	for row := range dsM.Rows() {
+		chunk = append(chunk, row)
+		if limit == 0 {
+			continue
+		}
+
+		chunkBytes += RowBytes(row)
+		if chunkBytes > limit {
+			if len(runs) == 0 {
+				g.Warn("max memory (%s) reached while sorting rows, spilling to %s", humanize.Bytes(limit), SpillFolder())
+			}
+			run, err := sorter.spill(dsM.ID, chunk)
+			if err != nil {
+				closeRuns()
+				return nil, g.Error(err, "could not spill sorted rows to disk")
+			}
+			runs = append(runs, run)
+			chunk, chunkBytes = nil, 0
+		}
+	}
+
+	if err = dsM.Err(); err != nil {
+		closeRuns()
+		return nil, g.Error(err, "could not read rows to sort")
+	}
+
+	sort.SliceStable(chunk, func(i, j int) bool { return sorter.compare(chunk[i], chunk[j]) < 0 })
+
+	// merge the runs, reading the next row of each
+	merger := &rowMerger{sorter: sorter}
+	for _, run := range runs {
+		merger.push(&sortRun{spill: run})
+	}
+	merger.push(&sortRun{rows: chunk})
+
+	nextFunc := func(it *Iterator) bool {
+		row, err := merger.next()
+		if err != nil {
+			it.Context.CaptureErr(g.Error(err, "could not read sorted rows"))
+			return false
+		} else if row == nil {
+			return false
+		}
+		it.Row = row
+		return true
+	}
+
+	dsN = NewDatastreamIt(df.Context.Ctx, dsM.Columns, nextFunc)
+	dsN.it.IsCasted = true
+	dsN.Inferred = true
+	dsN.Sp.Config = dsM.Sp.Config
+	dsN.Defer(closeRuns)
+
+	if err = dsN.Start(); err != nil {
+		closeRuns()
+		return nil, g.Error(err, "could not start sorted datastream")
+	}
+
+	return dsN, nil
+}
+
+// rowSorter compares rows by the sort columns, then by the whole row
+type rowSorter struct {
+	indexes []int
+	desc    []bool
+}
+
+func (rs *rowSorter) compare(a, b []any) int {
+	for i, index := range rs.indexes {
+		var valA, valB any
+		if index < len(a) {
+			valA = a[index]
+		}
+		if index < len(b) {
+			valB = b[index]
+		}
+
+		if c := compareSortValues(valA, valB); c != 0 {
+			if rs.desc[i] {
+				return -c
+			}
+			return c
+		}
+	}
+
+	// tie-breaker, for a deterministic order
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareSortValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// spill sorts the rows and writes them to a spill file
+func (rs *rowSorter) spill(name string, rows [][]any) (sf *SpillFile, err error) {
+	sort.SliceStable(rows, func(i, j int) bool { return rs.compare(rows[i], rows[j]) < 0 })
+
+	if sf, err = NewSpillFile("sort." + name); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if err = sf.Write(row); err != nil {
+			sf.Close()
+			return nil, err
+		}
+	}
+	return sf, nil
+}
+
+// compareSortValues compares two values, nulls first
+func compareSortValues(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	switch valA := a.(type) {
+	case string:
+		if valB, ok := b.(string); ok {
+			return strings.Compare(valA, valB)
+		}
+	case time.Time:
+		if valB, ok := b.(time.Time); ok {
+			return valA.Compare(valB)
+		}
+	case bool:
+		if valB, ok := b.(bool); ok {
+			switch {
+			case valA == valB:
+				return 0
+			case !valA:
+				return -1
+			}
+			return 1
+		}
+	case decimal.Decimal:
+		if valB, ok := b.(decimal.Decimal); ok {
+			return valA.Cmp(valB)
+		}
+	}
+
+	// integers are compared exactly, beyond the float precision (2^53)
+	if intA, ok := sortInteger(a); ok {
+		if intB, ok := sortInteger(b); ok {
+			return cmp.Compare(intA, intB)
+		}
+	}
+
+	if numA, ok := sortNumber(a); ok {
+		if numB, ok := sortNumber(b); ok {
+			switch {
+			case numA < numB:
+				return -1
+			case numA > numB:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	return strings.Compare(cast.ToString(a), cast.ToString(b))
+}
+
+// sortInteger returns the value as an int64, if an integer within its range
+func sortInteger(val any) (int64, bool) {
+	switch v := val.(type) {
+	case int, int8, int16, int32, int64, uint8, uint16, uint32:
+		return cast.ToInt64(v), true
+	case uint:
+		return int64(v), uint64(v) <= math.MaxInt64
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	}
+	return 0, false
+}
+
+// sortNumber returns the value as a float, if numeric
+func sortNumber(val any) (float64, bool) {
+	switch v := val.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return cast.ToFloat64(v), true
+	case decimal.Decimal:
+		return v.InexactFloat64(), true
+	}
+	return 0, false
+}
+
+// sortRun is a sorted run of rows, in memory or spilled to disk
+type sortRun struct {
+	rows  [][]any
+	spill *SpillFile
+	row   []any
+}
+
+// advance reads the next row of the run, nil when done
+func (sr *sortRun) advance() (err error) {
+	sr.row = nil
+	if sr.spill != nil {
+		sr.row, err = sr.spill.Read()
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	if len(sr.rows) > 0 {
+		sr.row = sr.rows[0]
+		sr.rows = sr.rows[1:]
+	}
+	return nil
+}
+
+// rowMerger merges sorted runs with a min-heap of their current rows
+type rowMerger struct {
+	sorter *rowSorter
+	runs   []*sortRun
+	err    error
+}
+
+func (rm *rowMerger) Len() int { return len(rm.runs) }
+func (rm *rowMerger) Less(i, j int) bool {
+	return rm.sorter.compare(rm.runs[i].row, rm.runs[j].row) < 0
+}
+func (rm *rowMerger) Swap(i, j int) { rm.runs[i], rm.runs[j] = rm.runs[j], rm.runs[i] }
+func (rm *rowMerger) Push(x any)    { rm.runs = append(rm.runs, x.(*sortRun)) }
+func (rm *rowMerger) Pop() any {
+	run := rm.runs[len(rm.runs)-1]
+	rm.runs = rm.runs[:len(rm.runs)-1]
+	return run
+}
+
+// push adds a run to the heap, with its first row
+func (rm *rowMerger) push(run *sortRun) {
+	if err := run.advance(); err != nil {
+		rm.err = err
+		return
+	} else if run.row != nil {
+		heap.Push(rm, run)
+	}
+}
+
+// next returns the smallest current row, nil once all runs are read
+func (rm *rowMerger) next() (row []any, err error) {
+	if rm.err != nil {
+		return nil, rm.err
+	} else if len(rm.runs) == 0 {
+		return nil, nil
+	}
+
+	run := rm.runs[0]
+	row = run.row
+	if err = run.advance(); err != nil {
+		return nil, err
+	} else if run.row == nil {
+		heap.Pop(rm)
+	} else {
+		heap.Fix(rm, 0)
+	}
+	return row, nil
+}
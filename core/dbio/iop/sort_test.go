@@ -0,0 +1,104 @@
+package iop
+
+import (
+	"os"
+	"testing"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/env"
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSortKeys(t *testing.T) {
+	keys, err := ParseSortKeys([]string{"id", "name DESC", " created_at asc "})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []SortKey{{Column: "id"}, {Column: "name", Desc: true}, {Column: "created_at"}}, keys)
+	}
+
+	_, err = ParseSortKeys([]string{"id desc nulls last"})
+	assert.Error(t, err)
+	_, err = ParseSortKeys([]string{"id down"})
+	assert.Error(t, err)
+}
+
+func TestCompareSortValues(t *testing.T) {
+	// beyond the float precision
+	assert.Equal(t, 1, compareSortValues(int64(9007199254740993), int64(9007199254740992)))
+	assert.Equal(t, -1, compareSortValues(int32(5), uint64(9007199254740993)))
+	assert.Equal(t, -1, compareSortValues(int64(1), 1.5))
+	assert.Equal(t, -1, compareSortValues(nil, int64(1)))
+}
+
+func TestSortDataflow(t *testing.T) {
+	homeDir := env.HomeDir
+	env.HomeDir = t.TempDir()
+	defer func() {
+		env.HomeDir = homeDir
+		os.Unsetenv("SLING_MAX_MEMORY")
+	}()
+
+	columns := Columns{
+		{Name: "group", Type: StringType, Position: 1},
+		{Name: "id", Type: BigIntType, Position: 2},
+		{Name: "name", Type: StringType, Position: 3},
+	}
+
+	// rows in two different orders, with duplicate sort values and nulls
+	makeDf := func(reverse bool) *Dataflow {
+		data := NewDataset(columns)
+		for i := 0; i < 1000; i++ {
+			n := i
+			if reverse {
+				n = 999 - i
+			}
+			var group any = g.F("g%d", n%7)
+			if n%50 == 0 {
+				group = nil
+			}
+			data.Rows = append(data.Rows, []any{group, int64(n % 100), g.F("name_%d", n)})
+		}
+		data.Inferred = true
+		df, err := MakeDataFlow(data.Stream())
+		g.AssertNoError(t, err)
+		return df
+	}
+
+	collect := func(df *Dataflow, keys ...SortKey) [][]any {
+		ds, err := SortDataflow(df, keys)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		data, err := ds.Collect(0)
+		assert.NoError(t, err)
+		return data.Rows
+	}
+
+	keys := []SortKey{{Column: "GROUP"}, {Column: "id", Desc: true}}
+	rows := collect(makeDf(false), keys...)
+	if !assert.Len(t, rows, 1000) {
+		return
+	}
+
+	// nulls first, then by group, then by id descending
+	assert.Nil(t, rows[0][0])
+	assert.Equal(t, "g0", cast.ToString(rows[20][0]))
+	for i := 1; i < len(rows); i++ {
+		if rows[i-1][0] == nil || rows[i-1][0] != rows[i][0] {
+			continue
+		}
+		assert.GreaterOrEqual(t, cast.ToInt(rows[i-1][1]), cast.ToInt(rows[i][1]))
+	}
+
+	// same output for a different input order
+	assert.Equal(t, rows, collect(makeDf(true), keys...))
+
+	// same output when spilling sorted runs to disk
+	os.Setenv("SLING_MAX_MEMORY", "4KB")
+	assert.Equal(t, rows, collect(makeDf(true), keys...))
+	os.Unsetenv("SLING_MAX_MEMORY")
+
+	// unknown column
+	_, err := SortDataflow(makeDf(false), []SortKey{{Column: "missing"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,218 @@
+package iop
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/flarco/g"
+	"github.com/segmentio/ksuid"
+	"github.com/shopspring/decimal"
+	"github.com/slingdata-io/sling-cli/core/env"
+	"github.com/spf13/cast"
+)
+
+// spillFiles holds the paths of the open spill files, for clean up on exit
+var spillFiles sync.Map
+
+func init() {
+	gob.Register(time.Time{})
+	gob.Register(decimal.Decimal{})
+}
+
+// MaxMemory returns the byte limit of the in-memory row buffering stages,
+// from SLING_MAX_MEMORY (e.g. `512MB`). 0 means no limit.
+// It bounds the type inference buffer of a datastream (bufferRow) and the
+// rows of SortDataflow, which spill to disk beyond it. Collecting a stream
+// into a Dataset (e.g. Dataflow.Collect) stays in memory and is not bounded.
+func MaxMemory() uint64 {
+	val := os.Getenv("SLING_MAX_MEMORY")
+	if val == "" {
+		return 0
+	}
+	limit, err := ParseMaxMemory(val)
+	if err != nil {
+		g.Warn("ignoring invalid SLING_MAX_MEMORY value (%s)", val)
+		return 0
+	}
+	return limit
+}
+
+// ParseMaxMemory parses a memory size such as `512MB`, `2GiB` or `1000000`
+func ParseMaxMemory(val string) (uint64, error) {
+	limit, err := humanize.ParseBytes(strings.TrimSpace(val))
+	if err != nil {
+		return 0, g.Error(err, "invalid memory size: %s", val)
+	}
+	return limit, nil
+}
+
+// SpillFolder returns the folder of the spill files, SLING_HOME/tmp, or the
+// temp folder when overridden (SLING_TMP_DIR)
+func SpillFolder() string {
+	if env.HomeDir == "" || env.TempDirOverride() != "" {
+		return env.GetTempFolder()
+	}
+	return path.Join(env.HomeDir, "tmp")
+}
+
+// CleanupSpillFiles removes the spill files still on disk.
+// Called on exit / interrupt.
+func CleanupSpillFiles() {
+	spillFiles.Range(func(key, value any) bool {
+		if sf, ok := value.(*SpillFile); ok {
+			sf.Close()
+		}
+		return true
+	})
+}
+
+// RowBytes estimates the memory used by a row
+func RowBytes(row []any) (size uint64) {
+	size = 24 // slice header
+	for _, val := range row {
+		size += 16 // interface header
+		switch v := val.(type) {
+		case string:
+			size += uint64(len(v))
+		case []byte:
+			size += uint64(len(v))
+		case time.Time:
+			size += 24
+		case decimal.Decimal:
+			size += 32
+		case map[string]any, []any:
+			size += uint64(len(g.Marshal(v)))
+		}
+	}
+	return
+}
+
+// SpillFile writes rows to a temporary file once a buffering stage
+// exceeds the memory limit, and reads them back in the same order.
+type SpillFile struct {
+	Path  string
+	Rows  int
+	Bytes uint64
+
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *gob.Encoder
+	decoder *gob.Decoder
+	read    int
+	mux     sync.Mutex
+}
+
+// NewSpillFile creates a spill file in the spill folder
+func NewSpillFile(name string) (sf *SpillFile, err error) {
+	folder := SpillFolder()
+	if err = os.MkdirAll(folder, 0755); err != nil {
+		return nil, g.Error(err, "could not create spill folder: %s", folder)
+	}
+
+	sf = &SpillFile{Path: path.Join(folder, g.F("spill.%s.%s.gob", name, ksuid.New().String()))}
+	sf.file, err = os.Create(sf.Path)
+	if err != nil {
+		return nil, g.Error(err, "could not create spill file: %s", sf.Path)
+	}
+	sf.writer = bufio.NewWriter(sf.file)
+	sf.encoder = gob.NewEncoder(sf.writer)
+	spillFiles.Store(sf.Path, sf)
+
+	return sf, nil
+}
+
+// Write appends a row to the spill file
+func (sf *SpillFile) Write(row []any) (err error) {
+	sf.mux.Lock()
+	defer sf.mux.Unlock()
+
+	if sf.encoder == nil {
+		return g.Error("spill file is not writable: %s", sf.Path)
+	}
+
+	// gob only handles registered types, other values are kept as strings
+	values := make([]any, len(row))
+	for i, val := range row {
+		switch v := val.(type) {
+		case nil, string, bool, int, int8, int16, int32, int64, uint, uint8,
+			uint16, uint32, uint64, float32, float64, []byte, time.Time, decimal.Decimal:
+			values[i] = v
+		case map[string]any, []any:
+			values[i] = g.Marshal(v)
+		default:
+			if str, err := cast.ToStringE(v); err == nil {
+				values[i] = str
+			} else {
+				values[i] = g.F("%v", v)
+			}
+		}
+	}
+
+	if err = sf.encoder.Encode(values); err != nil {
+		return g.Error(err, "could not write to spill file: %s", sf.Path)
+	}
+	sf.Rows++
+	sf.Bytes += RowBytes(row)
+
+	return nil
+}
+
+// Read returns the next spilled row, and io.EOF once all rows are read.
+// No more rows can be written after the first read.
+func (sf *SpillFile) Read() (row []any, err error) {
+	sf.mux.Lock()
+	defer sf.mux.Unlock()
+
+	if sf.decoder == nil {
+		if sf.file == nil {
+			return nil, io.EOF
+		}
+		if err = sf.writer.Flush(); err != nil {
+			return nil, g.Error(err, "could not flush spill file: %s", sf.Path)
+		}
+		if _, err = sf.file.Seek(0, io.SeekStart); err != nil {
+			return nil, g.Error(err, "could not rewind spill file: %s", sf.Path)
+		}
+		sf.encoder = nil
+		sf.decoder = gob.NewDecoder(bufio.NewReader(sf.file))
+	}
+
+	if sf.read >= sf.Rows {
+		return nil, io.EOF
+	}
+
+	if err = sf.decoder.Decode(&row); err != nil {
+		return nil, g.Error(err, "could not read from spill file: %s", sf.Path)
+	}
+	sf.read++
+
+	return row, nil
+}
+
+// Close closes and removes the spill file
+func (sf *SpillFile) Close() (err error) {
+	sf.mux.Lock()
+	defer sf.mux.Unlock()
+
+	if sf.file == nil {
+		return nil
+	}
+
+	sf.file.Close()
+	sf.file = nil
+	sf.encoder = nil
+	sf.decoder = nil
+	spillFiles.Delete(sf.Path)
+
+	if err = os.Remove(sf.Path); err != nil && !os.IsNotExist(err) {
+		return g.Error(err, "could not remove spill file: %s", sf.Path)
+	}
+	return nil
+}
@@ -59,13 +59,24 @@ type StreamConfig struct {
 	FileMaxBytes      int64                    `json:"file_max_bytes"`
 	BatchLimit        int64                    `json:"batch_limit"`
 	MaxDecimals       int                      `json:"max_decimals"`
+	DecimalPrecision  string                   `json:"decimal_precision"`
+	DecimalHeadroom   *int                     `json:"decimal_headroom"`      // integer digits added to the fitted decimals
+	DecimalMaxDigits  int                      `json:"decimal_max_precision"` // maximum precision of the fitted decimals
 	Flatten           bool                     `json:"flatten"`
 	FieldsPerRec      int                      `json:"fields_per_rec"`
 	Jmespath          string                   `json:"jmespath"`
+	NormalizeKeys     string                   `json:"normalize_keys"`  // JSON object keys normalization: lowercase | snake_case
+	SampleStrategy    string                   `json:"sample_strategy"` // rows sampled for inference: head | spread | random
 	Sheet             string                   `json:"sheet"`
 	ColumnCasing      ColumnCasing             `json:"column_casing"`
 	BoolAsInt         bool                     `json:"-"`
-	Columns           Columns                  `json:"columns"` // list of column types. Can be partial list! likely is!
+	BoolValues        *BoolValues              `json:"bool_values"`      // string values parsed as booleans
+	BoolFormat        string                   `json:"bool_format"`      // how booleans are written, e.g. `Y/N`
+	Columns           Columns                  `json:"columns"`          // list of column types. Can be partial list! likely is!
+	TransformPlugin   string                   `json:"transform_plugin"` // path of the plugin transforming the rows
+	JSONExtracts      []JSONExtract            `json:"extract"`          // nested fields of JSON columns extracted into columns
+	Pipeline          []PipelineStep           `json:"pipeline"`         // row transform steps, applied in order
+	Normalize         *NormalizeConfig         `json:"normalize"`        // arrays of JSON records split into child datasets
 	transforms        map[string]TransformList // array of transform functions to apply
 	maxDecimalsFormat string                   `json:"-"`
 
@@ -323,6 +334,18 @@ func (sp *StreamProcessor) SetConfig(configMap map[string]string) {
 		}
 	}
 
+	if val, ok := configMap["decimal_precision"]; ok {
+		sp.Config.DecimalPrecision = val
+	}
+
+	if val := configMap["decimal_headroom"]; val != "" {
+		sp.Config.DecimalHeadroom = g.Int(cast.ToInt(val))
+	}
+
+	if val := configMap["decimal_max_precision"]; val != "" {
+		sp.Config.DecimalMaxDigits = cast.ToInt(val)
+	}
+
 	if val, ok := configMap["empty_as_null"]; ok {
 		sp.Config.EmptyAsNull = cast.ToBool(val)
 	}
@@ -339,6 +362,14 @@ func (sp *StreamProcessor) SetConfig(configMap map[string]string) {
 		sp.Config.Jmespath = cast.ToString(val)
 	}
 
+	if val, ok := configMap["normalize_keys"]; ok {
+		sp.Config.NormalizeKeys = cast.ToString(val)
+	}
+
+	if val, ok := configMap["sample_strategy"]; ok {
+		sp.Config.SampleStrategy = cast.ToString(val)
+	}
+
 	if val, ok := configMap["sheet"]; ok {
 		sp.Config.Sheet = cast.ToString(val)
 	}
@@ -355,6 +386,14 @@ func (sp *StreamProcessor) SetConfig(configMap map[string]string) {
 		sp.Config.BoolAsInt = cast.ToBool(val)
 	}
 
+	if val, ok := configMap["bool_values"]; ok && val != "" {
+		g.Unmarshal(val, &sp.Config.BoolValues)
+	}
+
+	if val, ok := configMap["bool_format"]; ok {
+		sp.Config.BoolFormat = val
+	}
+
 	if val, ok := configMap["columns"]; ok {
 		g.Unmarshal(val, &sp.Config.Columns)
 	}
@@ -363,6 +402,24 @@ func (sp *StreamProcessor) SetConfig(configMap map[string]string) {
 		sp.applyTransforms(val)
 	}
 
+	if val, ok := configMap["transform_plugin"]; ok {
+		sp.Config.TransformPlugin = val
+	}
+
+	if val, ok := configMap["extract"]; ok && val != "" {
+		g.Unmarshal(val, &sp.Config.JSONExtracts)
+	}
+
+	if val, ok := configMap["pipeline"]; ok && val != "" {
+		g.Unmarshal(val, &sp.Config.Pipeline)
+	}
+
+	if val, ok := configMap["normalize"]; ok && strings.HasPrefix(val, "{") {
+		sp.Config.Normalize = &NormalizeConfig{}
+		g.Unmarshal(val, sp.Config.Normalize)
+		sp.Config.Flatten = true // arrays are split from the flattened records
+	}
+
 	if val, ok := configMap["compression"]; ok {
 		sp.Config.Compression = CompressorType(strings.ToLower(val))
 	}
@@ -805,7 +862,10 @@ func (sp *StreamProcessor) CastVal(i int, val interface{}, col *Column) interfac
 
 	case col.Type.IsBool():
 		var err error
-		bVal, err := sp.CastToBool(val)
+		bVal, ok := sp.Config.BoolValues.Parse(col.Name, sVal)
+		if !ok {
+			bVal, err = sp.CastToBool(val)
+		}
 		if err != nil {
 			// is string
 			sp.ds.ChangeColumn(i, StringType)
@@ -883,6 +943,8 @@ func (sp *StreamProcessor) CastToString(i int, val interface{}, valType ...Colum
 			return "1"
 		}
 		return "0"
+	case sp.Config.BoolFormat != "" && typ.IsBool():
+		return FormatBool(sp.Config.BoolFormat, cast.ToBool(val))
 	case typ.IsDecimal() || typ.IsFloat():
 		if RemoveTrailingDecZeros {
 			// attempt to remove trailing zeros, but is 10 times slower
@@ -1268,3 +1330,69 @@ func (sp *StreamProcessor) ProcessRow(row []interface{}) []interface{} {
 	}
 	return row
 }
+
+// BoolValues maps string values to booleans, when parsing. Columns
+// overrides the mapping of specific columns.
+//
+//	bool_values:
+//	  truthy: [Y, yes, t]
+//	  falsy: [N, no, f]
+//	  columns:
+//	    is_active: { truthy: ["1"], falsy: ["0"] }
+type BoolValues struct {
+	Truthy  []string               `json:"truthy,omitempty" yaml:"truthy,omitempty"`
+	Falsy   []string               `json:"falsy,omitempty" yaml:"falsy,omitempty"`
+	Columns map[string]*BoolValues `json:"columns,omitempty" yaml:"columns,omitempty"`
+}
+
+// Parse returns the boolean of the value for the column. ok is false if
+// the value is not mapped. Values are matched case-insensitively.
+func (bv *BoolValues) Parse(column, val string) (b bool, ok bool) {
+	if bv == nil {
+		return false, false
+	}
+
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return false, false // empty values are handled as nulls
+	}
+
+	mapping := bv
+	for name, colValues := range bv.Columns {
+		if colValues != nil && strings.EqualFold(name, column) {
+			mapping = colValues
+			break
+		}
+	}
+
+	for _, truthy := range mapping.Truthy {
+		if strings.EqualFold(truthy, val) {
+			return true, true
+		}
+	}
+	for _, falsy := range mapping.Falsy {
+		if strings.EqualFold(falsy, val) {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// FormatBool formats the boolean with the format `<true>/<false>`, e.g. `Y/N` or `1/0`
+func FormatBool(format string, b bool) string {
+	trueVal, falseVal, ok := strings.Cut(format, "/")
+	if !ok {
+		return strconv.FormatBool(b)
+	} else if b {
+		return trueVal
+	}
+	return falseVal
+}
+
+// ValidateBoolFormat checks a bool format, such as `Y/N`
+func ValidateBoolFormat(format string) error {
+	if trueVal, falseVal, ok := strings.Cut(format, "/"); !ok || trueVal == falseVal || strings.Contains(falseVal, "/") {
+		return g.Error("invalid bool format: %s (expected `<true>/<false>`, e.g. `Y/N`)", format)
+	}
+	return nil
+}
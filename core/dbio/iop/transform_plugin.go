@@ -0,0 +1,240 @@
+package iop
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"plugin"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/env"
+)
+
+// TransformPlugin transforms the rows of the streams, loaded with
+// `--transform-plugin` (SLING_TRANSFORM_PLUGIN). Rows are exchanged as maps
+// of the column name to the value. Returning a nil row drops it, and an
+// error rejects the row, as a failed constraint: it is skipped with
+// SLING_ON_CONSTRAINT_FAILURE=skip, else the run fails (the row cannot be kept
+// untransformed). Plugins cannot add columns. Close is called once the stream
+// is done.
+type TransformPlugin interface {
+	Transform(row map[string]any) (map[string]any, error)
+	Close() error
+}
+
+// TransformPluginFunc is the `Transform` function exported by Go plugins:
+//
+//	func Transform(row map[string]any) (map[string]any, error)
+type TransformPluginFunc func(row map[string]any) (map[string]any, error)
+
+func (f TransformPluginFunc) Transform(row map[string]any) (map[string]any, error) {
+	return f(row)
+}
+
+// Close is a no-op, Go plugins cannot be unloaded
+func (f TransformPluginFunc) Close() error { return nil }
+
+var transformPlugins sync.Map // path -> TransformPlugin, Go plugins only
+
+// LoadTransformPlugin loads the transform plugin at the path: a Go plugin
+// (`.so`, built with `-buildmode=plugin`) or a WASM module (`.wasm`).
+// Go plugins are loaded once per path, while each WASM load starts its own
+// runtime process, stopped by Close.
+func LoadTransformPlugin(path string) (tp TransformPlugin, err error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".so":
+		if val, ok := transformPlugins.Load(path); ok {
+			return val.(TransformPlugin), nil
+		}
+		if tp, err = loadGoTransformPlugin(path); err != nil {
+			return nil, err
+		}
+		val, _ := transformPlugins.LoadOrStore(path, tp)
+		return val.(TransformPlugin), nil
+	case ".wasm":
+		return loadWasmTransformPlugin(path)
+	}
+	return nil, g.Error("invalid transform plugin %s, expected a Go plugin (.so) or a WASM module (.wasm)", path)
+}
+
+// loadGoTransformPlugin opens the Go plugin and looks up its Transform function
+func loadGoTransformPlugin(path string) (tp TransformPlugin, err error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, g.Error(err, "could not open transform plugin %s", path)
+	}
+
+	symbol, err := p.Lookup("Transform")
+	if err != nil {
+		return nil, g.Error(err, "transform plugin %s does not export a Transform function", path)
+	}
+
+	switch f := symbol.(type) {
+	case func(map[string]any) (map[string]any, error):
+		return TransformPluginFunc(f), nil
+	case *func(map[string]any) (map[string]any, error):
+		return TransformPluginFunc(*f), nil
+	}
+	return nil, g.Error("Transform of plugin %s must be a `func(map[string]any) (map[string]any, error)`, got %T", path, symbol)
+}
+
+// wasmTransformPlugin runs a WASM module with a WASI runtime, which must be
+// installed separately: `wasmtime run` by default (https://wasmtime.dev,
+// expected in the PATH), or the command in SLING_WASM_RUNTIME. Each row is written to its stdin as a
+// JSON line, and it must write back one JSON line per row:
+// `{"row": {...}}`, `{"row": null}` to drop the row, or `{"error": "..."}`.
+type wasmTransformPlugin struct {
+	path   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	mux    sync.Mutex
+}
+
+type wasmTransformResponse struct {
+	Row   map[string]any `json:"row"`
+	Error string         `json:"error"`
+}
+
+func loadWasmTransformPlugin(path string) (tp TransformPlugin, err error) {
+	if _, err = os.Stat(path); err != nil {
+		return nil, g.Error(err, "could not find transform plugin %s", path)
+	}
+
+	runtime := strings.Fields(os.Getenv("SLING_WASM_RUNTIME"))
+	if len(runtime) == 0 {
+		runtime = []string{"wasmtime", "run"}
+	}
+
+	wp := &wasmTransformPlugin{path: path}
+	wp.cmd = exec.Command(runtime[0], append(runtime[1:], path)...)
+
+	if wp.stdin, err = wp.cmd.StdinPipe(); err != nil {
+		return nil, g.Error(err, "could not open stdin of transform plugin %s", path)
+	}
+	stdout, err := wp.cmd.StdoutPipe()
+	if err != nil {
+		return nil, g.Error(err, "could not open stdout of transform plugin %s", path)
+	}
+	stderr, err := wp.cmd.StderrPipe()
+	if err != nil {
+		return nil, g.Error(err, "could not open stderr of transform plugin %s", path)
+	}
+	wp.stdout = bufio.NewReader(stdout)
+
+	if err = wp.cmd.Start(); err != nil {
+		return nil, g.Error(err, "could not start transform plugin %s with %s. Make sure the WASM runtime is installed and in the PATH (see https://wasmtime.dev), or set SLING_WASM_RUNTIME", path, runtime[0])
+	}
+
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			g.Debug("transform plugin: %s", scanner.Text())
+		}
+	}()
+
+	return wp, nil
+}
+
+func (wp *wasmTransformPlugin) Transform(row map[string]any) (newRow map[string]any, err error) {
+	payload, err := json.Marshal(row)
+	if err != nil {
+		return nil, g.Error(err, "could not encode row for transform plugin")
+	}
+
+	wp.mux.Lock()
+	defer wp.mux.Unlock()
+
+	if _, err = wp.stdin.Write(append(payload, '\n')); err != nil {
+		return nil, g.Error(err, "could not write row to transform plugin %s", wp.path)
+	}
+
+	line, err := wp.stdout.ReadBytes('\n')
+	if err != nil {
+		return nil, g.Error(err, "could not read row from transform plugin %s", wp.path)
+	}
+
+	var resp wasmTransformResponse
+	decoder := json.NewDecoder(strings.NewReader(string(line)))
+	decoder.UseNumber()
+	if err = decoder.Decode(&resp); err != nil {
+		return nil, g.Error(err, "invalid response from transform plugin %s: %s", wp.path, strings.TrimSpace(string(line)))
+	} else if resp.Error != "" {
+		return nil, g.Error(resp.Error)
+	}
+
+	return resp.Row, nil
+}
+
+// Close ends the input of the runtime process and waits for it to exit,
+// killing it if it is still running after 5 seconds
+func (wp *wasmTransformPlugin) Close() (err error) {
+	wp.mux.Lock()
+	defer wp.mux.Unlock()
+
+	if wp.cmd == nil || wp.cmd.Process == nil {
+		return nil
+	}
+	wp.stdin.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- wp.cmd.Wait() }()
+
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		wp.cmd.Process.Kill()
+		err = <-done
+	}
+	wp.cmd = nil
+
+	if err != nil {
+		return g.Error(err, "transform plugin %s did not exit cleanly", wp.path)
+	}
+	return nil
+}
+
+// applyTransformPlugin applies the transform plugin on the casted row.
+// A nil row is returned if the row is dropped (or skipped on error).
+func (ds *Datastream) applyTransformPlugin(row []any) ([]any, error) {
+	rec := make(map[string]any, len(ds.Columns))
+	colIndex := make(map[string]int, len(ds.Columns))
+	for i, col := range ds.Columns {
+		colIndex[col.Name] = i
+		if i < len(row) {
+			rec[col.Name] = row[i]
+		}
+	}
+
+	newRec, err := ds.transformPlugin.Transform(rec)
+	if err != nil {
+		if os.Getenv("SLING_ON_CONSTRAINT_FAILURE") == "skip" {
+			if ds.transformPluginFailCnt++; ds.transformPluginFailCnt <= 20 {
+				env.Warn(env.WarningRejects, "skipping row %d, transform plugin failed: %s", ds.Sp.N, err.Error())
+			}
+			return nil, nil
+		}
+		return nil, g.Error(err, "transform plugin failed at row %d", ds.Sp.N)
+	} else if newRec == nil {
+		return nil, nil // dropped by the plugin
+	}
+
+	for name, val := range newRec {
+		i, ok := colIndex[name]
+		if !ok {
+			return nil, g.Error("transform plugin returned unknown column '%s' at row %d (plugins cannot add columns)", name, ds.Sp.N)
+		}
+		for len(row) <= i {
+			row = append(row, nil)
+		}
+		row[i] = ds.Sp.CastVal(i, val, &ds.Columns[i])
+	}
+
+	return row, nil
+}
@@ -0,0 +1,154 @@
+package iop
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransformPlugin(t *testing.T) {
+	payload := "name,amount\nalice,10\nbob,-5\n,3\ncarol,7\n"
+
+	read := func(path string) (Dataset, error) {
+		ds := NewDatastream(nil)
+		ds.SetConfig(map[string]string{"transform_plugin": path})
+		if err := ds.ConsumeCsvReader(strings.NewReader(payload)); err != nil {
+			return Dataset{}, err
+		}
+		return ds.Collect(0)
+	}
+
+	// same behavior as the sample plugin (examples/transform_plugin)
+	path := filepath.Join(t.TempDir(), "test_plugin.so")
+	transformPlugins.Store(path, TransformPluginFunc(func(row map[string]any) (map[string]any, error) {
+		name := cast.ToString(row["name"])
+		if name == "" {
+			return nil, g.Error("missing name")
+		} else if cast.ToFloat64(row["amount"]) < 0 {
+			return nil, nil
+		}
+		return map[string]any{"name": strings.ToUpper(name)}, nil
+	}))
+
+	// a plugin error fails the run
+	_, err := read(path)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "missing name")
+	}
+
+	os.Setenv("SLING_ON_CONSTRAINT_FAILURE", "abort")
+	_, err = read(path)
+	assert.Error(t, err)
+
+	// or skips the row, as a failed constraint
+	os.Setenv("SLING_ON_CONSTRAINT_FAILURE", "skip")
+	data, err := read(path)
+	if assert.NoError(t, err) && assert.Len(t, data.Rows, 2) {
+		assert.Equal(t, "ALICE", data.Rows[0][0])
+		assert.EqualValues(t, 10, data.Rows[0][1])
+		assert.Equal(t, "CAROL", data.Rows[1][0])
+	}
+	os.Unsetenv("SLING_ON_CONSTRAINT_FAILURE")
+
+	// plugins cannot add columns
+	path = filepath.Join(t.TempDir(), "add_column.so")
+	transformPlugins.Store(path, TransformPluginFunc(func(row map[string]any) (map[string]any, error) {
+		return map[string]any{"extra": 1}, nil
+	}))
+	_, err = read(path)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown column 'extra'")
+	}
+
+	_, err = LoadTransformPlugin("transform.py")
+	assert.Error(t, err)
+}
+
+func TestTransformPluginGo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("go plugins are not supported on windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "transform.so")
+	cmd := exec.Command("go", "build", "-buildmode=plugin", "-o", path, "../../../examples/transform_plugin")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("could not build sample plugin: %s\n%s", err, string(out))
+	}
+
+	tp, err := LoadTransformPlugin(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	row, err := tp.Transform(map[string]any{"name": "alice", "amount": int64(10)})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "ALICE", row["name"])
+	}
+
+	row, err = tp.Transform(map[string]any{"name": "bob", "amount": float64(-5)})
+	assert.NoError(t, err)
+	assert.Nil(t, row)
+
+	_, err = tp.Transform(map[string]any{"name": nil, "amount": int64(3)})
+	assert.Error(t, err)
+}
+
+func TestTransformPluginWasm(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test runtime is a shell script")
+	}
+
+	// a runtime which wraps each row in the response, or fails it
+	folder := t.TempDir()
+	runtimePath := filepath.Join(folder, "runtime.sh")
+	script := `#!/bin/sh
+while read -r line; do
+  case "$line" in
+    *fail*) echo '{"error": "cannot transform"}' ;;
+    *) echo "{\"row\": $line}" ;;
+  esac
+done
+`
+	os.WriteFile(runtimePath, []byte(script), 0755)
+	os.Setenv("SLING_WASM_RUNTIME", runtimePath)
+	defer os.Unsetenv("SLING_WASM_RUNTIME")
+
+	path := filepath.Join(folder, "transform.wasm")
+	os.WriteFile(path, []byte{}, 0644)
+
+	tp, err := LoadTransformPlugin(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	row, err := tp.Transform(map[string]any{"name": "alice", "amount": 10})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "alice", row["name"])
+		assert.Equal(t, "10", cast.ToString(row["amount"]))
+	}
+
+	_, err = tp.Transform(map[string]any{"name": "fail"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot transform")
+	}
+
+	// each load starts its own process, stopped on close
+	wp := tp.(*wasmTransformPlugin)
+	cmd := wp.cmd
+	assert.NoError(t, tp.Close())
+	assert.NotNil(t, cmd.ProcessState)
+	assert.NoError(t, tp.Close())
+
+	tp2, err := LoadTransformPlugin(path)
+	if assert.NoError(t, err) {
+		assert.NotSame(t, wp, tp2)
+		assert.NoError(t, tp2.Close())
+	}
+}
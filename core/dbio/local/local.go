@@ -10,6 +10,7 @@ import (
 
 	"github.com/slingdata-io/sling-cli/core/dbio"
 	"github.com/slingdata-io/sling-cli/core/dbio/connection"
+	"github.com/slingdata-io/sling-cli/core/env"
 
 	"github.com/flarco/g"
 	"github.com/flarco/g/process"
@@ -256,7 +257,7 @@ func (p *Profile) ListConnections(includeEnv bool) (cArr []connection.Connection
 
 	// from Environment
 	if includeEnv {
-		for name, val := range g.KVArrToMap(os.Environ()...) {
+		for name, val := range env.Environ() {
 			conn, err := connection.NewConnectionFromURL(strings.ToUpper(name), val)
 			if err != nil {
 				continue
@@ -3,8 +3,10 @@ package env
 import (
 	"embed"
 	"fmt"
+	"net/url"
 	"os"
 	"path"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -31,6 +33,9 @@ var (
 	NoDebugKey     = " /* nD */"
 )
 
+// HomeDirExplicit is true when SLING_HOME_DIR is set, which disables the project file
+var HomeDirExplicit = os.Getenv("SLING_HOME_DIR") != ""
+
 const (
 	DdlDefDecLength = 20
 	DdlMinDecLength = 24
@@ -91,7 +96,9 @@ func SetLogger() {
 	if os.Getenv("_DEBUG_CALLER_LEVEL") != "" {
 		g.CallerLevel = cast.ToInt(os.Getenv("_DEBUG_CALLER_LEVEL"))
 	}
-	if os.Getenv("DEBUG") == "TRACE" {
+	if IsQuiet() && os.Getenv("DEBUG") == "" {
+		g.SetZeroLogLevel(zerolog.WarnLevel) // only warnings & errors
+	} else if os.Getenv("DEBUG") == "TRACE" {
 		g.SetZeroLogLevel(zerolog.TraceLevel)
 		g.SetLogLevel(g.TraceLevel)
 	} else if os.Getenv("DEBUG") != "" {
@@ -133,6 +140,18 @@ func SetLogger() {
 	}
 }
 
+// IsQuiet returns true if non-error output should be suppressed.
+// SLING_QUIET can be `true` or `summary` (keeps the final summary line).
+func IsQuiet() bool {
+	val := strings.ToLower(os.Getenv("SLING_QUIET"))
+	return cast.ToBool(val) || val == "summary"
+}
+
+// QuietSummary returns true if the final summary should be printed in quiet mode
+func QuietSummary() bool {
+	return strings.ToLower(os.Getenv("SLING_QUIET")) == "summary"
+}
+
 // InitLogger initializes the g Logger
 func InitLogger() {
 
@@ -257,9 +276,58 @@ func readConnectionsMap(env map[string]interface{}) (conns map[string]map[string
 	return
 }
 
+// runTempFolder is the folder of the staging files of the run, created in the
+// temp dir override by InitTempFolder
+var runTempFolder string
+
+// TempDirOverride returns the folder to write the staging files in, instead
+// of the system temp folder (SLING_TMP_DIR, or SLING_TEMP_DIR)
+func TempDirOverride() string {
+	if val := os.Getenv("SLING_TMP_DIR"); val != "" {
+		return val
+	}
+	return os.Getenv("SLING_TEMP_DIR")
+}
+
+// InitTempFolder validates that the temp dir override is writable, and creates
+// the folder of the run in it. All the staging files of the run are written in
+// that folder, which is removed by CleanupTempFolder (also on interrupt).
+func InitTempFolder() (err error) {
+	CleanupTempFolder()
+
+	dir := TempDirOverride()
+	if dir == "" {
+		return nil
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return g.Error(err, "could not create temp dir: %s", dir)
+	}
+
+	folder, err := os.MkdirTemp(dir, "sling_")
+	if err != nil {
+		return g.Error(err, "temp dir is not writable: %s", dir)
+	}
+	runTempFolder = CleanWindowsPath(folder)
+	g.Debug("using temp folder: %s", runTempFolder)
+
+	return nil
+}
+
+// CleanupTempFolder removes the folder of the run created by InitTempFolder,
+// with any staging file left in it
+func CleanupTempFolder() {
+	if runTempFolder != "" {
+		RemoveAllLocalTempFile(runTempFolder)
+		runTempFolder = ""
+	}
+}
+
 func GetTempFolder() string {
 	tempDir := os.TempDir()
-	if val := os.Getenv("SLING_TEMP_DIR"); val != "" {
+	if runTempFolder != "" {
+		tempDir = runTempFolder
+	} else if val := TempDirOverride(); val != "" {
 		tempDir = val
 	}
 	tempDir = strings.TrimRight(strings.TrimRight(tempDir, "/"), "\\")
@@ -278,6 +346,9 @@ func processLogEntry(ll *g.LogLine) {
 	if LogSink != nil {
 		LogSink(ll)
 	}
+	if Warnings != nil && int(ll.Level) == int(zerolog.WarnLevel) {
+		Warnings.Add(ll.Line())
+	}
 }
 
 // RemoveLocalTempFile deletes the local file
@@ -350,9 +421,85 @@ func Clean(props map[string]string, line string) string {
 	for k, v := range props {
 		if strings.TrimSpace(v) == "" {
 			continue
-		} else if g.In(k, "password", "access_key_id", "secret_access_key", "session_token", "aws_access_key_id", "aws_secret_access_key", "ssh_private_key", "ssh_passphrase", "sas_svc_url", "conn_str") {
+		} else if IsSecretKey(k) {
 			line = strings.ReplaceAll(line, v, "***")
 		}
 	}
-	return line
+	return urlRegex.ReplaceAllStringFunc(line, RedactURL)
+}
+
+// urlRegex matches the urls of a log line
+var urlRegex = regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s'"]+`)
+
+// secretKeySnippets are lower-case snippets of property keys holding secrets
+var secretKeySnippets = []string{
+	"password", "passwd", "secret", "token", "private_key", "passphrase",
+	"credential", "api_key", "apikey", "access_key", "account_key", "sas_",
+	"conn_str", "keyfile_json",
+}
+
+// secretParamKeys are lower-case url query parameter keys holding secrets,
+// besides the secret property keys, such as of signed urls (e.g. `sig`)
+var secretParamKeys = []string{"sig", "sas", "key", "code", "auth"}
+
+// IsSecretKey returns true if the property key looks like it holds a secret.
+// Keys pointing to a file (e.g. `private_key_path`) are not secrets.
+func IsSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	if strings.HasSuffix(key, "_path") || strings.HasSuffix(key, "_file") {
+		return false
+	}
+	for _, snippet := range secretKeySnippets {
+		if strings.Contains(key, snippet) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactURL masks the password in a URL, and the values of its secret
+// query parameters (see isSecretParam), such as `sig` or `X-Amz-Signature`
+func RedactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || (u.User == nil && u.RawQuery == "") {
+		return value
+	}
+
+	redacted := false
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "***")
+			redacted = true
+		}
+	}
+
+	if query := u.Query(); len(query) > 0 {
+		queryRedacted := false
+		for key, vals := range query {
+			if isSecretParam(key) && len(vals) > 0 && !IsEnvReference(vals[0]) {
+				query.Set(key, "***")
+				queryRedacted = true
+			}
+		}
+		if queryRedacted {
+			u.RawQuery = query.Encode()
+			redacted = true
+		}
+	}
+
+	if !redacted {
+		return value
+	}
+	return strings.ReplaceAll(u.String(), "%2A%2A%2A", "***")
+}
+
+// isSecretParam returns true if the url query parameter key looks like it holds a secret
+func isSecretParam(key string) bool {
+	key = strings.ToLower(key)
+	return IsSecretKey(key) || g.In(key, secretParamKeys...) || strings.Contains(key, "signature")
+}
+
+// IsEnvReference returns true if the value is an env-var reference, e.g. `${PG_PASSWORD}`
+func IsEnvReference(value string) bool {
+	return len(g.Matches(value, `^\$(\{\w+\}|\w+)$`)) > 0
 }
@@ -0,0 +1,144 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// ProjectFileNames are the names of the project file, looked up in the working directory
+var ProjectFileNames = []string{"sling.yaml", "sling.env.yaml"}
+
+// Project is the project file of the working directory, if any
+var Project *ProjectFile
+
+// ProjectFile defines the connections, default options & env variables of all
+// the commands run in its directory. Its connections override the ones of the
+// global env file (env variables override both).
+//
+//	connections:
+//	  MY_PG:
+//	    url: postgres://...
+//	defaults:
+//	  target_options:
+//	    column_casing: snake
+//	env:
+//	  SLING_THREADS: 4
+type ProjectFile struct {
+	Connections map[string]map[string]any `json:"connections,omitempty" yaml:"connections,omitempty"`
+	Defaults    ProjectDefaults           `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+	Env         map[string]any            `json:"env,omitempty" yaml:"env,omitempty"`
+
+	Path string `json:"-" yaml:"-"`
+}
+
+// ProjectDefaults are the options applied to every run of the project, under
+// the connection default options & the run options
+type ProjectDefaults struct {
+	SourceOptions map[string]any `json:"source_options,omitempty" yaml:"source_options,omitempty"`
+	TargetOptions map[string]any `json:"target_options,omitempty" yaml:"target_options,omitempty"`
+}
+
+// FindProjectFile returns the path of the project file: SLING_PROJECT_FILE, else
+// the first of ProjectFileNames found in dir. There is no project file when
+// SLING_HOME_DIR is set explicitly (the home directory defines the setup), or
+// when SLING_PROJECT_FILE is false.
+func FindProjectFile(dir string) string {
+	if val := os.Getenv("SLING_PROJECT_FILE"); val != "" {
+		if enabled, err := cast.ToBoolE(val); err != nil {
+			return val // a path
+		} else if !enabled {
+			return ""
+		}
+	} else if HomeDirExplicit {
+		return ""
+	}
+
+	for _, name := range ProjectFileNames {
+		path := filepath.Join(dir, name)
+		if g.PathExists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// LoadProjectFile parses the project file, and sets its env variables which
+// are not already set. A replication file (with streams) is not a project file.
+func LoadProjectFile(path string) (pf *ProjectFile, err error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, g.Error(err, "could not read project file %s", path)
+	}
+
+	keys := map[string]any{}
+	if err = yaml.Unmarshal(bytes, &keys); err != nil {
+		return nil, g.Error(err, "could not parse project file %s", path)
+	} else if _, ok := keys["streams"]; ok {
+		g.Debug("%s is a replication, not a project file", path)
+		return nil, nil
+	}
+
+	pf = &ProjectFile{Path: path}
+	if err = yaml.Unmarshal(bytes, pf); err != nil {
+		return nil, g.Error(err, "could not parse project file %s", path)
+	}
+
+	// normalize nested maps for JSON conversion
+	pf.Defaults.SourceOptions = cast.ToStringMap(stringKeys(pf.Defaults.SourceOptions))
+	pf.Defaults.TargetOptions = cast.ToStringMap(stringKeys(pf.Defaults.TargetOptions))
+
+	for key, val := range pf.Env {
+		if _, found := os.LookupEnv(key); !found {
+			os.Setenv(key, cast.ToString(val))
+		}
+	}
+
+	return pf, nil
+}
+
+// LoadProject loads the project file of the working directory into Project.
+// It is called by the CLI at startup, not on import, so that the programs
+// embedding sling do not pick up the files of their working directory.
+func LoadProject() {
+	dir, _ := os.Getwd()
+	path := FindProjectFile(dir)
+	if path == "" {
+		return
+	}
+
+	pf, err := LoadProjectFile(path)
+	if err != nil {
+		g.Warn(err.Error())
+	} else if pf != nil {
+		Project = pf
+	}
+}
+
+// stringKeys converts nested yaml maps to have string keys
+func stringKeys(val any) any {
+	switch v := val.(type) {
+	case map[any]any:
+		m := map[string]any{}
+		for k, val := range v {
+			m[strings.TrimSpace(cast.ToString(k))] = stringKeys(val)
+		}
+		return m
+	case map[string]any:
+		m := map[string]any{}
+		for k, val := range v {
+			m[k] = stringKeys(val)
+		}
+		return m
+	case []any:
+		for i := range v {
+			v[i] = stringKeys(v[i])
+		}
+		return v
+	}
+	return val
+}
@@ -1,6 +1,11 @@
 package env
 
-import "os"
+import (
+	"os"
+	"strings"
+
+	"github.com/flarco/g"
+)
 
 var envVars = []string{
 	"PARALLEL", "CONCURRENCY", "USE_BUFFERED_STREAM", "CONCURRENCY_LIMIT",
@@ -8,7 +13,7 @@ var envVars = []string{
 	"BUCKET", "ACCESS_KEY_ID", "SECRET_ACCESS_KEY", "SESSION_TOKEN", "ENDPOINT", "REGION", "DEFAULT_REGION",
 
 	"AWS_BUCKET", "AWS_ACCESS_KEY_ID",
-	"AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_ENDPOINT", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_PROFILE",
+	"AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_ENDPOINT", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_PROFILE", "AWS_IAM_ROLE",
 
 	"COMPRESSION", "FILE_MAX_ROWS", "SAMPLE_SIZE",
 
@@ -40,7 +45,7 @@ func Vars() (vars map[string]string) {
 	vars = map[string]string{}
 	// get default from environment
 	for _, k := range envVars {
-		val := os.Getenv(k)
+		val := Getenv(k)
 		if vars[k] == "" && val != "" {
 			vars[k] = val
 		}
@@ -59,3 +64,44 @@ func Vars() (vars map[string]string) {
 
 	return
 }
+
+// Prefix returns the prefix of the env vars read preferentially for connections
+// (SLING_ENV_PREFIX, flag `--env-prefix`), to isolate instances on one host
+func Prefix() string {
+	return strings.TrimSpace(os.Getenv("SLING_ENV_PREFIX"))
+}
+
+// Getenv returns the value of the prefixed env var (e.g. `MYAPP_AWS_BUCKET`)
+// when set, otherwise of the unprefixed one
+func Getenv(key string) string {
+	if prefix := Prefix(); prefix != "" {
+		if val, ok := os.LookupEnv(prefix + key); ok {
+			return val
+		}
+	}
+	return os.Getenv(key)
+}
+
+// Environ returns the environment variables as a map. With a prefix, the
+// prefixed vars are returned without it, taking precedence over the unprefixed ones.
+func Environ() map[string]string {
+	environ := g.KVArrToMap(os.Environ()...)
+
+	prefix := Prefix()
+	if prefix == "" {
+		return environ
+	}
+
+	vars := map[string]string{}
+	for key, val := range environ {
+		if !strings.HasPrefix(key, prefix) {
+			vars[key] = val
+		}
+	}
+	for key, val := range environ {
+		if name := strings.TrimPrefix(key, prefix); name != key && name != "" {
+			vars[name] = val
+		}
+	}
+	return vars
+}
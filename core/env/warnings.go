@@ -0,0 +1,139 @@
+package env
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+)
+
+// WarningCategory is the category of a logged warning
+type WarningCategory string
+
+const (
+	WarningTypes   WarningCategory = "types"   // type coercions & mismatches
+	WarningSchema  WarningCategory = "schema"  // schema mismatches & drift
+	WarningRejects WarningCategory = "rejects" // skipped rows & constraint failures
+	WarningOther   WarningCategory = "other"
+)
+
+// WarningCategories are the valid warning categories
+var WarningCategories = []WarningCategory{WarningTypes, WarningSchema, WarningRejects, WarningOther}
+
+// Warn logs the warning, collecting it under its category. Warnings logged
+// with g.Warn directly are collected under WarningOther.
+func Warn(category WarningCategory, format string, args ...any) {
+	if Warnings != nil {
+		Warnings.AddCategory(category, g.F(format, args...))
+	}
+	g.Warn(format, args...)
+}
+
+// Warning is a warning logged during a run
+type Warning struct {
+	Category WarningCategory `json:"category"`
+	Text     string          `json:"text"`
+}
+
+// WarningCollector collects the warnings logged during a run, to fail it at
+// the end (with `--fail-on-warning`)
+type WarningCollector struct {
+	categories []WarningCategory // the categories failing the run
+	warnings   []Warning
+	raised     map[string]int // texts collected by Warn, not to collect again when logged
+	mux        sync.Mutex
+}
+
+// Warnings is the collector of the run warnings, if enabled
+var Warnings *WarningCollector
+
+// NewWarningCollector returns a collector of the warnings of the categories:
+// `all` (or true), or a comma-separated subset of WarningCategories
+func NewWarningCollector(spec string) (wc *WarningCollector, err error) {
+	wc = &WarningCollector{raised: map[string]int{}}
+
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if spec == "all" || spec == "" || cast.ToBool(spec) {
+		wc.categories = WarningCategories
+		return wc, nil
+	}
+
+	for _, val := range strings.Split(spec, ",") {
+		category := WarningCategory(strings.TrimSpace(val))
+		if !g.In(category, WarningCategories...) {
+			return nil, g.Error("invalid warning category '%s', valid categories are: all, types, schema, rejects, other", category)
+		}
+		wc.categories = append(wc.categories, category)
+	}
+
+	return wc, nil
+}
+
+// CollectWarnings starts collecting the warnings of the run, per the value of
+// --fail-on-warning (or SLING_FAIL_ON_WARNING). Collection is disabled if blank or false.
+func CollectWarnings(spec string) (err error) {
+	if val, e := cast.ToBoolE(spec); strings.TrimSpace(spec) == "" || (e == nil && !val) {
+		Warnings = nil
+		return nil
+	}
+
+	Warnings, err = NewWarningCollector(spec)
+	return err
+}
+
+// Add collects a logged warning under WarningOther, unless collected by Warn
+func (wc *WarningCollector) Add(line string) {
+	wc.mux.Lock()
+	defer wc.mux.Unlock()
+
+	for text, cnt := range wc.raised {
+		if strings.Contains(line, text) {
+			if cnt <= 1 {
+				delete(wc.raised, text)
+			} else {
+				wc.raised[text] = cnt - 1
+			}
+			return
+		}
+	}
+
+	if g.In(WarningOther, wc.categories...) {
+		wc.warnings = append(wc.warnings, Warning{Category: WarningOther, Text: strings.TrimSpace(line)})
+	}
+}
+
+// AddCategory collects the warning, if of a failing category
+func (wc *WarningCollector) AddCategory(category WarningCategory, text string) {
+	text = strings.TrimSpace(text)
+
+	wc.mux.Lock()
+	defer wc.mux.Unlock()
+
+	wc.raised[text]++
+	if g.In(category, wc.categories...) {
+		wc.warnings = append(wc.warnings, Warning{Category: category, Text: text})
+	}
+}
+
+// Warnings returns the collected warnings
+func (wc *WarningCollector) Warnings() []Warning {
+	wc.mux.Lock()
+	defer wc.mux.Unlock()
+	return append([]Warning{}, wc.warnings...)
+}
+
+// Err returns an error summarizing the collected warnings, if any
+func (wc *WarningCollector) Err() error {
+	warnings := wc.Warnings()
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	lines := make([]string, len(warnings))
+	for i, w := range warnings {
+		lines[i] = g.F("  - [%s] %s", w.Category, w.Text)
+	}
+
+	return g.Error("%d warning(s) occurred, failing the run (fail-on-warning):\n%s", len(warnings), strings.Join(lines, "\n"))
+}
@@ -0,0 +1,104 @@
+package sling
+
+import (
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio/database"
+	"github.com/spf13/cast"
+)
+
+// AssertionSeverity is the outcome of a failing assertion
+type AssertionSeverity string
+
+const (
+	AssertionSeverityError AssertionSeverity = "error" // fails the run. The default.
+	AssertionSeverityWarn  AssertionSeverity = "warn"  // logs a warning
+)
+
+// Assertion is a data-quality check run against the target after the load.
+// The SQL returns a count (first column of the first row), which must equal
+// Expect, e.g. `select count(*) from {target_table} where amount < 0` expecting 0.
+type Assertion struct {
+	Name     string            `json:"name,omitempty" yaml:"name,omitempty"`
+	SQL      string            `json:"sql" yaml:"sql"`
+	Expect   *int64            `json:"expect,omitempty" yaml:"expect,omitempty"`     // defaults to 0
+	Severity AssertionSeverity `json:"severity,omitempty" yaml:"severity,omitempty"` // error (default) or warn
+}
+
+// Assertions are the data-quality checks of a task or replication stream
+type Assertions []Assertion
+
+// Validate checks the assertions definitions
+func (as Assertions) Validate() error {
+	for i, a := range as {
+		if strings.TrimSpace(a.SQL) == "" {
+			return g.Error("assertion #%d (%s) has no sql", i+1, a.Name)
+		}
+		switch a.Severity {
+		case "", AssertionSeverityError, AssertionSeverityWarn:
+		default:
+			return g.Error("invalid severity for assertion #%d (%s): %s. Valid values are: error, warn", i+1, a.Name, a.Severity)
+		}
+	}
+	return nil
+}
+
+// label returns the name of the assertion, else its position
+func (a Assertion) label(i int) string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return g.F("#%d", i+1)
+}
+
+// Failure returns the failure message when the count differs from the
+// expected count, else an empty string
+func (a Assertion) Failure(i int, count int64) string {
+	if expect := g.PtrVal(a.Expect); count != expect {
+		return g.F("assertion %s failed: expected %d, got %d", a.label(i), expect, count)
+	}
+	return ""
+}
+
+// runAssertions runs the assertions against the target, once loaded. All
+// assertions are run, failures with the error severity fail the run.
+func runAssertions(t *TaskExecution, cfg *Config, tgtConn database.Connection, targetTable database.Table) error {
+	if len(cfg.Assertions) == 0 {
+		return nil
+	}
+
+	values := t.GetStateMap()
+	values["target_table"] = targetTable.FullName()
+
+	t.SetProgress("running %d assertion(s) on %s", len(cfg.Assertions), targetTable.FullName())
+
+	failures := []string{}
+	for i, assertion := range cfg.Assertions {
+		sql := g.Rm(assertion.SQL, values)
+		data, err := tgtConn.Query(sql)
+		if err != nil {
+			return g.Error(err, "could not run assertion %s", assertion.label(i))
+		} else if len(data.Rows) == 0 || len(data.Rows[0]) == 0 {
+			return g.Error("assertion %s returned no rows, expected a count", assertion.label(i))
+		}
+
+		count, err := cast.ToInt64E(data.Rows[0][0])
+		if err != nil {
+			return g.Error(err, "assertion %s did not return a count: %v", assertion.label(i), data.Rows[0][0])
+		}
+
+		if failure := assertion.Failure(i, count); failure == "" {
+			g.Debug("assertion %s passed", assertion.label(i))
+		} else if assertion.Severity == AssertionSeverityWarn {
+			g.Warn(failure)
+		} else {
+			failures = append(failures, failure)
+		}
+	}
+
+	if len(failures) > 0 {
+		return g.Error("data was loaded into %s, but %d assertion(s) failed:\n%s", targetTable.FullName(), len(failures), strings.Join(failures, "\n"))
+	}
+	return nil
+}
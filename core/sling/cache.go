@@ -0,0 +1,174 @@
+package sling
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio/database"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/slingdata-io/sling-cli/core/env"
+	"github.com/spf13/cast"
+)
+
+// ColumnCache is a local cache of discovered stream columns, keyed by
+// connection + object, to skip re-discovery on repeated runs.
+// It is enabled by setting SLING_CACHE_TTL (e.g. `30m`, `12h`).
+// Entries are only used while the checksum of the live source metadata
+// (see SourceMetaChecksum) matches the one at caching time.
+type ColumnCache struct {
+	Folder string
+	TTL    time.Duration
+}
+
+type columnCacheEntry struct {
+	Key      string      `json:"key"`
+	Columns  iop.Columns `json:"columns"`
+	Sourced  []bool      `json:"sourced"`  // not serialized in columns
+	Checksum string      `json:"checksum"` // of the live source metadata
+	CachedAt time.Time   `json:"cached_at"`
+}
+
+// NewColumnCache returns the column cache, or nil if disabled
+// (SLING_CACHE_TTL not set, or SLING_NO_CACHE is true)
+func NewColumnCache() *ColumnCache {
+	if cast.ToBool(os.Getenv("SLING_NO_CACHE")) || os.Getenv("SLING_CACHE_TTL") == "" {
+		return nil
+	}
+
+	ttl, err := time.ParseDuration(os.Getenv("SLING_CACHE_TTL"))
+	if err != nil || ttl <= 0 {
+		g.Warn("invalid value for SLING_CACHE_TTL: %s", os.Getenv("SLING_CACHE_TTL"))
+		return nil
+	}
+
+	return &ColumnCache{Folder: ColumnCacheFolder(), TTL: ttl}
+}
+
+// ColumnCacheFolder returns the folder of the column cache files
+func ColumnCacheFolder() string {
+	return path.Join(env.HomeDir, "cache", "columns")
+}
+
+// ColumnCacheKey returns the cache key for a connection and object
+func ColumnCacheKey(connHash, object string) string {
+	return g.MD5(connHash, strings.TrimSpace(object))
+}
+
+// ColumnsChecksum returns a cheap checksum of the column names & types
+func ColumnsChecksum(cols iop.Columns) string {
+	parts := make([]string, len(cols))
+	for i, col := range cols {
+		parts[i] = strings.ToLower(col.Name) + ":" + string(col.Type) + ":" + strings.ToLower(col.DbType)
+	}
+	return g.MD5(parts...)
+}
+
+// SourceMetaChecksum returns the checksum of the live metadata of the source
+// table: its columns from the metadata query (information schema), much
+// cheaper than running a custom SQL to get its columns. For custom SQL, the
+// checksum of the query text is returned, so the columns of a changed query
+// are not reused. Returns an empty string if the metadata is unavailable.
+func SourceMetaChecksum(conn database.Connection, table database.Table) string {
+	if table.IsQuery() {
+		return g.MD5(string(conn.GetType()), strings.TrimSpace(table.SQL))
+	}
+
+	cols, err := conn.GetColumns(table.FullName())
+	if err != nil {
+		g.Debug("could not get the metadata of %s for the column cache: %s", table.FullName(), err.Error())
+		return ""
+	}
+	return ColumnsChecksum(cols)
+}
+
+func (cc *ColumnCache) path(key string) string {
+	return path.Join(cc.Folder, key+".json")
+}
+
+// Get returns the cached columns for the key, if fresh and cached with the
+// same source metadata checksum
+func (cc *ColumnCache) Get(key, checksum string) (cols iop.Columns, ok bool) {
+	if cc == nil || checksum == "" {
+		return nil, false
+	}
+
+	bytes, err := os.ReadFile(cc.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry columnCacheEntry
+	if err = json.Unmarshal(bytes, &entry); err != nil {
+		cc.Invalidate(key)
+		return nil, false
+	}
+
+	if time.Since(entry.CachedAt) > cc.TTL {
+		cc.Invalidate(key)
+		return nil, false
+	} else if entry.Checksum != checksum {
+		g.Debug("schema change detected, invalidating cached columns")
+		cc.Invalidate(key)
+		return nil, false
+	}
+
+	for i := range entry.Columns {
+		entry.Columns[i].Sourced = i < len(entry.Sourced) && entry.Sourced[i]
+	}
+
+	return entry.Columns, true
+}
+
+// Set stores the columns for the key, with the source metadata checksum
+func (cc *ColumnCache) Set(key string, cols iop.Columns, checksum string) error {
+	if cc == nil || checksum == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(cc.Folder, 0755); err != nil {
+		return g.Error(err, "could not create cache folder")
+	}
+
+	entry := columnCacheEntry{
+		Key:      key,
+		Columns:  cols,
+		Sourced:  make([]bool, len(cols)),
+		Checksum: checksum,
+		CachedAt: time.Now(),
+	}
+
+	for i, col := range cols {
+		entry.Sourced[i] = col.Sourced
+	}
+
+	// write to a temp file then rename, so concurrent runs never read a partial entry
+	tmpPath := g.F("%s.%d.tmp", cc.path(key), os.Getpid())
+	if err := os.WriteFile(tmpPath, []byte(g.Marshal(entry)), 0644); err != nil {
+		return g.Error(err, "could not write cache file")
+	}
+	if err := os.Rename(tmpPath, cc.path(key)); err != nil {
+		os.Remove(tmpPath)
+		return g.Error(err, "could not write cache file")
+	}
+	return nil
+}
+
+// Invalidate removes the cache entry for the key
+func (cc *ColumnCache) Invalidate(key string) {
+	if cc == nil {
+		return
+	}
+	os.Remove(cc.path(key))
+}
+
+// ClearColumnCache removes all the column cache files
+func ClearColumnCache() (err error) {
+	if err = os.RemoveAll(ColumnCacheFolder()); err != nil {
+		return g.Error(err, "could not clear cache folder")
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package sling
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnCache(t *testing.T) {
+	folder, err := os.MkdirTemp("", "sling_column_cache")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(folder)
+
+	cache := &ColumnCache{Folder: folder, TTL: time.Hour}
+	key := ColumnCacheKey("conn-hash", "public.my_table")
+	cols := iop.Columns{
+		{Name: "id", Type: iop.BigIntType, Position: 1, Sourced: true},
+		{Name: "name", Type: iop.StringType, Position: 2, Sourced: true},
+	}
+
+	checksum := ColumnsChecksum(cols)
+
+	_, ok := cache.Get(key, checksum)
+	assert.False(t, ok)
+
+	g.AssertNoError(t, cache.Set(key, cols, checksum))
+	cached, ok := cache.Get(key, checksum)
+	if assert.True(t, ok) && assert.Len(t, cached, 2) {
+		assert.Equal(t, cols.Names(), cached.Names())
+		assert.True(t, cached[0].Sourced)
+	}
+
+	// a schema change of the source metadata invalidates
+	changed := append(iop.Columns{}, cols...)
+	changed = append(changed, iop.Column{Name: "email", Type: iop.StringType, Position: 3})
+	_, ok = cache.Get(key, ColumnsChecksum(changed))
+	assert.False(t, ok)
+	_, ok = cache.Get(key, checksum)
+	assert.False(t, ok) // removed
+
+	// without metadata, the cache is not used
+	g.AssertNoError(t, cache.Set(key, cols, ""))
+	_, ok = cache.Get(key, "")
+	assert.False(t, ok)
+
+	// ttl expiry
+	cache.TTL = 50 * time.Millisecond
+	g.AssertNoError(t, cache.Set(key, cols, checksum))
+	_, ok = cache.Get(key, checksum)
+	assert.True(t, ok)
+	time.Sleep(100 * time.Millisecond)
+	_, ok = cache.Get(key, checksum)
+	assert.False(t, ok)
+
+	// disabled
+	os.Setenv("SLING_CACHE_TTL", "10m")
+	os.Setenv("SLING_NO_CACHE", "true")
+	defer os.Unsetenv("SLING_CACHE_TTL")
+	defer os.Unsetenv("SLING_NO_CACHE")
+	assert.Nil(t, NewColumnCache())
+	os.Unsetenv("SLING_NO_CACHE")
+	if cc := NewColumnCache(); assert.NotNil(t, cc) {
+		assert.Equal(t, 10*time.Minute, cc.TTL)
+	}
+
+	// nil cache is a no-op
+	var nilCache *ColumnCache
+	_, ok = nilCache.Get(key, checksum)
+	assert.False(t, ok)
+	g.AssertNoError(t, nilCache.Set(key, cols, checksum))
+}
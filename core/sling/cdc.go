@@ -0,0 +1,143 @@
+package sling
+
+import (
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio/database"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+)
+
+// readsChanges returns true when the changes are read from a replication
+// slot (cdc_slot) or SQL Server change tracking (change_tracking)
+func (cfg *Config) readsChanges() bool {
+	if cfg.Source.Options == nil {
+		return false
+	}
+	return g.PtrVal(cfg.Source.Options.CdcSlot) != "" || g.PtrVal(cfg.Source.Options.ChangeTracking)
+}
+
+// cdcStateKey returns the state key of the slot position of the stream
+func (cfg *Config) cdcStateKey() string {
+	return cfg.StateKey() + " (cdc)"
+}
+
+// cdcStateStore returns the state store holding the slot positions.
+// Since a position is required, the default state table of the target
+// is used when the state store is not enabled.
+func (t *TaskExecution) cdcStateStore(tgtConn database.Connection) (store *StateStore, err error) {
+	store, err = NewStateStore(t.Config, tgtConn)
+	if err != nil || store != nil {
+		return store, err
+	}
+	tableName := stateTableInTargetSchema(t.Config, tgtConn.GetType(), DefaultStateTable)
+	return NewStateStoreFromConn(tgtConn, tableName)
+}
+
+// getCDCPosition reads the stored LSN (or change tracking version) to read the changes after
+func (t *TaskExecution) getCDCPosition(tgtConn database.Connection) (err error) {
+	store, err := t.cdcStateStore(tgtConn)
+	if err != nil {
+		return g.Error(err, "could not initialize state store")
+	}
+	defer store.Close()
+
+	state, ok, err := store.Read(t.Config.cdcStateKey())
+	if err != nil {
+		return g.Error(err, "could not read cdc position")
+	} else if ok {
+		t.cdcLSN = state.Watermark
+		g.Debug("reading changes after position %s", t.cdcLSN)
+	}
+	return nil
+}
+
+// readFromCDC reads the changes of the table from the replication slot
+func (t *TaskExecution) readFromCDC(srcConn database.Connection, table database.Table, slot string) (df *iop.Dataflow, err error) {
+	pgConn, ok := srcConn.(*database.PostgresConn)
+	if !ok {
+		return t.df, g.Error("cdc_slot is only supported for PostgreSQL sources")
+	}
+
+	if len(table.Columns) == 0 {
+		if table.Columns, err = srcConn.GetColumns(table.FullName()); err != nil {
+			return t.df, g.Error(err, "could not get columns for %s", table.FullName())
+		}
+	}
+
+	ds, lastLSN, err := pgConn.StreamCDC(slot, table, t.cdcLSN, t.Config.Source.PrimaryKey(), t.Config.Source.Limit())
+	if err != nil {
+		return t.df, g.Error(err, "could not read changes from slot %s", slot)
+	}
+	t.cdcLastLSN = lastLSN
+
+	df, err = iop.MakeDataFlow(ds)
+	if err != nil {
+		return t.df, g.Error(err, "could not create data flow")
+	}
+	return df, nil
+}
+
+// readFromChangeTracking reads the changes of the table with SQL Server change tracking
+func (t *TaskExecution) readFromChangeTracking(srcConn database.Connection, table database.Table) (df *iop.Dataflow, err error) {
+	msConn, ok := srcConn.(*database.MsSQLServerConn)
+	if !ok {
+		return t.df, g.Error("change_tracking is only supported for SQL Server sources")
+	}
+
+	if len(table.Columns) == 0 {
+		if table.Columns, err = srcConn.GetColumns(table.FullName()); err != nil {
+			return t.df, g.Error(err, "could not get columns for %s", table.FullName())
+		}
+	}
+
+	ds, lastVersion, err := msConn.StreamChangeTracking(table, t.cdcLSN, t.Config.Source.PrimaryKey())
+	if err != nil {
+		return t.df, g.Error(err, "could not read changes with change tracking")
+	}
+	t.cdcLastLSN = lastVersion
+
+	df, err = iop.MakeDataFlow(ds)
+	if err != nil {
+		return t.df, g.Error(err, "could not create data flow")
+	}
+	return df, nil
+}
+
+// updateCDCPosition stores the LSN (or change tracking version) of the last
+// change loaded, then advances the slot to release the WAL. The position is
+// stored first, so that changes are never skipped if the advance fails (the
+// run still errors, since the WAL would otherwise accumulate unnoticed).
+func (t *TaskExecution) updateCDCPosition(tgtConn, srcConn database.Connection, rowCount uint64) (err error) {
+	slot := g.PtrVal(t.Config.Source.Options.CdcSlot)
+	if !t.Config.readsChanges() || t.cdcLastLSN == "" || t.cdcLastLSN == t.cdcLSN {
+		return nil
+	} else if t.df == nil || t.df.Err() != nil {
+		return nil
+	}
+
+	store, err := t.cdcStateStore(tgtConn)
+	if err != nil {
+		return g.Error(err, "could not initialize state store")
+	}
+	defer store.Close()
+
+	state := IncrementalState{
+		StreamKey:     t.Config.cdcStateKey(),
+		Watermark:     t.cdcLastLSN,
+		WatermarkType: iop.StringType,
+		RunTime:       time.Now().UTC(),
+		RowCount:      rowCount,
+	}
+	if err = store.Write(state); err != nil {
+		return g.Error(err, "could not store cdc position")
+	}
+
+	if pgConn, ok := srcConn.(*database.PostgresConn); ok && slot != "" {
+		if err = pgConn.AdvanceCDCSlot(slot, t.cdcLastLSN); err != nil {
+			return g.Error(err, "could not advance replication slot %s", slot)
+		}
+	}
+
+	return nil
+}
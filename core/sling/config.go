@@ -14,6 +14,7 @@ import (
 	"github.com/slingdata-io/sling-cli/core/dbio/connection"
 	"github.com/slingdata-io/sling-cli/core/dbio/database"
 	"github.com/slingdata-io/sling-cli/core/dbio/filesys"
+	"github.com/slingdata-io/sling-cli/core/env"
 	"github.com/spf13/cast"
 
 	"github.com/flarco/g"
@@ -53,6 +54,99 @@ var AllMode = []struct {
 	{BackfillMode, "BackfillMode"},
 }
 
+// IfExists is the behavior when the target table already exists
+type IfExists string
+
+const (
+	// IfExistsAppend is to insert into the existing table
+	IfExistsAppend IfExists = "append"
+	// IfExistsReplace is to drop and recreate the table
+	IfExistsReplace IfExists = "replace"
+	// IfExistsTruncate is to truncate the table
+	IfExistsTruncate IfExists = "truncate"
+	// IfExistsFail is to error if the table exists
+	IfExistsFail IfExists = "fail"
+)
+
+var AllIfExists = []struct {
+	Value  IfExists
+	TSName string
+}{
+	{IfExistsAppend, "IfExistsAppend"},
+	{IfExistsReplace, "IfExistsReplace"},
+	{IfExistsTruncate, "IfExistsTruncate"},
+	{IfExistsFail, "IfExistsFail"},
+}
+
+// WriteMode is how the loaded rows are written into the target table
+type WriteMode string
+
+const (
+	// WriteModeAtomicSwap is to load a new table, then rename it over the target table
+	WriteModeAtomicSwap WriteMode = "atomic-swap"
+)
+
+var AllWriteMode = []struct {
+	Value  WriteMode
+	TSName string
+}{
+	{WriteModeAtomicSwap, "WriteModeAtomicSwap"},
+}
+
+// SurrogateKeyStrategy is how the surrogate key column of the target is generated
+type SurrogateKeyStrategy string
+
+const (
+	// SurrogateKeyHash is the hash (md5) of the natural key columns, computed in the stream
+	SurrogateKeyHash SurrogateKeyStrategy = "hash"
+	// SurrogateKeyIdentity is an auto-incrementing column (identity or sequence) of the target table
+	SurrogateKeyIdentity SurrogateKeyStrategy = "identity"
+)
+
+var AllSurrogateKeyStrategy = []struct {
+	Value  SurrogateKeyStrategy
+	TSName string
+}{
+	{SurrogateKeyHash, "SurrogateKeyHash"},
+	{SurrogateKeyIdentity, "SurrogateKeyIdentity"},
+}
+
+// LineageCommentPolicy is when the lineage comment of the target table is set
+type LineageCommentPolicy string
+
+const (
+	// LineageCommentCreate comments the table when created, reruns keep the comment
+	LineageCommentCreate LineageCommentPolicy = "create"
+	// LineageCommentUpdate comments the table on every run, with the last load
+	LineageCommentUpdate LineageCommentPolicy = "update"
+)
+
+var AllLineageCommentPolicy = []struct {
+	Value  LineageCommentPolicy
+	TSName string
+}{
+	{LineageCommentCreate, "LineageCommentCreate"},
+	{LineageCommentUpdate, "LineageCommentUpdate"},
+}
+
+// ObjectType is the kind of target object created
+type ObjectType string
+
+const (
+	// ObjectTypeTable is to load the data into a table
+	ObjectTypeTable ObjectType = "table"
+	// ObjectTypeView is to create (or replace) a view of the source query
+	ObjectTypeView ObjectType = "view"
+)
+
+var AllObjectType = []struct {
+	Value  ObjectType
+	TSName string
+}{
+	{ObjectTypeTable, "ObjectTypeTable"},
+	{ObjectTypeView, "ObjectTypeView"},
+}
+
 // NewConfig return a config object from a YAML / JSON string
 func NewConfig(cfgStr string) (cfg *Config, err error) {
 	// set default, unmarshalling will overwrite
@@ -72,6 +166,68 @@ func NewConfig(cfgStr string) (cfg *Config, err error) {
 	return
 }
 
+// applyConnDefaultOptions merges the connection default options
+// (`default_source_options`, `default_target_options`) under the run options.
+// A key set in the run options overrides the connection default.
+func (cfg *Config) applyConnDefaultOptions() (err error) {
+	srcDefaults, err := cfg.SrcConn.DefaultOptions(connection.DefaultSourceOptionsKey)
+	if err != nil {
+		return g.Error(err, "invalid %s for source connection", connection.DefaultSourceOptionsKey)
+	} else if len(srcDefaults) > 0 {
+		options := &SourceOptions{}
+		if err = g.JSONConvert(mergeDefaultOptions(srcDefaults, cfg.Source.Options), options); err != nil {
+			return g.Error(err, "invalid %s for source connection", connection.DefaultSourceOptionsKey)
+		}
+		cfg.Source.Options = options
+	}
+
+	tgtDefaults, err := cfg.TgtConn.DefaultOptions(connection.DefaultTargetOptionsKey)
+	if err != nil {
+		return g.Error(err, "invalid %s for target connection", connection.DefaultTargetOptionsKey)
+	} else if len(tgtDefaults) > 0 {
+		options := &TargetOptions{}
+		if err = g.JSONConvert(mergeDefaultOptions(tgtDefaults, cfg.Target.Options), options); err != nil {
+			return g.Error(err, "invalid %s for target connection", connection.DefaultTargetOptionsKey)
+		}
+		cfg.Target.Options = options
+	}
+
+	// project file defaults, under the connection defaults
+	if project := env.Project; project != nil {
+		if len(project.Defaults.SourceOptions) > 0 {
+			options := &SourceOptions{}
+			if err = g.JSONConvert(mergeDefaultOptions(project.Defaults.SourceOptions, cfg.Source.Options), options); err != nil {
+				return g.Error(err, "invalid source_options defaults in project file %s", project.Path)
+			}
+			cfg.Source.Options = options
+		}
+		if len(project.Defaults.TargetOptions) > 0 {
+			options := &TargetOptions{}
+			if err = g.JSONConvert(mergeDefaultOptions(project.Defaults.TargetOptions, cfg.Target.Options), options); err != nil {
+				return g.Error(err, "invalid target_options defaults in project file %s", project.Path)
+			}
+			cfg.Target.Options = options
+		}
+	}
+
+	return nil
+}
+
+// mergeDefaultOptions overlays the run options (keys which are set) on the defaults
+func mergeDefaultOptions(defaults map[string]any, runOptions any) map[string]any {
+	merged := g.M()
+	for k, v := range defaults {
+		merged[k] = v
+	}
+
+	runMap := g.M()
+	g.Unmarshal(g.Marshal(runOptions), &runMap)
+	for k, v := range runMap {
+		merged[k] = v
+	}
+	return merged
+}
+
 // SetDefault sets default options
 func (cfg *Config) SetDefault() {
 
@@ -158,9 +314,7 @@ func (cfg *Config) SetDefault() {
 	}
 
 	// default mode
-	if cfg.Mode == "" {
-		cfg.Mode = FullRefreshMode
-	}
+	cfg.Mode = cfg.effectiveMode()
 
 	if val := os.Getenv("SLING_LOADED_AT_COLUMN"); val != "" {
 		if cast.ToBool(val) || val == "unix" || val == "timestamp" {
@@ -276,19 +430,7 @@ func (cfg *Config) DetermineType() (Type JobType, err error) {
 	summary := g.F("srcFileProvided: %t, tgtFileProvided: %t, srcDbProvided: %t, tgtDbProvided: %t, srcStreamProvided: %t", srcFileProvided, tgtFileProvided, srcDbProvided, tgtDbProvided, srcStreamProvided)
 	g.Trace(summary)
 
-	if cfg.Mode == "" {
-		if cfg.Source.PrimaryKeyI != nil || cfg.Source.UpdateKey != "" {
-			cfg.Mode = IncrementalMode
-		} else {
-			cfg.Mode = FullRefreshMode
-		}
-	}
-
-	validMode := g.In(cfg.Mode, FullRefreshMode, IncrementalMode, BackfillMode, SnapshotMode, TruncateMode)
-	if !validMode {
-		err = g.Error("must specify valid mode: full-refresh, incremental, backfill, snapshot or truncate")
-		return
-	}
+	cfg.Mode = cfg.effectiveMode()
 
 	if cfg.Mode == IncrementalMode {
 		if cfg.SrcConn.Info().Type == dbio.TypeDbBigTable {
@@ -303,30 +445,32 @@ func (cfg *Config) DetermineType() (Type JobType, err error) {
 		} else if srcFileProvided && cfg.Source.UpdateKey == slingLoadedAtColumn {
 			// need to loaded_at column for file incremental
 			cfg.MetadataLoadedAt = g.Bool(true)
-		} else if cfg.Source.UpdateKey == "" && len(cfg.Source.PrimaryKey()) == 0 {
-			err = g.Error("must specify value for 'update_key' and/or 'primary_key' for incremental mode. See docs for more details: https://docs.slingdata.io/sling-cli/run/configuration")
-			if args := os.Getenv("SLING_CLI_ARGS"); strings.Contains(args, "-src-conn") || strings.Contains(args, "-tgt-conn") {
-				err = g.Error("must specify value for '--update-key' and/or '--primary-key' for incremental mode. See docs for more details: https://docs.slingdata.io/sling-cli/run/configuration")
-			}
-			return
 		}
-	} else if cfg.Mode == BackfillMode {
-		if cfg.Source.UpdateKey == "" || len(cfg.Source.PrimaryKey()) == 0 {
-			err = g.Error("must specify value for 'update_key' and 'primary_key' for backfill mode. See docs for more details: https://docs.slingdata.io/sling-cli/run/configuration")
-			if args := os.Getenv("SLING_CLI_ARGS"); strings.Contains(args, "-src-conn") || strings.Contains(args, "-tgt-conn") {
-				err = g.Error("must specify value for '--update-key' and '--primary-key' for backfill mode. See docs for more details: https://docs.slingdata.io/sling-cli/run/configuration")
-			}
-			return
-		}
-		if cfg.Source.Options == nil || cfg.Source.Options.Range == nil {
-			err = g.Error("must specify range (source.options.range or --range) for backfill mode. See docs for more details: https://docs.slingdata.io/sling-cli/run/configuration")
+	} else if cfg.Mode == SnapshotMode {
+		cfg.MetadataLoadedAt = g.Bool(true) // needed for snapshot mode
+	}
+
+	if err = cfg.validateMode(); err != nil {
+		return
+	}
+
+	if cfg.Source.hasWindow() {
+		if cfg.Source.UpdateKey == "" {
+			err = g.Error("must specify value for 'update_key' (or --update-key) with a since / until window")
 			return
-		} else if rangeArr := strings.Split(*cfg.Source.Options.Range, ","); len(rangeArr) != 2 {
-			err = g.Error("must specify valid range value for backfill mode separated by one comma, for example `2021-01-01,2021-02-01`. See docs for more details: https://docs.slingdata.io/sling-cli/run/configuration")
+		} else if !srcDbProvided {
+			err = g.Error("a since / until window is only supported for database sources")
 			return
 		}
-	} else if cfg.Mode == SnapshotMode {
-		cfg.MetadataLoadedAt = g.Bool(true) // needed for snapshot mode
+		for _, expr := range []string{g.PtrVal(cfg.Source.Options.Since), g.PtrVal(cfg.Source.Options.Until)} {
+			if expr == "" {
+				continue
+			} else if _, numErr := cast.ToFloat64E(expr); numErr == nil {
+				continue // for numeric update keys
+			} else if _, err = ParseWindowTime(expr, time.Now()); err != nil {
+				return
+			}
+		}
 	}
 
 	if srcDbProvided && tgtDbProvided {
@@ -412,6 +556,55 @@ func (cfg *Config) AsReplication() (rc ReplicationConfig) {
 	return rc
 }
 
+// effectiveMode returns the mode, defaulting to incremental if a primary
+// key or update key is provided, otherwise full-refresh
+func (cfg *Config) effectiveMode() Mode {
+	if cfg.Mode != "" {
+		return cfg.Mode
+	} else if cfg.Source.PrimaryKeyI != nil || cfg.Source.UpdateKey != "" {
+		return IncrementalMode
+	}
+	return FullRefreshMode
+}
+
+// validateMode checks that the mode is valid, with its required options
+// (update key, primary key, range)
+func (cfg *Config) validateMode() (err error) {
+	// the flags are mentioned when running from the CLI, without a replication
+	args := os.Getenv("SLING_CLI_ARGS")
+	useFlags := strings.Contains(args, "-src-conn") || strings.Contains(args, "-tgt-conn")
+
+	switch mode := cfg.effectiveMode(); mode {
+	case FullRefreshMode, TruncateMode, SnapshotMode:
+	case IncrementalMode:
+		if cfg.SrcConn.Info().Type == dbio.TypeDbBigTable {
+			return nil // default keys are used
+		} else if cfg.sourceIsFile() && cfg.Source.UpdateKey == slingLoadedAtColumn {
+			return nil
+		} else if cfg.Source.UpdateKey == "" && len(cfg.Source.PrimaryKey()) == 0 {
+			if useFlags {
+				return g.Error("must specify value for '--update-key' and/or '--primary-key' for incremental mode. See docs for more details: https://docs.slingdata.io/sling-cli/run/configuration")
+			}
+			return g.Error("must specify value for 'update_key' and/or 'primary_key' for incremental mode. See docs for more details: https://docs.slingdata.io/sling-cli/run/configuration")
+		}
+	case BackfillMode:
+		if cfg.Source.UpdateKey == "" || len(cfg.Source.PrimaryKey()) == 0 {
+			if useFlags {
+				return g.Error("must specify value for '--update-key' and '--primary-key' for backfill mode. See docs for more details: https://docs.slingdata.io/sling-cli/run/configuration")
+			}
+			return g.Error("must specify value for 'update_key' and 'primary_key' for backfill mode. See docs for more details: https://docs.slingdata.io/sling-cli/run/configuration")
+		}
+		if cfg.Source.Options == nil || cfg.Source.Options.Range == nil {
+			return g.Error("must specify range (source.options.range or --range) for backfill mode. See docs for more details: https://docs.slingdata.io/sling-cli/run/configuration")
+		} else if rangeArr := strings.Split(*cfg.Source.Options.Range, ","); len(rangeArr) != 2 {
+			return g.Error("must specify valid range value for backfill mode separated by one comma, for example `2021-01-01,2021-02-01`. See docs for more details: https://docs.slingdata.io/sling-cli/run/configuration")
+		}
+	default:
+		return g.Error("must specify valid mode: full-refresh, incremental, backfill, snapshot or truncate")
+	}
+	return nil
+}
+
 // Prepare prepares the config
 func (cfg *Config) Prepare() (err error) {
 	if cfg.Prepared {
@@ -464,6 +657,8 @@ func (cfg *Config) Prepare() (err error) {
 			if err != nil {
 				return g.Error(err, "could not init target connection")
 			}
+		} else if uErr := connection.ValidateURL(cfg.Target.Conn); strings.Contains(cfg.Target.Conn, "://") && uErr != nil && cfg.TgtConn.Data == nil {
+			return g.Error(uErr, "could not init target connection")
 		} else if !strings.Contains(cfg.Target.Conn, "://") && cfg.Target.Conn != "" && cfg.TgtConn.Data == nil {
 			return g.Error("could not find connection %s", cfg.Target.Conn)
 		} else if cfg.TgtConn.Data == nil {
@@ -511,6 +706,11 @@ func (cfg *Config) Prepare() (err error) {
 		cfg.Target.Options = &TargetOptions{}
 	}
 
+	// the first union stream is the task source, the others are read with it
+	if err = cfg.prepareUnion(); err != nil {
+		return err
+	}
+
 	// Set Source
 	cfg.Source.Stream = strings.TrimSpace(cfg.Source.Stream)
 	if cfg.Source.Data == nil || len(cfg.Source.Data) == 0 {
@@ -522,6 +722,8 @@ func (cfg *Config) Prepare() (err error) {
 			if err != nil {
 				return g.Error(err, "could not init source connection")
 			}
+		} else if uErr := connection.ValidateURL(cfg.Source.Conn); strings.Contains(cfg.Source.Conn, "://") && uErr != nil && cfg.SrcConn.Data == nil {
+			return g.Error(uErr, "could not init source connection")
 		} else if !strings.Contains(cfg.Source.Conn, "://") && cfg.Source.Conn != "" && cfg.SrcConn.Data == nil {
 			return g.Error("could not find connection %s", cfg.Source.Conn)
 		} else if cfg.SrcConn.Data == nil {
@@ -585,6 +787,11 @@ func (cfg *Config) Prepare() (err error) {
 		}
 	}
 
+	// merge the connection default options, run options take precedence
+	if err = cfg.applyConnDefaultOptions(); err != nil {
+		return err
+	}
+
 	// add md5 of options, so that wee reconnect for various options
 	// see variable `connPool`
 	cfg.SrcConn.Data["_source_options_md5"] = g.MD5(g.Marshal(cfg.Source.Options))
@@ -596,10 +803,328 @@ func (cfg *Config) Prepare() (err error) {
 
 	// validate capability to write
 	switch cfg.Target.Type {
-	case dbio.TypeDbPrometheus, dbio.TypeDbMongoDB, dbio.TypeDbBigTable:
+	case dbio.TypeDbPrometheus, dbio.TypeDbMongoDB, dbio.TypeDbBigTable, dbio.TypeDbPubSub, dbio.TypeDbInfluxDB, dbio.TypeDbFirestore:
 		return g.Error("sling cannot currently write to %s", cfg.Target.Type)
 	}
 
+	// validate if_exists
+	if ie := cfg.Target.Options.IfExists; ie != nil && *ie != "" {
+		switch *ie {
+		case IfExistsAppend, IfExistsReplace, IfExistsTruncate, IfExistsFail:
+		default:
+			return g.Error("invalid value for if_exists: %s. Valid values are: append, replace, truncate, fail", *ie)
+		}
+	}
+
+	// validate the compression of orc files, zstd is not supported by the orc writer
+	if cfg.Target.Type.IsFile() && cfg.Target.ObjectFileFormat() == dbio.FileTypeOrc {
+		if comp := cfg.Target.Options.Compression; comp != nil && *comp == iop.ZStandardCompressorType {
+			return g.Error("zstd compression is not supported for ORC files. Valid values are: zlib (default), snappy, none")
+		}
+	}
+
+	// validate column_casing
+	if cc := cfg.Target.Options.ColumnCasing; !cc.IsEmpty() && !g.In(*cc, iop.ColumnCasings...) {
+		return g.Error("invalid value for column_casing: %s. Valid values are: source, target, snake, upper, lower, normalize", *cc)
+	}
+
+	// validate quote_identifiers
+	if qi := cfg.Target.Options.QuoteIdentifiers; qi != nil && *qi != "" && !g.In(*qi, dbio.QuoteIdentifiersModes...) {
+		return g.Error("invalid value for quote_identifiers: %s. Valid values are: always, never, when-needed", *qi)
+	}
+
+	// validate hash_algorithm
+	if ha := cfg.Target.Options.HashAlgorithm; ha != nil && *ha != "" && !g.In(*ha, iop.HashAlgorithms...) {
+		return g.Error("invalid value for hash_algorithm: %s. Valid values are: md5, xxhash", *ha)
+	}
+
+	// validate target column types
+	if len(cfg.Target.Options.Columns) > 0 {
+		if !cfg.TgtConn.Type.IsDb() {
+			return g.Error("target option 'columns' is only supported for database targets")
+		}
+		for name, nativeType := range cfg.Target.Options.Columns {
+			if strings.TrimSpace(nativeType) == "" {
+				return g.Error("no type provided for column %s in target option 'columns'", name)
+			}
+		}
+	}
+
+	// validate assertions
+	if len(cfg.Assertions) > 0 {
+		if !cfg.TgtConn.Type.IsDb() {
+			return g.Error("assertions are only supported for database targets")
+		} else if err = cfg.Assertions.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// validate sample_strategy
+	if ss := g.PtrVal(cfg.Source.Options.SampleStrategy); ss != "" && !g.In(iop.SampleStrategy(ss), iop.SampleStrategies...) {
+		return g.Error("invalid value for sample_strategy: %s. Valid values are: head, spread, random", ss)
+	}
+
+	// validate normalize_keys
+	if nk := cfg.normalizeKeys(); nk != "" && !g.In(nk, iop.NormalizeKeysLowercase, iop.NormalizeKeysSnakeCase) {
+		return g.Error("invalid value for normalize_keys: %s. Valid values are: lowercase, snake_case", nk)
+	}
+
+	// validate decimal_precision, decimal_headroom & decimal_max_precision
+	if _, _, _, err = iop.ParseDecimalPrecision(g.PtrVal(cfg.Source.Options.DecimalPrecision)); err != nil {
+		return err
+	} else if dh := cfg.Target.Options.DecimalHeadroom; dh != nil && *dh < 0 {
+		return g.Error("invalid value for decimal_headroom: %d. Must be 0 or more", *dh)
+	} else if dmp := cfg.Target.Options.DecimalMaxPrecision; dmp != nil && (*dmp < 1 || *dmp > 76) {
+		return g.Error("invalid value for decimal_max_precision: %d. Must be between 1 and 76", *dmp)
+	}
+
+	// validate extract
+	for i := range cfg.Source.Options.Extract {
+		if err = cfg.Source.Options.Extract[i].Validate(); err != nil {
+			return g.Error(err, "invalid value for extract")
+		}
+	}
+
+	// validate pipeline
+	for _, step := range cfg.pipeline() {
+		if err = step.Validate(); err != nil {
+			return g.Error(err, "invalid value for pipeline")
+		}
+	}
+
+	// validate normalize
+	if err = cfg.validateNormalize(); err != nil {
+		return err
+	}
+
+	// validate timeouts
+	if _, err = parseTimeout(g.PtrVal(cfg.Source.Options.ExtractTimeout)); err != nil {
+		return g.Error(err, "invalid value for extract_timeout")
+	} else if _, err = parseTimeout(g.PtrVal(cfg.Target.Options.LoadTimeout)); err != nil {
+		return g.Error(err, "invalid value for load_timeout")
+	}
+
+	// validate manifest
+	if err = cfg.validateManifest(); err != nil {
+		return err
+	}
+
+	// validate fetch_size
+	if fetchSize := g.PtrVal(cfg.Source.Options.FetchSize); fetchSize < 0 {
+		return g.Error("invalid value %d for fetch_size, should be positive", fetchSize)
+	} else if fetchSize > 0 && !cfg.SrcConn.Type.IsDb() {
+		return g.Error("fetch_size is only supported for database sources")
+	}
+
+	// validate where, applied to the files & parquet row groups (not a SQL condition)
+	if g.PtrVal(cfg.Source.Options.Where) != "" && !cfg.SrcConn.Type.IsFile() {
+		return g.Error("where is only supported for file sources. For databases, use a custom SQL query")
+	}
+
+	// validate bool_format
+	if bf := g.PtrVal(cfg.Target.Options.BoolFormat); bf != "" {
+		if err = iop.ValidateBoolFormat(bf); err != nil {
+			return err
+		}
+	}
+
+	// validate indexes
+	for i, index := range cfg.Target.Options.Indexes {
+		if len(index.Columns) == 0 {
+			return g.Error("index #%d has no columns (target option 'indexes')", i+1)
+		}
+	}
+
+	// validate grants
+	if len(cfg.Target.Options.Grants) > 0 && !cfg.TgtConn.Type.IsDb() {
+		return g.Error("target option grants is only supported for database targets")
+	}
+	for i, grant := range cfg.Target.Options.Grants {
+		if err = grant.Validate(); err != nil {
+			return g.Error(err, "invalid grant #%d (target option 'grants')", i+1)
+		}
+	}
+
+	// validate json_format
+	if jf := cfg.Target.Options.JsonFormat; jf != nil && *jf != "" {
+		if !g.In(strings.ToLower(*jf), "lines", "array") {
+			return g.Error("invalid value for json_format: %s. Valid values are: lines, array", *jf)
+		}
+	}
+
+	// validate direct_insert, which cannot merge/upsert
+	if di := cfg.Target.Options.DirectInsert; di != nil && *di {
+		if g.In(cfg.Mode, IncrementalMode, BackfillMode) && len(cfg.Source.PrimaryKey()) > 0 {
+			return g.Error("direct_insert is not compatible with mode '%s' with a primary-key (requires a merge). Please remove direct_insert.", cfg.Mode)
+		}
+	}
+
+	// validate keep_temp
+	if _, err := cfg.keepTemp(); err != nil {
+		return err
+	}
+
+	// validate append_only, which inserts without a merge. The flag
+	// `--append-only` is ignored by streams with other modes or file targets.
+	if cfg.appendOnly() && (cfg.Mode != IncrementalMode || !cfg.TgtConn.Type.IsDb()) {
+		if cfg.Target.Options.AppendOnly != nil {
+			return g.Error("append_only requires mode 'incremental' into a database target")
+		}
+	} else if cfg.appendOnly() {
+		if g.PtrVal(cfg.Source.Options.CdcSlot) != "" || g.PtrVal(cfg.Source.Options.ChangeTracking) {
+			return g.Error("append_only is not compatible with cdc_slot or change_tracking (changes include updates and deletes)")
+		} else if g.PtrVal(cfg.Source.Options.DeletedMarker) != "" {
+			return g.Error("append_only is not compatible with deleted_marker (deletes require a merge)")
+		} else if cfg.SrcConn.Type.IsDb() && cfg.Source.UpdateKey == "" {
+			return g.Error("append_only requires an update-key, to only append the new rows")
+		}
+	}
+
+	// validate no_create, which forbids DDL. The flag `--no-create` is ignored by file targets.
+	if err := cfg.validateNoCreate(); err != nil {
+		return err
+	}
+
+	// validate write_mode
+	if err := cfg.validateWriteMode(); err != nil {
+		return err
+	}
+
+	// validate a composite update_key, compared as a row value
+	if err := cfg.validateCompositeUpdateKey(); err != nil {
+		return err
+	}
+
+	// validate lineage_comment
+	if err := cfg.validateLineageComment(); err != nil {
+		return err
+	}
+
+	// validate surrogate_key, generating a key column. Without a primary-key, the hash is the merge key.
+	if err := cfg.validateSurrogateKey(); err != nil {
+		return err
+	} else if sk := cfg.Target.Options.SurrogateKey; sk != nil && sk.Strategy == SurrogateKeyHash {
+		if g.In(cfg.Mode, IncrementalMode, BackfillMode) && !cfg.Source.HasPrimaryKey() {
+			cfg.Source.PrimaryKeyI = []string{sk.Name()}
+		}
+	}
+
+	// validate merge_update_columns / merge_exclude_columns, restricting the updated columns
+	if err := cfg.validateMergeColumns(); err != nil {
+		return err
+	}
+
+	// validate version_column, comparing the versions of the merged rows
+	if err := cfg.validateVersionColumn(); err != nil {
+		return err
+	}
+
+	// validate column_map, renaming stream columns
+	if err := cfg.validateColumnMap(); err != nil {
+		return err
+	}
+
+	// validate cdc_slot, which merges the changes on the primary key
+	if slot := g.PtrVal(cfg.Source.Options.CdcSlot); slot != "" {
+		if cfg.SrcConn.Type != dbio.TypeDbPostgres {
+			return g.Error("cdc_slot is only supported for PostgreSQL sources")
+		} else if !cfg.TgtConn.Type.IsDb() {
+			return g.Error("cdc_slot is only supported for database targets")
+		} else if cfg.Mode != IncrementalMode || len(cfg.Source.PrimaryKey()) == 0 {
+			return g.Error("cdc_slot requires mode 'incremental' with a primary-key")
+		} else if cfg.Source.UpdateKey != "" {
+			return g.Error("cdc_slot is not compatible with an update-key (the slot position is used)")
+		}
+
+		// apply the deletes by default
+		if g.PtrVal(cfg.Source.Options.DeletedMarker) == "" {
+			cfg.Source.Options.DeletedMarker = g.Ptr(g.F("%s = 'delete'", database.CDCOperationColumn))
+		}
+	}
+
+	// validate change_tracking, which merges the changes on the primary key
+	if g.PtrVal(cfg.Source.Options.ChangeTracking) {
+		if !g.In(cfg.SrcConn.Type, dbio.TypeDbSQLServer, dbio.TypeDbAzure) {
+			return g.Error("change_tracking is only supported for SQL Server sources")
+		} else if !cfg.TgtConn.Type.IsDb() {
+			return g.Error("change_tracking is only supported for database targets")
+		} else if cfg.Mode != IncrementalMode || len(cfg.Source.PrimaryKey()) == 0 {
+			return g.Error("change_tracking requires mode 'incremental' with a primary-key")
+		} else if cfg.Source.UpdateKey != "" {
+			return g.Error("change_tracking is not compatible with an update-key (the change tracking version is used)")
+		} else if g.PtrVal(cfg.Source.Options.CdcSlot) != "" {
+			return g.Error("change_tracking is not compatible with cdc_slot")
+		}
+
+		// apply the deletes by default
+		if g.PtrVal(cfg.Source.Options.DeletedMarker) == "" {
+			cfg.Source.Options.DeletedMarker = g.Ptr(g.F("%s = 'delete'", database.CDCOperationColumn))
+		}
+	}
+
+	// validate deleted_marker, which requires a merge
+	if dm := cfg.Source.Options.DeletedMarker; dm != nil && *dm != "" {
+		if !g.In(cfg.Mode, IncrementalMode, BackfillMode) || len(cfg.Source.PrimaryKey()) == 0 {
+			return g.Error("deleted_marker requires mode 'incremental' or 'backfill' with a primary-key")
+		} else if di := cfg.Target.Options.DirectInsert; di != nil && *di {
+			return g.Error("deleted_marker is not compatible with direct_insert")
+		}
+	} else if sd := cfg.Target.Options.SoftDelete; sd != nil && *sd != "" {
+		return g.Error("soft_delete requires the source option deleted_marker to be specified")
+	}
+
+	// validate load_procedure, which loads from the temp table
+	if lp := cfg.Target.Options.LoadProcedure; lp != nil && *lp != "" {
+		if !cfg.TgtConn.Type.IsDb() {
+			return g.Error("load_procedure is only supported for database targets")
+		} else if g.PtrVal(cfg.Target.Options.DirectInsert) {
+			return g.Error("load_procedure is not compatible with direct_insert")
+		}
+	}
+
+	// validate stage, used for snowflake PUT / COPY loading
+	if g.PtrVal(cfg.Target.Options.Stage) != "" && cfg.TgtConn.Type != dbio.TypeDbSnowflake {
+		return g.Error("target option stage is only supported for snowflake targets")
+	} else if g.PtrVal(cfg.Target.Options.StageFileFormat) != "" && g.PtrVal(cfg.Target.Options.Stage) == "" {
+		return g.Error("target option stage_file_format requires the stage option")
+	}
+
+	// validate dedupe_on_load, which keeps the latest row per primary key
+	if g.PtrVal(cfg.Target.Options.DedupeOnLoad) {
+		if !cfg.TgtConn.Type.IsDb() {
+			return g.Error("dedupe_on_load is only supported for database targets")
+		} else if template, _ := cfg.TgtConn.Type.Template(); template.Core["dedupe"] == "" {
+			return g.Error("dedupe_on_load is not supported for %s targets", cfg.TgtConn.Type)
+		} else if len(cfg.Source.PrimaryKey()) == 0 {
+			return g.Error("dedupe_on_load requires a primary key")
+		} else if cfg.Source.UpdateKey == "" && g.PtrVal(cfg.Target.Options.DedupeOrderBy) == "" {
+			return g.Error("dedupe_on_load requires an update key or the dedupe_order_by option")
+		}
+	}
+
+	// validate order_by, to sort the rows of file targets
+	if len(cfg.Target.Options.OrderBy) > 0 {
+		if cfg.TgtConn.Type.IsDb() {
+			return g.Error("order_by is only supported for file targets")
+		} else if _, err := iop.ParseSortKeys(cfg.Target.Options.OrderBy); err != nil {
+			return g.Error(err, "invalid order_by")
+		}
+	}
+
+	// validate bigquery_method & gcs_staging
+	if bm := g.PtrVal(cfg.Target.Options.BigQueryMethod); bm != "" {
+		if cfg.TgtConn.Type != dbio.TypeDbBigQuery {
+			return g.Error("bigquery_method is only supported for BigQuery targets")
+		} else if _, err := database.BigQueryLoadMethod(bm, "", false); err != nil {
+			return err
+		}
+	}
+	if gs := g.PtrVal(cfg.Target.Options.GcsStaging); gs != "" {
+		if _, err := database.BigQueryStagingPath(gs, "table", "ts"); err != nil {
+			return err
+		}
+	}
+
 	// validate table keys
 	if tkMap := cfg.Target.Options.TableKeys; tkMap != nil {
 		for _, kt := range lo.Keys(tkMap) {
@@ -633,6 +1158,10 @@ func (cfg *Config) Prepare() (err error) {
 	}
 
 	// sql prop
+	cfg.Source.Query, err = cfg.RenderSQLTemplate(cfg.Source.Query, fMap)
+	if err != nil {
+		return g.Error(err, "could not render source sql")
+	}
 	cfg.Source.Query = g.Rm(cfg.Source.Query, fMap)
 	if cfg.ReplicationStream != nil {
 		cfg.ReplicationStream.SQL = cfg.Source.Query
@@ -653,13 +1182,21 @@ func (cfg *Config) Prepare() (err error) {
 					err = nil // don't return error in case the table full name ends with .sql
 				}
 			} else {
+				sqlFromFile, err = cfg.RenderSQLTemplate(sqlFromFile, fMap)
+				if err != nil {
+					return g.Error(err, "could not render sql file: %s", cfg.Source.Stream)
+				}
 				cfg.Source.Stream = g.Rm(sqlFromFile, fMap)
 				if cfg.ReplicationStream != nil {
 					cfg.ReplicationStream.SQL = cfg.Source.Stream
 				}
 			}
 		} else if sTable.IsQuery() {
-			cfg.Source.Stream = g.Rm(sTable.SQL, fMap)
+			sql, err := cfg.RenderSQLTemplate(sTable.SQL, fMap)
+			if err != nil {
+				return g.Error(err, "could not render source sql")
+			}
+			cfg.Source.Stream = g.Rm(sql, fMap)
 			if cfg.ReplicationStream != nil {
 				cfg.ReplicationStream.SQL = cfg.Source.Stream
 			}
@@ -694,17 +1231,68 @@ func (cfg *Config) Prepare() (err error) {
 		}
 	}
 
+	// validate view targets, created from the source query
+	if err = cfg.validateViewTarget(); err != nil {
+		return err
+	}
+
 	// done
 	cfg.Prepared = true
 	return
 }
 
+// targetIsView returns true if the target object is a view of the source query
+func (cfg *Config) targetIsView() bool {
+	return cfg.Target.Options != nil && g.PtrVal(cfg.Target.Options.ObjectType) == ObjectTypeView
+}
+
+// validateViewTarget checks that a view target is created from a source
+// query, on the same connection (or database) as the target
+func (cfg *Config) validateViewTarget() error {
+	if cfg.Target.Options == nil || cfg.Target.Options.ObjectType == nil {
+		return nil
+	}
+
+	switch objectType := *cfg.Target.Options.ObjectType; objectType {
+	case ObjectTypeTable:
+		return nil
+	case ObjectTypeView:
+	default:
+		return g.Error("invalid object_type `%s`, must be `table` or `view`", objectType)
+	}
+
+	if !cfg.TgtConn.Type.IsDb() {
+		return g.Error("object_type `view` is only supported for database targets")
+	}
+
+	sameConn := strings.EqualFold(cfg.Source.Conn, cfg.Target.Conn)
+	if !sameConn && cfg.SrcConn.Type == cfg.TgtConn.Type {
+		sameConn = cfg.SrcConn.URL() != "" && cfg.SrcConn.URL() == cfg.TgtConn.URL()
+	}
+	if !sameConn {
+		return g.Error("object_type `view` requires the source and target to be the same connection (source: %s, target: %s)", cfg.Source.Conn, cfg.Target.Conn)
+	}
+
+	if sTable, _ := database.ParseTableName(cfg.Source.Stream, cfg.SrcConn.Type); !sTable.IsQuery() {
+		return g.Error("object_type `view` requires a source sql query, not a table: %s", cfg.Source.Stream)
+	}
+
+	if mode := cfg.effectiveMode(); mode != FullRefreshMode {
+		return g.Error("object_type `view` is only supported with the full-refresh mode, not %s", mode)
+	}
+
+	return nil
+}
+
 func (cfg *Config) FormatTargetObjectName() (err error) {
 	m, err := cfg.GetFormatMap()
 	if err != nil {
 		return g.Error(err, "could not get formatting variables")
 	}
 
+	// apply the replication naming rules
+	cfg.objectNaming.Apply(m)
+
 	// clean values for replacing, these need to be clean to be used in the object name
 	dateMap := iop.GetISO8601DateMap(time.Now())
 	for k, v := range m {
@@ -727,6 +1315,8 @@ func (cfg *Config) FormatTargetObjectName() (err error) {
 			return g.Error(err, "could not parse target table name")
 		} else if table.IsQuery() {
 			return g.Error("invalid table name: %s", table.Raw)
+		} else if err = cfg.objectNaming.ValidateObjectName(table); err != nil {
+			return err
 		}
 		cfg.Target.Object = table.FullName()
 	}
@@ -984,6 +1574,12 @@ type Config struct {
 	StreamName        string                   `json:"stream_name,omitempty" yaml:"stream_name,omitempty"`
 	ReplicationStream *ReplicationStreamConfig `json:"replication_stream,omitempty" yaml:"replication_stream,omitempty"`
 
+	// Assertions are data-quality checks run against the target after the load
+	Assertions Assertions `json:"assertions,omitempty" yaml:"assertions,omitempty"`
+
+	// FanOut are the configs of the other targets, written from the same extraction
+	FanOut []*Config `json:"fan_out,omitempty" yaml:"fan_out,omitempty"`
+
 	SrcConn  connection.Connection `json:"-" yaml:"-"`
 	TgtConn  connection.Connection `json:"-" yaml:"-"`
 	Prepared bool                  `json:"-" yaml:"-"`
@@ -996,7 +1592,9 @@ type Config struct {
 	MetadataRowID     bool  `json:"-" yaml:"-"`
 	MetadataExecID    bool  `json:"-" yaml:"-"`
 
-	extraTransforms []string `json:"-" yaml:"-"`
+	extraTransforms []string      `json:"-" yaml:"-"`
+	unionCfgs       []*Config     `json:"-" yaml:"-"` // the other streams of the union source
+	objectNaming    *ObjectNaming // replication object naming rules
 }
 
 // Scan scan value into Jsonb, implements sql.Scanner interface
@@ -1014,6 +1612,69 @@ func (cfg *Config) IgnoreExisting() bool {
 	return cfg.Target.Options.IgnoreExisting != nil && *cfg.Target.Options.IgnoreExisting
 }
 
+// IfExists returns the behavior when the target table exists.
+// If target_options.if_exists is not specified, it is derived from the mode.
+func (cfg *Config) IfExists() IfExists {
+	if cfg.Target.Options != nil && cfg.Target.Options.IfExists != nil && *cfg.Target.Options.IfExists != "" {
+		return *cfg.Target.Options.IfExists
+	}
+
+	switch cfg.Mode {
+	case FullRefreshMode:
+		return IfExistsReplace
+	case TruncateMode:
+		return IfExistsTruncate
+	}
+	return IfExistsAppend
+}
+
+// pipeline returns the steps of the source option `pipeline`,
+// followed by the steps of the target option `pipeline`
+func (cfg *Config) pipeline() (steps []iop.PipelineStep) {
+	if cfg.Source.Options != nil {
+		steps = append(steps, cfg.Source.Options.Pipeline...)
+	}
+	if cfg.Target.Options != nil {
+		steps = append(steps, cfg.Target.Options.Pipeline...)
+	}
+	return steps
+}
+
+// normalize returns the config splitting the arrays of JSON records into
+// child tables (source option `normalize`), nil if not enabled
+func (cfg *Config) normalize() *iop.NormalizeConfig {
+	if cfg.Source.Options == nil || !g.PtrVal(cfg.Source.Options.Normalize) {
+		return nil
+	}
+	return &iop.NormalizeConfig{
+		Keys:   cfg.Source.PrimaryKey(),
+		Depth:  g.PtrVal(cfg.Source.Options.NormalizeDepth),
+		Arrays: cfg.Source.Options.NormalizeArrays,
+	}
+}
+
+// validateNormalize checks the source option `normalize`, which needs a
+// primary key (the foreign key of the child tables) and a database target
+func (cfg *Config) validateNormalize() error {
+	if cfg.normalize() == nil {
+		if g.PtrVal(cfg.Source.Options.NormalizeDepth) != 0 || len(cfg.Source.Options.NormalizeArrays) > 0 {
+			return g.Error("source options normalize_depth & normalize_arrays need normalize: true")
+		}
+		return nil
+	}
+
+	if !cfg.TgtConn.Type.IsDb() {
+		return g.Error("source option normalize is only supported for database targets")
+	} else if !cfg.Source.HasPrimaryKey() {
+		return g.Error("source option normalize needs a primary key, referenced by the child tables")
+	} else if depth := g.PtrVal(cfg.Source.Options.NormalizeDepth); depth < 0 {
+		return g.Error("invalid value %d for normalize_depth", depth)
+	} else if cfg.atomicSwap() {
+		return g.Error("source option normalize is not supported with write_mode: atomic-swap")
+	}
+	return nil
+}
+
 // HasIncrementalVal returns true there is a non-null incremental value
 func (cfg *Config) HasIncrementalVal() bool {
 	return cfg.IncrementalVal != "" && cfg.IncrementalVal != "null"
@@ -1071,6 +1732,22 @@ func (cfg *Config) ColumnsPrepared() (columns iop.Columns) {
 	return
 }
 
+// TargetColumnsPrepared returns the columns of the target option `columns`, with
+// the general type of their native type. These are applied when parsing the
+// source values, so that a forced text column keeps values such as `001` as is.
+func (cfg *Config) TargetColumnsPrepared() (columns iop.Columns) {
+	if cfg.Target.Options == nil {
+		return
+	}
+	for name, nativeType := range cfg.Target.Options.Columns {
+		columns = append(columns, iop.Column{
+			Name: name,
+			Type: iop.NativeTypeToGeneral(name, nativeType, cfg.TgtConn.Type),
+		})
+	}
+	return columns
+}
+
 // TransformsPrepared returns the transforms columns
 func (cfg *Config) TransformsPrepared() (colTransforms map[string][]string) {
 
@@ -1151,6 +1828,60 @@ func (cfg Config) Value() (driver.Value, error) {
 	return []byte(out), err
 }
 
+// ResolvedMap returns the effective config, once prepared and with the
+// defaults applied, with the secrets redacted. Used by `--print-config`.
+func (cfg *Config) ResolvedMap() (m map[string]any, err error) {
+	if err = cfg.Prepare(); err != nil {
+		return nil, g.Error(err, "could not prepare config")
+	}
+	cfg.SetDefault()
+
+	m = g.M()
+	if err = g.JSONConvert(cfg, &m); err != nil {
+		return nil, g.Error(err, "could not convert config")
+	}
+
+	return redactSecrets(m).(map[string]any), nil
+}
+
+// ResolvedYAML returns the effective config as YAML (see ResolvedMap)
+func (cfg *Config) ResolvedYAML() (string, error) {
+	m, err := cfg.ResolvedMap()
+	if err != nil {
+		return "", err
+	}
+
+	out, err := yaml.Marshal(m)
+	if err != nil {
+		return "", g.Error(err, "could not marshal config")
+	}
+	return string(out), nil
+}
+
+// redactSecrets masks the secrets of a config value, at every level of
+// its maps (see connection.RedactProps), and the url passwords
+func redactSecrets(val any) any {
+	switch v := val.(type) {
+	case map[string]any:
+		redacted := connection.RedactProps(v)
+		for key, item := range redacted {
+			if item != "***" {
+				redacted[key] = redactSecrets(item)
+			}
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(v))
+		for i, item := range v {
+			redacted[i] = redactSecrets(item)
+		}
+		return redacted
+	case string:
+		return connection.RedactURL(v)
+	}
+	return val
+}
+
 func (cfg *Config) MD5() string {
 	payload := g.Marshal([]any{
 		g.M("source", cfg.Source.MD5()),
@@ -1202,6 +1933,9 @@ type Source struct {
 	UpdateKey   string         `json:"update_key,omitempty" yaml:"update_key,omitempty"`
 	Options     *SourceOptions `json:"options,omitempty" yaml:"options,omitempty"`
 
+	// Union are several streams written to the same target, see UnionSource
+	Union []*UnionSource `json:"union,omitempty" yaml:"union,omitempty"`
+
 	Data map[string]interface{} `json:"-" yaml:"-"`
 }
 
@@ -1227,6 +1961,22 @@ func (s *Source) HasUpdateKey() bool {
 	return s.UpdateKey != ""
 }
 
+// UpdateKeys returns the columns of the update key. A composite update key
+// (e.g. `event_date,event_id`) is compared lexicographically, as a row value.
+func (s *Source) UpdateKeys() (keys []string) {
+	for _, key := range strings.Split(s.UpdateKey, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// HasCompositeUpdateKey returns true if the update key has multiple columns
+func (s *Source) HasCompositeUpdateKey() bool {
+	return len(s.UpdateKeys()) > 1
+}
+
 func (s *Source) HasPrimaryKey() bool {
 	return strings.Join(s.PrimaryKey(), "") != ""
 }
@@ -1260,7 +2010,10 @@ type Target struct {
 	Data map[string]interface{} `json:"-" yaml:"-"`
 
 	TmpTableCreated bool        `json:"-" yaml:"-"`
+	TableCreated    bool        `json:"-" yaml:"-"` // target table created during the run
+	KeptCount       uint64      `json:"-" yaml:"-"` // target rows newer than the source, not updated (version_column)
 	columns         iop.Columns `json:"-" yaml:"-"`
+	columnsFrom     iop.Columns `json:"-" yaml:"-"` // reference columns from target_options.columns_from
 }
 
 func (t *Target) ObjectFileFormat() dbio.FileType {
@@ -1307,6 +2060,94 @@ type SourceOptions struct {
 	FileSelect     *[]string           `json:"file_select,omitempty" yaml:"file_select,omitempty"` // include/exclude files
 	ParallelChunks *int                `json:"parallel_chunks,omitempty" yaml:"parallel_chunks,omitempty"`
 
+	// Hive-style partition filter, e.g. `dt >= 2021-01-01`
+	PartitionFilter *string `json:"partition_filter,omitempty" yaml:"partition_filter,omitempty"`
+
+	// filter on file sources, e.g. `dt >= 2021-01-01 and amount > 100`. Conditions on
+	// partition keys skip files from their path, others skip parquet row groups from their stats.
+	// On Firestore, a JSON list of `[field, op, value]` conditions, e.g. `[["status", "==", "open"]]`.
+	Where *string `json:"where,omitempty" yaml:"where,omitempty"`
+
+	// push the select and where of S3 csv / jsonlines files to S3 Select, so that
+	// only matching rows & columns are transferred. Falls back to a full download.
+	UseS3Select *bool `json:"use_s3_select,omitempty" yaml:"use_s3_select,omitempty"`
+
+	// a file enumerating the objects to read (an S3 Inventory CSV, or a list of keys), instead of
+	// listing the bucket. Objects not found error, unless manifest_skip_missing is set.
+	Manifest            *string `json:"manifest,omitempty" yaml:"manifest,omitempty"`
+	ManifestSkipMissing *bool   `json:"manifest_skip_missing,omitempty" yaml:"manifest_skip_missing,omitempty"`
+
+	// rows sampled to infer the schema of csv & json lines files: `head` (the first rows,
+	// default), or also chunks read across local files, `spread` evenly or at `random` offsets
+	SampleStrategy *string `json:"sample_strategy,omitempty" yaml:"sample_strategy,omitempty"`
+
+	// normalize the keys of JSON objects, so that `userId` & `userid` merge into one column:
+	// `lowercase` or `snake_case`. Flag `--columns-lowercase-keys` sets `lowercase`.
+	NormalizeKeys *string `json:"normalize_keys,omitempty" yaml:"normalize_keys,omitempty"`
+
+	// column (e.g. `deleted_at`) or condition (e.g. `is_deleted = 1`) identifying soft-deleted source rows
+	DeletedMarker *string `json:"deleted_marker,omitempty" yaml:"deleted_marker,omitempty"`
+
+	// PostgreSQL logical replication slot (wal2json) to read the changes from (CDC)
+	CdcSlot *string `json:"cdc_slot,omitempty" yaml:"cdc_slot,omitempty"`
+
+	// SQL Server Change Tracking (CHANGETABLE) to read the changes from, instead of an update-key
+	ChangeTracking *bool `json:"change_tracking,omitempty" yaml:"change_tracking,omitempty"`
+
+	// read a Firestore collection from all its parent documents (collection group),
+	// e.g. the `orders` subcollections of every `users/{id}`, adding a `_path` column
+	Subcollections *bool `json:"subcollections,omitempty" yaml:"subcollections,omitempty"`
+
+	// name of the column identifying the origin of the rows of a `union` source (e.g. `_source`),
+	// and whether to read the union streams concurrently, instead of one after the other
+	UnionColumn     *string `json:"union_column,omitempty" yaml:"union_column,omitempty"`
+	UnionConcurrent *bool   `json:"union_concurrent,omitempty" yaml:"union_concurrent,omitempty"`
+
+	// precision of decimal columns without one: `auto` (fit the sampled values) or `precision,scale`
+	DecimalPrecision *string `json:"decimal_precision,omitempty" yaml:"decimal_precision,omitempty"`
+
+	// fail the run if the source yields zero rows, before any target change.
+	// Overrides SLING_ABORT_ON_EMPTY_SOURCE (flag `--abort-on-empty-source`).
+	AbortOnEmpty *bool `json:"abort_on_empty,omitempty" yaml:"abort_on_empty,omitempty"`
+
+	// maximum duration (e.g. `10m`, or seconds) of the extraction, until all rows are read
+	ExtractTimeout *string `json:"extract_timeout,omitempty" yaml:"extract_timeout,omitempty"`
+
+	// number of rows fetched per round trip through a server-side cursor (postgres & redshift),
+	// streaming large results in batches instead of buffering them client-side.
+	// Ignored by the other databases.
+	FetchSize *int `json:"fetch_size,omitempty" yaml:"fetch_size,omitempty"`
+
+	// bounded window on the update key, independent of the incremental watermark: absolute
+	// dates or relative to now (e.g. `-7d`, `now`). Flags `--since` (inclusive) / `--until` (exclusive).
+	Since *string `json:"since,omitempty" yaml:"since,omitempty"`
+	Until *string `json:"until,omitempty" yaml:"until,omitempty"`
+
+	// string values to parse as booleans, e.g. `Y/N`, with per-column overrides
+	BoolValues *iop.BoolValues `json:"bool_values,omitempty" yaml:"bool_values,omitempty"`
+
+	// nested fields of JSON columns extracted into columns, e.g. `{from: payload, path: $.user.id,
+	// as: user_id, type: integer}`. Pushed down into the query of database sources, when supported.
+	Extract []iop.JSONExtract `json:"extract,omitempty" yaml:"extract,omitempty"`
+
+	// row transform steps (rename, cast, hash, mask, compute, filter) applied in order in the
+	// stream, before the target option `pipeline`. Rows dropped by a filter are counted.
+	Pipeline []iop.PipelineStep `json:"pipeline,omitempty" yaml:"pipeline,omitempty"`
+
+	// split the arrays of objects of nested JSON records into child tables `<table>_<array>`, with
+	// the primary key as foreign key (`parent_<key>`) and the position in the array (`_index`).
+	// `normalize_depth` is the levels of nested arrays split (default 1), `normalize_arrays`
+	// the array paths split, e.g. `items` or `items.tags` (default all).
+	Normalize       *bool    `json:"normalize,omitempty" yaml:"normalize,omitempty"`
+	NormalizeDepth  *int     `json:"normalize_depth,omitempty" yaml:"normalize_depth,omitempty"`
+	NormalizeArrays []string `json:"normalize_arrays,omitempty" yaml:"normalize_arrays,omitempty"`
+
+	// fixed-width options
+	Layout          any     `json:"layout,omitempty" yaml:"layout,omitempty"`
+	Encoding        *string `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+	SkipHeaderLines *int    `json:"skip_header_lines,omitempty" yaml:"skip_header_lines,omitempty"`
+	SkipFooterLines *int    `json:"skip_footer_lines,omitempty" yaml:"skip_footer_lines,omitempty"`
+
 	// columns & transforms were moved out of source_options
 	// https://github.com/slingdata-io/sling-cli/issues/348
 	Columns    any `json:"columns,omitempty" yaml:"columns,omitempty"`       // legacy
@@ -1327,6 +2168,19 @@ type TargetOptions struct {
 	MaxDecimals      *int                `json:"max_decimals,omitempty" yaml:"max_decimals,omitempty"`
 	UseBulk          *bool               `json:"use_bulk,omitempty" yaml:"use_bulk,omitempty"`
 	IgnoreExisting   *bool               `json:"ignore_existing,omitempty" yaml:"ignore_existing,omitempty"`
+	IfExists         *IfExists           `json:"if_exists,omitempty" yaml:"if_exists,omitempty"`
+	ColumnsFrom      *ColumnsFrom        `json:"columns_from,omitempty" yaml:"columns_from,omitempty"`
+	S3Staging        *string             `json:"s3_staging,omitempty" yaml:"s3_staging,omitempty"`
+	Stage            *string             `json:"stage,omitempty" yaml:"stage,omitempty"`                         // snowflake stage to load through
+	StageFileFormat  *string             `json:"stage_file_format,omitempty" yaml:"stage_file_format,omitempty"` // named file format or format options
+	DirectInsert     *bool               `json:"direct_insert,omitempty" yaml:"direct_insert,omitempty"`
+	SoftDelete       *string             `json:"soft_delete,omitempty" yaml:"soft_delete,omitempty"`
+	LoadProcedure    *string             `json:"load_procedure,omitempty" yaml:"load_procedure,omitempty"`
+	JsonFormat       *string             `json:"json_format,omitempty" yaml:"json_format,omitempty"` // lines or array
+	DedupeOnLoad     *bool               `json:"dedupe_on_load,omitempty" yaml:"dedupe_on_load,omitempty"`
+	DedupeOrderBy    *string             `json:"dedupe_order_by,omitempty" yaml:"dedupe_order_by,omitempty"` // defaults to update key desc
+	BigQueryMethod   *string             `json:"bigquery_method,omitempty" yaml:"bigquery_method,omitempty"` // storage_write, load_job or insert_all
+	GcsStaging       *string             `json:"gcs_staging,omitempty" yaml:"gcs_staging,omitempty"`
 	AddNewColumns    *bool               `json:"add_new_columns,omitempty" yaml:"add_new_columns,omitempty"`
 	AdjustColumnType *bool               `json:"adjust_column_type,omitempty" yaml:"adjust_column_type,omitempty"`
 	ColumnCasing     *iop.ColumnCasing   `json:"column_casing,omitempty" yaml:"column_casing,omitempty"`
@@ -1336,6 +2190,117 @@ type TargetOptions struct {
 	TableDDL  *string            `json:"table_ddl,omitempty" yaml:"table_ddl,omitempty"`
 	PreSQL    *string            `json:"pre_sql,omitempty" yaml:"pre_sql,omitempty"`
 	PostSQL   *string            `json:"post_sql,omitempty" yaml:"post_sql,omitempty"`
+
+	// indexes to create after the load (clustering key for snowflake)
+	Indexes []database.IndexDefinition `json:"indexes,omitempty" yaml:"indexes,omitempty"`
+
+	// maximum duration (e.g. `10m`, or seconds) of the load into the target
+	LoadTimeout *string `json:"load_timeout,omitempty" yaml:"load_timeout,omitempty"`
+
+	// keep the temp table for inspection instead of dropping it: on `failure`
+	// (or `true`), or `always`. Overrides SLING_NO_TEMP_CLEANUP (flag `--no-temp-cleanup`).
+	KeepTemp *string `json:"keep_temp,omitempty" yaml:"keep_temp,omitempty"`
+
+	// how booleans are written to files, as `<true>/<false>` (e.g. `Y/N`, `1/0`)
+	BoolFormat *string `json:"bool_format,omitempty" yaml:"bool_format,omitempty"`
+
+	// insert incremental rows without a merge, for insert-only sources. With a primary-key,
+	// rows already in the target are skipped. Overrides SLING_APPEND_ONLY (flag `--append-only`).
+	AppendOnly *bool `json:"append_only,omitempty" yaml:"append_only,omitempty"`
+
+	// never issue DDL (create, alter or drop): the target table must already exist, with all
+	// the stream columns. Rows are inserted directly. Overrides SLING_NO_CREATE (flag `--no-create`).
+	NoCreate *bool `json:"no_create,omitempty" yaml:"no_create,omitempty"`
+
+	// `atomic-swap` loads a new table then renames it over the target table, dropping
+	// the previous one, so that the target is never empty or partial (full-refresh only).
+	// Dependent views are recreated and grants reapplied after the swap.
+	WriteMode *WriteMode `json:"write_mode,omitempty" yaml:"write_mode,omitempty"`
+
+	// the columns updated by a merge (incremental mode with a primary-key), others are only
+	// inserted. Or all the columns except merge_exclude_columns. Not both.
+	MergeUpdateColumns  []string `json:"merge_update_columns,omitempty" yaml:"merge_update_columns,omitempty"`
+	MergeExcludeColumns []string `json:"merge_exclude_columns,omitempty" yaml:"merge_exclude_columns,omitempty"`
+
+	// a generated key column of the target table (`_sling_key` by default): the `hash` of the natural
+	// key `columns` (default the primary-key), which is the merge key without a primary-key. Or an
+	// `identity` column of the target table, generated on insert and kept by the merges.
+	SurrogateKey *SurrogateKey `json:"surrogate_key,omitempty" yaml:"surrogate_key,omitempty"`
+
+	// a number, date or timestamp column compared by a merge: target rows are only updated
+	// when the source version is greater, rows where the target is newer are kept.
+	VersionColumn *string `json:"version_column,omitempty" yaml:"version_column,omitempty"`
+
+	// renames stream columns (source name -> target name), before column_casing
+	ColumnMap map[string]string `json:"column_map,omitempty" yaml:"column_map,omitempty"`
+
+	// rename the old column names of an existing target table with column_map, instead of
+	// erroring. Overrides SLING_RENAME_TARGET_COLUMNS (flag `--rename-target-columns`).
+	RenameTargetColumns *bool `json:"rename_target_columns,omitempty" yaml:"rename_target_columns,omitempty"`
+
+	// privileges to grant once the target table is created. Existing tables
+	// are skipped, unless SLING_FORCE_GRANTS is set (flag `--force-grants`).
+	Grants []database.GrantDefinition `json:"grants,omitempty" yaml:"grants,omitempty"`
+
+	// comments the target table & columns with the comments of the source table (database
+	// sources). columns_description (column name -> description) sets or overrides column comments.
+	AddComments        *bool             `json:"add_comments,omitempty" yaml:"add_comments,omitempty"`
+	ColumnsDescription map[string]string `json:"columns_description,omitempty" yaml:"columns_description,omitempty"`
+
+	// comments the target table with its lineage: the source connection & object, the sling
+	// version and the load time. Set when the table is `create`d (kept on reruns), or on every run (`update`).
+	LineageComment *LineageCommentPolicy `json:"lineage_comment,omitempty" yaml:"lineage_comment,omitempty"`
+
+	// how table & column names are quoted in generated SQL: `always` (default), `never`
+	// or `when-needed` (for mixed-case, special & reserved names)
+	QuoteIdentifiers *dbio.QuoteIdentifiers `json:"quote_identifiers,omitempty" yaml:"quote_identifiers,omitempty"`
+
+	// adds the _sling_row_hash column, the hash of hash_columns (default all non-metadata
+	// columns). With a merge, target rows with an unchanged hash are not updated.
+	AddHash       *bool              `json:"add_hash,omitempty" yaml:"add_hash,omitempty"`
+	HashAlgorithm *iop.HashAlgorithm `json:"hash_algorithm,omitempty" yaml:"hash_algorithm,omitempty"` // md5 (default) or xxhash
+	HashColumns   []string           `json:"hash_columns,omitempty" yaml:"hash_columns,omitempty"`
+
+	// explicit native types of target columns (column name -> type, e.g. `amount: text`),
+	// used to create the table and cast the values. Source parsing types are set with `columns`.
+	Columns map[string]string `json:"columns,omitempty" yaml:"columns,omitempty"`
+
+	// `table` (default) or `view`: creates (or replaces) the target object as a view of
+	// the source query, without moving data. Source and target must be the same connection.
+	ObjectType *ObjectType `json:"object_type,omitempty" yaml:"object_type,omitempty"`
+
+	// sorts the rows of file targets (e.g. `[id, created_at desc]`) for reproducible outputs.
+	// The whole stream is read before writing (no pure streaming), spilling past --max-memory.
+	OrderBy []string `json:"order_by,omitempty" yaml:"order_by,flow,omitempty"`
+
+	// row transform steps applied in order in the stream, after the source option `pipeline`
+	Pipeline []iop.PipelineStep `json:"pipeline,omitempty" yaml:"pipeline,omitempty"`
+
+	// integer digits added to the decimals fitted to the sampled values (default 2),
+	// and their maximum precision (default 38). See the source option `decimal_precision`.
+	DecimalHeadroom     *int `json:"decimal_headroom,omitempty" yaml:"decimal_headroom,omitempty"`
+	DecimalMaxPrecision *int `json:"decimal_max_precision,omitempty" yaml:"decimal_max_precision,omitempty"`
+}
+
+// ColumnsFrom is a reference table whose columns the target should mirror
+type ColumnsFrom struct {
+	Conn  string `json:"conn,omitempty" yaml:"conn,omitempty"` // defaults to the target connection
+	Table string `json:"table" yaml:"table"`
+}
+
+// SurrogateKey is a key column generated for the target table
+type SurrogateKey struct {
+	Column   string               `json:"column,omitempty" yaml:"column,omitempty"`
+	Strategy SurrogateKeyStrategy `json:"strategy" yaml:"strategy"`                   // hash or identity
+	Columns  []string             `json:"columns,omitempty" yaml:"columns,omitempty"` // hash: the natural key columns
+}
+
+// Name returns the name of the surrogate key column
+func (sk *SurrogateKey) Name() string {
+	if name := strings.TrimSpace(sk.Column); name != "" {
+		return name
+	}
+	return slingSurrogateKeyColumn
 }
 
 var SourceFileOptionsDefault = SourceOptions{
@@ -1401,6 +2366,7 @@ var TargetDBOptionsDefault = TargetOptions{
 	DatetimeFormat:   "auto",
 	MaxDecimals:      g.Int(-1),
 	ColumnCasing:     g.Ptr(iop.SourceColumnCasing),
+	QuoteIdentifiers: g.Ptr(dbio.QuoteIdentifiersAlways),
 }
 
 func (o *SourceOptions) SetDefaults(sourceOptions SourceOptions) {
@@ -1432,6 +2398,12 @@ func (o *SourceOptions) SetDefaults(sourceOptions SourceOptions) {
 	if o.Range == nil {
 		o.Range = sourceOptions.Range
 	}
+	if o.Since == nil {
+		o.Since = sourceOptions.Since
+	}
+	if o.Until == nil {
+		o.Until = sourceOptions.Until
+	}
 	if o.DatetimeFormat == "" {
 		o.DatetimeFormat = sourceOptions.DatetimeFormat
 	}
@@ -1450,6 +2422,75 @@ func (o *SourceOptions) SetDefaults(sourceOptions SourceOptions) {
 	if o.MaxDecimals == nil {
 		o.MaxDecimals = sourceOptions.MaxDecimals
 	}
+	if o.DecimalPrecision == nil {
+		o.DecimalPrecision = sourceOptions.DecimalPrecision
+	}
+	if o.PartitionFilter == nil {
+		o.PartitionFilter = sourceOptions.PartitionFilter
+	}
+	if o.Where == nil {
+		o.Where = sourceOptions.Where
+	}
+	if o.UseS3Select == nil {
+		o.UseS3Select = sourceOptions.UseS3Select
+	}
+	if o.Manifest == nil {
+		o.Manifest = sourceOptions.Manifest
+	}
+	if o.ManifestSkipMissing == nil {
+		o.ManifestSkipMissing = sourceOptions.ManifestSkipMissing
+	}
+	if o.NormalizeKeys == nil {
+		o.NormalizeKeys = sourceOptions.NormalizeKeys
+	}
+	if o.SampleStrategy == nil {
+		o.SampleStrategy = sourceOptions.SampleStrategy
+	}
+	if o.DeletedMarker == nil {
+		o.DeletedMarker = sourceOptions.DeletedMarker
+	}
+	if o.AbortOnEmpty == nil {
+		o.AbortOnEmpty = sourceOptions.AbortOnEmpty
+	}
+	if o.ExtractTimeout == nil {
+		o.ExtractTimeout = sourceOptions.ExtractTimeout
+	}
+	if o.FetchSize == nil {
+		o.FetchSize = sourceOptions.FetchSize
+	}
+	if o.BoolValues == nil {
+		o.BoolValues = sourceOptions.BoolValues
+	}
+	if o.CdcSlot == nil {
+		o.CdcSlot = sourceOptions.CdcSlot
+	}
+	if o.ChangeTracking == nil {
+		o.ChangeTracking = sourceOptions.ChangeTracking
+	}
+	if o.Subcollections == nil {
+		o.Subcollections = sourceOptions.Subcollections
+	}
+	if o.UnionColumn == nil {
+		o.UnionColumn = sourceOptions.UnionColumn
+	}
+	if o.UnionConcurrent == nil {
+		o.UnionConcurrent = sourceOptions.UnionConcurrent
+	}
+	if o.Extract == nil {
+		o.Extract = sourceOptions.Extract
+	}
+	if o.Pipeline == nil {
+		o.Pipeline = sourceOptions.Pipeline
+	}
+	if o.Normalize == nil {
+		o.Normalize = sourceOptions.Normalize
+	}
+	if o.NormalizeDepth == nil {
+		o.NormalizeDepth = sourceOptions.NormalizeDepth
+	}
+	if o.NormalizeArrays == nil {
+		o.NormalizeArrays = sourceOptions.NormalizeArrays
+	}
 	if o.Columns == nil {
 		o.Columns = sourceOptions.Columns // legacy
 	}
@@ -1491,6 +2532,84 @@ func (o *TargetOptions) SetDefaults(targetOptions TargetOptions) {
 	if o.IgnoreExisting == nil {
 		o.IgnoreExisting = targetOptions.IgnoreExisting
 	}
+	if o.IfExists == nil {
+		o.IfExists = targetOptions.IfExists
+	}
+	if o.ColumnsFrom == nil {
+		o.ColumnsFrom = targetOptions.ColumnsFrom
+	}
+	if o.S3Staging == nil {
+		o.S3Staging = targetOptions.S3Staging
+	}
+	if o.Stage == nil {
+		o.Stage = targetOptions.Stage
+	}
+	if o.StageFileFormat == nil {
+		o.StageFileFormat = targetOptions.StageFileFormat
+	}
+	if o.DirectInsert == nil {
+		o.DirectInsert = targetOptions.DirectInsert
+	}
+	if o.SoftDelete == nil {
+		o.SoftDelete = targetOptions.SoftDelete
+	}
+	if o.LoadProcedure == nil {
+		o.LoadProcedure = targetOptions.LoadProcedure
+	}
+	if o.JsonFormat == nil {
+		o.JsonFormat = targetOptions.JsonFormat
+	}
+	if o.DedupeOnLoad == nil {
+		o.DedupeOnLoad = targetOptions.DedupeOnLoad
+	}
+	if o.LoadTimeout == nil {
+		o.LoadTimeout = targetOptions.LoadTimeout
+	}
+	if o.BoolFormat == nil {
+		o.BoolFormat = targetOptions.BoolFormat
+	}
+	if o.KeepTemp == nil {
+		o.KeepTemp = targetOptions.KeepTemp
+	}
+	if o.AppendOnly == nil {
+		o.AppendOnly = targetOptions.AppendOnly
+	}
+	if o.NoCreate == nil {
+		o.NoCreate = targetOptions.NoCreate
+	}
+	if o.WriteMode == nil {
+		o.WriteMode = targetOptions.WriteMode
+	}
+	if o.Pipeline == nil {
+		o.Pipeline = targetOptions.Pipeline
+	}
+	if o.MergeUpdateColumns == nil {
+		o.MergeUpdateColumns = targetOptions.MergeUpdateColumns
+	}
+	if o.MergeExcludeColumns == nil {
+		o.MergeExcludeColumns = targetOptions.MergeExcludeColumns
+	}
+	if o.SurrogateKey == nil {
+		o.SurrogateKey = targetOptions.SurrogateKey
+	}
+	if o.VersionColumn == nil {
+		o.VersionColumn = targetOptions.VersionColumn
+	}
+	if o.ColumnMap == nil {
+		o.ColumnMap = targetOptions.ColumnMap
+	}
+	if o.RenameTargetColumns == nil {
+		o.RenameTargetColumns = targetOptions.RenameTargetColumns
+	}
+	if o.DedupeOrderBy == nil {
+		o.DedupeOrderBy = targetOptions.DedupeOrderBy
+	}
+	if o.BigQueryMethod == nil {
+		o.BigQueryMethod = targetOptions.BigQueryMethod
+	}
+	if o.GcsStaging == nil {
+		o.GcsStaging = targetOptions.GcsStaging
+	}
 	if o.PreSQL == nil {
 		o.PreSQL = targetOptions.PreSQL
 	}
@@ -1522,6 +2641,48 @@ func (o *TargetOptions) SetDefaults(targetOptions TargetOptions) {
 	if o.ColumnCasing == nil {
 		o.ColumnCasing = targetOptions.ColumnCasing
 	}
+	if o.Indexes == nil {
+		o.Indexes = targetOptions.Indexes
+	}
+	if o.Grants == nil {
+		o.Grants = targetOptions.Grants
+	}
+	if o.AddComments == nil {
+		o.AddComments = targetOptions.AddComments
+	}
+	if o.ColumnsDescription == nil {
+		o.ColumnsDescription = targetOptions.ColumnsDescription
+	}
+	if o.LineageComment == nil {
+		o.LineageComment = targetOptions.LineageComment
+	}
+	if o.QuoteIdentifiers == nil {
+		o.QuoteIdentifiers = targetOptions.QuoteIdentifiers
+	}
+	if o.AddHash == nil {
+		o.AddHash = targetOptions.AddHash
+	}
+	if o.HashAlgorithm == nil {
+		o.HashAlgorithm = targetOptions.HashAlgorithm
+	}
+	if o.HashColumns == nil {
+		o.HashColumns = targetOptions.HashColumns
+	}
+	if o.Columns == nil {
+		o.Columns = targetOptions.Columns
+	}
+	if o.ObjectType == nil {
+		o.ObjectType = targetOptions.ObjectType
+	}
+	if o.OrderBy == nil {
+		o.OrderBy = targetOptions.OrderBy
+	}
+	if o.DecimalHeadroom == nil {
+		o.DecimalHeadroom = targetOptions.DecimalHeadroom
+	}
+	if o.DecimalMaxPrecision == nil {
+		o.DecimalMaxPrecision = targetOptions.DecimalMaxPrecision
+	}
 	if o.TableKeys == nil {
 		o.TableKeys = targetOptions.TableKeys
 		if o.TableKeys == nil {
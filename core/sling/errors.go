@@ -0,0 +1,158 @@
+package sling
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+)
+
+// ErrorCode is a category of error, for programmatic handling
+type ErrorCode string
+
+const (
+	ErrorCodeAuth       ErrorCode = "AUTH"
+	ErrorCodeConn       ErrorCode = "CONN"
+	ErrorCodeSchema     ErrorCode = "SCHEMA"
+	ErrorCodePermission ErrorCode = "PERMISSION"
+	ErrorCodeTimeout    ErrorCode = "TIMEOUT"
+	ErrorCodeConfig     ErrorCode = "CONFIG"
+	ErrorCodeData       ErrorCode = "DATA"
+	ErrorCodeUnknown    ErrorCode = "UNKNOWN"
+)
+
+// sqlStateCodes map the SQLSTATE codes reported by the drivers (exact code
+// first, then the class of its first 2 characters) to error codes
+var sqlStateCodes = map[string]ErrorCode{
+	"57014": ErrorCodeTimeout,    // query_canceled (statement timeout)
+	"42501": ErrorCodePermission, // insufficient_privilege
+	"08":    ErrorCodeConn,       // connection exception
+	"28":    ErrorCodeAuth,       // invalid authorization specification
+	"42":    ErrorCodeSchema,     // syntax error or access rule violation
+	"22":    ErrorCodeData,       // data exception
+	"23":    ErrorCodeData,       // integrity constraint violation
+}
+
+// mysqlErrorCodes map the MySQL / MariaDB error numbers to error codes
+var mysqlErrorCodes = map[string]ErrorCode{
+	"1045": ErrorCodeAuth,       // access denied (bad credentials)
+	"1044": ErrorCodePermission, // access denied to database
+	"1142": ErrorCodePermission, // command denied to user
+	"1227": ErrorCodePermission, // missing privilege
+	"1049": ErrorCodeSchema,     // unknown database
+	"1054": ErrorCodeSchema,     // unknown column
+	"1146": ErrorCodeSchema,     // table doesn't exist
+	"1062": ErrorCodeData,       // duplicate entry
+	"1264": ErrorCodeData,       // out of range value
+	"1366": ErrorCodeData,       // incorrect value
+	"1406": ErrorCodeData,       // data too long
+	"2002": ErrorCodeConn,       // can't connect through socket
+	"2003": ErrorCodeConn,       // can't connect to server
+	"2013": ErrorCodeConn,       // lost connection during query
+	"3024": ErrorCodeTimeout,    // max execution time exceeded
+}
+
+var (
+	// sqlStateRegex matches the SQLSTATE of pgx (`(SQLSTATE 28P01)`),
+	// snowflake (`390100 (08004):`) and mysql (`Error 1045 (28000):`) errors
+	sqlStateRegex = regexp.MustCompile(`(?i)(?:sqlstate[ :=]*|\b\d{4,6} \()([0-9a-z]{5})\b`)
+	// mysqlErrorRegex matches the error number of mysql errors (`Error 1045:`)
+	mysqlErrorRegex = regexp.MustCompile(`(?i)\berror (\d{4})\b`)
+)
+
+// errorCodePhrases are the phrases of common driver / sling errors, matched
+// as whole words when no explicit code is reported. The order matters, the
+// first match wins.
+var errorCodePhrases = []struct {
+	Code  ErrorCode
+	Regex *regexp.Regexp
+}{
+	{ErrorCodeTimeout, phrasesRegex(
+		"context deadline exceeded", "timeout", "timed out", "i/o timeout",
+		"statement_timeout", "canceling statement due to statement timeout",
+	)},
+	{ErrorCodeAuth, phrasesRegex(
+		"password authentication failed", "authentication failed", "access denied for user",
+		"invalid username/password", "login failed", "incorrect username or password",
+		"invalid credentials", "invalidaccesskeyid", "signaturedoesnotmatch",
+		"expiredtoken", "invalid_grant", "unauthorized",
+	)},
+	{ErrorCodePermission, phrasesRegex(
+		"permission denied", "insufficient privileges", "not authorized",
+		"access denied", "accessdenied", "forbidden", "does not have privilege",
+	)},
+	{ErrorCodeConn, phrasesRegex(
+		"could not connect", "connection refused", "no such host", "connection reset",
+		"broken pipe", "network is unreachable", "unexpected eof", "server closed the connection",
+		"ssl is not enabled", "tls handshake", "failed to connect",
+	)},
+	{ErrorCodeSchema, phrasesRegex(
+		"does not exist", "table not found", "column not found", "unknown column", "invalid identifier",
+		"no such table", "no such column", "already exists", "ambiguous column",
+		"invalid object name", "undefined column", "undefined table",
+	)},
+	{ErrorCodeData, phrasesRegex(
+		"invalid input syntax", "out of range", "value too long", "cannot cast",
+		"could not convert", "conversion failed", "malformed", "invalid byte sequence",
+		"numeric field overflow", "violates", "duplicate key", "parse error",
+		"could not parse", "truncated",
+	)},
+	{ErrorCodeConfig, phrasesRegex(
+		"must specify", "invalid config", "error parsing config", "invalid value for",
+		"did not specify", "invalid connection name", "is not compatible",
+		"need to set", "not supported", "unsupported",
+	)},
+}
+
+// phrasesRegex returns the regex matching any of the phrases as whole words
+func phrasesRegex(phrases ...string) *regexp.Regexp {
+	quoted := lo.Map(phrases, func(phrase string, i int) string { return regexp.QuoteMeta(phrase) })
+	return regexp.MustCompile(`\b(?:` + strings.Join(quoted, "|") + `)\b`)
+}
+
+// ClassifyError returns the error code for the error. The explicit codes are
+// matched first (deadline, network timeout, SQLSTATE & mysql error number),
+// then the known error phrases.
+func ClassifyError(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorCodeTimeout
+	}
+
+	text := err.Error()
+	if E, ok := err.(*g.ErrType); ok && E.Debug() != "" {
+		text = E.Debug()
+	}
+	text = strings.ToLower(text)
+
+	if m := sqlStateRegex.FindStringSubmatch(text); m != nil {
+		state := strings.ToUpper(m[1])
+		if code, ok := sqlStateCodes[state]; ok {
+			return code
+		} else if code, ok := sqlStateCodes[state[:2]]; ok {
+			return code
+		}
+	}
+
+	if m := mysqlErrorRegex.FindStringSubmatch(text); m != nil {
+		if code, ok := mysqlErrorCodes[m[1]]; ok {
+			return code
+		}
+	}
+
+	for _, p := range errorCodePhrases {
+		if p.Regex.MatchString(text) {
+			return p.Code
+		}
+	}
+
+	return ErrorCodeUnknown
+}
@@ -0,0 +1,45 @@
+package sling
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flarco/g"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		code ErrorCode
+	}{
+		{nil, ""},
+		{context.DeadlineExceeded, ErrorCodeTimeout},
+		{g.Error("read tcp 10.0.0.1:5432: i/o timeout"), ErrorCodeTimeout},
+		{g.Error(g.Error(`pq: password authentication failed for user "bob"`), "Could not connect to source connection"), ErrorCodeAuth},
+		{g.Error("Error 1045: Access denied for user 'bob'@'localhost' (using password: YES)"), ErrorCodeAuth},
+		{g.Error("pq: permission denied for schema private"), ErrorCodePermission},
+		{g.Error("dial tcp 127.0.0.1:5432: connect: connection refused"), ErrorCodeConn},
+		{g.Error(`pq: relation "public.missing" does not exist`), ErrorCodeSchema},
+		{g.Error(`pq: invalid input syntax for type integer: "abc"`), ErrorCodeData},
+		{g.Error("must specify value for 'update_key' and/or 'primary_key' for incremental mode"), ErrorCodeConfig},
+		{g.Error("something unexpected happened"), ErrorCodeUnknown},
+
+		// explicit codes reported by the drivers
+		{g.Error(`ERROR: relation "orders" does not exist (SQLSTATE 42P01)`), ErrorCodeSchema},
+		{g.Error(`ERROR: canceling statement due to statement timeout (SQLSTATE 57014)`), ErrorCodeTimeout},
+		{g.Error(`FATAL: password authentication failed for user "bob" (SQLSTATE 28P01)`), ErrorCodeAuth},
+		{g.Error("390100 (08004): Incorrect username or password was specified."), ErrorCodeAuth},
+		{g.Error("Error 1146 (42S02): Table 'db.missing' doesn't exist"), ErrorCodeSchema},
+		{g.Error("Error 1142: SELECT command denied to user 'bob'@'localhost' for table 'orders'"), ErrorCodePermission},
+		{g.Error("Error 1062: Duplicate entry '1' for key 'PRIMARY'"), ErrorCodeData},
+
+		// phrases are matched as whole words
+		{g.Error("could not read column geofence_ssl_key"), ErrorCodeUnknown},
+		{g.Error("file not found: data/orders.csv"), ErrorCodeUnknown},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.code, ClassifyError(tt.err), g.ErrMsg(tt.err))
+	}
+}
@@ -0,0 +1,237 @@
+package sling
+
+import (
+	"sync"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+)
+
+// ReplicationTarget is a target of the replication `targets` list. Each
+// source stream is extracted once, and written to every target concurrently
+// (fan-out). The first target drives the extraction (incremental watermark),
+// the others only differ in how they write.
+//
+//	targets:
+//	  - conn: MY_SNOWFLAKE
+//	    mode: incremental
+//	  - conn: MY_S3
+//	    object: backup/{stream_table}.parquet
+//	    mode: full-refresh
+type ReplicationTarget struct {
+	Conn          string         `json:"conn" yaml:"conn"`
+	Object        string         `json:"object,omitempty" yaml:"object,omitempty"`
+	Mode          Mode           `json:"mode,omitempty" yaml:"mode,omitempty"`
+	TargetOptions *TargetOptions `json:"target_options,omitempty" yaml:"target_options,omitempty"`
+}
+
+// FanOutResult is the write result of a fan-out target
+type FanOutResult struct {
+	Conn   string `json:"conn"`
+	Object string `json:"object"`
+	Rows   uint64 `json:"rows"`
+	Error  string `json:"error,omitempty"`
+}
+
+// fanOutConnMux serializes the target connections of the fan-out targets (pooled)
+var fanOutConnMux sync.Mutex
+
+// fanOut holds the running writes of the fan-out targets of a task
+type fanOut struct {
+	tasks    []*TaskExecution
+	branches []*iop.Datastream // the first one is for the primary target
+	results  []FanOutResult
+	wg       sync.WaitGroup
+}
+
+// compileFanOut applies the first target of the `targets` list to the stream
+// config, and compiles the configs of the other targets
+func (rd *ReplicationConfig) compileFanOut(name string, stream *ReplicationStreamConfig, cfg *Config) (err error) {
+	if len(rd.Targets) == 0 {
+		return nil
+	}
+
+	applyTarget := func(target ReplicationTarget, c *Config) {
+		c.Target.Conn = target.Conn
+		if target.Object != "" {
+			c.Target.Object = target.Object
+		}
+		if target.Mode != "" {
+			c.Mode = target.Mode
+		}
+		if target.TargetOptions != nil {
+			c.Target.Options = nil
+			g.Unmarshal(g.Marshal(target.TargetOptions), &c.Target.Options)
+		}
+	}
+
+	applyTarget(rd.Targets[0], cfg)
+
+	// the extraction is shared, so a filtered extraction cannot replace a target
+	filtered := cfg.Source.UpdateKey != "" && g.In(cfg.Mode, IncrementalMode, BackfillMode)
+
+	for i, target := range rd.Targets[1:] {
+		if target.Conn == "" {
+			return g.Error("need to specify `conn` for target #%d", i+2)
+		}
+
+		fanCfg := Config{
+			Source:            cfg.Source,
+			Target:            Target{Object: stream.Object, Columns: stream.Columns},
+			Mode:              stream.Mode,
+			Transforms:        stream.Transforms,
+			Env:               cfg.Env,
+			StreamName:        name,
+			ReplicationStream: stream,
+			objectNaming:      rd.ObjectNaming,
+		}
+		fanCfg.Source.Options = nil // so that the configs do not share pointer values
+		g.Unmarshal(g.Marshal(cfg.Source.Options), &fanCfg.Source.Options)
+		g.Unmarshal(g.Marshal(stream.TargetOptions), &fanCfg.Target.Options)
+		applyTarget(target, &fanCfg)
+
+		if filtered && g.In(fanCfg.Mode, FullRefreshMode, TruncateMode) {
+			return g.Error("target #%d (%s) cannot use mode `%s`, since the stream `%s` is extracted incrementally for the first target", i+2, target.Conn, fanCfg.Mode, name)
+		}
+
+		if err = fanCfg.Prepare(); err != nil {
+			return g.Error(err, "could not prepare target #%d for stream %s", i+2, name)
+		}
+		cfg.FanOut = append(cfg.FanOut, &fanCfg)
+	}
+
+	return nil
+}
+
+// startFanOut tees the source dataflow to the fan-out targets, writing
+// concurrently. It returns the dataflow of the primary target.
+func (t *TaskExecution) startFanOut(df *iop.Dataflow) (*iop.Dataflow, error) {
+	if len(t.Config.FanOut) == 0 || t.skipStream {
+		return df, nil
+	}
+
+	fo := &fanOut{results: make([]FanOutResult, len(t.Config.FanOut))}
+	for _, cfg := range t.Config.FanOut {
+		child := &TaskExecution{
+			ExecID:       t.ExecID,
+			Config:       cfg,
+			Context:      g.NewContext(t.Context.Ctx),
+			Status:       ExecStatusRunning,
+			Replication:  t.Replication,
+			PBar:         NewPBar(time.Second),
+			df:           iop.NewDataflow(),
+			cleanupFuncs: []func(){},
+		}
+
+		var err error
+		if child.Type, err = cfg.DetermineType(); err != nil {
+			return df, g.Error(err, "could not determine type of target %s", cfg.Target.Conn)
+		}
+		child.Config.SetDefault()
+		fo.tasks = append(fo.tasks, child)
+	}
+
+	branches, err := iop.TeeDataflow(df, len(fo.tasks)+1)
+	if err != nil {
+		return df, g.Error(err, "could not tee source stream")
+	}
+	fo.branches = branches
+
+	for i, child := range fo.tasks {
+		fo.wg.Add(1)
+		go func(i int, child *TaskExecution) {
+			defer fo.wg.Done()
+
+			result := FanOutResult{Conn: child.Config.Target.Conn, Object: child.getTargetObjectValue()}
+			cnt, err := child.writeFanOut(branches[i+1])
+			result.Rows = cnt
+			if err != nil {
+				result.Error = err.Error()
+				branches[i+1].Context.Cancel() // detach branch
+			}
+			fo.results[i] = result
+		}(i, child)
+	}
+
+	t.fanOut = fo
+	t.SetProgress("writing to %d targets", len(fo.tasks)+1)
+
+	return iop.MakeDataFlow(branches[0])
+}
+
+// writeFanOut writes the branch datastream to the target of the task
+func (t *TaskExecution) writeFanOut(ds *iop.Datastream) (cnt uint64, err error) {
+	defer t.Cleanup()
+
+	err = t.setDataflow(iop.MakeDataFlow(ds))
+	if err != nil {
+		return 0, g.Error(err, "could not make dataflow")
+	}
+	defer t.df.Close()
+
+	switch t.Type {
+	case DbToDb, FileToDB:
+		fanOutConnMux.Lock()
+		tgtConn, err := t.getTgtDBConn(t.Context.Ctx)
+		if err == nil {
+			err = tgtConn.Connect()
+		}
+		fanOutConnMux.Unlock()
+		if err != nil {
+			return 0, g.Error(err, "Could not connect to target: %s", t.Config.Target.Conn)
+		}
+
+		if !t.isUsingPool() {
+			t.AddCleanupTaskLast(func() { tgtConn.Close() })
+		}
+
+		t.Config.Target.Object = setSchema(cast.ToString(t.Config.Target.Data["schema"]), t.Config.Target.Object)
+		t.Config.Target.Options.TableTmp = setSchema(cast.ToString(t.Config.Target.Data["schema"]), t.Config.Target.Options.TableTmp)
+
+		if cnt, err = t.WriteToDb(t.Config, t.df, tgtConn); err != nil {
+			return cnt, g.Error(err, "could not write to database")
+		}
+		return cnt, t.updateIncrementalState(tgtConn, cnt)
+	case DbToFile, FileToFile:
+		if cnt, err = t.WriteToFile(t.Config, t.df); err != nil {
+			return cnt, g.Error(err, "could not write to file")
+		}
+		return cnt, nil
+	}
+
+	return 0, g.Error("invalid fan-out task type: %s", t.Type)
+}
+
+// finishFanOut waits for the fan-out targets, and reports their results
+func (t *TaskExecution) finishFanOut() (err error) {
+	fo := t.fanOut
+	if fo == nil {
+		return nil
+	}
+
+	fo.branches[0].Context.Cancel() // detach primary branch, if not fully read
+	fo.wg.Wait()
+
+	eG := g.ErrorGroup{}
+	for _, result := range fo.results {
+		if result.Error != "" {
+			t.SetProgress("failed writing to %s (%s)", result.Object, result.Conn)
+			eG.Add(g.Error(result.Error))
+			continue
+		}
+		t.SetProgress("wrote %d rows to %s (%s)", result.Rows, result.Object, lo.Ternary(result.Conn != "", result.Conn, "local"))
+	}
+
+	return eG.Err()
+}
+
+// FanOutResults returns the write results of the fan-out targets
+func (t *TaskExecution) FanOutResults() []FanOutResult {
+	if t.fanOut == nil {
+		return nil
+	}
+	return t.fanOut.results
+}
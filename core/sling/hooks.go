@@ -1,6 +1,14 @@
 package sling
 
-import "github.com/flarco/g"
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+)
 
 type HookType string
 
@@ -26,3 +34,73 @@ func (hks Hooks) Parse(stage string, te *TaskExecution) (hooks []Hook, err error
 }
 
 var ParseHook = func(any, *TaskExecution, string) (Hook, error) { return nil, nil }
+
+// ReplicationHooks are shell commands executed at the start and end of a
+// replication, or when a stream fails. The commands receive the variables
+// SLING_STATUS, SLING_ROWS & SLING_STREAM in their environment.
+//
+// Hooks run arbitrary commands with the privileges of the sling process,
+// so they are opt-in: set SLING_SHELL_HOOKS=true to enable them, and only
+// do so for replication files from trusted sources.
+type ReplicationHooks struct {
+	Start []string `json:"start,omitempty" yaml:"start,omitempty"`
+	End   []string `json:"end,omitempty" yaml:"end,omitempty"`
+	Error []string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Execute runs the commands of the stage (start, end or error) with the
+// vars added to the environment. Returns on the first failing command.
+func (rh ReplicationHooks) Execute(stage string, vars map[string]string) (err error) {
+	var commands []string
+	switch stage {
+	case "start":
+		commands = rh.Start
+	case "end":
+		commands = rh.End
+	case "error":
+		commands = rh.Error
+	default:
+		return g.Error("invalid hook stage: %s", stage)
+	}
+
+	if len(commands) == 0 {
+		return nil
+	} else if !cast.ToBool(os.Getenv("SLING_SHELL_HOOKS")) {
+		g.Warn("skipping %s hooks since shell hooks are not enabled. Set SLING_SHELL_HOOKS=true to enable them.", stage)
+		return nil
+	}
+
+	for i, command := range commands {
+		if err = ExecuteHookCommand(g.F("%s-%02d", stage, i+1), command, vars); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExecuteHookCommand runs the shell command of a hook with the vars added
+// to the environment, logging its output
+func ExecuteHookCommand(id, command string, vars map[string]string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	cmd.Env = os.Environ()
+	for k, v := range vars {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	g.Debug("executing hook %s: %s", id, command)
+	out, err := cmd.CombinedOutput()
+	if output := strings.TrimSpace(string(out)); output != "" {
+		g.Info("hook %s output:\n%s", id, output)
+	}
+	if err != nil {
+		return g.Error(err, "hook %s failed: %s", id, command)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package sling
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio/database"
+	"github.com/spf13/cast"
+)
+
+// ObjectNaming are the rules deriving the target object of each stream
+// from its source name, set in the replication target:
+//
+//	target:
+//	  conn: MY_SNOWFLAKE
+//	  object_naming:
+//	    object: '{stream_schema}.stg_{stream_table}'
+//	    schemas:
+//	      public: raw          # remaps {stream_schema}
+//	    rename:
+//	      '^tbl_(.*)$': '$1'   # regex replacement on {stream_table}
+//
+// Streams with an explicit `object` are not affected.
+type ObjectNaming struct {
+	Object  string            `json:"object,omitempty" yaml:"object,omitempty"`
+	Schemas map[string]string `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+	Rename  map[string]string `json:"rename,omitempty" yaml:"rename,omitempty"`
+}
+
+var legalIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// Validate checks that the rename patterns compile
+func (on *ObjectNaming) Validate() error {
+	for pattern := range on.Rename {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return g.Error(err, "invalid rename pattern in object_naming: %s", pattern)
+		}
+	}
+	return nil
+}
+
+// Apply remaps the stream schema & renames the stream table of the
+// formatting variables. Rename patterns are tried in sorted order,
+// the first matching one is applied.
+func (on *ObjectNaming) Apply(m map[string]any) {
+	if on == nil {
+		return
+	}
+
+	if schema := cast.ToString(m["stream_schema"]); schema != "" {
+		for from, to := range on.Schemas {
+			if strings.EqualFold(from, schema) {
+				m["stream_schema"] = to
+				break
+			}
+		}
+	}
+
+	if table := cast.ToString(m["stream_table"]); table != "" {
+		patterns := make([]string, 0, len(on.Rename))
+		for pattern := range on.Rename {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil || !re.MatchString(table) {
+				continue
+			}
+			m["stream_table"] = re.ReplaceAllString(table, on.Rename[pattern])
+			break
+		}
+	}
+}
+
+// ValidateObjectName checks that the derived table name is a legal identifier
+func (on *ObjectNaming) ValidateObjectName(table database.Table) error {
+	if on == nil {
+		return nil
+	}
+	for _, name := range []string{table.Schema, table.Name} {
+		if name != "" && !legalIdentifier.MatchString(name) {
+			return g.Error("object_naming derived an illegal identifier: %s (in %s)", name, table.Raw)
+		}
+	}
+	if table.Name == "" {
+		return g.Error("object_naming derived an empty table name: %s", table.Raw)
+	}
+	return nil
+}
@@ -2,10 +2,15 @@ package sling
 
 import (
 	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/flarco/g"
+	"github.com/samber/lo"
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/mem"
 	"github.com/spf13/cast"
@@ -14,10 +19,56 @@ import (
 
 var ShowProgress = true
 
+// progressElements are the elements of the progress bar, for `--progress-format`
+var progressElements = map[string]string{
+	"elapsed": `{{etime . "%s" | yellow }}`,
+	"rows":    `{{counters . }}`,
+	"percent": `{{pct . | cyan }}`,
+	"rate":    `{{speed . "%s r/s" | green }}`,
+	"bytes":   `{{ bytes . | blue }}`,
+	"eta":     `{{ eta . | magenta }}`,
+}
+
+// defaultProgressFormat is the progress bar format, if SLING_PROGRESS_FORMAT is not set
+const defaultProgressFormat = "elapsed,rows,rate,bytes"
+
 type ProgressBar struct {
 	bar      *pb.ProgressBar
 	started  bool
 	finished bool
+	format   []string
+}
+
+// ParseProgressFormat parses the comma-separated progress elements
+// (elapsed, rows, percent, rate, bytes, eta)
+func ParseProgressFormat(format string) (elements []string, err error) {
+	for _, element := range strings.Split(format, ",") {
+		element = strings.ToLower(strings.TrimSpace(element))
+		if element == "" {
+			continue
+		} else if _, ok := progressElements[element]; !ok {
+			keys := lo.Keys(progressElements)
+			sort.Strings(keys)
+			return nil, g.Error("invalid progress element '%s', expected one of: %s", element, strings.Join(keys, ", "))
+		}
+		elements = append(elements, element)
+	}
+
+	if len(elements) == 0 {
+		return nil, g.Error("progress format has no elements")
+	}
+	return elements, nil
+}
+
+// progressTemplate returns the progress bar template of the elements
+func progressTemplate(elements []string) string {
+	parts := lo.Map(elements, func(element string, i int) string {
+		return progressElements[element]
+	})
+	if g.IsDebugLow() {
+		parts = append(parts, `{{ mem . }}`, `{{ cpu . }}`)
+	}
+	return strings.Join(append(parts, `{{ status . }}`), " ")
 }
 
 // NewPBar creates a new progress bar
@@ -29,22 +80,39 @@ func NewPBar(d time.Duration) *ProgressBar {
 	pb.RegisterElement("bytes", elementBytes, true)
 	pb.RegisterElement("rowRate", elementRowRate, true)
 	pb.RegisterElement("byteRate", elementByteRate, true)
-	tmpl := `{{etime . "%s" | yellow }} {{counters . }} {{speed . "%s r/s" | green }} {{ bytes . | blue }} {{ status . }}`
+	pb.RegisterElement("pct", elementPercent, true)
+	pb.RegisterElement("eta", elementETA, true)
 	if g.IsDebugLow() {
 		pb.RegisterElement("mem", elementMem, true)
 		pb.RegisterElement("cpu", elementCPU, true)
-		// tmpl = `{{etime . "%s" | yellow }} {{counters . }} {{speed . "%s r/s" | green }} {{ bytes . | blue }} {{ byteRate . }} {{ mem . }} {{ cpu . }} {{ status . }}`
-		tmpl = `{{etime . "%s" | yellow }} {{counters . }} {{speed . "%s r/s" | green }} {{ bytes . | blue }} {{ mem . }} {{ cpu . }} {{ status . }}`
 	}
-	barTmpl := pb.ProgressBarTemplate(tmpl)
+
+	format, err := ParseProgressFormat(os.Getenv("SLING_PROGRESS_FORMAT"))
+	if err != nil {
+		format, _ = ParseProgressFormat(defaultProgressFormat)
+	}
+
+	barTmpl := pb.ProgressBarTemplate(progressTemplate(format))
 	pbar = barTmpl.New(0)
 	pbar.SetRefreshRate(d)
 	pbar.SetWidth(40)
 	return &ProgressBar{
-		bar: pbar,
+		bar:    pbar,
+		format: format,
 	}
 }
 
+// NeedsTotal returns true if the progress format shows the percent or ETA,
+// which need the total number of rows
+func (pb *ProgressBar) NeedsTotal() bool {
+	return pb != nil && (lo.Contains(pb.format, "percent") || lo.Contains(pb.format, "eta"))
+}
+
+// SetTotal sets the total number of rows, for the percent / ETA
+func (pb *ProgressBar) SetTotal(total int64) {
+	pb.bar.SetTotal(total)
+}
+
 // SetStatus sets the progress bar status
 func (pb *ProgressBar) SetStatus(status string) {
 	if !pb.finished {
@@ -54,6 +122,9 @@ func (pb *ProgressBar) SetStatus(status string) {
 }
 
 func (pb *ProgressBar) Start() {
+	if !pb.started {
+		pb.bar.Set("startTime", time.Now())
+	}
 	pb.started = true
 	pb.bar.Start()
 }
@@ -137,6 +208,40 @@ var elementByteRate pb.ElementFunc = func(state *pb.State, args ...string) strin
 	return g.F("| %s", bytes)
 }
 
+var elementPercent pb.ElementFunc = func(state *pb.State, args ...string) string {
+	if state.Total() <= 0 {
+		return "" // indeterminate
+	}
+	pct := float64(state.Value()) / float64(state.Total()) * 100
+	return g.F("%.0f%%", math.Min(pct, 100))
+}
+
+var elementETA pb.ElementFunc = func(state *pb.State, args ...string) string {
+	startTime, ok := state.Get("startTime").(time.Time)
+	if !ok {
+		return ""
+	}
+	eta, ok := progressETA(state.Value(), state.Total(), time.Since(startTime))
+	if !ok {
+		return "" // indeterminate
+	}
+	return g.F("ETA %s", eta)
+}
+
+// progressETA returns the estimated remaining duration to reach the total,
+// at the average rate since the start. Returns false if unknown.
+func progressETA(current, total int64, elapsed time.Duration) (time.Duration, bool) {
+	if total <= 0 || current <= 0 || elapsed <= 0 {
+		return 0, false
+	} else if current >= total {
+		return 0, true
+	}
+
+	rate := float64(current) / elapsed.Seconds() // rows per second
+	remaining := time.Duration(float64(total-current) / rate * float64(time.Second))
+	return remaining.Round(time.Second), true
+}
+
 var elementRowRate pb.ElementFunc = func(state *pb.State, args ...string) string {
 	bytes := cast.ToString(state.Get("rowRate"))
 	return g.F("| %s", bytes)
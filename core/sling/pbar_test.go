@@ -0,0 +1,54 @@
+package sling
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressETA(t *testing.T) {
+	// 1,000 rows in 10s => 100 r/s, 9,000 rows remaining => 90s
+	eta, ok := progressETA(1000, 10000, 10*time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, 90*time.Second, eta)
+
+	// halfway, same duration remaining
+	eta, ok = progressETA(5000, 10000, 2*time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, eta)
+
+	// done, or over the total (table grew)
+	eta, ok = progressETA(10500, 10000, time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), eta)
+
+	// indeterminate
+	for _, args := range [][]int64{{1000, 0}, {0, 10000}, {-1, 10}} {
+		_, ok = progressETA(args[0], args[1], time.Minute)
+		assert.False(t, ok)
+	}
+	_, ok = progressETA(1000, 10000, 0)
+	assert.False(t, ok)
+}
+
+func TestParseProgressFormat(t *testing.T) {
+	elements, err := ParseProgressFormat(" Rows, percent,eta ")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"rows", "percent", "eta"}, elements)
+		assert.Equal(t, `{{counters . }} {{pct . | cyan }} {{ eta . | magenta }} {{ status . }}`, progressTemplate(elements))
+	}
+
+	_, err = ParseProgressFormat("rows,speed")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid progress element 'speed'")
+	}
+
+	_, err = ParseProgressFormat("")
+	assert.Error(t, err)
+
+	pbar := &ProgressBar{format: []string{"rows", "rate"}}
+	assert.False(t, pbar.NeedsTotal())
+	pbar.format = append(pbar.format, "eta")
+	assert.True(t, pbar.NeedsTotal())
+}
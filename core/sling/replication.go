@@ -20,9 +20,15 @@ import (
 type ReplicationConfig struct {
 	Source   string                              `json:"source,omitempty" yaml:"source,omitempty"`
 	Target   string                              `json:"target,omitempty" yaml:"target,omitempty"`
+	Targets  []ReplicationTarget                 `json:"targets,omitempty" yaml:"targets,omitempty"`
 	Defaults ReplicationStreamConfig             `json:"defaults,omitempty" yaml:"defaults,omitempty"`
 	Streams  map[string]*ReplicationStreamConfig `json:"streams,omitempty" yaml:"streams,omitempty"`
 	Env      map[string]any                      `json:"env,omitempty" yaml:"env,omitempty"`
+	Hooks    ReplicationHooks                    `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+	Join     *ReplicationJoin                    `json:"join,omitempty" yaml:"join,omitempty"`
+
+	// ObjectNaming are the target object naming rules (target.object_naming)
+	ObjectNaming *ObjectNaming `json:"object_naming,omitempty" yaml:"-"`
 
 	// Tasks are compiled tasks
 	Tasks    []*Config `json:"tasks"`
@@ -67,6 +73,29 @@ func (rd *ReplicationConfig) JSON() string {
 	return payload
 }
 
+// ResolvedYAML returns the compiled streams as YAML, each with its
+// effective config (see Config.ResolvedMap)
+func (rd *ReplicationConfig) ResolvedYAML() (string, error) {
+	streams := yaml.MapSlice{}
+	for _, cfg := range rd.Tasks {
+		m, err := cfg.ResolvedMap()
+		if err != nil {
+			return "", g.Error(err, "could not resolve stream %s", cfg.StreamName)
+		}
+		streams = append(streams, yaml.MapItem{Key: cfg.StreamName, Value: m})
+	}
+
+	out, err := yaml.Marshal(yaml.MapSlice{
+		{Key: "source", Value: connection.RedactURL(rd.Source)},
+		{Key: "target", Value: connection.RedactURL(rd.Target)},
+		{Key: "streams", Value: streams},
+	})
+	if err != nil {
+		return "", g.Error(err, "could not marshal replication")
+	}
+	return string(out), nil
+}
+
 // MD5 returns a md5 hash of the json payload
 func (rd *ReplicationConfig) MD5() string {
 	return g.MD5(rd.OriginalCfg())
@@ -417,6 +446,14 @@ func (rd *ReplicationConfig) Compile(cfgOverwrite *Config, selectStreams ...stri
 		return g.Error("cannot include and exclude tags. Either include or exclude.")
 	}
 
+	// streams selected by their exact name (not by wildcard or tag)
+	explicitStreams := []string{}
+	for _, selectStream := range selectStreams {
+		if _, _, found := rd.GetStream(selectStream); found {
+			explicitStreams = append(explicitStreams, rd.Normalize(selectStream))
+		}
+	}
+
 	for _, name := range rd.StreamsOrdered() {
 
 		stream := ReplicationStreamConfig{}
@@ -425,6 +462,13 @@ func (rd *ReplicationConfig) Compile(cfgOverwrite *Config, selectStreams ...stri
 		}
 		SetStreamDefaults(name, &stream, *rd)
 
+		// derive the object from the naming rules, unless explicit
+		if on := rd.ObjectNaming; on != nil && on.Object != "" {
+			if _, explicit := rd.maps.Streams[name]["object"]; !explicit {
+				stream.Object = on.Object
+			}
+		}
+
 		if stream.Object == "" {
 			return g.Error("need to specify `object` for stream `%s`. Please see https://docs.slingdata.io/sling-cli for help.", name)
 		}
@@ -453,6 +497,14 @@ func (rd *ReplicationConfig) Compile(cfgOverwrite *Config, selectStreams ...stri
 			continue
 		}
 
+		// disabled streams explicitly selected by name need to be forced
+		if stream.Disabled && g.In(rd.Normalize(name), explicitStreams...) {
+			if !cast.ToBool(os.Getenv("SLING_FORCE_DISABLED")) {
+				return g.Error("stream `%s` is disabled. Use the --force-disabled flag to run it.", name)
+			}
+			stream.Disabled = false
+		}
+
 		// config overwrite
 		taskEnv := g.ToMapString(rd.Env)
 		var incrementalVal string
@@ -522,6 +574,7 @@ func (rd *ReplicationConfig) Compile(cfgOverwrite *Config, selectStreams ...stri
 				Select:      stream.Select,
 				PrimaryKeyI: stream.PrimaryKey(),
 				UpdateKey:   stream.UpdateKey,
+				Union:       stream.Union,
 			},
 			Target: Target{
 				Conn:    rd.Target,
@@ -530,10 +583,12 @@ func (rd *ReplicationConfig) Compile(cfgOverwrite *Config, selectStreams ...stri
 			},
 			Mode:              stream.Mode,
 			Transforms:        stream.Transforms,
+			Assertions:        stream.Assertions,
 			Env:               taskEnv,
 			StreamName:        name,
 			IncrementalVal:    incrementalVal,
 			ReplicationStream: &stream,
+			objectNaming:      rd.ObjectNaming,
 		}
 
 		// so that the next stream does not retain previous pointer values
@@ -549,6 +604,11 @@ func (rd *ReplicationConfig) Compile(cfgOverwrite *Config, selectStreams ...stri
 			cfg.Target.Options.FileMaxRows = g.Int64(0)
 		}
 
+		// fan-out targets
+		if err = rd.compileFanOut(name, &stream, &cfg); err != nil {
+			return
+		}
+
 		// prepare config
 		err = cfg.Prepare()
 		if err != nil {
@@ -556,9 +616,23 @@ func (rd *ReplicationConfig) Compile(cfgOverwrite *Config, selectStreams ...stri
 			return
 		}
 
+		// the stream mode needs its own options (keys, range), checked before running any stream
+		if !stream.Disabled {
+			if err = cfg.validateMode(); err != nil {
+				return g.Error(err, "invalid mode `%s` for stream: %s", cfg.effectiveMode(), name)
+			}
+		}
+
 		rd.Tasks = append(rd.Tasks, &cfg)
 	}
 
+	// join tasks, selected with the `join` stream name
+	if rd.Join != nil && (len(selectStreams) == 0 || g.In(JoinStreamName, selectStreams...)) {
+		if err = rd.compileJoin(); err != nil {
+			return g.Error(err, "could not compile join")
+		}
+	}
+
 	rd.Compiled = true
 
 	g.Trace("len(selectStreams) = %d, len(matchedStreams) = %d, len(replication.Streams) = %d", len(selectStreams), len(matchedStreams), len(rd.Streams))
@@ -588,6 +662,8 @@ type ReplicationStreamConfig struct {
 	Columns       any            `json:"columns,omitempty" yaml:"columns,omitempty"`
 	PreHooks      Hooks          `json:"pre_hooks,omitempty" yaml:"pre_hooks,omitempty"`
 	PostHooks     Hooks          `json:"post_hooks,omitempty" yaml:"post_hooks,omitempty"`
+	Assertions    Assertions     `json:"assertions,omitempty" yaml:"assertions,omitempty"`
+	Union         []*UnionSource `json:"union,omitempty" yaml:"union,omitempty"`
 }
 
 func (s *ReplicationStreamConfig) PrimaryKey() []string {
@@ -617,6 +693,7 @@ func SetStreamDefaults(name string, stream *ReplicationStreamConfig, replication
 		"columns":     func() { stream.Columns = replicationCfg.Defaults.Columns },
 		"pre_hooks":   func() { stream.PreHooks = replicationCfg.Defaults.PreHooks },
 		"post_hooks":  func() { stream.PostHooks = replicationCfg.Defaults.PostHooks },
+		"assertions":  func() { stream.Assertions = replicationCfg.Defaults.Assertions },
 	}
 
 	for key, setFunc := range defaultSet {
@@ -662,8 +739,12 @@ func UnmarshalReplication(replicYAML string) (config ReplicationConfig, err erro
 		}
 	}
 
-	// replace variables across the yaml file
+	// replace variables across the yaml file, the config vars
+	// (flag `--config-var`) overriding the env defaults
 	Env = lo.Ternary(Env == nil, map[string]any{}, Env)
+	for k, v := range ConfigVars() {
+		Env[k] = v
+	}
 	replicYAML = g.Rm(replicYAML, Env)
 
 	// parse again
@@ -681,10 +762,27 @@ func UnmarshalReplication(replicYAML string) (config ReplicationConfig, err erro
 		return
 	}
 
+	// multiple targets (fan-out), the first one is the main target
+	var targets []ReplicationTarget
+	if val, ok := m["targets"]; ok {
+		if err = g.Unmarshal(g.Marshal(val), &targets); err != nil {
+			err = g.Error(err, "could not parse 'targets'")
+			return
+		} else if len(targets) == 0 {
+			err = g.Error("'targets' cannot be empty")
+			return
+		}
+	}
+
 	target, ok := m["target"]
-	if !ok {
+	if !ok && len(targets) > 0 {
+		target = targets[0].Conn
+	} else if !ok {
 		err = g.Error("did not find 'target' key")
 		return
+	} else if len(targets) > 0 {
+		err = g.Error("cannot specify both 'target' and 'targets'")
+		return
 	}
 
 	defaults, ok := m["defaults"]
@@ -693,21 +791,44 @@ func UnmarshalReplication(replicYAML string) (config ReplicationConfig, err erro
 	}
 
 	streams, ok := m["streams"]
-	if !ok {
+	if _, hasJoin := m["join"]; !ok && hasJoin {
+		streams = g.M() // streams not mandatory with a join
+	} else if !ok {
 		err = g.Error("did not find 'streams' key")
 		return
 	}
 
+	// target can be a map, with the object naming rules
+	targetConn := cast.ToString(target)
+	var objectNaming *ObjectNaming
+	if _, isString := target.(string); !isString && target != nil {
+		targetCfg := struct {
+			Conn         string        `json:"conn"`
+			ObjectNaming *ObjectNaming `json:"object_naming"`
+		}{}
+		if err = g.Unmarshal(g.Marshal(target), &targetCfg); err != nil {
+			err = g.Error(err, "could not parse 'target'")
+			return
+		} else if targetCfg.ObjectNaming != nil {
+			if err = targetCfg.ObjectNaming.Validate(); err != nil {
+				return
+			}
+		}
+		targetConn, objectNaming = targetCfg.Conn, targetCfg.ObjectNaming
+	}
+
 	maps := replicationConfigMaps{}
 	g.Unmarshal(g.Marshal(defaults), &maps.Defaults)
 	g.Unmarshal(g.Marshal(streams), &maps.Streams)
 
 	config = ReplicationConfig{
-		Source:      cast.ToString(source),
-		Target:      cast.ToString(target),
-		Env:         Env,
-		maps:        maps,
-		originalCfg: replicYAML, // set originalCfg
+		Source:       cast.ToString(source),
+		Target:       targetConn,
+		Targets:      targets,
+		Env:          Env,
+		ObjectNaming: objectNaming,
+		maps:         maps,
+		originalCfg:  replicYAML, // set originalCfg
 	}
 
 	// parse defaults
@@ -724,6 +845,24 @@ func UnmarshalReplication(replicYAML string) (config ReplicationConfig, err erro
 		return
 	}
 
+	// parse hooks
+	if hooks, ok := m["hooks"]; ok {
+		err = g.Unmarshal(g.Marshal(hooks), &config.Hooks)
+		if err != nil {
+			err = g.Error(err, "could not parse 'hooks'")
+			return
+		}
+	}
+
+	// parse join
+	if join, ok := m["join"]; ok {
+		err = g.Unmarshal(g.Marshal(join), &config.Join)
+		if err != nil {
+			err = g.Error(err, "could not parse 'join'")
+			return
+		}
+	}
+
 	// get streams & columns order
 	rootMap := yaml.MapSlice{}
 	err = yaml.Unmarshal([]byte(replicYAML), &rootMap)
@@ -0,0 +1,162 @@
+package sling
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/connection"
+	"github.com/slingdata-io/sling-cli/core/env"
+)
+
+// JoinStreamName is the stream name of the final join task
+const JoinStreamName = "join"
+
+// ReplicationJoin loads several source streams into an ephemeral DuckDB
+// database, then writes the result of the join SQL into the target.
+//
+//	join:
+//	  streams:
+//	    - name: customers
+//	      stream: file://data/customers.csv
+//	    - name: orders
+//	      conn: MY_POSTGRES   # defaults to the replication source
+//	      stream: public.orders
+//	  sql: select * from customers join orders using (customer_id)
+//	  object: public.customer_orders
+type ReplicationJoin struct {
+	Streams       []*JoinStream  `json:"streams" yaml:"streams"`
+	SQL           string         `json:"sql" yaml:"sql"`
+	Object        string         `json:"object" yaml:"object"`
+	Mode          Mode           `json:"mode,omitempty" yaml:"mode,omitempty"`
+	PrimaryKeyI   any            `json:"primary_key,omitempty" yaml:"primary_key,flow,omitempty"`
+	UpdateKey     string         `json:"update_key,omitempty" yaml:"update_key,omitempty"`
+	TargetOptions *TargetOptions `json:"target_options,omitempty" yaml:"target_options,omitempty"`
+
+	dbPath string
+}
+
+// JoinStream is a source stream loaded as a table into the join database
+type JoinStream struct {
+	Name          string         `json:"name" yaml:"name"` // the table name in the join SQL
+	Conn          string         `json:"conn,omitempty" yaml:"conn,omitempty"`
+	Stream        string         `json:"stream" yaml:"stream"`
+	SQL           string         `json:"sql,omitempty" yaml:"sql,omitempty"`
+	Select        []string       `json:"select,omitempty" yaml:"select,flow,omitempty"`
+	SourceOptions *SourceOptions `json:"source_options,omitempty" yaml:"source_options,omitempty"`
+}
+
+// Validate checks the join block
+func (j *ReplicationJoin) Validate() error {
+	if len(j.Streams) == 0 {
+		return g.Error("join: need to specify at least one stream")
+	} else if strings.TrimSpace(j.SQL) == "" {
+		return g.Error("join: need to specify the join `sql`")
+	} else if j.Object == "" {
+		return g.Error("join: need to specify the target `object`")
+	}
+
+	names := map[string]bool{}
+	for i, stream := range j.Streams {
+		if stream == nil || stream.Name == "" {
+			return g.Error("join: need to specify `name` for stream #%d", i+1)
+		} else if stream.Stream == "" && stream.SQL == "" {
+			return g.Error("join: need to specify `stream` or `sql` for stream `%s`", stream.Name)
+		} else if names[strings.ToLower(stream.Name)] {
+			return g.Error("join: duplicate stream name `%s`", stream.Name)
+		}
+		names[strings.ToLower(stream.Name)] = true
+	}
+	return nil
+}
+
+// DbURL returns the URL of the ephemeral DuckDB database
+func (j *ReplicationJoin) DbURL() string {
+	if j.dbPath == "" {
+		j.dbPath = path.Join(env.GetTempFolder(), g.F("sling_join_%s.duckdb", g.NowFileStr()))
+	}
+	return "duckdb://" + j.dbPath
+}
+
+// Cleanup removes the ephemeral DuckDB database
+func (j *ReplicationJoin) Cleanup() {
+	if j == nil || j.dbPath == "" {
+		return
+	}
+	os.Remove(j.dbPath)
+	os.Remove(j.dbPath + ".wal")
+}
+
+// compileJoin compiles the join block into tasks: one per stream, loading
+// into the join database, then the join SQL into the target
+func (rd *ReplicationConfig) compileJoin() (err error) {
+	j := rd.Join
+	if err = j.Validate(); err != nil {
+		return err
+	}
+
+	dbURL := j.DbURL()
+	for _, stream := range j.Streams {
+		// local file streams do not need a connection
+		conn := lo.Ternary(stream.Conn != "", stream.Conn, rd.Source)
+		if stream.Conn == "" && connection.SchemeType(stream.Stream) == dbio.TypeFileLocal {
+			conn = ""
+		}
+
+		cfg := Config{
+			Source: Source{
+				Conn:   conn,
+				Stream: lo.Ternary(stream.Stream != "", stream.Stream, stream.Name),
+				Query:  stream.SQL,
+				Select: stream.Select,
+			},
+			Target: Target{
+				Conn:   dbURL,
+				Object: "main." + stream.Name,
+			},
+			Mode:              FullRefreshMode,
+			Env:               g.ToMapString(rd.Env),
+			StreamName:        JoinStreamName + "." + stream.Name,
+			ReplicationStream: &ReplicationStreamConfig{Object: "main." + stream.Name},
+		}
+		g.Unmarshal(g.Marshal(stream.SourceOptions), &cfg.Source.Options)
+
+		if err = cfg.Prepare(); err != nil {
+			return g.Error(err, "could not prepare join stream task: %s", stream.Name)
+		}
+		rd.Tasks = append(rd.Tasks, &cfg)
+	}
+
+	mode := j.Mode
+	if mode == "" {
+		mode = FullRefreshMode
+	}
+
+	cfg := Config{
+		Source: Source{
+			Conn:        dbURL,
+			Stream:      j.SQL,
+			PrimaryKeyI: j.PrimaryKeyI,
+			UpdateKey:   j.UpdateKey,
+		},
+		Target: Target{
+			Conn:   rd.Target,
+			Object: j.Object,
+		},
+		Mode:              mode,
+		Env:               g.ToMapString(rd.Env),
+		StreamName:        JoinStreamName,
+		ReplicationStream: &ReplicationStreamConfig{Object: j.Object, Mode: mode},
+	}
+	g.Unmarshal(g.Marshal(j.TargetOptions), &cfg.Target.Options)
+
+	if err = cfg.Prepare(); err != nil {
+		return g.Error(err, "could not prepare join task")
+	}
+	rd.Tasks = append(rd.Tasks, &cfg)
+
+	return nil
+}
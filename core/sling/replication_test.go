@@ -1,11 +1,15 @@
 package sling
 
 import (
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
 	"github.com/flarco/g"
 	"github.com/slingdata-io/sling-cli/core/dbio/connection"
+	"github.com/slingdata-io/sling-cli/core/dbio/database"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -193,3 +197,361 @@ func TestReplicationWildcards(t *testing.T) {
 
 	}
 }
+
+func TestReplicationDisabled(t *testing.T) {
+	yaml := `
+source: LOCAL
+target: LOCAL
+defaults:
+	mode: full-refresh
+streams:
+	file:///tmp/sling/disabled_1.csv:
+		object: file:///tmp/sling/disabled_1_out.csv
+	file:///tmp/sling/disabled_2.csv:
+		object: file:///tmp/sling/disabled_2_out.csv
+		disabled: true
+	`
+	yaml = strings.ReplaceAll(yaml, "\t", "  ")
+
+	compile := func(selectStreams ...string) (replication ReplicationConfig, err error) {
+		replication, err = UnmarshalReplication(yaml)
+		if err != nil {
+			return
+		}
+		err = replication.Compile(nil, selectStreams...)
+		return
+	}
+
+	// disabled stream is compiled, but flagged to skip
+	replication, err := compile()
+	if assert.NoError(t, err) && assert.Len(t, replication.Tasks, 2) {
+		assert.False(t, replication.Tasks[0].ReplicationStream.Disabled)
+		assert.True(t, replication.Tasks[1].ReplicationStream.Disabled)
+	}
+
+	// selecting a disabled stream by name requires forcing
+	_, err = compile("file:///tmp/sling/disabled_2.csv")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--force-disabled")
+	}
+
+	os.Setenv("SLING_FORCE_DISABLED", "true")
+	defer os.Unsetenv("SLING_FORCE_DISABLED")
+
+	replication, err = compile("file:///tmp/sling/disabled_2.csv")
+	if assert.NoError(t, err) && assert.Len(t, replication.Tasks, 1) {
+		assert.False(t, replication.Tasks[0].ReplicationStream.Disabled)
+	}
+}
+
+func TestReplicationHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands use sh")
+	}
+
+	folder, err := os.MkdirTemp("", "sling_hooks")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(folder)
+	outFile := filepath.Join(folder, "hooks.txt")
+
+	yaml := g.F(`
+source: LOCAL
+target: LOCAL
+hooks:
+  start:
+    - echo "start $SLING_STATUS $SLING_STREAM" >> %[1]s
+  end:
+    - echo "end $SLING_STATUS $SLING_ROWS $SLING_STREAM" >> %[1]s
+  error:
+    - exit 3
+streams:
+  file://tests/files/test1.csv:
+`, outFile)
+
+	replication, err := LoadReplicationConfig(yaml)
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	assert.Len(t, replication.Hooks.Start, 1)
+	assert.Len(t, replication.Hooks.End, 1)
+
+	// not enabled, skipped
+	os.Unsetenv("SLING_SHELL_HOOKS")
+	g.AssertNoError(t, replication.Hooks.Execute("start", map[string]string{"SLING_STATUS": "running"}))
+	assert.False(t, g.PathExists(outFile))
+
+	os.Setenv("SLING_SHELL_HOOKS", "true")
+	defer os.Unsetenv("SLING_SHELL_HOOKS")
+
+	vars := map[string]string{"SLING_STATUS": "running", "SLING_ROWS": "0", "SLING_STREAM": "a,b"}
+	g.AssertNoError(t, replication.Hooks.Execute("start", vars))
+
+	vars = map[string]string{"SLING_STATUS": "success", "SLING_ROWS": "42", "SLING_STREAM": "a,b"}
+	g.AssertNoError(t, replication.Hooks.Execute("end", vars))
+
+	content, err := os.ReadFile(outFile)
+	if g.AssertNoError(t, err) {
+		assert.Equal(t, "start running a,b\nend success 42 a,b\n", string(content))
+	}
+
+	// non-zero exit returns an error
+	err = replication.Hooks.Execute("error", map[string]string{"SLING_STATUS": "error"})
+	assert.Error(t, err)
+
+	_, err = LoadReplicationConfig("source: LOCAL\ntarget: LOCAL\nhooks:\n  start: 1\nstreams:\n  a:\n")
+	assert.Error(t, err)
+}
+
+func TestReplicationJoin(t *testing.T) {
+	yaml := `
+source: LOCAL
+target: duckdb:///tmp/sling_join_target.duckdb
+join:
+  streams:
+    - name: customers
+      stream: file://tests/files/test1.csv
+    - name: orders
+      stream: file://tests/files/test2.csv
+  sql: select * from customers join orders using (id)
+  object: main.customer_orders
+`
+	replication, err := LoadReplicationConfig(yaml)
+	if !g.AssertNoError(t, err) || !assert.NotNil(t, replication.Join) {
+		return
+	}
+	assert.Len(t, replication.Join.Streams, 2)
+
+	err = replication.Compile(nil)
+	if !g.AssertNoError(t, err) || !assert.Len(t, replication.Tasks, 3) {
+		return
+	}
+	defer replication.Join.Cleanup()
+
+	joinURL := replication.Join.DbURL()
+	assert.True(t, strings.HasPrefix(joinURL, "duckdb://"))
+
+	assert.Equal(t, "join.customers", replication.Tasks[0].StreamName)
+	assert.Equal(t, joinURL, replication.Tasks[0].Target.Conn)
+	assert.Equal(t, "main.customers", replication.Tasks[0].Target.Object)
+	assert.Equal(t, "join.orders", replication.Tasks[1].StreamName)
+
+	final := replication.Tasks[2]
+	assert.Equal(t, JoinStreamName, final.StreamName)
+	assert.Equal(t, joinURL, final.Source.Conn)
+	assert.Contains(t, final.Source.Stream, "join orders")
+	assert.Equal(t, "main.customer_orders", final.Target.Object)
+	assert.Equal(t, FullRefreshMode, final.Mode)
+
+	// validation
+	err = (&ReplicationJoin{Streams: []*JoinStream{{Name: "a", Stream: "x"}}, Object: "t"}).Validate()
+	assert.ErrorContains(t, err, "sql")
+	err = (&ReplicationJoin{Streams: []*JoinStream{{Name: "a", Stream: "x"}, {Name: "A", Stream: "y"}}, SQL: "select 1", Object: "t"}).Validate()
+	assert.ErrorContains(t, err, "duplicate")
+}
+
+func TestReplicationFanOut(t *testing.T) {
+	yaml := `
+source: LOCAL
+targets:
+  - conn: duckdb:///tmp/sling_fan_out.duckdb
+    mode: full-refresh
+  - conn: LOCAL
+    object: file:///tmp/sling_fan_out/{stream_file_name}.csv
+    mode: truncate
+streams:
+  file://tests/files/test1.csv:
+    object: main.test1
+`
+	replication, err := LoadReplicationConfig(yaml)
+	if !g.AssertNoError(t, err) || !assert.Len(t, replication.Targets, 2) {
+		return
+	}
+	assert.Equal(t, "duckdb:///tmp/sling_fan_out.duckdb", replication.Target)
+
+	err = replication.Compile(nil)
+	if !g.AssertNoError(t, err) || !assert.Len(t, replication.Tasks, 1) {
+		return
+	}
+
+	task := replication.Tasks[0]
+	assert.Equal(t, "main.test1", task.Target.Object)
+	assert.Equal(t, FullRefreshMode, task.Mode)
+	if assert.Len(t, task.FanOut, 1) {
+		assert.Equal(t, "LOCAL", task.FanOut[0].Target.Conn)
+		assert.Equal(t, "file:///tmp/sling_fan_out/test1.csv", task.FanOut[0].Target.Object)
+		assert.Equal(t, TruncateMode, task.FanOut[0].Mode)
+	}
+
+	// an incremental extraction cannot fully refresh another target
+	yaml = `
+source: LOCAL
+targets:
+  - conn: duckdb:///tmp/sling_fan_out.duckdb
+    mode: incremental
+  - conn: LOCAL
+    object: file:///tmp/sling_fan_out/{stream_file_name}.csv
+    mode: full-refresh
+streams:
+  file://tests/files/test1.csv:
+    object: main.test1
+    primary_key: id
+    update_key: create_dt
+`
+	replication, err = LoadReplicationConfig(yaml)
+	if g.AssertNoError(t, err) {
+		err = replication.Compile(nil)
+		assert.ErrorContains(t, err, "extracted incrementally")
+	}
+
+	_, err = LoadReplicationConfig("source: LOCAL\ntarget: LOCAL\ntargets:\n  - conn: LOCAL\nstreams:\n  a:\n")
+	assert.Error(t, err)
+}
+
+func TestReplicationStreamModes(t *testing.T) {
+	yaml := `
+source: LOCAL
+target: duckdb:///tmp/sling_stream_modes.duckdb
+defaults:
+  mode: incremental
+  object: main.{stream_file_name}
+  primary_key: [id]
+  update_key: create_dt
+streams:
+  file://tests/files/test1.csv:
+  file://tests/files/test2.csv:
+    mode: full-refresh
+  file://tests/files/test3.csv:
+    mode: backfill
+    source_options:
+      range: 2021-01-01,2021-02-01
+  file://tests/files/test4.csv:
+    mode: snapshot
+`
+	replication, err := LoadReplicationConfig(yaml)
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	err = replication.Compile(nil)
+	if !g.AssertNoError(t, err) || !assert.Len(t, replication.Tasks, 4) {
+		return
+	}
+
+	modes := map[string]Mode{}
+	for _, task := range replication.Tasks {
+		modes[task.StreamName] = task.Mode
+	}
+	assert.Equal(t, IncrementalMode, modes["file://tests/files/test1.csv"])
+	assert.Equal(t, FullRefreshMode, modes["file://tests/files/test2.csv"])
+	assert.Equal(t, BackfillMode, modes["file://tests/files/test3.csv"])
+	assert.Equal(t, SnapshotMode, modes["file://tests/files/test4.csv"])
+	assert.Equal(t, "2021-01-01,2021-02-01", g.PtrVal(replication.Tasks[2].Source.Options.Range))
+
+	// the effective mode is printed per stream, with the keys resolved
+	out, err := replication.ResolvedYAML()
+	if g.AssertNoError(t, err) {
+		assert.Contains(t, out, "mode: incremental")
+		assert.Contains(t, out, "mode: full-refresh")
+		assert.Contains(t, out, "mode: backfill")
+	}
+
+	// each stream is validated with its own mode, at compile time
+	type test struct {
+		streams  string
+		errorMsg string
+	}
+	tests := []test{
+		{
+			streams: `
+  file://tests/files/test1.csv:
+    mode: full-refresh
+  file://tests/files/test2.csv:
+    mode: backfill
+    primary_key: [id]
+    update_key: create_dt`,
+			errorMsg: "must specify range",
+		},
+		{
+			streams: `
+  file://tests/files/test1.csv:
+  file://tests/files/test2.csv:
+    mode: incremental`,
+			errorMsg: "for incremental mode",
+		},
+		{
+			streams: `
+  file://tests/files/test1.csv:
+    mode: upsert`,
+			errorMsg: "must specify valid mode",
+		},
+	}
+
+	for i, tt := range tests {
+		replication, err = LoadReplicationConfig(`
+source: LOCAL
+target: duckdb:///tmp/sling_stream_modes.duckdb
+defaults:
+  object: main.{stream_file_name}
+streams:` + tt.streams + "\n")
+		if !g.AssertNoError(t, err, "test %d", i) {
+			continue
+		}
+		err = replication.Compile(nil)
+		if assert.Error(t, err, "test %d", i) {
+			assert.Contains(t, err.Error(), tt.errorMsg, "test %d", i)
+		}
+	}
+
+	// disabled streams are not validated
+	replication, err = LoadReplicationConfig(`
+source: LOCAL
+target: duckdb:///tmp/sling_stream_modes.duckdb
+defaults:
+  object: main.{stream_file_name}
+streams:
+  file://tests/files/test1.csv:
+  file://tests/files/test2.csv:
+    mode: backfill
+    disabled: true
+`)
+	if g.AssertNoError(t, err) {
+		assert.NoError(t, replication.Compile(nil))
+	}
+}
+
+func TestObjectNaming(t *testing.T) {
+	on := &ObjectNaming{
+		Object:  "{stream_schema}.stg_{stream_table}",
+		Schemas: map[string]string{"PUBLIC": "raw"},
+		Rename:  map[string]string{`^tbl_(.*)$`: "$1", `^(.*)_v\d+$`: "$1"},
+	}
+	g.AssertNoError(t, on.Validate())
+
+	m := map[string]any{"stream_schema": "public", "stream_table": "tbl_customers"}
+	on.Apply(m)
+	assert.Equal(t, "raw", m["stream_schema"])
+	assert.Equal(t, "customers", m["stream_table"])
+
+	// first matching pattern, in sorted order
+	m = map[string]any{"stream_schema": "sales", "stream_table": "tbl_orders_v2"}
+	on.Apply(m)
+	assert.Equal(t, "sales", m["stream_schema"])
+	assert.Equal(t, "tbl_orders", m["stream_table"])
+
+	// illegal identifiers
+	assert.Error(t, on.ValidateObjectName(database.Table{Schema: "raw", Name: "stg-orders", Raw: "raw.stg-orders"}))
+	g.AssertNoError(t, on.ValidateObjectName(database.Table{Schema: "raw", Name: "stg_orders", Raw: "raw.stg_orders"}))
+
+	// invalid pattern
+	_, err := LoadReplicationConfig("source: LOCAL\ntarget:\n  conn: LOCAL\n  object_naming:\n    rename:\n      '(': x\nstreams:\n  a:\n")
+	assert.Error(t, err)
+
+	// target as a map
+	replication, err := LoadReplicationConfig("source: LOCAL\ntarget:\n  conn: MY_PG\n  object_naming:\n    object: '{stream_table}'\nstreams:\n  a:\n")
+	if g.AssertNoError(t, err) && assert.NotNil(t, replication.ObjectNaming) {
+		assert.Equal(t, "MY_PG", replication.Target)
+		assert.Equal(t, "{stream_table}", replication.ObjectNaming.Object)
+	}
+}
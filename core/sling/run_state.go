@@ -0,0 +1,109 @@
+package sling
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/env"
+)
+
+// RunState is the outcome of the streams of the last run of a replication,
+// stored under SLING_HOME and keyed by the replication file path. It allows
+// re-running only the streams which failed or did not run (`--resume-failed`).
+type RunState struct {
+	Replication string                     `json:"replication"`
+	UpdatedAt   time.Time                  `json:"updated_at"`
+	Streams     map[string]*RunStateStream `json:"streams"`
+
+	path string
+}
+
+// RunStateStream is the outcome of a stream in the last run
+type RunStateStream struct {
+	Status    ExecStatus `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	ErrorCode ErrorCode  `json:"error_code,omitempty"`
+	EndTime   time.Time  `json:"end_time"`
+}
+
+// RunStateFolder returns the folder of the run state files
+func RunStateFolder() string {
+	return path.Join(env.HomeDir, "runs")
+}
+
+// NewRunState returns an empty run state for the replication file path
+func NewRunState(replicationPath string) *RunState {
+	if absPath, err := filepath.Abs(replicationPath); err == nil {
+		replicationPath = absPath
+	}
+	return &RunState{
+		Replication: replicationPath,
+		Streams:     map[string]*RunStateStream{},
+		path:        path.Join(RunStateFolder(), g.MD5(replicationPath)+".json"),
+	}
+}
+
+// LoadRunState loads the run state of the last run of the replication.
+// Returns an empty run state if none is stored.
+func LoadRunState(replicationPath string) (rs *RunState, err error) {
+	rs = NewRunState(replicationPath)
+
+	bytes, err := os.ReadFile(rs.path)
+	if os.IsNotExist(err) {
+		return rs, nil
+	} else if err != nil {
+		return rs, g.Error(err, "could not read run state: %s", rs.path)
+	}
+
+	if err = json.Unmarshal(bytes, rs); err != nil {
+		return NewRunState(replicationPath), g.Error(err, "could not parse run state: %s", rs.path)
+	} else if rs.Streams == nil {
+		rs.Streams = map[string]*RunStateStream{}
+	}
+
+	return rs, nil
+}
+
+// Succeeded returns whether the stream succeeded in the last run
+func (rs *RunState) Succeeded(streamName string) bool {
+	if rs == nil {
+		return false
+	}
+	s, ok := rs.Streams[streamName]
+	return ok && s.Status == ExecStatusSuccess
+}
+
+// Set records the outcome of the stream, and saves the run state
+func (rs *RunState) Set(streamName string, err error) error {
+	if rs == nil {
+		return nil
+	}
+
+	s := &RunStateStream{Status: ExecStatusSuccess, EndTime: time.Now()}
+	if err != nil {
+		s.Status = ExecStatusError
+		s.Error = err.Error()
+		s.ErrorCode = ClassifyError(err)
+	}
+	rs.Streams[streamName] = s
+
+	return rs.Save()
+}
+
+// Save writes the run state file
+func (rs *RunState) Save() error {
+	if err := os.MkdirAll(path.Dir(rs.path), 0755); err != nil {
+		return g.Error(err, "could not create run state folder")
+	}
+
+	rs.UpdatedAt = time.Now()
+	if err := os.WriteFile(rs.path, []byte(g.Marshal(rs)), 0644); err != nil {
+		return g.Error(err, "could not write run state: %s", rs.path)
+	}
+
+	return nil
+}
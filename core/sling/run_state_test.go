@@ -0,0 +1,39 @@
+package sling
+
+import (
+	"testing"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/env"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunState(t *testing.T) {
+	homeDir := env.HomeDir
+	env.HomeDir = t.TempDir()
+	defer func() { env.HomeDir = homeDir }()
+
+	rs, err := LoadRunState("replication.yaml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, rs.Streams)
+
+	assert.NoError(t, rs.Set("stream_a", nil))
+	assert.NoError(t, rs.Set("stream_b", g.Error("could not connect")))
+
+	// keyed by the absolute path of the replication
+	rs, err = LoadRunState("./replication.yaml")
+	if assert.NoError(t, err) && assert.Len(t, rs.Streams, 2) {
+		assert.True(t, rs.Succeeded("stream_a"))
+		assert.False(t, rs.Succeeded("stream_b"))
+		assert.False(t, rs.Succeeded("stream_c")) // did not run
+		assert.Contains(t, rs.Streams["stream_b"].Error, "could not connect")
+		assert.Equal(t, ErrorCodeConn, rs.Streams["stream_b"].ErrorCode)
+		assert.Empty(t, rs.Streams["stream_a"].ErrorCode)
+	}
+
+	rs, err = LoadRunState("other.yaml")
+	assert.NoError(t, err)
+	assert.Empty(t, rs.Streams)
+}
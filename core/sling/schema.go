@@ -0,0 +1,363 @@
+package sling
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v3"
+)
+
+// JSONSchema is a JSON Schema document, or a sub-schema
+type JSONSchema map[string]any
+
+// schemaEnums are the allowed values of the enum types
+var schemaEnums = map[reflect.Type][]any{
+	reflect.TypeOf(Mode("")): lo.Map(AllMode, func(m struct {
+		Value  Mode
+		TSName string
+	}, i int) any {
+		return string(m.Value)
+	}),
+	reflect.TypeOf(iop.ColumnCasing("")): {
+		string(iop.SourceColumnCasing), string(iop.TargetColumnCasing), string(iop.SnakeColumnCasing),
+		string(iop.UpperColumnCasing), string(iop.LowerColumnCasing), string(iop.NormalizeColumnCasing),
+	},
+}
+
+// ReplicationJSONSchema returns the JSON Schema of the replication config,
+// generated from the ReplicationConfig struct, for editor autocompletion &
+// validation (`sling schema replication`)
+func ReplicationJSONSchema() JSONSchema {
+	gen := &schemaGenerator{defs: JSONSchema{}, defTypes: map[string]reflect.Type{}}
+
+	schema := gen.structSchema(reflect.TypeOf(ReplicationConfig{}))
+
+	// target can be a map, with the object naming rules
+	schema["properties"].(JSONSchema)["target"] = JSONSchema{"anyOf": []any{
+		JSONSchema{"type": "string"},
+		JSONSchema{
+			"type": "object",
+			"properties": JSONSchema{
+				"conn":          JSONSchema{"type": "string"},
+				"object_naming": gen.typeSchema(reflect.TypeOf(&ObjectNaming{})),
+			},
+			"additionalProperties": false,
+		},
+	}}
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "Sling replication config"
+	schema["required"] = []any{"source"}
+	schema["$defs"] = gen.defs
+
+	return schema
+}
+
+// schemaGenerator generates JSON Schemas from Go types, with the structs
+// as definitions
+type schemaGenerator struct {
+	defs     JSONSchema
+	defTypes map[string]reflect.Type
+}
+
+// placeholderSchema matches the variables replaced when the replication is
+// loaded (`{VAR}` or `${VAR}`), which are strings until then
+var placeholderSchema = JSONSchema{"type": "string", "pattern": `\{[^{}]+\}`}
+
+func (gen *schemaGenerator) typeSchema(t reflect.Type) JSONSchema {
+	if values, ok := schemaEnums[t]; ok {
+		return JSONSchema{"anyOf": []any{JSONSchema{"type": "string", "enum": values}, placeholderSchema}}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullableSchema(gen.typeSchema(t.Elem()))
+	case reflect.Interface:
+		return JSONSchema{}
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return JSONSchema{"type": "string"}
+		}
+		return JSONSchema{"$ref": "#/$defs/" + gen.define(t)}
+	case reflect.Map:
+		return JSONSchema{"type": "object", "additionalProperties": gen.typeSchema(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return JSONSchema{"type": "string"}
+		}
+		return JSONSchema{"type": "array", "items": gen.typeSchema(t.Elem())}
+	case reflect.String:
+		return JSONSchema{"type": "string"}
+	case reflect.Bool:
+		return JSONSchema{"anyOf": []any{JSONSchema{"type": "boolean"}, placeholderSchema}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return JSONSchema{"anyOf": []any{JSONSchema{"type": "integer"}, placeholderSchema}}
+	case reflect.Float32, reflect.Float64:
+		return JSONSchema{"anyOf": []any{JSONSchema{"type": "number"}, placeholderSchema}}
+	}
+	return JSONSchema{}
+}
+
+// define adds the struct to the definitions, and returns its name
+func (gen *schemaGenerator) define(t reflect.Type) string {
+	name := t.Name()
+	if other, ok := gen.defTypes[name]; ok && other != t {
+		name = g.F("%s.%s", t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:], name)
+	}
+	if _, ok := gen.defTypes[name]; ok {
+		return name
+	}
+
+	gen.defTypes[name] = t
+	gen.defs[name] = JSONSchema{} // placeholder, for recursive types
+	gen.defs[name] = gen.structSchema(t)
+	return name
+}
+
+// structSchema returns the object schema of the struct, with its yaml keys.
+// Fields without a yaml tag are skipped (json tags are used if the struct
+// has no yaml tags, with untagged embedded structs inlined as with json).
+func (gen *schemaGenerator) structSchema(t reflect.Type) JSONSchema {
+	hasYamlTags := false
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("yaml"); ok {
+			hasYamlTags = true
+		}
+	}
+
+	properties := JSONSchema{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag, ok := field.Tag.Lookup("yaml")
+		if !ok && !hasYamlTags {
+			tag, ok = field.Tag.Lookup("json")
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		parts := strings.Split(tag, ",")
+		inline := lo.Contains(parts[1:], "inline") || (!ok && !hasYamlTags && field.Anonymous)
+		if inline && fieldType.Kind() == reflect.Struct {
+			for key, val := range gen.structSchema(fieldType)["properties"].(JSONSchema) {
+				properties[key] = val
+			}
+			continue
+		} else if !ok || tag == "-" {
+			continue
+		}
+
+		name := lo.Ternary(parts[0] == "", strings.ToLower(field.Name), parts[0])
+		properties[name] = gen.typeSchema(field.Type)
+	}
+
+	return JSONSchema{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// nullableSchema allows null values for the schema
+func nullableSchema(schema JSONSchema) JSONSchema {
+	switch typ := schema["type"].(type) {
+	case string:
+		schema["type"] = []any{typ, "null"}
+		if enum, ok := schema["enum"].([]any); ok {
+			schema["enum"] = append(append([]any{}, enum...), nil)
+		}
+		return schema
+	case nil:
+		if anyOf, ok := schema["anyOf"].([]any); ok {
+			schema["anyOf"] = append(append([]any{}, anyOf...), JSONSchema{"type": "null"})
+		} else if _, ok := schema["$ref"]; ok {
+			return JSONSchema{"anyOf": []any{schema, JSONSchema{"type": "null"}}}
+		}
+	}
+	return schema
+}
+
+// ValidateReplication validates the replication YAML / JSON content against
+// the replication JSON Schema
+func ValidateReplication(content string) error {
+	var value any
+	if err := yaml.Unmarshal([]byte(content), &value); err != nil {
+		return g.Error(err, "could not parse replication")
+	}
+	return ReplicationJSONSchema().Validate(stringKeys(value))
+}
+
+// stringKeys converts the maps with non-string keys (e.g. a stream named
+// `2024`), to validate them as objects
+func stringKeys(value any) any {
+	switch val := value.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(val))
+		for k, v := range val {
+			m[cast.ToString(k)] = stringKeys(v)
+		}
+		return m
+	case map[string]any:
+		for k, v := range val {
+			val[k] = stringKeys(v)
+		}
+	case []any:
+		for i, v := range val {
+			val[i] = stringKeys(v)
+		}
+	}
+	return value
+}
+
+// Validate validates the value against the schema, returning an error listing
+// the violations. Supports the keywords of the generated schemas: $ref (to
+// $defs), anyOf, type, enum, pattern, properties, required,
+// additionalProperties and items.
+func (s JSONSchema) Validate(value any) error {
+	problems := s.validate(s, value, "")
+	if len(problems) > 0 {
+		return g.Error("invalid replication config:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+func (s JSONSchema) validate(schema JSONSchema, value any, path string) (problems []string) {
+	at := lo.Ternary(path == "", "root", path)
+
+	if ref, ok := schema["$ref"].(string); ok {
+		defs, _ := s["$defs"].(JSONSchema)
+		refSchema, ok := defs[strings.TrimPrefix(ref, "#/$defs/")].(JSONSchema)
+		if !ok {
+			return []string{g.F("%s: unknown reference %s", at, ref)}
+		}
+		return s.validate(refSchema, value, path)
+	}
+
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		// report the problems of the first sub-schema, the main type
+		for _, sub := range anyOf {
+			subProblems := s.validate(sub.(JSONSchema), value, path)
+			if len(subProblems) == 0 {
+				return nil
+			} else if problems == nil {
+				problems = subProblems
+			}
+		}
+		return problems
+	}
+
+	if typ, ok := schema["type"]; ok {
+		types := []string{}
+		switch typ := typ.(type) {
+		case string:
+			types = append(types, typ)
+		case []any:
+			types = lo.Map(typ, func(t any, i int) string { return cast.ToString(t) })
+		}
+		if !lo.ContainsBy(types, func(t string) bool { return schemaTypeMatches(t, value) }) {
+			return []string{g.F("%s: expected %s, got %s", at, strings.Join(types, " or "), schemaTypeName(value))}
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		if !lo.ContainsBy(enum, func(e any) bool { return reflect.DeepEqual(e, value) }) {
+			values := lo.Map(enum, func(e any, i int) string { return fmt.Sprint(e) })
+			return []string{g.F("%s: invalid value %v, expected one of: %s", at, value, strings.Join(values, ", "))}
+		}
+	}
+
+	if pattern, ok := schema["pattern"].(string); ok {
+		if !regexp.MustCompile(pattern).MatchString(cast.ToString(value)) {
+			return []string{g.F("%s: value %v does not match %s", at, value, pattern)}
+		}
+	}
+
+	switch val := value.(type) {
+	case map[string]any:
+		if required, ok := schema["required"].([]any); ok {
+			for _, key := range required {
+				if _, ok := val[cast.ToString(key)]; !ok {
+					problems = append(problems, g.F("%s: missing key '%s'", at, key))
+				}
+			}
+		}
+
+		properties, _ := schema["properties"].(JSONSchema)
+		keys := lo.Keys(val)
+		sort.Strings(keys)
+		for _, key := range keys {
+			keyPath := strings.TrimPrefix(path+"."+key, ".")
+			if propSchema, ok := properties[key].(JSONSchema); ok {
+				problems = append(problems, s.validate(propSchema, val[key], keyPath)...)
+			} else if additional, ok := schema["additionalProperties"].(JSONSchema); ok {
+				problems = append(problems, s.validate(additional, val[key], keyPath)...)
+			} else if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+				problems = append(problems, g.F("%s: unknown key '%s'", at, key))
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(JSONSchema); ok {
+			for i, item := range val {
+				problems = append(problems, s.validate(items, item, g.F("%s[%d]", path, i))...)
+			}
+		}
+	}
+
+	return problems
+}
+
+// schemaTypeMatches returns true if the value is of the JSON Schema type
+func schemaTypeMatches(typ string, value any) bool {
+	switch typ {
+	case "null":
+		return value == nil
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		switch v := value.(type) {
+		case int, int64, uint64:
+			return true
+		case float64:
+			return v == float64(int64(v))
+		}
+	case "number":
+		switch value.(type) {
+		case int, int64, uint64, float64:
+			return true
+		}
+	}
+	return false
+}
+
+// schemaTypeName returns the JSON type name of the value
+func schemaTypeName(value any) string {
+	for _, typ := range []string{"null", "object", "array", "string", "boolean", "integer", "number"} {
+		if schemaTypeMatches(typ, value) {
+			return typ
+		}
+	}
+	return fmt.Sprintf("%T", value)
+}
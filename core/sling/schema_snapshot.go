@@ -0,0 +1,222 @@
+package sling
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/slingdata-io/sling-cli/core/env"
+	"github.com/spf13/cast"
+)
+
+// SchemaSnapshot records the source columns of streams in a file, to detect
+// schema drift on subsequent runs. It is enabled with SLING_SCHEMA_SNAPSHOT
+// (flag `--schema-snapshot`). On drift, the run fails, or warns when
+// SLING_ON_SCHEMA_DRIFT is `warn` (flag `--on-schema-drift`). A warned drift
+// is accepted into the snapshot, a failed one is not: delete the stream entry
+// to accept the new schema.
+type SchemaSnapshot struct {
+	Streams map[string]StreamSnapshot `json:"streams"`
+
+	path string
+}
+
+// StreamSnapshot is the recorded schema of a stream
+type StreamSnapshot struct {
+	Columns   []SnapshotColumn `json:"columns"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// SnapshotColumn is a recorded column
+type SnapshotColumn struct {
+	Name string         `json:"name"`
+	Type iop.ColumnType `json:"type"`
+}
+
+// SchemaDrift is the difference between a snapshot and the current columns
+type SchemaDrift struct {
+	Added   []SnapshotColumn    `json:"added,omitempty"`
+	Removed []SnapshotColumn    `json:"removed,omitempty"`
+	Changed []SchemaDriftChange `json:"changed,omitempty"`
+}
+
+// SchemaDriftChange is a column whose type changed
+type SchemaDriftChange struct {
+	Name    string         `json:"name"`
+	OldType iop.ColumnType `json:"old_type"`
+	NewType iop.ColumnType `json:"new_type"`
+}
+
+// SchemaDriftAction is the action on schema drift
+type SchemaDriftAction string
+
+const (
+	SchemaDriftError SchemaDriftAction = "error"
+	SchemaDriftWarn  SchemaDriftAction = "warn"
+)
+
+// LoadSchemaSnapshot reads the snapshot file. A missing file is an empty snapshot.
+func LoadSchemaSnapshot(filePath string) (ss *SchemaSnapshot, err error) {
+	ss = &SchemaSnapshot{Streams: map[string]StreamSnapshot{}, path: filePath}
+
+	bytes, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return ss, nil
+	} else if err != nil {
+		return nil, g.Error(err, "could not read schema snapshot: %s", filePath)
+	}
+
+	if err = json.Unmarshal(bytes, ss); err != nil {
+		return nil, g.Error(err, "could not parse schema snapshot: %s", filePath)
+	} else if ss.Streams == nil {
+		ss.Streams = map[string]StreamSnapshot{}
+	}
+
+	return ss, nil
+}
+
+// Save writes the snapshot file
+func (ss *SchemaSnapshot) Save() (err error) {
+	if folder := path.Dir(ss.path); folder != "" {
+		if err = os.MkdirAll(folder, 0755); err != nil {
+			return g.Error(err, "could not create schema snapshot folder")
+		}
+	}
+
+	bytes, err := json.MarshalIndent(ss, "", "  ")
+	if err != nil {
+		return g.Error(err, "could not serialize schema snapshot")
+	}
+
+	if err = os.WriteFile(ss.path, bytes, 0644); err != nil {
+		return g.Error(err, "could not write schema snapshot: %s", ss.path)
+	}
+	return nil
+}
+
+// Set records the columns of the stream
+func (ss *SchemaSnapshot) Set(key string, cols iop.Columns) {
+	snapshot := StreamSnapshot{UpdatedAt: time.Now().UTC()}
+	for _, col := range cols {
+		snapshot.Columns = append(snapshot.Columns, SnapshotColumn{Name: col.Name, Type: col.Type})
+	}
+	ss.Streams[key] = snapshot
+}
+
+// Compare returns the drift of the columns against the recorded stream
+// columns. ok is false if the stream is not recorded.
+func (ss *SchemaSnapshot) Compare(key string, cols iop.Columns) (drift SchemaDrift, ok bool) {
+	snapshot, ok := ss.Streams[key]
+	if !ok {
+		return drift, false
+	}
+
+	oldCols := map[string]SnapshotColumn{}
+	for _, col := range snapshot.Columns {
+		oldCols[strings.ToLower(col.Name)] = col
+	}
+
+	newCols := map[string]bool{}
+	for _, col := range cols {
+		newCols[strings.ToLower(col.Name)] = true
+		oldCol, found := oldCols[strings.ToLower(col.Name)]
+		if !found {
+			drift.Added = append(drift.Added, SnapshotColumn{Name: col.Name, Type: col.Type})
+		} else if oldCol.Type != col.Type {
+			drift.Changed = append(drift.Changed, SchemaDriftChange{Name: col.Name, OldType: oldCol.Type, NewType: col.Type})
+		}
+	}
+
+	for _, col := range snapshot.Columns {
+		if !newCols[strings.ToLower(col.Name)] {
+			drift.Removed = append(drift.Removed, col)
+		}
+	}
+
+	return drift, true
+}
+
+// IsEmpty returns true if there is no drift
+func (sd SchemaDrift) IsEmpty() bool {
+	return len(sd.Added)+len(sd.Removed)+len(sd.Changed) == 0
+}
+
+// String returns the drift as a diff
+func (sd SchemaDrift) String() string {
+	lines := []string{}
+	for _, col := range sd.Added {
+		lines = append(lines, g.F("  + %s (%s)", col.Name, col.Type))
+	}
+	for _, col := range sd.Removed {
+		lines = append(lines, g.F("  - %s (%s)", col.Name, col.Type))
+	}
+	for _, change := range sd.Changed {
+		lines = append(lines, g.F("  ~ %s (%s => %s)", change.Name, change.OldType, change.NewType))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// schemaSnapshotMux serializes the snapshot file updates of concurrent streams
+var schemaSnapshotMux sync.Mutex
+
+// schemaSnapshotKey returns the key of the stream in the snapshot file
+func (cfg *Config) schemaSnapshotKey() string {
+	if cfg.StreamName != "" {
+		return strings.TrimSpace(cfg.StreamName)
+	}
+	return strings.TrimSpace(cfg.Source.Stream)
+}
+
+// checkSchemaDrift compares the source columns with the schema snapshot,
+// then records them. With SLING_VALIDATE_ONLY, the stream is not loaded.
+func (t *TaskExecution) checkSchemaDrift(df *iop.Dataflow) (err error) {
+	snapshotPath := os.Getenv("SLING_SCHEMA_SNAPSHOT")
+	if snapshotPath == "" {
+		return nil
+	}
+
+	action := SchemaDriftAction(strings.ToLower(os.Getenv("SLING_ON_SCHEMA_DRIFT")))
+	switch action {
+	case "":
+		action = SchemaDriftError
+	case SchemaDriftError, SchemaDriftWarn:
+	default:
+		return g.Error("invalid value for on-schema-drift: %s (expected `error` or `warn`)", action)
+	}
+
+	schemaSnapshotMux.Lock()
+	defer schemaSnapshotMux.Unlock()
+
+	ss, err := LoadSchemaSnapshot(snapshotPath)
+	if err != nil {
+		return err
+	}
+
+	key := t.Config.schemaSnapshotKey()
+	if drift, ok := ss.Compare(key, df.Columns); !ok {
+		t.SetProgress("recording schema snapshot of %s", key)
+	} else if !drift.IsEmpty() {
+		if action == SchemaDriftError {
+			return g.Error("schema drift detected for %s:\n%s", key, drift.String())
+		}
+		env.Warn(env.WarningSchema, "schema drift detected for %s:\n%s", key, drift.String())
+	}
+
+	ss.Set(key, df.Columns)
+	if err = ss.Save(); err != nil {
+		return err
+	}
+
+	if cast.ToBool(os.Getenv("SLING_VALIDATE_ONLY")) {
+		t.SetProgress("validate-only: skipping load")
+		t.skipStream = true
+		df.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package sling
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaSnapshot(t *testing.T) {
+	folder, err := os.MkdirTemp("", "sling_schema_snapshot")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(folder)
+
+	filePath := path.Join(folder, "snapshot.json")
+	cols := iop.Columns{
+		{Name: "id", Type: iop.BigIntType, Position: 1},
+		{Name: "name", Type: iop.StringType, Position: 2},
+		{Name: "amount", Type: iop.DecimalType, Position: 3},
+	}
+
+	// missing file is empty
+	ss, err := LoadSchemaSnapshot(filePath)
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	_, ok := ss.Compare("public.orders", cols)
+	assert.False(t, ok)
+
+	ss.Set("public.orders", cols)
+	g.AssertNoError(t, ss.Save())
+
+	ss, err = LoadSchemaSnapshot(filePath)
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	// no drift, case-insensitive
+	same := iop.Columns{
+		{Name: "ID", Type: iop.BigIntType, Position: 1},
+		{Name: "name", Type: iop.StringType, Position: 2},
+		{Name: "amount", Type: iop.DecimalType, Position: 3},
+	}
+	drift, ok := ss.Compare("public.orders", same)
+	assert.True(t, ok)
+	assert.True(t, drift.IsEmpty())
+
+	// added
+	added := append(iop.Columns{}, cols...)
+	added = append(added, iop.Column{Name: "email", Type: iop.StringType, Position: 4})
+	drift, _ = ss.Compare("public.orders", added)
+	if assert.Len(t, drift.Added, 1) {
+		assert.Equal(t, "email", drift.Added[0].Name)
+	}
+	assert.Empty(t, drift.Removed)
+	assert.Empty(t, drift.Changed)
+	assert.Equal(t, "  + email (string)", drift.String())
+
+	// removed
+	drift, _ = ss.Compare("public.orders", cols[:2])
+	if assert.Len(t, drift.Removed, 1) {
+		assert.Equal(t, "amount", drift.Removed[0].Name)
+	}
+	assert.Empty(t, drift.Added)
+	assert.Empty(t, drift.Changed)
+	assert.Equal(t, "  - amount (decimal)", drift.String())
+
+	// changed
+	changed := iop.Columns{
+		{Name: "id", Type: iop.StringType, Position: 1},
+		{Name: "name", Type: iop.StringType, Position: 2},
+		{Name: "amount", Type: iop.DecimalType, Position: 3},
+	}
+	drift, _ = ss.Compare("public.orders", changed)
+	if assert.Len(t, drift.Changed, 1) {
+		assert.Equal(t, iop.BigIntType, drift.Changed[0].OldType)
+		assert.Equal(t, iop.StringType, drift.Changed[0].NewType)
+	}
+	assert.Equal(t, "  ~ id (bigint => string)", drift.String())
+}
+
+func TestCheckSchemaDrift(t *testing.T) {
+	folder, err := os.MkdirTemp("", "sling_schema_drift")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(folder)
+
+	filePath := path.Join(folder, "snapshot.json")
+	os.Setenv("SLING_SCHEMA_SNAPSHOT", filePath)
+	defer os.Unsetenv("SLING_SCHEMA_SNAPSHOT")
+	defer os.Unsetenv("SLING_ON_SCHEMA_DRIFT")
+
+	task := &TaskExecution{Config: &Config{StreamName: "file://orders.csv"}, PBar: NewPBar(time.Second)}
+	df := iop.NewDataflow()
+	df.Columns = iop.Columns{{Name: "id", Type: iop.BigIntType, Position: 1}}
+
+	// first run records
+	g.AssertNoError(t, task.checkSchemaDrift(df))
+
+	// drift fails, snapshot unchanged
+	df.Columns = iop.Columns{{Name: "id", Type: iop.StringType, Position: 1}}
+	err = task.checkSchemaDrift(df)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "~ id (bigint => string)")
+	}
+
+	// warn accepts the drift
+	os.Setenv("SLING_ON_SCHEMA_DRIFT", "warn")
+	g.AssertNoError(t, task.checkSchemaDrift(df))
+	os.Setenv("SLING_ON_SCHEMA_DRIFT", "error")
+	g.AssertNoError(t, task.checkSchemaDrift(df))
+
+	os.Setenv("SLING_ON_SCHEMA_DRIFT", "ignore")
+	assert.Error(t, task.checkSchemaDrift(df))
+}
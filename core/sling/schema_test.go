@@ -0,0 +1,126 @@
+package sling
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicationJSONSchema(t *testing.T) {
+	schema := ReplicationJSONSchema()
+
+	// must be serializable
+	_, err := json.Marshal(schema)
+	assert.NoError(t, err)
+
+	properties := schema["properties"].(JSONSchema)
+	for _, key := range []string{"source", "target", "defaults", "streams", "env", "hooks"} {
+		assert.Contains(t, properties, key)
+	}
+	assert.NotContains(t, properties, "tasks")
+
+	good := `
+source: POSTGRES
+target: SNOWFLAKE
+defaults:
+	mode: incremental
+	object: '{target_schema}.{stream_table}'
+	primary_key: [id]
+	update_key: updated_at
+	source_options:
+		empty_as_null: false
+		limit: 1000
+		bool_values:
+			truthy: ['Y']
+	target_options:
+		column_casing: snake
+		add_new_columns: true
+		indexes:
+			- columns: [customer_id]
+				unique: false
+		grants:
+			- privilege: select
+				to: role_analyst
+streams:
+	public.orders:
+		mode: full-refresh
+		select: [id, customer_id, amount]
+		assertions:
+			- sql: select count(*) from {object}
+				expect: 0
+	public.customers:
+	public.items:
+		primary_key: id
+		post_hooks:
+			- type: query
+				query: select 1
+env:
+	SLING_THREADS: 3
+hooks:
+	end: ['echo done']
+`
+	err = ValidateReplication(strings.ReplaceAll(good, "\t", "  "))
+	assert.NoError(t, err)
+
+	bad := `
+source: POSTGRES
+target: SNOWFLAKE
+defaults:
+	mode: upsert
+	source_option:
+		limit: 10
+streams:
+	public.orders:
+		disabled: 'no'
+		target_options:
+			column_casing: camel
+			indexes:
+				- columns: customer_id
+`
+	err = ValidateReplication(strings.ReplaceAll(bad, "\t", "  "))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "defaults: unknown key 'source_option'")
+		assert.Contains(t, err.Error(), "defaults.mode: invalid value upsert")
+		assert.Contains(t, err.Error(), "streams.public.orders.disabled: expected boolean, got string")
+		assert.Contains(t, err.Error(), "streams.public.orders.target_options.column_casing: invalid value camel")
+		assert.Contains(t, err.Error(), "streams.public.orders.target_options.indexes[0].columns: expected array, got string")
+	}
+
+	// variables are replaced on load, numeric stream names are keys
+	vars := `
+source: POSTGRES
+target: '{TARGET}'
+defaults:
+	mode: '{MODE}'
+	source_options:
+		limit: ${LIMIT}
+streams:
+	2024:
+		disabled: '{DISABLED}'
+`
+	err = ValidateReplication(strings.ReplaceAll(vars, "\t", "  "))
+	assert.NoError(t, err)
+
+	// target with object naming rules
+	naming := `
+source: POSTGRES
+target:
+	conn: SNOWFLAKE
+	object_naming:
+		object: '{stream_schema}.stg_{stream_table}'
+		rename:
+			'^tbl_(.*)$': '$1'
+streams:
+	public.*:
+`
+	err = ValidateReplication(strings.ReplaceAll(naming, "\t", "  "))
+	assert.NoError(t, err)
+
+	err = ValidateReplication("target: SNOWFLAKE\nstreams:\n  public.orders:\n    mode: '{mode'")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "root: missing key 'source'")
+		assert.Contains(t, err.Error(), "streams.public.orders.mode: invalid value {mode")
+	}
+}
@@ -0,0 +1,298 @@
+package sling
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/connection"
+	"github.com/slingdata-io/sling-cli/core/dbio/database"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+)
+
+// DefaultStateTable is the default table to store the incremental state in
+const DefaultStateTable = "_sling_state"
+
+// StateStore persists the incremental state of streams in a database table,
+// to avoid querying the max value of the update key on huge target tables.
+// It is enabled with SLING_STATE_TABLE or SLING_STATE_CONN
+// (flags `--state-table` & `--state-conn`).
+type StateStore struct {
+	Conn  database.Connection
+	Table database.Table
+
+	ownConn bool // the connection was opened for the store (SLING_STATE_CONN)
+}
+
+// IncrementalState is the stored state of a stream
+type IncrementalState struct {
+	StreamKey     string         `json:"stream_key"`
+	Watermark     string         `json:"watermark"`
+	WatermarkType iop.ColumnType `json:"watermark_type"`
+	RunTime       time.Time      `json:"run_time"`
+	RowCount      uint64         `json:"row_count"`
+}
+
+// SetWatermark sets the watermark from a raw value
+func (is *IncrementalState) SetWatermark(val any) {
+	is.Watermark = watermarkString(val)
+}
+
+// SetWatermarks sets the watermark of a composite update key: the values are
+// stored as a JSON array, and their types as `tuple(type1,type2)`.
+// NULL values are rejected, since a row value with a NULL never compares as greater.
+func (is *IncrementalState) SetWatermarks(vals []any, cols iop.Columns) (err error) {
+	watermarks := make([]string, len(vals))
+	types := make([]string, len(vals))
+	for i, val := range vals {
+		if val == nil {
+			return g.Error("the max value of the composite update key has a NULL for %s", cols[i].Name)
+		}
+		watermarks[i] = watermarkString(val)
+		types[i] = string(cols[i].Type)
+	}
+	is.Watermark = g.Marshal(watermarks)
+	is.WatermarkType = iop.ColumnType("tuple(" + strings.Join(types, ",") + ")")
+	return nil
+}
+
+// IsComposite returns true if the watermark is a tuple, of a composite update key
+func (is *IncrementalState) IsComposite() bool {
+	return strings.HasPrefix(string(is.WatermarkType), "tuple(") && strings.HasSuffix(string(is.WatermarkType), ")")
+}
+
+// Format returns the watermark as a SQL literal for the connection type,
+// or a row value such as `(v1, v2)` for a composite update key
+func (is *IncrementalState) Format(connType dbio.Type) string {
+	values := is.Formats(connType)
+	if !is.IsComposite() {
+		return values[0]
+	}
+	return "(" + strings.Join(values, ", ") + ")"
+}
+
+// Formats returns the watermark values as SQL literals, one per update key
+func (is *IncrementalState) Formats(connType dbio.Type) (values []string) {
+	if !is.IsComposite() {
+		return []string{formatWatermark(is.Watermark, is.WatermarkType, connType)}
+	}
+
+	watermarks := []string{}
+	if err := g.Unmarshal(is.Watermark, &watermarks); err != nil {
+		g.Warn("could not parse composite watermark %s: %s", is.Watermark, err.Error())
+	}
+
+	types := strings.TrimSuffix(strings.TrimPrefix(string(is.WatermarkType), "tuple("), ")")
+	for i, colType := range strings.Split(types, ",") {
+		watermark := ""
+		if i < len(watermarks) {
+			watermark = watermarks[i]
+		}
+		values = append(values, formatWatermark(watermark, iop.ColumnType(colType), connType))
+	}
+	return values
+}
+
+// watermarkString returns the raw value as stored in the state table
+func watermarkString(val any) string {
+	if t, ok := val.(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	return cast.ToString(val)
+}
+
+// formatWatermark returns the stored value as a SQL literal for the connection type
+func formatWatermark(watermark string, colType iop.ColumnType, connType dbio.Type) string {
+	column := iop.Column{Name: "watermark", Type: colType}
+	if column.Type.IsDatetime() || column.Type.IsDate() {
+		return iop.FormatValue(cast.ToTime(watermark), column, connType)
+	}
+	return iop.FormatValue(watermark, column, connType)
+}
+
+var stateColumns = iop.Columns{
+	{Name: "stream_key", Type: iop.StringType, Position: 1},
+	{Name: "watermark", Type: iop.StringType, Position: 2},
+	{Name: "watermark_type", Type: iop.StringType, Position: 3},
+	{Name: "run_time", Type: iop.TimestampType, Position: 4},
+	{Name: "row_count", Type: iop.BigIntType, Position: 5},
+}
+
+// NewStateStore returns the state store of the config, or nil if not enabled
+func NewStateStore(cfg *Config, tgtConn database.Connection) (ss *StateStore, err error) {
+	connName := os.Getenv("SLING_STATE_CONN")
+	tableName := os.Getenv("SLING_STATE_TABLE")
+	if connName == "" && tableName == "" {
+		return nil, nil
+	} else if tableName == "" {
+		tableName = DefaultStateTable
+	}
+
+	conn := tgtConn
+	if connName != "" {
+		entry := connection.GetLocalConns().Get(connName)
+		if entry.Name == "" {
+			return nil, g.Error("could not find state connection: %s", connName)
+		}
+
+		conn, err = entry.Connection.AsDatabase(true)
+		if err != nil {
+			return nil, g.Error(err, "could not initialize state connection: %s", connName)
+		} else if err = conn.Connect(); err != nil {
+			return nil, g.Error(err, "could not connect to state connection: %s", connName)
+		}
+	}
+
+	// default to the target schema
+	if connName == "" {
+		tableName = stateTableInTargetSchema(cfg, conn.GetType(), tableName)
+	}
+
+	ss, err = NewStateStoreFromConn(conn, tableName)
+	if err != nil {
+		if connName != "" {
+			conn.Close()
+		}
+		return nil, err
+	}
+	ss.ownConn = connName != ""
+
+	return ss, nil
+}
+
+// Close closes the connection of the store, if opened for the store
+func (ss *StateStore) Close() {
+	if ss == nil || !ss.ownConn {
+		return
+	}
+	if err := ss.Conn.Close(); err != nil {
+		g.Debug("could not close state connection: %s", err.Error())
+	}
+}
+
+// stateTableInTargetSchema qualifies the table name with the target schema, if unqualified
+func stateTableInTargetSchema(cfg *Config, connType dbio.Type, tableName string) string {
+	if strings.Contains(tableName, ".") || cfg == nil {
+		return tableName
+	}
+	if tgtTable, err := database.ParseTableName(cfg.Target.Object, connType); err == nil && tgtTable.Schema != "" {
+		return tgtTable.Schema + "." + tableName
+	}
+	return tableName
+}
+
+// NewStateStoreFromConn returns a state store using the connection & table
+func NewStateStoreFromConn(conn database.Connection, tableName string) (ss *StateStore, err error) {
+	table, err := database.ParseTableName(tableName, conn.GetType())
+	if err != nil {
+		return nil, g.Error(err, "could not parse state table name: %s", tableName)
+	}
+	table.Columns = stateColumns
+
+	return &StateStore{Conn: conn, Table: table}, nil
+}
+
+// StateKey returns the key identifying the stream in the state table
+func (cfg *Config) StateKey() string {
+	stream := cfg.StreamName
+	if stream == "" {
+		stream = cfg.Source.Stream
+	}
+
+	key := strings.ToLower(g.F(
+		"%s.%s > %s.%s",
+		cfg.Source.Conn, strings.TrimSpace(stream),
+		cfg.Target.Conn, cfg.Target.Object,
+	))
+	if len(key) > 200 {
+		return g.MD5(key) // long custom SQL streams
+	}
+	return key
+}
+
+// Read returns the stored state of the stream, if any
+func (ss *StateStore) Read(key string) (state IncrementalState, ok bool, err error) {
+	exists, err := database.TableExists(ss.Conn, ss.Table.FullName())
+	if err != nil {
+		return state, false, g.Error(err, "could not check state table %s", ss.Table.FullName())
+	} else if !exists {
+		return state, false, nil
+	}
+
+	sql := g.F(
+		"select %s from %s where %s = %s",
+		strings.Join(ss.Conn.GetType().QuoteNames(stateColumns.Names()[1:]...), ", "),
+		ss.Table.FDQN(),
+		ss.Conn.Quote("stream_key"),
+		iop.FormatValue(key, stateColumns[0], ss.Conn.GetType()),
+	)
+
+	data, err := ss.Conn.Query(sql)
+	if err != nil {
+		return state, false, g.Error(err, "could not read state for %s", key)
+	} else if len(data.Rows) == 0 || cast.ToString(data.Rows[0][0]) == "" {
+		return state, false, nil
+	}
+
+	row := data.Rows[0]
+	state = IncrementalState{
+		StreamKey:     key,
+		Watermark:     cast.ToString(row[0]),
+		WatermarkType: iop.ColumnType(cast.ToString(row[1])),
+		RunTime:       cast.ToTime(row[2]),
+		RowCount:      cast.ToUint64(row[3]),
+	}
+
+	return state, true, nil
+}
+
+// Write stores the state of the stream, replacing the previous one
+// within a transaction
+func (ss *StateStore) Write(state IncrementalState) (err error) {
+	data := iop.NewDataset(stateColumns)
+	if _, err = createTableIfNotExists(ss.Conn, data, &ss.Table, false); err != nil {
+		return g.Error(err, "could not create state table %s", ss.Table.FullName())
+	}
+
+	connType := ss.Conn.GetType()
+	values := []string{
+		iop.FormatValue(state.StreamKey, stateColumns[0], connType),
+		iop.FormatValue(state.Watermark, stateColumns[1], connType),
+		iop.FormatValue(string(state.WatermarkType), stateColumns[2], connType),
+		iop.FormatValue(state.RunTime, stateColumns[3], connType),
+		iop.FormatValue(state.RowCount, stateColumns[4], connType),
+	}
+
+	sqls := []string{
+		g.F(
+			"delete from %s where %s = %s",
+			ss.Table.FDQN(), ss.Conn.Quote("stream_key"), values[0],
+		),
+		g.F(
+			"insert into %s (%s) values (%s)",
+			ss.Table.FDQN(),
+			strings.Join(connType.QuoteNames(stateColumns.Names()...), ", "),
+			strings.Join(values, ", "),
+		),
+	}
+
+	if err = ss.Conn.Begin(); err != nil {
+		return g.Error(err, "could not begin state transaction")
+	}
+
+	for _, sql := range sqls {
+		if _, err = ss.Conn.Exec(sql); err != nil {
+			ss.Conn.Rollback()
+			return g.Error(err, "could not write state for %s", state.StreamKey)
+		}
+	}
+
+	if err = ss.Conn.Commit(); err != nil {
+		return g.Error(err, "could not commit state for %s", state.StreamKey)
+	}
+
+	return nil
+}
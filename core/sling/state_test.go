@@ -0,0 +1,193 @@
+package sling
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/database"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateStore(t *testing.T) {
+	conn, err := database.NewConn("sqlite://" + filepath.Join(t.TempDir(), "state.db"))
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+		return
+	}
+	defer conn.Close()
+
+	store, err := NewStateStoreFromConn(conn, "main._sling_state")
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	// missing table
+	_, ok, err := store.Read("key1")
+	g.AssertNoError(t, err)
+	assert.False(t, ok)
+
+	// write & read
+	state := IncrementalState{StreamKey: "key1", WatermarkType: iop.BigIntType, RunTime: time.Now(), RowCount: 10}
+	state.SetWatermark(100)
+	g.AssertNoError(t, store.Write(state))
+
+	stored, ok, err := store.Read("key1")
+	if g.AssertNoError(t, err) && assert.True(t, ok) {
+		assert.Equal(t, "100", stored.Watermark)
+		assert.Equal(t, iop.BigIntType, stored.WatermarkType)
+		assert.EqualValues(t, 10, stored.RowCount)
+	}
+
+	// update replaces the row
+	state.SetWatermark(200)
+	state.RowCount = 5
+	g.AssertNoError(t, store.Write(state))
+	stored, _, _ = store.Read("key1")
+	assert.Equal(t, "200", stored.Watermark)
+	assert.EqualValues(t, 5, stored.RowCount)
+
+	data, err := conn.Query("select count(*) from main._sling_state")
+	if g.AssertNoError(t, err) {
+		assert.EqualValues(t, 1, data.Rows[0][0])
+	}
+
+	// timestamp watermark formatting
+	tsState := IncrementalState{WatermarkType: iop.TimestampType}
+	tsState.SetWatermark(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	assert.Contains(t, tsState.Format(dbio.TypeDbPostgres), "2024-01-02 03:04:05")
+
+	// fallback to max query, then read from state
+	_, err = conn.ExecMulti(`create table main.orders (id integer, updated_at integer);
+		insert into main.orders values (1, 10), (2, 20), (3, 30);`)
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	os.Setenv("SLING_STATE_TABLE", "main._sling_state")
+	defer os.Unsetenv("SLING_STATE_TABLE")
+
+	cfg := &Config{
+		Source:     Source{Conn: "SRC", Stream: "orders", UpdateKey: "updated_at"},
+		Target:     Target{Conn: "TGT", Object: "main.orders", Options: &TargetOptions{}},
+		StreamName: "orders",
+	}
+
+	g.AssertNoError(t, getIncrementalValue(cfg, conn, dbio.TypeDbSQLite))
+	assert.Contains(t, cfg.IncrementalVal, "30") // no state yet
+
+	g.AssertNoError(t, updateIncrementalState(cfg, conn, 3))
+	stored, ok, _ = store.Read(cfg.StateKey())
+	if assert.True(t, ok) {
+		assert.Equal(t, "30", stored.Watermark)
+		assert.EqualValues(t, 3, stored.RowCount)
+	}
+
+	// new rows, the state is used instead of the max query
+	_, err = conn.Exec("insert into main.orders values (4, 40)")
+	g.AssertNoError(t, err)
+	cfg.IncrementalVal = ""
+	g.AssertNoError(t, getIncrementalValue(cfg, conn, dbio.TypeDbSQLite))
+	assert.Contains(t, cfg.IncrementalVal, "30")
+
+	// update scans since the previous watermark
+	g.AssertNoError(t, updateIncrementalState(cfg, conn, 1))
+	stored, _, _ = store.Read(cfg.StateKey())
+	assert.Equal(t, "40", stored.Watermark)
+
+	// a full-refresh rescans the whole table, resetting the watermark
+	_, err = conn.Exec("delete from main.orders where id = 4")
+	g.AssertNoError(t, err)
+	cfg.Mode = FullRefreshMode
+	g.AssertNoError(t, updateIncrementalState(cfg, conn, 3))
+	stored, _, _ = store.Read(cfg.StateKey())
+	assert.Equal(t, "30", stored.Watermark)
+	cfg.Mode = IncrementalMode
+
+	// the state is ignored if the target table was dropped
+	_, err = conn.Exec("drop table main.orders")
+	g.AssertNoError(t, err)
+	cfg.IncrementalVal = ""
+	g.AssertNoError(t, getIncrementalValue(cfg, conn, dbio.TypeDbSQLite))
+	assert.Empty(t, cfg.IncrementalVal)
+
+	// the target connection is not closed with the store
+	store.Close()
+	_, err = conn.Query("select 1")
+	g.AssertNoError(t, err)
+}
+
+func TestStateStoreComposite(t *testing.T) {
+	conn, err := database.NewConn("sqlite://" + filepath.Join(t.TempDir(), "state.db"))
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, conn.Connect()) {
+		return
+	}
+	defer conn.Close()
+
+	// tuple watermark round trip
+	state := IncrementalState{StreamKey: "key1", RunTime: time.Now()}
+	cols := iop.Columns{{Name: "event_date", Type: iop.DateType}, {Name: "event_id", Type: iop.BigIntType}}
+	g.AssertNoError(t, state.SetWatermarks([]any{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), 100}, cols))
+	assert.True(t, state.IsComposite())
+	assert.Equal(t, iop.ColumnType("tuple(date,bigint)"), state.WatermarkType)
+
+	// NULL values are rejected, a single key type is never composite
+	assert.Error(t, (&IncrementalState{}).SetWatermarks([]any{time.Now(), nil}, cols))
+	assert.False(t, (&IncrementalState{Watermark: "a,b", WatermarkType: iop.StringType}).IsComposite())
+
+	store, err := NewStateStoreFromConn(conn, "main._sling_state")
+	if !g.AssertNoError(t, err) || !g.AssertNoError(t, store.Write(state)) {
+		return
+	}
+
+	stored, ok, err := store.Read("key1")
+	if g.AssertNoError(t, err) && assert.True(t, ok) {
+		assert.Equal(t, state.Watermark, stored.Watermark)
+		formats := stored.Formats(dbio.TypeDbPostgres)
+		if assert.Len(t, formats, 2) {
+			assert.Contains(t, formats[0], "2024-01-02")
+			assert.Equal(t, "100", formats[1])
+		}
+		assert.Equal(t, "("+formats[0]+", 100)", stored.Format(dbio.TypeDbPostgres))
+	}
+
+	// lexicographic max of the composite update key
+	_, err = conn.ExecMulti(`create table main.events (event_day integer, event_id integer);
+		insert into main.events values (1, 50), (2, 10), (2, 30), (1, 90);`)
+	if !g.AssertNoError(t, err) {
+		return
+	}
+
+	os.Setenv("SLING_STATE_TABLE", "main._sling_state")
+	defer os.Unsetenv("SLING_STATE_TABLE")
+
+	cfg := &Config{
+		Source:     Source{Conn: "SRC", Stream: "events", UpdateKey: "event_day,event_id"},
+		Target:     Target{Conn: "TGT", Object: "main.events", Options: &TargetOptions{}},
+		StreamName: "events",
+	}
+
+	g.AssertNoError(t, getIncrementalValue(cfg, conn, dbio.TypeDbSQLite))
+	assert.Equal(t, "(2, 30)", cfg.IncrementalVal)
+
+	g.AssertNoError(t, updateIncrementalState(cfg, conn, 4))
+	stored, ok, _ = store.Read(cfg.StateKey())
+	if assert.True(t, ok) && assert.True(t, stored.IsComposite()) {
+		assert.Equal(t, []string{"2", "30"}, stored.Formats(dbio.TypeDbSQLite))
+	}
+
+	// the tuple watermark selects the next rows
+	_, err = conn.Exec("insert into main.events values (2, 40), (3, 1)")
+	g.AssertNoError(t, err)
+	data, err := conn.Query(g.F(
+		"select event_day, event_id from main.events where %s order by event_day, event_id",
+		incrementalWhere(dbio.TypeDbSQLite, cfg.Source.UpdateKeys(), stored.Format(dbio.TypeDbSQLite)),
+	))
+	if g.AssertNoError(t, err) && assert.Len(t, data.Rows, 2) {
+		assert.EqualValues(t, 40, data.Rows[0][1])
+		assert.EqualValues(t, 3, data.Rows[1][0])
+	}
+}
@@ -1,18 +1,23 @@
 package sling
 
 import (
+	"context"
 	"math"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/flarco/g"
 	"github.com/segmentio/ksuid"
 	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/database"
 	"github.com/slingdata-io/sling-cli/core/dbio/iop"
 	"github.com/slingdata-io/sling-cli/core/env"
 	"github.com/spf13/cast"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Set in the store/store.go file for history keeping
@@ -36,9 +41,17 @@ type TaskExecution struct {
 	data          *iop.Dataset  `json:"-"`
 	prevRowCount  uint64
 	prevByteCount uint64
-	skipStream    bool            `json:"skip_stream"`
-	lastIncrement time.Time       // the time of last row increment (to determine stalling)
-	Output        strings.Builder `json:"-"`
+	skipStream    bool                   `json:"skip_stream"`
+	interrupted   atomic.Bool            // whether interrupted with commit-on-interrupt
+	lastIncrement time.Time              // the time of last row increment (to determine stalling)
+	cdcLSN        string                 // the LSN / version to read the changes after (cdc_slot, change_tracking)
+	cdcLastLSN    string                 // the LSN / version of the last change read (cdc_slot, change_tracking)
+	fanOut        *fanOut                // the running writes of the fan-out targets
+	timeoutErr    error                  // the exceeded extract or load timeout
+	comments      database.TableComments // the comments of the source table (add_comments)
+	span          trace.Span             // the OpenTelemetry span of the stream
+	spanCtx       context.Context        // the context of the stream span, parent of the stage spans
+	Output        strings.Builder        `json:"-"`
 	OutputLines   chan *g.LogLine
 
 	Replication    *ReplicationConfig `json:"replication"`
@@ -46,6 +59,9 @@ type TaskExecution struct {
 	PBar           *ProgressBar       `json:"-"`
 	ProcStatsStart g.ProcStats        `json:"-"` // process stats at beginning
 	cleanupFuncs   []func()
+
+	// guards the status, progress, start time and dataflow, read by Snapshot
+	snapshotMux sync.Mutex
 }
 
 // ExecutionStatus is an execution status object
@@ -143,8 +159,10 @@ func NewTask(execID string, cfg *Config) (t *TaskExecution) {
 // SetProgress sets the progress
 func (t *TaskExecution) SetProgress(progressText string, args ...interface{}) {
 	progressText = g.F(progressText, args...)
+	t.snapshotMux.Lock()
 	t.ProgressHist = append(t.ProgressHist, progressText)
 	t.Progress = progressText
+	t.snapshotMux.Unlock()
 	if !t.PBar.started || t.PBar.finished {
 		if strings.HasSuffix(progressText, "failed") {
 			progressText = env.RedString(progressText)
@@ -155,6 +173,21 @@ func (t *TaskExecution) SetProgress(progressText string, args ...interface{}) {
 	}
 }
 
+// setStatus sets the status of the task
+func (t *TaskExecution) setStatus(status ExecStatus) {
+	t.snapshotMux.Lock()
+	t.Status = status
+	t.snapshotMux.Unlock()
+}
+
+// setDataflow sets the dataflow of the task, passing through the error
+func (t *TaskExecution) setDataflow(df *iop.Dataflow, err error) error {
+	t.snapshotMux.Lock()
+	t.df = df
+	t.snapshotMux.Unlock()
+	return err
+}
+
 // GetTotalBytes gets the inbound/oubound bytes of the task
 func (t *TaskExecution) GetTotalBytes() (rcBytes, txBytes uint64) {
 	procStatsEnd := g.GetProcStats(os.Getpid())
@@ -228,6 +261,15 @@ func (t *TaskExecution) GetBytesString() (s string) {
 	// return g.F("%s -> %s", humanize.Bytes(inBytes), humanize.Bytes(outBytes))
 }
 
+// GetKeptString returns the number of target rows kept as newer than the
+// source rows (version_column), for the run summary
+func (t *TaskExecution) GetKeptString() (s string) {
+	if t.Config == nil || t.Config.Target.KeptCount == 0 {
+		return ""
+	}
+	return g.F("[kept %d newer target rows]", t.Config.Target.KeptCount)
+}
+
 // GetCount return the current count of rows processed
 func (t *TaskExecution) GetCount() (count uint64) {
 	if t.StartTime == nil {
@@ -306,6 +348,22 @@ func (t *TaskExecution) setGetMetadata() (metadata iop.Metadata) {
 		metadata.RowNum.Key = slingRowNumColumn
 	}
 
+	if keyColumns := t.Config.surrogateKeyColumns(); len(keyColumns) > 0 {
+		metadata.SurrogateKey.Key = t.Config.Target.Options.SurrogateKey.Name()
+		metadata.SurrogateKey.Value = iop.RowHashOptions{
+			Algorithm: iop.HashAlgorithmMD5,
+			Columns:   keyColumns,
+		}
+	}
+
+	if g.PtrVal(t.Config.Target.Options.AddHash) {
+		metadata.RowHash.Key = slingRowHashColumn
+		metadata.RowHash.Value = iop.RowHashOptions{
+			Algorithm: g.PtrVal(t.Config.Target.Options.HashAlgorithm),
+			Columns:   t.Config.Target.Options.HashColumns,
+		}
+	}
+
 	// StarRocks: add _sling_row_id column if there is no primary,
 	// duplicate or hash key defined and set as Hash Key
 	if t.Config.TgtConn.Type == dbio.TypeDbStarRocks {
@@ -387,6 +445,31 @@ func (t *TaskExecution) Cleanup() {
 	}
 }
 
+// Interrupt handles an interrupt signal when SLING_COMMIT_ON_INTERRUPT is enabled.
+// It stops reading from the source, so that the rows already extracted are
+// committed into the target. Only applies when appending into a database table.
+// Returns false if the task should be cancelled instead.
+func (t *TaskExecution) Interrupt() bool {
+	if !cast.ToBool(os.Getenv("SLING_COMMIT_ON_INTERRUPT")) {
+		return false
+	}
+
+	t.snapshotMux.Lock()
+	df := t.df
+	t.snapshotMux.Unlock()
+
+	if !g.In(t.Type, FileToDB, DbToDb) || df == nil {
+		return false
+	} else if t.Config.IfExists() != IfExistsAppend {
+		g.Warn("cannot commit on interrupt with mode '%s', only when appending (such as incremental or snapshot).", t.Config.Mode)
+		return false
+	}
+
+	t.interrupted.Store(true)
+	df.StopReading()
+	return true
+}
+
 // shouldWriteViaDuckDB determines whether we should use duckdb
 // at the moment, use duckdb only for partitioned target parquet files
 func (t *TaskExecution) shouldWriteViaDuckDB(uri string) bool {
@@ -405,7 +488,13 @@ func (t *TaskExecution) getOptionsMap() (options map[string]any) {
 	options = g.M()
 	g.Unmarshal(g.Marshal(t.Config.Source.Options), &options)
 
-	if columns := t.Config.ColumnsPrepared(); len(columns) > 0 {
+	columns := t.Config.ColumnsPrepared()
+	for _, col := range t.Config.TargetColumnsPrepared() {
+		if columns.GetColumn(col.Name) == nil {
+			columns = iop.NewColumns(append(columns, col)...)
+		}
+	}
+	if len(columns) > 0 {
 		// set as string so that StreamProcessor parses it
 		options["columns"] = g.Marshal(columns)
 	}
@@ -414,6 +503,46 @@ func (t *TaskExecution) getOptionsMap() (options map[string]any) {
 		// set as string so that StreamProcessor parses it
 		options["transforms"] = g.Marshal(colTransforms)
 	}
+
+	if t.Config.Source.Options != nil && t.Config.Source.Options.Layout != nil {
+		// set as string so that the fixed-width reader parses it
+		options["layout"] = g.Marshal(t.Config.Source.Options.Layout)
+	}
+
+	if t.Config.Source.Options != nil && t.Config.Source.Options.BoolValues != nil {
+		// set as string so that StreamProcessor parses it
+		options["bool_values"] = g.Marshal(t.Config.Source.Options.BoolValues)
+	}
+
+	if t.Config.Source.Options != nil && len(t.Config.Source.Options.Extract) > 0 {
+		// set as string so that StreamProcessor parses it
+		options["extract"] = g.Marshal(t.Config.Source.Options.Extract)
+	}
+
+	if pipeline := t.Config.pipeline(); len(pipeline) > 0 {
+		// set as string so that StreamProcessor parses it
+		options["pipeline"] = g.Marshal(pipeline)
+	}
+
+	if normalize := t.Config.normalize(); normalize != nil {
+		// set as string so that StreamProcessor parses it
+		options["normalize"] = g.Marshal(normalize)
+	}
+
+	if normalizeKeys := t.Config.normalizeKeys(); normalizeKeys != "" {
+		options["normalize_keys"] = normalizeKeys
+	}
+
+	if t.Config.Target.Options != nil && t.Config.Target.Options.DecimalHeadroom != nil {
+		options["decimal_headroom"] = *t.Config.Target.Options.DecimalHeadroom
+	}
+	if t.Config.Target.Options != nil && t.Config.Target.Options.DecimalMaxPrecision != nil {
+		options["decimal_max_precision"] = *t.Config.Target.Options.DecimalMaxPrecision
+	}
+
+	if transformPlugin := os.Getenv("SLING_TRANSFORM_PLUGIN"); transformPlugin != "" {
+		options["transform_plugin"] = transformPlugin
+	}
 	return
 }
 
@@ -424,6 +553,11 @@ func applyColumnCasingToDf(df *iop.Dataflow, connType dbio.Type, casing *iop.Col
 		return
 	}
 
+	// warn on names which collide after casing
+	for newName, names := range casing.Collisions(df.Columns.Names(), connType) {
+		env.Warn(env.WarningSchema, "column casing '%s' results in duplicate column name %s (from %s)", *casing, newName, strings.Join(names, ", "))
+	}
+
 	// convert to target system casing
 	for i, col := range df.Columns {
 		df.Columns[i].Name = casing.Apply(col.Name, connType)
@@ -479,7 +613,7 @@ func ErrorHelper(err error) (helpString string) {
 		case contains("[AppendRow]: converting"):
 			helpString = "Perhaps using the `adjust_column_type: true` target option could help? See https://docs.slingdata.io/sling-cli/run/configuration#target"
 		case contains("mkdir", "permission denied"):
-			helpString = "Perhaps setting the SLING_TEMP_DIR environment variable to a writable folder will help."
+			helpString = "Perhaps setting the SLING_TMP_DIR environment variable (or the --tmp-dir flag) to a writable folder will help."
 		case contains("canceling statement due to conflict with recovery"):
 			helpString = "Perhaps adjusting the `max_standby_archive_delay` and `max_standby_streaming_delay` settings in the source PG Database could help. See https://stackoverflow.com/questions/14592436/postgresql-error-canceling-statement-due-to-conflict-with-recovery"
 		case contains("wrong number of fields"):
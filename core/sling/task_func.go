@@ -4,6 +4,7 @@ import (
 	"math"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/flarco/g"
 	"github.com/samber/lo"
 	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/connection"
 	"github.com/slingdata-io/sling-cli/core/dbio/database"
 	"github.com/slingdata-io/sling-cli/core/dbio/iop"
 	"github.com/slingdata-io/sling-cli/core/env"
@@ -41,6 +43,11 @@ func createSchemaIfNotExists(conn database.Connection, schemaName string) (creat
 	}
 
 	if !lo.Contains(schemas, schemaName) {
+		if conn.GetType() == dbio.TypeDbCassandra {
+			// a keyspace needs a replication strategy, for the user to choose
+			return false, g.Error("keyspace %s does not exist, please create it first", schemaName)
+		}
+
 		_, err = conn.Exec(g.F("create schema %s", conn.Quote(schemaName)))
 		if err != nil {
 			return false, g.Error(err, "Error creating schema %s", conn.Quote(schemaName))
@@ -171,6 +178,7 @@ func insertFromTemp(cfg *Config, tgtConn database.Connection) (err error) {
 		err = g.Error(err, "unable to parse tmp table name")
 		return
 	}
+	srcTable.Quoting, tgtTable.Quoting = cfg.quoteIdentifiers(), cfg.quoteIdentifiers()
 
 	sql := g.R(
 		tgtConn.Template().Core["insert_from_table"],
@@ -194,6 +202,99 @@ func getIncrementalValue(cfg *Config, tgtConn database.Connection, srcConnType d
 		return
 	}
 
+	// read from the state table, if enabled
+	if store, err := NewStateStore(cfg, tgtConn); err != nil {
+		g.Warn("could not initialize state store, falling back to max value query: %s", err.Error())
+	} else if store != nil {
+		defer store.Close()
+		state, ok, err := store.Read(cfg.StateKey())
+		if err != nil {
+			g.Warn("could not read incremental state, falling back to max value query: %s", err.Error())
+		} else if ok && !targetTableExists(cfg, tgtConn) {
+			g.Debug("ignoring incremental state from %s, the target table does not exist", store.Table.FullName())
+		} else if ok {
+			g.Debug("using incremental state from %s (last run at %s)", store.Table.FullName(), state.RunTime.Format(time.RFC3339))
+			cfg.IncrementalVal = state.Format(srcConnType)
+			return nil
+		} else {
+			g.Debug("no incremental state found in %s, falling back to max value query", store.Table.FullName())
+		}
+	}
+
+	maxVals, maxCols, err := getMaxUpdateKeyValues(cfg, tgtConn, "")
+	if err != nil || len(maxCols) == 0 {
+		return err
+	}
+
+	cfg.IncrementalVal, err = formatUpdateKeyValues(maxVals, maxCols, srcConnType)
+
+	return
+}
+
+// quoteUpdateKey returns the quoted update key, or the quoted columns
+// joined with commas for a composite update key
+func quoteUpdateKey(dialect dbio.Type, updateKeys []string) string {
+	quoted := lo.Map(updateKeys, func(k string, i int) string { return dialect.Quote(k, false) })
+	return strings.Join(quoted, ", ")
+}
+
+// incrementalWhere returns the condition selecting the records modified after the
+// watermark value. A composite update key is compared as a row value, such as
+// `("event_date", "event_id") > ('2024-01-01', 100)`.
+func incrementalWhere(dialect dbio.Type, updateKeys []string, value string) string {
+	if len(updateKeys) > 1 {
+		return g.R(
+			dialect.GetTemplateValue("core.incremental_where_tuple"),
+			"update_keys", quoteUpdateKey(dialect, updateKeys),
+			"value", value,
+			"gt", ">",
+		)
+	}
+	return g.R(
+		dialect.GetTemplateValue("core.incremental_where"),
+		"update_key", quoteUpdateKey(dialect, updateKeys),
+		"value", value,
+		"gt", ">",
+	)
+}
+
+// formatUpdateKeyValues returns the max values of the update key as a SQL literal,
+// or as a row value such as `(v1, v2)` for a composite update key.
+// Blank is returned if there is no max value (empty target table). A NULL in a
+// composite max value errors, since the row value would never compare as greater.
+func formatUpdateKeyValues(vals []any, cols iop.Columns, connType dbio.Type) (string, error) {
+	if len(vals) == 0 || vals[0] == nil {
+		return "", nil
+	} else if len(vals) == 1 {
+		return iop.FormatValue(vals[0], cols[0], connType), nil
+	}
+
+	values := make([]string, len(vals))
+	for i, val := range vals {
+		if val == nil {
+			return "", g.Error("the max value of the composite update key has a NULL for %s, which cannot be compared. Fill the NULL values of the key in the target table.", cols[i].Name)
+		}
+		values[i] = iop.FormatValue(val, cols[i], connType)
+	}
+	return "(" + strings.Join(values, ", ") + ")", nil
+}
+
+// targetTableExists returns true if the target table exists, or if it cannot be checked
+func targetTableExists(cfg *Config, tgtConn database.Connection) bool {
+	table, err := database.ParseTableName(cfg.Target.Object, tgtConn.GetType())
+	if err != nil {
+		return true
+	}
+	exists, err := database.TableExists(tgtConn, table.FullName())
+	return err != nil || exists
+}
+
+// getMaxUpdateKeyValues returns the max value of the update key in the target table.
+// For a composite update key, the lexicographic max is returned: the max of each
+// key among the rows matching the max values of the previous keys.
+// If sinceVal is provided (a SQL literal), only the values of the first key >= sinceVal are scanned.
+// No columns are returned if the target table does not exist or is empty.
+func getMaxUpdateKeyValues(cfg *Config, tgtConn database.Connection, sinceVal string) (maxVals []any, maxCols iop.Columns, err error) {
 	// get table columns type for table creation if not exists
 	// in order to get max value
 	// does table exists?
@@ -204,58 +305,136 @@ func getIncrementalValue(cfg *Config, tgtConn database.Connection, srcConnType d
 		return
 	}
 
-	tgtUpdateKey := cfg.Source.UpdateKey
-	if cc := cfg.Target.Options.ColumnCasing; cc != nil {
-		tgtUpdateKey = cc.Apply(tgtUpdateKey, tgtConn.GetType())
-	}
-
 	// get target columns to match update-key
 	// in case column casing needs adjustment
 	targetCols, _ := pullTargetTableColumns(cfg, tgtConn, false)
-	if updateCol := targetCols.GetColumn(tgtUpdateKey); updateCol != nil && updateCol.Name != "" {
-		tgtUpdateKey = updateCol.Name // overwrite with correct casing
-	} else if len(targetCols) == 0 {
+	if len(targetCols) == 0 {
 		return // target table does not exist
 	}
 
-	sql := g.F(
-		"select max(%s) as max_val from %s",
-		tgtConn.Quote(tgtUpdateKey, false),
-		table.FDQN(),
-	)
+	conds := []string{}
+	for i, updateKey := range cfg.Source.UpdateKeys() {
+		tgtUpdateKey := updateKey
+		if cc := cfg.Target.Options.ColumnCasing; cc != nil {
+			tgtUpdateKey = cc.Apply(tgtUpdateKey, tgtConn.GetType())
+		}
+		if updateCol := targetCols.GetColumn(tgtUpdateKey); updateCol != nil && updateCol.Name != "" {
+			tgtUpdateKey = updateCol.Name // overwrite with correct casing
+		}
+		quotedKey := tgtConn.Quote(tgtUpdateKey, false)
 
-	data, err := tgtConn.Query(sql)
-	if err != nil {
-		errMsg := strings.ToLower(err.Error())
-		if strings.Contains(errMsg, "exist") ||
-			strings.Contains(errMsg, "not found") ||
-			strings.Contains(errMsg, "unknown") ||
-			strings.Contains(errMsg, "no such table") ||
-			strings.Contains(errMsg, "invalid object") {
-			// table does not exists, will be create later
+		if i == 0 && sinceVal != "" {
+			conds = append(conds, g.F("%s >= %s", quotedKey, sinceVal))
+		}
+
+		sql := g.F("select max(%s) as max_val from %s", quotedKey, table.FDQN())
+		if len(conds) > 0 {
+			sql = sql + " where " + strings.Join(conds, " and ")
+		}
+
+		data, err := tgtConn.Query(sql)
+		if err != nil {
+			errMsg := strings.ToLower(err.Error())
+			if strings.Contains(errMsg, "exist") ||
+				strings.Contains(errMsg, "not found") ||
+				strings.Contains(errMsg, "unknown") ||
+				strings.Contains(errMsg, "no such table") ||
+				strings.Contains(errMsg, "invalid object") {
+				// table does not exists, will be create later
+				// set val to blank for full load
+				return nil, nil, nil
+			}
+			return nil, nil, g.Error(err, "could not get max value for "+tgtUpdateKey)
+		}
+		if len(data.Rows) == 0 || len(data.Rows[0]) == 0 {
+			// table is empty
 			// set val to blank for full load
-			return nil
+			return nil, nil, nil
+		}
+
+		// set null for empty value (e.g. if target table exists but is empty)
+		maxVal := lo.Ternary(cast.ToString(data.Rows[0][0]) == "", nil, data.Rows[0][0])
+
+		// oracle's DATE type is mapped to datetime, but needs to use the TO_DATE function
+		if data.Columns[0].DbType == "DATE" && tgtConn.GetType() == dbio.TypeDbOracle {
+			data.Columns[0].Type = iop.DateType // force date type
+		}
+
+		maxVals = append(maxVals, maxVal)
+		maxCols = append(maxCols, data.Columns[0])
+
+		// the next key is the max among the rows with the max value
+		if maxVal == nil {
+			conds = append(conds, g.F("%s is null", quotedKey))
+		} else {
+			conds = append(conds, g.F("%s = %s", quotedKey, iop.FormatValue(maxVal, data.Columns[0], tgtConn.GetType())))
 		}
-		err = g.Error(err, "could not get max value for "+tgtUpdateKey)
-		return
 	}
-	if len(data.Rows) == 0 || len(data.Rows[0]) == 0 {
-		// table is empty
-		// set val to blank for full load
+
+	return maxVals, maxCols, nil
+}
+
+// updateIncrementalState stores the new watermark after a successful incremental load
+// A full-refresh with an update key resets the watermark, so the next incremental
+// run does not start from a stale one.
+func (t *TaskExecution) updateIncrementalState(tgtConn database.Connection, rowCount uint64) (err error) {
+	if !t.Config.Source.HasUpdateKey() || !g.In(t.Config.Mode, IncrementalMode, FullRefreshMode) {
+		return nil
+	} else if t.df == nil || t.df.Err() != nil {
 		return nil
 	}
+	return updateIncrementalState(t.Config, tgtConn, rowCount)
+}
 
-	// set null for empty value (e.g. if target table exists but is empty)
-	incrementalVal := lo.Ternary(cast.ToString(data.Rows[0][0]) == "", nil, data.Rows[0][0])
+// updateIncrementalState stores the new watermark in the state table, if enabled.
+// The max value is only scanned over the range since the previous watermark.
+func updateIncrementalState(cfg *Config, tgtConn database.Connection, rowCount uint64) (err error) {
+	store, err := NewStateStore(cfg, tgtConn)
+	if err != nil || store == nil {
+		return err
+	}
+	defer store.Close()
 
-	// oracle's DATE type is mapped to datetime, but needs to use the TO_DATE function
-	if data.Columns[0].DbType == "DATE" && tgtConn.GetType() == dbio.TypeDbOracle {
-		data.Columns[0].Type = iop.DateType // force date type
+	// refresh the target columns, the table may have just been created
+	if _, err = pullTargetTableColumns(cfg, tgtConn, true); err != nil {
+		return g.Error(err, "could not get target columns for incremental state")
 	}
 
-	cfg.IncrementalVal = iop.FormatValue(incrementalVal, data.Columns[0], srcConnType)
+	key := cfg.StateKey()
+	sinceVal := ""
+	prev, ok, _ := store.Read(key)
+	if cfg.Mode == FullRefreshMode {
+		ok = false // rescan the whole table
+	} else if ok && prev.Watermark != "" {
+		sinceVal = prev.Formats(tgtConn.GetType())[0] // the first key of a composite update key
+	}
 
-	return
+	maxVals, maxCols, err := getMaxUpdateKeyValues(cfg, tgtConn, sinceVal)
+	if err != nil {
+		return g.Error(err, "could not get new watermark")
+	}
+
+	state := IncrementalState{StreamKey: key, RunTime: time.Now(), RowCount: rowCount}
+	if len(maxCols) > 1 && maxVals[0] != nil {
+		if err = state.SetWatermarks(maxVals, maxCols); err != nil {
+			return g.Error(err, "could not set new watermark")
+		}
+	} else if len(maxCols) == 1 && maxVals[0] != nil {
+		state.WatermarkType = maxCols[0].Type
+		state.SetWatermark(maxVals[0])
+	} else if ok {
+		// no new rows, keep previous watermark
+		state.Watermark, state.WatermarkType = prev.Watermark, prev.WatermarkType
+	} else if cfg.Mode != FullRefreshMode {
+		return nil // empty target
+	} // else an empty target after a full-refresh, clear the watermark
+
+	if err = store.Write(state); err != nil {
+		return g.Error(err, "could not update incremental state")
+	}
+	g.Debug("updated incremental state in %s (watermark: %s)", store.Table.FullName(), state.Watermark)
+
+	return nil
 }
 
 func getRate(cnt uint64) string {
@@ -279,3 +458,530 @@ func GetSQLText(sqlStringPath string) (string, error) {
 func setStage(value string) {
 	env.SetTelVal("stage", value)
 }
+
+// setColumnsFrom pulls the columns of the reference table specified with
+// target_options.columns_from, and sets them as the target columns so that
+// the stream is casted to match. Columns specified explicitly take precedence.
+func (t *TaskExecution) setColumnsFrom() (err error) {
+	cf := t.Config.Target.Options.ColumnsFrom
+	if cf == nil || cf.Table == "" {
+		return nil
+	}
+
+	refConn := t.Config.TgtConn
+	if cf.Conn != "" && !strings.EqualFold(cf.Conn, t.Config.Target.Conn) {
+		connsMap := lo.KeyBy(connection.GetLocalConns(), func(c connection.ConnEntry) string {
+			return strings.ToLower(c.Connection.Name)
+		})
+		c, ok := connsMap[strings.ToLower(cf.Conn)]
+		if !ok {
+			return g.Error("could not find connection %s (columns_from)", cf.Conn)
+		}
+		refConn = c.Connection
+	}
+
+	conn, err := refConn.AsDatabase(false)
+	if err != nil {
+		return g.Error(err, "could not initialize connection %s (columns_from)", refConn.Name)
+	} else if err = conn.Connect(); err != nil {
+		return g.Error(err, "could not connect to %s (columns_from)", refConn.Name)
+	}
+	defer conn.Close()
+
+	refColumns, err := conn.GetColumns(cf.Table)
+	if err != nil {
+		return g.Error(err, "could not get columns for reference table %s", cf.Table)
+	} else if len(refColumns) == 0 {
+		return g.Error("reference table %s has no columns or does not exist", cf.Table)
+	}
+
+	// explicitly specified columns take precedence
+	specified := t.Config.ColumnsPrepared()
+	specifiedMap := specified.FieldMap(true)
+	columns := make(iop.Columns, len(refColumns))
+	for i, col := range refColumns {
+		if j, ok := specifiedMap[strings.ToLower(col.Name)]; ok {
+			col.Type = specified[j].Type
+		}
+		columns[i] = iop.Column{Name: col.Name, Type: col.Type, Position: i + 1}
+	}
+
+	g.Debug("using columns from reference table %s: %s", cf.Table, g.Marshal(columns.Types()))
+	t.Config.Target.Columns = columns
+	t.Config.Target.columnsFrom = columns
+
+	return nil
+}
+
+// checkColumnsFrom ensures all stream columns exist in the reference
+// table specified with target_options.columns_from
+func checkColumnsFrom(cfg *Config, streamColumns iop.Columns) (err error) {
+	if len(cfg.Target.columnsFrom) == 0 {
+		return nil
+	}
+
+	refMap := cfg.Target.columnsFrom.FieldMap(true)
+	extra := []string{}
+	for _, col := range streamColumns {
+		if _, ok := col.Metadata["sling_metadata"]; ok {
+			continue // sling metadata columns
+		} else if _, ok := refMap[strings.ToLower(col.Name)]; !ok {
+			extra = append(extra, col.Name)
+		}
+	}
+
+	if len(extra) > 0 {
+		return g.Error(
+			"stream has columns not present in reference table %s: %s. Use `select` to exclude them.",
+			cfg.Target.Options.ColumnsFrom.Table, strings.Join(extra, ", "),
+		)
+	}
+
+	return nil
+}
+
+// s3StagingProps returns the target connection props for S3 staging
+// (such as Redshift COPY). `s3_staging` can be an S3 URL, or the name
+// of an S3 connection whose bucket & credentials should be used.
+func (t *TaskExecution) s3StagingProps() (props []string, err error) {
+	staging := t.Config.Target.Options.S3Staging
+	if staging == nil || *staging == "" || strings.HasPrefix(*staging, "s3://") {
+		return nil, nil
+	}
+
+	c := connection.GetLocalConns().Get(*staging)
+	if c.Name == "" {
+		return nil, g.Error("could not find connection %s (s3_staging)", *staging)
+	} else if c.Connection.Type != dbio.TypeFileS3 {
+		return nil, g.Error("connection %s is not an S3 connection (s3_staging)", *staging)
+	}
+
+	data := c.Connection.DataS(true)
+	if data["bucket"] == "" {
+		return nil, g.Error("connection %s has no bucket (s3_staging)", *staging)
+	}
+
+	for key, val := range data {
+		if !strings.HasPrefix(key, "aws_") {
+			key = "aws_" + key
+		}
+		props = append(props, g.F("%s=%s", key, val))
+	}
+	props = append(props, "s3_staging=") // use aws_bucket instead
+
+	return props, nil
+}
+
+// validateColumnMap checks the column_map renames: target names must be
+// unique and cannot be renamed again. Key columns cannot be renamed,
+// since they are also used against the source.
+func (cfg *Config) validateColumnMap() error {
+	columnMap := cfg.Target.Options.ColumnMap
+	if len(columnMap) == 0 {
+		return nil
+	}
+
+	keys := append(cfg.Source.PrimaryKey(), cfg.Source.UpdateKeys()...)
+	newNames := map[string]string{}
+	for oldName, newName := range columnMap {
+		switch {
+		case strings.TrimSpace(newName) == "":
+			return g.Error("column_map: blank target name for column %s", oldName)
+		case newNames[strings.ToLower(newName)] != "":
+			return g.Error("column_map: columns %s and %s are both renamed to %s", newNames[strings.ToLower(newName)], oldName, newName)
+		case lo.ContainsBy(keys, func(k string) bool { return strings.EqualFold(k, oldName) }):
+			return g.Error("column_map: cannot rename key column %s (primary-key / update-key)", oldName)
+		}
+		newNames[strings.ToLower(newName)] = oldName
+	}
+
+	for oldName := range columnMap {
+		if other, ok := newNames[strings.ToLower(oldName)]; ok && !strings.EqualFold(other, oldName) {
+			return g.Error("column_map: %s is both renamed and the target name of %s", oldName, other)
+		}
+	}
+
+	return nil
+}
+
+// validateMergeColumns checks merge_update_columns / merge_exclude_columns,
+// which restrict the columns updated by a merge. The names are checked
+// against the stream columns when merging.
+func (cfg *Config) validateMergeColumns() error {
+	updateCols := cfg.Target.Options.MergeUpdateColumns
+	excludeCols := cfg.Target.Options.MergeExcludeColumns
+	if len(updateCols) == 0 && len(excludeCols) == 0 {
+		return nil
+	}
+
+	switch {
+	case len(updateCols) > 0 && len(excludeCols) > 0:
+		return g.Error("cannot specify both merge_update_columns and merge_exclude_columns")
+	case !cfg.TgtConn.Type.IsDb():
+		return g.Error("merge_update_columns / merge_exclude_columns are only supported for database targets")
+	case !g.In(cfg.Mode, IncrementalMode, BackfillMode) || len(cfg.Source.PrimaryKey()) == 0:
+		return g.Error("merge_update_columns / merge_exclude_columns require a merge (mode 'incremental' or 'backfill' with a primary-key)")
+	case cfg.appendOnly():
+		return g.Error("merge_update_columns / merge_exclude_columns are not compatible with append_only (no merge)")
+	case g.In(cfg.TgtConn.Type, dbio.TypeDbRedshift, dbio.TypeDbBigQuery, dbio.TypeDbClickhouse, dbio.TypeDbProton, dbio.TypeDbStarRocks):
+		// these merge by deleting & re-inserting the rows
+		return g.Error("merge_update_columns / merge_exclude_columns are not supported for %s targets", cfg.TgtConn.Type)
+	}
+
+	for _, name := range append(updateCols, excludeCols...) {
+		if strings.TrimSpace(name) == "" || strings.Contains(name, ",") {
+			return g.Error("invalid column name in merge_update_columns / merge_exclude_columns: %#v", name)
+		}
+	}
+
+	return nil
+}
+
+// validateVersionColumn checks version_column, which requires a merge. The
+// type of the column is checked against the target table when merging.
+func (cfg *Config) validateVersionColumn() error {
+	versionCol := strings.TrimSpace(g.PtrVal(cfg.Target.Options.VersionColumn))
+	if versionCol == "" {
+		return nil
+	}
+
+	switch {
+	case !cfg.TgtConn.Type.IsDb():
+		return g.Error("version_column is only supported for database targets")
+	case cfg.TgtConn.Type == dbio.TypeDbClickhouse:
+		// deletes are asynchronous mutations, without correlated subqueries
+		return g.Error("version_column is not supported for clickhouse targets")
+	case !g.In(cfg.Mode, IncrementalMode, BackfillMode) || len(cfg.Source.PrimaryKey()) == 0:
+		return g.Error("version_column requires a merge (mode 'incremental' or 'backfill' with a primary-key)")
+	case cfg.appendOnly():
+		return g.Error("version_column is not compatible with append_only (no merge)")
+	case g.PtrVal(cfg.Target.Options.LoadProcedure) != "":
+		return g.Error("version_column is not compatible with load_procedure")
+	case strings.Contains(versionCol, ","):
+		return g.Error("invalid version_column %#v, should be a single column", versionCol)
+	case lo.Contains(lo.Map(cfg.Source.PrimaryKey(), func(k string, i int) string { return strings.ToLower(k) }), strings.ToLower(versionCol)):
+		return g.Error("version_column %s cannot be part of the primary-key", versionCol)
+	}
+
+	return nil
+}
+
+// validateSurrogateKey checks surrogate_key. A hash needs the natural key columns (or a
+// primary-key), an identity column needs a dialect able to add it to the target table.
+func (cfg *Config) validateSurrogateKey() error {
+	sk := cfg.Target.Options.SurrogateKey
+	if sk == nil {
+		return nil
+	} else if !cfg.TgtConn.Type.IsDb() {
+		return g.Error("surrogate_key is only supported for database targets")
+	}
+
+	name := strings.ToLower(sk.Name())
+	inPK := lo.Contains(lo.Map(cfg.Source.PrimaryKey(), func(k string, i int) string { return strings.ToLower(k) }), name)
+
+	switch sk.Strategy {
+	case SurrogateKeyHash:
+		if len(sk.Columns) == 0 && (!cfg.Source.HasPrimaryKey() || inPK) {
+			return g.Error("surrogate_key strategy hash needs the natural key columns (`columns`), or a primary-key")
+		} else if lo.Contains(lo.Map(sk.Columns, func(c string, i int) string { return strings.ToLower(c) }), name) {
+			return g.Error("surrogate_key %s cannot hash itself", sk.Name())
+		}
+	case SurrogateKeyIdentity:
+		switch {
+		case cfg.TgtConn.Type.GetTemplateValue("core.add_identity_column") == "":
+			return g.Error("surrogate_key strategy identity is not supported for %s", cfg.TgtConn.Type)
+		case len(sk.Columns) > 0:
+			return g.Error("surrogate_key strategy identity does not hash columns, the values are generated by the target")
+		case inPK:
+			return g.Error("surrogate_key %s cannot be the primary-key with strategy identity (generated on insert), use strategy hash", sk.Name())
+		case cfg.noCreate():
+			return g.Error("surrogate_key strategy identity is not compatible with no_create (the column is added to the target table)")
+		case cfg.atomicSwap():
+			return g.Error("surrogate_key strategy identity is not compatible with write_mode %s", WriteModeAtomicSwap)
+		case cfg.Mode == FullRefreshMode || cfg.Mode == TruncateMode:
+			return g.Error("surrogate_key strategy identity would generate new keys for all the rows on every %s run, use strategy hash for stable keys", cfg.Mode)
+		}
+	default:
+		return g.Error("invalid surrogate_key strategy %#v. Valid values are: hash, identity", sk.Strategy)
+	}
+
+	return nil
+}
+
+// validateCompositeUpdateKey checks that a composite update key is read from a database
+// supporting row-value comparison (`core.incremental_where_tuple`), into a database target
+// holding the tuple watermark
+func (cfg *Config) validateCompositeUpdateKey() error {
+	if !cfg.Source.HasCompositeUpdateKey() {
+		return nil
+	}
+
+	keys := cfg.Source.UpdateKeys()
+	switch {
+	case !cfg.SrcConn.Type.IsDb():
+		return g.Error("composite update_key (%s) is only supported for database sources", strings.Join(keys, ", "))
+	case cfg.SrcConn.Type.GetTemplateValue("core.incremental_where_tuple") == "":
+		return g.Error("composite update_key (%s) is not supported for %s, which cannot compare row values", strings.Join(keys, ", "), cfg.SrcConn.Type)
+	case !cfg.TgtConn.Type.IsDb():
+		return g.Error("composite update_key (%s) is only supported for database targets", strings.Join(keys, ", "))
+	case cfg.Mode == BackfillMode || cfg.Source.hasWindow():
+		return g.Error("composite update_key (%s) is not compatible with backfill mode or since / until", strings.Join(keys, ", "))
+	case len(lo.Uniq(lo.Map(keys, func(k string, i int) string { return strings.ToLower(k) }))) < len(keys):
+		return g.Error("composite update_key (%s) has duplicate columns", strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+// validateManifest checks that the manifest is read with a file source, listing the objects to read
+func (cfg *Config) validateManifest() error {
+	if g.PtrVal(cfg.Source.Options.Manifest) == "" {
+		return nil
+	} else if !cfg.SrcConn.Type.IsFile() {
+		return g.Error("manifest is only supported for file sources")
+	} else if cfg.Source.Query != "" || g.In(g.PtrVal(cfg.Source.Options.Format), dbio.FileTypeIceberg, dbio.FileTypeDelta) {
+		return g.Error("manifest is not compatible with iceberg / delta formats or a source query")
+	}
+	return nil
+}
+
+// validateLineageComment checks the lineage_comment policy and that the target can comment tables
+func (cfg *Config) validateLineageComment() error {
+	policy := cfg.Target.Options.LineageComment
+	if policy == nil {
+		return nil
+	} else if !g.In(*policy, LineageCommentCreate, LineageCommentUpdate) {
+		return g.Error("invalid lineage_comment %#v. Valid values are: create, update", *policy)
+	} else if !cfg.TgtConn.Type.IsDb() || !database.CommentsSupported(cfg.TgtConn.Type) {
+		return g.Error("lineage_comment is not supported for %s", cfg.TgtConn.Type)
+	}
+	return nil
+}
+
+// surrogateKeyColumns returns the natural key columns hashed by the surrogate key
+func (cfg *Config) surrogateKeyColumns() []string {
+	sk := cfg.Target.Options.SurrogateKey
+	if sk == nil || sk.Strategy != SurrogateKeyHash {
+		return nil
+	} else if len(sk.Columns) > 0 {
+		return sk.Columns
+	}
+	return cfg.Source.PrimaryKey()
+}
+
+// validateNoCreate checks no_create, which forbids any DDL on the target:
+// rows are inserted directly into the existing table, without a temp table
+func (cfg *Config) validateNoCreate() error {
+	if !cfg.noCreate() {
+		return nil
+	} else if !cfg.TgtConn.Type.IsDb() {
+		if cfg.Target.Options.NoCreate != nil {
+			return g.Error("no_create is only supported for database targets")
+		}
+		return nil
+	}
+
+	switch {
+	case cfg.IfExists() == IfExistsReplace:
+		return g.Error("no_create cannot replace (drop) the target table. Use mode 'truncate', or if_exists 'truncate' or 'append'")
+	case g.In(cfg.Mode, IncrementalMode, BackfillMode) && len(cfg.Source.PrimaryKey()) > 0:
+		return g.Error("no_create is not compatible with mode '%s' with a primary-key (a merge requires a temp table)", cfg.Mode)
+	case g.PtrVal(cfg.Target.Options.LoadProcedure) != "":
+		return g.Error("no_create is not compatible with load_procedure (requires a temp table)")
+	case g.PtrVal(cfg.Target.Options.TableDDL) != "":
+		return g.Error("no_create is not compatible with table_ddl")
+	case len(cfg.Target.Options.Indexes) > 0:
+		return g.Error("no_create is not compatible with indexes")
+	case g.PtrVal(cfg.Target.Options.AddComments) || len(cfg.Target.Options.ColumnsDescription) > 0:
+		return g.Error("no_create is not compatible with add_comments / columns_description")
+	case cfg.renameTargetColumns() && len(cfg.Target.Options.ColumnMap) > 0:
+		return g.Error("no_create is not compatible with rename_target_columns")
+	}
+
+	return nil
+}
+
+// validateWriteMode checks the target option `write_mode`. The atomic swap replaces
+// the whole table, with a dialect able to rename tables atomically.
+func (cfg *Config) validateWriteMode() error {
+	writeMode := g.PtrVal(cfg.Target.Options.WriteMode)
+	if writeMode == "" {
+		return nil
+	} else if writeMode != WriteModeAtomicSwap {
+		return g.Error("invalid value for write_mode: %s. Valid values are: %s", writeMode, WriteModeAtomicSwap)
+	}
+
+	switch {
+	case !cfg.TgtConn.Type.IsDb():
+		return g.Error("write_mode %s is only supported for database targets", writeMode)
+	case !database.SwapSupported(cfg.TgtConn.Type):
+		return g.Error("write_mode %s is not supported for %s, which cannot rename tables atomically", writeMode, cfg.TgtConn.Type)
+	case cfg.Mode != FullRefreshMode || cfg.IfExists() != IfExistsReplace:
+		return g.Error("write_mode %s replaces the target table, and requires mode 'full-refresh' (if_exists 'replace')", writeMode)
+	case cfg.noCreate():
+		return g.Error("write_mode %s is not compatible with no_create", writeMode)
+	case g.PtrVal(cfg.Target.Options.DirectInsert):
+		return g.Error("write_mode %s is not compatible with direct_insert (requires a temp table)", writeMode)
+	case g.PtrVal(cfg.Target.Options.LoadProcedure) != "":
+		return g.Error("write_mode %s is not compatible with load_procedure", writeMode)
+	}
+
+	return nil
+}
+
+// checkNoCreateTarget ensures the target table exists and has all the stream
+// columns, since no_create does not allow to create or alter it
+func checkNoCreateTarget(cfg *Config, tgtConn database.Connection, targetTable database.Table, streamColumns iop.Columns) (err error) {
+	if exists, err := database.TableExists(tgtConn, targetTable.FullName()); err != nil {
+		return g.Error(err, "could not check if table exists: %s", targetTable.FullName())
+	} else if !exists {
+		return g.Error("target table %s does not exist, and no_create does not allow to create it. Please create it first.", targetTable.FullName())
+	}
+
+	tgtCols, err := pullTargetTableColumns(cfg, tgtConn, true)
+	if err != nil {
+		return err
+	}
+
+	missing := lo.Map(tgtCols.GetMissing(streamColumns...), func(col iop.Column, i int) string {
+		return col.Name
+	})
+	if len(missing) > 0 {
+		return g.Error(
+			"target table %s does not have stream columns %s, and no_create does not allow to add them. Use `select` to exclude them.",
+			targetTable.FullName(), strings.Join(missing, ", "),
+		)
+	}
+
+	return nil
+}
+
+// mergeUpsertOptions returns the upsert options of the columns of
+// merge_update_columns / merge_exclude_columns, renamed like the stream
+// columns (with column_map & column_casing)
+func (cfg *Config) mergeUpsertOptions(tgtType dbio.Type) database.UpsertOptions {
+	rename := func(names []string) []string {
+		if len(names) == 0 {
+			return nil
+		}
+		newNames := make([]string, len(names))
+		for i, name := range names {
+			newNames[i], _ = lookupColumnMap(cfg.Target.Options.ColumnMap, strings.TrimSpace(name))
+			if casing := cfg.Target.Options.ColumnCasing; casing != nil {
+				newNames[i] = casing.Apply(newNames[i], tgtType)
+			}
+		}
+		return newNames
+	}
+
+	return database.UpsertOptions{
+		UpdateColumns:  rename(cfg.Target.Options.MergeUpdateColumns),
+		ExcludeColumns: rename(cfg.Target.Options.MergeExcludeColumns),
+	}
+}
+
+// versionColumn returns the target name of version_column, renamed like the
+// stream columns (with column_map & column_casing)
+func (cfg *Config) versionColumn(tgtType dbio.Type) string {
+	versionCol := strings.TrimSpace(g.PtrVal(cfg.Target.Options.VersionColumn))
+	if versionCol == "" {
+		return ""
+	}
+
+	versionCol, _ = lookupColumnMap(cfg.Target.Options.ColumnMap, versionCol)
+	if casing := cfg.Target.Options.ColumnCasing; casing != nil {
+		versionCol = casing.Apply(versionCol, tgtType)
+	}
+	return versionCol
+}
+
+// lookupColumnMap returns the target name of a column with column_map
+func lookupColumnMap(columnMap map[string]string, name string) (string, bool) {
+	if newName, ok := columnMap[name]; ok {
+		return newName, true
+	}
+	for oldName, newName := range columnMap {
+		if strings.EqualFold(oldName, name) {
+			return newName, true
+		}
+	}
+	return name, false
+}
+
+// applyColumnMapToDf renames the dataflow columns with column_map
+func applyColumnMapToDf(df *iop.Dataflow, columnMap map[string]string) {
+	if len(columnMap) == 0 {
+		return
+	}
+
+	rename := func(cols iop.Columns) {
+		for i, col := range cols {
+			if newName, ok := lookupColumnMap(columnMap, col.Name); ok {
+				cols[i].Name = newName
+			}
+		}
+	}
+
+	rename(df.Columns)
+	for _, ds := range df.Streams {
+		rename(ds.Columns)
+		if ds.CurrentBatch != nil {
+			rename(ds.CurrentBatch.Columns)
+		}
+	}
+}
+
+// checkColumnMapTarget ensures an existing target table does not still
+// have the old names of the columns renamed with column_map, which would
+// otherwise be loaded into new columns next to the old ones. With
+// rename_target_columns, the target columns are renamed.
+func checkColumnMapTarget(cfg *Config, tgtConn database.Connection, targetTable database.Table) (err error) {
+	columnMap := cfg.Target.Options.ColumnMap
+	if len(columnMap) == 0 || cfg.IfExists() == IfExistsReplace {
+		return nil
+	}
+
+	if exists, err := database.TableExists(tgtConn, targetTable.FullName()); err != nil {
+		return g.Error(err, "could not check if table exists: %s", targetTable.FullName())
+	} else if !exists {
+		return nil
+	}
+
+	tgtCols, err := tgtConn.GetColumns(targetTable.FullName())
+	if err != nil {
+		return g.Error(err, "could not get column list for %s", targetTable.FullName())
+	}
+
+	oldNames := lo.Keys(columnMap)
+	sort.Strings(oldNames)
+
+	renamed := false
+	for _, oldName := range oldNames {
+		newName := cfg.Target.Options.ColumnCasing.Apply(columnMap[oldName], tgtConn.GetType())
+		oldCol := tgtCols.GetColumn(oldName)
+		if oldCol == nil || tgtCols.GetColumn(newName) != nil {
+			continue
+		}
+
+		if !cfg.renameTargetColumns() {
+			return g.Error(
+				"target table %s has column %s, which column_map renames to %s. Rename the target column, or use the `rename_target_columns` target option (flag `--rename-target-columns`)",
+				targetTable.FullName(), oldCol.Name, newName,
+			)
+		}
+
+		sql, err := database.RenameColumnSQL(tgtConn.GetType(), targetTable, oldCol.Name, newName)
+		if err != nil {
+			return g.Error(err, "could not rename column %s in %s", oldCol.Name, targetTable.FullName())
+		} else if _, err = tgtConn.Exec(sql); err != nil {
+			return g.Error(err, "could not rename column %s in %s", oldCol.Name, targetTable.FullName())
+		}
+		g.Info("renamed column %s to %s in %s (column_map)", oldCol.Name, newName, targetTable.FullName())
+		renamed = true
+	}
+
+	if renamed {
+		_, err = pullTargetTableColumns(cfg, tgtConn, true)
+	}
+
+	return err
+}
@@ -31,6 +31,8 @@ var slingStreamURLColumn = "_sling_stream_url"
 var slingRowNumColumn = "_sling_row_num"
 var slingRowIDColumn = "_sling_row_id"
 var slingExecIDColumn = "_sling_exec_id"
+var slingRowHashColumn = "_sling_row_hash"
+var slingSurrogateKeyColumn = "_sling_key"
 
 func init() {
 	// we need a webserver to get the pprof webserver
@@ -48,7 +50,9 @@ func (t *TaskExecution) Execute() error {
 
 	done := make(chan struct{})
 	now := time.Now()
+	t.snapshotMux.Lock()
 	t.StartTime = &now
+	t.snapshotMux.Unlock()
 	t.lastIncrement = now
 
 	if t.Context == nil {
@@ -61,6 +65,9 @@ func (t *TaskExecution) Execute() error {
 	// set defaults
 	t.Config.SetDefault()
 
+	t.startSpan()
+	defer t.endSpan()
+
 	// print for debugging
 	g.Trace("using Config:\n%s", g.Pretty(t.Config))
 	env.SetTelVal("stage", "2 - task-execution")
@@ -94,7 +101,7 @@ func (t *TaskExecution) Execute() error {
 			}
 		}()
 
-		t.Status = ExecStatusRunning
+		t.setStatus(ExecStatusRunning)
 
 		if t.Err != nil {
 			return
@@ -114,10 +121,23 @@ func (t *TaskExecution) Execute() error {
 			return
 		} else if t.skipStream {
 			t.SetProgress("skipping stream")
-			t.Status = ExecStatusSkipped
+			t.setStatus(ExecStatusSkipped)
+			return
+		}
+
+		// dry-run only prepares the extraction query of database sources
+		if cast.ToBool(os.Getenv("SLING_DRY_RUN")) && !g.In(t.Type, DbToDb, DbToFile) {
+			t.SetProgress("dry-run: skipping stream")
 			return
 		}
 
+		// align target columns with reference table
+		if t.Type == FileToDB || t.Type == DbToDb {
+			if t.Err = t.setColumnsFrom(); t.Err != nil {
+				return
+			}
+		}
+
 		switch t.Type {
 		case DbSQL:
 			t.Err = t.runDbSQL()
@@ -134,6 +154,11 @@ func (t *TaskExecution) Execute() error {
 			t.Err = g.Error("Cannot Execute. Task Type is not specified")
 		}
 
+		// wait for the fan-out targets
+		if err := t.finishFanOut(); err != nil && t.Err == nil {
+			t.Err = err
+		}
+
 		// update into store
 		StoreUpdate(t)
 
@@ -141,8 +166,8 @@ func (t *TaskExecution) Execute() error {
 		if df := t.Df(); df != nil {
 			for _, col := range df.Columns {
 				if c := col.Constraint; c != nil && c.FailCnt > 0 {
-					g.Warn("column '%s' had %d constraint failures (%s) ", col.Name, c.FailCnt, c.Expression)
-					t.Status = ExecStatusWarning // set as warning status
+					env.Warn(env.WarningRejects, "column '%s' had %d constraint failures (%s) ", col.Name, c.FailCnt, c.Expression)
+					t.setStatus(ExecStatusWarning) // set as warning status
 				}
 			}
 		}
@@ -170,17 +195,30 @@ func (t *TaskExecution) Execute() error {
 		}
 	}
 
-	if t.Err == nil {
+	// the phase timeout is the cause of the cancellation
+	if err := t.TimeoutErr(); err != nil {
+		t.Err = err
+	}
+
+	if t.Err == nil && t.interrupted.Load() {
+		t.SetProgress("execution interrupted (committed %d rows)", t.GetCount())
+		t.setStatus(ExecStatusInterrupted)
+	} else if t.Err == nil {
+		if cnt := t.df.FilteredCount(); cnt > 0 {
+			t.SetProgress("filtered out %d rows (pipeline)", cnt)
+		}
 		if t.Status == ExecStatusWarning {
 			t.SetProgress("execution succeeded (with warnings)")
 		} else {
 			t.SetProgress("execution succeeded")
-			t.Status = ExecStatusSuccess
+			t.setStatus(ExecStatusSuccess)
 		}
 	} else {
 		t.SetProgress("execution failed")
-		t.Status = ExecStatusError
-		if err := t.df.Context.Err(); err != nil && err.Error() != t.Err.Error() {
+		t.setStatus(ExecStatusError)
+		if t.TimeoutErr() != nil {
+			t.Err = g.Error(t.Err)
+		} else if err := t.df.Context.Err(); err != nil && err.Error() != t.Err.Error() {
 			eG := g.ErrorGroup{}
 			eG.Add(err)
 			eG.Add(t.Err)
@@ -292,6 +330,17 @@ func (t *TaskExecution) getSrcDBConn(ctx context.Context) (conn database.Connect
 	return
 }
 
+// ackSourceMessages acknowledges the messages pulled from a queue source
+// (Pub/Sub), once written to the target. Messages not acked (on failure)
+// are released for redelivery when the source connection closes.
+func (t *TaskExecution) ackSourceMessages(srcConn database.Connection) {
+	if psConn, ok := srcConn.(*database.PubSubConn); ok {
+		if cnt := psConn.AckMessages(); cnt > 0 {
+			t.SetProgress("acknowledged %d messages", cnt)
+		}
+	}
+}
+
 func (t *TaskExecution) getTgtDBConn(ctx context.Context) (conn database.Connection, err error) {
 
 	options := g.M()
@@ -300,6 +349,12 @@ func (t *TaskExecution) getTgtDBConn(ctx context.Context) (conn database.Connect
 		g.MapToKVArr(t.Config.TgtConn.DataS()), g.MapToKVArr(g.ToMapString(options))...,
 	)
 
+	stagingProps, err := t.s3StagingProps()
+	if err != nil {
+		return nil, g.Error(err, "could not set S3 staging")
+	}
+	tgtProps = append(tgtProps, stagingProps...)
+
 	// Connection context should be different than task context
 	conn, err = database.NewConnContext(ctx, t.Config.TgtConn.URL(), tgtProps...)
 	if err != nil {
@@ -369,6 +424,70 @@ func (t *TaskExecution) runDbSQL() (err error) {
 	return
 }
 
+// runDbToView creates (or replaces) the target object as a view of the
+// source query, on the target connection. No data is moved.
+func (t *TaskExecution) runDbToView() (err error) {
+	tgtConn, err := t.getTgtDBConn(t.Context.Ctx)
+	if err != nil {
+		err = g.Error(err, "Could not initialize target connection")
+		return
+	}
+
+	t.SetProgress("connecting to target database (%s)", tgtConn.GetType())
+	err = tgtConn.Connect()
+	if err != nil {
+		err = g.Error(err, "Could not connect to: %s (%s)", t.Config.TgtConn.Info().Name, tgtConn.GetType())
+		return
+	}
+
+	if !t.isUsingPool() {
+		defer tgtConn.Close()
+	}
+
+	t.Config.Target.Object = setSchema(cast.ToString(t.Config.Target.Data["schema"]), t.Config.Target.Object)
+	view, err := database.ParseTableName(t.Config.Target.Object, tgtConn.GetType())
+	if err != nil {
+		return g.Error(err, "could not parse target view name: %s", t.Config.Target.Object)
+	}
+
+	// placeholders of incremental sql, not used in a view
+	sql := g.R(t.Config.Source.Stream, "incremental_where_cond", "1=1")
+	sql = g.R(sql, "incremental_value", "null")
+
+	ddl, err := view.CreateViewDDL(sql)
+	if err != nil {
+		return g.Error(err, "could not generate view ddl")
+	}
+
+	if cast.ToBool(os.Getenv("SLING_DRY_RUN")) {
+		g.Info("dry-run: %s", ddl)
+		t.SetProgress("dry-run: skipping view creation")
+		return nil
+	}
+
+	if err = executeSQL(t, tgtConn, t.Config.Target.Options.PreSQL, "pre"); err != nil {
+		return err
+	}
+
+	if !database.ViewReplaceable(tgtConn.GetType()) {
+		if err = tgtConn.DropView(view.FullName()); err != nil {
+			return g.Error(err, "could not drop view %s", view.FullName())
+		}
+	}
+
+	t.SetProgress("creating view %s", view.FullName())
+	if _, err = tgtConn.Exec(ddl); err != nil {
+		return g.Error(err, "could not create view %s", view.FullName())
+	}
+
+	if err = executeSQL(t, tgtConn, t.Config.Target.Options.PostSQL, "post"); err != nil {
+		return err
+	}
+
+	t.SetProgress("created view %s in %d secs", view.FullName(), int(time.Since(start).Seconds()))
+	return nil
+}
+
 func (t *TaskExecution) runDbToFile() (err error) {
 
 	start = time.Now()
@@ -392,10 +511,12 @@ func (t *TaskExecution) runDbToFile() (err error) {
 
 	t.SetProgress("reading from source database")
 	defer t.Cleanup()
-	t.df, err = t.ReadFromDB(t.Config, srcConn)
+	err = t.setDataflow(t.ReadFromDB(t.Config, srcConn))
 	if err != nil {
 		err = g.Error(err, "Could not ReadFromDB")
 		return
+	} else if t.skipStream {
+		return // dry-run
 	}
 	defer t.df.Close()
 
@@ -410,6 +531,8 @@ func (t *TaskExecution) runDbToFile() (err error) {
 		return
 	}
 
+	t.ackSourceMessages(srcConn)
+
 	t.SetProgress("wrote %d rows [%s r/s] to %s", cnt, getRate(cnt), t.getTargetObjectValue())
 
 	err = t.df.Err()
@@ -439,11 +562,14 @@ func (t *TaskExecution) runFileToDB() (err error) {
 	}
 
 	// check if table exists by getting target columns
-	// only pull if ignore_existing is specified (don't need columns yet otherwise)
-	if t.Config.IgnoreExisting() {
+	// only pull if ignore_existing or if_exists=fail is specified (don't need columns yet otherwise)
+	if t.Config.IgnoreExisting() || t.Config.IfExists() == IfExistsFail {
 		if cols, _ := pullTargetTableColumns(t.Config, tgtConn, false); len(cols) > 0 {
-			g.Debug("not writing since table exists at %s (ignore_existing=true)", t.Config.Target.Object)
-			return nil
+			if t.Config.IgnoreExisting() {
+				g.Debug("not writing since table exists at %s (ignore_existing=true)", t.Config.Target.Object)
+				return nil
+			}
+			return g.Error("table %s already exists (if_exists=fail)", t.Config.Target.Object)
 		}
 	}
 
@@ -465,11 +591,11 @@ func (t *TaskExecution) runFileToDB() (err error) {
 	} else {
 		t.SetProgress("reading from source file system (%s)", t.Config.SrcConn.Type)
 	}
-	t.df, err = t.ReadFromFile(t.Config)
+	err = t.setDataflow(t.ReadFromFile(t.Config))
 	if err != nil {
 		if strings.Contains(err.Error(), "Provided 0 files") {
 			if t.isIncrementalWithUpdateKey() && t.Config.HasIncrementalVal() {
-				t.SetProgress("no new files found since latest timestamp (%s)", time.Unix(cast.ToInt64(t.Config.IncrementalVal), 0))
+				t.SetProgress("no new files found since latest value (%s)", t.incrementalValString())
 			} else {
 				t.SetProgress("no files found")
 			}
@@ -479,6 +605,8 @@ func (t *TaskExecution) runFileToDB() (err error) {
 		}
 		err = g.Error(err, "could not read from file")
 		return
+	} else if t.skipStream {
+		return // validate-only
 	}
 	defer t.df.Close()
 
@@ -494,8 +622,12 @@ func (t *TaskExecution) runFileToDB() (err error) {
 		return
 	}
 
+	if err = t.updateIncrementalState(tgtConn, cnt); err != nil {
+		return
+	}
+
 	elapsed := int(time.Since(start).Seconds())
-	t.SetProgress("inserted %d rows into %s in %d secs [%s r/s]", cnt, t.getTargetObjectValue(), elapsed, getRate(cnt))
+	t.SetProgress("inserted %d rows into %s in %d secs [%s r/s] %s", cnt, t.getTargetObjectValue(), elapsed, getRate(cnt), t.GetKeptString())
 
 	if err != nil {
 		err = g.Error(t.df.Err(), "error in transfer")
@@ -503,6 +635,15 @@ func (t *TaskExecution) runFileToDB() (err error) {
 	return
 }
 
+// incrementalValString returns the incremental value for display, as a
+// timestamp for the file system timestamp or as is (e.g. a partition value)
+func (t *TaskExecution) incrementalValString() string {
+	if t.Config.Source.UpdateKey == slingLoadedAtColumn {
+		return time.Unix(cast.ToInt64(t.Config.IncrementalVal), 0).String()
+	}
+	return strings.Trim(t.Config.IncrementalVal, "'")
+}
+
 func (t *TaskExecution) runFileToFile() (err error) {
 
 	start = time.Now()
@@ -512,11 +653,11 @@ func (t *TaskExecution) runFileToFile() (err error) {
 	} else {
 		t.SetProgress("reading from source file system (%s)", t.Config.SrcConn.Type)
 	}
-	t.df, err = t.ReadFromFile(t.Config)
+	err = t.setDataflow(t.ReadFromFile(t.Config))
 	if err != nil {
 		if strings.Contains(err.Error(), "Provided 0 files") {
 			if t.isIncrementalWithUpdateKey() && t.Config.HasIncrementalVal() {
-				t.SetProgress("no new files found since latest timestamp (%s)", time.Unix(cast.ToInt64(t.Config.IncrementalVal), 0))
+				t.SetProgress("no new files found since latest value (%s)", t.incrementalValString())
 			} else {
 				t.SetProgress("no files found")
 			}
@@ -526,6 +667,8 @@ func (t *TaskExecution) runFileToFile() (err error) {
 		}
 		err = g.Error(err, "Could not ReadFromFile")
 		return
+	} else if t.skipStream {
+		return // validate-only
 	}
 	defer t.df.Close()
 
@@ -556,6 +699,10 @@ func (t *TaskExecution) runDbToDb() (err error) {
 		t.Config.Mode = FullRefreshMode
 	}
 
+	if t.Config.targetIsView() {
+		return t.runDbToView()
+	}
+
 	// Initiate connections
 	srcConn, err := t.getSrcDBConn(t.Context.Ctx)
 	if err != nil {
@@ -597,6 +744,8 @@ func (t *TaskExecution) runDbToDb() (err error) {
 		if t.Config.IgnoreExisting() {
 			g.Debug("not writing since table exists at %s (ignore_existing=true)", t.Config.Target.Object)
 			return nil
+		} else if t.Config.IfExists() == IfExistsFail {
+			return g.Error("table %s already exists (if_exists=fail)", t.Config.Target.Object)
 		}
 	}
 
@@ -610,11 +759,22 @@ func (t *TaskExecution) runDbToDb() (err error) {
 		t.Context.Map.Set("incremental_value", t.Config.IncrementalVal)
 	}
 
+	// get replication slot position / change tracking version
+	if t.Config.readsChanges() {
+		t.SetProgress("getting change position")
+		if err = t.getCDCPosition(tgtConn); err != nil {
+			err = g.Error(err, "Could not get replication slot position")
+			return err
+		}
+	}
+
 	t.SetProgress("reading from source database")
-	t.df, err = t.ReadFromDB(t.Config, srcConn)
+	err = t.setDataflow(t.ReadFromDB(t.Config, srcConn))
 	if err != nil {
 		err = g.Error(err, "Could not ReadFromDB")
 		return
+	} else if t.skipStream {
+		return // dry-run
 	}
 	defer t.df.Close()
 
@@ -635,12 +795,22 @@ func (t *TaskExecution) runDbToDb() (err error) {
 		return
 	}
 
+	if err = t.updateIncrementalState(tgtConn, cnt); err != nil {
+		return
+	}
+
+	if err = t.updateCDCPosition(tgtConn, srcConn, cnt); err != nil {
+		return
+	}
+
+	t.ackSourceMessages(srcConn)
+
 	bytesStr := ""
 	if val := t.GetBytesString(); val != "" {
 		bytesStr = "[" + val + "]"
 	}
 	elapsed := int(time.Since(start).Seconds())
-	t.SetProgress("inserted %d rows into %s in %d secs [%s r/s] %s", cnt, t.getTargetObjectValue(), elapsed, getRate(cnt), bytesStr)
+	t.SetProgress("inserted %d rows into %s in %d secs [%s r/s] %s %s", cnt, t.getTargetObjectValue(), elapsed, getRate(cnt), bytesStr, t.GetKeptString())
 
 	if t.df.Err() != nil {
 		err = g.Error(t.df.Err(), "Error running runDbToDb")
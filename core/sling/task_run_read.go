@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/flarco/g"
 	"github.com/samber/lo"
@@ -19,6 +20,15 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 
 	setStage("3 - prepare-dataflow")
 
+	stage := t.startStage("extract")
+	stopTimeout := t.startTimeout("extract", cfg.extractTimeout())
+	defer func() {
+		t.stopWhenRead(df, err, func() {
+			stopTimeout()
+			stage.EndRead(df, err)
+		})
+	}()
+
 	selectFieldsStr := "*"
 	sTable, err := database.ParseTableName(cfg.Source.Stream, srcConn.GetType())
 	if err != nil {
@@ -32,10 +42,33 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 	st := sTable
 	st.SQL = g.R(st.SQL, "incremental_where_cond", "1=1") // so we get the columns, and not change the orig SQL
 	st.SQL = g.R(st.SQL, "incremental_value", "null")     // so we get the columns, and not change the orig SQL
-	sTable.Columns, err = srcConn.GetSQLColumns(st)
-	if err != nil {
-		err = g.Error(err, "Could not get source columns")
-		return t.df, err
+
+	// use the cached columns if fresh and the source metadata is unchanged, to skip re-discovery
+	cache := NewColumnCache()
+	cacheKey := ColumnCacheKey(cfg.SrcConn.Hash(), cfg.Source.Stream)
+	metaChecksum := ""
+	if cache != nil {
+		metaChecksum = SourceMetaChecksum(srcConn, st)
+	}
+	cachedCols, cached := cache.Get(cacheKey, metaChecksum)
+	if cached {
+		g.Debug("using cached columns for %s", cfg.Source.Stream)
+		sTable.Columns = cachedCols
+	} else {
+		sTable.Columns, err = srcConn.GetSQLColumns(st)
+		if err != nil {
+			err = g.Error(err, "Could not get source columns")
+			return t.df, err
+		}
+		g.LogError(cache.Set(cacheKey, sTable.Columns, metaChecksum))
+	}
+
+	// get the comments of the source table, to apply on the target (add_comments)
+	if cfg.Target.Options != nil && g.PtrVal(cfg.Target.Options.AddComments) && !sTable.IsQuery() {
+		if t.comments, err = srcConn.GetComments(sTable.FullName()); err != nil {
+			g.Warn("could not get the comments of %s: %s", sTable.FullName(), err.Error())
+			err = nil
+		}
 	}
 
 	if len(cfg.Source.Select) > 0 {
@@ -72,7 +105,7 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 		selectFieldsStr = strings.Join(fields, ", ")
 	}
 
-	if t.isIncrementalWithUpdateKey() || t.Config.Mode == BackfillMode {
+	if t.isIncrementalWithUpdateKey() || t.Config.Mode == BackfillMode || cfg.Source.hasWindow() {
 		// default true value
 		incrementalWhereCond := "1=1"
 
@@ -81,16 +114,20 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 		updateCol := sTable.Columns.GetColumn(cfg.Source.UpdateKey)
 		if updateCol != nil && updateCol.Name != "" {
 			cfg.Source.UpdateKey = updateCol.Name // overwrite with correct casing
+		} else if cfg.Source.HasCompositeUpdateKey() {
+			updateKeys := cfg.Source.UpdateKeys()
+			for i, updateKey := range updateKeys {
+				if col := sTable.Columns.GetColumn(updateKey); col != nil && col.Name != "" {
+					updateKeys[i] = col.Name // overwrite with correct casing
+				}
+			}
+			cfg.Source.UpdateKey = strings.Join(updateKeys, ",")
 		}
+		quotedUpdateKey := quoteUpdateKey(srcConn.GetType(), cfg.Source.UpdateKeys())
 
 		// select only records that have been modified after last max value
 		if cfg.IncrementalVal != "" {
-			incrementalWhereCond = g.R(
-				srcConn.GetTemplateValue("core.incremental_where"),
-				"update_key", srcConn.Quote(cfg.Source.UpdateKey, false),
-				"value", cfg.IncrementalVal,
-				"gt", ">",
-			)
+			incrementalWhereCond = incrementalWhere(srcConn.GetType(), cfg.Source.UpdateKeys(), cfg.IncrementalVal)
 		} else {
 			// allows the use of coalesce in custom SQL using {incremental_value}
 			// this will be null when target table does not exists
@@ -117,6 +154,9 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 				timestampTemplate := srcConn.GetTemplateValue("variable.timestamp_layout_str")
 				startValue = g.R(timestampTemplate, "value", startValue)
 				endValue = g.R(timestampTemplate, "value", endValue)
+			} else if updateCol.IsString() && srcConn.GetType() == dbio.TypeDbFirestore {
+				startValue = iop.FormatValue(startValue, *updateCol, srcConn.GetType())
+				endValue = iop.FormatValue(endValue, *updateCol, srcConn.GetType())
 			} else if updateCol.IsString() {
 				startValue = `'` + startValue + `'`
 				endValue = `'` + endValue + `'`
@@ -130,6 +170,24 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 			)
 		}
 
+		// bounded window (--since / --until), independent of the watermark
+		if cfg.Source.hasWindow() {
+			if updateCol == nil {
+				return t.df, g.Error("did not find update key %s in source columns", cfg.Source.UpdateKey)
+			}
+
+			incrementalWhereCond, err = windowWhereCond(
+				srcConn.GetType(), *updateCol,
+				g.PtrVal(cfg.Source.Options.Since),
+				g.PtrVal(cfg.Source.Options.Until),
+				time.Now(),
+			)
+			if err != nil {
+				return t.df, g.Error(err, "could not build since / until window")
+			}
+			g.Debug("using window: %s", incrementalWhereCond)
+		}
+
 		if sTable.SQL == "" {
 			key := lo.Ternary(
 				cfg.Source.Limit() > 0,
@@ -145,7 +203,7 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 				"fields", selectFieldsStr,
 				"table", sTable.FDQN(),
 				"incremental_where_cond", incrementalWhereCond,
-				"update_key", srcConn.Quote(cfg.Source.UpdateKey, false),
+				"update_key", quotedUpdateKey,
 			)
 		} else {
 			if !(strings.Contains(sTable.SQL, "{incremental_where_cond}") || strings.Contains(sTable.SQL, "{incremental_value}")) {
@@ -156,7 +214,7 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 			sTable.SQL = g.R(
 				sTable.SQL,
 				"incremental_where_cond", incrementalWhereCond,
-				"update_key", srcConn.Quote(cfg.Source.UpdateKey, false),
+				"update_key", quotedUpdateKey,
 				"incremental_value", cfg.IncrementalVal,
 			)
 		}
@@ -166,6 +224,11 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 		srcConn.SetProp("start_time", t.Config.IncrementalVal)
 	}
 
+	// stream through a server-side cursor, if supported
+	if fetchSize := g.PtrVal(cfg.Source.Options.FetchSize); fetchSize > 0 {
+		srcConn.SetProp("fetch_size", cast.ToString(fetchSize))
+	}
+
 	sTable.SQL = g.R(sTable.SQL, "incremental_where_cond", "1=1") // if running non-incremental mode
 	sTable.SQL = g.R(sTable.SQL, "incremental_value", "null")     // if running non-incremental mode
 
@@ -174,6 +237,15 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 		sTable.SQL = sTable.Select(cfg.Source.Limit(), cfg.Source.Offset(), strings.Split(selectFieldsStr, ",")...)
 	}
 
+	// push down the JSON extracts into the query, else extracted in the stream
+	if extracts := cfg.Source.Options.Extract; len(extracts) > 0 && srcConn.GetTemplateValue("function.json_extract") != "" {
+		if sql, err := sTable.JSONExtractSQL(extracts); err != nil {
+			g.Debug("extracting JSON fields in the stream: %s", err.Error())
+		} else {
+			sTable.SQL = sql
+		}
+	}
+
 	// set constraints
 	for _, col := range cfg.ColumnsPrepared() {
 		if c := sTable.Columns.GetColumn(col.Name); c != nil {
@@ -181,29 +253,116 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 		}
 	}
 
+	if cast.ToBool(os.Getenv("SLING_EXPLAIN")) {
+		t.explainQuery(srcConn, sTable)
+	}
+
+	if cast.ToBool(os.Getenv("SLING_DRY_RUN")) {
+		t.SetProgress("dry-run: skipping extraction")
+		t.skipStream = true
+		return t.df, nil
+	}
+
+	// read the changes from the replication slot
+	if slot := g.PtrVal(cfg.Source.Options.CdcSlot); slot != "" {
+		if df, err = t.readFromCDC(srcConn, sTable, slot); err != nil {
+			return t.df, err
+		}
+		return df, t.setColumnKeys(df)
+	}
+
+	// read the changes from change tracking
+	if g.PtrVal(cfg.Source.Options.ChangeTracking) {
+		if df, err = t.readFromChangeTracking(srcConn, sTable); err != nil {
+			return t.df, err
+		}
+		return df, t.setColumnKeys(df)
+	}
+
+	// count the rows of the table, for the progress percent / ETA
+	if ShowProgress && t.PBar.NeedsTotal() && !st.IsQuery() && !srcConn.GetType().IsNoSQL() &&
+		!(t.isIncrementalWithUpdateKey() || t.Config.Mode == BackfillMode || cfg.Source.hasWindow()) {
+		t.setProgressTotal(srcConn, sTable, cfg.Source.Limit())
+	}
+
 	df, err = srcConn.BulkExportFlow(sTable)
 	if err != nil {
+		cache.Invalidate(cacheKey)
 		err = g.Error(err, "Could not BulkExportFlow")
 		return t.df, err
 	}
 
+	if df, err = t.startUnion(df); err != nil {
+		return t.df, err
+	}
+
 	err = t.setColumnKeys(df)
 	if err != nil {
 		err = g.Error(err, "Could not set column keys")
 		return t.df, err
 	}
 
+	if err = t.checkSchemaDrift(df); err != nil {
+		return t.df, err
+	}
+
+	if df, err = t.startFanOut(df); err != nil {
+		return t.df, err
+	}
+
 	g.Trace("%#v", df.Columns.Types())
 	setStage("3 - dataflow-stream")
 
 	return
 }
 
+// setProgressTotal sets the number of rows of the source table, to show the
+// percent / ETA. The catalog estimate is used when the dialect has one, an exact
+// count only if SLING_PROGRESS_COUNT is true (a full scan on large tables).
+// The progress is indeterminate otherwise, or if the lookup fails.
+func (t *TaskExecution) setProgressTotal(srcConn database.Connection, table database.Table, limit int) {
+	var total int64
+	if cast.ToBool(os.Getenv("SLING_PROGRESS_COUNT")) {
+		cnt, err := srcConn.GetCount(table.FDQN())
+		if err != nil {
+			g.Debug("could not count rows of %s for progress: %s", table.FDQN(), err.Error())
+			return
+		}
+		total = cast.ToInt64(cnt)
+	} else if _, ok := srcConn.Template().Metadata["row_count_estimates"]; ok {
+		data, err := srcConn.SubmitTemplate(
+			"single", srcConn.Template().Metadata, "row_count_estimates",
+			g.M("schema", table.Schema, "table", table.Name),
+		)
+		if err != nil || len(data.Rows) == 0 {
+			g.Debug("could not estimate rows of %s for progress: %v", table.FDQN(), err)
+			return
+		}
+		total = cast.ToInt64(data.Rows[0][len(data.Columns)-1])
+	}
+
+	if total <= 0 {
+		return // unknown (e.g. never analyzed)
+	} else if limit > 0 && int64(limit) < total {
+		total = int64(limit)
+	}
+	t.PBar.SetTotal(total)
+}
+
 // ReadFromFile reads from a source file
 func (t *TaskExecution) ReadFromFile(cfg *Config) (df *iop.Dataflow, err error) {
 
 	setStage("3 - prepare-dataflow")
 
+	stage := t.startStage("extract")
+	stopTimeout := t.startTimeout("extract", cfg.extractTimeout())
+	defer func() {
+		t.stopWhenRead(df, err, func() {
+			stopTimeout()
+			stage.EndRead(df, err)
+		})
+	}()
+
 	// sets metadata
 	metadata := t.setGetMetadata()
 
@@ -237,12 +396,17 @@ func (t *TaskExecution) ReadFromFile(cfg *Config) (df *iop.Dataflow, err error)
 		}
 
 		fsCfg := iop.FileStreamConfig{
-			Select:           cfg.Source.Select,
-			Limit:            cfg.Source.Limit(),
-			SQL:              cfg.Source.Query,
-			FileSelect:       cfg.Source.Options.FileSelect,
-			IncrementalKey:   cfg.Source.UpdateKey,
-			IncrementalValue: cfg.IncrementalVal,
+			Select:              cfg.Source.Select,
+			Limit:               cfg.Source.Limit(),
+			SQL:                 cfg.Source.Query,
+			FileSelect:          cfg.Source.Options.FileSelect,
+			PartitionFilter:     g.PtrVal(cfg.Source.Options.PartitionFilter),
+			Where:               g.PtrVal(cfg.Source.Options.Where),
+			UseS3Select:         g.PtrVal(cfg.Source.Options.UseS3Select),
+			Manifest:            g.PtrVal(cfg.Source.Options.Manifest),
+			ManifestSkipMissing: g.PtrVal(cfg.Source.Options.ManifestSkipMissing),
+			IncrementalKey:      cfg.Source.UpdateKey,
+			IncrementalValue:    cfg.IncrementalVal,
 		}
 
 		// set incrementalValue if incremental or backfill
@@ -278,12 +442,24 @@ func (t *TaskExecution) ReadFromFile(cfg *Config) (df *iop.Dataflow, err error)
 		return df, g.Error("Could not read columns")
 	}
 
+	if df, err = t.startUnion(df); err != nil {
+		return t.df, err
+	}
+
 	err = t.setColumnKeys(df)
 	if err != nil {
 		err = g.Error(err, "Could not set column keys")
 		return t.df, err
 	}
 
+	if err = t.checkSchemaDrift(df); err != nil {
+		return t.df, err
+	}
+
+	if df, err = t.startFanOut(df); err != nil {
+		return t.df, err
+	}
+
 	g.Trace("%#v", df.Columns.Types())
 	setStage("3 - dataflow-stream")
 
@@ -303,7 +479,7 @@ func (t *TaskExecution) setColumnKeys(df *iop.Dataflow) (err error) {
 	}
 
 	if t.Config.Source.HasUpdateKey() {
-		eG.Capture(df.Columns.SetMetadata(iop.UpdateKey.MetadataKey(), "source", t.Config.Source.UpdateKey))
+		eG.Capture(df.Columns.SetMetadata(iop.UpdateKey.MetadataKey(), "source", t.Config.Source.UpdateKeys()...))
 	}
 
 	if tkMap := t.Config.Target.Options.TableKeys; tkMap != nil {
@@ -314,3 +490,31 @@ func (t *TaskExecution) setColumnKeys(df *iop.Dataflow) (err error) {
 
 	return eG.Err()
 }
+
+// explainQuery prints the query plan of the extraction query.
+// Dialects without an EXPLAIN statement are skipped with a warning.
+func (t *TaskExecution) explainQuery(srcConn database.Connection, sTable database.Table) {
+	query := sTable.SQL
+	if query == "" {
+		query = sTable.Select(0, 0)
+	}
+
+	explainSQL, err := database.ExplainSQL(srcConn.GetType(), query)
+	if err != nil {
+		g.Warn("cannot explain query: %s", err.Error())
+		return
+	}
+
+	data, err := srcConn.Query(explainSQL)
+	if err != nil {
+		g.Warn("could not explain query: %s", err.Error())
+		return
+	}
+
+	lines := []string{}
+	for _, row := range data.Rows {
+		values := lo.Map(row, func(v any, i int) string { return cast.ToString(v) })
+		lines = append(lines, strings.Join(values, " | "))
+	}
+	g.Info("query plan for:\n%s\n\n%s", query, strings.Join(lines, "\n"))
+}
@@ -1,37 +1,56 @@
 package sling
 
 import (
-	"bufio"
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/flarco/g"
 	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core"
 	"github.com/slingdata-io/sling-cli/core/dbio"
 	"github.com/slingdata-io/sling-cli/core/dbio/database"
 	"github.com/slingdata-io/sling-cli/core/dbio/filesys"
 	"github.com/slingdata-io/sling-cli/core/dbio/iop"
 	"github.com/slingdata-io/sling-cli/core/env"
 	"github.com/spf13/cast"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // WriteToFile writes to a target file
 func (t *TaskExecution) WriteToFile(cfg *Config, df *iop.Dataflow) (cnt uint64, err error) {
 	var bw int64
+	stage := t.startStage("load")
+	defer func() { stage.End(err, attribute.Int64("sling.rows", int64(cnt)), attribute.Int64("sling.bytes", bw)) }()
 	defer t.PBar.Finish()
+
+	// sort the rows, for reproducible outputs. This reads the whole source,
+	// so it is bound by the extract timeout, not the load timeout.
+	if len(cfg.Target.Options.OrderBy) > 0 {
+		if df, err = t.sortDataflow(df, cfg.Target.Options.OrderBy); err != nil {
+			return 0, err
+		}
+	}
+
+	defer t.startTimeout("load", cfg.loadTimeout())()
 	setStage("5 - load-into-final")
 
 	if uri := cfg.TgtConn.URL(); uri != "" {
 		dateMap := iop.GetISO8601DateMap(time.Now())
 		cfg.TgtConn.Set(g.M("url", g.Rm(uri, dateMap)))
 
-		if len(df.Buffer) == 0 && !cast.ToBool(os.Getenv("SLING_ALLOW_EMPTY")) {
+		if len(df.Buffer) == 0 && cfg.abortOnEmptySource() {
+			return 0, errEmptySource(cfg)
+		} else if len(df.Buffer) == 0 && !cast.ToBool(os.Getenv("SLING_ALLOW_EMPTY")) {
 			g.Warn("No data or records found in stream. Nothing to do. To allow Sling to create empty files, set SLING_ALLOW_EMPTY=TRUE")
 			return
 		}
@@ -50,7 +69,8 @@ func (t *TaskExecution) WriteToFile(cfg *Config, df *iop.Dataflow) (cnt uint64,
 			return cnt, err
 		}
 
-		// apply column casing
+		// apply column map & casing
+		applyColumnMapToDf(df, t.Config.Target.Options.ColumnMap)
 		applyColumnCasingToDf(df, fs.FsType(), t.Config.Target.Options.ColumnCasing)
 
 		// use duckdb for writing parquet
@@ -66,7 +86,8 @@ func (t *TaskExecution) WriteToFile(cfg *Config, df *iop.Dataflow) (cnt uint64,
 		}
 		cnt = df.Count()
 	} else if cfg.Options.StdOut {
-		// apply column casing
+		// apply column map & casing
+		applyColumnMapToDf(df, t.Config.Target.Options.ColumnMap)
 		applyColumnCasingToDf(df, dbio.TypeFileLocal, t.Config.Target.Options.ColumnCasing)
 
 		limit := cast.ToUint64(cfg.Source.Limit())
@@ -84,9 +105,24 @@ func (t *TaskExecution) WriteToFile(cfg *Config, df *iop.Dataflow) (cnt uint64,
 			return cnt, nil
 		}
 
+		// json output, as lines or a single array
+		jsonFormat := strings.ToLower(g.PtrVal(cfg.Target.Options.JsonFormat))
+		if cfg.Target.Options.Format.IsJson() || jsonFormat != "" {
+			asArray := jsonFormat == "array" || (jsonFormat == "" && cfg.Target.Options.Format == dbio.FileTypeJson)
+			cnt, bw, err = writeJsonToStdout(df, asArray)
+			if err != nil {
+				err = g.Error(err, "Could not write to Stdout")
+			}
+			return
+		}
+
 		options := map[string]string{"delimiter": ","}
 		g.Unmarshal(g.Marshal(cfg.Target.Options), &options)
 
+		stdout := newFlushWriter(os.Stdout, stdoutFlushRows(), stdoutFlushInterval(), true)
+		stdout.quote = '"' // the csv writer quote
+		defer stdout.Close()
+
 		for stream := range df.StreamCh {
 			// stream.SetConfig(options)
 			// c := iop.CSV{File: os.Stdout}
@@ -110,9 +146,8 @@ func (t *TaskExecution) WriteToFile(cfg *Config, df *iop.Dataflow) (cnt uint64,
 					err = g.Error(err, "number columns have changed, not compatible with stdout.")
 					return
 				}
-				bufStdout := bufio.NewWriter(os.Stdout)
-				bw, err = filesys.Write(batchR.Reader, bufStdout)
-				bufStdout.Flush()
+				bw, err = filesys.Write(batchR.Reader, stdout)
+				stdout.Flush()
 				if err != nil {
 					err = g.Error(err, "Could not write to Stdout")
 					return
@@ -137,12 +172,50 @@ func (t *TaskExecution) WriteToFile(cfg *Config, df *iop.Dataflow) (cnt uint64,
 	return
 }
 
+// writeJsonToStdout writes the dataflow records to stdout as JSON lines, or as
+// a single JSON array streamed record by record (not buffering all rows)
+func writeJsonToStdout(df *iop.Dataflow, asArray bool) (cnt uint64, bw int64, err error) {
+	ds := iop.MergeDataflow(df)
+
+	var readers chan *io.PipeReader
+	if asArray {
+		readers = ds.NewJsonReaderChnl(df.StreamConfig())
+	} else {
+		readers = ds.NewJsonLinesReaderChnl(df.StreamConfig())
+	}
+
+	// JSON lines are flushed on row boundaries
+	stdout := newFlushWriter(os.Stdout, stdoutFlushRows(), stdoutFlushInterval(), !asArray)
+	defer stdout.Close()
+
+	for reader := range readers {
+		n, err := filesys.Write(reader, stdout)
+		bw = bw + n
+		if err != nil {
+			return cnt, bw, g.Error(err, "could not write json")
+		}
+	}
+
+	if asArray {
+		stdout.Write([]byte("\n"))
+	}
+
+	if err = ds.Context.Err(); err != nil {
+		return cnt, bw, g.Error(err, "encountered stream error")
+	}
+
+	return ds.Count, bw, nil
+}
+
 // WriteToDb writes to a target DB
 // create temp table
 // load into temp table
 // insert / incremental / replace into target table
 func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn database.Connection) (cnt uint64, err error) {
+	stage := t.startStage("load")
+	defer func() { stage.End(err, attribute.Int64("sling.rows", int64(cnt))) }()
 	defer t.PBar.Finish()
+	defer t.startTimeout("load", cfg.loadTimeout())()
 
 	// Detect empty columns
 	if len(df.Columns) == 0 {
@@ -150,10 +223,28 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 		return 0, err
 	}
 
-	// write directly to the final table (no temp table)
-	if directInsert := cast.ToBool(os.Getenv("SLING_DIRECT_INSERT")); directInsert {
+	// Ensure columns conform to the reference table (columns_from)
+	if err = checkColumnsFrom(cfg, df.Columns); err != nil {
+		return 0, err
+	}
+
+	// cassandra has no temp tables nor merges, inserts are upserts on the primary key
+	if tgtConn.GetType() == dbio.TypeDbCassandra {
+		return t.writeToDbDirectly(cfg, df, tgtConn)
+	}
+
+	// no DDL allowed (no temp table): insert into the existing table
+	if cfg.noCreate() {
+		return t.writeToDbDirectly(cfg, df, tgtConn)
+	}
+
+	// write directly to the final table (no temp table).
+	// This is not atomic: a failure mid-load leaves the rows inserted so far.
+	if directInsert := cast.ToBool(os.Getenv("SLING_DIRECT_INSERT")) || g.PtrVal(cfg.Target.Options.DirectInsert); directInsert {
 		if g.In(cfg.Mode, IncrementalMode, BackfillMode) && len(cfg.Source.PrimaryKey()) > 0 {
 			g.Warn("mode '%s' with a primary-key is not supported for direct write, falling back to using a temporary table.", cfg.Mode)
+		} else if g.PtrVal(cfg.Target.Options.LoadProcedure) != "" {
+			g.Warn("load_procedure is not supported for direct write, falling back to using a temporary table.")
 		} else {
 			return t.writeToDbDirectly(cfg, df, tgtConn)
 		}
@@ -165,11 +256,21 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 		return 0, err
 	}
 
+	// Ensure the existing target columns match the renamed columns
+	if err := checkColumnMapTarget(cfg, tgtConn, targetTable); err != nil {
+		return 0, err
+	}
+
 	tableTmp, err := initializeTempTable(cfg, tgtConn, targetTable)
 	if err != nil {
 		return 0, err
 	}
 
+	// Ensure the load procedure exists, before staging
+	if err := checkLoadProcedure(cfg, tgtConn, targetTable); err != nil {
+		return 0, err
+	}
+
 	setStage("4 - prepare-temp")
 
 	// Ensure schema exists
@@ -201,8 +302,13 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 		return 0, err
 	}
 
-	// Create temp table
-	if err := createTable(t, tgtConn, tableTmp, sampleData, true); err != nil {
+	// Create temp table. Not a temporary table when swapped in as the target table.
+	if cfg.atomicSwap() {
+		if _, err := createTableIfNotExists(tgtConn, sampleData, &tableTmp, false); err != nil {
+			err = g.Error(err, "could not create table "+tableTmp.FullName())
+			return 0, err
+		}
+	} else if err := createTable(t, tgtConn, tableTmp, sampleData, true); err != nil {
 		err = g.Error(err, "could not create table "+tableTmp.FullName())
 		return 0, err
 	}
@@ -212,9 +318,12 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 	df.Columns = sampleData.Columns
 	setStage("4 - load-into-temp")
 
-	// Add cleanup task for temp table
+	// Add cleanup task for temp table, unless kept for inspection
+	var loaded atomic.Bool
+	keepTemp, _ := cfg.keepTemp()
 	t.AddCleanupTaskFirst(func() {
-		if cast.ToBool(os.Getenv("SLING_KEEP_TEMP")) {
+		if keepTemp == keepTempAlways || (keepTemp == keepTempFailure && !loaded.Load()) {
+			g.Info("keeping temp table %s for inspection (keep_temp: %s)", tableTmp.FullName(), keepTemp)
 			return
 		}
 
@@ -268,6 +377,10 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 
 	t.PBar.Finish()
 
+	if t.interrupted.Load() {
+		g.Warn("interrupted: committing %d rows extracted so far into %s", cnt, targetTable.FullName())
+	}
+
 	// Validate data
 	tCnt, err := tgtConn.GetCount(tableTmp.FullName())
 	if err != nil {
@@ -283,6 +396,17 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 		}
 	}
 
+	// Abort before touching the final table
+	if cnt == 0 && cfg.abortOnEmptySource() {
+		return 0, errEmptySource(cfg)
+	}
+
+	// Load the child rows of the normalized JSON arrays
+	children, err := t.loadNormalizedTemp(cfg, df, tgtConn, targetTable)
+	if err != nil {
+		return 0, err
+	}
+
 	// Execute pre-SQL
 	if err := executeSQL(t, tgtConn, cfg.Target.Options.PreSQL, "pre"); err != nil {
 		err = g.Error(err, "Error executing %s-sql", "pre")
@@ -292,6 +416,7 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 	// Handle empty data case
 	if cnt == 0 && !cast.ToBool(os.Getenv("SLING_ALLOW_EMPTY_TABLES")) && !cast.ToBool(os.Getenv("SLING_ALLOW_EMPTY")) {
 		g.Warn("no data or records found in stream. Nothing to do. To allow Sling to create empty tables, set SLING_ALLOW_EMPTY=TRUE")
+		loaded.Store(true)
 		return 0, nil
 	} else if cnt > 0 {
 		// FIXME: find root cause of why columns don't sync while streaming
@@ -310,45 +435,89 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 		}
 	}
 
-	// need to contain the final write in a transcation after data is loaded
+	if cfg.atomicSwap() {
+		// rename the temp table over the final table
+		setStage("5 - swap-final")
+		if err := swapTargetTable(t, cfg, tgtConn, tableTmp, targetTable); err != nil {
+			err = g.Error(err, "error swapping temp table into final table")
+			return 0, err
+		}
+	} else {
+		// need to contain the final write in a transcation after data is loaded
 
-	txOptions := determineTxOptions(tgtConn.GetType())
-	if err := tgtConn.BeginContext(df.Context.Ctx, &txOptions); err != nil {
-		err = g.Error(err, "could not open transaction to write to final table")
-		return 0, err
-	}
+		txOptions := determineTxOptions(tgtConn.GetType())
+		if err := tgtConn.BeginContext(df.Context.Ctx, &txOptions); err != nil {
+			err = g.Error(err, "could not open transaction to write to final table")
+			return 0, err
+		}
 
-	defer tgtConn.Rollback() // rollback in case of error
+		defer tgtConn.Rollback() // rollback in case of error
 
-	setStage("5 - prepare-final")
+		setStage("5 - prepare-final")
 
-	// Prepare final table operations
-	if err = prepareFinal(t, cfg, tgtConn, targetTable, df); err != nil {
-		err = g.Error(err, "error preparing final table")
-		return 0, err
-	}
+		// Prepare final table operations
+		if err = prepareFinal(t, cfg, tgtConn, targetTable, df); err != nil {
+			err = g.Error(err, "error preparing final table")
+			return 0, err
+		}
 
-	// Put data from tmp to final
-	setStage("5 - load-into-final")
+		// Put data from tmp to final
+		setStage("5 - load-into-final")
 
-	// Transfer data from temp to final table
-	if cnt == 0 {
-		t.SetProgress("0 rows inserted. Nothing to do.")
-	} else if err := transferData(cfg, tgtConn, tableTmp, targetTable); err != nil {
-		err = g.Error(err, "error transferring data from temp to final table")
-		return 0, err
+		// Transfer data from temp to final table
+		if cnt == 0 {
+			t.SetProgress("0 rows inserted. Nothing to do.")
+		} else {
+			merge := t.startStage("merge")
+			err = transferData(cfg, tgtConn, tableTmp, targetTable)
+			merge.End(err, attribute.String("sling.mode", string(cfg.Mode)))
+			if err != nil {
+				err = g.Error(err, "error transferring data from temp to final table")
+				return 0, err
+			}
+		}
+
+		// Write the child tables of the normalized JSON arrays
+		if err := mergeNormalized(t, cfg, tgtConn, tableTmp, children); err != nil {
+			err = g.Error(err, "error writing the normalized child tables")
+			return 0, err
+		}
+
+		// Remove duplicates in final table
+		if err := dedupeTargetTable(cfg, tgtConn, targetTable); err != nil {
+			err = g.Error(err, "error deduplicating final table")
+			return 0, err
+		}
+
+		// Execute post-SQL
+		if err := executeSQL(t, tgtConn, cfg.Target.Options.PostSQL, "post"); err != nil {
+			err = g.Error(err, "error executing %s-sql", "post")
+			return 0, err
+		}
+
+		// Commit transaction
+		if err := tgtConn.Commit(); err != nil {
+			err = g.Error(err, "could not commit final transaction")
+			return 0, err
+		}
 	}
 
-	// Execute post-SQL
-	if err := executeSQL(t, tgtConn, cfg.Target.Options.PostSQL, "post"); err != nil {
-		err = g.Error(err, "error executing %s-sql", "post")
-		return 0, err
+	// Create indexes, after the load
+	if err := createIndexes(t, tgtConn, targetTable); err != nil {
+		return cnt, err
 	}
 
-	// Commit transaction
-	if err := tgtConn.Commit(); err != nil {
-		err = g.Error(err, "could not commit final transaction")
-		return 0, err
+	// Grant privileges on the created table
+	if err := applyGrants(t, cfg, tgtConn, targetTable); err != nil {
+		return cnt, err
+	}
+
+	// Comment the table & columns
+	applyComments(t, cfg, tgtConn, targetTable)
+
+	// Run the data-quality assertions
+	if err := runAssertions(t, cfg, tgtConn, targetTable); err != nil {
+		return cnt, err
 	}
 
 	// Set progress as finished
@@ -358,14 +527,20 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 	}
 
 	setStage("6 - closing")
+	loaded.Store(true)
 
 	return cnt, nil
 }
 
 func (t *TaskExecution) writeToDbDirectly(cfg *Config, df *iop.Dataflow, tgtConn database.Connection) (cnt uint64, err error) {
+	// the child tables of the normalized JSON arrays are merged from temp tables
+	if cfg.normalize() != nil {
+		return 0, g.Error("source option normalize is not supported with a direct write (direct_insert, no_create or %s target)", tgtConn.GetType())
+	}
+
 	// writing directly does not support incremental/backfill with a primary key
-	// (which requires a merge/upsert). We can only insert.
-	if g.In(cfg.Mode, IncrementalMode, BackfillMode) && len(cfg.Source.PrimaryKey()) > 0 {
+	// (which requires a merge/upsert). We can only insert, except on cassandra (upserts).
+	if g.In(cfg.Mode, IncrementalMode, BackfillMode) && len(cfg.Source.PrimaryKey()) > 0 && tgtConn.GetType() != dbio.TypeDbCassandra {
 		return 0, g.Error("mode '%s' with a primary-key is not supported for direct write.", cfg.Mode)
 	}
 
@@ -375,8 +550,15 @@ func (t *TaskExecution) writeToDbDirectly(cfg *Config, df *iop.Dataflow, tgtConn
 		return 0, err
 	}
 
+	// Ensure the existing target columns match the renamed columns
+	if err := checkColumnMapTarget(cfg, tgtConn, targetTable); err != nil {
+		return 0, err
+	}
+
 	// Ensure schema exists
-	if err := ensureSchemaExists(tgtConn, targetTable.Schema); err != nil {
+	if cfg.noCreate() {
+		g.Debug("no_create: not creating nor altering %s", targetTable.FullName())
+	} else if err := ensureSchemaExists(tgtConn, targetTable.Schema); err != nil {
 		return 0, err
 	}
 
@@ -392,6 +574,18 @@ func (t *TaskExecution) writeToDbDirectly(cfg *Config, df *iop.Dataflow, tgtConn
 		return 0, err
 	}
 
+	// Ensure the existing table has the stream columns
+	if cfg.noCreate() {
+		if err := checkNoCreateTarget(cfg, tgtConn, targetTable, sampleData.Columns); err != nil {
+			return 0, err
+		}
+	}
+
+	// Abort before touching the final table
+	if len(sampleData.Rows) == 0 && cfg.abortOnEmptySource() {
+		return 0, errEmptySource(cfg)
+	}
+
 	// Set table keys
 	targetTable.Columns = sampleData.Columns
 	if err := targetTable.SetKeys(cfg.Source.PrimaryKey(), cfg.Source.UpdateKey, cfg.Target.Options.TableKeys); err != nil {
@@ -405,8 +599,10 @@ func (t *TaskExecution) writeToDbDirectly(cfg *Config, df *iop.Dataflow, tgtConn
 	}
 
 	// Create final table
-	if err := createTable(t, tgtConn, targetTable, sampleData, false); err != nil {
-		return 0, err
+	if !cfg.noCreate() {
+		if err := createTable(t, tgtConn, targetTable, sampleData, false); err != nil {
+			return 0, err
+		}
 	}
 
 	df.Columns = sampleData.Columns
@@ -443,9 +639,10 @@ func (t *TaskExecution) writeToDbDirectly(cfg *Config, df *iop.Dataflow, tgtConn
 		return 0, err
 	}
 
-	// Validate data only for full-refresh or truncate
-	// otherwise, we cannot validate the data.
-	if g.In(cfg.Mode, FullRefreshMode, TruncateMode) {
+	// Validate data only when the table was replaced or truncated
+	// otherwise, we cannot validate the data. Cassandra inserts are upserts (rows
+	// sharing a key collapse) and a count(*) scans every partition, so skip it there.
+	if g.In(cfg.IfExists(), IfExistsReplace, IfExistsTruncate) && tgtConn.GetType() != dbio.TypeDbCassandra {
 		tCnt, err := tgtConn.GetCount(targetTable.FullName())
 		if err != nil {
 			err = g.Error(err, "could not get count from final table %s", targetTable.FullName())
@@ -481,11 +678,34 @@ func (t *TaskExecution) writeToDbDirectly(cfg *Config, df *iop.Dataflow, tgtConn
 		g.Warn("no data or records found in stream. Nothing to insert.")
 	}
 
+	// Remove duplicates in final table
+	if err := dedupeTargetTable(cfg, tgtConn, targetTable); err != nil {
+		return cnt, g.Error(err, "error deduplicating final table")
+	}
+
 	// Execute post-SQL
 	if err := executeSQL(t, tgtConn, cfg.Target.Options.PostSQL, "post"); err != nil {
 		return cnt, err
 	}
 
+	// Create indexes, after the load
+	if err := createIndexes(t, tgtConn, targetTable); err != nil {
+		return cnt, err
+	}
+
+	// Grant privileges on the created table
+	if err := applyGrants(t, cfg, tgtConn, targetTable); err != nil {
+		return cnt, err
+	}
+
+	// Comment the table & columns
+	applyComments(t, cfg, tgtConn, targetTable)
+
+	// Run the data-quality assertions
+	if err := runAssertions(t, cfg, tgtConn, targetTable); err != nil {
+		return cnt, err
+	}
+
 	// Finalize progress
 	if err := df.Err(); err != nil {
 		setStage("6 - closing")
@@ -512,6 +732,7 @@ func initializeTargetTable(cfg *Config, tgtConn database.Connection) (database.T
 	if err != nil {
 		return database.Table{}, g.Error(err, "could not parse object table name")
 	}
+	targetTable.Quoting = cfg.quoteIdentifiers()
 
 	if cfg.Target.Options.TableDDL != nil {
 		targetTable.DDL = *cfg.Target.Options.TableDDL
@@ -545,6 +766,7 @@ func initializeTempTable(cfg *Config, tgtConn database.Connection, targetTable d
 		if err != nil {
 			return database.Table{}, g.Error(err, "could not parse object table name")
 		}
+		tableTmp.Quoting = cfg.quoteIdentifiers()
 		suffix := lo.Ternary(tgtConn.GetType().DBNameUpperCase(), "_TMP", "_tmp")
 		if g.In(tgtConn.GetType(), dbio.TypeDbOracle) {
 			if len(tableTmp.Name) > 24 {
@@ -568,6 +790,7 @@ func initializeTempTable(cfg *Config, tgtConn database.Connection, targetTable d
 		if err != nil {
 			return database.Table{}, g.Error(err, "could not parse temp table name")
 		}
+		tableTmp.Quoting = cfg.quoteIdentifiers()
 	}
 
 	// Set DDL for temp table
@@ -601,6 +824,9 @@ func createTable(t *TaskExecution, tgtConn database.Connection, table database.T
 	}
 	if created {
 		t.SetProgress("created table %s", table.FullName())
+		if !isTemp {
+			t.Config.Target.TableCreated = true
+		}
 	}
 	return nil
 }
@@ -664,7 +890,8 @@ func configureColumnHandlers(t *TaskExecution, cfg *Config, df *iop.Dataflow, tg
 }
 
 func prepareDataflow(t *TaskExecution, df *iop.Dataflow, tgtConn database.Connection) (iop.Dataset, error) {
-	// apply column casing
+	// apply column map & casing
+	applyColumnMapToDf(df, t.Config.Target.Options.ColumnMap)
 	applyColumnCasingToDf(df, tgtConn.GetType(), t.Config.Target.Options.ColumnCasing)
 
 	sampleData := df.BufferDataset()
@@ -674,9 +901,81 @@ func prepareDataflow(t *TaskExecution, df *iop.Dataflow, tgtConn database.Connec
 		df.Columns = sampleData.Columns
 	}
 
+	// apply the explicit target column types
+	if err := applyTargetColumnTypes(t.Config, df, &sampleData, tgtConn.GetType()); err != nil {
+		return sampleData, err
+	}
+
 	return sampleData, nil
 }
 
+// applyTargetColumnTypes sets the types of the target option `columns` on the
+// dataflow & stream columns: the native type is used in the DDL, and values are
+// cast to its general type (already parsed as such, see TargetColumnsPrepared).
+// Errors if a sampled value cannot be cast.
+func applyTargetColumnTypes(cfg *Config, df *iop.Dataflow, sampleData *iop.Dataset, tgtType dbio.Type) error {
+	for name, nativeType := range cfg.Target.Options.Columns {
+		i := lo.IndexOf(lo.Map(sampleData.Columns, func(c iop.Column, i int) string {
+			return strings.ToLower(c.Name)
+		}), strings.ToLower(name))
+		if i < 0 {
+			env.Warn(env.WarningSchema, "column %s of target option 'columns' is not in the stream", name)
+			continue
+		}
+
+		col := sampleData.Columns[i]
+		colType := iop.NativeTypeToGeneral(col.Name, nativeType, tgtType)
+		for _, row := range sampleData.Rows {
+			if i < len(row) && !canCastValue(row[i], colType) {
+				return g.Error("cannot cast column %s (%s) to %s, with value: %v", col.Name, col.Type, nativeType, row[i])
+			}
+		}
+
+		setType := func(columns iop.Columns) {
+			if i >= len(columns) {
+				return
+			}
+			columns[i].Type = colType
+			columns[i].DbType = nativeType
+			if columns[i].Metadata == nil {
+				columns[i].Metadata = map[string]string{}
+			}
+			columns[i].Metadata["target_type"] = nativeType
+		}
+
+		setType(sampleData.Columns)
+		setType(df.Columns)
+		for _, ds := range df.StreamMap {
+			if len(ds.Columns) == len(df.Columns) {
+				setType(ds.Columns)
+			}
+		}
+		g.Debug("using type %s (%s) for target column %s", nativeType, colType, col.Name)
+	}
+	return nil
+}
+
+// canCastValue returns true if the value can be cast to the column type
+func canCastValue(val any, colType iop.ColumnType) bool {
+	if val == nil {
+		return true
+	}
+
+	var err error
+	switch {
+	case colType.IsNumber():
+		if _, ok := val.(bool); ok {
+			return true
+		}
+		_, err = cast.ToFloat64E(strings.TrimSpace(cast.ToString(val)))
+	case colType.IsBool():
+		_, err = cast.ToBoolE(val)
+	case colType.IsDate() || colType.IsDatetime():
+		_, err = cast.ToTimeE(val)
+	}
+	return err == nil
+}
+
 func prepareFinal(
 	t *TaskExecution,
 	cfg *Config,
@@ -685,8 +984,9 @@ func prepareFinal(
 	df *iop.Dataflow,
 ) error {
 
-	// Handle Full Refresh Mode: Drop the target table if it exists
-	if cfg.Mode == FullRefreshMode {
+	// Drop the target table if it exists (if_exists=replace, default for full-refresh)
+	ifExists := cfg.IfExists()
+	if ifExists == IfExistsReplace {
 		if err := tgtConn.DropTable(targetTable.FullName()); err != nil {
 			return g.Error(err, "could not drop table "+targetTable.FullName())
 		}
@@ -697,12 +997,18 @@ func prepareFinal(
 	sample.Rows = df.Buffer
 	sample.Inferred = true // already inferred with SyncStats
 
-	created, err := createTableIfNotExists(tgtConn, sample, &targetTable, false)
-	if err != nil {
-		return g.Error(err, "could not create table "+targetTable.FullName())
-	} else if created {
+	var created bool
+	var err error
+	if !cfg.noCreate() {
+		if created, err = createTableIfNotExists(tgtConn, sample, &targetTable, false); err != nil {
+			return g.Error(err, "could not create table "+targetTable.FullName())
+		}
+	}
+
+	if created {
 		t.SetProgress("created table %s", targetTable.FullName())
-	} else if cfg.Mode == TruncateMode {
+		cfg.Target.TableCreated = true
+	} else if ifExists == IfExistsTruncate {
 		// Truncate table since it exists
 		if err := truncateTable(t, tgtConn, targetTable.FullName()); err != nil {
 			return err
@@ -710,8 +1016,15 @@ func prepareFinal(
 		t.SetProgress("truncated table %s", targetTable.FullName())
 	}
 
-	// If the table wasn't created and we're not in Full Refresh Mode, handle schema updates
-	if !created && cfg.Mode != FullRefreshMode {
+	// Add the identity surrogate key column, generated by the target
+	if !cfg.noCreate() {
+		if err := addIdentityColumn(t, cfg, tgtConn, targetTable); err != nil {
+			return err
+		}
+	}
+
+	// If the table wasn't created nor replaced, handle schema updates (not with no_create)
+	if !created && ifExists != IfExistsReplace && !cfg.noCreate() {
 		// Add missing columns if the option is enabled
 		if cfg.Target.Options.AddNewColumns != nil && *cfg.Target.Options.AddNewColumns {
 			if ok, err := tgtConn.AddMissingColumns(targetTable, sample.Columns); err != nil {
@@ -757,12 +1070,88 @@ func prepareFinal(
 	return nil
 }
 
+// addIdentityColumn adds the surrogate key column (strategy identity) to the target
+// table if missing, with the identity (or sequence) of the dialect. The column is not
+// in the stream: its values are generated on insert, and kept by the merges.
+// Dialects which cannot add an identity to a non-empty table (snowflake) rebuild
+// it with the `add_identity_column_rebuild` template, generating the existing keys.
+func addIdentityColumn(t *TaskExecution, cfg *Config, tgtConn database.Connection, targetTable database.Table) error {
+	sk := cfg.Target.Options.SurrogateKey
+	if sk == nil || sk.Strategy != SurrogateKeyIdentity {
+		return nil
+	}
+
+	column := sk.Name()
+	if casing := cfg.Target.Options.ColumnCasing; casing != nil {
+		column = casing.Apply(column, tgtConn.GetType())
+	}
+
+	columns, err := tgtConn.GetColumns(targetTable.FullName())
+	if err != nil {
+		return g.Error(err, "could not get columns of %s", targetTable.FullName())
+	} else if columns.GetColumn(column) != nil {
+		return nil
+	}
+
+	sequence := targetTable
+	sequence.Name = strings.ToLower(targetTable.Name + "_" + column + "_seq")
+
+	template := tgtConn.GetTemplateValue("core.add_identity_column")
+	if rebuild := tgtConn.GetTemplateValue("core.add_identity_column_rebuild"); rebuild != "" {
+		if count, err := tgtConn.GetCount(targetTable.FullName()); err != nil {
+			return g.Error(err, "could not count rows of %s", targetTable.FullName())
+		} else if count > 0 {
+			template = rebuild
+		}
+	}
+
+	tempTable := targetTable
+	tempTable.Name += lo.Ternary(tgtConn.GetType().DBNameUpperCase(), "_SK_TMP", "_sk_tmp")
+	fields := lo.Map(columns.Names(), func(name string, i int) string { return tgtConn.Quote(name, false) })
+
+	sql := g.R(
+		template,
+		"table", targetTable.FullName(),
+		"temp_table", tempTable.FullName(),
+		"column", tgtConn.Quote(column),
+		"sequence", sequence.FullName(),
+		"fields", strings.Join(fields, ", "),
+	)
+	if _, err = tgtConn.ExecMulti(sql); err != nil {
+		return g.Error(err, "could not add surrogate key column %s to %s", column, targetTable.FullName())
+	}
+	t.SetProgress("added surrogate key column %s to %s", column, targetTable.FullName())
+
+	return nil
+}
+
 func transferData(cfg *Config, tgtConn database.Connection, tableTmp, targetTable database.Table) error {
 	if cfg.Mode == "drop (need to optimize temp table in place)" {
 		// Use swap
 		return transferBySwappingTables(tgtConn, tableTmp, targetTable)
 	}
 
+	if g.PtrVal(cfg.Target.Options.LoadProcedure) != "" {
+		// user procedure performs the final load
+		if err := callLoadProcedure(cfg, tgtConn, tableTmp, targetTable); err != nil {
+			err = g.Error(err, "could not call load procedure")
+			return err
+		}
+		return nil
+	}
+
+	if cfg.Mode == IncrementalMode && cfg.appendOnly() {
+		// no merge: skip the rows already in the target (by primary key), then insert
+		if err := deleteExistingFromTemp(cfg, tgtConn, tableTmp, targetTable); err != nil {
+			return err
+		}
+		if err := insertFromTemp(cfg, tgtConn); err != nil {
+			err = g.Error(err, "could not insert from temp")
+			return err
+		}
+		return nil
+	}
+
 	if (cfg.Mode == IncrementalMode && len(cfg.Source.PrimaryKey()) == 0) || cfg.Mode == SnapshotMode || cfg.Mode == FullRefreshMode || cfg.Mode == TruncateMode {
 		// insert directly
 		if err := insertFromTemp(cfg, tgtConn); err != nil {
@@ -786,6 +1175,87 @@ func transferData(cfg *Config, tgtConn database.Connection, tableTmp, targetTabl
 	return err
 }
 
+// swapTargetTable replaces the target table with the loaded temp table (target option
+// `write_mode: atomic-swap`), within a transaction where DDL is transactional. The views
+// bound to the previous table are recreated on the new one, before it is dropped.
+func swapTargetTable(t *TaskExecution, cfg *Config, tgtConn database.Connection, tableTmp, targetTable database.Table) error {
+	exists, err := database.TableExists(tgtConn, targetTable.FullName())
+	if err != nil {
+		return g.Error(err, "could not check if table exists: %s", targetTable.FullName())
+	}
+
+	swapDDLs, dropDDL, err := targetTable.SwapDDL(tableTmp, exists)
+	if err != nil {
+		return err
+	}
+
+	var viewDDLs []string
+	if exists {
+		if viewDDLs, err = dependentViewsDDL(tgtConn, targetTable); err != nil {
+			return err
+		}
+	}
+
+	txOptions := determineTxOptions(tgtConn.GetType())
+	if err := tgtConn.BeginContext(tgtConn.Context().Ctx, &txOptions); err != nil {
+		return g.Error(err, "could not open transaction to swap tables")
+	}
+	defer tgtConn.Rollback() // rollback in case of error
+
+	t.SetProgress("swapping %s into %s", tableTmp.FullName(), targetTable.FullName())
+	for _, ddl := range append(append(swapDDLs, viewDDLs...), dropDDL) {
+		if ddl == "" {
+			continue
+		} else if _, err := tgtConn.Exec(ddl); err != nil {
+			return g.Error(err, "could not swap %s into %s", tableTmp.FullName(), targetTable.FullName())
+		}
+	}
+
+	// Execute post-SQL
+	if err := executeSQL(t, tgtConn, cfg.Target.Options.PostSQL, "post"); err != nil {
+		return g.Error(err, "error executing %s-sql", "post")
+	}
+
+	if err := tgtConn.Commit(); err != nil {
+		return g.Error(err, "could not commit swap transaction")
+	}
+
+	// a new table, so that grants are applied
+	cfg.Target.TableCreated = true
+	return nil
+}
+
+// dependentViewsDDL returns the statements recreating the views which select from the
+// table, for dialects where views are bound to the table (`metadata.dependent_views`)
+func dependentViewsDDL(tgtConn database.Connection, table database.Table) (ddls []string, err error) {
+	if _, ok := tgtConn.Template().Metadata["dependent_views"]; !ok {
+		return nil, nil
+	}
+
+	data, err := tgtConn.SubmitTemplate(
+		"single", tgtConn.Template().Metadata, "dependent_views",
+		g.M("schema", table.Schema, "table", table.Name),
+	)
+	if err != nil {
+		return nil, g.Error(err, "could not get the views depending on %s", table.FullName())
+	}
+
+	// dropped then created, since `create or replace` fails when the column types
+	// change. Views depending on these views prevent the drop, failing the swap.
+	for _, row := range data.Rows {
+		viewSQL := strings.TrimSuffix(strings.TrimSpace(cast.ToString(row[1])), ";")
+		ddls = append(
+			ddls,
+			g.R(tgtConn.GetTemplateValue("core.drop_view"), "view", cast.ToString(row[0])),
+			g.F("create view %s as %s", cast.ToString(row[0]), viewSQL),
+		)
+	}
+	if len(ddls) > 0 {
+		g.Debug("recreating %d dependent view(s) of %s after the swap", len(data.Rows), table.FullName())
+	}
+	return ddls, nil
+}
+
 func transferBySwappingTables(tgtConn database.Connection, tableTmp, targetTable database.Table) error {
 	g.Debug("swapping temporary table %s with target table %s", tableTmp.FullName(), targetTable.FullName())
 	if err := tgtConn.SwapTable(tableTmp.FullName(), targetTable.FullName()); err != nil {
@@ -899,6 +1369,42 @@ func writeDataflowViaDuckDB(t *TaskExecution, df *iop.Dataflow, fs filesys.FileS
 	return bw, err
 }
 
+// checkLoadProcedure ensures the load procedure exists in the target database
+func checkLoadProcedure(cfg *Config, tgtConn database.Connection, targetTable database.Table) error {
+	procedure := g.PtrVal(cfg.Target.Options.LoadProcedure)
+	if procedure == "" {
+		return nil
+	}
+
+	exists, err := database.ProcedureExists(tgtConn, procedure, targetTable.Schema)
+	if err != nil {
+		return g.Error(err, "could not validate load_procedure")
+	} else if !exists {
+		return g.Error("load_procedure %s does not exist", procedure)
+	}
+	return nil
+}
+
+// callLoadProcedure calls the user procedure with the temp table and
+// target table names as arguments, instead of sling's insert / merge
+func callLoadProcedure(cfg *Config, tgtConn database.Connection, tableTmp, targetTable database.Table) error {
+	sql, err := database.CallProcedureSQL(
+		tgtConn.GetType(),
+		g.PtrVal(cfg.Target.Options.LoadProcedure),
+		tableTmp.Schema+"."+tableTmp.Name,
+		targetTable.Schema+"."+targetTable.Name,
+	)
+	if err != nil {
+		return g.Error(err, "could not generate call statement")
+	}
+
+	g.Debug("calling load procedure from temporary table %s to target table %s", tableTmp.FullName(), targetTable.FullName())
+	if _, err = tgtConn.Exec(sql); err != nil {
+		return g.Error(err, "could not execute: %s", sql)
+	}
+	return nil
+}
+
 func performUpsert(tgtConn database.Connection, tableTmp, targetTable database.Table, cfg *Config) error {
 	tgtPrimaryKey := cfg.Source.PrimaryKey()
 	if casing := cfg.Target.Options.ColumnCasing; casing != nil {
@@ -906,19 +1412,490 @@ func performUpsert(tgtConn database.Connection, tableTmp, targetTable database.T
 			tgtPrimaryKey[i] = casing.Apply(pk, tgtConn.GetType())
 		}
 	}
-	g.Debug("performing upsert from temporary table %s to target table %s with primary keys %v",
-		tableTmp.FullName(), targetTable.FullName(), tgtPrimaryKey)
-	rowAffCnt, err := tgtConn.Upsert(tableTmp.FullName(), targetTable.FullName(), tgtPrimaryKey)
-	if err != nil {
-		err = g.Error(err, "could not perform upsert from temp")
+
+	// keep the target rows newer than the source
+	if err := deleteStaleFromTemp(cfg, tgtConn, tableTmp, targetTable, tgtPrimaryKey); err != nil {
 		return err
 	}
-	if rowAffCnt > 0 {
-		g.DebugLow("%d TOTAL INSERTS / UPDATES", rowAffCnt)
+
+	// propagate source deletions, before upserting
+	deletedCond := deletedMarkerCondition(tgtConn, cfg)
+	softDeleteCol := ""
+	if deletedCond != "" {
+		if sd := cfg.Target.Options.SoftDelete; sd != nil && *sd != "" {
+			softDeleteCol = *sd
+			if casing := cfg.Target.Options.ColumnCasing; casing != nil {
+				softDeleteCol = casing.Apply(softDeleteCol, tgtConn.GetType())
+			}
+			col := iop.Column{Name: softDeleteCol, Type: iop.TimestampType}
+			if _, err := tgtConn.AddMissingColumns(targetTable, iop.Columns{col}); err != nil {
+				return g.Error(err, "could not add soft_delete column %s", softDeleteCol)
+			}
+		} else if err := deleteMarkedRows(tgtConn, tableTmp, targetTable, tgtPrimaryKey, deletedCond); err != nil {
+			return g.Error(err, "could not delete marked rows")
+		}
+	}
+
+	// skip the rows whose hash is unchanged
+	if err := deleteUnchangedFromTemp(cfg, tgtConn, tableTmp, targetTable, tgtPrimaryKey); err != nil {
+		return err
+	}
+
+	g.Debug("performing upsert from temporary table %s to target table %s with primary keys %v",
+		tableTmp.FullName(), targetTable.FullName(), tgtPrimaryKey)
+	upsertOptions := cfg.mergeUpsertOptions(tgtConn.GetType())
+	rowAffCnt, err := tgtConn.Upsert(tableTmp.FullName(), targetTable.FullName(), tgtPrimaryKey, upsertOptions)
+	if err != nil {
+		err = g.Error(err, "could not perform upsert from temp")
+		return err
+	}
+	if rowAffCnt > 0 {
+		g.DebugLow("%d TOTAL INSERTS / UPDATES", rowAffCnt)
+	}
+
+	if softDeleteCol != "" {
+		if err := flagMarkedRows(tgtConn, tableTmp, targetTable, tgtPrimaryKey, deletedCond, softDeleteCol); err != nil {
+			return g.Error(err, "could not flag soft-deleted rows")
+		}
 	}
 	return nil
 }
 
+// deletedMarkerCondition returns the SQL condition identifying deleted rows
+// in the temp table. A single column name means `column is not null`.
+func deletedMarkerCondition(tgtConn database.Connection, cfg *Config) string {
+	dm := cfg.Source.Options.DeletedMarker
+	if dm == nil || strings.TrimSpace(*dm) == "" {
+		return ""
+	}
+
+	marker := strings.TrimSpace(*dm)
+	if len(g.Matches(marker, `^\w+$`)) == 0 {
+		return marker // custom condition
+	}
+
+	if casing := cfg.Target.Options.ColumnCasing; casing != nil {
+		marker = casing.Apply(marker, tgtConn.GetType())
+	}
+	return g.F("%s is not null", tgtConn.Quote(marker))
+}
+
+// markedRowsExists returns the correlated sub-query matching target rows
+// whose primary key is marked as deleted in the temp table
+func markedRowsExists(tgtConn database.Connection, tableTmp, targetTable database.Table, pk []string, cond string) string {
+	pkEquals := lo.Map(pk, func(k string, i int) string {
+		return g.F("src.%s = %s.%s", tgtConn.Quote(k), targetTable.FullName(), tgtConn.Quote(k))
+	})
+	return g.F(
+		"exists (select 1 from %s src where %s and (%s))",
+		tableTmp.FullName(), strings.Join(pkEquals, " and "), cond,
+	)
+}
+
+// deleteMarkedRows deletes the target rows marked as deleted in the temp table,
+// then removes the marked rows from the temp table so they are not upserted
+func deleteMarkedRows(tgtConn database.Connection, tableTmp, targetTable database.Table, pk []string, cond string) error {
+	sql := g.F(
+		"delete from %s where %s",
+		targetTable.FullName(), markedRowsExists(tgtConn, tableTmp, targetTable, pk, cond),
+	)
+	result, err := tgtConn.Exec(sql)
+	if err != nil {
+		return g.Error(err, "could not delete rows from %s", targetTable.FullName())
+	}
+	if cnt, _ := result.RowsAffected(); cnt > 0 {
+		g.DebugLow("%d TOTAL DELETES", cnt)
+	}
+
+	sql = g.F("delete from %s where %s", tableTmp.FullName(), cond)
+	if _, err = tgtConn.Exec(sql); err != nil {
+		return g.Error(err, "could not delete marked rows from %s", tableTmp.FullName())
+	}
+	return nil
+}
+
+// flagMarkedRows sets the soft-delete column of the target rows marked as deleted,
+// and clears it for the rows that were not (e.g. restored)
+func flagMarkedRows(tgtConn database.Connection, tableTmp, targetTable database.Table, pk []string, cond, softDeleteCol string) error {
+	for _, clause := range []struct{ value, cond string }{
+		{"current_timestamp", cond},
+		{"null", g.F("not (%s)", cond)},
+	} {
+		sql := g.F(
+			"update %s set %s = %s where %s",
+			targetTable.FullName(), tgtConn.Quote(softDeleteCol), clause.value,
+			markedRowsExists(tgtConn, tableTmp, targetTable, pk, clause.cond),
+		)
+		if _, err := tgtConn.Exec(sql); err != nil {
+			return g.Error(err, "could not update %s", targetTable.FullName())
+		}
+	}
+	return nil
+}
+
+// dedupeTargetTable deletes the duplicate rows in the target table (dedupe_on_load),
+// keeping the latest row per primary key. Runs entirely in the database.
+func dedupeTargetTable(cfg *Config, tgtConn database.Connection, targetTable database.Table) error {
+	if !g.PtrVal(cfg.Target.Options.DedupeOnLoad) {
+		return nil
+	}
+
+	casing := cfg.Target.Options.ColumnCasing
+	applyCasing := func(name string) string {
+		if casing != nil {
+			return casing.Apply(name, tgtConn.GetType())
+		}
+		return name
+	}
+
+	pk := lo.Map(cfg.Source.PrimaryKey(), func(k string, i int) string { return applyCasing(k) })
+	orderBy := g.PtrVal(cfg.Target.Options.DedupeOrderBy)
+	if orderBy == "" {
+		orderBy = strings.Join(lo.Map(cfg.Source.UpdateKeys(), func(k string, i int) string {
+			return tgtConn.Quote(applyCasing(k)) + " desc"
+		}), ", ")
+	}
+
+	sql, err := database.DedupeSQL(tgtConn.GetType(), targetTable.FullName(), pk, orderBy)
+	if err != nil {
+		return g.Error(err, "could not generate dedupe statement")
+	}
+
+	result, err := tgtConn.Exec(sql)
+	if err != nil {
+		return g.Error(err, "could not dedupe %s", targetTable.FullName())
+	}
+	if cnt, _ := result.RowsAffected(); cnt > 0 {
+		g.Debug("removed %d duplicate rows from %s", cnt, targetTable.FullName())
+	}
+	return nil
+}
+
+// createIndexes creates the indexes of the target option `indexes`
+// which do not exist yet (clustering key for Snowflake)
+func createIndexes(t *TaskExecution, tgtConn database.Connection, targetTable database.Table) error {
+	indexes := t.Config.Target.Options.Indexes
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	existing := []string{}
+	if data, err := tgtConn.GetIndexes(targetTable.FullName()); err == nil {
+		for _, row := range data.Rows {
+			existing = append(existing, cast.ToString(row[0]))
+		}
+	} else {
+		g.Debug("could not get existing indexes of %s: %s", targetTable.FullName(), err.Error())
+	}
+
+	ddls := targetTable.IndexesDDL(indexes, existing)
+	if len(ddls) == 0 {
+		return nil
+	}
+
+	t.SetProgress("creating %d index(es) on %s", len(ddls), targetTable.FullName())
+	for _, ddl := range ddls {
+		if _, err := tgtConn.Exec(ddl); err != nil {
+			return g.Error(err, "could not create index on %s", targetTable.FullName())
+		}
+	}
+	return nil
+}
+
+// normalizedTable is a child table split from the JSON records (source option
+// `normalize`), with the temp table its rows are loaded into
+type normalizedTable struct {
+	table database.Table
+	tmp   database.Table
+}
+
+// loadNormalizedTemp loads the child rows split from the JSON records (source option
+// `normalize`) into temp tables, next to the target table. The child tables
+// `<table>_<array>` are written from them with the target table, see mergeNormalized.
+func (t *TaskExecution) loadNormalizedTemp(cfg *Config, df *iop.Dataflow, tgtConn database.Connection, targetTable database.Table) (children []normalizedTable, err error) {
+	if cfg.normalize() == nil {
+		return nil, nil
+	}
+
+	streams, err := df.NormalizedStreams()
+	if err != nil {
+		return nil, g.Error(err, "could not read the normalized rows")
+	}
+	paths := lo.Keys(streams)
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		ds := streams[path]
+
+		childName := targetTable.Name + "_" + strings.ReplaceAll(path, "__", "_")
+		child := normalizedTable{table: database.Table{
+			Name:     lo.Ternary(tgtConn.GetType().DBNameUpperCase(), strings.ToUpper(childName), childName),
+			Schema:   targetTable.Schema,
+			Database: targetTable.Database,
+			Dialect:  targetTable.Dialect,
+			Quoting:  cfg.quoteIdentifiers(),
+		}}
+		child.table.Raw = child.table.FullName()
+		child.tmp = child.table
+		child.tmp.Name += lo.Ternary(tgtConn.GetType().DBNameUpperCase(), "_TMP", "_tmp")
+		child.tmp.Raw = child.tmp.FullName()
+
+		if err = dropTableIfExists(tgtConn, child.tmp.FullName()); err != nil {
+			return nil, err
+		} else if _, err = createTableIfNotExists(tgtConn, iop.NewDataset(ds.Columns), &child.tmp, false); err != nil {
+			return nil, g.Error(err, "could not create table %s", child.tmp.FullName())
+		}
+		tmpName := child.tmp.FullName()
+		t.AddCleanupTaskFirst(func() { g.LogError(tgtConn.DropTable(tmpName)) })
+
+		childDf, err := iop.MakeDataFlow(ds)
+		if err != nil {
+			return nil, g.Error(err, "could not stream the rows of child table %s", child.table.FullName())
+		}
+
+		if err = tgtConn.BeginContext(df.Context.Ctx); err != nil {
+			return nil, g.Error(err, "could not open transaction to write to temp table")
+		}
+		cnt, err := tgtConn.BulkImportFlow(child.tmp.FullName(), childDf)
+		if err != nil {
+			tgtConn.Rollback()
+			return nil, g.Error(err, "could not insert into %s", child.tmp.FullName())
+		} else if err = tgtConn.Commit(); err != nil {
+			return nil, g.Error(err, "could not commit transaction")
+		}
+		t.SetProgress("inserted %d rows into %s", cnt, child.tmp.FullName())
+
+		children = append(children, child)
+	}
+
+	return children, nil
+}
+
+// mergeNormalized writes the child tables from their temp tables, within the transaction
+// of the target table. The child tables are replaced or truncated as the target table.
+// Else, the child rows of the parent rows in the temp table replace the previous ones
+// (merged on the parent key), so that incremental runs do not duplicate them.
+func mergeNormalized(t *TaskExecution, cfg *Config, tgtConn database.Connection, tableTmp database.Table, children []normalizedTable) error {
+	if len(children) == 0 {
+		return nil
+	}
+
+	// the parent key of the child rows, matching the primary key of the temp table
+	parentOf := func(alias string) string {
+		equals := lo.Map(cfg.Source.PrimaryKey(), func(key string, i int) string {
+			parentKey := key
+			if col := tableTmp.Columns.GetColumn(key); col != nil {
+				parentKey = col.Name
+			}
+			childKey := iop.NormalizeParentPrefix + parentKey
+			return g.F("p.%s = %s.%s", tgtConn.Quote(parentKey), alias, tgtConn.Quote(childKey))
+		})
+		return g.F("exists (select 1 from %s p where %s)", tableTmp.FullName(), strings.Join(equals, " and "))
+	}
+
+	for _, child := range children {
+		tmpColumns, err := tgtConn.GetColumns(child.tmp.FullName())
+		if err != nil {
+			return g.Error(err, "could not get columns of %s", child.tmp.FullName())
+		}
+
+		if cfg.IfExists() == IfExistsReplace {
+			if err = dropTableIfExists(tgtConn, child.table.FullName()); err != nil {
+				return err
+			}
+		}
+
+		created, err := createTableIfNotExists(tgtConn, iop.NewDataset(tmpColumns), &child.table, false)
+		if err != nil {
+			return g.Error(err, "could not create child table %s", child.table.FullName())
+		} else if created {
+			t.SetProgress("created child table %s", child.table.FullName())
+		} else {
+			if _, err = tgtConn.AddMissingColumns(child.table, tmpColumns); err != nil {
+				return g.Error(err, "could not add missing columns to %s", child.table.FullName())
+			}
+
+			if cfg.IfExists() == IfExistsTruncate {
+				err = truncateTable(t, tgtConn, child.table.FullName())
+			} else {
+				sql := g.F("delete from %s where %s", child.table.FullName(), parentOf(child.table.FullName()))
+				_, err = tgtConn.Exec(sql)
+			}
+			if err != nil {
+				return g.Error(err, "could not delete the previous rows of %s", child.table.FullName())
+			}
+		}
+
+		fields := strings.Join(tgtConn.GetType().QuoteNames(tmpColumns.Names()...), ", ")
+		sql := g.F(
+			"insert into %s (%s) select %s from %s c where %s",
+			child.table.FullName(), fields, fields, child.tmp.FullName(), parentOf("c"),
+		)
+		result, err := tgtConn.Exec(sql)
+		if err != nil {
+			return g.Error(err, "could not insert into child table %s", child.table.FullName())
+		}
+		cnt, _ := result.RowsAffected()
+		t.SetProgress("inserted %d rows into child table %s", cnt, child.table.FullName())
+	}
+
+	return nil
+}
+
+// sortDataflow sorts the rows of the dataflow by the order_by columns.
+// All rows are read (and spilled past the max memory) before writing.
+func (t *TaskExecution) sortDataflow(df *iop.Dataflow, orderBy []string) (*iop.Dataflow, error) {
+	keys, err := iop.ParseSortKeys(orderBy)
+	if err != nil {
+		return df, g.Error(err, "invalid order_by")
+	}
+
+	t.SetProgress("sorting rows by %s", strings.Join(orderBy, ", "))
+	ds, err := iop.SortDataflow(df, keys)
+	if err != nil {
+		return df, g.Error(err, "could not sort rows")
+	}
+
+	sortedDf, err := iop.MakeDataFlow(ds)
+	if err != nil {
+		return df, g.Error(err, "could not make sorted dataflow")
+	}
+	t.AddCleanupTaskFirst(func() { sortedDf.Close() })
+
+	return sortedDf, nil
+}
+
+// applyGrants grants the privileges of the grants target option, when the
+// target table was created during the run (or with SLING_FORCE_GRANTS)
+func applyGrants(t *TaskExecution, cfg *Config, tgtConn database.Connection, targetTable database.Table) error {
+	grants := cfg.Target.Options.Grants
+	if len(grants) == 0 {
+		return nil
+	} else if !cfg.Target.TableCreated && !cast.ToBool(os.Getenv("SLING_FORCE_GRANTS")) {
+		g.Debug("table %s already existed, skipping grants (use --force-grants to apply)", targetTable.FullName())
+		return nil
+	}
+
+	ddls, err := targetTable.GrantsDDL(grants)
+	if err != nil {
+		return errGrantFailed(targetTable, err)
+	}
+
+	t.SetProgress("granting %d privilege(s) on %s", len(ddls), targetTable.FullName())
+	for _, ddl := range ddls {
+		if _, err := tgtConn.Exec(ddl); err != nil {
+			return errGrantFailed(targetTable, err)
+		}
+	}
+	return nil
+}
+
+// applyComments comments the target table & columns with the comments of the
+// source table (add_comments), columns_description and the lineage of the table
+// (lineage_comment). Failures are logged, since the data is already loaded.
+func applyComments(t *TaskExecution, cfg *Config, tgtConn database.Connection, targetTable database.Table) {
+	policy := g.PtrVal(cfg.Target.Options.LineageComment)
+	if !g.PtrVal(cfg.Target.Options.AddComments) && len(cfg.Target.Options.ColumnsDescription) == 0 && policy == "" {
+		return
+	} else if !database.CommentsSupported(tgtConn.GetType()) {
+		g.Debug("comments are not supported for %s, skipping", tgtConn.GetType())
+		return
+	}
+
+	tgtColumns, err := tgtConn.GetColumns(targetTable.FullName())
+	if err != nil {
+		g.Warn("could not comment %s, failed to get columns: %s", targetTable.FullName(), err.Error())
+		return
+	}
+
+	comments := targetComments(cfg, t.comments, tgtColumns, tgtConn.GetType())
+	switch {
+	case policy == LineageCommentUpdate, policy == LineageCommentCreate && cfg.Target.TableCreated:
+		comments.Table = strings.TrimSpace(comments.Table + "\n" + lineageComment(cfg, g.PtrVal(t.StartTime)))
+	case policy == LineageCommentCreate:
+		comments.Table = "" // keep the lineage of the existing table
+	}
+
+	// mysql re-states the column definition to comment a column
+	if strings.Contains(tgtConn.GetType().GetTemplateValue("core.comment_column"), "{definition}") && len(comments.Columns) > 0 {
+		tgtComments, err := tgtConn.GetComments(targetTable.FullName())
+		if err != nil {
+			g.Warn("could not comment the columns of %s: %s", targetTable.FullName(), err.Error())
+			comments.Columns = nil
+		}
+		comments.Definitions = tgtComments.Definitions
+	}
+
+	ddls := targetTable.CommentsDDL(comments)
+	if len(ddls) == 0 {
+		return
+	}
+
+	t.SetProgress("commenting %s", targetTable.FullName())
+	for _, ddl := range ddls {
+		if _, err := tgtConn.Exec(ddl); err != nil {
+			g.Warn("could not comment %s: %s", targetTable.FullName(), err.Error())
+			return
+		}
+	}
+}
+
+// lineageComment returns the comment recording the provenance of the target
+// table: the source connection & object, the sling version and the load time
+func lineageComment(cfg *Config, loadedAt time.Time) string {
+	connName := cfg.SrcConn.Name
+	if connName == "" {
+		connName = cfg.SrcConn.Type.String() // never the url, which may hold secrets
+	}
+
+	object := cfg.Source.Stream
+	if u, err := url.Parse(object); err == nil && u.Scheme != "" && u.Host != "" {
+		// file urls may hold credentials or signatures
+		u.User, u.RawQuery, u.Fragment = nil, "", ""
+		object = u.String()
+	}
+	if cfg.Source.Query != "" {
+		object = "custom SQL"
+	} else if table, _ := database.ParseTableName(object, cfg.SrcConn.Type); cfg.SrcConn.Type.IsDb() && table.IsQuery() {
+		object = "custom SQL"
+	}
+
+	if loadedAt.IsZero() {
+		loadedAt = time.Now()
+	}
+
+	return g.F(
+		"Loaded by Sling %s from %s (%s) at %s",
+		core.Version, connName, object, loadedAt.UTC().Format(time.RFC3339),
+	)
+}
+
+// targetComments maps the column names of the source comments and of
+// columns_description (which takes precedence) to the target columns, with
+// column_map & column_casing. Columns not in the target are skipped.
+func targetComments(cfg *Config, srcComments database.TableComments, tgtColumns iop.Columns, tgtType dbio.Type) (comments database.TableComments) {
+	comments = database.TableComments{Table: srcComments.Table, Columns: map[string]string{}}
+
+	setComment := func(name, comment string) {
+		name, _ = lookupColumnMap(cfg.Target.Options.ColumnMap, name)
+		if casing := cfg.Target.Options.ColumnCasing; casing != nil {
+			name = casing.Apply(name, tgtType)
+		}
+		if col := tgtColumns.GetColumn(name); col != nil {
+			comments.Columns[col.Name] = comment
+		}
+	}
+
+	for name, comment := range srcComments.Columns {
+		setComment(name, comment)
+	}
+	for name, comment := range cfg.Target.Options.ColumnsDescription {
+		setComment(name, comment)
+	}
+
+	return comments
+}
+
 func executeSQL(t *TaskExecution, tgtConn database.Connection, sqlStatements *string, stage string) error {
 	if sqlStatements == nil || *sqlStatements == "" {
 		return nil
@@ -934,3 +1911,222 @@ func executeSQL(t *TaskExecution, tgtConn database.Connection, sqlStatements *st
 	}
 	return nil
 }
+
+// abortOnEmptySource returns true if an empty source should fail the run
+func (cfg *Config) abortOnEmptySource() bool {
+	if cfg.Source.Options != nil && cfg.Source.Options.AbortOnEmpty != nil {
+		return *cfg.Source.Options.AbortOnEmpty
+	}
+	return cast.ToBool(os.Getenv("SLING_ABORT_ON_EMPTY_SOURCE"))
+}
+
+// quoteIdentifiers returns how the target table & column names are quoted
+func (cfg *Config) quoteIdentifiers() dbio.QuoteIdentifiers {
+	if cfg.Target.Options != nil && cfg.Target.Options.QuoteIdentifiers != nil {
+		return *cfg.Target.Options.QuoteIdentifiers
+	}
+	return dbio.QuoteIdentifiersAlways
+}
+
+// normalizeKeys returns the normalization of JSON object keys, from the
+// source options, else `lowercase` if SLING_COLUMNS_LOWERCASE_KEYS is set
+func (cfg *Config) normalizeKeys() string {
+	if cfg.Source.Options != nil && cfg.Source.Options.NormalizeKeys != nil {
+		return *cfg.Source.Options.NormalizeKeys
+	}
+	if cast.ToBool(os.Getenv("SLING_COLUMNS_LOWERCASE_KEYS")) {
+		return iop.NormalizeKeysLowercase
+	}
+	return ""
+}
+
+// appendOnly returns true if incremental rows are inserted without a merge
+// (target option `append_only`, or SLING_APPEND_ONLY with flag `--append-only`)
+func (cfg *Config) appendOnly() bool {
+	if cfg.Target.Options != nil && cfg.Target.Options.AppendOnly != nil {
+		return *cfg.Target.Options.AppendOnly
+	}
+	return cast.ToBool(os.Getenv("SLING_APPEND_ONLY"))
+}
+
+// noCreate returns true if no DDL is issued on the target table (target
+// option `no_create`, or SLING_NO_CREATE with flag `--no-create`)
+func (cfg *Config) noCreate() bool {
+	if cfg.Target.Options != nil && cfg.Target.Options.NoCreate != nil {
+		return *cfg.Target.Options.NoCreate
+	}
+	return cast.ToBool(os.Getenv("SLING_NO_CREATE"))
+}
+
+// atomicSwap returns true if the loaded temp table is renamed over the
+// target table (target option `write_mode: atomic-swap`)
+func (cfg *Config) atomicSwap() bool {
+	return cfg.Target.Options != nil && g.PtrVal(cfg.Target.Options.WriteMode) == WriteModeAtomicSwap
+}
+
+const (
+	keepTempFailure = "failure" // keep the temp table if the load fails
+	keepTempAlways  = "always"  // keep the temp table, also on success
+)
+
+// keepTemp returns when the temp table is kept for inspection, instead of
+// dropped: `failure`, `always` or empty (never). From the target option
+// `keep_temp`, else SLING_NO_TEMP_CLEANUP (flag `--no-temp-cleanup`).
+// SLING_KEEP_TEMP also keeps the other temp files & stages, always.
+func (cfg *Config) keepTemp() (string, error) {
+	val := os.Getenv("SLING_NO_TEMP_CLEANUP")
+	if cfg.Target.Options != nil && cfg.Target.Options.KeepTemp != nil {
+		val = *cfg.Target.Options.KeepTemp
+	} else if val == "" && cast.ToBool(os.Getenv("SLING_KEEP_TEMP")) {
+		val = keepTempAlways
+	}
+
+	switch val = strings.ToLower(strings.TrimSpace(val)); val {
+	case keepTempFailure, keepTempAlways:
+		return val, nil
+	case "", "false":
+		return "", nil
+	case "true":
+		return keepTempFailure, nil
+	}
+	return "", g.Error("invalid value for keep_temp: %s. Valid values are: failure, always", val)
+}
+
+// renameTargetColumns returns true if the target columns are renamed with
+// column_map (target option `rename_target_columns`, or SLING_RENAME_TARGET_COLUMNS
+// with flag `--rename-target-columns`)
+func (cfg *Config) renameTargetColumns() bool {
+	if cfg.Target.Options != nil && cfg.Target.Options.RenameTargetColumns != nil {
+		return *cfg.Target.Options.RenameTargetColumns
+	}
+	return cast.ToBool(os.Getenv("SLING_RENAME_TARGET_COLUMNS"))
+}
+
+// existingRowsSQL returns the statement deleting the temp table rows whose
+// primary key (and extra columns) already exists in the target table
+func existingRowsSQL(tgtConn database.Connection, tableTmp, targetTable database.Table, pk []string, extraCols ...string) string {
+	pkEquals := lo.Map(append(append([]string{}, pk...), extraCols...), func(k string, i int) string {
+		return g.F("tgt.%s = %s.%s", tgtConn.Quote(k), tableTmp.FullName(), tgtConn.Quote(k))
+	})
+	return g.F(
+		"delete from %s where exists (select 1 from %s tgt where %s)",
+		tableTmp.FullName(), targetTable.FullName(), strings.Join(pkEquals, " and "),
+	)
+}
+
+// deleteExistingFromTemp removes the rows already loaded from the temp
+// table, when append-only with a primary key
+func deleteExistingFromTemp(cfg *Config, tgtConn database.Connection, tableTmp, targetTable database.Table) error {
+	// a copy, the source primary key is not modified
+	pk := append([]string{}, cfg.Source.PrimaryKey()...)
+	if len(pk) == 0 {
+		return nil
+	}
+
+	if casing := cfg.Target.Options.ColumnCasing; casing != nil {
+		for i, k := range pk {
+			pk[i] = casing.Apply(k, tgtConn.GetType())
+		}
+	}
+
+	result, err := tgtConn.Exec(existingRowsSQL(tgtConn, tableTmp, targetTable, pk))
+	if err != nil {
+		return g.Error(err, "could not remove existing rows from %s", tableTmp.FullName())
+	}
+	if cnt, _ := result.RowsAffected(); cnt > 0 {
+		g.Debug("skipped %d rows already in %s (append_only)", cnt, targetTable.FullName())
+	}
+	return nil
+}
+
+// deleteUnchangedFromTemp removes the temp table rows whose primary key & row
+// hash (add_hash) are unchanged in the target table, so they are not updated
+func deleteUnchangedFromTemp(cfg *Config, tgtConn database.Connection, tableTmp, targetTable database.Table, pk []string) error {
+	if !g.PtrVal(cfg.Target.Options.AddHash) || len(pk) == 0 {
+		return nil
+	}
+
+	hashCol := slingRowHashColumn
+	if casing := cfg.Target.Options.ColumnCasing; casing != nil {
+		hashCol = casing.Apply(hashCol, tgtConn.GetType())
+	}
+
+	// target tables created before add_hash have no hash to compare
+	columns, err := tgtConn.GetColumns(targetTable.FullName())
+	if err != nil {
+		return g.Error(err, "could not get columns of %s", targetTable.FullName())
+	} else if columns.GetColumn(hashCol) == nil {
+		g.Debug("%s has no %s column, updating all rows", targetTable.FullName(), hashCol)
+		return nil
+	}
+
+	result, err := tgtConn.Exec(existingRowsSQL(tgtConn, tableTmp, targetTable, pk, hashCol))
+	if err != nil {
+		return g.Error(err, "could not remove unchanged rows from %s", tableTmp.FullName())
+	}
+	if cnt, _ := result.RowsAffected(); cnt > 0 {
+		g.Debug("skipped %d unchanged rows of %s (add_hash)", cnt, targetTable.FullName())
+	}
+	return nil
+}
+
+// staleRowsSQL returns the statement deleting the temp table rows whose version
+// is not greater than the version of the target row with the same primary key
+func staleRowsSQL(tgtConn database.Connection, tableTmp, targetTable database.Table, pk []string, versionCol string) string {
+	pkEquals := lo.Map(pk, func(k string, i int) string {
+		return g.F("tgt.%s = %s.%s", tgtConn.Quote(k), tableTmp.FullName(), tgtConn.Quote(k))
+	})
+	tgtVersion := "tgt." + tgtConn.Quote(versionCol)
+	srcVersion := tableTmp.FullName() + "." + tgtConn.Quote(versionCol)
+	return g.F(
+		"delete from %s where exists (select 1 from %s tgt where %s and %s is not null and (%s is null or %s >= %s))",
+		tableTmp.FullName(), targetTable.FullName(), strings.Join(pkEquals, " and "),
+		tgtVersion, srcVersion, tgtVersion, srcVersion,
+	)
+}
+
+// deleteStaleFromTemp removes the temp table rows which are not newer than the
+// target rows (version_column), so that the merge keeps the target rows
+func deleteStaleFromTemp(cfg *Config, tgtConn database.Connection, tableTmp, targetTable database.Table, pk []string) error {
+	versionCol := cfg.versionColumn(tgtConn.GetType())
+	if versionCol == "" || len(pk) == 0 {
+		return nil
+	}
+
+	// the versions must be comparable
+	for _, table := range []database.Table{targetTable, tableTmp} {
+		columns, err := tgtConn.GetColumns(table.FullName())
+		if err != nil {
+			return g.Error(err, "could not get columns of %s", table.FullName())
+		}
+
+		col := columns.GetColumn(versionCol)
+		if col == nil {
+			return g.Error("version_column %s not found in %s", versionCol, table.FullName())
+		} else if !col.IsNumber() && !col.IsDate() && !col.IsDatetime() {
+			return g.Error("version_column %s of %s is not comparable (type %s), should be a number, date or timestamp", versionCol, table.FullName(), col.Type)
+		}
+	}
+
+	result, err := tgtConn.Exec(staleRowsSQL(tgtConn, tableTmp, targetTable, pk, versionCol))
+	if err != nil {
+		return g.Error(err, "could not remove stale rows from %s", tableTmp.FullName())
+	}
+	if cnt, _ := result.RowsAffected(); cnt > 0 {
+		cfg.Target.KeptCount += uint64(cnt)
+		g.Info("kept %d rows of %s newer than the source (version_column %s)", cnt, targetTable.FullName(), versionCol)
+	}
+	return nil
+}
+
+// errGrantFailed is the error of a grant, reported apart from load errors
+// since the data is already loaded
+func errGrantFailed(targetTable database.Table, err error) error {
+	return g.Error(err, "data was loaded into %s, but granting privileges failed (target option 'grants')", targetTable.FullName())
+}
+
+// errEmptySource is the error of an empty source, with abort_on_empty
+func errEmptySource(cfg *Config) error {
+	stream := lo.Ternary(cfg.StreamName != "", cfg.StreamName, cfg.Source.Stream)
+	return g.Error("source stream %s returned 0 rows, aborting without changing the target (abort_on_empty)", stream)
+}
@@ -0,0 +1,94 @@
+package sling
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+)
+
+// ProgressSnapshot is a point-in-time view of the progress of a task,
+// printed on demand (SIGUSR1) without interrupting the run
+type ProgressSnapshot struct {
+	Stream    string        `json:"stream"`
+	Status    ExecStatus    `json:"status"`
+	Progress  string        `json:"progress"`
+	Rows      uint64        `json:"rows"`
+	BytesIn   uint64        `json:"bytes_in"`
+	BytesOut  uint64        `json:"bytes_out"`
+	Elapsed   time.Duration `json:"elapsed"`
+	TotalRows uint64        `json:"total_rows,omitempty"` // when known (limit)
+}
+
+// Snapshot returns the current progress snapshot of the task,
+// copied under the lock since the task keeps running
+func (t *TaskExecution) Snapshot() (ps ProgressSnapshot) {
+	t.snapshotMux.Lock()
+	ps = ProgressSnapshot{Status: t.Status, Progress: t.Progress}
+	startTime, df := t.StartTime, t.df
+	t.snapshotMux.Unlock()
+
+	if t.Config != nil {
+		ps.Stream = lo.Ternary(t.Config.StreamName != "", t.Config.StreamName, t.Config.Source.Stream)
+		if limit := t.Config.Source.Limit(); limit > 0 {
+			ps.TotalRows = cast.ToUint64(limit)
+		}
+	}
+	if startTime != nil {
+		ps.Elapsed = time.Since(*startTime)
+	}
+	if df != nil {
+		ps.Rows = df.Count()
+		ps.BytesIn, ps.BytesOut = df.Bytes()
+	}
+	return
+}
+
+// Rate returns the rows per second
+func (ps ProgressSnapshot) Rate() float64 {
+	if ps.Elapsed <= 0 {
+		return 0
+	}
+	return float64(ps.Rows) / ps.Elapsed.Seconds()
+}
+
+// Remaining returns the estimated remaining duration,
+// ok is false when the total row count is unknown
+func (ps ProgressSnapshot) Remaining() (remaining time.Duration, ok bool) {
+	rate := ps.Rate()
+	if ps.TotalRows == 0 || rate == 0 {
+		return 0, false
+	} else if ps.Rows >= ps.TotalRows {
+		return 0, true
+	}
+	seconds := float64(ps.TotalRows-ps.Rows) / rate
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second), true
+}
+
+// String returns the snapshot as a human readable block
+func (ps ProgressSnapshot) String() string {
+	stream := lo.Ternary(ps.Stream != "", ps.Stream, "(none)")
+	status := lo.Ternary(ps.Status != "", string(ps.Status), "running")
+
+	lines := []string{
+		g.F("progress snapshot | stream: %s | status: %s", stream, status),
+		g.F("  rows: %s (%s r/s)", humanize.Comma(int64(ps.Rows)), humanize.Commaf(float64(int64(ps.Rate())))),
+		g.F("  bytes: %s read, %s written", humanize.Bytes(ps.BytesIn), humanize.Bytes(ps.BytesOut)),
+		g.F("  elapsed: %s", ps.Elapsed.Round(time.Second)),
+	}
+
+	if remaining, ok := ps.Remaining(); ok {
+		lines = append(lines, g.F("  remaining: ~%s (%s of %s rows)", remaining, humanize.Comma(int64(ps.Rows)), humanize.Comma(int64(ps.TotalRows))))
+	} else {
+		lines = append(lines, "  remaining: unknown")
+	}
+
+	if ps.Progress != "" {
+		lines = append(lines, g.F("  last: %s", ps.Progress))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,49 @@
+package sling
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressSnapshot(t *testing.T) {
+	ps := ProgressSnapshot{
+		Stream:    "public.orders",
+		Status:    ExecStatusRunning,
+		Progress:  "writing to target database [mode: full-refresh]",
+		Rows:      2500,
+		BytesIn:   5 * 1000 * 1000,
+		BytesOut:  4 * 1000 * 1000,
+		Elapsed:   10 * time.Second,
+		TotalRows: 10000,
+	}
+
+	assert.Equal(t, 250.0, ps.Rate())
+	remaining, ok := ps.Remaining()
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, remaining)
+
+	lines := strings.Split(ps.String(), "\n")
+	if assert.Len(t, lines, 6) {
+		assert.Equal(t, "progress snapshot | stream: public.orders | status: running", lines[0])
+		assert.Equal(t, "  rows: 2,500 (250 r/s)", lines[1])
+		assert.Equal(t, "  bytes: 5.0 MB read, 4.0 MB written", lines[2])
+		assert.Equal(t, "  elapsed: 10s", lines[3])
+		assert.Equal(t, "  remaining: ~30s (2,500 of 10,000 rows)", lines[4])
+		assert.Equal(t, "  last: writing to target database [mode: full-refresh]", lines[5])
+	}
+
+	// unknown total
+	ps.TotalRows = 0
+	_, ok = ps.Remaining()
+	assert.False(t, ok)
+	assert.Contains(t, ps.String(), "remaining: unknown")
+
+	// empty task
+	task := &TaskExecution{}
+	snapshot := task.Snapshot()
+	assert.Zero(t, snapshot.Rows)
+	assert.Contains(t, snapshot.String(), "stream: (none)")
+}
@@ -0,0 +1,134 @@
+package sling
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+)
+
+// stdoutFlushInterval returns the maximum time rows are buffered before
+// being flushed to stdout, from SLING_STDOUT_FLUSH_INTERVAL (flag `--stdout-flush-interval`)
+func stdoutFlushInterval() time.Duration {
+	interval, err := parseTimeout(os.Getenv("SLING_STDOUT_FLUSH_INTERVAL"))
+	if err != nil {
+		g.Warn("invalid SLING_STDOUT_FLUSH_INTERVAL: %s", err.Error())
+		return 0
+	}
+	return interval
+}
+
+// stdoutFlushRows returns the number of rows after which stdout is flushed,
+// from SLING_STDOUT_FLUSH_ROWS (flag `--stdout-flush-rows`)
+func stdoutFlushRows() int {
+	return cast.ToInt(os.Getenv("SLING_STDOUT_FLUSH_ROWS"))
+}
+
+// flushWriter is a buffered writer which also flushes every flushRows rows and
+// every flushInterval, so that piped consumers get rows promptly. With
+// lineAligned, flushes only happen at the end of a line (CSV & JSON lines rows).
+// With quote set, line breaks within quoted CSV values do not end a row.
+type flushWriter struct {
+	writer        *bufio.Writer
+	flushRows     int
+	flushInterval time.Duration
+	lineAligned   bool
+	quote         byte
+
+	rows      int  // rows since the last flush
+	atRowEnd  bool // the buffer ends on a row boundary
+	inQuotes  bool // within a quoted CSV value
+	mux       sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newFlushWriter returns a flushWriter on w. Close stops the interval flushing
+// and flushes the remaining buffer.
+func newFlushWriter(w io.Writer, flushRows int, flushInterval time.Duration, lineAligned bool) *flushWriter {
+	fw := &flushWriter{
+		writer:        bufio.NewWriter(w),
+		flushRows:     flushRows,
+		flushInterval: flushInterval,
+		lineAligned:   lineAligned,
+		atRowEnd:      true,
+		done:          make(chan struct{}),
+	}
+
+	if flushInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(flushInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-fw.done:
+					return
+				case <-ticker.C:
+					fw.mux.Lock()
+					if fw.atRowEnd && fw.writer.Buffered() > 0 {
+						fw.flush()
+					}
+					fw.mux.Unlock()
+				}
+			}
+		}()
+	}
+
+	return fw
+}
+
+func (fw *flushWriter) Write(p []byte) (n int, err error) {
+	fw.mux.Lock()
+	defer fw.mux.Unlock()
+
+	if n, err = fw.writer.Write(p); err != nil {
+		return n, err
+	}
+
+	if fw.lineAligned && fw.quote != 0 {
+		for _, b := range p {
+			if b == fw.quote {
+				fw.inQuotes = !fw.inQuotes // an escaped quote toggles twice
+			} else if b == '\n' && !fw.inQuotes {
+				fw.rows++
+			}
+		}
+		fw.atRowEnd = bytes.HasSuffix(p, []byte{'\n'}) && !fw.inQuotes
+	} else if fw.lineAligned {
+		fw.rows += bytes.Count(p, []byte{'\n'})
+		fw.atRowEnd = bytes.HasSuffix(p, []byte{'\n'})
+	} else {
+		fw.rows++
+	}
+
+	if fw.atRowEnd && fw.flushRows > 0 && fw.rows >= fw.flushRows {
+		err = fw.flush()
+	}
+	return n, err
+}
+
+// Flush writes the buffered data to the underlying writer
+func (fw *flushWriter) Flush() error {
+	fw.mux.Lock()
+	defer fw.mux.Unlock()
+	return fw.flush()
+}
+
+func (fw *flushWriter) flush() error {
+	fw.rows = 0
+	return fw.writer.Flush()
+}
+
+// Close stops the interval flushing and flushes the buffer
+func (fw *flushWriter) Close() error {
+	fw.closeOnce.Do(func() { close(fw.done) })
+
+	fw.mux.Lock()
+	defer fw.mux.Unlock()
+	return fw.flush()
+}
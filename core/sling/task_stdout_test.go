@@ -0,0 +1,78 @@
+package sling
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lockedBuffer is a bytes.Buffer safe to read while being written
+type lockedBuffer struct {
+	buf bytes.Buffer
+	mux sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.buf.String()
+}
+
+func TestFlushWriter(t *testing.T) {
+	// without flush options, rows stay buffered until flushed
+	out := &lockedBuffer{}
+	fw := newFlushWriter(out, 0, 0, true)
+	fw.Write([]byte("id,name\n1,a\n"))
+	assert.Empty(t, out.String())
+	fw.Close()
+	assert.Equal(t, "id,name\n1,a\n", out.String())
+
+	// flush every 2 rows, on row boundaries only
+	out = &lockedBuffer{}
+	fw = newFlushWriter(out, 2, 0, true)
+	fw.Write([]byte("id,name\n"))
+	fw.Write([]byte("1,"))
+	assert.Empty(t, out.String())
+	fw.Write([]byte("a\n"))
+	assert.Equal(t, "id,name\n1,a\n", out.String())
+	fw.Write([]byte("2,b\n3,"))
+	assert.Equal(t, "id,name\n1,a\n", out.String(), "should not flush a partial row")
+	fw.Write([]byte("c\n"))
+	assert.Equal(t, "id,name\n1,a\n2,b\n3,c\n", out.String())
+	fw.Close()
+
+	// line breaks in quoted CSV values do not end a row
+	out = &lockedBuffer{}
+	fw = newFlushWriter(out, 1, 0, true)
+	fw.quote = '"'
+	fw.Write([]byte("1,\"multi\n"))
+	assert.Empty(t, out.String(), "should not flush within a quoted value")
+	fw.Write([]byte("line \"\"x\"\"\"\n"))
+	assert.Equal(t, "1,\"multi\nline \"\"x\"\"\"\n", out.String())
+	fw.Close()
+}
+
+func TestFlushWriterInterval(t *testing.T) {
+	out := &lockedBuffer{}
+	fw := newFlushWriter(out, 0, 50*time.Millisecond, true)
+	defer fw.Close()
+
+	// slow producer: each row should appear before the next one is produced
+	for i, row := range []string{"1,a\n", "2,b\n", "3,c\n"} {
+		fw.Write([]byte(row))
+		assert.Eventually(t, func() bool {
+			return strings.Count(out.String(), "\n") == i+1
+		}, time.Second, 10*time.Millisecond, "row %d was not flushed", i+1)
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,119 @@
+package sling
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+)
+
+// parseTimeout parses a duration (e.g. `90s`, `10m`) or a number of seconds
+func parseTimeout(val string) (timeout time.Duration, err error) {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return 0, nil
+	}
+
+	if seconds, err := cast.ToIntE(val); err == nil {
+		timeout = time.Duration(seconds) * time.Second
+	} else if timeout, err = time.ParseDuration(val); err != nil {
+		return 0, g.Error("could not parse duration: %s", val)
+	}
+
+	if timeout < 0 {
+		return 0, g.Error("duration cannot be negative: %s", val)
+	}
+	return timeout, nil
+}
+
+// phaseTimeout returns the timeout of a phase option, or the global
+// SLING_TIMEOUT (flag `--timeout`) if not set. When both are set, the shorter one applies.
+func phaseTimeout(option *string) time.Duration {
+	timeout, _ := parseTimeout(g.PtrVal(option))
+	global, _ := parseTimeout(os.Getenv("SLING_TIMEOUT"))
+	if timeout == 0 || (global > 0 && global < timeout) {
+		return global
+	}
+	return timeout
+}
+
+// extractTimeout is the timeout of the extraction (source_options.extract_timeout)
+func (cfg *Config) extractTimeout() time.Duration {
+	var option *string
+	if cfg.Source.Options != nil {
+		option = cfg.Source.Options.ExtractTimeout
+	}
+	if option == nil && cfg.streamingSource() {
+		return 0 // the global timeout is the duration of the pull, not a failure
+	}
+	return phaseTimeout(option)
+}
+
+// loadTimeout is the timeout of the load (target_options.load_timeout)
+func (cfg *Config) loadTimeout() time.Duration {
+	var option *string
+	if cfg.Target.Options != nil {
+		option = cfg.Target.Options.LoadTimeout
+	}
+	if option == nil && cfg.streamingSource() {
+		return 0 // the load lasts as long as the pull
+	}
+	return phaseTimeout(option)
+}
+
+// streamingSource returns true if the source is pulled until the global
+// timeout (e.g. Pub/Sub), instead of being read until done
+func (cfg *Config) streamingSource() bool {
+	return cfg.SrcConn.Type == dbio.TypeDbPubSub
+}
+
+// startTimeout cancels the task if the phase (`extract` or `load`) is not
+// done within the timeout. The returned func marks the phase as done.
+func (t *TaskExecution) startTimeout(phase string, timeout time.Duration) (stop func()) {
+	if timeout <= 0 {
+		return func() {}
+	}
+
+	timer := time.AfterFunc(timeout, func() {
+		t.timeoutErr = g.Error("%s timeout of %s exceeded", phase, timeout)
+		t.Context.Cancel()
+	})
+
+	once := sync.Once{}
+	stop = func() { once.Do(func() { timer.Stop() }) }
+	t.AddCleanupTaskLast(stop)
+
+	return stop
+}
+
+// stopWhenRead marks the extraction as done once the source dataflow is fully read
+func (t *TaskExecution) stopWhenRead(df *iop.Dataflow, err error, stop func()) {
+	if df == nil || err != nil {
+		stop()
+		return
+	}
+
+	go func() {
+		defer stop()
+		for !df.IsClosed() {
+			select {
+			case <-t.Context.Ctx.Done():
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}()
+}
+
+// TimeoutErr returns the error of an exceeded extract or load timeout
+func (t *TaskExecution) TimeoutErr() error {
+	if t.Context == nil || t.Context.Ctx.Err() == nil {
+		return nil // the timeout error is set before canceling
+	}
+	return t.timeoutErr
+}
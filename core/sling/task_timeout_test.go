@@ -0,0 +1,92 @@
+package sling
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/connection"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimeout(t *testing.T) {
+	timeout, err := parseTimeout("90")
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Second, timeout)
+
+	timeout, err = parseTimeout("10m")
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, timeout)
+
+	timeout, err = parseTimeout("")
+	assert.NoError(t, err)
+	assert.Zero(t, timeout)
+
+	_, err = parseTimeout("ten minutes")
+	assert.Error(t, err)
+	_, err = parseTimeout("-5s")
+	assert.Error(t, err)
+
+	// the global timeout caps the phase timeouts
+	os.Setenv("SLING_TIMEOUT", "30s")
+	defer os.Unsetenv("SLING_TIMEOUT")
+	assert.Equal(t, 30*time.Second, phaseTimeout(g.String("10m")))
+	assert.Equal(t, 10*time.Second, phaseTimeout(g.String("10s")))
+	assert.Equal(t, 30*time.Second, phaseTimeout(nil))
+
+	// the global timeout applies alone, except to the pull of a streaming source
+	cfg := &Config{SrcConn: connection.Connection{Type: dbio.TypeDbPostgres}}
+	assert.Equal(t, 30*time.Second, cfg.extractTimeout())
+	assert.Equal(t, 30*time.Second, cfg.loadTimeout())
+	cfg.SrcConn.Type = dbio.TypeDbPubSub
+	assert.Zero(t, cfg.extractTimeout())
+	assert.Zero(t, cfg.loadTimeout())
+
+	os.Unsetenv("SLING_TIMEOUT")
+	assert.Zero(t, phaseTimeout(nil))
+}
+
+func TestPhaseTimeouts(t *testing.T) {
+	newTask := func() *TaskExecution {
+		return &TaskExecution{Context: g.NewContext(context.Background())}
+	}
+
+	waitCanceled := func(task *TaskExecution) bool {
+		select {
+		case <-task.Context.Ctx.Done():
+			return true
+		case <-time.After(5 * time.Second):
+			return false
+		}
+	}
+
+	// extract timeout fires while the load is within its timeout
+	task := newTask()
+	task.startTimeout("load", time.Minute)
+	task.startTimeout("extract", 50*time.Millisecond)
+	if assert.True(t, waitCanceled(task)) && assert.Error(t, task.TimeoutErr()) {
+		assert.Contains(t, task.TimeoutErr().Error(), "extract timeout of 50ms exceeded")
+	}
+	task.Cleanup()
+
+	// extraction done in time, load timeout fires
+	task = newTask()
+	stopExtract := task.startTimeout("extract", 50*time.Millisecond)
+	stopExtract()
+	task.startTimeout("load", 100*time.Millisecond)
+	if assert.True(t, waitCanceled(task)) && assert.Error(t, task.TimeoutErr()) {
+		assert.Contains(t, task.TimeoutErr().Error(), "load timeout")
+	}
+	task.Cleanup()
+
+	// phases done in time
+	task = newTask()
+	task.startTimeout("extract", 50*time.Millisecond)()
+	task.startTimeout("load", 50*time.Millisecond)()
+	time.Sleep(150 * time.Millisecond)
+	assert.NoError(t, task.Context.Err())
+	assert.NoError(t, task.TimeoutErr())
+}
@@ -0,0 +1,177 @@
+package sling
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/spf13/cast"
+)
+
+// SQLTemplateFuncs returns the functions available in SQL templates.
+//
+//	{{ env "MY_VAR" }}                    value of environment variable
+//	{{ now }}                             current timestamp (time.Time)
+//	{{ dateAdd "-1d" }}                   run date shifted by the duration, as YYYY-MM-DD
+//	{{ dateAdd "-2h" "2006-01-02 15:04" }} same, with a custom Go layout
+//	{{ dateFormat .range_start "20060102" }} parses & reformats a date value
+//	{{ ident .my_table }}                 safely quoted identifier (dialect-aware)
+//	{{ literal .my_value }}               safely quoted string literal
+//
+// Durations accept the units s, m, h, d, w, mo & y (e.g. `-1d`, `+3mo`).
+func SQLTemplateFuncs(dbType dbio.Type, runTime time.Time) template.FuncMap {
+	return template.FuncMap{
+		"env": os.Getenv,
+		"now": func() time.Time { return runTime },
+		"dateAdd": func(duration string, layout ...string) (string, error) {
+			t, err := addDuration(runTime, duration)
+			if err != nil {
+				return "", err
+			}
+			if len(layout) > 0 {
+				return t.Format(layout[0]), nil
+			}
+			return t.Format("2006-01-02"), nil
+		},
+		"dateFormat": func(value any, layout string) (string, error) {
+			t, err := cast.ToTimeE(value)
+			if err != nil {
+				return "", g.Error(err, "could not parse date: %v", value)
+			}
+			return t.Format(layout), nil
+		},
+		"ident": func(name string) string {
+			return quoteIdentifier(dbType, name)
+		},
+		"literal": func(value any) string {
+			return quoteLiteral(dbType, cast.ToString(value))
+		},
+	}
+}
+
+// RenderSQLTemplate renders a SQL text containing Go template expressions.
+// Available variables are the ones from the format map (`.stream_table`,
+// `.run_timestamp`, etc.), as well as:
+//
+//	.run_date     the date of the run, as YYYY-MM-DD
+//	.range_start  the start value of the backfill range
+//	.range_end    the end value of the backfill range
+//	.vars         the config vars (flag `--config-var key=value`)
+func (cfg *Config) RenderSQLTemplate(text string, fMap map[string]any) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	runTime := time.Now()
+	data := g.M("run_date", runTime.Format("2006-01-02"), "vars", ConfigVars())
+	for k, v := range fMap {
+		data[k] = v
+	}
+
+	if cfg.Source.Options != nil && cfg.Source.Options.Range != nil {
+		if rangeArr := strings.Split(*cfg.Source.Options.Range, ","); len(rangeArr) == 2 {
+			data["range_start"] = strings.TrimSpace(rangeArr[0])
+			data["range_end"] = strings.TrimSpace(rangeArr[1])
+		}
+	}
+
+	tmpl, err := template.New("sql").
+		Option("missingkey=error").
+		Funcs(SQLTemplateFuncs(cfg.SrcConn.Type, runTime)).
+		Parse(text)
+	if err != nil {
+		return "", g.Error(err, "could not parse SQL template")
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, data); err != nil {
+		return "", g.Error(err, "could not render SQL template")
+	}
+
+	return buf.String(), nil
+}
+
+// ParseConfigVars parses the `key=value` values of the flag `--config-var`
+func ParseConfigVars(values []string) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, value := range values {
+		key, val, found := strings.Cut(value, "=")
+		if key = strings.TrimSpace(key); !found || key == "" {
+			return nil, g.Error("invalid config var %#v, should be key=value", value)
+		}
+		vars[key] = val
+	}
+	return vars, nil
+}
+
+// ConfigVars returns the config vars (env var SLING_CONFIG_VARS, a JSON object set
+// by the flag `--config-var`), available as `.vars` in SQL templates and as `{key}`
+// in replications, where they override the replication env defaults
+func ConfigVars() map[string]any {
+	vars := map[string]any{}
+	if payload := os.Getenv("SLING_CONFIG_VARS"); payload != "" {
+		if err := g.Unmarshal(payload, &vars); err != nil {
+			g.Warn("could not parse SLING_CONFIG_VARS: %s", err.Error())
+		}
+	}
+	return vars
+}
+
+// quoteIdentifier quotes each part of a (possibly qualified) identifier,
+// removing any quote characters to prevent injection
+func quoteIdentifier(dbType dbio.Type, name string) string {
+	q := `"`
+	if tmpl, err := dbType.Template(); err == nil && tmpl.Variable["quote_char"] != "" {
+		q = tmpl.Variable["quote_char"]
+	}
+
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		part = strings.ReplaceAll(strings.TrimSpace(part), q, "")
+		parts[i] = q + part + q
+	}
+	return strings.Join(parts, ".")
+}
+
+// quoteLiteral quotes the value as a string literal, escaping the backslashes
+// for the dialects treating them as escape characters
+func quoteLiteral(dbType dbio.Type, value string) string {
+	switch dbType {
+	case dbio.TypeDbMySQL, dbio.TypeDbMariaDB, dbio.TypeDbStarRocks, dbio.TypeDbSnowflake,
+		dbio.TypeDbBigQuery, dbio.TypeDbClickhouse:
+		value = strings.ReplaceAll(value, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// addDuration shifts the time by a duration such as `-1d`, `+3mo` or `12h`
+func addDuration(t time.Time, duration string) (time.Time, error) {
+	duration = strings.TrimSpace(duration)
+	matches := g.Matches(duration, `^([+-]?\d+)\s*(s|m|h|d|w|mo|y)$`)
+	if len(matches) == 0 || len(matches[0].Group) < 2 {
+		return t, g.Error("invalid duration: %s", duration)
+	}
+
+	n := cast.ToInt(strings.TrimPrefix(matches[0].Group[0], "+"))
+	switch matches[0].Group[1] {
+	case "s":
+		return t.Add(time.Duration(n) * time.Second), nil
+	case "m":
+		return t.Add(time.Duration(n) * time.Minute), nil
+	case "h":
+		return t.Add(time.Duration(n) * time.Hour), nil
+	case "d":
+		return t.AddDate(0, 0, n), nil
+	case "w":
+		return t.AddDate(0, 0, 7*n), nil
+	case "mo":
+		return t.AddDate(0, n, 0), nil
+	case "y":
+		return t.AddDate(n, 0, 0), nil
+	}
+	return t, g.Error("invalid duration: %s", duration)
+}
@@ -0,0 +1,165 @@
+package sling
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracer creates the run, stream and stage spans. It is a no-op unless
+// tracing was started with an OpenTelemetry endpoint (--otel-endpoint).
+var tracer trace.Tracer = noop.NewTracerProvider().Tracer("sling")
+
+// runCtx holds the run span, the parent of the stream spans
+var runCtx = context.Background()
+
+// setTracerProvider sets the provider of the spans
+func setTracerProvider(tp trace.TracerProvider) {
+	tracer = tp.Tracer("sling")
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
+
+// StartTracing exports the spans to the OTLP/HTTP endpoint
+// (e.g. http://localhost:4318). The returned function flushes the spans.
+func StartTracing(endpoint string) (shutdown func(), err error) {
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, g.Error(err, "could not create OpenTelemetry exporter for %s", endpoint)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", "sling"),
+			attribute.String("service.version", core.Version),
+		)),
+	)
+	setTracerProvider(tp)
+
+	shutdown = func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			g.Warn("could not flush OpenTelemetry spans: %s", err.Error())
+		}
+	}
+
+	return shutdown, nil
+}
+
+// StartRunSpan starts the span of a run. When the TRACEPARENT (and TRACESTATE)
+// env vars are set, such as by a CI or an orchestrator, the run span is
+// nested in the calling trace.
+func StartRunSpan(name string, attrs ...attribute.KeyValue) (end func(err error)) {
+	carrier := propagation.MapCarrier{
+		"traceparent": os.Getenv("TRACEPARENT"),
+		"tracestate":  os.Getenv("TRACESTATE"),
+	}
+	parentCtx := propagation.TraceContext{}.Extract(context.Background(), carrier)
+
+	attrs = append(attrs, attribute.String("sling.exec_id", os.Getenv("SLING_EXEC_ID")))
+	ctx, span := tracer.Start(parentCtx, name, trace.WithAttributes(attrs...))
+	runCtx = ctx
+
+	return func(err error) {
+		endSpan(span, err)
+		runCtx = context.Background()
+	}
+}
+
+// startSpan starts the span of the task execution (stream)
+func (t *TaskExecution) startSpan() {
+	attrs := []attribute.KeyValue{
+		attribute.String("sling.exec_id", t.ExecID),
+		attribute.String("sling.type", string(t.Type)),
+	}
+
+	name := "stream"
+	if cfg := t.Config; cfg != nil {
+		name = cfg.StreamName
+		if name == "" {
+			name = cfg.Source.Stream
+		}
+		attrs = append(attrs,
+			attribute.String("sling.stream", name),
+			attribute.String("sling.mode", string(cfg.Mode)),
+			attribute.String("sling.source.type", cfg.SrcConn.Type.String()),
+			attribute.String("sling.target.type", cfg.TgtConn.Type.String()),
+			attribute.String("sling.target.object", cfg.Target.Object),
+		)
+	}
+
+	t.spanCtx, t.span = tracer.Start(runCtx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends the span of the task execution, with the rows and bytes
+func (t *TaskExecution) endSpan() {
+	if t.span == nil {
+		return
+	}
+
+	inBytes, _ := t.GetBytes()
+	t.span.SetAttributes(
+		attribute.Int64("sling.rows", int64(t.GetCount())),
+		attribute.Int64("sling.bytes", int64(inBytes)),
+		attribute.String("sling.status", string(t.Status)),
+	)
+	endSpan(t.span, t.Err)
+}
+
+// stageSpan is the span of a stage of the task execution (extract, load, merge)
+type stageSpan struct {
+	span trace.Span
+}
+
+// startStage starts the span of a stage, as a child of the stream span
+func (t *TaskExecution) startStage(name string) *stageSpan {
+	ctx := t.spanCtx
+	if ctx == nil {
+		ctx = runCtx
+	}
+	_, span := tracer.Start(ctx, name, trace.WithAttributes(attribute.String("sling.stage", name)))
+	return &stageSpan{span: span}
+}
+
+// End ends the span of the stage, recording the error if any
+func (ss *stageSpan) End(err error, attrs ...attribute.KeyValue) {
+	ss.span.SetAttributes(attrs...)
+	endSpan(ss.span, err)
+}
+
+// EndRead ends the span of the extract stage, once the dataflow is read (or
+// failed), so that it covers the streaming of the rows
+func (ss *stageSpan) EndRead(df *iop.Dataflow, err error) {
+	if df == nil {
+		ss.End(err)
+		return
+	} else if err == nil {
+		err = df.Err()
+	}
+	ss.End(err, attribute.Int64("sling.rows", int64(df.Count())))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+	span.End()
+}
@@ -0,0 +1,91 @@
+package sling
+
+import (
+	"os"
+	"testing"
+
+	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	setTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer func() { tracer = noop.NewTracerProvider().Tracer("sling") }()
+
+	getAttr := func(span tracetest.SpanStub, key string) attribute.Value {
+		for _, kv := range span.Attributes {
+			if string(kv.Key) == key {
+				return kv.Value
+			}
+		}
+		return attribute.Value{}
+	}
+
+	// nested in the calling trace
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	os.Setenv("TRACEPARENT", traceParent)
+	defer os.Unsetenv("TRACEPARENT")
+
+	endRun := StartRunSpan("sling run")
+
+	task := &TaskExecution{
+		ExecID: "exec-1",
+		Type:   DbToFile,
+		Config: &Config{StreamName: "public.users", Mode: FullRefreshMode},
+	}
+	task.Config.SrcConn.Type = dbio.TypeDbPostgres
+	task.Config.TgtConn.Type = dbio.TypeFileLocal
+	task.Config.Target.Object = "file:///tmp/users.csv"
+
+	task.startSpan()
+	task.startStage("extract").End(nil)
+	task.startStage("load").End(g.Error("could not write"), attribute.Int64("sling.rows", 10))
+	task.Err = g.Error("could not write")
+	task.Status = ExecStatusError
+	task.endSpan()
+
+	endRun(nil)
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 4) {
+		return
+	}
+	extract, load, stream, run := spans[0], spans[1], spans[2], spans[3]
+
+	// hierarchy
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", run.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", run.Parent.SpanID().String())
+	assert.Equal(t, run.SpanContext.SpanID(), stream.Parent.SpanID())
+	assert.Equal(t, stream.SpanContext.SpanID(), extract.Parent.SpanID())
+	assert.Equal(t, stream.SpanContext.SpanID(), load.Parent.SpanID())
+	for _, span := range spans {
+		assert.Equal(t, run.SpanContext.TraceID(), span.SpanContext.TraceID())
+	}
+
+	// attributes
+	assert.Equal(t, "public.users", stream.Name)
+	assert.Equal(t, "public.users", getAttr(stream, "sling.stream").AsString())
+	assert.Equal(t, "postgres", getAttr(stream, "sling.source.type").AsString())
+	assert.Equal(t, "file", getAttr(stream, "sling.target.type").AsString())
+	assert.Equal(t, "exec-1", getAttr(stream, "sling.exec_id").AsString())
+	assert.Equal(t, int64(0), getAttr(stream, "sling.rows").AsInt64())
+	assert.Equal(t, "extract", extract.Name)
+	assert.Equal(t, "load", getAttr(load, "sling.stage").AsString())
+	assert.Equal(t, int64(10), getAttr(load, "sling.rows").AsInt64())
+
+	// errors
+	assert.Equal(t, codes.Ok, extract.Status.Code)
+	assert.Equal(t, codes.Error, load.Status.Code)
+	assert.Equal(t, codes.Error, stream.Status.Code)
+	assert.Equal(t, codes.Ok, run.Status.Code)
+	if assert.Len(t, load.Events, 1) {
+		assert.Equal(t, "exception", load.Events[0].Name)
+	}
+}
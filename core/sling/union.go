@@ -0,0 +1,199 @@
+package sling
+
+import (
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+)
+
+// UnionSource is a stream of the source `union` list. The streams (with
+// compatible schemas) are written to the same target, with the union of
+// their columns: columns missing from a stream are filled with nulls. The
+// streams are opened together (to get their columns), then read one after
+// the other, or concurrently with `union_concurrent`.
+//
+//	streams:
+//	  orders_all:
+//	    union:
+//	      - conn: SHARD_1   # defaults to the replication source
+//	        stream: public.orders
+//	      - conn: SHARD_2
+//	        stream: public.orders
+//	    object: public.orders_all
+//	    source_options:
+//	      union_column: _source   # the name (or conn) of the origin stream
+type UnionSource struct {
+	Name          string         `json:"name,omitempty" yaml:"name,omitempty"` // the value of the union column, defaults to the conn
+	Conn          string         `json:"conn,omitempty" yaml:"conn,omitempty"`
+	Stream        string         `json:"stream,omitempty" yaml:"stream,omitempty"`
+	SQL           string         `json:"sql,omitempty" yaml:"sql,omitempty"`
+	Select        []string       `json:"select,omitempty" yaml:"select,flow,omitempty"`
+	SourceOptions *SourceOptions `json:"source_options,omitempty" yaml:"source_options,omitempty"`
+}
+
+// Label returns the value identifying the stream in the union column
+func (us *UnionSource) Label() string {
+	switch {
+	case us.Name != "":
+		return us.Name
+	case us.Conn != "":
+		return us.Conn
+	}
+	return us.Stream
+}
+
+// validateUnion checks the union list, with the labels of the streams being distinct
+func validateUnion(union []*UnionSource) error {
+	labels := map[string]bool{}
+	for i, us := range union {
+		if us == nil || (us.Stream == "" && us.SQL == "") {
+			return g.Error("union: need to specify `stream` or `sql` for stream #%d", i+1)
+		} else if labels[strings.ToLower(us.Label())] {
+			return g.Error("union: duplicate stream `%s`, specify a distinct `name` for each stream", us.Label())
+		}
+		labels[strings.ToLower(us.Label())] = true
+	}
+	return nil
+}
+
+// prepareUnion sets the first union stream as the task source, and prepares
+// the configs of the other streams, read with the same options
+func (cfg *Config) prepareUnion() (err error) {
+	if len(cfg.Source.Union) == 0 {
+		return nil
+	} else if err = validateUnion(cfg.Source.Union); err != nil {
+		return err
+	}
+
+	// the stream options take precedence over the task options
+	unionOptions := func(us *UnionSource) (options *SourceOptions) {
+		options = &SourceOptions{}
+		g.Unmarshal(g.Marshal(us.SourceOptions), options)
+		if cfg.Source.Options != nil {
+			options.SetDefaults(*cfg.Source.Options)
+		}
+		return options
+	}
+
+	// the streams default to the task source connection
+	defaultConn := cfg.Source.Conn
+	unionConn := func(us *UnionSource) string {
+		return lo.Ternary(us.Conn != "", us.Conn, defaultConn)
+	}
+	unionSelect := func(us *UnionSource) []string {
+		return lo.Ternary(len(us.Select) > 0, us.Select, cfg.Source.Select)
+	}
+
+	first := cfg.Source.Union[0]
+	cfg.Source.Conn = unionConn(first)
+	cfg.Source.Stream = first.Stream
+	cfg.Source.Query = first.SQL
+	cfg.Source.Select = unionSelect(first)
+	cfg.Source.Options = unionOptions(first)
+
+	cfg.unionCfgs = nil
+	for i, us := range cfg.Source.Union[1:] {
+		unionCfg := &Config{
+			Source: Source{
+				Conn:        unionConn(us),
+				Stream:      us.Stream,
+				Query:       us.SQL,
+				Select:      unionSelect(us),
+				PrimaryKeyI: cfg.Source.PrimaryKeyI,
+				UpdateKey:   cfg.Source.UpdateKey,
+				Options:     unionOptions(us),
+			},
+			Target:     Target{Conn: cfg.Target.Conn, Object: cfg.Target.Object},
+			Mode:       cfg.Mode,
+			Env:        cfg.Env,
+			StreamName: cfg.StreamName,
+		}
+		if cfg.ReplicationMode() {
+			// to share the pooled connections
+			unionCfg.ReplicationStream = &ReplicationStreamConfig{Object: cfg.Target.Object}
+		}
+
+		if err = unionCfg.Prepare(); err != nil {
+			return g.Error(err, "could not prepare union stream #%d (%s)", i+2, us.Label())
+		}
+		cfg.unionCfgs = append(cfg.unionCfgs, unionCfg)
+	}
+
+	return nil
+}
+
+// startUnion reads the other union streams, and merges them with the
+// dataflow of the first stream into one dataflow
+func (t *TaskExecution) startUnion(df *iop.Dataflow) (*iop.Dataflow, error) {
+	if len(t.Config.unionCfgs) == 0 {
+		return df, nil
+	}
+
+	dfs := []*iop.Dataflow{df}
+	for i, cfg := range t.Config.unionCfgs {
+		unionDf, err := t.readUnionStream(cfg)
+		if err != nil {
+			return df, g.Error(err, "could not read union stream #%d (%s)", i+2, t.Config.Source.Union[i+1].Label())
+		}
+		dfs = append(dfs, unionDf)
+	}
+
+	t.AddCleanupTaskFirst(func() {
+		for _, df := range dfs {
+			df.Close()
+		}
+	})
+
+	labels := lo.Map(t.Config.Source.Union, func(us *UnionSource, i int) string { return us.Label() })
+	ds, err := iop.UnionDataflow(
+		dfs, labels,
+		g.PtrVal(t.Config.Source.Options.UnionColumn),
+		g.PtrVal(t.Config.Source.Options.UnionConcurrent),
+	)
+	if err != nil {
+		return df, g.Error(err, "could not union source streams")
+	}
+
+	t.SetProgress("reading %d union streams", len(dfs))
+
+	return iop.MakeDataFlow(ds)
+}
+
+// readUnionStream starts reading the union stream of the config
+func (t *TaskExecution) readUnionStream(cfg *Config) (df *iop.Dataflow, err error) {
+	child := &TaskExecution{
+		ExecID:       t.ExecID,
+		Config:       cfg,
+		Context:      g.NewContext(t.Context.Ctx),
+		Status:       ExecStatusRunning,
+		Replication:  t.Replication,
+		PBar:         NewPBar(time.Second),
+		df:           iop.NewDataflow(),
+		cleanupFuncs: []func(){},
+	}
+
+	if child.Type, err = cfg.DetermineType(); err != nil {
+		return nil, g.Error(err, "could not determine type of source %s", cfg.Source.Conn)
+	}
+	child.Config.SetDefault()
+	child.Config.IncrementalVal = t.Config.IncrementalVal
+	t.AddCleanupTaskLast(child.Cleanup)
+
+	if !cfg.SrcConn.Type.IsDb() {
+		return child.ReadFromFile(child.Config)
+	}
+
+	srcConn, err := child.getSrcDBConn(child.Context.Ctx)
+	if err != nil {
+		return nil, g.Error(err, "could not connect to source %s", cfg.Source.Conn)
+	}
+
+	if !child.isUsingPool() {
+		t.AddCleanupTaskLast(func() { srcConn.Close() })
+	}
+
+	return child.ReadFromDB(child.Config, srcConn)
+}
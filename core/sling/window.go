@@ -0,0 +1,106 @@
+package sling
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/shopspring/decimal"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+)
+
+// relativeTimeRegex matches a time relative to now, such as `-7d` or `+12h`
+var relativeTimeRegex = regexp.MustCompile(`^([+-])?(\d+)\s*(s|m|h|d|w|M|y)$`)
+
+// ParseWindowTime parses a bound of the `--since` / `--until` window: an
+// absolute date or timestamp, `now`, or a time relative to now with a unit
+// of s, m, h, d, w, M (months) or y, such as `-7d`.
+func ParseWindowTime(expr string, now time.Time) (t time.Time, err error) {
+	expr = strings.TrimSpace(expr)
+	if strings.EqualFold(expr, "now") {
+		return now, nil
+	}
+
+	if matches := relativeTimeRegex.FindStringSubmatch(expr); matches != nil {
+		num := cast.ToInt(matches[2])
+		if matches[1] == "-" {
+			num = -num
+		}
+
+		switch matches[3] {
+		case "s":
+			return now.Add(time.Duration(num) * time.Second), nil
+		case "m":
+			return now.Add(time.Duration(num) * time.Minute), nil
+		case "h":
+			return now.Add(time.Duration(num) * time.Hour), nil
+		case "d":
+			return now.AddDate(0, 0, num), nil
+		case "w":
+			return now.AddDate(0, 0, num*7), nil
+		case "M":
+			return now.AddDate(0, num, 0), nil
+		case "y":
+			return now.AddDate(num, 0, 0), nil
+		}
+	}
+
+	t, err = cast.ToTimeE(expr)
+	if err != nil {
+		return t, g.Error("invalid date or relative time: %s (e.g. `2024-01-31`, `-7d` or `now`)", expr)
+	}
+	return t, nil
+}
+
+// hasWindow returns true if a `--since` / `--until` window is set
+func (s *Source) hasWindow() bool {
+	return s.Options != nil && (g.PtrVal(s.Options.Since) != "" || g.PtrVal(s.Options.Until) != "")
+}
+
+// windowWhereCond returns the condition of the `--since` (inclusive) /
+// `--until` (exclusive) window on the update key column. Dates apply to
+// date & timestamp columns, numbers to numeric columns.
+func windowWhereCond(dialect dbio.Type, updateCol iop.Column, since, until string, now time.Time) (cond string, err error) {
+	formatValue := func(expr string) (string, error) {
+		switch {
+		case updateCol.IsDate() || updateCol.IsDatetime():
+			t, err := ParseWindowTime(expr, now)
+			if err != nil {
+				return "", err
+			}
+			return iop.FormatValue(t, updateCol, dialect), nil
+		case updateCol.IsNumber():
+			// parsed as decimal, to keep large integers exact (floats are not above 2^53)
+			num, err := decimal.NewFromString(strings.TrimSpace(expr))
+			if err != nil {
+				return "", g.Error("invalid number for numeric update key %s: %s", updateCol.Name, expr)
+			}
+			return num.String(), nil
+		}
+		return "", g.Error("update key %s (%s) is not compatible with --since / --until, it must be a date, timestamp or number column", updateCol.Name, updateCol.Type)
+	}
+
+	conds := []string{}
+	for _, bound := range []struct{ expr, op string }{{since, ">="}, {until, "<"}} {
+		if strings.TrimSpace(bound.expr) == "" {
+			continue
+		}
+
+		value, err := formatValue(bound.expr)
+		if err != nil {
+			return "", err
+		}
+
+		conds = append(conds, g.R(
+			dialect.GetTemplateValue("core.incremental_where"),
+			"update_key", dialect.Quote(updateCol.Name, false),
+			"value", value,
+			"gt", bound.op,
+		))
+	}
+
+	return strings.Join(conds, " and "), nil
+}
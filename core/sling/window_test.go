@@ -0,0 +1,93 @@
+package sling
+
+import (
+	"testing"
+	"time"
+
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWindowTime(t *testing.T) {
+	now := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	cases := map[string]time.Time{
+		"now":        now,
+		"NOW":        now,
+		"-7d":        time.Date(2024, 3, 8, 10, 30, 0, 0, time.UTC),
+		"-12h":       time.Date(2024, 3, 14, 22, 30, 0, 0, time.UTC),
+		"-30m":       time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC),
+		"-2w":        time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC),
+		"-1M":        time.Date(2024, 2, 15, 10, 30, 0, 0, time.UTC),
+		"-1y":        time.Date(2023, 3, 15, 10, 30, 0, 0, time.UTC),
+		"+1d":        time.Date(2024, 3, 16, 10, 30, 0, 0, time.UTC),
+		"2024-01-31": time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+
+		"2024-01-31T08:00:00Z": time.Date(2024, 1, 31, 8, 0, 0, 0, time.UTC),
+	}
+
+	for expr, expected := range cases {
+		value, err := ParseWindowTime(expr, now)
+		if assert.NoError(t, err, expr) {
+			assert.True(t, expected.Equal(value), "%s: %s != %s", expr, expected, value)
+		}
+	}
+
+	for _, expr := range []string{"-7x", "yesterday", "7 days ago"} {
+		_, err := ParseWindowTime(expr, now)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestWindowWhereCond(t *testing.T) {
+	now := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	col := iop.Column{Name: "updated_at", Type: iop.TimestampType}
+	cond, err := windowWhereCond(dbio.TypeDbPostgres, col, "-7d", "now", now)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `"updated_at" >= '2024-03-08 10:30:00.000000' and "updated_at" < '2024-03-15 10:30:00.000000'`, cond)
+	}
+
+	// only since
+	col = iop.Column{Name: "dt", Type: iop.DateType}
+	cond, err = windowWhereCond(dbio.TypeDbPostgres, col, "2024-01-01", "", now)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `"dt" >= '2024-01-01'`, cond)
+	}
+
+	// only until, mysql quoting
+	col = iop.Column{Name: "dt", Type: iop.DateType}
+	cond, err = windowWhereCond(dbio.TypeDbMySQL, col, "", "-1d", now)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "`dt` < '2024-03-14'", cond)
+	}
+
+	// numeric update key
+	col = iop.Column{Name: "id", Type: iop.BigIntType}
+	cond, err = windowWhereCond(dbio.TypeDbPostgres, col, "100", "200", now)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `"id" >= 100 and "id" < 200`, cond)
+	}
+
+	// integers above 2^53 are kept exact
+	cond, err = windowWhereCond(dbio.TypeDbPostgres, col, "9007199254740993", "", now)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `"id" >= 9007199254740993`, cond)
+	}
+
+	col = iop.Column{Name: "amount", Type: iop.DecimalType}
+	cond, err = windowWhereCond(dbio.TypeDbPostgres, col, "10.25", "", now)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `"amount" >= 10.25`, cond)
+	}
+	col = iop.Column{Name: "id", Type: iop.BigIntType}
+
+	_, err = windowWhereCond(dbio.TypeDbPostgres, col, "-7d", "", now)
+	assert.Error(t, err)
+
+	// incompatible update key
+	col = iop.Column{Name: "name", Type: iop.StringType}
+	_, err = windowWhereCond(dbio.TypeDbPostgres, col, "-7d", "", now)
+	assert.ErrorContains(t, err, "not compatible")
+}
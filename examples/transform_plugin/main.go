@@ -0,0 +1,39 @@
+// A sample transform plugin, which upper-cases the `name` column, drops the
+// rows with a negative `amount` and fails the rows without a name. Build it with:
+//
+//	go build -buildmode=plugin -o transform.so ./examples/transform_plugin
+//
+// and run sling with `--transform-plugin transform.so`. Transform is called
+// concurrently by the streams, so it must be safe for concurrent use.
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Transform receives the row as a map of the column name to the value, and
+// returns the transformed row. Returning a nil row drops it, and an error
+// fails the row.
+func Transform(row map[string]any) (map[string]any, error) {
+	name, _ := row["name"].(string)
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("missing name for row %v", row)
+	}
+	row["name"] = strings.ToUpper(name)
+
+	switch amount := row["amount"].(type) {
+	case int64:
+		if amount < 0 {
+			return nil, nil
+		}
+	case float64:
+		if amount < 0 {
+			return nil, nil
+		}
+	}
+
+	return row, nil
+}
+
+func main() {}